@@ -24,8 +24,19 @@ import (
 	"path/filepath"
 )
 
+// ImageBuilder builds container images using a container runtime.
+type ImageBuilder interface {
+	BuildImage(ctx context.Context, tag, dockerfile, contextDir string, buildArgs ...string) error
+}
+
 // Build constructs a container for one of the project's microservices.
 func Build(ctx context.Context, name, binary string) error {
+	return BuildWith(ctx, nil, name, binary)
+}
+
+// BuildWith constructs a container for one of the project's microservices using the provided builder.
+// If builder is nil, the docker CLI is used.
+func BuildWith(ctx context.Context, builder ImageBuilder, name, binary string) error {
 	tempDir, err := os.MkdirTemp("", "oss-rebuild")
 	if err != nil {
 		return err
@@ -40,6 +51,9 @@ func Build(ctx context.Context, name, binary string) error {
 	// Build the Docker image.
 	relpath := "build/package/Dockerfile." + name
 	dockerfile, _ := filepath.Abs(relpath)
+	if builder != nil {
+		return builder.BuildImage(ctx, name, dockerfile, tempDir, "BINARY="+name)
+	}
 	cmd := exec.CommandContext(ctx, "docker", "build", "--build-arg", "BINARY="+name, "--tag", name, "--file", dockerfile, tempDir)
 	cmd.Stdout = log.Writer()
 	cmd.Stderr = log.Writer()
@@ -16,29 +16,61 @@ package main
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"slices"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	gcs "cloud.google.com/go/storage"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/api/apipb"
 	"github.com/google/oss-rebuild/internal/api/apiservice"
 	"github.com/google/oss-rebuild/internal/api/inferenceservice"
 	"github.com/google/oss-rebuild/internal/api/rebuilderservice"
+	"github.com/google/oss-rebuild/internal/api/runnerservice"
+	"github.com/google/oss-rebuild/internal/feed"
 	"github.com/google/oss-rebuild/internal/gcb"
 	"github.com/google/oss-rebuild/internal/httpegress"
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/kube"
+	"github.com/google/oss-rebuild/internal/mirror"
+	"github.com/google/oss-rebuild/internal/notify"
+	"github.com/google/oss-rebuild/internal/oci"
+	"github.com/google/oss-rebuild/internal/quarantine"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/internal/telemetry"
 	"github.com/google/oss-rebuild/internal/uri"
+	"github.com/google/oss-rebuild/internal/verifier"
 	"github.com/google/oss-rebuild/pkg/kmsdsse"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/benchmark"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/pkg/errors"
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"google.golang.org/api/cloudbuild/v1"
 	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 var (
@@ -47,16 +79,81 @@ var (
 	buildLocalURL         = flag.String("build-local-url", "", "URL of the rebuild service")
 	inferenceURL          = flag.String("inference-url", "", "URL of the inference service")
 	signingKeyVersion     = flag.String("signing-key-version", "", "Resource name of the signing CryptoKeyVersion")
-	metadataBucket        = flag.String("metadata-bucket", "", "GCS bucket for rebuild metadata")
-	attestationBucket     = flag.String("attestation-bucket", "", "GCS bucket to which to publish rebuild attestation")
+	signingKeyFile        = flag.String("signing-key-file", "", "if provided, the path of a PEM-encoded ECDSA private key with which to sign attestations in place of the KMS signing-key-version")
+	metadataBucket        = flag.String("metadata-bucket", "", "GCS bucket or store URL (gs://, s3://, file://) for rebuild metadata")
+	attestationBucket     = flag.String("attestation-bucket", "", "GCS bucket or store URL (gs://, s3://, file://) to which to publish rebuild attestation")
 	logsBucket            = flag.String("logs-bucket", "", "GCS bucket for rebuild logs")
 	prebuildBucket        = flag.String("prebuild-bucket", "", "GCS bucket from which prebuilt build tools are stored")
 	buildDefRepo          = flag.String("build-def-repo", "", "repository for build definitions")
 	buildDefRepoDir       = flag.String("build-def-repo-dir", ".", "relpath within the build definitions repository")
+	selfURL               = flag.String("self-url", "http://localhost:8080", "the URL at which this service is reachable, to which the tasks executing the targets of batch requests are delivered")
+	benchmarkRepo         = flag.String("benchmark-repo", "", "if provided, the git repository from which the benchmarks referenced by batch requests are read")
+	kubeNamespace         = flag.String("kube-namespace", "", "if provided, the Kubernetes namespace in which to run rebuilds as Jobs instead of on Cloud Build")
+	kubeServiceAccount    = flag.String("kube-service-account", "", "the Kubernetes service account as which to run rebuild Jobs")
+	kubeNodeSelector      = flag.String("kube-node-selector", "", "comma-separated label=value pairs constraining the nodes on which rebuild Jobs run")
+	kubeUploaderImage     = flag.String("kube-uploader-image", "gcr.io/cloud-builders/gsutil", "the image used by rebuild Jobs to upload outputs to the metadata store")
+	windowsRunnerURL      = flag.String("windows-runner-url", "", "if provided, URL of the runner service for rebuilds that must be executed on Windows")
+	macosRunnerURL        = flag.String("macos-runner-url", "", "if provided, URL of the runner service for rebuilds that must be executed on macOS")
+	linuxRunnerURL        = flag.String("linux-runner-url", "", "if provided, URL of the runner service on which Linux rebuilds are executed in place of Cloud Build or Kubernetes")
+	buildCacheBucket      = flag.String("build-cache-bucket", "", "if provided, the GCS bucket or store URL (gs://, s3://, file://) in which the outputs of remote builds are cached for reuse")
+	grpcPort              = flag.Int("grpc-port", 0, "if provided, the port on which to additionally serve the gRPC API")
+	attestationLayout     = flag.String("attestation-layout", string(verifier.LayoutV1), "the arrangement of objects written to the attestation bucket. Options: v1 (bundles only), v2 (bundles and per-package index files). Indexes of packages attested under v1 are created using 'ctl migrate-index'")
 	overwriteAttestations = flag.Bool("overwrite-attestations", false, "whether to overwrite existing attestations when writing to GCS")
+	notifyWebhookURL      = flag.String("notify-webhook-url", "", "if provided, the URL to which rebuild event notifications are posted as JSON")
+	notifySlackURL        = flag.String("notify-slack-url", "", "if provided, the Slack incoming webhook URL to which rebuild event notifications are posted")
+	notifySMTPAddr        = flag.String("notify-smtp-addr", "", "if provided, the host:port of the SMTP server through which rebuild event notifications are emailed")
+	notifyEmailFrom       = flag.String("notify-email-from", "", "the sender address of notification emails")
+	notifyEmailTo         = flag.String("notify-email-to", "", "comma-separated recipient addresses of notification emails")
+	notifyEvents          = flag.String("notify-events", "", "comma-separated kinds of event of which to notify. Options: regression, run_complete, watched_failure, quarantined, artifact_mutated. Defaults to all")
+	quarantineThreshold   = flag.Int("quarantine-risk-threshold", 50, "the risk score at or above which a mismatched smoketest result is quarantined for review. Zero disables quarantine")
+	reviewAudience        = flag.String("review-audience", "", "the audience, typically the service URL, of the ID tokens with which quarantine reviewers and annotation authors authenticate. Reviews and annotations are rejected if not provided")
+	reviewers             = flag.String("reviewers", "", "comma-separated email addresses of the callers permitted to review quarantined results. Reviews are rejected if not provided")
+	advisoryRepo          = flag.String("advisory-repo", "", "if provided, the GitHub repository (owner/name) in which to draft a security advisory when a quarantined result is escalated. The token is read from GITHUB_TOKEN")
+	registryMirrors       = flag.String("registry-mirrors", "", "if provided, the path of a YAML file configuring the private registry mirrors from which packages are read")
+	notifyWatchFile       = flag.String("notify-watch-file", "", "if provided, a file listing the packages, as lines of '<ecosystem> <package>', whose failures are notified")
+	upstreamArchive       = flag.String("upstream-archive-bucket", "", "if provided, the GCS bucket or store URL (gs://, s3://, file://) in which upstream artifacts are retained so that they remain verifiable once removed from their registry")
+	mavenKeyring          = flag.String("maven-keyring", "", "if provided, the path of an armored PGP keyring of the publisher keys trusted to sign Maven artifacts. Maven signatures are only verified if provided")
+	verifyCargoVCSInfo    = flag.Bool("verify-cargo-vcs-info", false, "whether to compare the commit recorded in published crates against the commit from which they were rebuilt, recording the outcome as an upstream check")
+	lineEndingPaths       = flag.String("stabilize-line-endings", "", "comma-separated path patterns of the text files whose CRLF line endings are normalized to LF when comparing artifacts. Patterns without a '/' match file names, e.g. '*.md,*.txt,package/lib/*.js'")
+	upstreamFallbacks     = flag.String("upstream-fallbacks", "", "comma-separated sources, consulted in order, from which to read upstream artifacts no longer served by their registry. Options: wayback, or <upstream-prefix>=<mirror-prefix> for a mirror serving artifacts at rewritten URLs. An artifact read from these is only used if it matches a digest previously observed from its registry")
 )
 
-var httpcfg = httpegress.Config{}
+var (
+	httpcfg  = httpegress.Config{}
+	queuecfg = taskqueue.Config{}
+)
+
+// batchQueue delivers the targets of batch requests to batchTargetURL.
+var (
+	batchQueue     taskqueue.Queue
+	batchTargetURL *url.URL
+)
+
+// serviceClient returns a client for calling the internal service at rawURL.
+// Services addressed over plain HTTP, as in a local development stack, are
+// called without identity tokens.
+func serviceClient(ctx context.Context, rawURL string) (*url.URL, httpx.BasicClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parsing service URL")
+	}
+	if u.Scheme == "http" {
+		return u, telemetry.WrapClient(http.DefaultClient), nil
+	}
+	client, err := idtoken.NewClient(ctx, rawURL)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "initializing service client")
+	}
+	return u, telemetry.WrapClient(client), nil
+}
+
+// storeURL returns the store URL for a flag value, treating a bare name as a GCS bucket.
+func storeURL(v string) string {
+	if strings.Contains(v, "://") {
+		return v
+	}
+	return "gs://" + v
+}
 
 func RebuildSmoketestInit(ctx context.Context) (*apiservice.RebuildSmoketestDeps, error) {
 	var d apiservice.RebuildSmoketestDeps
@@ -65,19 +162,63 @@ func RebuildSmoketestInit(ctx context.Context) (*apiservice.RebuildSmoketestDeps
 	if err != nil {
 		return nil, errors.Wrap(err, "creating firestore client")
 	}
-	u, err := url.Parse(*buildLocalURL)
-	if err != nil {
-		return nil, errors.Wrap(err, "parsing build local URL")
-	}
-	runclient, err := idtoken.NewClient(ctx, *buildLocalURL)
+	u, runclient, err := serviceClient(ctx, *buildLocalURL)
 	if err != nil {
 		return nil, errors.Wrap(err, "initializing build local client")
 	}
 	d.SmoketestStub = api.StubFromHandler(runclient, *u.JoinPath("smoketest"), rebuilderservice.RebuildSmoketest)
 	d.VersionStub = api.StubFromHandler(runclient, *u.JoinPath("version"), rebuilderservice.Version)
+	d.Notifier, d.Watched, err = makeNotifier()
+	if err != nil {
+		return nil, errors.Wrap(err, "configuring notifications")
+	}
+	d.QuarantineThreshold = *quarantineThreshold
 	return &d, nil
 }
 
+// makeNotifier returns the configured notifier, or nil if none is configured, along with the watched packages.
+func makeNotifier() (notify.Notifier, feed.Tracked, error) {
+	var sinks notify.Multi
+	if *notifyWebhookURL != "" {
+		sinks = append(sinks, &notify.Webhook{Client: http.DefaultClient, URL: *notifyWebhookURL})
+	}
+	if *notifySlackURL != "" {
+		sinks = append(sinks, &notify.Slack{Client: http.DefaultClient, URL: *notifySlackURL})
+	}
+	if *notifySMTPAddr != "" {
+		if *notifyEmailFrom == "" || *notifyEmailTo == "" {
+			return nil, nil, errors.New("notify-email-from and notify-email-to are required with notify-smtp-addr")
+		}
+		sinks = append(sinks, &notify.Email{Addr: *notifySMTPAddr, From: *notifyEmailFrom, To: strings.Split(*notifyEmailTo, ",")})
+	}
+	var watched feed.Tracked
+	if *notifyWatchFile != "" {
+		f, err := os.Open(*notifyWatchFile)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "opening watch file")
+		}
+		defer f.Close()
+		watched, err = feed.ReadTracked(f)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "reading watch file")
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, watched, nil
+	}
+	if *notifyEvents == "" {
+		return sinks, watched, nil
+	}
+	f := &notify.Filter{Notifier: sinks}
+	for _, k := range strings.Split(*notifyEvents, ",") {
+		if !slices.Contains(notify.Kinds, notify.Kind(k)) {
+			return nil, nil, errors.Errorf("unknown notification event: %s", k)
+		}
+		f.Kinds = append(f.Kinds, notify.Kind(k))
+	}
+	return f, watched, nil
+}
+
 func makeKMSSigner(ctx context.Context, cryptoKeyVersion string) (*dsse.EnvelopeSigner, error) {
 	kc, err := kms.NewKeyManagementClient(ctx)
 	if err != nil {
@@ -98,6 +239,124 @@ func makeKMSSigner(ctx context.Context, cryptoKeyVersion string) (*dsse.Envelope
 	return dsseSigner, nil
 }
 
+// fileSigner signs with an ECDSA private key held in memory.
+type fileSigner struct {
+	key *ecdsa.PrivateKey
+	id  string
+}
+
+var _ dsse.SignerVerifier = &fileSigner{}
+
+func (s *fileSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+}
+
+func (s *fileSigner) Verify(ctx context.Context, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(&s.key.PublicKey, digest[:], sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func (s *fileSigner) KeyID() (string, error) {
+	return s.id, nil
+}
+
+func (s *fileSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+// makeFileSigner creates a signer from the PEM-encoded PKCS#8 ECDSA private key at path.
+// The key ID is the SHA-256 digest of the DER-encoded public key.
+func makeFileSigner(path string) (*dsse.EnvelopeSigner, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading signing key")
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block in signing key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing signing key")
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("unsupported signing key type: %T", parsed)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding public key")
+	}
+	digest := sha256.Sum256(pub)
+	return dsse.NewEnvelopeSigner(&fileSigner{key: key, id: hex.EncodeToString(digest[:])})
+}
+
+func makeKubeOptions() (*rebuild.KubeOptions, error) {
+	client, err := kube.InClusterService()
+	if err != nil {
+		return nil, errors.Wrap(err, "creating kubernetes client")
+	}
+	opts := &rebuild.KubeOptions{
+		Client:         client,
+		Namespace:      *kubeNamespace,
+		ServiceAccount: *kubeServiceAccount,
+		UploaderImage:  *kubeUploaderImage,
+	}
+	if *kubeNodeSelector != "" {
+		opts.NodeSelector = make(map[string]string)
+		for _, pair := range strings.Split(*kubeNodeSelector, ",") {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, errors.Errorf("invalid node selector: %s", pair)
+			}
+			opts.NodeSelector[k] = v
+		}
+	}
+	return opts, nil
+}
+
+// reviewerList returns the identities permitted to review quarantined results.
+func reviewerList() []string {
+	var ids []string
+	for _, r := range strings.Split(*reviewers, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			ids = append(ids, r)
+		}
+	}
+	return ids
+}
+
+// makeUpstreamOptions returns the configured sources of upstream artifacts.
+func makeUpstreamOptions(ctx context.Context) (verifier.UpstreamOptions, error) {
+	var opts verifier.UpstreamOptions
+	if *upstreamArchive != "" {
+		store, err := rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, ""), storeURL(*upstreamArchive))
+		if err != nil {
+			return opts, errors.Wrap(err, "creating upstream archive store")
+		}
+		opts.Archive = store
+		// NOTE: Retained artifacts take precedence over external sources.
+		opts.Fallbacks = append(opts.Fallbacks, verifier.StoreSource{Store: store})
+	}
+	if *upstreamFallbacks == "" {
+		return opts, nil
+	}
+	for _, f := range strings.Split(*upstreamFallbacks, ",") {
+		if f == "wayback" {
+			opts.Fallbacks = append(opts.Fallbacks, verifier.WaybackSource{})
+		} else if prefix, mirrorPrefix, ok := strings.Cut(f, "="); ok && prefix != "" && mirrorPrefix != "" {
+			opts.Fallbacks = append(opts.Fallbacks, verifier.MirrorSource{Prefix: prefix, URL: mirrorPrefix})
+		} else {
+			return opts, errors.Errorf("invalid upstream fallback %q", f)
+		}
+	}
+	return opts, nil
+}
+
 func RebuildPackageInit(ctx context.Context) (*apiservice.RebuildPackageDeps, error) {
 	var d apiservice.RebuildPackageDeps
 	var err error
@@ -105,15 +364,54 @@ func RebuildPackageInit(ctx context.Context) (*apiservice.RebuildPackageDeps, er
 	if err != nil {
 		return nil, errors.Wrap(err, "making http client")
 	}
-	d.Signer, err = makeKMSSigner(ctx, *signingKeyVersion)
+	if *registryMirrors != "" {
+		d.Mirrors, err = mirror.Load(*registryMirrors)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading registry mirrors")
+		}
+		d.HTTPClient, err = d.Mirrors.Client(d.HTTPClient)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring registry mirror credentials")
+		}
+	}
+	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating signer")
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	if *signingKeyFile != "" {
+		d.Signer, err = makeFileSigner(*signingKeyFile)
+	} else {
+		d.Signer, err = makeKMSSigner(ctx, *signingKeyVersion)
 	}
-	svc, err := cloudbuild.NewService(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating CloudBuild service")
+		return nil, errors.Wrap(err, "creating signer")
 	}
-	d.GCBClient = &gcb.Service{Service: svc}
+	// NOTE: Cloud Build is not required when Linux rebuilds are executed by a runner.
+	if *linuxRunnerURL == "" {
+		svc, err := cloudbuild.NewService(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating CloudBuild service")
+		}
+		d.GCBClient = &gcb.Service{Service: svc}
+	}
+	if *kubeNamespace != "" {
+		d.KubeOptions, err = makeKubeOptions()
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring kubernetes")
+		}
+	}
+	d.PlatformRunStubs = make(map[rebuild.Platform]api.StubT[schema.PlatformRunRequest, schema.PlatformRunResponse])
+	for p, rawURL := range map[rebuild.Platform]string{rebuild.WindowsPlatform: *windowsRunnerURL, rebuild.MacOSPlatform: *macosRunnerURL, rebuild.LinuxPlatform: *linuxRunnerURL} {
+		if rawURL == "" {
+			continue
+		}
+		u, runclient, err := serviceClient(ctx, rawURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "initializing %s runner client", p)
+		}
+		d.PlatformRunStubs[p] = api.StubFromHandler(runclient, *u.JoinPath("run"), runnerservice.Run)
+	}
+	d.ImageResolver = &oci.Resolver{Client: d.HTTPClient}
 	d.BuildProject = *project
 	d.BuildServiceAccount = *buildRemoteIdentity
 	d.UtilPrebuildBucket = *prebuildBucket
@@ -127,24 +425,77 @@ func RebuildPackageInit(ctx context.Context) (*apiservice.RebuildPackageDeps, er
 		Ref:  plumbing.Main.String(),
 		Dir:  path.Clean(*buildDefRepoDir),
 	}
-	d.AttestationStore, err = rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, ""), "gs://"+*attestationBucket)
+	d.AttestationStore, err = rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, ""), storeURL(*attestationBucket))
 	if err != nil {
 		return nil, errors.Wrap(err, "creating attestation uploader")
 	}
 	d.MetadataBuilder = func(ctx context.Context, id string) (rebuild.AssetStore, error) {
-		return rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, id), "gs://"+*metadataBucket)
+		return rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, id), storeURL(*metadataBucket))
 	}
 	d.OverwriteAttestations = *overwriteAttestations
-	u, err := url.Parse(*inferenceURL)
+	switch layout := verifier.StorageLayout(*attestationLayout); layout {
+	case verifier.LayoutV1, verifier.LayoutV2:
+		d.AttestationLayout = layout
+	default:
+		return nil, errors.Errorf("unknown attestation layout: %s", layout)
+	}
+	d.VerifyCargoVCSInfo = *verifyCargoVCSInfo
+	if *mavenKeyring != "" {
+		f, err := os.Open(*mavenKeyring)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening maven keyring")
+		}
+		defer f.Close()
+		d.MavenKeyring, err = openpgp.ReadArmoredKeyRing(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading maven keyring")
+		}
+	}
+	if *lineEndingPaths != "" {
+		d.Stabilization.LineEndingPaths = strings.Split(*lineEndingPaths, ",")
+		if err := d.Stabilization.Validate(); err != nil {
+			return nil, errors.Wrap(err, "parsing stabilize-line-endings")
+		}
+	}
+	d.Upstream, err = makeUpstreamOptions(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsing inference URL")
+		return nil, err
+	}
+	if *buildCacheBucket != "" {
+		store, err := rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, ""), storeURL(*buildCacheBucket))
+		if err != nil {
+			return nil, errors.Wrap(err, "creating build cache store")
+		}
+		d.BuildCache = &rebuild.BuildCache{Store: store}
 	}
-	u = u.JoinPath("infer")
-	runclient, err := idtoken.NewClient(ctx, *inferenceURL)
+	u, runclient, err := serviceClient(ctx, *inferenceURL)
 	if err != nil {
 		return nil, errors.Wrap(err, "initializing inference client")
 	}
-	d.InferStub = api.StubFromHandler(runclient, *u, inferenceservice.Infer)
+	d.InferStub = api.StubFromHandler(runclient, *u.JoinPath("infer"), inferenceservice.Infer)
+	d.Notifier, d.Watched, err = makeNotifier()
+	if err != nil {
+		return nil, errors.Wrap(err, "configuring notifications")
+	}
+	return &d, nil
+}
+
+func LogsInit(ctx context.Context) (*apiservice.LogsDeps, error) {
+	var d apiservice.LogsDeps
+	svc, err := cloudbuild.NewService(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating CloudBuild service")
+	}
+	d.GCBClient = &gcb.Service{Service: svc}
+	d.GCSClient, err = gcs.NewClient(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating GCS client")
+	}
+	d.BuildLogsBucket = *logsBucket
+	d.MetadataBuilder = func(ctx context.Context, id string) (rebuild.AssetStore, error) {
+		return rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, id), storeURL(*metadataBucket))
+	}
+	d.PollInterval = 5 * time.Second
 	return &d, nil
 }
 
@@ -156,22 +507,14 @@ func VersionInit(ctx context.Context) (*apiservice.VersionDeps, error) {
 		return nil, errors.Wrap(err, "creating firestore client")
 	}
 	{
-		u, err := url.Parse(*buildLocalURL)
-		if err != nil {
-			return nil, errors.Wrap(err, "parsing build local URL")
-		}
-		runclient, err := idtoken.NewClient(ctx, *buildLocalURL)
+		u, runclient, err := serviceClient(ctx, *buildLocalURL)
 		if err != nil {
 			return nil, errors.Wrap(err, "initializing build local client")
 		}
 		d.BuildLocalVersionStub = api.StubFromHandler(runclient, *u.JoinPath("version"), rebuilderservice.Version)
 	}
 	{
-		u, err := url.Parse(*inferenceURL)
-		if err != nil {
-			return nil, errors.Wrap(err, "parsing inference URL")
-		}
-		runclient, err := idtoken.NewClient(ctx, *inferenceURL)
+		u, runclient, err := serviceClient(ctx, *inferenceURL)
 		if err != nil {
 			return nil, errors.Wrap(err, "initializing inference client")
 		}
@@ -187,16 +530,231 @@ func CreateRunInit(ctx context.Context) (*apiservice.CreateRunDeps, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "creating firestore client")
 	}
+	{
+		u, runclient, err := serviceClient(ctx, *buildLocalURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing build local client")
+		}
+		d.BuildLocalVersionStub = api.StubFromHandler(runclient, *u.JoinPath("version"), rebuilderservice.Version)
+	}
+	{
+		u, runclient, err := serviceClient(ctx, *inferenceURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing inference client")
+		}
+		d.InferenceVersionStub = api.StubFromHandler(runclient, *u.JoinPath("version"), inferenceservice.Version)
+	}
+	d.BuildDefRepo = *buildDefRepo
+	d.PrebuildBucket = *prebuildBucket
+	return &d, nil
+}
+
+func BatchInit(ctx context.Context) (*apiservice.BatchDeps, error) {
+	var d apiservice.BatchDeps
+	var err error
+	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	d.CreateRunStub = api.Unary(CreateRunInit, apiservice.CreateRun)
+	d.Queue = batchQueue
+	d.TargetURL = batchTargetURL
+	if *benchmarkRepo != "" {
+		d.LoadBenchmark = loadBenchmark
+	}
+	d.Notifier, _, err = makeNotifier()
+	if err != nil {
+		return nil, errors.Wrap(err, "configuring notifications")
+	}
+	return &d, nil
+}
+
+// loadBenchmark reads the targets of the benchmark referenced as "<path>[@<ref>]" from the benchmark repository.
+func loadBenchmark(ctx context.Context, ref string) ([]rebuild.Target, error) {
+	name, rev, _ := strings.Cut(ref, "@")
+	repo, err := benchmark.NewGitRepository(ctx, *benchmarkRepo, rev)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening benchmark repository")
+	}
+	set, err := repo.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	var targets []rebuild.Target
+	for _, p := range set.Packages {
+		for _, v := range p.Versions {
+			targets = append(targets, rebuild.Target{Ecosystem: rebuild.Ecosystem(p.Ecosystem), Package: p.Name, Version: v})
+		}
+	}
+	return targets, nil
+}
+
+func BatchTargetInit(ctx context.Context) (*apiservice.BatchTargetDeps, error) {
+	var d apiservice.BatchTargetDeps
+	var err error
+	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	d.SmoketestStub = api.Unary(RebuildSmoketestInit, apiservice.RebuildSmoketest)
+	d.RebuildStub = api.Unary(RebuildPackageInit, apiservice.RebuildPackage)
+	d.Notifier, _, err = makeNotifier()
+	if err != nil {
+		return nil, errors.Wrap(err, "configuring notifications")
+	}
+	return &d, nil
+}
+
+func BatchStatusInit(ctx context.Context) (*apiservice.BatchStatusDeps, error) {
+	var d apiservice.BatchStatusDeps
+	var err error
+	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	return &d, nil
+}
+
+func StatusInit(ctx context.Context) (*apiservice.StatusDeps, error) {
+	var d apiservice.StatusDeps
+	var err error
+	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	return &d, nil
+}
+
+func StatsInit(ctx context.Context) (*apiservice.StatsDeps, error) {
+	var d apiservice.StatsDeps
+	var err error
+	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	return &d, nil
+}
+
+func AnnotateInit(ctx context.Context) (*apiservice.AnnotateDeps, error) {
+	var d apiservice.AnnotateDeps
+	var err error
+	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	return &d, nil
+}
+
+func QuarantineReviewInit(ctx context.Context) (*apiservice.QuarantineReviewDeps, error) {
+	var d apiservice.QuarantineReviewDeps
+	var err error
+	d.FirestoreClient, err = firestore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating firestore client")
+	}
+	if *advisoryRepo != "" {
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			return nil, errors.New("GITHUB_TOKEN is required with advisory-repo")
+		}
+		d.AdvisoryDrafter = &quarantine.GitHubAdvisories{Client: http.DefaultClient, Repo: *advisoryRepo, Token: token}
+	}
+	d.Notifier, _, err = makeNotifier()
+	if err != nil {
+		return nil, errors.Wrap(err, "configuring notifications")
+	}
+	return &d, nil
+}
+
+// withHeaders sets the provided response headers before serving the request with h.
+func withHeaders(h http.Handler, headers map[string]string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		for k, v := range headers {
+			rw.Header().Set(k, v)
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+func SBOMInit(ctx context.Context) (*apiservice.SBOMDeps, error) {
+	var d apiservice.SBOMDeps
+	d.BatchStub = api.Unary(BatchInit, apiservice.Batch)
 	return &d, nil
 }
 
 func main() {
 	httpcfg.RegisterFlags(flag.CommandLine)
+	queuecfg.RegisterFlags(flag.CommandLine)
 	flag.Parse()
-	http.HandleFunc("/smoketest", api.Handler(RebuildSmoketestInit, apiservice.RebuildSmoketest))
-	http.HandleFunc("/rebuild", api.Handler(RebuildPackageInit, apiservice.RebuildPackage))
-	http.HandleFunc("/version", api.Handler(VersionInit, apiservice.Version))
-	http.HandleFunc("/runs", api.Handler(CreateRunInit, apiservice.CreateRun))
+	metrics, err := telemetry.Setup(context.Background())
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "initializing telemetry"))
+	}
+	http.Handle("/metrics", metrics)
+	{
+		ctx := context.Background()
+		u, client, err := serviceClient(ctx, *selfURL)
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "initializing batch task client"))
+		}
+		batchTargetURL = u.JoinPath("batch", "target")
+		if *project != "" {
+			fs, err := firestore.NewClient(ctx, *project)
+			if err != nil {
+				log.Fatalln(errors.Wrap(err, "creating firestore client"))
+			}
+			queuecfg.DeadLetters = &taskqueue.FirestoreDeadLetters{Client: fs}
+		}
+		batchQueue, err = taskqueue.MakeQueue(ctx, queuecfg, client)
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "creating task queue"))
+		}
+	}
+	http.Handle("/smoketest", telemetry.WrapHandler(api.Handler(RebuildSmoketestInit, apiservice.RebuildSmoketest), "smoketest"))
+	http.Handle("/rebuild", telemetry.WrapHandler(api.Handler(RebuildPackageInit, apiservice.RebuildPackage), "rebuild"))
+	http.Handle("/version", telemetry.WrapHandler(api.Handler(VersionInit, apiservice.Version), "version"))
+	http.Handle("/logs", telemetry.WrapHandler(api.StreamHandler(LogsInit, apiservice.Logs), "logs"))
+	http.Handle("/runs", telemetry.WrapHandler(api.Handler(CreateRunInit, apiservice.CreateRun), "runs"))
+	http.Handle("/batch", telemetry.WrapHandler(api.Handler(BatchInit, apiservice.Batch), "batch"))
+	http.Handle("/batch/target", telemetry.WrapHandler(api.Handler(BatchTargetInit, apiservice.BatchTarget), "batch_target"))
+	http.Handle("/batch/status", telemetry.WrapHandler(api.Handler(BatchStatusInit, apiservice.BatchStatus), "batch_status"))
+	http.Handle("/sbom", telemetry.WrapHandler(api.Handler(SBOMInit, apiservice.SBOM), "sbom"))
+	http.Handle("/annotate", telemetry.WrapHandler(api.Authenticated(api.Handler(AnnotateInit, apiservice.Annotate), *reviewAudience, idtoken.Validate), "annotate"))
+	http.Handle("/quarantine/review", telemetry.WrapHandler(api.Authenticated(api.Authorized(api.Handler(QuarantineReviewInit, apiservice.QuarantineReview), reviewerList()), *reviewAudience, idtoken.Validate), "quarantine_review"))
+	http.Handle("/status", telemetry.WrapHandler(api.Handler(StatusInit, apiservice.Status), "status"))
+	http.Handle("/badge", telemetry.WrapHandler(withHeaders(api.StreamHandler(StatusInit, apiservice.Badge), map[string]string{
+		"Content-Type":  "image/svg+xml",
+		"Cache-Control": "public, max-age=300",
+	}), "badge"))
+	http.Handle("/stats", telemetry.WrapHandler(withHeaders(api.Handler(StatsInit, apiservice.Stats), map[string]string{
+		"Cache-Control": "public, max-age=300",
+	}), "stats"))
+	if *grpcPort != 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *grpcPort))
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "listening for gRPC"))
+		}
+		s := grpc.NewServer()
+		apipb.RegisterRebuildServiceServer(s, &apiservice.GRPCServer{
+			RebuildPackageStub: api.Unary(RebuildPackageInit, apiservice.RebuildPackage),
+			SmoketestStub:      api.Unary(RebuildSmoketestInit, apiservice.RebuildSmoketest),
+			VersionStub:        api.Unary(VersionInit, apiservice.Version),
+			CreateRunStub:      api.Unary(CreateRunInit, apiservice.CreateRun),
+			LogsStream:         api.Stream(LogsInit, apiservice.Logs),
+		})
+		go func() {
+			if err := s.Serve(lis); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+		// Serve the REST mappings of the gRPC API by proxying to the gRPC server.
+		gw := runtime.NewServeMux()
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		if err := apipb.RegisterRebuildServiceHandlerFromEndpoint(context.Background(), gw, fmt.Sprintf("localhost:%d", *grpcPort), opts); err != nil {
+			log.Fatalln(errors.Wrap(err, "registering gRPC gateway"))
+		}
+		http.Handle("/v1/", telemetry.WrapHandler(gw, "gateway"))
+	}
 	if err := http.ListenAndServe(":8080", nil); err != nil {
 		log.Fatalln(err)
 	}
@@ -23,10 +23,18 @@ import (
 	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/api/inferenceservice"
 	"github.com/google/oss-rebuild/internal/httpegress"
+	"github.com/google/oss-rebuild/internal/mirror"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/pkg/errors"
 )
 
-var httpcfg = httpegress.Config{}
+var (
+	httpcfg         = httpegress.Config{}
+	cacheURL        = flag.String("cache-url", "", "if provided, the store URL (gs://, s3://, file://) in which inference results are cached for reuse")
+	repoDiscovery   = flag.Bool("repo-discovery", true, "whether to search for the source repository when package metadata lacks a usable one")
+	registryMirrors = flag.String("registry-mirrors", "", "if provided, the path of a YAML file configuring the private registry mirrors from which packages are read")
+	cacheVersion    = flag.String("cache-version", "", "the inference code version by which cache entries are keyed. Defaults to the service revision or VCS commit")
+)
 
 func InferInit(ctx context.Context) (*inferenceservice.InferDeps, error) {
 	var d inferenceservice.InferDeps
@@ -35,6 +43,32 @@ func InferInit(ctx context.Context) (*inferenceservice.InferDeps, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "making http client")
 	}
+	if *registryMirrors != "" {
+		d.Mirrors, err = mirror.Load(*registryMirrors)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading registry mirrors")
+		}
+		d.HTTPClient, err = d.Mirrors.Client(d.HTTPClient)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring registry mirror credentials")
+		}
+	}
+	d.RepoDiscovery = *repoDiscovery
+	if *cacheURL != "" {
+		version := *cacheVersion
+		if version == "" {
+			version = inferenceservice.CodeVersion()
+		}
+		if version == "" {
+			log.Println("No inference code version available; disabling inference cache")
+			return &d, nil
+		}
+		store, err := rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, ""), *cacheURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating inference cache store")
+		}
+		d.Cache = &inferenceservice.Cache{Store: store, Version: version}
+	}
 	return &d, nil
 }
 
@@ -15,9 +15,7 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -29,9 +27,7 @@ import (
 	gcs "cloud.google.com/go/storage"
 	"github.com/google/oss-rebuild/internal/verifier"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
-	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/pkg/errors"
-	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"github.com/spf13/cobra"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
@@ -47,87 +43,18 @@ var rootCmd = &cobra.Command{
 	Short: "A CLI tool for OSS Rebuild",
 }
 
-// Bundle is a SLSA rebuild attestation bundle.
-type Bundle struct {
-	Bytes []byte
-}
-
-func NewBundle(ctx context.Context, t rebuild.Target, attestation rebuild.AssetStore) (*Bundle, error) {
+// NewBundle fetches the attestation bundle for the target from the store.
+func NewBundle(ctx context.Context, t rebuild.Target, attestation rebuild.AssetStore) (*verifier.Bundle, error) {
 	r, _, err := attestation.Reader(ctx, rebuild.Asset{Target: t, Type: rebuild.AttestationBundleAsset})
 	if err != nil {
 		log.Fatal(errors.Wrap(err, "opening bundle"))
 	}
-	bundle := bytes.NewBuffer(nil)
 	defer r.Close()
-	if _, err := io.Copy(bundle, r); err != nil {
-		log.Fatal(errors.Wrap(err, "reading bundle"))
-	}
-	return &Bundle{bundle.Bytes()}, nil
-}
-
-func unwrapEnvelope(e *dsse.Envelope) (*in_toto.ProvenanceStatementSLSA1, error) {
-	if e.Payload == "" {
-		return nil, errors.New("no payload")
-	}
-	b, err := base64.StdEncoding.DecodeString(e.Payload)
-	if err != nil {
-		return nil, errors.New("payload is not b64 encoded")
-	}
-	var decoded *in_toto.ProvenanceStatementSLSA1
-	if json.Unmarshal(b, &decoded) != nil {
-		return nil, errors.New("payload is not valid json")
-	}
-	return decoded, nil
-}
-
-// Payloads returns all payloads in the bundle.
-func (b *Bundle) Payloads() ([]*in_toto.ProvenanceStatementSLSA1, error) {
-	bundle := bytes.NewBuffer(b.Bytes)
-	d := json.NewDecoder(bundle)
-	var payloads []*in_toto.ProvenanceStatementSLSA1
-	for {
-		var envelope dsse.Envelope
-		err := d.Decode(&envelope)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, errors.Wrap(err, "decoding envelope")
-		}
-		p, err := unwrapEnvelope(&envelope)
-		if err != nil {
-			return nil, errors.Wrap(err, "unwrapping envelope")
-		}
-		payloads = append(payloads, p)
-	}
-	return payloads, nil
-}
-
-func (b *Bundle) rebuildAttestation() (*in_toto.ProvenanceStatementSLSA1, error) {
-	payloads, err := b.Payloads()
+	bundle, err := verifier.ReadBundle(r)
 	if err != nil {
-		return nil, err
-	}
-	for _, p := range payloads {
-		if p.Predicate.BuildDefinition.BuildType == verifier.RebuildBuildType {
-			return p, nil
-		}
-	}
-	return nil, errors.New("no rebuild attestation found")
-}
-
-// Byproduct returns the named byproduct from the rebuild attestation.
-func (b *Bundle) Byproduct(name string) ([]byte, error) {
-	att, err := b.rebuildAttestation()
-	if err != nil {
-		return nil, err
-	}
-	for _, b := range att.Predicate.RunDetails.Byproducts {
-		if b.Name == name {
-			return b.Content, nil
-		}
+		log.Fatal(err)
 	}
-	return nil, fmt.Errorf("byproduct named %s not found", name)
+	return bundle, nil
 }
 
 var getCmd = &cobra.Command{
@@ -169,7 +96,7 @@ The ecosystem is one of npm, pypi, or cratesio. For npm the artifact is the <pac
 				Artifact:  artifact,
 			}
 		}
-		var bundle *Bundle
+		var bundle *verifier.Bundle
 		{
 			ctx := cmd.Context()
 			ctx = context.WithValue(ctx, rebuild.RunID, "")
@@ -239,6 +166,28 @@ var listCmd = &cobra.Command{
 		if len(args) < 2 {
 			log.Fatal("Please include at least an ecosystem and package")
 		}
+		{
+			// NOTE: Packages attested before the adoption of the v2 layout may
+			// have no index, in which case the bundles are listed.
+			ctx := context.WithValue(cmd.Context(), rebuild.RunID, "")
+			ctx = context.WithValue(ctx, rebuild.GCSClientOptionsID, []option.ClientOption{option.WithoutAuthentication()})
+			attestation, err := rebuild.NewGCSStore(ctx, "gs://"+*bucket)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "initializing GCS store"))
+			}
+			idx, err := verifier.ReadIndex(ctx, attestation, rebuild.Ecosystem(args[0]), args[1])
+			if err == nil {
+				for _, e := range idx.Entries {
+					if len(args) == 3 && e.Version != args[2] {
+						continue
+					}
+					io.WriteString(cmd.OutOrStdout(), strings.TrimPrefix(e.Bundle, "gs://"+*bucket+"/")+"\n")
+				}
+				return
+			} else if !errors.Is(err, rebuild.ErrAssetNotFound) {
+				log.Fatal(errors.Wrap(err, "reading package index"))
+			}
+		}
 		gcsClient, err := gcs.NewClient(cmd.Context(), option.WithoutAuthentication())
 		if err != nil {
 			log.Fatal(errors.Wrap(err, "initializing GCS client"))
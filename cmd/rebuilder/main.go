@@ -27,6 +27,8 @@ import (
 	"github.com/google/oss-rebuild/internal/api/rebuilderservice"
 	"github.com/google/oss-rebuild/internal/gitx"
 	"github.com/google/oss-rebuild/internal/httpegress"
+	"github.com/google/oss-rebuild/internal/mirror"
+	"github.com/google/oss-rebuild/internal/telemetry"
 	"github.com/google/oss-rebuild/internal/timewarp"
 	"github.com/pkg/errors"
 	"google.golang.org/api/idtoken"
@@ -34,12 +36,14 @@ import (
 )
 
 var (
-	debugBucket         = flag.String("debug-bucket", "", "if provided, the bucket to which rebuild results should be uploaded")
+	debugBucket         = flag.String("debug-bucket", "", "if provided, the store URL (gs://, s3://, file://) to which rebuild results should be uploaded")
 	gitCacheURL         = flag.String("git-cache-url", "", "if provided, the git-cache service to use to fetch repos")
 	defaultVersionCount = flag.Int("default-version-count", 5, "The number of versions to rebuild if no version is provided")
 	useTimewarp         = flag.Bool("timewarp", true, "whether to use launch an instance of the timewarp server")
 	timewarpPort        = flag.Int("timewarp-port", 8081, "the port for timewarp to serve on")
 	localAssetDir       = flag.String("asset-dir", "assets", "the directory into which local assets will be stored")
+	registryMirrors     = flag.String("registry-mirrors", "", "if provided, the path of a YAML file configuring the private registry mirrors from which packages are read")
+	dependencyCacheKey  = flag.String("dependency-cache-key", "", "if provided, identifies the persistent dependency caches mounted into this rebuilder")
 )
 
 var httpcfg = httpegress.Config{}
@@ -51,6 +55,16 @@ func RebuildSmoketestInit(ctx context.Context) (*rebuilderservice.RebuildSmokete
 	if err != nil {
 		return nil, errors.Wrap(err, "creating http client")
 	}
+	if *registryMirrors != "" {
+		d.Mirrors, err = mirror.Load(*registryMirrors)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading registry mirrors")
+		}
+		d.HTTPClient, err = d.Mirrors.Client(d.HTTPClient)
+		if err != nil {
+			return nil, errors.Wrap(err, "configuring registry mirror credentials")
+		}
+	}
 	if *gitCacheURL != "" {
 		c, err := idtoken.NewClient(ctx, *gitCacheURL)
 		if err != nil {
@@ -74,6 +88,7 @@ func RebuildSmoketestInit(ctx context.Context) (*rebuilderservice.RebuildSmokete
 	}
 	d.AssetDir = *localAssetDir
 	d.DefaultVersionCount = *defaultVersionCount
+	d.DependencyCacheKey = *dependencyCacheKey
 	return &d, nil
 }
 
@@ -87,8 +102,13 @@ func main() {
 			}
 		}()
 	}
-	http.HandleFunc("/smoketest", api.Handler(RebuildSmoketestInit, rebuilderservice.RebuildSmoketest))
-	http.HandleFunc("/version", api.Handler(api.NoDepsInit, rebuilderservice.Version))
+	metrics, err := telemetry.Setup(context.Background())
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "initializing telemetry"))
+	}
+	http.Handle("/metrics", metrics)
+	http.Handle("/smoketest", telemetry.WrapHandler(api.Handler(RebuildSmoketestInit, rebuilderservice.RebuildSmoketest), "smoketest"))
+	http.Handle("/version", telemetry.WrapHandler(api.Handler(api.NoDepsInit, rebuilderservice.Version), "version"))
 	if err := http.ListenAndServe(":8080", nil); err != nil {
 		log.Fatalln(err)
 	}
@@ -0,0 +1,69 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// main contains the platform runner, which executes rebuild build scripts directly on a host such as one running Windows or macOS.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/api/runnerservice"
+	"github.com/google/oss-rebuild/internal/timewarp"
+	"github.com/pkg/errors"
+)
+
+var (
+	port        = flag.Int("port", 8080, "the port on which to serve the runner API")
+	workDir     = flag.String("work-dir", "", "the directory in which build workspaces are created, the system temporary directory if unset")
+	shell       = flag.String("shell", "bash", "the bash executable with which build scripts are run")
+	name        = flag.String("name", "", "identifies this runner in build results, the hostname if unset")
+	useTimewarp = flag.Bool("timewarp", true, "whether to use launch an instance of the timewarp server")
+)
+
+// timewarpPort is the port on which rebuild instructions for platform runners address timewarp.
+const timewarpPort = 8081
+
+func RunInit(ctx context.Context) (*runnerservice.RunDeps, error) {
+	d := runnerservice.RunDeps{WorkDir: *workDir, Shell: *shell, Name: *name}
+	if d.Name == "" {
+		var err error
+		d.Name, err = os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "getting hostname")
+		}
+	}
+	return &d, nil
+}
+
+func main() {
+	flag.Parse()
+	if *useTimewarp {
+		go func() {
+			if err := http.ListenAndServe(fmt.Sprintf("localhost:%d", timewarpPort), timewarp.Handler{}); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+	}
+	http.Handle("/run", api.Handler(RunInit, runnerservice.Run))
+	http.Handle("/version", api.Handler(api.NoDepsInit, runnerservice.Version))
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", *port), nil); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// main contains the release watcher, which enqueues rebuilds of newly published versions of tracked packages.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/feed"
+	"github.com/google/oss-rebuild/internal/httpegress"
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/internal/telemetry"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	"github.com/pkg/errors"
+	"google.golang.org/api/idtoken"
+)
+
+var (
+	apiURL      = flag.String("api", "", "URL of the rebuild API to which rebuilds are submitted")
+	trackedPath = flag.String("tracked", "", "path to the list of tracked packages, one '<ecosystem> <package>' per line")
+	interval    = flag.Duration("interval", 5*time.Minute, "the interval at which registry feeds are polled")
+	project     = flag.String("project", "", "if provided, the GCP project in whose Firestore failed rebuild tasks are dead-lettered")
+)
+
+var (
+	httpcfg  = httpegress.Config{}
+	queuecfg = taskqueue.Config{}
+)
+
+func main() {
+	httpcfg.RegisterFlags(flag.CommandLine)
+	queuecfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *apiURL == "" || *trackedPath == "" {
+		log.Fatalln("--api and --tracked must be provided")
+	}
+	f, err := os.Open(*trackedPath)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "opening tracked packages"))
+	}
+	tracked, err := feed.ReadTracked(f)
+	f.Close()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	u, err := url.Parse(*apiURL)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "parsing API URL"))
+	}
+	var apiclient httpx.BasicClient = http.DefaultClient
+	if u.Scheme != "http" {
+		apiclient, err = idtoken.NewClient(ctx, *apiURL)
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "initializing API client"))
+		}
+	}
+	if *project != "" {
+		fs, err := firestore.NewClient(ctx, *project)
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "creating firestore client"))
+		}
+		queuecfg.DeadLetters = &taskqueue.FirestoreDeadLetters{Client: fs}
+	}
+	queue, err := taskqueue.MakeQueue(ctx, queuecfg, apiclient)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "creating task queue"))
+	}
+	egress, err := httpegress.MakeClient(ctx, httpcfg)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "creating http client"))
+	}
+	regclient := &telemetry.RegistryClient{BasicClient: egress}
+	w := &feed.Watcher{
+		Feeds: []feed.Feed{
+			&feed.NPMFeed{Client: regclient, Registry: npmreg.HTTPRegistry{Client: regclient}},
+			&feed.PyPIFeed{Client: regclient},
+			&feed.CratesIOFeed{Client: regclient},
+		},
+		Tracked: tracked,
+		Queue:   queue,
+		API:     u,
+		ID:      time.Now().UTC().Format(time.RFC3339),
+	}
+	log.Printf("Watching registries for releases of tracked packages every %v", *interval)
+	if err := w.Run(ctx, *interval); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalln(err)
+	}
+}
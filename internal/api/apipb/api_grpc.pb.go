@@ -0,0 +1,309 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: internal/api/apipb/api.proto
+
+package apipb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RebuildService_RebuildPackage_FullMethodName = "/ossrebuild.api.v1.RebuildService/RebuildPackage"
+	RebuildService_Smoketest_FullMethodName      = "/ossrebuild.api.v1.RebuildService/Smoketest"
+	RebuildService_Version_FullMethodName        = "/ossrebuild.api.v1.RebuildService/Version"
+	RebuildService_CreateRun_FullMethodName      = "/ossrebuild.api.v1.RebuildService/CreateRun"
+	RebuildService_Logs_FullMethodName           = "/ossrebuild.api.v1.RebuildService/Logs"
+)
+
+// RebuildServiceClient is the client API for RebuildService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RebuildServiceClient interface {
+	// RebuildPackage rebuilds a package and publishes attestations on success.
+	RebuildPackage(ctx context.Context, in *RebuildPackageRequest, opts ...grpc.CallOption) (*RebuildPackageResponse, error)
+	// Smoketest rebuilds one or more versions of a package without attesting.
+	Smoketest(ctx context.Context, in *SmoketestRequest, opts ...grpc.CallOption) (*SmoketestResponse, error)
+	// Version returns the version of the named service.
+	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
+	// CreateRun registers a new run.
+	CreateRun(ctx context.Context, in *CreateRunRequest, opts ...grpc.CallOption) (*CreateRunResponse, error)
+	// Logs streams the logs of a remote rebuild as they are produced.
+	Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (RebuildService_LogsClient, error)
+}
+
+type rebuildServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRebuildServiceClient(cc grpc.ClientConnInterface) RebuildServiceClient {
+	return &rebuildServiceClient{cc}
+}
+
+func (c *rebuildServiceClient) RebuildPackage(ctx context.Context, in *RebuildPackageRequest, opts ...grpc.CallOption) (*RebuildPackageResponse, error) {
+	out := new(RebuildPackageResponse)
+	err := c.cc.Invoke(ctx, RebuildService_RebuildPackage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rebuildServiceClient) Smoketest(ctx context.Context, in *SmoketestRequest, opts ...grpc.CallOption) (*SmoketestResponse, error) {
+	out := new(SmoketestResponse)
+	err := c.cc.Invoke(ctx, RebuildService_Smoketest_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rebuildServiceClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	err := c.cc.Invoke(ctx, RebuildService_Version_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rebuildServiceClient) CreateRun(ctx context.Context, in *CreateRunRequest, opts ...grpc.CallOption) (*CreateRunResponse, error) {
+	out := new(CreateRunResponse)
+	err := c.cc.Invoke(ctx, RebuildService_CreateRun_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rebuildServiceClient) Logs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (RebuildService_LogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RebuildService_ServiceDesc.Streams[0], RebuildService_Logs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rebuildServiceLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RebuildService_LogsClient interface {
+	Recv() (*LogsResponse, error)
+	grpc.ClientStream
+}
+
+type rebuildServiceLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *rebuildServiceLogsClient) Recv() (*LogsResponse, error) {
+	m := new(LogsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RebuildServiceServer is the server API for RebuildService service.
+// All implementations must embed UnimplementedRebuildServiceServer
+// for forward compatibility
+type RebuildServiceServer interface {
+	// RebuildPackage rebuilds a package and publishes attestations on success.
+	RebuildPackage(context.Context, *RebuildPackageRequest) (*RebuildPackageResponse, error)
+	// Smoketest rebuilds one or more versions of a package without attesting.
+	Smoketest(context.Context, *SmoketestRequest) (*SmoketestResponse, error)
+	// Version returns the version of the named service.
+	Version(context.Context, *VersionRequest) (*VersionResponse, error)
+	// CreateRun registers a new run.
+	CreateRun(context.Context, *CreateRunRequest) (*CreateRunResponse, error)
+	// Logs streams the logs of a remote rebuild as they are produced.
+	Logs(*LogsRequest, RebuildService_LogsServer) error
+	mustEmbedUnimplementedRebuildServiceServer()
+}
+
+// UnimplementedRebuildServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRebuildServiceServer struct {
+}
+
+func (UnimplementedRebuildServiceServer) RebuildPackage(context.Context, *RebuildPackageRequest) (*RebuildPackageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RebuildPackage not implemented")
+}
+func (UnimplementedRebuildServiceServer) Smoketest(context.Context, *SmoketestRequest) (*SmoketestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Smoketest not implemented")
+}
+func (UnimplementedRebuildServiceServer) Version(context.Context, *VersionRequest) (*VersionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Version not implemented")
+}
+func (UnimplementedRebuildServiceServer) CreateRun(context.Context, *CreateRunRequest) (*CreateRunResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRun not implemented")
+}
+func (UnimplementedRebuildServiceServer) Logs(*LogsRequest, RebuildService_LogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method Logs not implemented")
+}
+func (UnimplementedRebuildServiceServer) mustEmbedUnimplementedRebuildServiceServer() {}
+
+// UnsafeRebuildServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RebuildServiceServer will
+// result in compilation errors.
+type UnsafeRebuildServiceServer interface {
+	mustEmbedUnimplementedRebuildServiceServer()
+}
+
+func RegisterRebuildServiceServer(s grpc.ServiceRegistrar, srv RebuildServiceServer) {
+	s.RegisterService(&RebuildService_ServiceDesc, srv)
+}
+
+func _RebuildService_RebuildPackage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RebuildPackageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RebuildServiceServer).RebuildPackage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RebuildService_RebuildPackage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RebuildServiceServer).RebuildPackage(ctx, req.(*RebuildPackageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RebuildService_Smoketest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SmoketestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RebuildServiceServer).Smoketest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RebuildService_Smoketest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RebuildServiceServer).Smoketest(ctx, req.(*SmoketestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RebuildService_Version_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RebuildServiceServer).Version(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RebuildService_Version_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RebuildServiceServer).Version(ctx, req.(*VersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RebuildService_CreateRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RebuildServiceServer).CreateRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RebuildService_CreateRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RebuildServiceServer).CreateRun(ctx, req.(*CreateRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RebuildService_Logs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RebuildServiceServer).Logs(m, &rebuildServiceLogsServer{stream})
+}
+
+type RebuildService_LogsServer interface {
+	Send(*LogsResponse) error
+	grpc.ServerStream
+}
+
+type rebuildServiceLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *rebuildServiceLogsServer) Send(m *LogsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// RebuildService_ServiceDesc is the grpc.ServiceDesc for RebuildService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RebuildService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ossrebuild.api.v1.RebuildService",
+	HandlerType: (*RebuildServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RebuildPackage",
+			Handler:    _RebuildService_RebuildPackage_Handler,
+		},
+		{
+			MethodName: "Smoketest",
+			Handler:    _RebuildService_Smoketest_Handler,
+		},
+		{
+			MethodName: "Version",
+			Handler:    _RebuildService_Version_Handler,
+		},
+		{
+			MethodName: "CreateRun",
+			Handler:    _RebuildService_CreateRun_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Logs",
+			Handler:       _RebuildService_Logs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "internal/api/apipb/api.proto",
+}
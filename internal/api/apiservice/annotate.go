@@ -0,0 +1,77 @@
+package apiservice
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+)
+
+// annotationCollection holds the notes attached to rebuild results.
+const annotationCollection = "annotations"
+
+type AnnotateDeps struct {
+	FirestoreClient *firestore.Client
+}
+
+// Annotate attaches a note to the rebuild results of a package version.
+// The author is the authenticated caller recorded in ctx.
+func Annotate(ctx context.Context, req schema.AnnotateRequest, deps *AnnotateDeps) (*schema.AnnotateResponse, error) {
+	author, ok := api.Caller(ctx)
+	if !ok {
+		return nil, api.AsStatus(codes.Unauthenticated, errors.New("author is not authenticated"))
+	}
+	if err := normalizePackage(req.Ecosystem, &req.Package); err != nil {
+		return nil, err
+	}
+	doc, _, err := deps.FirestoreClient.Collection(annotationCollection).Add(ctx, schema.Annotation{
+		Ecosystem: string(req.Ecosystem),
+		Package:   req.Package,
+		Version:   req.Version,
+		Artifact:  req.Artifact,
+		Author:    author,
+		Text:      req.Text,
+		Link:      req.Link,
+		Created:   time.Now().UTC().UnixMilli(),
+	})
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore write"))
+	}
+	return &schema.AnnotateResponse{ID: doc.ID}, nil
+}
+
+// fetchAnnotations returns the annotations of a package version, oldest first.
+// If artifact is provided, only annotations of that artifact or the whole version are returned.
+func fetchAnnotations(ctx context.Context, client *firestore.Client, req schema.StatusRequest) ([]schema.Annotation, error) {
+	iter := client.Collection(annotationCollection).
+		Where("ecosystem", "==", string(req.Ecosystem)).
+		Where("package", "==", req.Package).
+		Where("version", "==", req.Version).
+		Documents(ctx)
+	defer iter.Stop()
+	var annotations []schema.Annotation
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var a schema.Annotation
+		if err := doc.DataTo(&a); err != nil {
+			return nil, errors.Wrap(err, "parsing annotation")
+		}
+		if req.Artifact != "" && a.Artifact != "" && a.Artifact != req.Artifact {
+			continue
+		}
+		annotations = append(annotations, a)
+	}
+	sort.SliceStable(annotations, func(i, j int) bool { return annotations[i].Created < annotations[j].Created })
+	return annotations, nil
+}
@@ -0,0 +1,241 @@
+package apiservice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/notify"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type BatchDeps struct {
+	FirestoreClient *firestore.Client
+	CreateRunStub   api.StubT[schema.CreateRunRequest, schema.CreateRunResponse]
+	// Queue delivers a BatchTargetRequest to TargetURL for each target of a batch.
+	Queue     taskqueue.Queue
+	TargetURL *url.URL
+	// LoadBenchmark, if provided, resolves the targets of a benchmark reference.
+	LoadBenchmark func(ctx context.Context, ref string) ([]rebuild.Target, error)
+	Notifier      notify.Notifier
+}
+
+// Batch creates a run for the batch of targets and enqueues a task to execute each one.
+// The returned ID can be passed to BatchStatus to poll the job's progress.
+func Batch(ctx context.Context, req schema.BatchRequest, deps *BatchDeps) (*schema.BatchResponse, error) {
+	if req.Benchmark != "" {
+		if deps.LoadBenchmark == nil {
+			return nil, api.AsStatus(codes.FailedPrecondition, errors.New("no benchmark repository configured"))
+		}
+		targets, err := deps.LoadBenchmark(ctx, req.Benchmark)
+		if err != nil {
+			return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "loading benchmark"))
+		}
+		if len(targets) == 0 {
+			return nil, api.AsStatus(codes.InvalidArgument, errors.New("benchmark has no targets"))
+		}
+		req.Targets = targets
+		if req.Name == "" {
+			req.Name = req.Benchmark
+		}
+	}
+	// NOTE: Results are keyed by target so duplicates would never be counted.
+	targets := slices.Clone(req.Targets)
+	slices.SortFunc(targets, func(a, b rebuild.Target) int { return strings.Compare(resultKey(a), resultKey(b)) })
+	targets = slices.CompactFunc(targets, func(a, b rebuild.Target) bool { return resultKey(a) == resultKey(b) })
+	if len(targets) > schema.MaxBatchSize {
+		return nil, api.AsStatus(codes.InvalidArgument, errors.Errorf("too many targets: %d > %d", len(targets), schema.MaxBatchSize))
+	}
+	req.Targets = targets
+	run, err := deps.CreateRunStub(ctx, schema.CreateRunRequest{Name: req.Name, Type: req.Mode, Hash: req.Hash()})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating run")
+	}
+	doc := deps.FirestoreClient.Collection("batches").Doc(run.ID)
+	_, err = doc.Create(ctx, schema.BatchStatus{
+		Mode:    req.Mode,
+		Name:    req.Name,
+		Total:   len(targets),
+		Created: time.Now().UTC().UnixMilli(),
+	})
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore write"))
+	}
+	for i, t := range targets {
+		msg := schema.BatchTargetRequest{
+			BatchID:   run.ID,
+			Mode:      req.Mode,
+			Ecosystem: t.Ecosystem,
+			Package:   t.Package,
+			Version:   t.Version,
+			Artifact:  t.Artifact,
+		}
+		opts := taskqueue.TaskOptions{Name: fmt.Sprintf("batch-%s-%d", run.ID, i), Group: string(t.Ecosystem)}
+		if _, err := deps.Queue.Add(ctx, deps.TargetURL.String(), msg, opts); err != nil {
+			// NOTE: Record the target as failed so the job can still complete.
+			err = errors.Wrap(err, "enqueueing target")
+			log.Printf("batch %s: %v: %v", run.ID, t, err)
+			if err := recordBatchResult(ctx, deps.FirestoreClient, deps.Notifier, run.ID, schema.BatchResult{Target: t, Message: err.Error()}); err != nil {
+				log.Printf("batch %s: recording result: %v", run.ID, err)
+			}
+		}
+	}
+	return &schema.BatchResponse{ID: run.ID, Total: len(targets)}, nil
+}
+
+type BatchTargetDeps struct {
+	FirestoreClient *firestore.Client
+	SmoketestStub   api.StubT[schema.SmoketestRequest, schema.SmoketestResponse]
+	RebuildStub     api.StubT[schema.RebuildPackageRequest, api.NoReturn]
+	Notifier        notify.Notifier
+}
+
+// BatchTarget executes a single target of a batch job and records its outcome.
+// The job is marked finished, and its completion notified, once the outcome
+// of every target is recorded.
+func BatchTarget(ctx context.Context, req schema.BatchTargetRequest, deps *BatchTargetDeps) (*api.NoReturn, error) {
+	t := req.Target()
+	result := schema.BatchResult{Target: t, Success: true}
+	if err := executeTarget(ctx, req.Mode, req.BatchID, t, deps); err != nil {
+		log.Printf("batch %s: %v: %v", req.BatchID, t, err)
+		result = schema.BatchResult{Target: t, Message: err.Error()}
+	}
+	// NOTE: An error causes the task to be redelivered. Redeliveries of a
+	// recorded target do not alter the job's progress.
+	if err := recordBatchResult(ctx, deps.FirestoreClient, deps.Notifier, req.BatchID, result); err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "recording result"))
+	}
+	return &api.NoReturn{}, nil
+}
+
+func resultKey(t rebuild.Target) string {
+	return sanitize(strings.Join([]string{string(t.Ecosystem), t.Package, t.Version, t.Artifact}, "!"))
+}
+
+// recordBatchResult records the outcome of a target of the batch, if not
+// already recorded, and updates the progress of the job.
+func recordBatchResult(ctx context.Context, client *firestore.Client, n notify.Notifier, id string, result schema.BatchResult) error {
+	doc := client.Collection("batches").Doc(id)
+	rdoc := doc.Collection("results").Doc(resultKey(result.Target))
+	var s schema.BatchStatus
+	var finished bool
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		finished = false
+		snap, err := tx.Get(doc)
+		if err != nil {
+			return err
+		}
+		if err := snap.DataTo(&s); err != nil {
+			return errors.Wrap(err, "parsing batch")
+		}
+		if _, err := tx.Get(rdoc); err == nil {
+			return nil
+		} else if status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err := tx.Create(rdoc, result); err != nil {
+			return err
+		}
+		field := "failed"
+		if result.Success {
+			field = "succeeded"
+			s.Succeeded++
+		} else {
+			s.Failed++
+		}
+		updates := []firestore.Update{{Path: field, Value: firestore.Increment(1)}}
+		if s.Done() {
+			finished = true
+			updates = append(updates, firestore.Update{Path: "finished", Value: time.Now().UTC().UnixMilli()})
+		}
+		return tx.Update(doc, updates)
+	})
+	if err != nil {
+		return err
+	}
+	if finished {
+		notify.Send(ctx, n, notify.Event{
+			Kind:    notify.RunComplete,
+			RunID:   id,
+			Message: fmt.Sprintf("%s of %q: %d succeeded, %d failed", s.Mode, s.Name, s.Succeeded, s.Failed),
+		})
+	}
+	return nil
+}
+
+// executeTarget executes a single target from a batch, returning an error if it did not succeed.
+func executeTarget(ctx context.Context, mode, id string, t rebuild.Target, deps *BatchTargetDeps) error {
+	switch mode {
+	case schema.BatchSmoketestMode:
+		resp, err := deps.SmoketestStub(ctx, schema.SmoketestRequest{
+			Ecosystem: t.Ecosystem,
+			Package:   t.Package,
+			Versions:  []string{t.Version},
+			ID:        id,
+		})
+		if err != nil {
+			return err
+		}
+		for _, v := range resp.Verdicts {
+			if v.Message != "" {
+				return errors.New(v.Message)
+			}
+		}
+		return nil
+	case schema.BatchAttestMode:
+		_, err := deps.RebuildStub(ctx, schema.RebuildPackageRequest{
+			Ecosystem: t.Ecosystem,
+			Package:   t.Package,
+			Version:   t.Version,
+			Artifact:  t.Artifact,
+			ID:        id,
+		})
+		return err
+	default:
+		return errors.Errorf("unknown mode: %s", mode)
+	}
+}
+
+type BatchStatusDeps struct {
+	FirestoreClient *firestore.Client
+}
+
+// BatchStatus returns the aggregate progress of the batch job with the requested ID.
+func BatchStatus(ctx context.Context, req schema.BatchStatusRequest, deps *BatchStatusDeps) (*schema.BatchStatus, error) {
+	snap, err := deps.FirestoreClient.Collection("batches").Doc(req.ID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, api.AsStatus(codes.NotFound, errors.Errorf("unknown batch: %s", req.ID))
+	} else if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore read"))
+	}
+	var s schema.BatchStatus
+	if err := snap.DataTo(&s); err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "parsing batch"))
+	}
+	s.ID = req.ID
+	if req.Results {
+		docs, err := snap.Ref.Collection("results").Documents(ctx).GetAll()
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore read"))
+		}
+		for _, d := range docs {
+			var r schema.BatchResult
+			if err := d.DataTo(&r); err != nil {
+				return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "parsing result"))
+			}
+			s.Results = append(s.Results, r)
+		}
+	}
+	return &s, nil
+}
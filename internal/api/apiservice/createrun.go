@@ -2,27 +2,70 @@ package apiservice
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/pkg/archive"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 )
 
 type CreateRunDeps struct {
-	FirestoreClient *firestore.Client
+	FirestoreClient       *firestore.Client
+	BuildLocalVersionStub api.StubT[schema.VersionRequest, schema.VersionResponse]
+	InferenceVersionStub  api.StubT[schema.VersionRequest, schema.VersionResponse]
+	BuildDefRepo          string
+	PrebuildBucket        string
+}
+
+// snapshotConfig captures the configuration of the services that will execute the run.
+func snapshotConfig(ctx context.Context, req schema.CreateRunRequest, deps *CreateRunDeps) (*schema.RunConfig, error) {
+	cfg := &schema.RunConfig{
+		BenchmarkName:   req.Name,
+		BenchmarkHash:   req.Hash,
+		BenchmarkRepo:   req.Repo,
+		BenchmarkCommit: req.Commit,
+		RunType:         req.Type,
+		APIVersion:      os.Getenv("K_REVISION"),
+		BuildDefRepo:    deps.BuildDefRepo,
+		PrebuildBucket:  deps.PrebuildBucket,
+		Stabilizers:     archive.Stabilizers,
+	}
+	if deps.BuildLocalVersionStub != nil {
+		resp, err := deps.BuildLocalVersionStub(ctx, schema.VersionRequest{})
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching build-local version")
+		}
+		cfg.BuildLocalVersion = resp.Version
+	}
+	if deps.InferenceVersionStub != nil {
+		resp, err := deps.InferenceVersionStub(ctx, schema.VersionRequest{})
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching inference version")
+		}
+		cfg.InferenceVersion = resp.Version
+	}
+	return cfg, nil
 }
 
 func CreateRun(ctx context.Context, req schema.CreateRunRequest, deps *CreateRunDeps) (*schema.CreateRunResponse, error) {
+	cfg, err := snapshotConfig(ctx, req, deps)
+	if err != nil {
+		return nil, api.AsStatus(codes.Unavailable, errors.Wrap(err, "snapshotting config"))
+	}
 	id := time.Now().UTC().Format(time.RFC3339)
-	err := deps.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, t *firestore.Transaction) error {
+	err = deps.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, t *firestore.Transaction) error {
 		return t.Create(deps.FirestoreClient.Collection("runs").Doc(id), map[string]any{
-			"benchmark_name": req.Name,
-			"benchmark_hash": req.Hash,
-			"run_type":       req.Type,
-			"created":        time.Now().UTC().UnixMilli(),
+			"benchmark_name":   req.Name,
+			"benchmark_hash":   req.Hash,
+			"benchmark_repo":   req.Repo,
+			"benchmark_commit": req.Commit,
+			"run_type":         req.Type,
+			"created":          time.Now().UTC().UnixMilli(),
+			"config":           cfg,
 		})
 	})
 	if err != nil {
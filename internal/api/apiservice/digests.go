@@ -0,0 +1,100 @@
+package apiservice
+
+import (
+	"context"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/notify"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// upstreamDigestCollection is the index of the upstream artifact digest observed for each artifact.
+const upstreamDigestCollection = "upstream_digests"
+
+// recordUpstreamDigest updates the upstream digest index with the artifact
+// served by the registry and notifies the operators if it has changed.
+// A nil client disables the index.
+func recordUpstreamDigest(ctx context.Context, client *firestore.Client, n notify.Notifier, t rebuild.Target, runID string, up verifier.ArtifactSummary) {
+	if client == nil {
+		return
+	}
+	var digest string
+	for _, h := range up.Hash {
+		if h.Algorithm == crypto.SHA256 {
+			digest = "sha256:" + hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	if digest == "" {
+		return
+	}
+	key := sanitize(strings.Join([]string{string(t.Ecosystem), t.Package, t.Version, t.Artifact}, "!"))
+	ref := client.Collection(upstreamDigestCollection).Doc(key)
+	var rec schema.UpstreamDigest
+	var changed bool
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		rec = schema.UpstreamDigest{}
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		} else if err == nil {
+			if err := doc.DataTo(&rec); err != nil {
+				return err
+			}
+		}
+		rec.Ecosystem, rec.Package, rec.Version, rec.Artifact = string(t.Ecosystem), t.Package, t.Version, t.Artifact
+		changed = rec.Observe(digest, runID, time.Now())
+		return tx.Set(ref, rec)
+	})
+	if err != nil {
+		log.Printf("recording upstream digest of %v: %v\n", t, err)
+		return
+	}
+	if changed {
+		msg := fmt.Sprintf("%s changed from %s to %s", t.Artifact, rec.PreviousDigest, rec.Digest)
+		log.Printf("upstream artifact mutation of %v: %s\n", t, msg)
+		notify.Send(ctx, n, notify.Event{Kind: notify.ArtifactMutated, Target: &t, RunID: runID, Message: msg})
+	}
+}
+
+// knownUpstreamDigests returns the digests previously observed for the
+// upstream artifact of a target: the one recorded in the upstream digest index
+// and the one attested by an existing bundle. A nil client skips the index.
+func knownUpstreamDigests(client *firestore.Client, a verifier.Attestor) func(context.Context, rebuild.Target) ([]string, error) {
+	return func(ctx context.Context, t rebuild.Target) ([]string, error) {
+		var known []string
+		if client != nil {
+			key := sanitize(strings.Join([]string{string(t.Ecosystem), t.Package, t.Version, t.Artifact}, "!"))
+			doc, err := client.Collection(upstreamDigestCollection).Doc(key).Get(ctx)
+			if err != nil && status.Code(err) != codes.NotFound {
+				return nil, errors.Wrap(err, "reading upstream digest index")
+			} else if err == nil {
+				var rec schema.UpstreamDigest
+				if err := doc.DataTo(&rec); err != nil {
+					return nil, errors.Wrap(err, "parsing upstream digest")
+				}
+				if rec.Digest != "" {
+					known = append(known, rec.Digest)
+				}
+			}
+		}
+		attested, err := a.UpstreamDigest(ctx, t)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading attested upstream digest")
+		}
+		if attested != "" {
+			known = append(known, attested)
+		}
+		return known, nil
+	}
+}
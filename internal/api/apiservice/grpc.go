@@ -0,0 +1,139 @@
+package apiservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/api/apipb"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// GRPCServer serves the API over gRPC by translating requests for the HTTP API's handlers.
+type GRPCServer struct {
+	apipb.UnimplementedRebuildServiceServer
+	RebuildPackageStub api.StubT[schema.RebuildPackageRequest, api.NoReturn]
+	SmoketestStub      api.StubT[schema.SmoketestRequest, schema.SmoketestResponse]
+	VersionStub        api.StubT[schema.VersionRequest, schema.VersionResponse]
+	CreateRunStub      api.StubT[schema.CreateRunRequest, schema.CreateRunResponse]
+	LogsStream         api.StreamT[schema.LogsRequest]
+}
+
+var _ apipb.RebuildServiceServer = &GRPCServer{}
+
+func resourcesFromProto(r *apipb.Resources) *rebuild.Resources {
+	if r == nil {
+		return nil
+	}
+	return &rebuild.Resources{
+		Timeout:  time.Duration(r.TimeoutSeconds) * time.Second,
+		CPUs:     r.Cpus,
+		MemoryMB: r.MemoryMb,
+	}
+}
+
+func (s *GRPCServer) RebuildPackage(ctx context.Context, req *apipb.RebuildPackageRequest) (*apipb.RebuildPackageResponse, error) {
+	_, err := s.RebuildPackageStub(ctx, schema.RebuildPackageRequest{
+		Ecosystem:        rebuild.Ecosystem(req.Ecosystem),
+		Package:          req.Package,
+		Version:          req.Version,
+		ID:               req.Id,
+		StrategyFromRepo: req.StrategyFromRepo,
+		Resources:        resourcesFromProto(req.Resources),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &apipb.RebuildPackageResponse{}, nil
+}
+
+func (s *GRPCServer) Smoketest(ctx context.Context, req *apipb.SmoketestRequest) (*apipb.SmoketestResponse, error) {
+	sreq := schema.SmoketestRequest{
+		Ecosystem: rebuild.Ecosystem(req.Ecosystem),
+		Package:   req.Package,
+		Versions:  req.Versions,
+		ID:        req.Id,
+		Resources: resourcesFromProto(req.Resources),
+	}
+	if req.Strategy != "" {
+		sreq.Strategy = &schema.StrategyOneOf{}
+		if err := json.Unmarshal([]byte(req.Strategy), sreq.Strategy); err != nil {
+			return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "parsing strategy"))
+		}
+	}
+	resp, err := s.SmoketestStub(ctx, sreq)
+	if err != nil {
+		return nil, err
+	}
+	out := &apipb.SmoketestResponse{Executor: resp.Executor, DependencyCacheKey: resp.DependencyCacheKey}
+	for _, v := range resp.Verdicts {
+		strategy, err := json.Marshal(v.StrategyOneof)
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "serializing strategy"))
+		}
+		out.Verdicts = append(out.Verdicts, &apipb.Verdict{
+			Target: &apipb.Target{
+				Ecosystem: string(v.Target.Ecosystem),
+				Package:   v.Target.Package,
+				Version:   v.Target.Version,
+				Artifact:  v.Target.Artifact,
+			},
+			Message:  v.Message,
+			Strategy: string(strategy),
+			Timings: &apipb.Timings{
+				CloneEstimateMillis: v.Timings.CloneEstimate.Milliseconds(),
+				SourceMillis:        v.Timings.Source.Milliseconds(),
+				InferMillis:         v.Timings.Infer.Milliseconds(),
+				BuildMillis:         v.Timings.Build.Milliseconds(),
+			},
+		})
+	}
+	return out, nil
+}
+
+func (s *GRPCServer) Version(ctx context.Context, req *apipb.VersionRequest) (*apipb.VersionResponse, error) {
+	resp, err := s.VersionStub(ctx, schema.VersionRequest{Service: req.Service})
+	if err != nil {
+		return nil, err
+	}
+	return &apipb.VersionResponse{Version: resp.Version}, nil
+}
+
+func (s *GRPCServer) CreateRun(ctx context.Context, req *apipb.CreateRunRequest) (*apipb.CreateRunResponse, error) {
+	resp, err := s.CreateRunStub(ctx, schema.CreateRunRequest{Name: req.Name, Type: req.Type, Hash: req.Hash})
+	if err != nil {
+		return nil, err
+	}
+	return &apipb.CreateRunResponse{Id: resp.ID}, nil
+}
+
+func (s *GRPCServer) Logs(req *apipb.LogsRequest, stream apipb.RebuildService_LogsServer) error {
+	return s.LogsStream(stream.Context(), schema.LogsRequest{
+		Ecosystem: rebuild.Ecosystem(req.Ecosystem),
+		Package:   req.Package,
+		Version:   req.Version,
+		Artifact:  req.Artifact,
+		ID:        req.Id,
+	}, &logsWriter{stream: stream})
+}
+
+// logsWriter sends each write to a Logs stream as a response message.
+type logsWriter struct {
+	stream apipb.RebuildService_LogsServer
+}
+
+func (w *logsWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// NOTE: Sent messages must not be modified and callers may reuse p.
+	if err := w.stream.Send(&apipb.LogsResponse{Data: bytes.Clone(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,146 @@
+package apiservice
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/api/apipb"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// serveGRPC serves s on a local port and returns its address.
+func serveGRPC(t *testing.T, s *GRPCServer) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	apipb.RegisterRebuildServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestGRPCServerLogs(t *testing.T) {
+	var gotReq schema.LogsRequest
+	addr := serveGRPC(t, &GRPCServer{
+		LogsStream: func(ctx context.Context, req schema.LogsRequest, w io.Writer) error {
+			gotReq = req
+			for _, chunk := range []string{"Step 1\n", "Step 2\n"} {
+				if _, err := io.WriteString(w, chunk); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	ctx := context.Background()
+	req := &apipb.LogsRequest{Ecosystem: "npm", Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", Id: "run"}
+	wantReq := schema.LogsRequest{Ecosystem: "npm", Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", ID: "run"}
+	wantChunks := []string{"Step 1\n", "Step 2\n"}
+	t.Run("gRPC", func(t *testing.T) {
+		conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		stream, err := apipb.NewRebuildServiceClient(conn).Logs(ctx, req)
+		if err != nil {
+			t.Fatalf("Logs() returned error: %v", err)
+		}
+		var chunks []string
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("Recv() returned error: %v", err)
+			}
+			chunks = append(chunks, string(resp.Data))
+		}
+		if diff := cmp.Diff(wantReq, gotReq); diff != "" {
+			t.Errorf("LogsRequest diff (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(wantChunks, chunks); diff != "" {
+			t.Errorf("chunks diff (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("gateway", func(t *testing.T) {
+		gw := runtime.NewServeMux()
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		if err := apipb.RegisterRebuildServiceHandlerFromEndpoint(ctx, gw, addr, opts); err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewServer(gw)
+		defer srv.Close()
+		resp, err := http.Get(srv.URL + "/v1/logs?ecosystem=npm&package=left-pad&version=1.3.0&artifact=left-pad-1.3.0.tgz&id=run")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %s, want 200 OK", resp.Status)
+		}
+		var chunks []string
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			var msg struct {
+				Result struct {
+					Data []byte `json:"data"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+				t.Fatalf("parsing %q: %v", sc.Text(), err)
+			}
+			chunks = append(chunks, string(msg.Result.Data))
+		}
+		if diff := cmp.Diff(wantReq, gotReq); diff != "" {
+			t.Errorf("LogsRequest diff (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff(wantChunks, chunks); diff != "" {
+			t.Errorf("chunks diff (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestGRPCServerGatewayVersion(t *testing.T) {
+	addr := serveGRPC(t, &GRPCServer{
+		VersionStub: func(ctx context.Context, req schema.VersionRequest) (*schema.VersionResponse, error) {
+			return &schema.VersionResponse{Version: req.Service + "@v1"}, nil
+		},
+	})
+	gw := runtime.NewServeMux()
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if err := apipb.RegisterRebuildServiceHandlerFromEndpoint(context.Background(), gw, addr, opts); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/v1/version?service=build-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got apipb.VersionResponse
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("parsing %q: %v", b, err)
+	}
+	if got.Version != "build-local@v1" {
+		t.Errorf("Version = %q, want %q", got.Version, "build-local@v1")
+	}
+}
@@ -0,0 +1,64 @@
+package apiservice
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/gcb"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/api/cloudbuild/v1"
+	"google.golang.org/grpc/codes"
+)
+
+type LogsDeps struct {
+	GCBClient       gcb.Client
+	GCSClient       *gcs.Client
+	BuildLogsBucket string
+	MetadataBuilder func(ctx context.Context, id string) (rebuild.AssetStore, error)
+	PollInterval    time.Duration
+}
+
+// Logs streams the logs of a remote rebuild as they are written until the build completes.
+func Logs(ctx context.Context, req schema.LogsRequest, deps *LogsDeps, w io.Writer) error {
+	metadata, err := deps.MetadataBuilder(ctx, req.ID)
+	if err != nil {
+		return api.AsStatus(codes.Internal, errors.Wrap(err, "creating metadata store"))
+	}
+	r, _, err := metadata.Reader(ctx, rebuild.Asset{Target: req.Target(), Type: rebuild.RemoteBuildAsset})
+	if errors.Is(err, rebuild.ErrAssetNotFound) {
+		return api.AsStatus(codes.NotFound, errors.New("no remote build found"))
+	} else if err != nil {
+		return api.AsStatus(codes.Internal, errors.Wrap(err, "opening remote build"))
+	}
+	defer r.Close()
+	var rb rebuild.RemoteBuild
+	if err := json.NewDecoder(r).Decode(&rb); err != nil {
+		return api.AsStatus(codes.Internal, errors.Wrap(err, "parsing remote build"))
+	}
+	logs := &gcsLog{obj: deps.GCSClient.Bucket(deps.BuildLogsBucket).Object(gcb.LogObject(rb.BuildID))}
+	return gcb.FollowLogs(ctx, deps.GCBClient, &cloudbuild.Operation{Name: rb.Operation}, logs, w, deps.PollInterval)
+}
+
+// gcsLog is a build log stored in GCS.
+type gcsLog struct {
+	obj *gcs.ObjectHandle
+}
+
+var _ gcb.LogSource = &gcsLog{}
+
+func (l *gcsLog) ReadFrom(ctx context.Context, offset int64) (io.ReadCloser, error) {
+	r, err := l.obj.NewRangeReader(ctx, offset, -1)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return http.NoBody, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,29 @@
+package apiservice
+
+import (
+	"context"
+
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+)
+
+// platformRunner executes builds using a runner service on a non-Linux host.
+type platformRunner struct {
+	stub api.StubT[schema.PlatformRunRequest, schema.PlatformRunResponse]
+}
+
+var _ rebuild.PlatformRunner = &platformRunner{}
+
+func (r *platformRunner) Run(ctx context.Context, req rebuild.PlatformRunRequest) (*rebuild.PlatformRunResult, error) {
+	resp, err := r.stub(ctx, schema.PlatformRunRequest{Target: req.Target, Script: req.Script, Resources: &req.Resources})
+	if err != nil {
+		return nil, errors.Wrap(err, "calling runner")
+	}
+	res := &rebuild.PlatformRunResult{Artifact: resp.Artifact, Logs: resp.Logs, Runner: resp.Runner}
+	if resp.Error != "" {
+		return res, errors.New(resp.Error)
+	}
+	return res, nil
+}
@@ -0,0 +1,121 @@
+package apiservice
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/notify"
+	"github.com/google/oss-rebuild/internal/quarantine"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// quarantineCollection holds the review status of each quarantined artifact.
+	quarantineCollection = "quarantine"
+	// quarantineAuditCollection is the append-only log of quarantine workflow transitions.
+	quarantineAuditCollection = "quarantine_audit"
+)
+
+func quarantineDoc(client *firestore.Client, t rebuild.Target) *firestore.DocumentRef {
+	key := sanitize(strings.Join([]string{string(t.Ecosystem), t.Package, t.Version, t.Artifact}, "!"))
+	return client.Collection(quarantineCollection).Doc(key)
+}
+
+// maybeQuarantine quarantines the artifact if the risk of its mismatch meets the threshold.
+// Artifacts that were previously quarantined, including those since released, are not re-flagged.
+// A nil client or non-positive threshold disables quarantine.
+func maybeQuarantine(ctx context.Context, client *firestore.Client, n notify.Notifier, t rebuild.Target, runID string, risk *rebuild.RiskAssessment, threshold int) {
+	if client == nil || threshold <= 0 || risk == nil || risk.Score < threshold {
+		return
+	}
+	rec, entry := quarantine.New(t, runID, risk, time.Now().UTC())
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Create(quarantineDoc(client, t), rec); err != nil {
+			return err
+		}
+		return tx.Create(client.Collection(quarantineAuditCollection).NewDoc(), entry)
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return
+	} else if err != nil {
+		log.Printf("quarantining %v: %v\n", t, err)
+		return
+	}
+	notify.Send(ctx, n, notify.Event{Kind: notify.Quarantined, Target: &t, RunID: runID, Message: rec.Reason})
+}
+
+type QuarantineReviewDeps struct {
+	FirestoreClient *firestore.Client
+	// AdvisoryDrafter, if provided, opens an advisory draft when a result is escalated.
+	AdvisoryDrafter quarantine.AdvisoryDrafter
+	Notifier        notify.Notifier
+}
+
+// QuarantineReview applies a reviewer's action to a quarantined rebuild result.
+// The reviewer is the authenticated caller recorded in ctx.
+func QuarantineReview(ctx context.Context, req schema.QuarantineReviewRequest, deps *QuarantineReviewDeps) (*schema.QuarantineReviewResponse, error) {
+	reviewer, ok := api.Caller(ctx)
+	if !ok {
+		return nil, api.AsStatus(codes.Unauthenticated, errors.New("reviewer is not authenticated"))
+	}
+	action := quarantine.Action(req.Action)
+	if !slices.Contains(quarantine.Actions, action) {
+		return nil, api.AsStatus(codes.InvalidArgument, errors.Errorf("unknown action: %s", req.Action))
+	}
+	if err := normalizePackage(req.Ecosystem, &req.Package); err != nil {
+		return nil, err
+	}
+	t := rebuild.Target{Ecosystem: req.Ecosystem, Package: req.Package, Version: req.Version, Artifact: req.Artifact}
+	doc := quarantineDoc(deps.FirestoreClient, t)
+	var rec quarantine.Record
+	err := deps.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(doc)
+		if err != nil {
+			return err
+		}
+		if err := snap.DataTo(&rec); err != nil {
+			return errors.Wrap(err, "parsing quarantine record")
+		}
+		entry, err := rec.Apply(reviewer, action, req.Note, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if err := tx.Set(doc, rec); err != nil {
+			return err
+		}
+		return tx.Create(deps.FirestoreClient.Collection(quarantineAuditCollection).NewDoc(), entry)
+	})
+	switch {
+	case status.Code(err) == codes.NotFound:
+		return nil, api.AsStatus(codes.NotFound, errors.New("artifact is not quarantined"))
+	case errors.Is(err, quarantine.ErrSelfApproval):
+		return nil, api.AsStatus(codes.PermissionDenied, err)
+	case errors.Is(err, quarantine.ErrInvalidTransition):
+		return nil, api.AsStatus(codes.FailedPrecondition, err)
+	case err != nil:
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore transaction"))
+	}
+	if rec.State == quarantine.Escalated && deps.AdvisoryDrafter != nil {
+		if u, err := deps.AdvisoryDrafter.Draft(ctx, rec); err != nil {
+			log.Printf("drafting advisory for %v: %v\n", t, err)
+		} else {
+			rec.AdvisoryURL = u
+			if _, err := doc.Update(ctx, []firestore.Update{{Path: "advisory_url", Value: u}}); err != nil {
+				log.Printf("recording advisory for %v: %v\n", t, err)
+			}
+		}
+	}
+	if rec.State.Terminal() {
+		notify.Send(ctx, deps.Notifier, notify.Event{Kind: notify.Quarantined, Target: &t, Message: string(rec.State)})
+	}
+	return &schema.QuarantineReviewResponse{State: string(rec.State), AdvisoryURL: rec.AdvisoryURL}, nil
+}
@@ -5,69 +5,95 @@ import (
 	"context"
 	"crypto"
 	"fmt"
+	"log"
 	"strings"
+	"time"
 
+	"cloud.google.com/go/firestore"
+	"github.com/ProtonMail/go-crypto/openpgp"
 	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/cache"
+	"github.com/google/oss-rebuild/internal/feed"
 	"github.com/google/oss-rebuild/internal/gcb"
 	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/mirror"
+	"github.com/google/oss-rebuild/internal/notify"
+	"github.com/google/oss-rebuild/internal/telemetry"
 	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/archive"
 	"github.com/google/oss-rebuild/pkg/builddef"
+	"github.com/google/oss-rebuild/pkg/pkgname"
 	cratesrb "github.com/google/oss-rebuild/pkg/rebuild/cratesio"
 	npmrb "github.com/google/oss-rebuild/pkg/rebuild/npm"
 	pypirb "github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
-	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
-	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
-	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/codes"
 )
 
-func doNPMRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
-	if err := npmrb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
-		return "", errors.Wrap(err, "rebuild failed")
+// The doRebuild functions return the upstream URL of each artifact rebuilt, keyed by artifact name.
+
+func doNPMRebuild(ctx context.Context, input rebuild.Input, id string, mux rebuild.RegistryMux, opts rebuild.RemoteOptions) (upstreamURLs map[string]string, err error) {
+	t := input.Target
+	if err := npmrb.RebuildRemote(ctx, input, id, opts); err != nil {
+		return nil, errors.Wrap(err, "rebuild failed")
 	}
 	vmeta, err := mux.NPM.Version(ctx, t.Package, t.Version)
 	if err != nil {
-		return "", errors.Wrap(err, "fetching metadata failed")
+		return nil, errors.Wrap(err, "fetching metadata failed")
 	}
-	return vmeta.Dist.URL, nil
+	return map[string]string{t.Artifact: vmeta.Dist.URL}, nil
 }
 
-func doCratesRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
-	if err := cratesrb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
-		return "", errors.Wrap(err, "rebuild failed")
+func doCratesRebuild(ctx context.Context, input rebuild.Input, id string, mux rebuild.RegistryMux, opts rebuild.RemoteOptions) (upstreamURLs map[string]string, err error) {
+	t := input.Target
+	if err := cratesrb.RebuildRemote(ctx, input, id, opts); err != nil {
+		return nil, errors.Wrap(err, "rebuild failed")
 	}
 	vmeta, err := mux.CratesIO.Version(ctx, t.Package, t.Version)
 	if err != nil {
-		return "", errors.Wrap(err, "fetching metadata failed")
+		return nil, errors.Wrap(err, "fetching metadata failed")
 	}
-	return vmeta.DownloadURL, nil
+	return map[string]string{t.Artifact: vmeta.DownloadURL}, nil
 }
 
-func doPyPIRebuild(ctx context.Context, t rebuild.Target, id string, mux rebuild.RegistryMux, s rebuild.Strategy, opts rebuild.RemoteOptions) (upstreamURL string, err error) {
+func doPyPIRebuild(ctx context.Context, input rebuild.Input, id string, mux rebuild.RegistryMux, opts rebuild.RemoteOptions) (upstreamURLs map[string]string, err error) {
+	t := input.Target
 	release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
 	if err != nil {
-		return "", errors.Wrap(err, "fetching metadata failed")
+		return nil, errors.Wrap(err, "fetching metadata failed")
 	}
-	for _, r := range release.Artifacts {
-		if r.Filename == t.Artifact {
-			upstreamURL = r.URL
+	upstreamURLs = make(map[string]string)
+	for _, a := range input.Targets() {
+		for _, r := range release.Artifacts {
+			if r.Filename == a.Artifact {
+				upstreamURLs[a.Artifact] = r.URL
+			}
+		}
+		if _, ok := upstreamURLs[a.Artifact]; !ok {
+			return nil, errors.Errorf("artifact %s not found in release", a.Artifact)
 		}
 	}
-	if upstreamURL == "" {
-		return "", errors.New("artifact not found in release")
+	if err := pypirb.RebuildRemote(ctx, input, id, opts); err != nil {
+		return nil, errors.Wrap(err, "rebuild failed")
 	}
-	if err := pypirb.RebuildRemote(ctx, rebuild.Input{Target: t, Strategy: s}, id, opts); err != nil {
-		return "", errors.Wrap(err, "rebuild failed")
+	return upstreamURLs, nil
+}
+
+// normalizePackage replaces the package name with its canonical spelling.
+func normalizePackage(eco rebuild.Ecosystem, pkg *string) error {
+	name, err := pkgname.Normalize(eco, *pkg)
+	if err != nil {
+		return api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "normalizing package"))
 	}
-	return upstreamURL, nil
+	*pkg = name
+	return nil
 }
 
 func sanitize(key string) string {
@@ -99,10 +125,40 @@ func populateArtifact(ctx context.Context, t *rebuild.Target, mux rebuild.Regist
 	return nil
 }
 
+// siblingArtifacts returns the other artifacts of the target's version that can
+// be produced by the same build as the target.
+func siblingArtifacts(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) ([]string, error) {
+	switch t.Ecosystem {
+	case rebuild.NPM, rebuild.CratesIO:
+		// NOTE: These ecosystems publish a single artifact per version.
+		return nil, nil
+	case rebuild.PyPI:
+		release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching metadata failed")
+		}
+		var siblings []string
+		for _, a := range release.Artifacts {
+			// NOTE: Only pure wheels are supported by the PyPI build strategy.
+			if a.Filename != t.Artifact && strings.HasSuffix(a.Filename, "none-any.whl") {
+				siblings = append(siblings, a.Filename)
+			}
+		}
+		return siblings, nil
+	default:
+		return nil, errors.New("unknown ecosystem")
+	}
+}
+
 type RebuildPackageDeps struct {
 	HTTPClient            httpx.BasicClient
+	FirestoreClient       *firestore.Client
 	Signer                *dsse.EnvelopeSigner
 	GCBClient             gcb.Client
+	KubeOptions           *rebuild.KubeOptions
+	PlatformRunStubs      map[rebuild.Platform]api.StubT[schema.PlatformRunRequest, schema.PlatformRunResponse]
+	BuildCache            *rebuild.BuildCache
+	ImageResolver         rebuild.ImageResolver
 	BuildProject          string
 	BuildServiceAccount   string
 	UtilPrebuildBucket    string
@@ -111,36 +167,94 @@ type RebuildPackageDeps struct {
 	AttestationStore      rebuild.AssetStore
 	MetadataBuilder       func(ctx context.Context, id string) (rebuild.AssetStore, error)
 	OverwriteAttestations bool
-	InferStub             api.StubT[schema.InferenceRequest, schema.StrategyOneOf]
+	// AttestationLayout is the arrangement of objects written to AttestationStore.
+	AttestationLayout verifier.StorageLayout
+	InferStub         api.StubT[schema.InferenceRequest, schema.StrategyOneOf]
+	Notifier          notify.Notifier
+	Watched           feed.Tracked
+	// Mirrors, if provided, are the private registry mirrors from which upstream artifacts are read.
+	Mirrors *mirror.Config
+	// Upstream configures the retention of upstream artifacts and the sources
+	// from which to read those no longer served by their registry.
+	Upstream verifier.UpstreamOptions
+	// VerifyCargoVCSInfo enables comparing the commit recorded in published
+	// crates against the commit from which they were rebuilt.
+	VerifyCargoVCSInfo bool
+	// Stabilization configures the opt-in stabilizers applied when comparing artifacts.
+	Stabilization archive.Options
+	// MavenKeyring, if provided, holds the publisher keys trusted to sign
+	// Maven artifacts. If not provided, Maven signatures are not verified.
+	MavenKeyring openpgp.KeyRing
 }
 
 func RebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps *RebuildPackageDeps) (*api.NoReturn, error) {
-	t := rebuild.Target{Ecosystem: req.Ecosystem, Package: req.Package, Version: req.Version}
+	t := rebuild.Target{Ecosystem: req.Ecosystem, Package: req.Package, Version: req.Version, Artifact: req.Artifact}
+	ctx, span := telemetry.StartSpan(ctx, "RebuildPackage",
+		attribute.String("ecosystem", string(t.Ecosystem)),
+		attribute.String("package", t.Package),
+		attribute.String("version", t.Version),
+	)
+	defer span.End()
 	ctx = context.WithValue(ctx, rebuild.HTTPBasicClientID, deps.HTTPClient)
-	regclient := httpx.NewCachedClient(deps.HTTPClient, &cache.CoalescingMemoryCache{})
-	mux := rebuild.RegistryMux{
-		CratesIO: cratesreg.HTTPRegistry{Client: regclient},
-		NPM:      npmreg.HTTPRegistry{Client: regclient},
-		PyPI:     pypireg.HTTPRegistry{Client: regclient},
+	regclient := httpx.NewCachedClient(&telemetry.RegistryClient{BasicClient: deps.HTTPClient}, &cache.CoalescingMemoryCache{})
+	mux, err := deps.Mirrors.RegistryMux(regclient)
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "configuring registries"))
+	}
+	t, err = pkgname.Resolver{Mux: mux}.Resolve(ctx, t)
+	if err != nil {
+		return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "resolving package"))
 	}
 	if err := populateArtifact(ctx, &t, mux); err != nil {
 		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "selecting artifact"))
 	}
+	rbinput := rebuild.Input{Target: t}
+	if req.AllArtifacts {
+		siblings, err := siblingArtifacts(ctx, t, mux)
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "selecting sibling artifacts"))
+		}
+		rbinput.Siblings = siblings
+	}
 	signer := verifier.InTotoEnvelopeSigner{EnvelopeSigner: deps.Signer}
-	a := verifier.Attestor{Store: deps.AttestationStore, Signer: signer, AllowOverwrite: deps.OverwriteAttestations}
+	a := verifier.Attestor{Store: deps.AttestationStore, Signer: signer, AllowOverwrite: deps.OverwriteAttestations, Layout: deps.AttestationLayout}
 	if !deps.OverwriteAttestations {
-		if exists, err := a.BundleExists(ctx, t); err != nil {
-			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "checking existing bundle"))
-		} else if exists {
-			return nil, api.AsStatus(codes.AlreadyExists, errors.New("conflict with existing attestation bundle"))
+		for _, at := range rbinput.Targets() {
+			if exists, err := a.BundleExists(ctx, at); err != nil {
+				return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "checking existing bundle"))
+			} else if exists {
+				return nil, api.AsStatus(codes.AlreadyExists, errors.Errorf("conflict with existing attestation bundle for %s", at.Artifact))
+			}
+		}
+	}
+	// NOTE: Upstream metadata is authenticated before rebuilding so that a
+	// rebuild is never compared against a forged upstream.
+	upstreamChecks := make(map[string][]verifier.UpstreamCheck)
+	for _, at := range rbinput.Targets() {
+		var check *verifier.UpstreamCheck
+		if at.Ecosystem == rebuild.Maven && deps.MavenKeyring != nil {
+			check, err = verifier.VerifyMavenSignature(ctx, at, mux.Maven, deps.MavenKeyring)
+		} else {
+			check, err = verifier.VerifyRegistrySignature(ctx, at, mux)
 		}
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "verifying upstream signature"))
+		}
+		if check == nil {
+			continue
+		}
+		if !check.Verified {
+			recordStatus(ctx, deps.FirestoreClient, at, req.ID, false, "upstream signature verification failed")
+			return nil, api.AsStatus(codes.FailedPrecondition, errors.Errorf("upstream signature verification failed for %s: %s", at.Artifact, check.Detail))
+		}
+		upstreamChecks[at.Artifact] = append(upstreamChecks[at.Artifact], *check)
 	}
 	var manualStrategy, strategy rebuild.Strategy
 	var buildDefLoc rebuild.Location
 	ireq := schema.InferenceRequest{
-		Ecosystem: req.Ecosystem,
-		Package:   req.Package,
-		Version:   req.Version,
+		Ecosystem: t.Ecosystem,
+		Package:   t.Package,
+		Version:   t.Version,
 	}
 	if req.StrategyFromRepo {
 		defs, err := builddef.NewBuildDefinitionSetFromGit(&builddef.GitBuildDefinitionSetOptions{
@@ -189,8 +303,17 @@ func RebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps
 	if err != nil {
 		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "creating metadata store"))
 	}
-	var upstreamURI string
+	// NOTE: The remote build is recorded under the request's run so it can be located while in progress.
+	runMetadata, err := deps.MetadataBuilder(ctx, req.ID)
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "creating run metadata store"))
+	}
+	var upstreamURIs map[string]string
 	hashes := []crypto.Hash{crypto.SHA256}
+	if t.Ecosystem == rebuild.Maven {
+		// NOTE: Maven repositories publish only the SHA-1 checksum of each file.
+		hashes = append(hashes, crypto.SHA1)
+	}
 	opts := rebuild.RemoteOptions{
 		GCBClient:           deps.GCBClient,
 		Project:             deps.BuildProject,
@@ -198,39 +321,107 @@ func RebuildPackage(ctx context.Context, req schema.RebuildPackageRequest, deps
 		UtilPrebuildBucket:  deps.UtilPrebuildBucket,
 		LogsBucket:          deps.BuildLogsBucket,
 		MetadataStore:       metadata,
+		ImageResolver:       deps.ImageResolver,
+		RemoteBuildStore:    runMetadata,
+		Kube:                deps.KubeOptions,
+		PlatformRunners:     make(map[rebuild.Platform]rebuild.PlatformRunner),
+		BuildCache:          deps.BuildCache,
+		BypassBuildCache:    req.BypassCache,
+	}
+	for p, stub := range deps.PlatformRunStubs {
+		opts.PlatformRunners[p] = &platformRunner{stub: stub}
+	}
+	rbinput.Strategy = strategy
+	rbinput.DisableInstallHooks = req.DisableInstallHooks
+	if req.Resources != nil {
+		rbinput.Resources = *req.Resources
 	}
 	// TODO: These doRebuild functions should return a verdict, and this handler
 	// should forward those to the caller as a schema.Verdict.
+	start := time.Now()
 	switch t.Ecosystem {
 	case rebuild.NPM:
 		hashes = append(hashes, crypto.SHA512)
-		upstreamURI, err = doNPMRebuild(ctx, t, id, mux, strategy, opts)
+		upstreamURIs, err = doNPMRebuild(ctx, rbinput, id, mux, opts)
 	case rebuild.CratesIO:
-		upstreamURI, err = doCratesRebuild(ctx, t, id, mux, strategy, opts)
+		upstreamURIs, err = doCratesRebuild(ctx, rbinput, id, mux, opts)
 	case rebuild.PyPI:
-		upstreamURI, err = doPyPIRebuild(ctx, t, id, mux, strategy, opts)
+		upstreamURIs, err = doPyPIRebuild(ctx, rbinput, id, mux, opts)
 	default:
 		return nil, api.AsStatus(codes.InvalidArgument, errors.New("unsupported ecosystem"))
 	}
 	if err != nil {
+		telemetry.RecordRebuild(ctx, "api", string(t.Ecosystem), time.Since(start), false)
+		for _, at := range rbinput.Targets() {
+			recordStatus(ctx, deps.FirestoreClient, at, req.ID, false, err.Error())
+		}
+		notifyFailure(ctx, deps.Notifier, deps.Watched, t, req.ID, err.Error(), false)
 		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "rebuilding"))
 	}
-	rb, up, err := verifier.SummarizeArtifacts(ctx, metadata, t, upstreamURI, hashes)
-	if err != nil {
-		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "comparing artifacts"))
-	}
-	exactMatch := bytes.Equal(rb.Hash.Sum(nil), up.Hash.Sum(nil))
-	canonicalizedMatch := bytes.Equal(rb.CanonicalHash.Sum(nil), up.CanonicalHash.Sum(nil))
-	if !exactMatch && !canonicalizedMatch {
-		return nil, api.AsStatus(codes.FailedPrecondition, errors.Wrap(err, "rebuild content mismatch"))
+	// NOTE: Each artifact is verified and attested independently so that a
+	// mismatch of one does not prevent the attestation of the others.
+	var mismatched, unverified []string
+	upstreamOpts := deps.Upstream
+	upstreamOpts.KnownDigests = knownUpstreamDigests(deps.FirestoreClient, a)
+	for _, at := range rbinput.Targets() {
+		rb, up, err := verifier.SummarizeArtifacts(ctx, metadata, at, upstreamURIs[at.Artifact], hashes, deps.Stabilization, upstreamOpts)
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrapf(err, "comparing artifacts of %s", at.Artifact))
+		}
+		recordUpstreamDigest(ctx, deps.FirestoreClient, deps.Notifier, at, req.ID, up)
+		check, err := verifier.VerifyRegistryChecksum(ctx, at, mux, up)
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrapf(err, "verifying upstream checksum of %s", at.Artifact))
+		}
+		if check != nil && !check.Verified {
+			recordStatus(ctx, deps.FirestoreClient, at, req.ID, false, "upstream checksum verification failed")
+			unverified = append(unverified, at.Artifact)
+			continue
+		} else if check != nil {
+			upstreamChecks[at.Artifact] = append(upstreamChecks[at.Artifact], *check)
+		}
+		if deps.VerifyCargoVCSInfo && at.Ecosystem == rebuild.CratesIO {
+			// NOTE: A mismatch is informative rather than disqualifying since
+			// the recorded commit may be unpushed or have been rewritten.
+			if inst, err := strategy.GenerateFor(at, rebuild.BuildEnv{}); err != nil {
+				log.Printf("generating instructions for %s: %v", at.Artifact, err)
+			} else if check := verifier.VerifyCargoVCSInfo(up, inst.Location); check != nil {
+				upstreamChecks[at.Artifact] = append(upstreamChecks[at.Artifact], *check)
+			}
+		}
+		up.Checks = upstreamChecks[at.Artifact]
+		exactMatch := bytes.Equal(rb.Hash.Sum(nil), up.Hash.Sum(nil))
+		canonicalizedMatch := bytes.Equal(rb.CanonicalHash.Sum(nil), up.CanonicalHash.Sum(nil))
+		telemetry.RecordRebuild(ctx, "api", string(at.Ecosystem), time.Since(start), exactMatch || canonicalizedMatch)
+		if !exactMatch && !canonicalizedMatch {
+			recordStatus(ctx, deps.FirestoreClient, at, req.ID, false, "rebuild content mismatch")
+			notifyFailure(ctx, deps.Notifier, deps.Watched, at, req.ID, "rebuild content mismatch", false)
+			mismatched = append(mismatched, at.Artifact)
+			continue
+		}
+		input := rebuild.Input{Target: at, Strategy: manualStrategy, DisableInstallHooks: req.DisableInstallHooks}
+		eqStmt, buildStmt, err := verifier.CreateAttestations(ctx, input, strategy, id, rb, up, metadata, buildDefLoc)
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "creating attestations"))
+		}
+		if err := a.PublishBundle(ctx, at, eqStmt, buildStmt); errors.Is(err, verifier.ErrIndexNotUpdated) {
+			// NOTE: The bundle was published so the rebuild succeeded. The index
+			// is recreated by the next index migration.
+			log.Printf("publishing bundle for %s: %v", at.Artifact, err)
+		} else if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "publishing bundle"))
+		}
+		match := schema.StabilizedMatch
+		if exactMatch {
+			match = schema.ExactMatch
+		}
+		recordSuccess(ctx, deps.FirestoreClient, at, req.ID, match)
 	}
-	input := rebuild.Input{Target: t, Strategy: manualStrategy}
-	eqStmt, buildStmt, err := verifier.CreateAttestations(ctx, input, strategy, id, rb, up, metadata, buildDefLoc)
-	if err != nil {
-		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "creating attestations"))
+	if len(unverified) > 0 {
+		return nil, api.AsStatus(codes.FailedPrecondition, errors.Errorf("upstream checksum verification failed: %s", strings.Join(unverified, ", ")))
 	}
-	if err := a.PublishBundle(ctx, t, eqStmt, buildStmt); err != nil {
-		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "publishing bundle"))
+	if len(mismatched) > 0 {
+		return nil, api.AsStatus(codes.FailedPrecondition, errors.Errorf("rebuild content mismatch: %s", strings.Join(mismatched, ", ")))
 	}
 	return nil, nil
 }
@@ -0,0 +1,41 @@
+package apiservice
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/sbom"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+type SBOMDeps struct {
+	BatchStub api.StubT[schema.BatchRequest, schema.BatchResponse]
+}
+
+// SBOM submits the supported components of an SBOM as a batch job.
+func SBOM(ctx context.Context, req schema.SBOMRequest, deps *SBOMDeps) (*schema.SBOMResponse, error) {
+	doc, err := sbom.Parse(strings.NewReader(req.SBOM))
+	if err != nil {
+		return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "parsing SBOM"))
+	}
+	targets, unsupported := doc.Targets()
+	if len(targets) == 0 {
+		return nil, api.AsStatus(codes.InvalidArgument, errors.New("no supported components in SBOM"))
+	}
+	resp, err := deps.BatchStub(ctx, schema.BatchRequest{Mode: req.Mode, Targets: targets, Name: req.Name})
+	if err != nil {
+		return nil, err
+	}
+	r := &schema.SBOMResponse{BatchResponse: *resp}
+	for _, c := range unsupported {
+		id := c.PURL
+		if id == "" {
+			id = c.Name + "@" + c.Version
+		}
+		r.Unsupported = append(r.Unsupported, id)
+	}
+	return r, nil
+}
@@ -9,9 +9,14 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/feed"
+	"github.com/google/oss-rebuild/internal/notify"
+	"github.com/google/oss-rebuild/internal/telemetry"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/iterator"
 	"google.golang.org/grpc/codes"
 )
 
@@ -19,12 +24,50 @@ type RebuildSmoketestDeps struct {
 	FirestoreClient *firestore.Client
 	SmoketestStub   api.StubT[schema.SmoketestRequest, schema.SmoketestResponse]
 	VersionStub     api.StubT[schema.VersionRequest, schema.VersionResponse]
+	Notifier        notify.Notifier
+	Watched         feed.Tracked
+	// QuarantineThreshold is the risk score at or above which a mismatch is quarantined.
+	// Zero disables quarantine.
+	QuarantineThreshold int
+}
+
+// lastAttemptSucceeded returns whether the most recent recorded attempt succeeded.
+func lastAttemptSucceeded(ctx context.Context, attempts *firestore.CollectionRef) (bool, error) {
+	doc, err := attempts.OrderBy("created", firestore.Desc).Limit(1).Documents(ctx).Next()
+	if err == iterator.Done {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var sa schema.SmoketestAttempt
+	if err := doc.DataTo(&sa); err != nil {
+		return false, err
+	}
+	return sa.Success, nil
+}
+
+// notifyFailure reports the failed rebuild of a target to the operators if it is a regression or watched.
+func notifyFailure(ctx context.Context, n notify.Notifier, watched feed.Tracked, t rebuild.Target, runID, msg string, regressed bool) {
+	if regressed {
+		notify.Send(ctx, n, notify.Event{Kind: notify.Regression, Target: &t, RunID: runID, Message: msg})
+	}
+	if watched.Contains(t.Ecosystem, t.Package) {
+		notify.Send(ctx, n, notify.Event{Kind: notify.WatchedFailure, Target: &t, RunID: runID, Message: msg})
+	}
 }
 
 func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *RebuildSmoketestDeps) (*schema.SmoketestResponse, error) {
 	if sreq.ID == "" {
 		sreq.ID = time.Now().UTC().Format(time.RFC3339)
 	}
+	if err := normalizePackage(sreq.Ecosystem, &sreq.Package); err != nil {
+		return nil, err
+	}
+	ctx, span := telemetry.StartSpan(ctx, "RebuildSmoketest",
+		attribute.String("ecosystem", string(sreq.Ecosystem)),
+		attribute.String("package", sreq.Package),
+	)
+	defer span.End()
 	log.Printf("Dispatching smoketest: %v", sreq)
 	versionChan := make(chan string, 1)
 	go func() {
@@ -39,7 +82,7 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 	}()
 	resp, stuberr := deps.SmoketestStub(ctx, sreq)
 	var verdicts []schema.Verdict
-	var executor string
+	var executor, cacheKey string
 	if errors.Is(stuberr, api.ErrNotOK) {
 		log.Printf("smoketest failed: %v\n", stuberr)
 		// If smoketest failed, populate the verdicts with as much info as we can (pulling executor
@@ -62,6 +105,7 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 	} else {
 		verdicts = resp.Verdicts
 		executor = resp.Executor
+		cacheKey = resp.DependencyCacheKey
 	}
 	for _, v := range verdicts {
 		var rawStrategy string
@@ -70,7 +114,37 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 		} else {
 			rawStrategy = string(enc)
 		}
-		_, err := deps.FirestoreClient.Collection("ecosystem").Doc(string(v.Target.Ecosystem)).Collection("packages").Doc(sanitize(sreq.Package)).Collection("versions").Doc(v.Target.Version).Collection("attempts").Doc(sreq.ID).Set(ctx, schema.SmoketestAttempt{
+		var riskScore int
+		var riskEvidence string
+		if v.Risk != nil {
+			riskScore = v.Risk.Score
+			if enc, err := json.Marshal(v.Risk.Evidence); err != nil {
+				log.Printf("invalid risk evidence returned from smoketest: %v\n", err)
+			} else {
+				riskEvidence = string(enc)
+			}
+		}
+		var installHooks string
+		if v.InstallHooks != nil {
+			if enc, err := json.Marshal(v.InstallHooks.Hooks); err != nil {
+				log.Printf("invalid install hooks returned from smoketest: %v\n", err)
+			} else {
+				installHooks = string(enc)
+			}
+		}
+		var failureSnippet string
+		if v.LogFailure != nil {
+			failureSnippet = v.LogFailure.Snippet
+		}
+		attempts := deps.FirestoreClient.Collection("ecosystem").Doc(string(v.Target.Ecosystem)).Collection("packages").Doc(sanitize(sreq.Package)).Collection("versions").Doc(v.Target.Version).Collection("attempts")
+		if v.Message != "" && deps.Notifier != nil {
+			regressed, err := lastAttemptSucceeded(ctx, attempts)
+			if err != nil {
+				log.Printf("reading previous attempt for %s@%s: %v\n", sreq.Package, v.Target.Version, err)
+			}
+			notifyFailure(ctx, deps.Notifier, deps.Watched, v.Target, sreq.ID, v.Message, regressed)
+		}
+		_, err := attempts.Doc(sreq.ID).Set(ctx, schema.SmoketestAttempt{
 			Ecosystem:         string(v.Target.Ecosystem),
 			Package:           v.Target.Package,
 			Version:           v.Target.Version,
@@ -83,12 +157,18 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 			TimeInfer:         v.Timings.Infer.Seconds(),
 			TimeBuild:         v.Timings.Build.Seconds(),
 			ExecutorVersion:   executor,
+			DependencyCache:   cacheKey,
 			RunID:             sreq.ID,
 			Created:           time.Now().UnixMilli(),
+			RiskScore:         riskScore,
+			RiskEvidence:      riskEvidence,
+			InstallHooks:      installHooks,
+			FailureSnippet:    failureSnippet,
 		})
 		if err != nil {
 			return nil, api.AsStatus(codes.Internal, errors.Wrapf(err, "writing record for %s@%s", sreq.Package, v.Target.Version))
 		}
+		maybeQuarantine(ctx, deps.FirestoreClient, deps.Notifier, v.Target, sreq.ID, v.Risk, deps.QuarantineThreshold)
 	}
 	if stuberr != nil {
 		// TODO: Pass on status code here.
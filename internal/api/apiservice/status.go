@@ -0,0 +1,207 @@
+package apiservice
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/badge"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// statusCollection is the index of the latest attested rebuild outcome of each artifact.
+	statusCollection = "status"
+	// outcomesCollection is the append-only record of every attested rebuild outcome.
+	outcomesCollection = "outcomes"
+	// statsCollection holds the ReproducibilityStats of each ecosystem, with
+	// those of each month in its "months" subcollection, maintained as
+	// outcomes are recorded.
+	statsCollection = "stats"
+)
+
+// recordStatus updates the status index with the outcome of an attested rebuild.
+// A nil client disables the index.
+func recordStatus(ctx context.Context, client *firestore.Client, t rebuild.Target, runID string, success bool, msg string) {
+	writeStatus(ctx, client, t, schema.RebuildStatus{Success: success, Message: msg, RunID: runID})
+}
+
+// recordSuccess updates the status index with a successful attested rebuild.
+// A nil client disables the index.
+func recordSuccess(ctx context.Context, client *firestore.Client, t rebuild.Target, runID string, match schema.MatchKind) {
+	writeStatus(ctx, client, t, schema.RebuildStatus{Success: true, Match: match, RunID: runID})
+}
+
+func writeStatus(ctx context.Context, client *firestore.Client, t rebuild.Target, s schema.RebuildStatus) {
+	if client == nil {
+		return
+	}
+	s.Ecosystem = string(t.Ecosystem)
+	s.Package = t.Package
+	s.Version = t.Version
+	s.Artifact = t.Artifact
+	s.Updated = time.Now().UTC().UnixMilli()
+	key := sanitize(strings.Join([]string{string(t.Ecosystem), t.Package, t.Version, t.Artifact}, "!"))
+	doc := client.Collection(statusCollection).Doc(key)
+	eco := client.Collection(statsCollection).Doc(s.Ecosystem)
+	month := eco.Collection("months").Doc(schema.StatsMonth(s.Updated))
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// The ecosystem's totals count the latest outcome of each artifact so
+		// that of any previous rebuild is replaced.
+		delta := schema.OutcomeStats(s)
+		snap, err := tx.Get(doc)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		} else if err == nil {
+			var prev schema.RebuildStatus
+			if err := snap.DataTo(&prev); err != nil {
+				return errors.Wrap(err, "parsing status")
+			}
+			delta = delta.Sub(schema.OutcomeStats(prev))
+		}
+		if err := tx.Set(doc, s); err != nil {
+			return err
+		}
+		if err := tx.Create(client.Collection(outcomesCollection).NewDoc(), s); err != nil {
+			return err
+		}
+		if err := tx.Set(eco, statsIncrements(delta), firestore.MergeAll); err != nil {
+			return err
+		}
+		return tx.Set(month, statsIncrements(schema.OutcomeStats(s)), firestore.MergeAll)
+	})
+	if err != nil {
+		log.Printf("recording status of %v: %v\n", t, err)
+	}
+}
+
+// statsIncrements returns the updates adding the counts of delta to a stored ReproducibilityStats.
+func statsIncrements(delta schema.ReproducibilityStats) map[string]any {
+	updates := make(map[string]any)
+	for field, n := range map[string]int{
+		"attempted":    delta.Attempted,
+		"reproducible": delta.Reproducible,
+		"exact":        delta.Exact,
+		"stabilized":   delta.Stabilized,
+		"failed":       delta.Failed,
+	} {
+		if n != 0 {
+			updates[field] = firestore.Increment(n)
+		}
+	}
+	return updates
+}
+
+type StatusDeps struct {
+	FirestoreClient *firestore.Client
+}
+
+// Status returns the latest attested rebuild status of the requested package version.
+func Status(ctx context.Context, req schema.StatusRequest, deps *StatusDeps) (*schema.StatusResponse, error) {
+	if err := normalizePackage(req.Ecosystem, &req.Package); err != nil {
+		return nil, err
+	}
+	q := deps.FirestoreClient.Collection(statusCollection).
+		Where("ecosystem", "==", string(req.Ecosystem)).
+		Where("package", "==", req.Package).
+		Where("version", "==", req.Version)
+	if req.Artifact != "" {
+		q = q.Where("artifact", "==", req.Artifact)
+	}
+	resp := &schema.StatusResponse{}
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore read"))
+		}
+		var s schema.RebuildStatus
+		if err := doc.DataTo(&s); err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "parsing status"))
+		}
+		resp.Artifacts = append(resp.Artifacts, s)
+	}
+	var err error
+	resp.Annotations, err = fetchAnnotations(ctx, deps.FirestoreClient, req)
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "fetching annotations"))
+	}
+	return resp, nil
+}
+
+// Badge writes an SVG badge displaying the latest attested rebuild status of the requested package version.
+func Badge(ctx context.Context, req schema.StatusRequest, deps *StatusDeps, w io.Writer) error {
+	resp, err := Status(ctx, req, deps)
+	if err != nil {
+		return err
+	}
+	message, color := "unknown", badge.Grey
+	switch {
+	case resp.Reproducible():
+		message, color = "reproducible", badge.Green
+	case len(resp.Artifacts) > 0:
+		message, color = "unreproducible", badge.Red
+	}
+	b, err := badge.Render("oss-rebuild", message, color)
+	if err != nil {
+		return api.AsStatus(codes.Internal, errors.Wrap(err, "rendering badge"))
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+type StatsDeps struct {
+	FirestoreClient *firestore.Client
+}
+
+// Stats returns the aggregate reproducibility statistics maintained as outcomes are recorded.
+func Stats(ctx context.Context, req schema.StatsRequest, deps *StatsDeps) (*schema.StatsResponse, error) {
+	coll := deps.FirestoreClient.Collection(statsCollection)
+	var docs []*firestore.DocumentSnapshot
+	if req.Ecosystem != "" {
+		doc, err := coll.Doc(string(req.Ecosystem)).Get(ctx)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore read"))
+		} else if err == nil {
+			docs = append(docs, doc)
+		}
+	} else {
+		var err error
+		docs, err = coll.Documents(ctx).GetAll()
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore read"))
+		}
+	}
+	resp := &schema.StatsResponse{Ecosystems: []schema.EcosystemStats{}}
+	for _, doc := range docs {
+		e := schema.EcosystemStats{Ecosystem: rebuild.Ecosystem(doc.Ref.ID), Months: make(map[string]schema.ReproducibilityStats)}
+		if err := doc.DataTo(&e.ReproducibilityStats); err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "parsing stats"))
+		}
+		months, err := doc.Ref.Collection("months").Documents(ctx).GetAll()
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "firestore read"))
+		}
+		for _, m := range months {
+			var ms schema.ReproducibilityStats
+			if err := m.DataTo(&ms); err != nil {
+				return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "parsing stats"))
+			}
+			e.Months[m.Ref.ID] = ms
+		}
+		resp.Ecosystems = append(resp.Ecosystems, e)
+	}
+	return resp, nil
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/idtoken"
+)
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx recording the authenticated identity of the caller.
+func WithCaller(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, callerKey{}, identity)
+}
+
+// Caller returns the authenticated identity of the caller recorded in ctx.
+func Caller(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(callerKey{}).(string)
+	return identity, ok && identity != ""
+}
+
+// TokenValidator verifies an ID token issued for audience and returns its claims.
+// idtoken.Validate is a TokenValidator for Google-signed ID tokens.
+type TokenValidator func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+
+// Authenticated serves h only for requests bearing a valid ID token issued for
+// audience, recording the token's verified email address as the caller.
+// An empty audience rejects all requests.
+func Authenticated(h http.Handler, audience string, validate TokenValidator) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		identity, err := authenticate(r, audience, validate)
+		if err != nil {
+			log.Println(errors.Wrap(err, "authenticating request"))
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(rw, r.WithContext(WithCaller(r.Context(), identity)))
+	})
+}
+
+// Authorized serves h only for callers, authenticated by an enclosing
+// Authenticated handler, whose identity is one of allowed. Identities are
+// compared case-insensitively. An empty allowed rejects all requests.
+func Authorized(h http.Handler, allowed []string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		identity, ok := Caller(r.Context())
+		if !ok || !slices.ContainsFunc(allowed, func(a string) bool { return strings.EqualFold(a, identity) }) {
+			log.Printf("rejecting unauthorized caller %q\n", identity)
+			http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+func authenticate(r *http.Request, audience string, validate TokenValidator) (string, error) {
+	if audience == "" {
+		return "", errors.New("no audience configured")
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", errors.New("missing bearer token")
+	}
+	payload, err := validate(r.Context(), token, audience)
+	if err != nil {
+		return "", err
+	}
+	// NOTE: Validators need not check the audience themselves.
+	if payload.Audience != audience {
+		return "", errors.Errorf("unexpected audience: %s", payload.Audience)
+	}
+	email, _ := payload.Claims["email"].(string)
+	if verified, _ := payload.Claims["email_verified"].(bool); email == "" || !verified {
+		return "", errors.New("token has no verified email")
+	}
+	return email, nil
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/idtoken"
+)
+
+func TestAuthenticated(t *testing.T) {
+	const audience = "https://api.example.com"
+	validate := func(ctx context.Context, token, aud string) (*idtoken.Payload, error) {
+		switch token {
+		case "valid":
+			return &idtoken.Payload{Audience: aud, Claims: map[string]any{"email": "alice@example.com", "email_verified": true}}, nil
+		case "unverified":
+			return &idtoken.Payload{Audience: aud, Claims: map[string]any{"email": "alice@example.com", "email_verified": false}}, nil
+		case "no-email":
+			return &idtoken.Payload{Audience: aud, Claims: map[string]any{}}, nil
+		case "other-audience":
+			return &idtoken.Payload{Audience: "https://other.example.com", Claims: map[string]any{"email": "alice@example.com", "email_verified": true}}, nil
+		default:
+			return nil, errors.New("invalid token")
+		}
+	}
+	for _, tc := range []struct {
+		name       string
+		audience   string
+		header     string
+		wantStatus int
+		wantCaller string
+	}{
+		{name: "valid", audience: audience, header: "Bearer valid", wantStatus: http.StatusOK, wantCaller: "alice@example.com"},
+		{name: "missing header", audience: audience, header: "", wantStatus: http.StatusUnauthorized},
+		{name: "not bearer", audience: audience, header: "Basic valid", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", audience: audience, header: "Bearer forged", wantStatus: http.StatusUnauthorized},
+		{name: "unverified email", audience: audience, header: "Bearer unverified", wantStatus: http.StatusUnauthorized},
+		{name: "no email", audience: audience, header: "Bearer no-email", wantStatus: http.StatusUnauthorized},
+		{name: "audience mismatch", audience: audience, header: "Bearer other-audience", wantStatus: http.StatusUnauthorized},
+		{name: "no audience configured", audience: "", header: "Bearer valid", wantStatus: http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotCaller string
+			h := Authenticated(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				gotCaller, _ = Caller(r.Context())
+			}), tc.audience, validate)
+			req := httptest.NewRequest(http.MethodPost, "/quarantine/review", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if gotCaller != tc.wantCaller {
+				t.Errorf("caller = %q, want %q", gotCaller, tc.wantCaller)
+			}
+		})
+	}
+}
+
+func TestHandlerPropagatesCaller(t *testing.T) {
+	var gotCaller string
+	h := Handler(NoDepsInit, func(ctx context.Context, req FooRequest, _ *NoDeps) (*FooResponse, error) {
+		gotCaller, _ = Caller(ctx)
+		return &FooResponse{}, nil
+	})
+	req := httptest.NewRequest(http.MethodPost, "/?foo=x", nil)
+	req = req.WithContext(WithCaller(req.Context(), "alice@example.com"))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if gotCaller != "alice@example.com" {
+		t.Errorf("caller = %q, want %q", gotCaller, "alice@example.com")
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	allowed := []string{"alice@example.com", "Bob@Example.com"}
+	for _, tc := range []struct {
+		name       string
+		allowed    []string
+		caller     string
+		wantStatus int
+	}{
+		{name: "allowed", allowed: allowed, caller: "alice@example.com", wantStatus: http.StatusOK},
+		{name: "case-insensitive", allowed: allowed, caller: "bob@example.com", wantStatus: http.StatusOK},
+		{name: "not allowed", allowed: allowed, caller: "builder@project.iam.gserviceaccount.com", wantStatus: http.StatusForbidden},
+		{name: "unauthenticated", allowed: allowed, wantStatus: http.StatusForbidden},
+		{name: "none allowed", caller: "alice@example.com", wantStatus: http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var served bool
+			h := Authorized(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				served = true
+			}), tc.allowed)
+			req := httptest.NewRequest(http.MethodPost, "/quarantine/review", nil)
+			if tc.caller != "" {
+				req = req.WithContext(WithCaller(req.Context(), tc.caller))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if served != (tc.wantStatus == http.StatusOK) {
+				t.Errorf("served = %t, want %t", served, !served)
+			}
+		})
+	}
+}
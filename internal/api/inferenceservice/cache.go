@@ -0,0 +1,104 @@
+package inferenceservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"runtime/debug"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+)
+
+// Cache persists inference results so unchanged packages are not re-analyzed.
+//
+// Entries are keyed on the request and the version of the inference code.
+// Inference is deterministic for a given code version so a new deployment
+// implicitly invalidates the entries of its predecessors.
+type Cache struct {
+	// Store holds the cached strategies and should be shared across instances.
+	Store rebuild.AssetStore
+	// Version identifies the inference code. Entries are only reused within a version.
+	Version string
+}
+
+// CodeVersion returns an identifier for the running inference code, or the
+// empty string if none is available.
+func CodeVersion() string {
+	if rev := os.Getenv("K_REVISION"); rev != "" {
+		return rev
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		var rev string
+		var modified bool
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				rev = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		// NOTE: Builds from a modified tree don't uniquely identify their code.
+		if !modified {
+			return rev
+		}
+	}
+	return ""
+}
+
+func (c *Cache) asset(req schema.InferenceRequest) (rebuild.Asset, error) {
+	b, err := json.Marshal(struct {
+		Ecosystem    rebuild.Ecosystem
+		Package      string
+		Version      string
+		StrategyHint *schema.StrategyOneOf
+		CodeVersion  string
+	}{req.Ecosystem, req.Package, req.Version, req.StrategyHint, c.Version})
+	if err != nil {
+		return rebuild.Asset{}, errors.Wrap(err, "marshalling key")
+	}
+	sum := sha256.Sum256(b)
+	key := hex.EncodeToString(sum[:])
+	return rebuild.Asset{Type: rebuild.InferenceAsset, Target: rebuild.Target{Ecosystem: "inferencecache", Package: key[:2], Version: key, Artifact: "strategy"}}, nil
+}
+
+// Get returns the cached result of req, returning false if none exists.
+func (c *Cache) Get(ctx context.Context, req schema.InferenceRequest) (*schema.StrategyOneOf, bool, error) {
+	a, err := c.asset(req)
+	if err != nil {
+		return nil, false, err
+	}
+	r, _, err := c.Store.Reader(ctx, a)
+	if errors.Is(err, rebuild.ErrAssetNotFound) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "opening entry")
+	}
+	defer r.Close()
+	var oneof schema.StrategyOneOf
+	if err := json.NewDecoder(r).Decode(&oneof); err != nil {
+		return nil, false, errors.Wrap(err, "decoding entry")
+	}
+	return &oneof, true, nil
+}
+
+// Put records the result of req.
+func (c *Cache) Put(ctx context.Context, req schema.InferenceRequest, oneof *schema.StrategyOneOf) error {
+	a, err := c.asset(req)
+	if err != nil {
+		return err
+	}
+	w, _, err := c.Store.Writer(ctx, a)
+	if err != nil {
+		return errors.Wrap(err, "creating writer")
+	}
+	if err := json.NewEncoder(w).Encode(oneof); err != nil {
+		w.Close()
+		return errors.Wrap(err, "encoding entry")
+	}
+	return w.Close()
+}
@@ -0,0 +1,45 @@
+package inferenceservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	store := rebuild.NewFilesystemAssetStore(memfs.New())
+	req := schema.InferenceRequest{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0"}
+	hint := schema.NewStrategyOneOf(&rebuild.LocationHint{Location: rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: "abcd"}})
+	c := &Cache{Store: store, Version: "v1"}
+	if _, ok, err := c.Get(ctx, req); err != nil || ok {
+		t.Fatalf("Get() on empty cache = %v, %v", ok, err)
+	}
+	if err := c.Put(ctx, req, &hint); err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+	got, ok, err := c.Get(ctx, req)
+	if err != nil || !ok {
+		t.Fatalf("Get() = %v, %v", ok, err)
+	}
+	if diff := cmp.Diff(&hint, got); diff != "" {
+		t.Errorf("Get() mismatch (-want +got):\n%s", diff)
+	}
+	t.Run("VersionMiss", func(t *testing.T) {
+		other := &Cache{Store: store, Version: "v2"}
+		if _, ok, err := other.Get(ctx, req); err != nil || ok {
+			t.Errorf("Get() with new version = %v, %v", ok, err)
+		}
+	})
+	t.Run("HintMiss", func(t *testing.T) {
+		hinted := req
+		hinted.StrategyHint = &hint
+		if _, ok, err := c.Get(ctx, hinted); err != nil || ok {
+			t.Errorf("Get() with hint = %v, %v", ok, err)
+		}
+	})
+}
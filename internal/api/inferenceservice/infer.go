@@ -8,55 +8,93 @@ import (
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/mirror"
+	"github.com/google/oss-rebuild/internal/repodiscovery"
+	"github.com/google/oss-rebuild/pkg/pkgname"
 	"github.com/google/oss-rebuild/pkg/rebuild/cratesio"
 	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/oci"
 	"github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
-	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
-	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
-	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 )
 
-func doInfer(ctx context.Context, rebuilder rebuild.Rebuilder, t rebuild.Target, mux rebuild.RegistryMux, hint rebuild.Strategy) (rebuild.Strategy, error) {
+func inferWithRepo(ctx context.Context, rebuilder rebuild.Rebuilder, t rebuild.Target, mux rebuild.RegistryMux, repo string, hint rebuild.Strategy) (rebuild.Strategy, error) {
 	s := memory.NewStorage()
 	fs := memfs.New()
-	var repo string
-	if lh, ok := hint.(*rebuild.LocationHint); ok && lh != nil {
-		repo = lh.Location.Repo
-	} else {
-		var err error
-		repo, err = rebuilder.InferRepo(ctx, t, mux)
-		if err != nil {
-			return nil, err
-		}
-	}
 	rcfg, err := rebuilder.CloneRepo(ctx, t, repo, fs, s)
 	if err != nil {
 		return nil, err
 	}
-	strategy, err := rebuilder.InferStrategy(ctx, t, mux, &rcfg, hint)
-	if err != nil {
-		return nil, err
+	return rebuilder.InferStrategy(ctx, t, mux, &rcfg, hint)
+}
+
+func doInfer(ctx context.Context, rebuilder rebuild.Rebuilder, t rebuild.Target, mux rebuild.RegistryMux, hint rebuild.Strategy, discoverer *repodiscovery.Discoverer) (rebuild.Strategy, error) {
+	if lh, ok := hint.(*rebuild.LocationHint); ok && lh != nil {
+		return inferWithRepo(ctx, rebuilder, t, mux, lh.Location.Repo, hint)
+	}
+	repo, err := rebuilder.InferRepo(ctx, t, mux)
+	if err != nil || repo == "" {
+		if discoverer == nil {
+			return nil, err
+		}
+		log.Printf("No repo in metadata [pkg=%s, version=%v]: %v. Attempting discovery\n", t.Package, t.Version, err)
+		repo, err = discoverer.Discover(ctx, t)
+		if err != nil {
+			return nil, errors.Wrap(err, "discovering repo")
+		}
+		return inferWithRepo(ctx, rebuilder, t, mux, repo, hint)
 	}
-	return strategy, nil
+	strategy, err := inferWithRepo(ctx, rebuilder, t, mux, repo, hint)
+	if err != nil && discoverer != nil {
+		// NOTE: Metadata can reference the wrong repo so search for a better match.
+		log.Printf("Inference failed using metadata repo [pkg=%s, version=%v, repo=%s]: %v. Attempting discovery\n", t.Package, t.Version, repo, err)
+		alt, derr := discoverer.Discover(ctx, t, repo)
+		if derr != nil {
+			log.Printf("Repo discovery failed [pkg=%s, version=%v]: %v\n", t.Package, t.Version, derr)
+			return nil, err
+		}
+		return inferWithRepo(ctx, rebuilder, t, mux, alt, hint)
+	}
+	return strategy, err
 }
 
 type InferDeps struct {
 	HTTPClient httpx.BasicClient
+	// Cache, if provided, is consulted before and populated after inference.
+	Cache *Cache
+	// RepoDiscovery enables searching for the source repo when package metadata lacks a usable one.
+	RepoDiscovery bool
+	// Mirrors, if provided, are the private registry mirrors from which package metadata is read.
+	Mirrors *mirror.Config
 }
 
 func Infer(ctx context.Context, req schema.InferenceRequest, deps *InferDeps) (*schema.StrategyOneOf, error) {
 	if req.LocationHint() != nil && req.LocationHint().Ref == "" && req.LocationHint().Dir != "" {
 		return nil, api.AsStatus(codes.Unimplemented, errors.New("location hint dir without ref not implemented"))
 	}
+	pkg, err := pkgname.Normalize(req.Ecosystem, req.Package)
+	if err != nil {
+		return nil, api.AsStatus(codes.InvalidArgument, errors.Wrap(err, "normalizing package"))
+	}
+	req.Package = pkg
+	if deps.Cache != nil {
+		if oneof, ok, err := deps.Cache.Get(ctx, req); err != nil {
+			log.Printf("Reading inference cache for [pkg=%s, version=%v]: %v\n", req.Package, req.Version, err)
+		} else if ok {
+			return oneof, nil
+		}
+	}
 	ctx = context.WithValue(ctx, rebuild.HTTPBasicClientID, deps.HTTPClient)
-	mux := rebuild.RegistryMux{
-		CratesIO: cratesreg.HTTPRegistry{Client: deps.HTTPClient},
-		NPM:      npmreg.HTTPRegistry{Client: deps.HTTPClient},
-		PyPI:     pypireg.HTTPRegistry{Client: deps.HTTPClient},
+	mux, err := deps.Mirrors.RegistryMux(deps.HTTPClient)
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "configuring registries"))
+	}
+	var discoverer *repodiscovery.Discoverer
+	if deps.RepoDiscovery {
+		discoverer = repodiscovery.New(deps.HTTPClient, mux)
 	}
 	var s rebuild.Strategy
 	t := rebuild.Target{
@@ -65,14 +103,15 @@ func Infer(ctx context.Context, req schema.InferenceRequest, deps *InferDeps) (*
 		Version:   req.Version,
 	}
 	// TODO: Use req.LocationHint in these individual infer calls.
-	var err error
 	switch req.Ecosystem {
 	case rebuild.NPM:
-		s, err = doInfer(ctx, npm.Rebuilder{}, t, mux, req.LocationHint())
+		s, err = doInfer(ctx, npm.Rebuilder{}, t, mux, req.LocationHint(), discoverer)
 	case rebuild.PyPI:
-		s, err = doInfer(ctx, pypi.Rebuilder{}, t, mux, req.LocationHint())
+		s, err = doInfer(ctx, pypi.Rebuilder{}, t, mux, req.LocationHint(), discoverer)
 	case rebuild.CratesIO:
-		s, err = doInfer(ctx, cratesio.Rebuilder{}, t, mux, req.LocationHint())
+		s, err = doInfer(ctx, cratesio.Rebuilder{}, t, mux, req.LocationHint(), discoverer)
+	case rebuild.OCI:
+		s, err = doInfer(ctx, oci.Rebuilder{}, t, mux, req.LocationHint(), discoverer)
 	default:
 		return nil, api.AsStatus(codes.InvalidArgument, errors.New("unsupported ecosystem"))
 	}
@@ -81,5 +120,10 @@ func Infer(ctx context.Context, req schema.InferenceRequest, deps *InferDeps) (*
 		return nil, api.AsStatus(codes.InvalidArgument, errors.New("no inference provided"))
 	}
 	oneof := schema.NewStrategyOneOf(s)
+	if deps.Cache != nil {
+		if err := deps.Cache.Put(ctx, req, &oneof); err != nil {
+			log.Printf("Writing inference cache for [pkg=%s, version=%v]: %v\n", req.Package, req.Version, err)
+		}
+	}
 	return &oneof, nil
 }
@@ -4,20 +4,21 @@ import (
 	"context"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/google/oss-rebuild/internal/api"
 	"github.com/google/oss-rebuild/internal/gitx"
 	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/mirror"
+	"github.com/google/oss-rebuild/internal/telemetry"
 	rsrb "github.com/google/oss-rebuild/pkg/rebuild/cratesio"
+	"github.com/google/oss-rebuild/pkg/rebuild/lint"
 	mavenrb "github.com/google/oss-rebuild/pkg/rebuild/maven"
 	npmrb "github.com/google/oss-rebuild/pkg/rebuild/npm"
+	ocirb "github.com/google/oss-rebuild/pkg/rebuild/oci"
 	pypirb "github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
-	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
-	mavenreg "github.com/google/oss-rebuild/pkg/registry/maven"
-	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
-	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 )
@@ -82,10 +83,21 @@ func doCratesIORebuildSmoketest(ctx context.Context, req schema.SmoketestRequest
 	return rsrb.RebuildMany(rbctx, inputs, mux)
 }
 
-func doMavenRebuildSmoketest(ctx context.Context, req schema.SmoketestRequest, versionCount int) ([]rebuild.Verdict, error) {
+func doOCIRebuildSmoketest(ctx context.Context, req schema.SmoketestRequest, mux rebuild.RegistryMux) ([]rebuild.Verdict, error) {
+	// NOTE: Image tags are mutable and seldom track releases so versions must be provided.
 	if len(req.Versions) == 0 {
-		var meta mavenreg.MavenPackage
-		meta, err := mavenreg.PackageMetadata(req.Package)
+		return nil, errors.New("versions must be provided for OCI images")
+	}
+	inputs, err := req.ToInputs()
+	if err != nil {
+		return nil, errors.Wrap(err, "converting smoketest request to inputs")
+	}
+	return ocirb.RebuildMany(ctx, inputs, mux)
+}
+
+func doMavenRebuildSmoketest(ctx context.Context, req schema.SmoketestRequest, mux rebuild.RegistryMux, versionCount int) ([]rebuild.Verdict, error) {
+	if len(req.Versions) == 0 {
+		meta, err := mux.Maven.PackageMetadata(ctx, req.Package)
 		if err != nil {
 			return nil, errors.Wrapf(err, "Failed to fetch versions")
 		}
@@ -102,6 +114,34 @@ func doMavenRebuildSmoketest(ctx context.Context, req schema.SmoketestRequest, v
 	return mavenrb.RebuildMany(rbctx, req.Package, inputs)
 }
 
+// lintRequest returns failing verdicts for the versions whose provided strategy
+// has errors which would fail the rebuild, sparing the execution of the build.
+func lintRequest(ctx context.Context, req schema.SmoketestRequest, env rebuild.BuildEnv) ([]schema.Verdict, error) {
+	inputs, err := req.ToInputs()
+	if err != nil {
+		return nil, errors.Wrap(err, "converting smoketest request to inputs")
+	}
+	var verdicts []schema.Verdict
+	for _, input := range inputs {
+		findings := lint.Lint(ctx, input.Target, input.Strategy, lint.Options{Env: env, Shell: "sh"})
+		if !lint.HasErrors(findings) {
+			continue
+		}
+		var msgs []string
+		for _, f := range findings {
+			if f.Severity == lint.Error {
+				msgs = append(msgs, f.String())
+			}
+		}
+		verdicts = append(verdicts, schema.Verdict{
+			Target:        input.Target,
+			Message:       "invalid strategy: " + strings.Join(msgs, "; "),
+			StrategyOneof: *req.Strategy,
+		})
+	}
+	return verdicts, nil
+}
+
 type RebuildSmoketestDeps struct {
 	HTTPClient          httpx.BasicClient
 	GitCache            *gitx.Cache
@@ -109,6 +149,10 @@ type RebuildSmoketestDeps struct {
 	TimewarpURL         *string
 	DebugBucket         *string
 	DefaultVersionCount int
+	// DependencyCacheKey, if provided, identifies the dependency caches mounted into this rebuilder.
+	DependencyCacheKey string
+	// Mirrors, if provided, are the private registry mirrors from which upstream artifacts are read.
+	Mirrors *mirror.Config
 }
 
 func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *RebuildSmoketestDeps) (*schema.SmoketestResponse, error) {
@@ -118,10 +162,10 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 		ctx = context.WithValue(ctx, rebuild.RepoCacheClientID, *deps.GitCache)
 	}
 	ctx = context.WithValue(ctx, rebuild.HTTPBasicClientID, deps.HTTPClient)
-	mux := rebuild.RegistryMux{
-		CratesIO: cratesreg.HTTPRegistry{Client: deps.HTTPClient},
-		NPM:      npmreg.HTTPRegistry{Client: deps.HTTPClient},
-		PyPI:     pypireg.HTTPRegistry{Client: deps.HTTPClient},
+	regclient := &telemetry.RegistryClient{BasicClient: deps.HTTPClient}
+	mux, err := deps.Mirrors.RegistryMux(regclient)
+	if err != nil {
+		return nil, errors.Wrap(err, "configuring registries")
 	}
 	if deps.TimewarpURL != nil {
 		ctx = context.WithValue(ctx, rebuild.TimewarpID, *deps.TimewarpURL)
@@ -130,8 +174,27 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 	if deps.DebugBucket != nil {
 		ctx = context.WithValue(ctx, rebuild.UploadArtifactsPathID, *deps.DebugBucket)
 	}
+	if sreq.Strategy != nil {
+		env := rebuild.BuildEnv{HasRepo: true}
+		if deps.TimewarpURL != nil {
+			env.TimewarpHost = *deps.TimewarpURL
+		}
+		invalid, err := lintRequest(ctx, sreq, env)
+		if err != nil {
+			return nil, api.AsStatus(codes.InvalidArgument, err)
+		}
+		if len(invalid) > 0 {
+			return &schema.SmoketestResponse{Verdicts: invalid, Executor: os.Getenv("K_REVISION"), DependencyCacheKey: deps.DependencyCacheKey}, nil
+		}
+	}
+	if deps.DependencyCacheKey != "" {
+		release, err := rebuild.AcquireDependencyCache(sreq.Ecosystem)
+		if err != nil {
+			return nil, api.AsStatus(codes.Internal, err)
+		}
+		defer release()
+	}
 	var verdicts []rebuild.Verdict
-	var err error
 	switch sreq.Ecosystem {
 	case rebuild.NPM:
 		verdicts, err = doNpmRebuildSmoketest(ctx, sreq, mux, deps.DefaultVersionCount)
@@ -139,8 +202,10 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 		verdicts, err = doPypiRebuildSmoketest(ctx, sreq, mux, deps.DefaultVersionCount)
 	case rebuild.CratesIO:
 		verdicts, err = doCratesIORebuildSmoketest(ctx, sreq, mux, deps.DefaultVersionCount)
+	case rebuild.OCI:
+		verdicts, err = doOCIRebuildSmoketest(ctx, sreq, mux)
 	case rebuild.Maven:
-		verdicts, err = doMavenRebuildSmoketest(ctx, sreq, deps.DefaultVersionCount)
+		verdicts, err = doMavenRebuildSmoketest(ctx, sreq, mux, deps.DefaultVersionCount)
 	default:
 		return nil, api.AsStatus(codes.InvalidArgument, errors.New("unsupported ecosystem"))
 	}
@@ -152,12 +217,16 @@ func RebuildSmoketest(ctx context.Context, sreq schema.SmoketestRequest, deps *R
 	}
 	smkVerdicts := make([]schema.Verdict, len(verdicts))
 	for i, v := range verdicts {
+		telemetry.RecordRebuild(ctx, "build-local", string(v.Target.Ecosystem), v.Timings.Source+v.Timings.Infer+v.Timings.Build, v.Message == "")
 		smkVerdicts[i] = schema.Verdict{
 			Target:        v.Target,
 			Message:       v.Message,
 			StrategyOneof: schema.NewStrategyOneOf(v.Strategy),
 			Timings:       v.Timings,
+			Risk:          v.Risk,
+			InstallHooks:  v.InstallHooks,
+			LogFailure:    v.LogFailure,
 		}
 	}
-	return &schema.SmoketestResponse{Verdicts: smkVerdicts, Executor: os.Getenv("K_REVISION")}, nil
+	return &schema.SmoketestResponse{Verdicts: smkVerdicts, Executor: os.Getenv("K_REVISION"), DependencyCacheKey: deps.DependencyCacheKey}, nil
 }
@@ -3,14 +3,15 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/google/oss-rebuild/internal/httpx"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
-	"github.com/google/oss-rebuild/pkg/rebuild/schema/form"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -21,6 +22,9 @@ type Dependencies interface{}
 type InitT[D Dependencies] func(context.Context) (D, error)
 type HandlerT[I schema.Message, O any, D Dependencies] func(context.Context, I, D) (*O, error)
 type StubT[I schema.Message, O any] func(context.Context, I) (*O, error)
+type StreamHandlerT[I schema.Message, D Dependencies] func(context.Context, I, D, io.Writer) error
+type StreamStubT[I schema.Message] func(context.Context, I) (io.ReadCloser, error)
+type StreamT[I schema.Message] func(context.Context, I, io.Writer) error
 
 type NoDeps struct{}
 
@@ -32,26 +36,11 @@ var ErrNotOK = errors.New("non-OK response")
 
 func Stub[I schema.Message, O any](client httpx.BasicClient, u url.URL) StubT[I, O] {
 	return func(ctx context.Context, i I) (*O, error) {
-		values, err := form.Marshal(i)
+		resp, err := post(ctx, client, u, i)
 		if err != nil {
-			return nil, errors.Wrap(err, "serializing request")
-		}
-		if err := i.Validate(); err != nil {
-			return nil, errors.Wrap(err, "serializing request")
-		}
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(values.Encode()))
-		if err != nil {
-			return nil, errors.Wrap(err, "building http request")
-		}
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, errors.Wrap(err, "making http request")
+			return nil, err
 		}
 		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			return nil, errors.Wrap(ErrNotOK, resp.Status)
-		}
 		var o O
 		if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
 			return nil, errors.Wrap(err, "decoding response")
@@ -60,10 +49,80 @@ func Stub[I schema.Message, O any](client httpx.BasicClient, u url.URL) StubT[I,
 	}
 }
 
+// StreamStub returns a client for a StreamHandler. The caller must close the returned reader.
+func StreamStub[I schema.Message](client httpx.BasicClient, u url.URL) StreamStubT[I] {
+	return func(ctx context.Context, i I) (io.ReadCloser, error) {
+		resp, err := post(ctx, client, u, i)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	}
+}
+
+// post sends the request and returns the response if it was successful.
+func post(ctx context.Context, client httpx.BasicClient, u url.URL, i schema.Message) (*http.Response, error) {
+	values, err := schema.Encode(i)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing request")
+	}
+	if err := i.Validate(); err != nil {
+		return nil, errors.Wrap(err, "serializing request")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "building http request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "making http request")
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusBadRequest {
+			// NOTE: Servers that predate versioning do not set the header.
+			if v, err := schema.ParseVersion(resp.Header.Get(schema.VersionHeader)); err == nil && v != schema.V0 && v < schema.CurrentVersion {
+				return nil, errors.Wrapf(schema.ErrUnsupportedVersion, "server supports up to version %d", v)
+			}
+		}
+		return nil, errors.Wrap(ErrNotOK, resp.Status)
+	}
+	return resp, nil
+}
+
 func StubFromHandler[I schema.Message, O any, D Dependencies](client httpx.BasicClient, u url.URL, handler HandlerT[I, O, D]) StubT[I, O] {
 	return Stub[I, O](client, u)
 }
 
+// Unary adapts a handler to serve requests outside of HTTP, such as over gRPC.
+func Unary[I schema.Message, O any, D Dependencies](initDeps InitT[D], handler HandlerT[I, O, D]) StubT[I, O] {
+	return func(ctx context.Context, req I) (*O, error) {
+		if err := req.Validate(); err != nil {
+			return nil, AsStatus(codes.InvalidArgument, errors.Wrap(err, "validating request"))
+		}
+		deps, err := initDeps(ctx)
+		if err != nil {
+			return nil, AsStatus(codes.Internal, errors.Wrap(err, "initializing dependencies"))
+		}
+		return handler(ctx, req, deps)
+	}
+}
+
+// Stream adapts a stream handler to serve requests outside of HTTP, such as over gRPC.
+func Stream[I schema.Message, D Dependencies](initDeps InitT[D], handler StreamHandlerT[I, D]) StreamT[I] {
+	return func(ctx context.Context, req I, w io.Writer) error {
+		if err := req.Validate(); err != nil {
+			return AsStatus(codes.InvalidArgument, errors.Wrap(err, "validating request"))
+		}
+		deps, err := initDeps(ctx)
+		if err != nil {
+			return AsStatus(codes.Internal, errors.Wrap(err, "initializing dependencies"))
+		}
+		return handler(ctx, req, deps, w)
+	}
+}
+
 func AsStatus(code codes.Code, err error) error {
 	return status.New(code, err.Error()).Err()
 }
@@ -88,12 +147,17 @@ var grpcToHTTP = map[codes.Code]int{
 	codes.Unauthenticated:    http.StatusUnauthorized,
 }
 
+// Handler serves a handler that writes a JSON response.
+//
+// The handler's context carries the values of the request's context, such as
+// the authenticated caller, but is not canceled when the client disconnects.
 func Handler[I schema.Message, O any, D Dependencies](initDeps InitT[D], handler HandlerT[I, O, D]) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx := context.Background()
+		ctx := context.WithoutCancel(r.Context())
 		r.ParseForm()
 		var req I
-		if err := form.Unmarshal(r.Form, &req); err != nil {
+		rw.Header().Set(schema.VersionHeader, strconv.Itoa(int(schema.CurrentVersion)))
+		if err := schema.Decode(r.Form, &req); err != nil {
 			log.Println(errors.Wrap(err, "parsing request"))
 			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			return
@@ -110,14 +174,7 @@ func Handler[I schema.Message, O any, D Dependencies](initDeps InitT[D], handler
 			return
 		}
 		o, err := handler(ctx, req, deps)
-		s := status.Convert(err)
-		status, ok := grpcToHTTP[s.Code()]
-		if !ok {
-			log.Printf("unknown error code: %s\n", s.Code())
-			status = http.StatusInternalServerError
-		}
-		if status != http.StatusOK {
-			log.Println(s.Err())
+		if status := httpStatus(err); status != http.StatusOK {
 			http.Error(rw, http.StatusText(status), status)
 			return
 		}
@@ -129,3 +186,81 @@ func Handler[I schema.Message, O any, D Dependencies](initDeps InitT[D], handler
 		}
 	}
 }
+
+// httpStatus returns the HTTP status corresponding to a handler's error, logging any failure.
+func httpStatus(err error) int {
+	s := status.Convert(err)
+	code, ok := grpcToHTTP[s.Code()]
+	if !ok {
+		log.Printf("unknown error code: %s\n", s.Code())
+		code = http.StatusInternalServerError
+	}
+	if code != http.StatusOK {
+		log.Println(s.Err())
+	}
+	return code
+}
+
+// StreamHandler serves a handler that writes a plain text response as it is produced.
+// A Content-Type set on the response before the handler is invoked is preserved.
+//
+// Unlike Handler, the handler's context is canceled when the client disconnects.
+// Errors that occur after the response has begun are logged and end the response.
+func StreamHandler[I schema.Message, D Dependencies](initDeps InitT[D], handler StreamHandlerT[I, D]) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		r.ParseForm()
+		var req I
+		rw.Header().Set(schema.VersionHeader, strconv.Itoa(int(schema.CurrentVersion)))
+		if err := schema.Decode(r.Form, &req); err != nil {
+			log.Println(errors.Wrap(err, "parsing request"))
+			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			log.Println(errors.Wrap(err, "validating request"))
+			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		deps, err := initDeps(ctx)
+		if err != nil {
+			log.Println(errors.Wrap(err, "initializing dependencies"))
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w := &flushWriter{rw: rw}
+		err = handler(ctx, req, deps, w)
+		if w.started {
+			if err != nil {
+				log.Println(errors.Wrap(err, "streaming response"))
+			}
+			return
+		}
+		if status := httpStatus(err); status != http.StatusOK {
+			http.Error(rw, http.StatusText(status), status)
+		}
+	}
+}
+
+// flushWriter flushes each write to the client.
+type flushWriter struct {
+	rw      http.ResponseWriter
+	started bool
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !w.started {
+		if w.rw.Header().Get("Content-Type") == "" {
+			w.rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.started = true
+	}
+	n, err := w.rw.Write(p)
+	if f, ok := w.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
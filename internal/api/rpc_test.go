@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -44,8 +45,8 @@ func TestStub(t *testing.T) {
 		if r.Method != "POST" {
 			t.Errorf("Expected POST request, got %s", r.Method)
 		}
-		if form := r.Form.Encode(); form != "foo=foo" {
-			t.Errorf("Expected form 'foo=foo', got '%s'", form)
+		if form := r.Form.Encode(); form != "foo=foo&schema_version=1" {
+			t.Errorf("Expected form 'foo=foo&schema_version=1', got '%s'", form)
 		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"Bar":"Bar"}`))
@@ -161,3 +162,42 @@ func TestHandlerWithError(t *testing.T) {
 		t.Errorf("Expected body '%s', got '%s'", expectedBody, string(b))
 	}
 }
+
+func TestUnary(t *testing.T) {
+	ctx := context.Background()
+	handler := func(ctx context.Context, req FooRequest, deps *NoDeps) (*FooResponse, error) {
+		return &FooResponse{Bar: req.Foo}, nil
+	}
+	resp, err := Unary(NoDepsInit, handler)(ctx, FooRequest{Foo: "foo"})
+	if err != nil {
+		t.Fatalf("Unary returned an error: %v", err)
+	}
+	if resp.Bar != "foo" {
+		t.Errorf("Expected Bar 'foo', got '%s'", resp.Bar)
+	}
+	failingInit := func(context.Context) (*NoDeps, error) { return nil, errors.New("no deps") }
+	_, err = Unary(failingInit, handler)(ctx, FooRequest{Foo: "foo"})
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("Expected code %s, got %s", codes.Internal, code)
+	}
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	handler := func(ctx context.Context, req FooRequest, deps *NoDeps, w io.Writer) error {
+		_, err := io.WriteString(w, req.Foo)
+		return err
+	}
+	var buf bytes.Buffer
+	if err := Stream(NoDepsInit, handler)(ctx, FooRequest{Foo: "foo"}, &buf); err != nil {
+		t.Fatalf("Stream returned an error: %v", err)
+	}
+	if buf.String() != "foo" {
+		t.Errorf("Expected output 'foo', got '%s'", buf.String())
+	}
+	failingInit := func(context.Context) (*NoDeps, error) { return nil, errors.New("no deps") }
+	err := Stream(failingInit, handler)(ctx, FooRequest{Foo: "foo"}, &buf)
+	if code := status.Code(err); code != codes.Internal {
+		t.Errorf("Expected code %s, got %s", codes.Internal, code)
+	}
+}
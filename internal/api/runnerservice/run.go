@@ -0,0 +1,57 @@
+package runnerservice
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+type RunDeps struct {
+	// WorkDir is the directory in which build workspaces are created.
+	WorkDir string
+	// Shell is the bash executable with which build scripts are run.
+	Shell string
+	// Name identifies this runner in build results.
+	Name string
+}
+
+// Run executes the build script in a fresh workspace and returns the artifact it produced.
+//
+// Build failures are reported in the response alongside the logs so the
+// caller can record them.
+func Run(ctx context.Context, req schema.PlatformRunRequest, deps *RunDeps) (*schema.PlatformRunResponse, error) {
+	dir, err := os.MkdirTemp(deps.WorkDir, "rebuild-")
+	if err != nil {
+		return nil, api.AsStatus(codes.Internal, errors.Wrap(err, "creating workspace"))
+	}
+	defer os.RemoveAll(dir)
+	if req.Resources != nil && req.Resources.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Resources.Timeout)
+		defer cancel()
+	}
+	output := new(bytes.Buffer)
+	cmd := exec.CommandContext(ctx, deps.Shell, "-c", req.Script)
+	cmd.Dir = dir
+	cmd.Stdout = output
+	cmd.Stderr = output
+	resp := &schema.PlatformRunResponse{Runner: deps.Name}
+	err = cmd.Run()
+	resp.Logs = output.String()
+	if err != nil {
+		resp.Error = errors.Wrap(err, "executing build").Error()
+		return resp, nil
+	}
+	resp.Artifact, err = os.ReadFile(filepath.Join(dir, "out", req.Target.Artifact))
+	if err != nil {
+		resp.Error = errors.Wrap(err, "reading artifact").Error()
+	}
+	return resp, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package badge renders status badges as SVG images.
+package badge
+
+import (
+	"bytes"
+	"html/template"
+)
+
+// Colors used to render badges.
+const (
+	Green = "#4c1"
+	Red   = "#e05d44"
+	Grey  = "#9f9f9f"
+)
+
+// charWidth approximates the width in pixels of a character of 11px Verdana.
+const charWidth = 7
+
+// padding is the horizontal space in pixels surrounding the text of each half of the badge.
+const padding = 10
+
+var badgeTpl = template.Must(template.New("badge").Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Message}}">` +
+		`<title>{{.Label}}: {{.Message}}</title>` +
+		`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` +
+		`<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>` +
+		`<g clip-path="url(#r)">` +
+		`<rect width="{{.LabelWidth}}" height="20" fill="#555"/>` +
+		`<rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{.Color}}"/>` +
+		`<rect width="{{.Width}}" height="20" fill="url(#s)"/>` +
+		`</g>` +
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` +
+		`<text x="{{.LabelX}}" y="14">{{.Label}}</text>` +
+		`<text x="{{.MessageX}}" y="14">{{.Message}}</text>` +
+		`</g>` +
+		`</svg>`))
+
+// Render returns an SVG badge displaying the label and message with the message on a background of color.
+func Render(label, message, color string) ([]byte, error) {
+	lw := len(label)*charWidth + padding
+	mw := len(message)*charWidth + padding
+	var buf bytes.Buffer
+	err := badgeTpl.Execute(&buf, map[string]any{
+		"Label":        label,
+		"Message":      message,
+		"Color":        color,
+		"Width":        lw + mw,
+		"LabelWidth":   lw,
+		"MessageWidth": mw,
+		"LabelX":       lw / 2,
+		"MessageX":     lw + mw/2,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
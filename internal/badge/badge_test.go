@@ -0,0 +1,61 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package badge
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	b, err := Render("oss-rebuild", "reproducible", Green)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	var svg struct {
+		XMLName xml.Name `xml:"svg"`
+		Width   int      `xml:"width,attr"`
+		Title   string   `xml:"title"`
+	}
+	if err := xml.Unmarshal(b, &svg); err != nil {
+		t.Fatalf("Render() produced invalid XML: %v", err)
+	}
+	if want := (11*charWidth + padding) + (12*charWidth + padding); svg.Width != want {
+		t.Errorf("width = %d, want %d", svg.Width, want)
+	}
+	if want := "oss-rebuild: reproducible"; svg.Title != want {
+		t.Errorf("title = %q, want %q", svg.Title, want)
+	}
+	if !strings.Contains(string(b), `fill="#4c1"`) {
+		t.Errorf("badge missing message color")
+	}
+}
+
+func TestRenderEscapes(t *testing.T) {
+	b, err := Render("a<b", "c&d", Red)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	var svg struct {
+		Title string `xml:"title"`
+	}
+	if err := xml.Unmarshal(b, &svg); err != nil {
+		t.Fatalf("Render() produced invalid XML: %v", err)
+	}
+	if want := "a<b: c&d"; svg.Title != want {
+		t.Errorf("title = %q, want %q", svg.Title, want)
+	}
+}
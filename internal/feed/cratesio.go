@@ -0,0 +1,101 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+var cratesIndexCommitsURL, _ = url.Parse("https://api.github.com/repos/rust-lang/crates.io-index/commits")
+
+const cratesIndexPageSize = 100
+
+// cratesIndexCommit matches the messages of index commits that publish a crate version.
+var cratesIndexCommit = regexp.MustCompile("^(?:Create|Update) crate `([^#`]+)#([^`]+)`")
+
+// CratesIOFeed reports crates.io releases using the commits to the crates.io index repository.
+type CratesIOFeed struct {
+	Client httpx.BasicClient
+}
+
+var _ Feed = &CratesIOFeed{}
+
+// Ecosystem returns the crates.io ecosystem.
+func (f *CratesIOFeed) Ecosystem() rebuild.Ecosystem {
+	return rebuild.CratesIO
+}
+
+type githubCommit struct {
+	Commit struct {
+		Message   string `json:"message"`
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+// Poll returns the releases of tracked packages published after since.
+func (f *CratesIOFeed) Poll(ctx context.Context, since time.Time, tracked Tracked) ([]Release, error) {
+	var releases []Release
+	for page := 1; ; page++ {
+		u := *cratesIndexCommitsURL
+		u.RawQuery = url.Values{
+			"since":    {since.UTC().Format(time.RFC3339)},
+			"per_page": {strconv.Itoa(cratesIndexPageSize)},
+			"page":     {strconv.Itoa(page)},
+		}.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := f.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, errors.Errorf("crates.io index error: %v", resp.Status)
+		}
+		var commits []githubCommit
+		err = json.NewDecoder(resp.Body).Decode(&commits)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding index commits")
+		}
+		for _, c := range commits {
+			m := cratesIndexCommit.FindStringSubmatch(c.Commit.Message)
+			if m == nil || !tracked.Contains(rebuild.CratesIO, m[1]) {
+				continue
+			}
+			if published := c.Commit.Committer.Date; published.After(since) {
+				releases = append(releases, Release{Ecosystem: rebuild.CratesIO, Package: m[1], Version: m[2], Published: published})
+			}
+		}
+		if len(commits) < cratesIndexPageSize {
+			break
+		}
+	}
+	return releases, nil
+}
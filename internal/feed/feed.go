@@ -0,0 +1,93 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package feed watches package registries for newly published releases.
+package feed
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/pkgname"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// Release is a package version published to a registry.
+type Release struct {
+	Ecosystem rebuild.Ecosystem
+	Package   string
+	Version   string
+	Published time.Time
+}
+
+// Feed is a source of releases published to a registry.
+type Feed interface {
+	// Ecosystem returns the ecosystem whose releases are reported by the feed.
+	Ecosystem() rebuild.Ecosystem
+	// Poll returns the releases of tracked packages published after since.
+	Poll(ctx context.Context, since time.Time, tracked Tracked) ([]Release, error)
+}
+
+// Tracked is the set of packages whose new releases are to be rebuilt.
+type Tracked map[rebuild.Ecosystem]map[string]bool
+
+// normalize returns the canonical form of the package name, used as the key
+// of tracked packages, or the name unchanged if it is invalid.
+func normalize(ecosystem rebuild.Ecosystem, pkg string) string {
+	if name, err := pkgname.Normalize(ecosystem, pkg); err == nil {
+		return name
+	}
+	return pkg
+}
+
+// Contains returns whether the package is tracked.
+func (t Tracked) Contains(ecosystem rebuild.Ecosystem, pkg string) bool {
+	return t[ecosystem][normalize(ecosystem, pkg)]
+}
+
+// Add tracks the package.
+func (t Tracked) Add(ecosystem rebuild.Ecosystem, pkg string) {
+	if t[ecosystem] == nil {
+		t[ecosystem] = make(map[string]bool)
+	}
+	t[ecosystem][normalize(ecosystem, pkg)] = true
+}
+
+// ReadTracked parses a list of tracked packages.
+//
+// Each line is of the form "<ecosystem> <package>". Blank lines and lines
+// beginning with "#" are ignored.
+func ReadTracked(r io.Reader) (Tracked, error) {
+	t := make(Tracked)
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("malformed line %d: %q", lineno, line)
+		}
+		t.Add(rebuild.Ecosystem(fields[0]), fields[1])
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading tracked packages")
+	}
+	return t, nil
+}
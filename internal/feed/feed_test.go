@@ -0,0 +1,222 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func response(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestReadTracked(t *testing.T) {
+	got, err := ReadTracked(strings.NewReader("# comment\nnpm lodash\n\npypi requests\nnpm @scope/pkg\n"))
+	if err != nil {
+		t.Fatalf("ReadTracked() error: %v", err)
+	}
+	want := Tracked{
+		rebuild.NPM:  {"lodash": true, "@scope/pkg": true},
+		rebuild.PyPI: {"requests": true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadTracked() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := ReadTracked(strings.NewReader("npm")); err == nil {
+		t.Error("ReadTracked() expected error for malformed line")
+	}
+}
+
+func TestPyPIFeed(t *testing.T) {
+	client := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://pypi.org/rss/updates.xml",
+				Response: response(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<item><title>requests 2.32.0</title><link>https://pypi.org/project/requests/2.32.0/</link><pubDate>Mon, 20 May 2024 12:00:00 GMT</pubDate></item>
+<item><title>other 1.0</title><link>https://pypi.org/project/other/1.0/</link><pubDate>Mon, 20 May 2024 12:00:00 GMT</pubDate></item>
+<item><title>requests 2.31.0</title><link>https://pypi.org/project/requests/2.31.0/</link><pubDate>Mon, 20 May 2024 08:00:00 GMT</pubDate></item>
+</channel></rss>`),
+			},
+		},
+	}
+	tracked := Tracked{rebuild.PyPI: {"requests": true}}
+	since := time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC)
+	got, err := (&PyPIFeed{Client: client}).Poll(context.Background(), since, tracked)
+	if err != nil {
+		t.Fatalf("Poll() error: %v", err)
+	}
+	want := []Release{
+		{Ecosystem: rebuild.PyPI, Package: "requests", Version: "2.32.0", Published: time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("Poll() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPyPIFeedDisplayName(t *testing.T) {
+	client := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://pypi.org/rss/updates.xml",
+				Response: response(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<item><title>Zope.Interface 7.0</title><link>https://pypi.org/project/Zope.Interface/7.0/</link><pubDate>Mon, 20 May 2024 12:00:00 GMT</pubDate></item>
+<item><title>other 1.0</title><link>https://pypi.org/project/other/1.0/</link><pubDate>Mon, 20 May 2024 08:00:00 GMT</pubDate></item>
+</channel></rss>`),
+			},
+		},
+	}
+	tracked := make(Tracked)
+	tracked.Add(rebuild.PyPI, "zope_interface")
+	since := time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC)
+	got, err := (&PyPIFeed{Client: client}).Poll(context.Background(), since, tracked)
+	if err != nil {
+		t.Fatalf("Poll() error: %v", err)
+	}
+	want := []Release{
+		{Ecosystem: rebuild.PyPI, Package: "zope-interface", Version: "7.0", Published: time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("Poll() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPyPIFeedGap(t *testing.T) {
+	client := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				// The oldest update is newer than since so earlier releases may be missing.
+				URL: "https://pypi.org/rss/updates.xml",
+				Response: response(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<item><title>requests 2.32.0</title><link>https://pypi.org/project/requests/2.32.0/</link><pubDate>Mon, 20 May 2024 12:00:00 GMT</pubDate></item>
+</channel></rss>`),
+			},
+			{
+				URL: "https://pypi.org/rss/project/requests/releases.xml",
+				Response: response(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<item><title>2.32.0</title><link>https://pypi.org/project/requests/2.32.0/</link><pubDate>Mon, 20 May 2024 12:00:00 GMT</pubDate></item>
+<item><title>2.31.1</title><link>https://pypi.org/project/requests/2.31.1/</link><pubDate>Mon, 20 May 2024 11:00:00 GMT</pubDate></item>
+<item><title>2.31.0</title><link>https://pypi.org/project/requests/2.31.0/</link><pubDate>Mon, 20 May 2024 08:00:00 GMT</pubDate></item>
+</channel></rss>`),
+			},
+		},
+	}
+	tracked := Tracked{rebuild.PyPI: {"requests": true}}
+	since := time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC)
+	got, err := (&PyPIFeed{Client: client}).Poll(context.Background(), since, tracked)
+	if err != nil {
+		t.Fatalf("Poll() error: %v", err)
+	}
+	want := []Release{
+		{Ecosystem: rebuild.PyPI, Package: "requests", Version: "2.32.0", Published: time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)},
+		{Ecosystem: rebuild.PyPI, Package: "requests", Version: "2.31.1", Published: time.Date(2024, 5, 20, 11, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("Poll() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCratesIOFeed(t *testing.T) {
+	client := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://api.github.com/repos/rust-lang/crates.io-index/commits?page=1&per_page=100&since=2024-05-20T10%3A00%3A00Z",
+				Response: response(`[
+{"commit":{"message":"Update crate ` + "`serde#1.0.203`" + `","committer":{"date":"2024-05-20T12:00:00Z"}}},
+{"commit":{"message":"Yank crate ` + "`serde#1.0.202`" + `","committer":{"date":"2024-05-20T11:00:00Z"}}},
+{"commit":{"message":"Create crate ` + "`untracked#0.1.0`" + `","committer":{"date":"2024-05-20T11:00:00Z"}}}
+]`),
+			},
+		},
+	}
+	tracked := Tracked{rebuild.CratesIO: {"serde": true}}
+	since := time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC)
+	got, err := (&CratesIOFeed{Client: client}).Poll(context.Background(), since, tracked)
+	if err != nil {
+		t.Fatalf("Poll() error: %v", err)
+	}
+	want := []Release{
+		{Ecosystem: rebuild.CratesIO, Package: "serde", Version: "1.0.203", Published: time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Poll() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+type fakeFeed struct {
+	releases []Release
+}
+
+func (f *fakeFeed) Ecosystem() rebuild.Ecosystem { return rebuild.NPM }
+
+func (f *fakeFeed) Poll(ctx context.Context, since time.Time, tracked Tracked) ([]Release, error) {
+	return f.releases, nil
+}
+
+func TestWatcherPoll(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm(): %v", err)
+		}
+		mu.Lock()
+		got = append(got, r.URL.Path+" "+r.Form.Get("package")+"@"+r.Form.Get("version"))
+		mu.Unlock()
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	q := taskqueue.NewLocalQueue(ctx, server.Client(), 1)
+	api, _ := url.Parse(server.URL)
+	feed := &fakeFeed{releases: []Release{
+		{Ecosystem: rebuild.NPM, Package: "lodash", Version: "4.17.22"},
+		{Ecosystem: rebuild.NPM, Package: "@scope/pkg", Version: "1.0.0"},
+	}}
+	w := &Watcher{Feeds: []Feed{feed}, Queue: q, API: api, ID: "watch"}
+	for i := 0; i < 2; i++ {
+		// Releases reported again by a subsequent poll are not re-enqueued.
+		if _, err := w.Poll(ctx, time.Time{}); err != nil {
+			t.Fatalf("Poll() error: %v", err)
+		}
+	}
+	q.Close()
+	sort.Strings(got)
+	want := []string{"/rebuild @scope/pkg@1.0.0", "/rebuild lodash@4.17.22"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("delivered requests mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	"github.com/pkg/errors"
+)
+
+var npmChangesURL, _ = url.Parse("https://replicate.npmjs.com/_changes")
+
+const npmChangesLimit = 1000
+
+// NPMFeed reports npm releases using the registry's replication changes feed.
+type NPMFeed struct {
+	Client   httpx.BasicClient
+	Registry npmreg.Registry
+	// seq is the position in the changes feed from which to resume.
+	seq json.RawMessage
+}
+
+var _ Feed = &NPMFeed{}
+
+// Ecosystem returns the npm ecosystem.
+func (f *NPMFeed) Ecosystem() rebuild.Ecosystem {
+	return rebuild.NPM
+}
+
+type npmChanges struct {
+	Results []struct {
+		Seq json.RawMessage `json:"seq"`
+		ID  string          `json:"id"`
+	} `json:"results"`
+	LastSeq json.RawMessage `json:"last_seq"`
+}
+
+func (f *NPMFeed) changes(ctx context.Context) (*npmChanges, error) {
+	u := *npmChangesURL
+	since := "now"
+	if f.seq != nil {
+		// Sequence identifiers may be numbers or opaque strings.
+		var s string
+		if err := json.Unmarshal(f.seq, &s); err != nil {
+			s = string(f.seq)
+		}
+		since = s
+	}
+	u.RawQuery = url.Values{"since": {since}, "limit": {strconv.Itoa(npmChangesLimit)}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("npm changes error: %v", resp.Status)
+	}
+	var c npmChanges
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return nil, errors.Wrap(err, "decoding npm changes")
+	}
+	return &c, nil
+}
+
+// Poll returns the releases of tracked packages published after since.
+//
+// The first call establishes the current position in the changes feed and
+// only subsequent changes are considered.
+func (f *NPMFeed) Poll(ctx context.Context, since time.Time, tracked Tracked) ([]Release, error) {
+	changed := make(map[string]bool)
+	for {
+		c, err := f.changes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range c.Results {
+			if tracked.Contains(rebuild.NPM, r.ID) {
+				changed[r.ID] = true
+			}
+		}
+		f.seq = c.LastSeq
+		if len(c.Results) < npmChangesLimit {
+			break
+		}
+	}
+	var releases []Release
+	for pkg := range changed {
+		p, err := f.Registry.Package(ctx, pkg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetching %s", pkg)
+		}
+		for version := range p.Versions {
+			published, ok := p.UploadTimes[version]
+			if ok && published.After(since) {
+				releases = append(releases, Release{Ecosystem: rebuild.NPM, Package: pkg, Version: version, Published: published})
+			}
+		}
+	}
+	return releases, nil
+}
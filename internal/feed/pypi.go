@@ -0,0 +1,149 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/pkgname"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+var pypiUpdatesURL, _ = url.Parse("https://pypi.org/rss/updates.xml")
+
+// pypiProjectReleasesURL returns the URL of the RSS feed of a project's releases.
+func pypiProjectReleasesURL(project string) string {
+	return "https://pypi.org/rss/project/" + url.PathEscape(project) + "/releases.xml"
+}
+
+// PyPIFeed reports PyPI releases using the registry's RSS feed of recent updates.
+//
+// The feed contains only the most recent releases. If its oldest release was
+// published after the previous poll, releases may have fallen off of it
+// between polls so the per-project feed of each tracked package is read
+// instead.
+type PyPIFeed struct {
+	Client httpx.BasicClient
+}
+
+var _ Feed = &PyPIFeed{}
+
+// Ecosystem returns the PyPI ecosystem.
+func (f *PyPIFeed) Ecosystem() rebuild.Ecosystem {
+	return rebuild.PyPI
+}
+
+type rss struct {
+	Items []struct {
+		Link    string `xml:"link"`
+		PubDate string `xml:"pubDate"`
+	} `xml:"channel>item"`
+}
+
+func (f *PyPIFeed) fetch(ctx context.Context, u string) (*rss, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("pypi feed %s error: %v", u, resp.Status)
+	}
+	var feed rss
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", u)
+	}
+	return &feed, nil
+}
+
+// releases returns the releases of tracked packages in the feed published
+// after since, along with the publish time of the oldest item in the feed.
+func (feed *rss) releases(since time.Time, tracked Tracked) ([]Release, time.Time, error) {
+	var releases []Release
+	var oldest time.Time
+	for _, item := range feed.Items {
+		published, err := time.Parse(time.RFC1123, item.PubDate)
+		if err != nil {
+			return nil, time.Time{}, errors.Wrapf(err, "parsing publish time %q", item.PubDate)
+		}
+		if oldest.IsZero() || published.Before(oldest) {
+			oldest = published
+		}
+		if !published.After(since) {
+			continue
+		}
+		// Links are of the form https://pypi.org/project/<name>/<version>/
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(item.Link, "https://pypi.org/project/"), "/"), "/")
+		if len(parts) != 2 {
+			return nil, time.Time{}, errors.Errorf("unexpected release link: %s", item.Link)
+		}
+		// NOTE: Links use the project's display name, which may differ from the tracked name.
+		name, err := pkgname.Normalize(rebuild.PyPI, parts[0])
+		if err != nil {
+			return nil, time.Time{}, errors.Wrapf(err, "release link %s", item.Link)
+		}
+		if tracked.Contains(rebuild.PyPI, name) {
+			releases = append(releases, Release{Ecosystem: rebuild.PyPI, Package: name, Version: parts[1], Published: published})
+		}
+	}
+	return releases, oldest, nil
+}
+
+// Poll returns the releases of tracked packages published after since.
+func (f *PyPIFeed) Poll(ctx context.Context, since time.Time, tracked Tracked) ([]Release, error) {
+	feed, err := f.fetch(ctx, pypiUpdatesURL.String())
+	if err != nil {
+		return nil, err
+	}
+	releases, oldest, err := feed.releases(since, tracked)
+	if err != nil {
+		return nil, err
+	}
+	if !oldest.IsZero() && !oldest.After(since) {
+		return releases, nil
+	}
+	log.Printf("pypi updates feed begins at %v, after %v; reading the feed of each tracked project", oldest, since)
+	var projects []string
+	for name := range tracked[rebuild.PyPI] {
+		projects = append(projects, name)
+	}
+	sort.Strings(projects)
+	releases = nil
+	for _, name := range projects {
+		feed, err := f.fetch(ctx, pypiProjectReleasesURL(name))
+		if err != nil {
+			return nil, err
+		}
+		rs, _, err := feed.releases(since, tracked)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, rs...)
+	}
+	return releases, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+)
+
+// Watcher polls Feeds and enqueues rebuilds of the new releases of tracked packages.
+type Watcher struct {
+	Feeds   []Feed
+	Tracked Tracked
+	Queue   taskqueue.Queue
+	// API is the URL of the rebuild API to which rebuild requests are sent.
+	API *url.URL
+	// ID is the run ID with which to associate the enqueued rebuilds.
+	ID string
+}
+
+var taskNameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// taskName returns a queue-safe name that identifies the rebuild of r.
+func taskName(r Release) string {
+	return taskNameDisallowed.ReplaceAllString(fmt.Sprintf("%s-%s-%s", r.Ecosystem, r.Package, r.Version), "_")
+}
+
+// Poll enqueues rebuilds for tracked releases published after since and
+// returns the releases that were enqueued.
+//
+// Errors from individual feeds are logged so that one unavailable registry
+// does not prevent the others from being processed.
+func (w *Watcher) Poll(ctx context.Context, since time.Time) ([]Release, error) {
+	var enqueued []Release
+	for _, f := range w.Feeds {
+		releases, err := f.Poll(ctx, since, w.Tracked)
+		if err != nil {
+			log.Printf("polling %s feed: %v", f.Ecosystem(), err)
+			continue
+		}
+		for _, r := range releases {
+			_, err := w.Queue.Add(ctx, w.API.JoinPath("rebuild").String(), schema.RebuildPackageRequest{
+				Ecosystem: r.Ecosystem,
+				Package:   r.Package,
+				Version:   r.Version,
+				ID:        w.ID,
+			}, taskqueue.TaskOptions{
+				Name:     taskName(r),
+				Priority: taskqueue.PriorityNewRelease,
+				Group:    string(r.Ecosystem),
+			})
+			if errors.Is(err, taskqueue.ErrTaskExists) {
+				continue
+			} else if err != nil {
+				return enqueued, errors.Wrapf(err, "enqueueing %s %s@%s", r.Ecosystem, r.Package, r.Version)
+			}
+			enqueued = append(enqueued, r)
+		}
+	}
+	return enqueued, nil
+}
+
+// Run polls the feeds every interval until ctx is cancelled.
+// Only releases published after Run is called are enqueued.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) error {
+	since := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		polled := time.Now()
+		enqueued, err := w.Poll(ctx, since)
+		if err != nil {
+			return err
+		}
+		for _, r := range enqueued {
+			log.Printf("Enqueued rebuild of %s %s@%s", r.Ecosystem, r.Package, r.Version)
+		}
+		since = polled
+	}
+}
@@ -53,6 +53,24 @@ func DoBuild(ctx context.Context, client Client, project string, build *cloudbui
 	if err != nil {
 		return nil, err
 	}
+	return WaitBuild(ctx, client, op)
+}
+
+// BuildID returns the ID of the build tracked by the operation.
+func BuildID(op *cloudbuild.Operation) (string, error) {
+	var bm cloudbuild.BuildOperationMetadata
+	if err := json.Unmarshal(op.Metadata, &bm); err != nil {
+		return "", errors.Wrap(err, "parsing operation metadata")
+	}
+	if bm.Build == nil || bm.Build.Id == "" {
+		return "", errors.New("no build in operation metadata")
+	}
+	return bm.Build.Id, nil
+}
+
+// WaitBuild waits for the build tracked by the operation to complete and returns the result.
+func WaitBuild(ctx context.Context, client Client, op *cloudbuild.Operation) (*cloudbuild.Build, error) {
+	var err error
 	for !op.Done {
 		time.Sleep(10 * time.Second)
 		op, err = client.GetOperation(ctx, op)
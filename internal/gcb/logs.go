@@ -0,0 +1,69 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcb
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+// LogObject returns the name of the object in the logs bucket to which the build's logs are written.
+func LogObject(buildID string) string {
+	return "log-" + buildID + ".txt"
+}
+
+// LogSource provides the portion of a build's log beginning at an offset.
+type LogSource interface {
+	// ReadFrom returns a reader of the log content beginning at offset.
+	// A log that has not yet been created should be read as empty.
+	ReadFrom(ctx context.Context, offset int64) (io.ReadCloser, error)
+}
+
+// FollowLogs copies the build's logs to w as they are written until the build completes.
+//
+// Cloud Build appends to the log object periodically, so the log is polled at
+// the provided interval. Any remaining content is copied once the build is done.
+func FollowLogs(ctx context.Context, client Client, op *cloudbuild.Operation, logs LogSource, w io.Writer, interval time.Duration) error {
+	var offset int64
+	for {
+		var err error
+		op, err = client.GetOperation(ctx, op)
+		if err != nil {
+			return errors.Wrap(err, "fetching operation")
+		}
+		r, err := logs.ReadFrom(ctx, offset)
+		if err != nil {
+			return errors.Wrap(err, "reading logs")
+		}
+		n, err := io.Copy(w, r)
+		r.Close()
+		offset += n
+		if err != nil {
+			return errors.Wrap(err, "copying logs")
+		}
+		if op.Done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
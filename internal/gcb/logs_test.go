@@ -0,0 +1,73 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcb_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/gcb"
+	"github.com/google/oss-rebuild/internal/gcb/gcbtest"
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+// growingLog is a LogSource whose content grows with each poll.
+type growingLog struct {
+	chunks []string
+	polls  int
+}
+
+func (l *growingLog) ReadFrom(ctx context.Context, offset int64) (io.ReadCloser, error) {
+	l.polls++
+	content := strings.Join(l.chunks[:min(l.polls, len(l.chunks))], "")
+	return io.NopCloser(strings.NewReader(content[offset:])), nil
+}
+
+func TestFollowLogs(t *testing.T) {
+	var polls int
+	client := &gcbtest.MockClient{
+		GetOperationFunc: func(ctx context.Context, op *cloudbuild.Operation) (*cloudbuild.Operation, error) {
+			polls++
+			return &cloudbuild.Operation{Name: op.Name, Done: polls >= 3}, nil
+		},
+	}
+	logs := &growingLog{chunks: []string{"step 1\n", "", "step 2\n", "done\n"}}
+	out := new(bytes.Buffer)
+	if err := gcb.FollowLogs(context.Background(), client, &cloudbuild.Operation{Name: "operations/build-id"}, logs, out, 0); err != nil {
+		t.Fatalf("FollowLogs() error: %v", err)
+	}
+	// NOTE: The final chunk is written after the build completes and is not followed.
+	if diff := cmp.Diff("step 1\nstep 2\n", out.String()); diff != "" {
+		t.Errorf("FollowLogs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildID(t *testing.T) {
+	op := &cloudbuild.Operation{Metadata: []byte(`{"build":{"id":"build-id"}}`)}
+	id, err := gcb.BuildID(op)
+	if err != nil {
+		t.Fatalf("BuildID() error: %v", err)
+	}
+	if id != "build-id" {
+		t.Errorf("BuildID() = %s, want build-id", id)
+	}
+	if _, err := gcb.BuildID(&cloudbuild.Operation{Metadata: []byte(`{}`)}); err == nil {
+		t.Error("BuildID() expected error for missing build")
+	}
+}
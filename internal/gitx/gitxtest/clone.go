@@ -0,0 +1,95 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitxtest
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	billy "github.com/go-git/go-billy/v5"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/google/oss-rebuild/internal/gitx"
+	"github.com/google/oss-rebuild/internal/uri"
+)
+
+// scheme is the transport protocol through which repositories are served in-process.
+const scheme = "gitxtest"
+
+var (
+	installOnce sync.Once
+	served      = &loader{repos: make(map[string]storer.Storer)}
+	servers     int
+)
+
+// loader resolves repositories registered by each CloneFunc.
+type loader struct {
+	mu    sync.Mutex
+	repos map[string]storer.Storer
+}
+
+func (l *loader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.repos[ep.Host+ep.Path]
+	if !ok {
+		return nil, transport.ErrRepositoryNotFound
+	}
+	return s, nil
+}
+
+// CloneFunc returns a gitx.CloneFunc that clones the provided repositories,
+// keyed by repo URL, without network access. Clones of any other URL fail
+// with transport.ErrRepositoryNotFound.
+//
+// Cloning uses the git protocol so, as with a remote, only objects reachable
+// from the repository's refs are transferred.
+func CloneFunc(repos map[string]*Repo) (gitx.CloneFunc, error) {
+	installOnce.Do(func() { client.InstallProtocol(scheme, server.NewClient(served)) })
+	served.mu.Lock()
+	servers++
+	host := "server" + strconv.Itoa(servers)
+	urls := make(map[string]string)
+	for u, r := range repos {
+		canon, err := uri.CanonicalizeRepoURI(u)
+		if err != nil {
+			served.mu.Unlock()
+			return nil, err
+		}
+		p := "/" + strings.TrimPrefix(canon, "https://")
+		served.repos[host+p] = r.Storer
+		urls[canon] = scheme + "://" + host + p
+	}
+	served.mu.Unlock()
+	return func(ctx context.Context, s storage.Storer, fs billy.Filesystem, opt *git.CloneOptions) (*git.Repository, error) {
+		canon, err := uri.CanonicalizeRepoURI(opt.URL)
+		if err != nil {
+			return nil, err
+		}
+		u, ok := urls[canon]
+		if !ok {
+			return nil, transport.ErrRepositoryNotFound
+		}
+		o := *opt
+		o.URL = u
+		return git.CloneContext(ctx, s, fs, &o)
+	}, nil
+}
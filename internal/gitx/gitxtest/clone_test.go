@@ -0,0 +1,71 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitxtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/pkg/errors"
+)
+
+func TestCloneFunc(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewRepo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit(map[string]string{"README.md": "v1"}, "first"); err != nil {
+		t.Fatal(err)
+	}
+	second, err := repo.Commit(map[string]string{"README.md": "v2", "src/lib.js": "x"}, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Tag("v2.0.0", second); err != nil {
+		t.Fatal(err)
+	}
+	clone, err := CloneFunc(map[string]*Repo{"git+https://github.com/Foo/bar.git": repo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := memfs.New()
+	cloned, err := clone(ctx, memory.NewStorage(), fs, &git.CloneOptions{URL: "https://github.com/foo/bar"})
+	if err != nil {
+		t.Fatalf("clone() error = %v", err)
+	}
+	head, err := cloned.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Hash() != second {
+		t.Errorf("HEAD = %s, want %s", head.Hash(), second)
+	}
+	if _, err := cloned.Tag("v2.0.0"); err != nil {
+		t.Errorf("Tag(v2.0.0) error = %v", err)
+	}
+	if b, err := util.ReadFile(fs, "src/lib.js"); err != nil || string(b) != "x" {
+		t.Errorf("ReadFile(src/lib.js) = %q, %v; want \"x\"", b, err)
+	}
+	_, err = clone(ctx, memory.NewStorage(), memfs.New(), &git.CloneOptions{URL: "https://github.com/foo/other"})
+	if !errors.Is(err, transport.ErrRepositoryNotFound) {
+		t.Errorf("clone(unknown) error = %v, want ErrRepositoryNotFound", err)
+	}
+}
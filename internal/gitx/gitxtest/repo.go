@@ -0,0 +1,73 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gitxtest provides in-memory git repositories for use in tests.
+package gitxtest
+
+import (
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Repo is an in-memory git repository whose history is built up by tests.
+type Repo struct {
+	*git.Repository
+	commits int
+}
+
+// NewRepo creates an empty repository.
+func NewRepo() (*Repo, error) {
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{Repository: r}, nil
+}
+
+// signature returns a deterministic signature for the next commit.
+func (r *Repo) signature() *object.Signature {
+	return &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(0, 0).UTC().Add(time.Duration(r.commits) * time.Hour)}
+}
+
+// Commit writes the provided files, keyed by path, to the worktree and
+// commits them along with the rest of the worktree.
+func (r *Repo) Commit(files map[string]string, msg string) (plumbing.Hash, error) {
+	w, err := r.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	for name, content := range files {
+		if err := util.WriteFile(w.Filesystem, name, []byte(content), 0644); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+	if err := w.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	sig := r.signature()
+	r.commits++
+	return w.Commit(msg, &git.CommitOptions{Author: sig, Committer: sig, AllowEmptyCommits: true})
+}
+
+// Tag creates an annotated tag of the provided commit.
+func (r *Repo) Tag(name string, h plumbing.Hash) error {
+	_, err := r.CreateTag(name, h, &git.CreateTagOptions{Tagger: r.signature(), Message: name})
+	return err
+}
@@ -45,6 +45,26 @@ func (c *WithUserAgent) Do(req *http.Request) (*http.Response, error) {
 	return c.BasicClient.Do(req)
 }
 
+// WithAuthorization is a basic HTTP client that adds an Authorization header to requests to Host.
+//
+// Requests to other hosts, such as those for artifacts served from a CDN, are
+// sent without credentials.
+type WithAuthorization struct {
+	BasicClient
+	Host          string
+	Authorization string
+}
+
+var _ BasicClient = &WithAuthorization{}
+
+// Do adds the Authorization header, if applicable, and sends the request.
+func (c *WithAuthorization) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == c.Host {
+		req.Header.Set("Authorization", c.Authorization)
+	}
+	return c.BasicClient.Do(req)
+}
+
 // CachedClient is a BasicClient that caches responses.
 type CachedClient struct {
 	BasicClient
@@ -1,35 +1,167 @@
 package httpxtest
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"net/http"
+	"regexp"
+	"strings"
+	"sync"
 )
 
+// Call describes an expected request and the response with which to fulfill it.
 type Call struct {
-	URL      string
+	URL string
+	// URLPattern, if provided, is matched against the request URL instead of URL.
+	URLPattern *regexp.Regexp
+	// Times is the number of requests this Call may fulfill.
+	// Zero is treated as exactly once and a negative value allows unlimited calls.
+	Times    int
 	Response *http.Response
 	Error    error
 }
 
+func (c Call) matches(url string) bool {
+	if c.URLPattern != nil {
+		return c.URLPattern.MatchString(url)
+	}
+	return c.URL == url
+}
+
+func (c Call) limit() int {
+	if c.Times == 0 {
+		return 1
+	}
+	return c.Times
+}
+
+func (c Call) String() string {
+	if c.URLPattern != nil {
+		return fmt.Sprintf("pattern %q", c.URLPattern.String())
+	}
+	return fmt.Sprintf("url %q", c.URL)
+}
+
+// MockClient is a httpx.BasicClient that fulfills requests from a set of expected Calls.
+//
+// By default, Calls are expected in the order provided and an unexpected
+// request causes a panic. Setting Unordered matches each request against the
+// first Call with remaining uses whose URL matches, regardless of order.
 type MockClient struct {
 	Calls        []Call
 	URLValidator func(expected, actual string)
-	callCount    int
+	// Unordered allows Calls to be fulfilled in any order.
+	Unordered bool
+	// ErrorOnUnexpected causes unexpected requests to return an error instead of panicking.
+	ErrorOnUnexpected bool
+	mu                sync.Mutex
+	callCount         int
+	uses              []int
+	bodies            map[int][]byte
+	unexpected        []string
 }
 
 func (m *MockClient) Do(req *http.Request) (*http.Response, error) {
-	if m.callCount >= len(m.Calls) {
-		panic("unexpected request")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.uses == nil {
+		m.uses = make([]int, len(m.Calls))
 	}
-	call := m.Calls[m.callCount]
+	actual := req.URL.String()
+	idx := m.next(actual)
+	if idx < 0 {
+		msg := fmt.Sprintf("unexpected request: %s %s", req.Method, actual)
+		if !m.ErrorOnUnexpected {
+			panic(msg)
+		}
+		m.unexpected = append(m.unexpected, msg)
+		return nil, fmt.Errorf("httpxtest: %s", msg)
+	}
+	call := m.Calls[idx]
+	m.uses[idx]++
 	m.callCount++
 
-	if m.URLValidator != nil {
-		m.URLValidator(call.URL, req.URL.String())
+	if m.URLValidator != nil && call.URLPattern == nil {
+		m.URLValidator(call.URL, actual)
+	}
+	resp, err := m.response(idx)
+	if err != nil {
+		return nil, err
+	}
+	return resp, call.Error
+}
+
+// next returns the index of the Call that should fulfill a request to url or -1 if none exists.
+func (m *MockClient) next(url string) int {
+	for i, call := range m.Calls {
+		if exhausted := call.limit() >= 0 && m.uses[i] >= call.limit(); exhausted {
+			continue
+		}
+		if !m.Unordered {
+			// NOTE: Ordered calls defer exact URL checking to the URLValidator, if provided.
+			if call.URLPattern != nil && !call.URLPattern.MatchString(url) {
+				return -1
+			}
+			return i
+		}
+		if call.matches(url) {
+			return i
+		}
 	}
+	return -1
+}
 
-	return call.Response, call.Error
+// response returns the Response for the Call at idx, buffering its body so it can be served repeatedly.
+func (m *MockClient) response(idx int) (*http.Response, error) {
+	call := m.Calls[idx]
+	if call.Response == nil || call.Response.Body == nil || call.limit() == 1 {
+		return call.Response, nil
+	}
+	if m.bodies == nil {
+		m.bodies = make(map[int][]byte)
+	}
+	body, ok := m.bodies[idx]
+	if !ok {
+		var err error
+		body, err = io.ReadAll(call.Response.Body)
+		if err != nil {
+			return nil, err
+		}
+		m.bodies[idx] = body
+	}
+	resp := *call.Response
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return &resp, nil
 }
 
 func (m *MockClient) CallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.callCount
 }
+
+// Verify returns an error describing any unexpected requests and any Calls
+// that were not fulfilled the expected number of times.
+func (m *MockClient) Verify() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var problems []string
+	problems = append(problems, m.unexpected...)
+	for i, call := range m.Calls {
+		var used int
+		if m.uses != nil {
+			used = m.uses[i]
+		}
+		switch limit := call.limit(); {
+		case limit < 0 && used == 0:
+			problems = append(problems, fmt.Sprintf("unmet expectation %d (%s): never called", i, call))
+		case limit > 0 && used < limit:
+			problems = append(problems, fmt.Sprintf("unmet expectation %d (%s): called %d of %d times", i, call, used, limit))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("httpxtest: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpxtest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func get(t *testing.T, c *MockClient, url string) (string, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), nil
+}
+
+func body(s string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader([]byte(s)))}
+}
+
+func TestMockClient(t *testing.T) {
+	t.Run("Ordered", func(t *testing.T) {
+		c := &MockClient{Calls: []Call{
+			{URL: "https://example.com/a", Response: body("a")},
+			{URL: "https://example.com/b", Response: body("b")},
+		}}
+		for _, want := range []string{"a", "b"} {
+			got, err := get(t, c, "https://example.com/"+want)
+			if err != nil || got != want {
+				t.Errorf("Do() = %q, %v; want %q", got, err, want)
+			}
+		}
+		if err := c.Verify(); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+	})
+	t.Run("UnorderedRepeated", func(t *testing.T) {
+		c := &MockClient{
+			Unordered: true,
+			Calls: []Call{
+				{URL: "https://example.com/a", Response: body("a")},
+				{URLPattern: regexp.MustCompile(`/b/\d+$`), Times: -1, Response: body("b")},
+			},
+		}
+		want := map[string]string{
+			"https://example.com/b/1": "b",
+			"https://example.com/b/2": "b",
+			"https://example.com/a":   "a",
+		}
+		var wg sync.WaitGroup
+		for url := range want {
+			wg.Add(1)
+			go func(url string) {
+				defer wg.Done()
+				got, err := get(t, c, url)
+				if err != nil || got != want[url] {
+					t.Errorf("Do(%s) = %q, %v; want %q", url, got, err, want[url])
+				}
+			}(url)
+		}
+		wg.Wait()
+		if c.CallCount() != 3 {
+			t.Errorf("CallCount() = %d, want 3", c.CallCount())
+		}
+		if err := c.Verify(); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+	})
+	t.Run("ErrorOnUnexpected", func(t *testing.T) {
+		c := &MockClient{
+			Unordered:         true,
+			ErrorOnUnexpected: true,
+			Calls: []Call{
+				{URL: "https://example.com/a", Times: 2, Response: body("a")},
+			},
+		}
+		if _, err := get(t, c, "https://example.com/a"); err != nil {
+			t.Fatalf("Do() error: %v", err)
+		}
+		if _, err := get(t, c, "https://example.com/c"); err == nil {
+			t.Error("Do() expected error for unexpected request")
+		}
+		err := c.Verify()
+		if err == nil {
+			t.Fatal("Verify() expected error")
+		}
+		for _, want := range []string{"unexpected request: GET https://example.com/c", `(url "https://example.com/a"): called 1 of 2 times`} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Verify() = %v, want containing %q", err, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integrationtest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	billy "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+const npmRegistry = "https://registry.npmjs.org"
+
+// NPMRelease describes an npm package version to be served by a Registry.
+type NPMRelease struct {
+	Name    string
+	Version string
+	// Repo is the repository URL recorded in the version's metadata.
+	Repo string
+	// GitHEAD is the commit recorded in the version's metadata, if any.
+	GitHEAD string
+	// Tarball is the published artifact.
+	Tarball   []byte
+	Published time.Time
+}
+
+type npmPackage struct {
+	versions map[string]map[string]any
+	times    map[string]time.Time
+	latest   string
+}
+
+// PublishNPM serves the release's version metadata, package metadata, and
+// tarball. Publishing further versions of the same package adds them to its
+// package metadata.
+func (r *Registry) PublishNPM(rel NPMRelease) error {
+	sha1sum := sha1.Sum(rel.Tarball)
+	sha512sum := sha512.Sum512(rel.Tarball)
+	tarball := npmRegistry + "/" + rel.Name + "/-/" + path.Base(rel.Name) + "-" + rel.Version + ".tgz"
+	version := map[string]any{
+		"name":         rel.Name,
+		"version":      rel.Version,
+		"gitHead":      rel.GitHEAD,
+		"_npmVersion":  "9.8.1",
+		"_nodeVersion": "20.9.0",
+		"repository":   map[string]string{"type": "git", "url": rel.Repo},
+		"dist": map[string]string{
+			"tarball":   tarball,
+			"shasum":    hex.EncodeToString(sha1sum[:]),
+			"integrity": "sha512-" + base64.StdEncoding.EncodeToString(sha512sum[:]),
+		},
+	}
+	r.mu.Lock()
+	p, ok := r.npm[rel.Name]
+	if !ok {
+		p = &npmPackage{versions: make(map[string]map[string]any), times: make(map[string]time.Time)}
+		r.npm[rel.Name] = p
+	}
+	p.versions[rel.Version] = version
+	p.times[rel.Version] = rel.Published
+	p.latest = rel.Version
+	pkg := map[string]any{
+		"name":      rel.Name,
+		"dist-tags": map[string]string{"latest": p.latest},
+		"versions":  p.versions,
+		"time":      p.times,
+	}
+	r.mu.Unlock()
+	if err := r.Serve(tarball, rel.Tarball); err != nil {
+		return err
+	}
+	if err := r.ServeJSON(npmRegistry+"/"+rel.Name+"/"+rel.Version, version); err != nil {
+		return err
+	}
+	return r.ServeJSON(npmRegistry+"/"+rel.Name, pkg)
+}
+
+// NPMTarball returns a tarball of the provided files, keyed by path, laid
+// out as produced by "npm pack".
+func NPMTarball(files map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		body := files[name]
+		h := &tar.Header{Name: path.Join("package", name), Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(body))}
+		if err := tw.WriteHeader(h); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NPMPack is a BuildFunc that packs the files of the build directory in
+// place of running "npm pack".
+//
+// NOTE: The worktree contains no git metadata since repositories are cloned
+// into separate storage.
+func NPMPack(ctx context.Context, t rebuild.Target, inst rebuild.Instructions, fs billy.Filesystem) error {
+	dir := inst.Location.Dir
+	if dir == "" {
+		dir = "."
+	}
+	files := make(map[string]string)
+	err := util.Walk(fs, dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		b, err := util.ReadFile(fs, p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "reading build directory")
+	}
+	tgz, err := NPMTarball(files)
+	if err != nil {
+		return errors.Wrap(err, "packing")
+	}
+	return util.WriteFile(fs, inst.OutputPath, tgz, 0644)
+}
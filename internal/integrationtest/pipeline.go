@@ -0,0 +1,288 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package integrationtest runs the rebuild pipeline in-process against fake
+// registries and source repositories.
+//
+// The pipeline runs inference, the build, stabilization, comparison, and
+// attestation using production code. Only the build's execution is replaced
+// since it would otherwise run the ecosystem's toolchain.
+package integrationtest
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+	"time"
+
+	billy "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/google/oss-rebuild/internal/gitx"
+	"github.com/google/oss-rebuild/internal/gitx/gitxtest"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// BuildFunc produces the artifact described by inst within fs, which
+// contains the source checked out at inst.Location.Ref.
+type BuildFunc func(ctx context.Context, t rebuild.Target, inst rebuild.Instructions, fs billy.Filesystem) error
+
+// Env is an in-process deployment of the rebuild pipeline.
+type Env struct {
+	Registry *Registry
+	// Repos are the source repositories available to clone, keyed by URL.
+	Repos map[string]*gitxtest.Repo
+	// Metadata holds the build metadata and rebuilt artifacts.
+	Metadata rebuild.AssetStore
+	// Debug holds the canonicalized artifacts used for comparison.
+	Debug rebuild.AssetStore
+	// Attestations holds the published attestation bundles.
+	Attestations rebuild.AssetStore
+	Signer       *verifier.InTotoEnvelopeSigner
+}
+
+// NewEnv creates an Env whose resources are released when the test completes.
+func NewEnv(t testing.TB) *Env {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := dsse.NewEnvelopeSigner(&ecdsaSigner{key: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Env{
+		Registry:     NewRegistry(),
+		Repos:        make(map[string]*gitxtest.Repo),
+		Metadata:     rebuild.NewFilesystemAssetStore(memfs.New()),
+		Debug:        rebuild.NewFilesystemAssetStore(memfs.New()),
+		Attestations: rebuild.NewFilesystemAssetStore(memfs.New()),
+		Signer:       &verifier.InTotoEnvelopeSigner{EnvelopeSigner: signer},
+	}
+	t.Cleanup(e.Registry.Close)
+	return e
+}
+
+// AddRepo creates an empty source repository available to clone from url.
+func (e *Env) AddRepo(url string) (*gitxtest.Repo, error) {
+	r, err := gitxtest.NewRepo()
+	if err != nil {
+		return nil, err
+	}
+	e.Repos[url] = r
+	return r, nil
+}
+
+// Result is the outcome of a pipeline run.
+type Result struct {
+	Verdict *rebuild.Verdict
+	// Rebuild and Upstream summarize the compared artifacts. They are absent
+	// if the rebuild did not match.
+	Rebuild, Upstream *verifier.ArtifactSummary
+	// Bundle is the published attestation bundle, if the rebuild was attested.
+	Bundle *verifier.Bundle
+}
+
+// Run rebuilds the input using r for all but the build's execution, which
+// is performed by build, and attests the result if it matches upstream.
+//
+// NOTE: Run replaces gitx.Clone for its duration so it must not be called
+// concurrently.
+func (e *Env) Run(ctx context.Context, r rebuild.Rebuilder, build BuildFunc, input rebuild.Input) (*Result, error) {
+	clone, err := gitxtest.CloneFunc(e.Repos)
+	if err != nil {
+		return nil, errors.Wrap(err, "serving repos")
+	}
+	defer func(orig gitx.CloneFunc) { gitx.Clone = orig }(gitx.Clone)
+	gitx.Clone = clone
+	ctx = context.WithValue(ctx, rebuild.HTTPBasicClientID, e.Registry.Client())
+	mux := e.Registry.Mux()
+	t := input.Target
+	rb := &pipelineRebuilder{Rebuilder: r, build: build}
+	fs := memfs.New()
+	start := time.Now().UTC()
+	verdict, _, err := rebuild.RebuildOne(ctx, rb, input, mux, &rebuild.RepoConfig{}, fs, memory.NewStorage(), e.Debug)
+	if err != nil {
+		return nil, errors.Wrap(err, "rebuilding")
+	}
+	res := &Result{Verdict: verdict}
+	if verdict.Message != "" {
+		return res, nil
+	}
+	if err := e.writeMetadata(ctx, t, rb.inst, fs, start); err != nil {
+		return nil, err
+	}
+	upstreamURI, err := upstreamURI(ctx, t, mux)
+	if err != nil {
+		return nil, err
+	}
+	rbSummary, upSummary, err := verifier.SummarizeArtifacts(ctx, e.Metadata, t, upstreamURI, []crypto.Hash{crypto.SHA256}, archive.Options{}, verifier.UpstreamOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "summarizing artifacts")
+	}
+	if !bytes.Equal(rbSummary.CanonicalHash.Sum(nil), upSummary.CanonicalHash.Sum(nil)) {
+		return res, nil
+	}
+	res.Rebuild, res.Upstream = &rbSummary, &upSummary
+	eqStmt, buildStmt, err := verifier.CreateAttestations(ctx, input, verdict.Strategy, "integration-test", rbSummary, upSummary, e.Metadata, rebuild.Location{})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating attestations")
+	}
+	a := verifier.Attestor{Store: e.Attestations, Signer: *e.Signer, Layout: verifier.LayoutV2}
+	if err := a.PublishBundle(ctx, t, eqStmt, buildStmt); err != nil {
+		return nil, errors.Wrap(err, "publishing bundle")
+	}
+	br, _, err := e.Attestations.Reader(ctx, rebuild.Asset{Target: t, Type: rebuild.AttestationBundleAsset})
+	if err != nil {
+		return nil, errors.Wrap(err, "opening bundle")
+	}
+	defer br.Close()
+	res.Bundle, err = verifier.ReadBundle(br)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// writeMetadata records the rebuilt artifact and the build metadata otherwise written by a remote build.
+func (e *Env) writeMetadata(ctx context.Context, t rebuild.Target, inst rebuild.Instructions, fs billy.Filesystem, start time.Time) error {
+	artifact, err := util.ReadFile(fs, inst.OutputPath)
+	if err != nil {
+		return errors.Wrap(err, "reading rebuilt artifact")
+	}
+	bi, err := json.Marshal(rebuild.BuildInfo{Target: t, ID: "integration-test", Builder: "integrationtest", BuildStart: start, BuildEnd: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	dockerfile := "# Built in-process from:\n# " + inst.Location.Repo + "@" + inst.Location.Ref + "\n"
+	for typ, content := range map[rebuild.AssetType][]byte{
+		rebuild.RebuildAsset:    artifact,
+		rebuild.BuildInfoAsset:  bi,
+		rebuild.DockerfileAsset: []byte(dockerfile),
+	} {
+		w, _, err := e.Metadata.Writer(ctx, rebuild.Asset{Target: t, Type: typ})
+		if err != nil {
+			return errors.Wrapf(err, "creating writer for %s", typ)
+		}
+		if _, err := w.Write(content); err != nil {
+			w.Close()
+			return errors.Wrapf(err, "writing %s", typ)
+		}
+		if err := w.Close(); err != nil {
+			return errors.Wrapf(err, "closing %s", typ)
+		}
+	}
+	return nil
+}
+
+// upstreamURI returns the registry URL of the target's artifact.
+func upstreamURI(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (string, error) {
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		v, err := mux.NPM.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return "", errors.Wrap(err, "fetching metadata")
+		}
+		return v.Dist.URL, nil
+	case rebuild.CratesIO:
+		v, err := mux.CratesIO.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return "", errors.Wrap(err, "fetching metadata")
+		}
+		return v.DownloadURL, nil
+	case rebuild.PyPI:
+		release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+		if err != nil {
+			return "", errors.Wrap(err, "fetching metadata")
+		}
+		for _, a := range release.Artifacts {
+			if a.Filename == t.Artifact {
+				return a.URL, nil
+			}
+		}
+		return "", errors.Errorf("artifact %s not found in release", t.Artifact)
+	default:
+		return "", errors.Errorf("unsupported ecosystem: %s", t.Ecosystem)
+	}
+}
+
+// pipelineRebuilder replaces the build's execution with a BuildFunc.
+type pipelineRebuilder struct {
+	rebuild.Rebuilder
+	build BuildFunc
+	repo  *git.Repository
+	inst  rebuild.Instructions
+}
+
+func (r *pipelineRebuilder) CloneRepo(ctx context.Context, t rebuild.Target, repoURI string, fs billy.Filesystem, s storage.Storer) (rebuild.RepoConfig, error) {
+	rcfg, err := r.Rebuilder.CloneRepo(ctx, t, repoURI, fs, s)
+	r.repo = rcfg.Repository
+	return rcfg, err
+}
+
+// Rebuild checks out the source, as the instructions' Source script would, and runs the build.
+func (r *pipelineRebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Instructions, fs billy.Filesystem) error {
+	r.inst = inst
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(inst.Location.Ref), Force: true}); err != nil {
+		return errors.Wrapf(err, "checking out %s", inst.Location.Ref)
+	}
+	return r.build(ctx, t, inst, fs)
+}
+
+// ecdsaSigner is an ephemeral signing key for attestations.
+type ecdsaSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+var _ dsse.SignerVerifier = &ecdsaSigner{}
+
+func (s *ecdsaSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, s.key, digest[:])
+}
+
+func (s *ecdsaSigner) Verify(ctx context.Context, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(&s.key.PublicKey, digest[:], sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func (s *ecdsaSigner) KeyID() (string, error) {
+	return "integrationtest", nil
+}
+
+func (s *ecdsaSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
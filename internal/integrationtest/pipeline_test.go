@@ -0,0 +1,131 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integrationtest
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/verifier"
+	npmrb "github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestNPM(t *testing.T) {
+	files := map[string]string{
+		"package.json": `{"name":"left-pad","version":"1.3.0"}`,
+		"index.js":     "module.exports = leftPad;\n",
+	}
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz"}
+	for _, tc := range []struct {
+		name string
+		// upstream are the files published in place of those in the repo, if provided.
+		upstream    map[string]string
+		wantMessage string
+	}{
+		{
+			name: "reproducible",
+		},
+		{
+			name:        "upstream only file",
+			upstream:    map[string]string{"dist/index.min.js": "leftPad"},
+			wantMessage: "found in upstream but not rebuild",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := NewEnv(t)
+			repo, err := env.AddRepo("https://github.com/left-pad/left-pad")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.Commit(map[string]string{"README.md": "left-pad"}, "initial"); err != nil {
+				t.Fatal(err)
+			}
+			head, err := repo.Commit(files, "release 1.3.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			published := maps.Clone(files)
+			maps.Copy(published, tc.upstream)
+			published["README.md"] = "left-pad"
+			tarball, err := NPMTarball(published)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = env.Registry.PublishNPM(NPMRelease{
+				Name:      target.Package,
+				Version:   target.Version,
+				Repo:      "git+https://github.com/left-pad/left-pad.git",
+				GitHEAD:   head.String(),
+				Tarball:   tarball,
+				Published: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := env.Run(ctx, npmrb.Rebuilder{}, NPMPack, rebuild.Input{Target: target})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if tc.wantMessage != "" {
+				if !strings.Contains(res.Verdict.Message, tc.wantMessage) {
+					t.Errorf("Verdict.Message = %q, want containing %q", res.Verdict.Message, tc.wantMessage)
+				}
+				if res.Bundle != nil {
+					t.Error("mismatched rebuild was attested")
+				}
+				return
+			}
+			if res.Verdict.Message != "" {
+				t.Fatalf("Verdict.Message = %q, want success", res.Verdict.Message)
+			}
+			if res.Bundle == nil {
+				t.Fatal("rebuild was not attested")
+			}
+			got, err := res.Bundle.Target()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(target, got); diff != "" {
+				t.Errorf("Bundle.Target() mismatch (-want +got):\n%s", diff)
+			}
+			att, err := res.Bundle.RebuildAttestation()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var source string
+			for _, rd := range att.Predicate.BuildDefinition.ResolvedDependencies {
+				if strings.HasPrefix(rd.Name, "git+") {
+					source = rd.Name + "@" + rd.Digest["sha1"]
+				}
+			}
+			if want := "git+https://github.com/left-pad/left-pad@" + head.String(); source != want {
+				t.Errorf("attested source = %q, want %q", source, want)
+			}
+			idx, err := verifier.ReadIndex(ctx, env.Attestations, target.Ecosystem, target.Package)
+			if err != nil {
+				t.Fatalf("ReadIndex() error = %v", err)
+			}
+			if diff := cmp.Diff([]string{target.Version}, idx.Versions()); diff != "" {
+				t.Errorf("Versions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
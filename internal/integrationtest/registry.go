@@ -0,0 +1,129 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integrationtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
+	mavenreg "github.com/google/oss-rebuild/pkg/registry/maven"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	"github.com/pkg/errors"
+)
+
+// Registry is a fake package registry serving fixtures over HTTP.
+//
+// Requests made through Client are redirected to the registry's server
+// regardless of their host so the production URLs of each registry client
+// are exercised. Requests for URLs without a fixture receive a 404.
+type Registry struct {
+	srv      *httptest.Server
+	mu       sync.Mutex
+	fixtures map[string][]byte
+	npm      map[string]*npmPackage
+}
+
+// NewRegistry starts a Registry. It must be closed when no longer in use.
+func NewRegistry() *Registry {
+	r := &Registry{fixtures: make(map[string][]byte), npm: make(map[string]*npmPackage)}
+	r.srv = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	return r
+}
+
+// Close shuts down the registry's server.
+func (r *Registry) Close() {
+	r.srv.Close()
+}
+
+// fixtureKey identifies the fixture for a URL, ignoring its scheme and query.
+func fixtureKey(u *url.URL) string {
+	return u.Host + u.Path
+}
+
+func (r *Registry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	body, ok := r.fixtures[strings.TrimPrefix(req.URL.Path, "/")]
+	r.mu.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	w.Write(body)
+}
+
+// Serve responds to requests for rawURL with body.
+func (r *Registry) Serve(rawURL string, body []byte) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrap(err, "parsing fixture URL")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixtures[fixtureKey(u)] = body
+	return nil
+}
+
+// ServeJSON responds to requests for rawURL with the JSON encoding of v.
+func (r *Registry) ServeJSON(rawURL string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "marshalling fixture")
+	}
+	return r.Serve(rawURL, b)
+}
+
+// Client returns a client whose requests are all served by the registry.
+func (r *Registry) Client() httpx.BasicClient {
+	return &redirectClient{base: r.srv.URL, client: r.srv.Client()}
+}
+
+// Mux returns the production registry clients configured to use the registry.
+func (r *Registry) Mux() rebuild.RegistryMux {
+	c := r.Client()
+	return rebuild.RegistryMux{
+		NPM:      npmreg.HTTPRegistry{Client: c},
+		PyPI:     pypireg.HTTPRegistry{Client: c},
+		CratesIO: cratesreg.HTTPRegistry{Client: c},
+		Maven:    mavenreg.HTTPRegistry{Client: c},
+	}
+}
+
+// redirectClient sends each request to a single server, prefixing its path with the original host.
+type redirectClient struct {
+	base   string
+	client *http.Client
+}
+
+func (c *redirectClient) Do(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(c.base)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/" + fixtureKey(req.URL)
+	u.RawQuery = req.URL.RawQuery
+	redirected := req.Clone(req.Context())
+	redirected.URL = u
+	redirected.Host = ""
+	redirected.RequestURI = ""
+	return c.client.Do(redirected)
+}
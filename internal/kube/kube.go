@@ -0,0 +1,346 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kube provides a minimal client for running Jobs on Kubernetes.
+//
+// Only the subset of the batch/v1 and core/v1 APIs required to execute and
+// observe rebuild Jobs is modeled.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/pkg/errors"
+)
+
+// ObjectMeta is the metadata common to all Kubernetes objects.
+type ObjectMeta struct {
+	Name         string            `json:"name,omitempty"`
+	GenerateName string            `json:"generateName,omitempty"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// EnvVar is an environment variable set in a container.
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ResourceRequirements are the compute resources requested by and available to a container.
+type ResourceRequirements struct {
+	Limits   map[string]string `json:"limits,omitempty"`
+	Requests map[string]string `json:"requests,omitempty"`
+}
+
+// VolumeMount mounts a Volume within a container.
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+}
+
+// EmptyDirVolumeSource is a scratch directory sharing the lifetime of a pod.
+type EmptyDirVolumeSource struct{}
+
+// PersistentVolumeClaimVolumeSource references a claim in the pod's namespace.
+type PersistentVolumeClaimVolumeSource struct {
+	ClaimName string `json:"claimName"`
+}
+
+// Volume is storage available to the containers of a pod.
+type Volume struct {
+	Name                  string                             `json:"name"`
+	EmptyDir              *EmptyDirVolumeSource              `json:"emptyDir,omitempty"`
+	PersistentVolumeClaim *PersistentVolumeClaimVolumeSource `json:"persistentVolumeClaim,omitempty"`
+}
+
+// RuntimeDefaultProfile selects the container runtime's default seccomp or AppArmor profile.
+const RuntimeDefaultProfile = "RuntimeDefault"
+
+// SeccompProfile is the seccomp profile applied to a container.
+type SeccompProfile struct {
+	Type             string `json:"type"`
+	LocalhostProfile string `json:"localhostProfile,omitempty"`
+}
+
+// AppArmorProfile is the AppArmor profile applied to a container.
+type AppArmorProfile struct {
+	Type             string `json:"type"`
+	LocalhostProfile string `json:"localhostProfile,omitempty"`
+}
+
+// SecurityContext is the privilege and access control applied to a container.
+type SecurityContext struct {
+	AllowPrivilegeEscalation *bool            `json:"allowPrivilegeEscalation,omitempty"`
+	SeccompProfile           *SeccompProfile  `json:"seccompProfile,omitempty"`
+	AppArmorProfile          *AppArmorProfile `json:"appArmorProfile,omitempty"`
+}
+
+// Container is a single container within a pod.
+type Container struct {
+	Name            string               `json:"name"`
+	Image           string               `json:"image"`
+	Command         []string             `json:"command,omitempty"`
+	Args            []string             `json:"args,omitempty"`
+	WorkingDir      string               `json:"workingDir,omitempty"`
+	Env             []EnvVar             `json:"env,omitempty"`
+	Resources       ResourceRequirements `json:"resources,omitempty"`
+	VolumeMounts    []VolumeMount        `json:"volumeMounts,omitempty"`
+	SecurityContext *SecurityContext     `json:"securityContext,omitempty"`
+}
+
+// PodSpec describes the containers of a pod and where they are scheduled.
+type PodSpec struct {
+	RestartPolicy      string            `json:"restartPolicy,omitempty"`
+	ServiceAccountName string            `json:"serviceAccountName,omitempty"`
+	NodeSelector       map[string]string `json:"nodeSelector,omitempty"`
+	InitContainers     []Container       `json:"initContainers,omitempty"`
+	Containers         []Container       `json:"containers"`
+	Volumes            []Volume          `json:"volumes,omitempty"`
+}
+
+// PodTemplateSpec describes the pods created by a Job.
+type PodTemplateSpec struct {
+	Metadata ObjectMeta `json:"metadata,omitempty"`
+	Spec     PodSpec    `json:"spec"`
+}
+
+// JobSpec describes the execution of a Job.
+type JobSpec struct {
+	BackoffLimit            *int32          `json:"backoffLimit,omitempty"`
+	ActiveDeadlineSeconds   *int64          `json:"activeDeadlineSeconds,omitempty"`
+	TTLSecondsAfterFinished *int32          `json:"ttlSecondsAfterFinished,omitempty"`
+	Template                PodTemplateSpec `json:"template"`
+}
+
+// JobCondition is an observation of a Job's state.
+type JobCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// JobStatus is the observed state of a Job.
+type JobStatus struct {
+	StartTime      *time.Time     `json:"startTime,omitempty"`
+	CompletionTime *time.Time     `json:"completionTime,omitempty"`
+	Active         int32          `json:"active,omitempty"`
+	Succeeded      int32          `json:"succeeded,omitempty"`
+	Failed         int32          `json:"failed,omitempty"`
+	Conditions     []JobCondition `json:"conditions,omitempty"`
+}
+
+// Job is a batch/v1 Job.
+type Job struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       JobSpec    `json:"spec"`
+	Status     JobStatus  `json:"status,omitempty"`
+}
+
+// NewJob returns a Job with the provided metadata and spec.
+func NewJob(meta ObjectMeta, spec JobSpec) *Job {
+	return &Job{APIVersion: "batch/v1", Kind: "Job", Metadata: meta, Spec: spec}
+}
+
+// Pod is the subset of a core/v1 Pod required to locate its logs.
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+// Client abstracts the Kubernetes API operations used to execute Jobs.
+type Client interface {
+	CreateJob(ctx context.Context, job *Job) (*Job, error)
+	GetJob(ctx context.Context, namespace, name string) (*Job, error)
+	// ListPods returns the pods in the namespace matching the label selector.
+	ListPods(ctx context.Context, namespace, selector string) ([]Pod, error)
+	// PodLogs returns the logs of the container in the pod.
+	PodLogs(ctx context.Context, namespace, pod, container string) (io.ReadCloser, error)
+}
+
+// Service is a Client for the Kubernetes API server at Host.
+type Service struct {
+	Client httpx.BasicClient
+	// Host is the base URL of the API server.
+	Host string
+	// Token, if provided, is sent as a bearer token to authenticate requests.
+	Token string
+}
+
+var _ Client = &Service{}
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// InClusterService returns a Service authenticated as the pod's service account.
+func InClusterService() (*Service, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a cluster")
+	}
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading service account token")
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading cluster CA")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("parsing cluster CA")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	return &Service{Client: client, Host: "https://" + net.JoinHostPort(host, port), Token: strings.TrimSpace(string(token))}, nil
+}
+
+func (s *Service) do(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	u, err := url.Parse(s.Host)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing host")
+	}
+	u = u.JoinPath(path)
+	u.RawQuery = query.Encode()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshalling request")
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		// NOTE: Error responses are a Status object whose message describes the failure.
+		var st struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&st)
+		return nil, errors.Errorf("kubernetes API error: %s: %s", resp.Status, st.Message)
+	}
+	return resp, nil
+}
+
+func (s *Service) doJSON(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := s.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "decoding response")
+}
+
+// CreateJob creates the Job in its namespace.
+func (s *Service) CreateJob(ctx context.Context, job *Job) (*Job, error) {
+	var created Job
+	if err := s.doJSON(ctx, http.MethodPost, fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", job.Metadata.Namespace), nil, job, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetJob returns the current state of the Job.
+func (s *Service) GetJob(ctx context.Context, namespace, name string) (*Job, error) {
+	var job Job
+	if err := s.doJSON(ctx, http.MethodGet, fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs/%s", namespace, name), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListPods returns the pods in the namespace matching the label selector.
+func (s *Service) ListPods(ctx context.Context, namespace, selector string) ([]Pod, error) {
+	var list struct {
+		Items []Pod `json:"items"`
+	}
+	if err := s.doJSON(ctx, http.MethodGet, fmt.Sprintf("/api/v1/namespaces/%s/pods", namespace), url.Values{"labelSelector": {selector}}, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// PodLogs returns the logs of the container in the pod.
+func (s *Service) PodLogs(ctx context.Context, namespace, pod, container string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", namespace, pod), url.Values{"container": {container}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ErrJobFailed is returned when a Job does not complete successfully.
+var ErrJobFailed = errors.New("job failed")
+
+// condition returns the condition of the provided type if it is true.
+func (j *Job) condition(typ string) *JobCondition {
+	for i, c := range j.Status.Conditions {
+		if c.Type == typ && c.Status == "True" {
+			return &j.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// WaitJob polls the Job until it completes or fails and returns its final state.
+func WaitJob(ctx context.Context, client Client, namespace, name string, interval time.Duration) (*Job, error) {
+	for {
+		job, err := client.GetJob(ctx, namespace, name)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching job")
+		}
+		if job.condition("Complete") != nil {
+			return job, nil
+		}
+		if c := job.condition("Failed"); c != nil {
+			return job, errors.Wrapf(ErrJobFailed, "%s: %s", c.Reason, c.Message)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestService(t *testing.T) {
+	var gotJob Job
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("unexpected Authorization: %q", got)
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/apis/batch/v1/namespaces/ns/jobs":
+			json.NewDecoder(r.Body).Decode(&gotJob)
+			created := gotJob
+			created.Metadata.Name = "rebuild-abcde"
+			json.NewEncoder(w).Encode(created)
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/batch/v1/namespaces/ns/jobs/missing":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"kind":"Status","message":"jobs.batch \"missing\" not found"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/ns/pods":
+			if got := r.URL.Query().Get("labelSelector"); got != "job-name=rebuild-abcde" {
+				t.Errorf("unexpected labelSelector: %q", got)
+			}
+			io.WriteString(w, `{"items":[{"metadata":{"name":"rebuild-abcde-xyz"},"status":{"phase":"Succeeded"}}]}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/ns/pods/rebuild-abcde-xyz/log":
+			io.WriteString(w, r.URL.Query().Get("container")+" logs")
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	s := &Service{Client: srv.Client(), Host: srv.URL, Token: "token"}
+	ctx := context.Background()
+	job, err := s.CreateJob(ctx, NewJob(ObjectMeta{GenerateName: "rebuild-", Namespace: "ns"}, JobSpec{}))
+	if err != nil {
+		t.Fatalf("CreateJob() error: %v", err)
+	}
+	if job.Metadata.Name != "rebuild-abcde" || gotJob.Kind != "Job" || gotJob.APIVersion != "batch/v1" {
+		t.Errorf("CreateJob() unexpected job: sent %+v, got %+v", gotJob, job)
+	}
+	if _, err := s.GetJob(ctx, "ns", "missing"); err == nil {
+		t.Error("GetJob() expected error for missing job")
+	}
+	pods, err := s.ListPods(ctx, "ns", "job-name=rebuild-abcde")
+	if err != nil {
+		t.Fatalf("ListPods() error: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Metadata.Name != "rebuild-abcde-xyz" || pods[0].Status.Phase != "Succeeded" {
+		t.Errorf("ListPods() unexpected pods: %+v", pods)
+	}
+	r, err := s.PodLogs(ctx, "ns", "rebuild-abcde-xyz", "build")
+	if err != nil {
+		t.Fatalf("PodLogs() error: %v", err)
+	}
+	defer r.Close()
+	if diff := cmp.Diff("build logs", string(must(io.ReadAll(r)))); diff != "" {
+		t.Errorf("PodLogs() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+type fakeClient struct {
+	Client
+	jobs []*Job
+}
+
+func (f *fakeClient) GetJob(ctx context.Context, namespace, name string) (*Job, error) {
+	j := f.jobs[0]
+	if len(f.jobs) > 1 {
+		f.jobs = f.jobs[1:]
+	}
+	return j, nil
+}
+
+func TestWaitJob(t *testing.T) {
+	running := &Job{Status: JobStatus{Active: 1}}
+	complete := &Job{Status: JobStatus{Succeeded: 1, Conditions: []JobCondition{{Type: "Complete", Status: "True"}}}}
+	failed := &Job{Status: JobStatus{Failed: 1, Conditions: []JobCondition{{Type: "Failed", Status: "True", Reason: "DeadlineExceeded", Message: "Job was active longer than specified deadline"}}}}
+	ctx := context.Background()
+	got, err := WaitJob(ctx, &fakeClient{jobs: []*Job{running, running, complete}}, "ns", "job", time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitJob() error: %v", err)
+	}
+	if got != complete {
+		t.Errorf("WaitJob() = %+v, want %+v", got, complete)
+	}
+	if _, err := WaitJob(ctx, &fakeClient{jobs: []*Job{running, failed}}, "ns", "job", time.Millisecond); !errors.Is(err, ErrJobFailed) {
+		t.Errorf("WaitJob() = %v, want ErrJobFailed", err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := WaitJob(cctx, &fakeClient{jobs: []*Job{running}}, "ns", "job", time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitJob() = %v, want context.Canceled", err)
+	}
+}
+
+func must[T any](t T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
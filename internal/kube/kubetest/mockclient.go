@@ -0,0 +1,34 @@
+package kubetest
+
+import (
+	"context"
+	"io"
+
+	"github.com/google/oss-rebuild/internal/kube"
+)
+
+// MockClient implements kube.Client for testing.
+type MockClient struct {
+	CreateJobFunc func(ctx context.Context, job *kube.Job) (*kube.Job, error)
+	GetJobFunc    func(ctx context.Context, namespace, name string) (*kube.Job, error)
+	ListPodsFunc  func(ctx context.Context, namespace, selector string) ([]kube.Pod, error)
+	PodLogsFunc   func(ctx context.Context, namespace, pod, container string) (io.ReadCloser, error)
+}
+
+var _ kube.Client = &MockClient{}
+
+func (mc *MockClient) CreateJob(ctx context.Context, job *kube.Job) (*kube.Job, error) {
+	return mc.CreateJobFunc(ctx, job)
+}
+
+func (mc *MockClient) GetJob(ctx context.Context, namespace, name string) (*kube.Job, error) {
+	return mc.GetJobFunc(ctx, namespace, name)
+}
+
+func (mc *MockClient) ListPods(ctx context.Context, namespace, selector string) ([]kube.Pod, error) {
+	return mc.ListPodsFunc(ctx, namespace, selector)
+}
+
+func (mc *MockClient) PodLogs(ctx context.Context, namespace, pod, container string) (io.ReadCloser, error) {
+	return mc.PodLogsFunc(ctx, namespace, pod, container)
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mirror provides configuration for accessing package registries
+// through private mirrors such as Artifactory, Nexus, Verdaccio, and devpi.
+//
+// This allows the internal re-publication of an open source package to be
+// verified against the same source-of-truth rebuild as the public artifact.
+package mirror
+
+import (
+	"encoding/base64"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
+	mavenreg "github.com/google/oss-rebuild/pkg/registry/maven"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	ocireg "github.com/google/oss-rebuild/pkg/registry/oci"
+	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Kind is the product serving a mirror which determines the API used to access it.
+type Kind string
+
+const (
+	Artifactory Kind = "artifactory"
+	Nexus       Kind = "nexus"
+	Verdaccio   Kind = "verdaccio"
+	Devpi       Kind = "devpi"
+)
+
+// supported lists the mirror kinds available for each ecosystem.
+var supported = map[rebuild.Ecosystem][]Kind{
+	rebuild.NPM:      {Artifactory, Nexus, Verdaccio},
+	rebuild.PyPI:     {Artifactory, Nexus, Devpi},
+	rebuild.CratesIO: {Artifactory, Nexus},
+	rebuild.Maven:    {Artifactory, Nexus},
+}
+
+// Mirror describes a private mirror of an ecosystem's registry.
+//
+// URL is the base URL of the mirror's repository as would be provided to the
+// ecosystem's package manager e.g.
+//   - Artifactory: https://example.jfrog.io/artifactory/api/npm/npm-remote
+//   - Nexus: https://nexus.example.com/repository/pypi-proxy
+//   - Artifactory (Cargo): https://example.jfrog.io/artifactory/api/cargo/cargo-remote
+//   - Nexus (Maven): https://nexus.example.com/repository/maven-central
+//   - Verdaccio: https://verdaccio.example.com
+//   - devpi: https://devpi.example.com/root/pypi
+//
+// Credentials are read from the environment so they need not be stored
+// alongside the configuration. If PasswordEnv is provided, HTTP Basic auth is
+// used with Username. If TokenEnv is provided, the token is sent as a Bearer
+// token. Otherwise, the mirror is accessed anonymously.
+type Mirror struct {
+	Ecosystem   rebuild.Ecosystem `yaml:"ecosystem"`
+	Kind        Kind              `yaml:"kind"`
+	URL         string            `yaml:"url"`
+	Username    string            `yaml:"username,omitempty"`
+	PasswordEnv string            `yaml:"password_env,omitempty"`
+	TokenEnv    string            `yaml:"token_env,omitempty"`
+}
+
+// Config is the set of mirrors to use in place of the public registries.
+type Config struct {
+	Mirrors []Mirror `yaml:"mirrors"`
+}
+
+// Load reads and validates the Config from the YAML file at path.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening mirror config")
+	}
+	defer f.Close()
+	var c Config
+	d := yaml.NewDecoder(f)
+	d.KnownFields(true)
+	if err := d.Decode(&c); err != nil {
+		return nil, errors.Wrap(err, "decoding mirror config")
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate returns an error if the Config contains an unsupported or ambiguous mirror.
+func (c Config) Validate() error {
+	seen := make(map[rebuild.Ecosystem]bool)
+	for _, m := range c.Mirrors {
+		kinds, ok := supported[m.Ecosystem]
+		if !ok {
+			return errors.Errorf("mirrors not supported for ecosystem %q", m.Ecosystem)
+		}
+		if !slices.Contains(kinds, m.Kind) {
+			return errors.Errorf("unsupported %s mirror kind %q", m.Ecosystem, m.Kind)
+		}
+		if seen[m.Ecosystem] {
+			return errors.Errorf("multiple mirrors for ecosystem %s", m.Ecosystem)
+		}
+		seen[m.Ecosystem] = true
+		if u, err := url.Parse(m.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.Errorf("invalid %s mirror URL %q", m.Ecosystem, m.URL)
+		}
+		if m.PasswordEnv != "" && m.TokenEnv != "" {
+			return errors.Errorf("%s mirror has both password_env and token_env", m.Ecosystem)
+		}
+		if m.PasswordEnv != "" && m.Username == "" {
+			return errors.Errorf("%s mirror has password_env without username", m.Ecosystem)
+		}
+	}
+	return nil
+}
+
+// authorization returns the Authorization header value for the mirror, if any.
+func (m Mirror) authorization() (string, error) {
+	switch {
+	case m.PasswordEnv != "":
+		password, ok := os.LookupEnv(m.PasswordEnv)
+		if !ok {
+			return "", errors.Errorf("%s mirror password variable %s not set", m.Ecosystem, m.PasswordEnv)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(m.Username+":"+password)), nil
+	case m.TokenEnv != "":
+		token, ok := os.LookupEnv(m.TokenEnv)
+		if !ok {
+			return "", errors.Errorf("%s mirror token variable %s not set", m.Ecosystem, m.TokenEnv)
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", nil
+	}
+}
+
+// Client returns a client that authenticates requests to each mirror with
+// its credentials and otherwise behaves as client.
+// A nil Config returns client unchanged.
+func (c *Config) Client(client httpx.BasicClient) (httpx.BasicClient, error) {
+	if c == nil {
+		return client, nil
+	}
+	for _, m := range c.Mirrors {
+		auth, err := m.authorization()
+		if err != nil {
+			return nil, err
+		}
+		if auth == "" {
+			continue
+		}
+		u, err := url.Parse(m.URL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s mirror URL", m.Ecosystem)
+		}
+		client = &httpx.WithAuthorization{BasicClient: client, Host: u.Host, Authorization: auth}
+	}
+	return client, nil
+}
+
+// RegistryMux returns a RegistryMux that accesses each ecosystem through its
+// configured mirror, or the public registry if none is configured.
+// Requests are sent using client which should be derived from Client when
+// the mirrors require authentication.
+// A nil Config uses the public registries for all ecosystems.
+func (c *Config) RegistryMux(client httpx.BasicClient) (rebuild.RegistryMux, error) {
+	mux := rebuild.RegistryMux{
+		CratesIO: cratesreg.HTTPRegistry{Client: client},
+		Maven:    mavenreg.HTTPRegistry{Client: client},
+		NPM:      npmreg.HTTPRegistry{Client: client},
+		PyPI:     pypireg.HTTPRegistry{Client: client},
+		OCI:      ocireg.HTTPRegistry{Client: client},
+	}
+	if c == nil {
+		return mux, nil
+	}
+	for _, m := range c.Mirrors {
+		u, err := url.Parse(m.URL)
+		if err != nil {
+			return rebuild.RegistryMux{}, errors.Wrapf(err, "parsing %s mirror URL", m.Ecosystem)
+		}
+		switch m.Ecosystem {
+		case rebuild.NPM:
+			mux.NPM = npmreg.HTTPRegistry{Client: client, URL: u}
+		case rebuild.PyPI:
+			if m.Kind == Devpi {
+				mux.PyPI = pypireg.DevpiRegistry{Client: client, URL: u}
+			} else {
+				mux.PyPI = pypireg.HTTPRegistry{Client: client, URL: u}
+			}
+		case rebuild.CratesIO:
+			mux.CratesIO = cratesreg.HTTPRegistry{Client: client, URL: u}
+		case rebuild.Maven:
+			mux.Maven = mavenreg.HTTPRegistry{Client: client, URL: u}
+		default:
+			return rebuild.RegistryMux{}, errors.Errorf("mirrors not supported for ecosystem %q", m.Ecosystem)
+		}
+	}
+	return mux, nil
+}
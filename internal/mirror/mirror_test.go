@@ -0,0 +1,200 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	mavenreg "github.com/google/oss-rebuild/pkg/registry/maven"
+	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+)
+
+// recordingClient responds to every request with an empty JSON object and records the Authorization header sent.
+type recordingClient struct {
+	auth map[string]string
+}
+
+func (c *recordingClient) Do(req *http.Request) (*http.Response, error) {
+	c.auth[req.URL.String()] = req.Header.Get("Authorization")
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader([]byte("{}")))}, nil
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			config: `mirrors:
+- ecosystem: npm
+  kind: verdaccio
+  url: https://verdaccio.example.com
+- ecosystem: pypi
+  kind: devpi
+  url: https://devpi.example.com/root/pypi
+  username: rebuild
+  password_env: DEVPI_PASSWORD
+`,
+		},
+		{
+			name: "unsupported ecosystem",
+			config: `mirrors:
+- ecosystem: oci
+  kind: artifactory
+  url: https://example.jfrog.io/artifactory/api/docker/docker-remote
+`,
+			wantErr: true,
+		},
+		{
+			name: "unsupported kind",
+			config: `mirrors:
+- ecosystem: npm
+  kind: devpi
+  url: https://devpi.example.com/root/pypi
+`,
+			wantErr: true,
+		},
+		{
+			name: "cratesio and maven",
+			config: `mirrors:
+- ecosystem: cratesio
+  kind: artifactory
+  url: https://example.jfrog.io/artifactory/api/cargo/cargo-remote
+- ecosystem: maven
+  kind: nexus
+  url: https://nexus.example.com/repository/maven-central
+`,
+		},
+		{
+			name: "duplicate ecosystem",
+			config: `mirrors:
+- ecosystem: npm
+  kind: verdaccio
+  url: https://verdaccio.example.com
+- ecosystem: npm
+  kind: nexus
+  url: https://nexus.example.com/repository/npm-proxy
+`,
+			wantErr: true,
+		},
+		{
+			name: "relative URL",
+			config: `mirrors:
+- ecosystem: npm
+  kind: verdaccio
+  url: verdaccio.example.com
+`,
+			wantErr: true,
+		},
+		{
+			name: "password without username",
+			config: `mirrors:
+- ecosystem: npm
+  kind: verdaccio
+  url: https://verdaccio.example.com
+  password_env: VERDACCIO_PASSWORD
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown field",
+			config: `mirrors:
+- ecosystem: npm
+  kind: verdaccio
+  url: https://verdaccio.example.com
+  password: hunter2
+`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mirrors.yaml")
+			if err := os.WriteFile(path, []byte(tc.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistryMux(t *testing.T) {
+	t.Setenv("NEXUS_TOKEN", "s3cr3t")
+	t.Setenv("DEVPI_PASSWORD", "hunter2")
+	c := &Config{Mirrors: []Mirror{
+		{Ecosystem: rebuild.NPM, Kind: Nexus, URL: "https://nexus.example.com/repository/npm-proxy", TokenEnv: "NEXUS_TOKEN"},
+		{Ecosystem: rebuild.PyPI, Kind: Devpi, URL: "https://devpi.example.com/root/pypi", Username: "rebuild", PasswordEnv: "DEVPI_PASSWORD"},
+		{Ecosystem: rebuild.Maven, Kind: Nexus, URL: "https://nexus.example.com/repository/maven-central", TokenEnv: "NEXUS_TOKEN"},
+	}}
+	rc := &recordingClient{auth: make(map[string]string)}
+	client, err := c.Client(rc)
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+	mux, err := c.RegistryMux(client)
+	if err != nil {
+		t.Fatalf("RegistryMux() error = %v", err)
+	}
+	if _, ok := mux.PyPI.(pypireg.DevpiRegistry); !ok {
+		t.Errorf("RegistryMux() PyPI = %T, want pypi.DevpiRegistry", mux.PyPI)
+	}
+	ctx := context.Background()
+	mux.NPM.Version(ctx, "express", "4.18.2")
+	mux.PyPI.Release(ctx, "requests", "2.31.0")
+	mux.CratesIO.Version(ctx, "serde", "1.0.0")
+	mux.Maven.ReleaseFile(ctx, "junit:junit", "4.13", mavenreg.TypePOM)
+	want := map[string]string{
+		"https://nexus.example.com/repository/maven-central/junit/junit/4.13/junit-4.13.pom": "Bearer s3cr3t",
+		"https://nexus.example.com/repository/npm-proxy/express/4.18.2":                      "Bearer s3cr3t",
+		"https://devpi.example.com/root/pypi/requests/2.31.0":                                "Basic cmVidWlsZDpodW50ZXIy",
+		"https://crates.io/api/v1/crates/serde/1.0.0":                                        "",
+	}
+	if diff := cmp.Diff(want, rc.auth); diff != "" {
+		t.Errorf("Authorization mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClientMissingCredential(t *testing.T) {
+	c := &Config{Mirrors: []Mirror{
+		{Ecosystem: rebuild.NPM, Kind: Artifactory, URL: "https://example.jfrog.io/artifactory/api/npm/npm-remote", TokenEnv: "OSS_REBUILD_TEST_UNSET_TOKEN"},
+	}}
+	if _, err := c.Client(http.DefaultClient); err == nil {
+		t.Error("Client() error = nil, want missing variable error")
+	}
+}
+
+func TestNilConfig(t *testing.T) {
+	var c *Config
+	mux, err := c.RegistryMux(http.DefaultClient)
+	if err != nil {
+		t.Fatalf("RegistryMux() error = %v", err)
+	}
+	if _, ok := mux.PyPI.(pypireg.HTTPRegistry); !ok {
+		t.Errorf("RegistryMux() PyPI = %T, want pypi.HTTPRegistry", mux.PyPI)
+	}
+}
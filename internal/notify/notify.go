@@ -0,0 +1,211 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify delivers notifications of rebuild events to operators.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// Kind identifies the type of an Event.
+type Kind string
+
+const (
+	// Regression is reported when a previously reproducible target fails to rebuild.
+	Regression Kind = "regression"
+	// RunComplete is reported when all the targets of a run have been executed.
+	RunComplete Kind = "run_complete"
+	// WatchedFailure is reported when a rebuild of a watched package fails.
+	WatchedFailure Kind = "watched_failure"
+	// Quarantined is reported when a rebuild result is quarantined as high-risk.
+	Quarantined Kind = "quarantined"
+	// ArtifactMutated is reported when the registry serves different content for an already-observed artifact.
+	ArtifactMutated Kind = "artifact_mutated"
+)
+
+// Kinds are all the supported kinds of event.
+var Kinds = []Kind{Regression, RunComplete, WatchedFailure, Quarantined, ArtifactMutated}
+
+// Event is an occurrence of which operators are to be notified.
+type Event struct {
+	Kind    Kind
+	Target  *rebuild.Target `json:",omitempty"`
+	RunID   string          `json:",omitempty"`
+	Message string
+	Time    time.Time
+}
+
+// Summary returns a single-line, human-readable description of the event.
+func (e Event) Summary() string {
+	var parts []string
+	switch e.Kind {
+	case Regression:
+		parts = append(parts, "Rebuild regressed")
+	case RunComplete:
+		parts = append(parts, "Run completed")
+	case WatchedFailure:
+		parts = append(parts, "Watched package failed")
+	case Quarantined:
+		parts = append(parts, "Rebuild quarantined")
+	case ArtifactMutated:
+		parts = append(parts, "Upstream artifact changed")
+	default:
+		parts = append(parts, string(e.Kind))
+	}
+	if e.Target != nil {
+		parts = append(parts, fmt.Sprintf("%s %s@%s", e.Target.Ecosystem, e.Target.Package, e.Target.Version))
+	}
+	if e.RunID != "" {
+		parts = append(parts, fmt.Sprintf("(run %s)", e.RunID))
+	}
+	s := strings.Join(parts, " ")
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
+
+// Notifier delivers events to an external service.
+type Notifier interface {
+	Notify(context.Context, Event) error
+}
+
+// Send delivers the event using "n", logging rather than returning any error.
+// A nil Notifier discards the event.
+func Send(ctx context.Context, n Notifier, e Event) {
+	if n == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if err := n.Notify(ctx, e); err != nil {
+		log.Printf("delivering %s notification: %v", e.Kind, err)
+	}
+}
+
+func post(ctx context.Context, client httpx.BasicClient, url string, body any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "marshalling notification")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("notification rejected: %s", resp.Status)
+	}
+	return nil
+}
+
+// Webhook posts each event as JSON to a URL.
+type Webhook struct {
+	Client httpx.BasicClient
+	URL    string
+}
+
+var _ Notifier = &Webhook{}
+
+// Notify posts the event to the webhook.
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	return post(ctx, w.Client, w.URL, e)
+}
+
+// Slack posts a summary of each event to a Slack incoming webhook.
+type Slack struct {
+	Client httpx.BasicClient
+	URL    string
+}
+
+var _ Notifier = &Slack{}
+
+// Notify posts the event summary to the Slack channel.
+func (s *Slack) Notify(ctx context.Context, e Event) error {
+	return post(ctx, s.Client, s.URL, map[string]string{"text": e.Summary()})
+}
+
+// Email sends a message describing each event through an SMTP server.
+type Email struct {
+	// Addr is the host:port of the SMTP server.
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+var _ Notifier = &Email{}
+
+// Notify sends the event summary to the configured recipients.
+func (m *Email) Notify(ctx context.Context, e Event) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [oss-rebuild] %s\r\n", e.Summary())
+	fmt.Fprintf(&msg, "\r\n%s\r\n", e.Summary())
+	return errors.Wrap(smtp.SendMail(m.Addr, m.Auth, m.From, m.To, msg.Bytes()), "sending mail")
+}
+
+// Filter forwards only the events of the provided kinds.
+type Filter struct {
+	Kinds    []Kind
+	Notifier Notifier
+}
+
+var _ Notifier = &Filter{}
+
+// Notify forwards the event if its kind is permitted.
+func (f *Filter) Notify(ctx context.Context, e Event) error {
+	for _, k := range f.Kinds {
+		if k == e.Kind {
+			return f.Notifier.Notify(ctx, e)
+		}
+	}
+	return nil
+}
+
+// Multi delivers each event to all of its Notifiers.
+type Multi []Notifier
+
+var _ Notifier = Multi{}
+
+// Notify delivers the event to each Notifier, returning the first error encountered.
+func (m Multi) Notify(ctx context.Context, e Event) error {
+	var first error
+	for _, n := range m {
+		if err := n.Notify(ctx, e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
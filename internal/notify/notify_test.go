@@ -0,0 +1,121 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+type recordingClient struct {
+	urls   []string
+	bodies []string
+}
+
+func (c *recordingClient) Do(req *http.Request) (*http.Response, error) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.urls = append(c.urls, req.URL.String())
+	c.bodies = append(c.bodies, string(b))
+	return &http.Response{StatusCode: http.StatusOK, Status: http.StatusText(http.StatusOK), Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+var regression = Event{
+	Kind:    Regression,
+	Target:  &rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0"},
+	RunID:   "run-1",
+	Message: "content mismatch",
+	Time:    time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC),
+}
+
+func TestSummary(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{
+			name:  "regression",
+			event: regression,
+			want:  "Rebuild regressed npm left-pad@1.3.0 (run run-1): content mismatch",
+		},
+		{
+			name:  "run complete",
+			event: Event{Kind: RunComplete, RunID: "run-1", Message: "9 succeeded, 1 failed"},
+			want:  "Run completed (run run-1): 9 succeeded, 1 failed",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.event.Summary()); diff != "" {
+				t.Errorf("Summary() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	client := &recordingClient{}
+	w := &Webhook{Client: client, URL: "https://example.com/hook"}
+	if err := w.Notify(context.Background(), regression); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"https://example.com/hook"}, client.urls); diff != "" {
+		t.Errorf("urls mismatch (-want +got):\n%s", diff)
+	}
+	var got Event
+	if err := json.Unmarshal([]byte(client.bodies[0]), &got); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if diff := cmp.Diff(regression, got); diff != "" {
+		t.Errorf("event mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	client := &recordingClient{}
+	s := &Slack{Client: client, URL: "https://hooks.slack.com/services/T/B/X"}
+	if err := s.Notify(context.Background(), regression); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	want := []string{`{"text":"Rebuild regressed npm left-pad@1.3.0 (run run-1): content mismatch"}`}
+	if diff := cmp.Diff(want, client.bodies); diff != "" {
+		t.Errorf("bodies mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	client := &recordingClient{}
+	f := &Filter{Kinds: []Kind{RunComplete}, Notifier: &Webhook{Client: client, URL: "https://example.com/hook"}}
+	ctx := context.Background()
+	if err := f.Notify(ctx, regression); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	if err := f.Notify(ctx, Event{Kind: RunComplete, RunID: "run-1"}); err != nil {
+		t.Fatalf("Notify() error: %v", err)
+	}
+	if len(client.bodies) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(client.bodies))
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oci provides access to container image registries.
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/pkg/errors"
+)
+
+const dockerHub = "registry-1.docker.io"
+
+// manifestTypes are the manifest media types accepted when resolving a reference.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Reference is a parsed container image reference.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an image reference using the docker CLI's defaulting rules.
+func ParseReference(ref string) (Reference, error) {
+	var r Reference
+	name := ref
+	if n, digest, ok := strings.Cut(name, "@"); ok {
+		name, r.Digest = n, digest
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, r.Tag = name[:i], name[i+1:]
+	}
+	if name == "" {
+		return Reference{}, errors.Errorf("invalid image reference: %q", ref)
+	}
+	first, rest, hasSlash := strings.Cut(name, "/")
+	if hasSlash && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry, r.Repository = first, rest
+	} else {
+		r.Registry, r.Repository = dockerHub, name
+		if !hasSlash {
+			r.Repository = "library/" + name
+		}
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+	return r, nil
+}
+
+// Resolver resolves image references to manifest digests using the registry HTTP API.
+type Resolver struct {
+	Client httpx.BasicClient
+}
+
+// Resolve returns the digest of the manifest referred to by ref.
+// References that already include a digest are returned without contacting the registry.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	parsed, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Digest != "" {
+		return parsed.Digest, nil
+	}
+	u := url.URL{Scheme: "https", Host: parsed.Registry, Path: "/v2/" + parsed.Repository + "/manifests/" + parsed.Tag}
+	resp, err := r.head(ctx, u.String(), "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := r.token(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return "", errors.Wrap(err, "fetching registry token")
+		}
+		resp, err = r.head(ctx, u.String(), token)
+		if err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("resolving %s: %s", ref, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", errors.Errorf("resolving %s: no digest returned", ref)
+	}
+	return digest, nil
+}
+
+func (r *Resolver) head(ctx context.Context, u, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "building manifest request")
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ","))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "requesting manifest")
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// token performs the anonymous token exchange described by a Bearer challenge.
+func (r *Resolver) token(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", errors.Errorf("unsupported auth challenge: %q", challenge)
+	}
+	attrs := parseChallenge(params)
+	realm, err := url.Parse(attrs["realm"])
+	if err != nil || attrs["realm"] == "" {
+		return "", errors.Errorf("invalid realm in challenge: %q", challenge)
+	}
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if v, ok := attrs[k]; ok {
+			q.Set(k, v)
+		}
+	}
+	realm.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "building token request")
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "requesting token")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("token request: %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.Wrap(err, "decoding token")
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseChallenge parses the comma-separated key="value" pairs of an auth challenge.
+func parseChallenge(params string) map[string]string {
+	attrs := make(map[string]string)
+	for params != "" {
+		var kv string
+		params = strings.TrimLeft(params, " ,")
+		key, rest, ok := strings.Cut(params, "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			kv, params = rest[1:end+1], rest[end+2:]
+		} else {
+			kv, params, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(key)] = kv
+	}
+	return attrs
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+)
+
+func TestParseReference(t *testing.T) {
+	for _, tc := range []struct {
+		ref  string
+		want Reference
+	}{
+		{"alpine:3.19", Reference{Registry: dockerHub, Repository: "library/alpine", Tag: "3.19"}},
+		{"gcr.io/cloud-builders/gsutil", Reference{Registry: "gcr.io", Repository: "cloud-builders/gsutil", Tag: "latest"}},
+		{"localhost:5000/foo/bar:v1", Reference{Registry: "localhost:5000", Repository: "foo/bar", Tag: "v1"}},
+		{"alpine@sha256:abcd", Reference{Registry: dockerHub, Repository: "library/alpine", Digest: "sha256:abcd"}},
+	} {
+		t.Run(tc.ref, func(t *testing.T) {
+			got, err := ParseReference(tc.ref)
+			if err != nil {
+				t.Fatalf("ParseReference() error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseReference() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func response(status int, header http.Header, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestResolve(t *testing.T) {
+	client := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://registry-1.docker.io/v2/library/alpine/manifests/3.19",
+				Response: response(http.StatusUnauthorized, http.Header{
+					"Www-Authenticate": []string{`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull"`},
+				}, ""),
+			},
+			{
+				URL:      "https://auth.docker.io/token?scope=repository%3Alibrary%2Falpine%3Apull&service=registry.docker.io",
+				Response: response(http.StatusOK, nil, `{"token":"tok"}`),
+			},
+			{
+				URL:      "https://registry-1.docker.io/v2/library/alpine/manifests/3.19",
+				Response: response(http.StatusOK, http.Header{"Docker-Content-Digest": []string{"sha256:1234"}}, ""),
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if expected != actual {
+				t.Errorf("URL mismatch: want=%s got=%s", expected, actual)
+			}
+		},
+	}
+	r := &Resolver{Client: client}
+	got, err := r.Resolve(context.Background(), "alpine:3.19")
+	if err != nil {
+		t.Fatalf("Resolve() error: %v", err)
+	}
+	if got != "sha256:1234" {
+		t.Errorf("Resolve() = %s, want sha256:1234", got)
+	}
+	if err := client.Verify(); err != nil {
+		t.Error(err)
+	}
+}
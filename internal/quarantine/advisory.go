@@ -0,0 +1,96 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quarantine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// AdvisoryDrafter opens a draft security advisory for an escalated Record.
+type AdvisoryDrafter interface {
+	// Draft creates the advisory and returns a URL at which it can be reviewed.
+	Draft(ctx context.Context, r Record) (string, error)
+}
+
+var githubAPI, _ = url.Parse("https://api.github.com")
+
+// GitHubAdvisories drafts repository security advisories on GitHub.
+type GitHubAdvisories struct {
+	Client httpx.BasicClient
+	// Repo is the "owner/name" of the repository in which advisories are drafted.
+	Repo  string
+	Token string
+}
+
+var _ AdvisoryDrafter = &GitHubAdvisories{}
+
+// githubEcosystems maps rebuild ecosystems to those of the GitHub Advisory Database.
+var githubEcosystems = map[rebuild.Ecosystem]string{
+	rebuild.NPM:      "npm",
+	rebuild.PyPI:     "pip",
+	rebuild.CratesIO: "rust",
+	rebuild.Maven:    "maven",
+}
+
+// Draft creates a draft advisory describing the escalated Record.
+func (g *GitHubAdvisories) Draft(ctx context.Context, r Record) (string, error) {
+	vuln := map[string]any{
+		"package":                  map[string]string{"ecosystem": githubEcosystems[rebuild.Ecosystem(r.Ecosystem)], "name": r.Package},
+		"vulnerable_version_range": "= " + r.Version,
+	}
+	body := map[string]any{
+		"summary": fmt.Sprintf("Malicious code in %s %s", r.Package, r.Version),
+		"description": fmt.Sprintf("The published %s artifact of %s@%s does not match the artifact rebuilt from source "+
+			"and was confirmed malicious by two reviewers.\n\nRisk score: %d\nIndicators: %s\nRun: %s",
+			r.Ecosystem, r.Package, r.Version, r.RiskScore, r.Reason, r.RunID),
+		"severity":        "critical",
+		"vulnerabilities": []any{vuln},
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling advisory")
+	}
+	u := githubAPI.JoinPath("repos", g.Repo, "security-advisories")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return "", errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "sending request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", errors.Errorf("advisory rejected: %s", resp.Status)
+	}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", errors.Wrap(err, "decoding response")
+	}
+	return created.HTMLURL, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quarantine implements the review workflow for rebuild results flagged as high-risk.
+//
+// A flagged result enters the Quarantined state. Any reviewer may propose a
+// resolution, either releasing the result as benign or escalating it as
+// malicious, but the proposal only takes effect once approved by a second,
+// different reviewer. Every transition is recorded as an AuditEntry.
+package quarantine
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// State is the stage of the review workflow in which a Record resides.
+type State string
+
+const (
+	// Quarantined results await a proposed resolution.
+	Quarantined State = "quarantined"
+	// PendingRelease results await approval of a proposal to release them.
+	PendingRelease State = "pending_release"
+	// PendingEscalation results await approval of a proposal to escalate them.
+	PendingEscalation State = "pending_escalation"
+	// Released results were confirmed benign by two reviewers.
+	Released State = "released"
+	// Escalated results were confirmed malicious by two reviewers.
+	Escalated State = "escalated"
+)
+
+// Terminal returns whether no further transitions are possible from the state.
+func (s State) Terminal() bool {
+	return s == Released || s == Escalated
+}
+
+// Action is a reviewer's input to the workflow.
+type Action string
+
+const (
+	// ProposeRelease proposes that a quarantined result is benign.
+	ProposeRelease Action = "propose_release"
+	// ProposeEscalation proposes that a quarantined result is malicious.
+	ProposeEscalation Action = "propose_escalation"
+	// Approve confirms the pending proposal.
+	Approve Action = "approve"
+	// Reject declines the pending proposal, returning the result to quarantine.
+	Reject Action = "reject"
+)
+
+// Actions are all the supported reviewer actions.
+var Actions = []Action{ProposeRelease, ProposeEscalation, Approve, Reject}
+
+// The action taken when a Record is first created.
+const flagAction Action = "flag"
+
+var (
+	// ErrInvalidTransition is returned for an action not permitted from the current state.
+	ErrInvalidTransition = errors.New("invalid transition")
+	// ErrSelfApproval is returned when the proposer of a resolution attempts to approve it.
+	ErrSelfApproval = errors.New("proposal must be approved by a different reviewer")
+)
+
+// Record is the review status of a single flagged rebuild result.
+type Record struct {
+	Ecosystem string `firestore:"ecosystem,omitempty"`
+	Package   string `firestore:"package,omitempty"`
+	Version   string `firestore:"version,omitempty"`
+	Artifact  string `firestore:"artifact,omitempty"`
+	RunID     string `firestore:"run_id,omitempty"`
+	State     State  `firestore:"state,omitempty"`
+	RiskScore int    `firestore:"risk_score,omitempty"`
+	// Reason summarizes the evidence that caused the result to be flagged.
+	Reason string `firestore:"reason,omitempty"`
+	// Proposer is the reviewer whose proposal is pending, if any.
+	Proposer string `firestore:"proposer,omitempty"`
+	// AdvisoryURL locates the advisory draft opened upon escalation, if any.
+	AdvisoryURL string `firestore:"advisory_url,omitempty"`
+	Created     int64  `firestore:"created,omitempty"`
+	Updated     int64  `firestore:"updated,omitempty"`
+}
+
+// Target returns the rebuild.Target to which the Record applies.
+func (r Record) Target() rebuild.Target {
+	return rebuild.Target{
+		Ecosystem: rebuild.Ecosystem(r.Ecosystem),
+		Package:   r.Package,
+		Version:   r.Version,
+		Artifact:  r.Artifact,
+	}
+}
+
+// AuditEntry is an immutable log of a single workflow transition.
+type AuditEntry struct {
+	Ecosystem string `firestore:"ecosystem,omitempty"`
+	Package   string `firestore:"package,omitempty"`
+	Version   string `firestore:"version,omitempty"`
+	Artifact  string `firestore:"artifact,omitempty"`
+	Actor     string `firestore:"actor,omitempty"`
+	Action    Action `firestore:"action,omitempty"`
+	From      State  `firestore:"from,omitempty"`
+	To        State  `firestore:"to,omitempty"`
+	Note      string `firestore:"note,omitempty"`
+	Created   int64  `firestore:"created,omitempty"`
+}
+
+// New creates a Quarantined Record for a flagged result along with its initial AuditEntry.
+func New(t rebuild.Target, runID string, risk *rebuild.RiskAssessment, now time.Time) (Record, AuditEntry) {
+	r := Record{
+		Ecosystem: string(t.Ecosystem),
+		Package:   t.Package,
+		Version:   t.Version,
+		Artifact:  t.Artifact,
+		RunID:     runID,
+		State:     Quarantined,
+		Created:   now.UnixMilli(),
+		Updated:   now.UnixMilli(),
+	}
+	if risk != nil {
+		r.RiskScore = risk.Score
+		var indicators []string
+		for _, e := range risk.Evidence {
+			if !slices.Contains(indicators, string(e.Indicator)) {
+				indicators = append(indicators, string(e.Indicator))
+			}
+		}
+		r.Reason = strings.Join(indicators, ", ")
+	}
+	return r, r.entry("", flagAction, "", Quarantined, r.Reason, now)
+}
+
+// Apply performs a reviewer's action on the Record, returning the resulting AuditEntry.
+// The Record is left unmodified if the action is not permitted.
+func (r *Record) Apply(actor string, a Action, note string, now time.Time) (AuditEntry, error) {
+	if actor == "" {
+		return AuditEntry{}, errors.New("actor is required")
+	}
+	var next State
+	switch {
+	case r.State == Quarantined && a == ProposeRelease:
+		next = PendingRelease
+	case r.State == Quarantined && a == ProposeEscalation:
+		next = PendingEscalation
+	case (r.State == PendingRelease || r.State == PendingEscalation) && a == Approve:
+		if actor == r.Proposer {
+			return AuditEntry{}, ErrSelfApproval
+		}
+		next = map[State]State{PendingRelease: Released, PendingEscalation: Escalated}[r.State]
+	case (r.State == PendingRelease || r.State == PendingEscalation) && a == Reject:
+		next = Quarantined
+	default:
+		return AuditEntry{}, errors.Wrapf(ErrInvalidTransition, "%s from %s", a, r.State)
+	}
+	prev := r.State
+	r.State = next
+	r.Updated = now.UnixMilli()
+	if next == PendingRelease || next == PendingEscalation {
+		r.Proposer = actor
+	} else {
+		r.Proposer = ""
+	}
+	return r.entry(actor, a, prev, next, note, now), nil
+}
+
+func (r Record) entry(actor string, a Action, from, to State, note string, now time.Time) AuditEntry {
+	return AuditEntry{
+		Ecosystem: r.Ecosystem,
+		Package:   r.Package,
+		Version:   r.Version,
+		Artifact:  r.Artifact,
+		Actor:     actor,
+		Action:    a,
+		From:      from,
+		To:        to,
+		Note:      note,
+		Created:   now.UnixMilli(),
+	}
+}
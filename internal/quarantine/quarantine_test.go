@@ -0,0 +1,177 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quarantine
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+var (
+	target = rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz"}
+	risk   = &rebuild.RiskAssessment{Score: 55, Evidence: []rebuild.RiskEvidence{
+		{Path: "package/package.json", Indicator: rebuild.IndicatorInstallHook, Detail: "scripts.postinstall"},
+		{Path: "package/p.js", Indicator: rebuild.IndicatorNetwork, Detail: "https://evil.example"},
+		{Path: "package/q.js", Indicator: rebuild.IndicatorNetwork, Detail: "fetch("},
+	}}
+	now = time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+)
+
+func TestNew(t *testing.T) {
+	r, e := New(target, "run-1", risk, now)
+	want := Record{Ecosystem: "npm", Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz", RunID: "run-1", State: Quarantined, RiskScore: 55, Reason: "install-hook, network", Created: now.UnixMilli(), Updated: now.UnixMilli()}
+	if diff := cmp.Diff(want, r); diff != "" {
+		t.Errorf("New() record mismatch (-want +got):\n%s", diff)
+	}
+	if e.Action != flagAction || e.To != Quarantined {
+		t.Errorf("New() entry = %+v, want flag to %s", e, Quarantined)
+	}
+}
+
+func TestApply(t *testing.T) {
+	type step struct {
+		actor   string
+		action  Action
+		want    State
+		wantErr error
+	}
+	for _, tc := range []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "release",
+			steps: []step{
+				{"alice", ProposeRelease, PendingRelease, nil},
+				{"bob", Approve, Released, nil},
+			},
+		},
+		{
+			name: "escalate",
+			steps: []step{
+				{"alice", ProposeEscalation, PendingEscalation, nil},
+				{"bob", Approve, Escalated, nil},
+			},
+		},
+		{
+			name: "self approval",
+			steps: []step{
+				{"alice", ProposeEscalation, PendingEscalation, nil},
+				{"alice", Approve, PendingEscalation, ErrSelfApproval},
+			},
+		},
+		{
+			name: "reject then repropose",
+			steps: []step{
+				{"alice", ProposeRelease, PendingRelease, nil},
+				{"bob", Reject, Quarantined, nil},
+				{"bob", ProposeEscalation, PendingEscalation, nil},
+				{"alice", Approve, Escalated, nil},
+			},
+		},
+		{
+			name: "approve without proposal",
+			steps: []step{
+				{"alice", Approve, Quarantined, ErrInvalidTransition},
+			},
+		},
+		{
+			name: "terminal",
+			steps: []step{
+				{"alice", ProposeRelease, PendingRelease, nil},
+				{"bob", Approve, Released, nil},
+				{"carol", ProposeEscalation, Released, ErrInvalidTransition},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := New(target, "run-1", risk, now)
+			for i, s := range tc.steps {
+				prev := r.State
+				e, err := r.Apply(s.actor, s.action, "", now)
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: Apply() error = %v, want %v", i, err, s.wantErr)
+				}
+				if r.State != s.want {
+					t.Fatalf("step %d: State = %s, want %s", i, r.State, s.want)
+				}
+				if err == nil && (e.Actor != s.actor || e.From != prev || e.To != s.want) {
+					t.Errorf("step %d: entry = %+v", i, e)
+				}
+			}
+		})
+	}
+}
+
+type fakeClient struct {
+	req  *http.Request
+	body string
+	resp *http.Response
+}
+
+func (c *fakeClient) Do(req *http.Request) (*http.Response, error) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.req, c.body = req, string(b)
+	return c.resp, nil
+}
+
+func TestGitHubAdvisories(t *testing.T) {
+	client := &fakeClient{resp: &http.Response{
+		StatusCode: http.StatusCreated,
+		Status:     http.StatusText(http.StatusCreated),
+		Body:       io.NopCloser(strings.NewReader(`{"html_url":"https://github.com/org/advisories/security/advisories/GHSA-xxxx"}`)),
+	}}
+	g := &GitHubAdvisories{Client: client, Repo: "org/advisories", Token: "secret"}
+	r, _ := New(target, "run-1", risk, now)
+	got, err := g.Draft(context.Background(), r)
+	if err != nil {
+		t.Fatalf("Draft() error = %v", err)
+	}
+	if want := "https://github.com/org/advisories/security/advisories/GHSA-xxxx"; got != want {
+		t.Errorf("Draft() = %q, want %q", got, want)
+	}
+	if want := "https://api.github.com/repos/org/advisories/security-advisories"; client.req.URL.String() != want {
+		t.Errorf("request URL = %q, want %q", client.req.URL, want)
+	}
+	if got := client.req.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+	var body struct {
+		Vulnerabilities []struct {
+			Package struct {
+				Ecosystem string `json:"ecosystem"`
+				Name      string `json:"name"`
+			} `json:"package"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal([]byte(client.body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Vulnerabilities) != 1 || body.Vulnerabilities[0].Package.Ecosystem != "npm" || body.Vulnerabilities[0].Package.Name != "left-pad" {
+		t.Errorf("unexpected vulnerabilities: %+v", body.Vulnerabilities)
+	}
+}
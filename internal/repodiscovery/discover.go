@@ -0,0 +1,121 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repodiscovery finds the source repository of a package when its registry metadata does not.
+package repodiscovery
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/uri"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultMinScore      = 0.1
+	defaultMaxCandidates = 8
+)
+
+// ErrNoRepo indicates that no candidate repository matched the artifact.
+var ErrNoRepo = errors.New("no matching repository found")
+
+// Discoverer proposes candidate repositories for a target and validates each
+// by matching the contents of the upstream artifact against the repository.
+type Discoverer struct {
+	// Sources are consulted in order for candidates.
+	Sources []Source
+	// Mux provides access to the upstream artifacts.
+	Mux rebuild.RegistryMux
+	// MinScore is the minimum fraction of artifact files that must be found in a candidate for it to be accepted.
+	MinScore float64
+	// MaxCandidates, if positive, bounds the number of candidates cloned for validation.
+	MaxCandidates int
+}
+
+// New returns a Discoverer using all available sources.
+func New(client httpx.BasicClient, mux rebuild.RegistryMux) *Discoverer {
+	return &Discoverer{
+		Sources: []Source{
+			DepsDev{Client: client},
+			Homepage{Client: client, Mux: mux},
+			Conventions{},
+			GitHubSearch{Client: client},
+		},
+		Mux:           mux,
+		MinScore:      defaultMinScore,
+		MaxCandidates: defaultMaxCandidates,
+	}
+}
+
+// Discover returns the candidate repository that best matches the target's artifact.
+// Repositories in exclude, such as one already found to be wrong, are not considered.
+func (d *Discoverer) Discover(ctx context.Context, t rebuild.Target, exclude ...string) (string, error) {
+	blobs, err := artifactBlobs(ctx, d.Mux, t)
+	if err != nil {
+		return "", errors.Wrap(err, "reading artifact")
+	}
+	if len(blobs) == 0 {
+		return "", errors.New("artifact contains no files")
+	}
+	var best string
+	var bestScore float64
+	for _, c := range d.candidates(ctx, t, exclude) {
+		score, err := Score(ctx, c, blobs)
+		if err != nil {
+			log.Printf("Skipping repo candidate [pkg=%s,repo=%s]: %v\n", t.Package, c, err)
+			continue
+		}
+		log.Printf("Scored repo candidate [pkg=%s,repo=%s,score=%.2f]\n", t.Package, c, score)
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	if best == "" || bestScore < d.MinScore {
+		return "", ErrNoRepo
+	}
+	return best, nil
+}
+
+// candidates returns the distinct canonicalized candidates proposed by the sources.
+func (d *Discoverer) candidates(ctx context.Context, t rebuild.Target, exclude []string) []string {
+	seen := make(map[string]bool)
+	for _, e := range exclude {
+		if c, err := uri.CanonicalizeRepoURI(e); err == nil {
+			seen[c] = true
+		}
+	}
+	var out []string
+	for _, s := range d.Sources {
+		uris, err := s.Candidates(ctx, t)
+		if err != nil {
+			log.Printf("Repo discovery source %s failed [pkg=%s]: %v\n", s.Name(), t.Package, err)
+			continue
+		}
+		for _, u := range uris {
+			c, err := uri.CanonicalizeRepoURI(u)
+			if err != nil || seen[c] {
+				continue
+			}
+			seen[c] = true
+			out = append(out, c)
+			if d.MaxCandidates > 0 && len(out) >= d.MaxCandidates {
+				return out
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,257 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repodiscovery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/uri"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// Source proposes candidate repositories for a target.
+type Source interface {
+	// Name identifies the source in logs.
+	Name() string
+	// Candidates returns repository URIs that may host the target's source.
+	Candidates(ctx context.Context, t rebuild.Target) ([]string, error)
+}
+
+var (
+	_ Source = DepsDev{}
+	_ Source = GitHubSearch{}
+	_ Source = Homepage{}
+	_ Source = Conventions{}
+)
+
+// maxPageSize bounds the size of fetched homepages.
+const maxPageSize = 1 << 20
+
+func getJSON(ctx context.Context, client httpx.BasicClient, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status: %s", resp.Status)
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(v), "decoding response")
+}
+
+var depsDevURL, _ = url.Parse("https://api.deps.dev")
+
+// depsDevSystems maps ecosystems to their deps.dev package system.
+var depsDevSystems = map[rebuild.Ecosystem]string{
+	rebuild.NPM:      "npm",
+	rebuild.PyPI:     "pypi",
+	rebuild.CratesIO: "cargo",
+}
+
+// DepsDev proposes the source repositories that deps.dev associates with a package version.
+type DepsDev struct {
+	Client httpx.BasicClient
+}
+
+// Name identifies the source in logs.
+func (DepsDev) Name() string { return "deps.dev" }
+
+// Candidates returns the source repository links recorded by deps.dev.
+func (d DepsDev) Candidates(ctx context.Context, t rebuild.Target) ([]string, error) {
+	system, ok := depsDevSystems[t.Ecosystem]
+	if !ok {
+		return nil, nil
+	}
+	// NOTE: Names may contain slashes (e.g. npm scopes) so each segment must be escaped.
+	u := depsDevURL.String() + "/v3/systems/" + system + "/packages/" + url.PathEscape(t.Package) + "/versions/" + url.PathEscape(t.Version)
+	var resp struct {
+		Links []struct {
+			Label string `json:"label"`
+			URL   string `json:"url"`
+		} `json:"links"`
+		RelatedProjects []struct {
+			ProjectKey struct {
+				ID string `json:"id"`
+			} `json:"projectKey"`
+			RelationType string `json:"relationType"`
+		} `json:"relatedProjects"`
+	}
+	if err := getJSON(ctx, d.Client, u, &resp); err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, p := range resp.RelatedProjects {
+		if p.RelationType == "SOURCE_REPO" {
+			out = append(out, "https://"+p.ProjectKey.ID)
+		}
+	}
+	for _, l := range resp.Links {
+		if l.Label == "SOURCE_REPO" {
+			out = append(out, l.URL)
+		} else if repo := uri.FindCommonRepo(l.URL); repo != "" {
+			out = append(out, repo)
+		}
+	}
+	return out, nil
+}
+
+var githubSearchURL, _ = url.Parse("https://api.github.com/search/repositories")
+
+// GitHubSearch proposes the GitHub repositories whose names best match the package.
+type GitHubSearch struct {
+	Client httpx.BasicClient
+}
+
+// Name identifies the source in logs.
+func (GitHubSearch) Name() string { return "github-search" }
+
+// Candidates returns the top results of a GitHub repository search for the package name.
+func (g GitHubSearch) Candidates(ctx context.Context, t rebuild.Target) ([]string, error) {
+	u := *githubSearchURL
+	u.RawQuery = url.Values{"q": []string{baseName(t.Package) + " in:name"}, "per_page": []string{"5"}}.Encode()
+	var resp struct {
+		Items []struct {
+			HTMLURL string `json:"html_url"`
+		} `json:"items"`
+	}
+	if err := getJSON(ctx, g.Client, u.String(), &resp); err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, item := range resp.Items {
+		out = append(out, item.HTMLURL)
+	}
+	return out, nil
+}
+
+// Homepage proposes the repository linked from the package's homepage.
+type Homepage struct {
+	Client httpx.BasicClient
+	Mux    rebuild.RegistryMux
+}
+
+// Name identifies the source in logs.
+func (Homepage) Name() string { return "homepage" }
+
+func (h Homepage) homepage(ctx context.Context, t rebuild.Target) (string, error) {
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		v, err := h.Mux.NPM.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return "", err
+		}
+		return v.Homepage, nil
+	case rebuild.PyPI:
+		r, err := h.Mux.PyPI.Release(ctx, t.Package, t.Version)
+		if err != nil {
+			return "", err
+		}
+		if r.Homepage != "" {
+			return r.Homepage, nil
+		}
+		for name, u := range r.ProjectURLs {
+			if strings.ReplaceAll(strings.ToLower(name), " ", "") == "homepage" {
+				return u, nil
+			}
+		}
+		return "", nil
+	case rebuild.CratesIO:
+		c, err := h.Mux.CratesIO.Crate(ctx, t.Package)
+		if err != nil {
+			return "", err
+		}
+		return c.Homepage, nil
+	default:
+		return "", nil
+	}
+}
+
+// Candidates returns the homepage if it is a repository or else the first repository linked from it.
+func (h Homepage) Candidates(ctx context.Context, t rebuild.Target) ([]string, error) {
+	page, err := h.homepage(ctx, t)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching metadata")
+	}
+	if page == "" {
+		return nil, nil
+	}
+	if repo := uri.FindCommonRepo(page); repo != "" {
+		return []string{repo}, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, page, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching homepage")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching homepage: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading homepage")
+	}
+	if repo := uri.FindCommonRepo(string(body)); repo != "" && !strings.Contains(repo, "sponsors") {
+		return []string{repo}, nil
+	}
+	return nil, nil
+}
+
+// Conventions proposes repositories derived from the package name using common naming conventions.
+type Conventions struct{}
+
+// Name identifies the source in logs.
+func (Conventions) Name() string { return "conventions" }
+
+// Candidates returns repositories whose location follows from the package name.
+//
+// Names that embed a repository path (e.g. "github.com/owner/repo") map to it
+// directly. Scoped npm packages map to a repository of the scope's
+// organization. Otherwise, projects commonly live in an organization of the
+// same name.
+func (Conventions) Candidates(ctx context.Context, t rebuild.Target) ([]string, error) {
+	if repo := uri.FindCommonRepo(t.Package); repo != "" {
+		return []string{repo}, nil
+	}
+	name := baseName(t.Package)
+	var out []string
+	if scope, _, ok := strings.Cut(strings.TrimPrefix(t.Package, "@"), "/"); ok && strings.HasPrefix(t.Package, "@") {
+		out = append(out, "https://github.com/"+scope+"/"+name)
+	}
+	out = append(out, "https://github.com/"+name+"/"+name)
+	if alt := strings.ReplaceAll(name, "_", "-"); alt != name {
+		out = append(out, "https://github.com/"+alt+"/"+alt)
+	}
+	return out, nil
+}
+
+// baseName returns the package name without any scope or namespace.
+func baseName(pkg string) string {
+	return pkg[strings.LastIndex(pkg, "/")+1:]
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repodiscovery
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func response(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestConventions(t *testing.T) {
+	for _, tc := range []struct {
+		pkg  string
+		want []string
+	}{
+		{"left-pad", []string{"https://github.com/left-pad/left-pad"}},
+		{"@babel/core", []string{"https://github.com/babel/core", "https://github.com/core/core"}},
+		{"typing_extensions", []string{"https://github.com/typing_extensions/typing_extensions", "https://github.com/typing-extensions/typing-extensions"}},
+		{"github.com/foo/bar", []string{"github.com/foo/bar"}},
+	} {
+		t.Run(tc.pkg, func(t *testing.T) {
+			got, err := Conventions{}.Candidates(context.Background(), rebuild.Target{Package: tc.pkg})
+			if err != nil {
+				t.Fatalf("Candidates() error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Candidates() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDepsDev(t *testing.T) {
+	client := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://api.deps.dev/v3/systems/npm/packages/@babel%2Fcore/versions/7.0.0",
+				Response: response(`{
+					"links": [
+						{"label": "HOMEPAGE", "url": "https://babeljs.io"},
+						{"label": "SOURCE_REPO", "url": "git+https://github.com/babel/babel.git"}
+					],
+					"relatedProjects": [
+						{"projectKey": {"id": "github.com/babel/babel"}, "relationType": "SOURCE_REPO"},
+						{"projectKey": {"id": "github.com/babel/other"}, "relationType": "ISSUE_TRACKER"}
+					]
+				}`),
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if expected != actual {
+				t.Errorf("URL mismatch: want=%s got=%s", expected, actual)
+			}
+		},
+	}
+	got, err := DepsDev{Client: client}.Candidates(context.Background(), rebuild.Target{Ecosystem: rebuild.NPM, Package: "@babel/core", Version: "7.0.0"})
+	if err != nil {
+		t.Fatalf("Candidates() error: %v", err)
+	}
+	want := []string{"https://github.com/babel/babel", "git+https://github.com/babel/babel.git"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Candidates() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+type staticSource []string
+
+func (staticSource) Name() string { return "static" }
+
+func (s staticSource) Candidates(context.Context, rebuild.Target) ([]string, error) { return s, nil }
+
+func TestCandidates(t *testing.T) {
+	d := &Discoverer{
+		Sources: []Source{
+			staticSource{"https://github.com/Foo/Bar.git", "not a url"},
+			staticSource{"github.com/foo/bar", "https://github.com/foo/old", "https://github.com/foo/baz", "https://github.com/foo/qux"},
+		},
+		MaxCandidates: 2,
+	}
+	got := d.candidates(context.Background(), rebuild.Target{}, []string{"https://github.com/foo/old"})
+	want := []string{"https://github.com/foo/bar", "https://github.com/foo/baz"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("candidates() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repodiscovery
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/google/oss-rebuild/internal/gitx"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// openArtifact returns the upstream artifact of the target along with its format.
+func openArtifact(ctx context.Context, mux rebuild.RegistryMux, t rebuild.Target) (io.ReadCloser, archive.Format, error) {
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		r, err := mux.NPM.Artifact(ctx, t.Package, t.Version)
+		return r, archive.TarGzFormat, err
+	case rebuild.CratesIO:
+		r, err := mux.CratesIO.Artifact(ctx, t.Package, t.Version)
+		return r, archive.TarGzFormat, err
+	case rebuild.PyPI:
+		name := t.Artifact
+		if name == "" {
+			release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+			if err != nil {
+				return nil, archive.UnknownFormat, err
+			}
+			// NOTE: Prefer the sdist since its layout most closely mirrors the repository.
+			for _, a := range release.Artifacts {
+				if strings.HasSuffix(a.Filename, ".tar.gz") || (name == "" && strings.HasSuffix(a.Filename, ".whl")) {
+					name = a.Filename
+				}
+			}
+			if name == "" {
+				return nil, archive.UnknownFormat, errors.New("no supported artifact")
+			}
+		}
+		f := archive.TarGzFormat
+		if strings.HasSuffix(name, ".whl") || strings.HasSuffix(name, ".zip") {
+			f = archive.ZipFormat
+		}
+		r, err := mux.PyPI.Artifact(ctx, t.Package, t.Version, name)
+		return r, f, err
+	default:
+		return nil, archive.UnknownFormat, errors.Errorf("unsupported ecosystem: %s", t.Ecosystem)
+	}
+}
+
+// artifactBlobs returns the git blob hashes of the files in the target's upstream artifact.
+func artifactBlobs(ctx context.Context, mux rebuild.RegistryMux, t rebuild.Target) (map[plumbing.Hash]bool, error) {
+	r, f, err := openArtifact(ctx, mux, t)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return blobHashes(r, f)
+}
+
+// blobHashes returns the git blob hashes of the non-empty regular files in the archive.
+func blobHashes(r io.Reader, f archive.Format) (map[plumbing.Hash]bool, error) {
+	hashes := make(map[plumbing.Hash]bool)
+	add := func(r io.Reader) error {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if len(b) > 0 {
+			hashes[plumbing.ComputeHash(plumbing.BlobObject, b)] = true
+		}
+		return nil
+	}
+	switch f {
+	case archive.TarGzFormat:
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing gzip reader")
+		}
+		defer gzr.Close()
+		tr := tar.NewReader(gzr)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, errors.Wrap(err, "reading tar")
+			}
+			if h.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := add(tr); err != nil {
+				return nil, errors.Wrapf(err, "reading %s", h.Name)
+			}
+		}
+	case archive.ZipFormat:
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading zip")
+		}
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing zip reader")
+		}
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, errors.Wrapf(err, "opening %s", zf.Name)
+			}
+			err = add(rc)
+			rc.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading %s", zf.Name)
+			}
+		}
+	default:
+		return nil, errors.New("unsupported archive type")
+	}
+	return hashes, nil
+}
+
+// Score returns the fraction of blobs present at the head of the repository's default branch.
+func Score(ctx context.Context, repoURI string, blobs map[plumbing.Hash]bool) (float64, error) {
+	if len(blobs) == 0 {
+		return 0, nil
+	}
+	// NOTE: Only the latest commit is fetched to bound the cost of validating
+	// each candidate. Most files are unchanged between releases so a correct
+	// repository matches well even when the release predates the head.
+	repo, err := gitx.Clone(ctx, memory.NewStorage(), nil, &git.CloneOptions{URL: repoURI, Depth: 1, SingleBranch: true, Tags: git.NoTags})
+	if err != nil {
+		return 0, errors.Wrap(err, "cloning repo")
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return 0, errors.Wrap(err, "resolving head")
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return 0, errors.Wrap(err, "reading head commit")
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return 0, errors.Wrap(err, "reading tree")
+	}
+	matched := make(map[plumbing.Hash]bool)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if blobs[f.Hash] {
+			matched[f.Hash] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "walking tree")
+	}
+	return float64(len(matched)) / float64(len(blobs)), nil
+}
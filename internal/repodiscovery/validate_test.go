@@ -0,0 +1,58 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repodiscovery
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/archive"
+)
+
+func TestBlobHashes(t *testing.T) {
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for _, f := range []struct {
+		name string
+		body string
+		typ  byte
+	}{
+		{"package/", "", tar.TypeDir},
+		{"package/index.js", "module.exports = 1;\n", tar.TypeReg},
+		{"package/empty", "", tar.TypeReg},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: f.typ, Size: int64(len(f.body)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gzw.Close()
+	got, err := blobHashes(buf, archive.TarGzFormat)
+	if err != nil {
+		t.Fatalf("blobHashes() error: %v", err)
+	}
+	want := map[plumbing.Hash]bool{plumbing.ComputeHash(plumbing.BlobObject, []byte("module.exports = 1;\n")): true}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("blobHashes() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sbom maps the components of Software Bills of Materials to rebuild targets.
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/pkgname"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// Format identifies the SBOM standard to which a document conforms.
+type Format string
+
+// Supported SBOM formats. Only the JSON encodings are supported.
+const (
+	CycloneDX Format = "CycloneDX"
+	SPDX      Format = "SPDX"
+)
+
+// Component is a software component listed in an SBOM.
+type Component struct {
+	Name    string
+	Version string
+	// PURL is the Package URL identifying the component, if provided.
+	PURL string
+}
+
+// Document is the content of an SBOM relevant to rebuilding its components.
+type Document struct {
+	Format     Format
+	Components []Component
+}
+
+// ErrUnsupported is returned for components that cannot be mapped to a rebuild target.
+var ErrUnsupported = errors.New("unsupported component")
+
+type cycloneDXComponent struct {
+	Name       string               `json:"name"`
+	Version    string               `json:"version"`
+	PURL       string               `json:"purl"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXDocument struct {
+	BOMFormat  string               `json:"bomFormat"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type spdxPackage struct {
+	Name         string `json:"name"`
+	VersionInfo  string `json:"versionInfo"`
+	ExternalRefs []struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	} `json:"externalRefs"`
+}
+
+type spdxDocument struct {
+	SPDXVersion string        `json:"spdxVersion"`
+	Packages    []spdxPackage `json:"packages"`
+}
+
+// Parse reads a CycloneDX or SPDX JSON document, detecting its format from its content.
+func Parse(r io.Reader) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading SBOM")
+	}
+	var probe struct {
+		BOMFormat   string `json:"bomFormat"`
+		SPDXVersion string `json:"spdxVersion"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return nil, errors.Wrap(err, "parsing SBOM")
+	}
+	switch {
+	case probe.BOMFormat == string(CycloneDX):
+		var d cycloneDXDocument
+		if err := json.Unmarshal(b, &d); err != nil {
+			return nil, errors.Wrap(err, "parsing CycloneDX SBOM")
+		}
+		doc := &Document{Format: CycloneDX}
+		var walk func([]cycloneDXComponent)
+		walk = func(cs []cycloneDXComponent) {
+			for _, c := range cs {
+				doc.Components = append(doc.Components, Component{Name: c.Name, Version: c.Version, PURL: c.PURL})
+				walk(c.Components)
+			}
+		}
+		walk(d.Components)
+		return doc, nil
+	case strings.HasPrefix(probe.SPDXVersion, "SPDX-"):
+		var d spdxDocument
+		if err := json.Unmarshal(b, &d); err != nil {
+			return nil, errors.Wrap(err, "parsing SPDX SBOM")
+		}
+		doc := &Document{Format: SPDX}
+		for _, p := range d.Packages {
+			c := Component{Name: p.Name, Version: p.VersionInfo}
+			for _, ref := range p.ExternalRefs {
+				if ref.ReferenceType == "purl" {
+					c.PURL = ref.ReferenceLocator
+					break
+				}
+			}
+			doc.Components = append(doc.Components, c)
+		}
+		return doc, nil
+	default:
+		return nil, errors.New("unrecognized SBOM format")
+	}
+}
+
+// Targets returns the rebuild targets for the document's components.
+// Components that cannot be mapped to a target are returned separately.
+// Components listed more than once produce a single target.
+func (d *Document) Targets() (targets []rebuild.Target, unsupported []Component) {
+	seen := make(map[rebuild.Target]bool)
+	for _, c := range d.Components {
+		t, err := TargetFromPURL(c.PURL)
+		if err != nil {
+			unsupported = append(unsupported, c)
+			continue
+		}
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	return targets, unsupported
+}
+
+// TargetFromPURL returns the rebuild target identified by a Package URL.
+//
+// See https://github.com/package-url/purl-spec for the format.
+func TargetFromPURL(purl string) (rebuild.Target, error) {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return rebuild.Target{}, errors.Wrapf(ErrUnsupported, "not a purl: %q", purl)
+	}
+	// Qualifiers and subpath do not affect the identity of the package version.
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+	rest, version, ok := strings.Cut(rest, "@")
+	if !ok || version == "" {
+		return rebuild.Target{}, errors.Wrapf(ErrUnsupported, "no version: %q", purl)
+	}
+	version, err := url.PathUnescape(version)
+	if err != nil {
+		return rebuild.Target{}, errors.Wrap(err, "decoding version")
+	}
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	for i, s := range segments {
+		if segments[i], err = url.PathUnescape(s); err != nil {
+			return rebuild.Target{}, errors.Wrap(err, "decoding path")
+		}
+	}
+	if len(segments) < 2 {
+		return rebuild.Target{}, errors.Wrapf(ErrUnsupported, "no name: %q", purl)
+	}
+	typ, namespace, name := strings.ToLower(segments[0]), segments[1:len(segments)-1], segments[len(segments)-1]
+	switch typ {
+	case "npm":
+		pkg := name
+		if len(namespace) > 0 {
+			pkg = strings.Join(namespace, "/") + "/" + name
+		}
+		return rebuild.Target{Ecosystem: rebuild.NPM, Package: pkg, Version: version}, nil
+	case "pypi":
+		pkg, err := pkgname.Normalize(rebuild.PyPI, name)
+		if err != nil {
+			return rebuild.Target{}, err
+		}
+		return rebuild.Target{Ecosystem: rebuild.PyPI, Package: pkg, Version: version}, nil
+	case "cargo":
+		return rebuild.Target{Ecosystem: rebuild.CratesIO, Package: name, Version: version}, nil
+	case "maven":
+		if len(namespace) == 0 {
+			return rebuild.Target{}, errors.Wrapf(ErrUnsupported, "no maven group: %q", purl)
+		}
+		return rebuild.Target{Ecosystem: rebuild.Maven, Package: strings.Join(namespace, ".") + ":" + name, Version: version}, nil
+	default:
+		return rebuild.Target{}, errors.Wrapf(ErrUnsupported, "unsupported type %q", typ)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestTargetFromPURL(t *testing.T) {
+	for _, tc := range []struct {
+		purl string
+		want rebuild.Target
+	}{
+		{"pkg:npm/left-pad@1.3.0", rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0"}},
+		{"pkg:npm/%40babel/core@7.24.0", rebuild.Target{Ecosystem: rebuild.NPM, Package: "@babel/core", Version: "7.24.0"}},
+		{"pkg:pypi/Django_Rest@3.15.1?extension=whl", rebuild.Target{Ecosystem: rebuild.PyPI, Package: "django-rest", Version: "3.15.1"}},
+		{"pkg:cargo/serde@1.0.197", rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "serde", Version: "1.0.197"}},
+		{"pkg:maven/org.apache.commons/commons-lang3@3.12.0#sub", rebuild.Target{Ecosystem: rebuild.Maven, Package: "org.apache.commons:commons-lang3", Version: "3.12.0"}},
+	} {
+		got, err := TargetFromPURL(tc.purl)
+		if err != nil {
+			t.Errorf("TargetFromPURL(%q) error: %v", tc.purl, err)
+			continue
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("TargetFromPURL(%q) mismatch (-want +got):\n%s", tc.purl, diff)
+		}
+	}
+	for _, purl := range []string{"", "pkg:golang/golang.org/x/mod@v0.17.0", "pkg:npm/left-pad", "pkg:maven/commons-lang3@3.12.0"} {
+		if _, err := TargetFromPURL(purl); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("TargetFromPURL(%q) = %v, want ErrUnsupported", purl, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		doc             string
+		wantFormat      Format
+		wantTargets     []rebuild.Target
+		wantUnsupported []string
+	}{
+		{
+			name: "cyclonedx",
+			doc: `{"bomFormat": "CycloneDX", "specVersion": "1.5", "components": [
+				{"name": "left-pad", "version": "1.3.0", "purl": "pkg:npm/left-pad@1.3.0", "components": [
+					{"name": "serde", "version": "1.0.197", "purl": "pkg:cargo/serde@1.0.197"}
+				]},
+				{"name": "left-pad", "version": "1.3.0", "purl": "pkg:npm/left-pad@1.3.0"},
+				{"name": "internal-lib", "version": "0.1.0"}
+			]}`,
+			wantFormat: CycloneDX,
+			wantTargets: []rebuild.Target{
+				{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0"},
+				{Ecosystem: rebuild.CratesIO, Package: "serde", Version: "1.0.197"},
+			},
+			wantUnsupported: []string{"internal-lib"},
+		},
+		{
+			name: "spdx",
+			doc: `{"spdxVersion": "SPDX-2.3", "packages": [
+				{"name": "absl-py", "versionInfo": "2.0.0", "externalRefs": [
+					{"referenceCategory": "SECURITY", "referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:a:absl:absl-py:2.0.0"},
+					{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:pypi/absl-py@2.0.0"}
+				]},
+				{"name": "mod", "versionInfo": "v0.17.0", "externalRefs": [
+					{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:golang/golang.org/x/mod@v0.17.0"}
+				]}
+			]}`,
+			wantFormat: SPDX,
+			wantTargets: []rebuild.Target{
+				{Ecosystem: rebuild.PyPI, Package: "absl-py", Version: "2.0.0"},
+			},
+			wantUnsupported: []string{"mod"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.doc))
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if doc.Format != tc.wantFormat {
+				t.Errorf("Parse() format = %s, want %s", doc.Format, tc.wantFormat)
+			}
+			targets, unsupported := doc.Targets()
+			if diff := cmp.Diff(tc.wantTargets, targets); diff != "" {
+				t.Errorf("Targets() mismatch (-want +got):\n%s", diff)
+			}
+			var names []string
+			for _, c := range unsupported {
+				names = append(names, c.Name)
+			}
+			if diff := cmp.Diff(tc.wantUnsupported, names); diff != "" {
+				t.Errorf("Targets() unsupported mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+	if _, err := Parse(strings.NewReader(`{"name": "not an sbom"}`)); err == nil {
+		t.Error("Parse() expected error for unrecognized format")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// RetryPolicy controls the redelivery of tasks whose delivery fails.
+type RetryPolicy struct {
+	// MaxAttempts is the number of deliveries attempted before a task is dead-lettered.
+	// Values below one are treated as a single attempt.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry. It doubles for each subsequent retry.
+	MinBackoff time.Duration
+	// MaxBackoff, if non-zero, caps the delay between retries.
+	MaxBackoff time.Duration
+}
+
+// backoff returns the delay before the delivery following the provided attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff != 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// FailureReason describes the final failed delivery of a task.
+type FailureReason struct {
+	// StatusCode is the HTTP status of the response, or zero if none was received.
+	StatusCode int `firestore:"status_code"`
+	// Message describes the failure.
+	Message string `firestore:"message"`
+}
+
+// retryable returns whether redelivery could succeed.
+func (r FailureReason) retryable() bool {
+	switch {
+	case r.StatusCode == 0, r.StatusCode >= 500:
+		return true
+	case r.StatusCode == http.StatusRequestTimeout, r.StatusCode == http.StatusTooManyRequests:
+		return true
+	default:
+		return false
+	}
+}
+
+// DeadLetter is a task that could not be delivered.
+type DeadLetter struct {
+	Name     string        `firestore:"name"`
+	URL      string        `firestore:"url"`
+	Body     []byte        `firestore:"body"`
+	Headers  http.Header   `firestore:"headers"`
+	Group    string        `firestore:"group"`
+	Attempts int           `firestore:"attempts"`
+	Reason   FailureReason `firestore:"reason"`
+	Failed   time.Time     `firestore:"failed"`
+}
+
+// DeadLetterStore retains tasks that exhausted their delivery attempts so they can be resubmitted.
+type DeadLetterStore interface {
+	// Add records a dead-lettered task, replacing any existing one with the same name.
+	Add(ctx context.Context, dl DeadLetter) error
+	// List returns all recorded dead-lettered tasks ordered by failure time.
+	List(ctx context.Context) ([]DeadLetter, error)
+	// Remove deletes the dead-lettered task with the provided name.
+	Remove(ctx context.Context, name string) error
+}
+
+// MemoryDeadLetters is an in-process DeadLetterStore.
+type MemoryDeadLetters struct {
+	mu      sync.Mutex
+	letters map[string]DeadLetter
+}
+
+var _ DeadLetterStore = &MemoryDeadLetters{}
+
+// Add records a dead-lettered task.
+func (m *MemoryDeadLetters) Add(ctx context.Context, dl DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.letters == nil {
+		m.letters = make(map[string]DeadLetter)
+	}
+	m.letters[dl.Name] = dl
+	return nil
+}
+
+// List returns all recorded dead-lettered tasks ordered by failure time.
+func (m *MemoryDeadLetters) List(ctx context.Context) ([]DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var dls []DeadLetter
+	for _, dl := range m.letters {
+		dls = append(dls, dl)
+	}
+	sort.Slice(dls, func(i, j int) bool { return dls[i].Failed.Before(dls[j].Failed) })
+	return dls, nil
+}
+
+// Remove deletes the dead-lettered task with the provided name.
+func (m *MemoryDeadLetters) Remove(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.letters, name)
+	return nil
+}
+
+// FirestoreDeadLetters is a DeadLetterStore backed by a Firestore collection.
+type FirestoreDeadLetters struct {
+	Client *firestore.Client
+}
+
+var _ DeadLetterStore = &FirestoreDeadLetters{}
+
+const deadLetterCollection = "dead_letters"
+
+func (f *FirestoreDeadLetters) doc(name string) *firestore.DocumentRef {
+	return f.Client.Collection(deadLetterCollection).Doc(strings.ReplaceAll(name, "/", "!"))
+}
+
+// Add records a dead-lettered task.
+func (f *FirestoreDeadLetters) Add(ctx context.Context, dl DeadLetter) error {
+	if _, err := f.doc(dl.Name).Set(ctx, dl); err != nil {
+		return errors.Wrap(err, "writing dead letter")
+	}
+	return nil
+}
+
+// List returns all recorded dead-lettered tasks ordered by failure time.
+func (f *FirestoreDeadLetters) List(ctx context.Context) ([]DeadLetter, error) {
+	iter := f.Client.Collection(deadLetterCollection).OrderBy("failed", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	var dls []DeadLetter
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "listing dead letters")
+		}
+		var dl DeadLetter
+		if err := doc.DataTo(&dl); err != nil {
+			return nil, errors.Wrapf(err, "reading dead letter %s", doc.Ref.ID)
+		}
+		dls = append(dls, dl)
+	}
+	return dls, nil
+}
+
+// Remove deletes the dead-lettered task with the provided name.
+func (f *FirestoreDeadLetters) Remove(ctx context.Context, name string) error {
+	if _, err := f.doc(name).Delete(ctx); err != nil {
+		return errors.Wrap(err, "deleting dead letter")
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/internal/telemetry"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+)
+
+// LocalQueue is an in-process Queue that delivers tasks using a pool of goroutines.
+//
+// Tasks are not persisted so any tasks pending when the process exits are lost.
+// Task names are deduplicated for the lifetime of the queue.
+type LocalQueue struct {
+	client  httpx.BasicClient
+	policy  Policy
+	sched   *scheduler
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	closed  bool
+	counter int
+	names   map[string]bool
+	// timers holds the tasks waiting for their ScheduleTime, including retries.
+	timers map[*Task]*time.Timer
+}
+
+var _ Queue = &LocalQueue{}
+
+// NewLocalQueue creates a LocalQueue that delivers tasks with client using the provided number of workers.
+// Workers stop once ctx is cancelled or the queue is closed. Cancelling ctx
+// also closes the queue and discards any tasks not yet delivered.
+func NewLocalQueue(ctx context.Context, client httpx.BasicClient, workers int) *LocalQueue {
+	return NewLocalQueueWithPolicy(ctx, client, workers, Policy{})
+}
+
+// NewLocalQueueWithPolicy creates a LocalQueue whose delivery order and concurrency is governed by policy.
+func NewLocalQueueWithPolicy(ctx context.Context, client httpx.BasicClient, workers int, policy Policy) *LocalQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &LocalQueue{client: client, policy: policy, sched: newScheduler(policy), names: make(map[string]bool), timers: make(map[*Task]*time.Timer)}
+	for i := 0; i < workers; i++ {
+		go q.work(ctx)
+	}
+	context.AfterFunc(ctx, q.abort)
+	return q
+}
+
+// abort closes the queue and discards all undelivered tasks.
+// Tasks being delivered are left to the workers to complete.
+func (q *LocalQueue) abort() {
+	q.mu.Lock()
+	q.closed = true
+	q.stopTimersLocked()
+	q.mu.Unlock()
+	dropped := q.sched.drain()
+	if len(dropped) > 0 {
+		log.Printf("discarding %d pending tasks", len(dropped))
+	}
+	for range dropped {
+		q.wg.Done()
+	}
+	q.sched.close()
+}
+
+// scheduleLocked submits t for delivery once its ScheduleTime is reached.
+// The caller must hold q.mu.
+func (q *LocalQueue) scheduleLocked(t *Task) {
+	delay := time.Until(t.ScheduleTime)
+	if delay <= 0 {
+		q.sched.push(t)
+		return
+	}
+	q.timers[t] = time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		// NOTE: A timer that fires concurrently with being stopped will find its task removed.
+		if _, ok := q.timers[t]; !ok {
+			return
+		}
+		delete(q.timers, t)
+		q.sched.push(t)
+	})
+}
+
+// stopTimersLocked discards the tasks waiting for their ScheduleTime.
+// The caller must hold q.mu.
+func (q *LocalQueue) stopTimersLocked() {
+	if len(q.timers) > 0 {
+		log.Printf("discarding %d scheduled tasks", len(q.timers))
+	}
+	for t, timer := range q.timers {
+		timer.Stop()
+		delete(q.timers, t)
+		q.wg.Done()
+	}
+}
+
+func (q *LocalQueue) work(ctx context.Context) {
+	for {
+		t := q.sched.pop(ctx)
+		if t == nil {
+			return
+		}
+		telemetry.RecordQueueLatency(ctx, "memory", time.Since(t.readyTime()))
+		t.Attempts++
+		reason, err := q.deliver(ctx, t)
+		q.sched.done(t)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("task %s failed: %v", t.Name, err)
+			q.fail(ctx, t, reason)
+		}
+		q.wg.Done()
+	}
+}
+
+// fail schedules a retry of the task or, if none remain or the queue is
+// closed, records it as dead-lettered.
+func (q *LocalQueue) fail(ctx context.Context, t *Task, reason FailureReason) {
+	if reason.retryable() && t.Attempts < q.policy.Retry.MaxAttempts {
+		q.mu.Lock()
+		if !q.closed {
+			q.wg.Add(1)
+			t.ScheduleTime = time.Now().Add(q.policy.Retry.backoff(t.Attempts))
+			q.scheduleLocked(t)
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+	}
+	if q.policy.DeadLetters == nil {
+		return
+	}
+	dl := DeadLetter{
+		Name:     t.Name,
+		URL:      t.URL,
+		Body:     t.Body,
+		Headers:  t.Headers,
+		Group:    t.Group,
+		Attempts: t.Attempts,
+		Reason:   reason,
+		Failed:   time.Now().UTC(),
+	}
+	if err := q.policy.DeadLetters.Add(ctx, dl); err != nil {
+		log.Printf("dead-lettering task %s: %v", t.Name, err)
+	}
+}
+
+func (q *LocalQueue) deliver(ctx context.Context, t *Task) (FailureReason, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(t.Body))
+	if err != nil {
+		err = errors.Wrap(err, "building http request")
+		return FailureReason{Message: err.Error()}, err
+	}
+	for k, vs := range t.Headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := q.client.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "making http request")
+		return FailureReason{Message: err.Error()}, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(body))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return FailureReason{StatusCode: resp.StatusCode, Message: msg}, errors.Errorf("non-OK response: %s", resp.Status)
+	}
+	return FailureReason{}, nil
+}
+
+// Add enqueues msg for delivery to url.
+func (q *LocalQueue) Add(ctx context.Context, url string, msg schema.Message, opts TaskOptions) (*Task, error) {
+	body, err := encode(msg)
+	if err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, errors.New("queue closed")
+	}
+	name := opts.Name
+	if name == "" {
+		q.counter++
+		name = fmt.Sprintf("local-%d", q.counter)
+	}
+	if q.names[name] {
+		return nil, errors.Wrap(ErrTaskExists, name)
+	}
+	q.names[name] = true
+	t := &Task{
+		Name:         name,
+		URL:          url,
+		Body:         body,
+		ScheduleTime: opts.ScheduleTime,
+		Headers:      opts.Headers.Clone(),
+		Priority:     opts.Priority,
+		Group:        opts.Group,
+		added:        time.Now(),
+	}
+	q.wg.Add(1)
+	q.scheduleLocked(t)
+	return t, nil
+}
+
+// Wait blocks until all tasks added to the queue, including those scheduled
+// for later delivery and their retries, have been delivered or discarded.
+func (q *LocalQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Close stops accepting new tasks, discards those scheduled for later
+// delivery, and waits for the remaining tasks to be delivered.
+// Tasks that fail once the queue is closed are not retried.
+func (q *LocalQueue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.stopTimersLocked()
+	q.mu.Unlock()
+	q.wg.Wait()
+	q.sched.close()
+}
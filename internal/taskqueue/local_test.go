@@ -0,0 +1,231 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+)
+
+func TestLocalQueue(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm(): %v", err)
+		}
+		mu.Lock()
+		got = append(got, r.URL.Path+"?"+r.Form.Encode())
+		mu.Unlock()
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	q, err := MakeQueue(ctx, Config{Kind: "memory", Workers: 2}, server.Client())
+	if err != nil {
+		t.Fatalf("MakeQueue() error: %v", err)
+	}
+	for _, svc := range []string{"a", "b", "c"} {
+		if _, err := q.Add(ctx, server.URL+"/version", schema.VersionRequest{Service: svc}, TaskOptions{}); err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+	q.(*LocalQueue).Close()
+	sort.Strings(got)
+	want := []string{"/version?schema_version=1&service=a", "/version?schema_version=1&service=b", "/version?schema_version=1&service=c"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("delivered requests mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := q.Add(ctx, server.URL, schema.VersionRequest{}, TaskOptions{}); err == nil {
+		t.Error("Add() after Close() expected error")
+	}
+}
+
+func TestLocalQueueOptions(t *testing.T) {
+	delivered := make(chan time.Time, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Attempt"); got != "1" {
+			t.Errorf("X-Attempt header: want=1 got=%q", got)
+		}
+		delivered <- time.Now()
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	q := NewLocalQueue(ctx, server.Client(), 1)
+	start := time.Now()
+	opts := TaskOptions{
+		Name:         "rebuild-foo",
+		ScheduleTime: start.Add(100 * time.Millisecond),
+		Headers:      http.Header{"X-Attempt": []string{"1"}},
+	}
+	task, err := q.Add(ctx, server.URL, schema.VersionRequest{}, opts)
+	if err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if task.Name != "rebuild-foo" {
+		t.Errorf("Task.Name: want=rebuild-foo got=%s", task.Name)
+	}
+	if _, err := q.Add(ctx, server.URL, schema.VersionRequest{}, TaskOptions{Name: "rebuild-foo"}); !errors.Is(err, ErrTaskExists) {
+		t.Errorf("Add() with duplicate name: want=%v got=%v", ErrTaskExists, err)
+	}
+	q.Wait()
+	q.Close()
+	select {
+	case at := <-delivered:
+		if at.Before(opts.ScheduleTime) {
+			t.Errorf("task delivered %v before ScheduleTime", opts.ScheduleTime.Sub(at))
+		}
+	default:
+		t.Error("scheduled task was not delivered before Wait() returned")
+	}
+}
+
+func TestLocalQueueDeadLetter(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/unavailable":
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		case "/invalid":
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	dls := &MemoryDeadLetters{}
+	policy := Policy{Retry: RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}, DeadLetters: dls}
+	q := NewLocalQueueWithPolicy(ctx, server.Client(), 1, policy)
+	for _, name := range []string{"unavailable", "invalid", "ok"} {
+		if _, err := q.Add(ctx, server.URL+"/"+name, schema.VersionRequest{}, TaskOptions{Name: name}); err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+	q.Wait()
+	q.Close()
+	// Only server errors are retried.
+	if diff := cmp.Diff(map[string]int{"/unavailable": 3, "/invalid": 1, "/ok": 1}, requests); diff != "" {
+		t.Errorf("delivery attempts mismatch (-want +got):\n%s", diff)
+	}
+	got, err := dls.List(ctx)
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+	var summary []string
+	for _, dl := range got {
+		summary = append(summary, fmt.Sprintf("%s attempts=%d status=%d %s", dl.Name, dl.Attempts, dl.Reason.StatusCode, dl.Reason.Message))
+	}
+	want := []string{
+		"invalid attempts=1 status=400 bad request",
+		"unavailable attempts=3 status=503 try later",
+	}
+	if diff := cmp.Diff(want, summary); diff != "" {
+		t.Errorf("dead letters mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// returnsWithin fails the test if f does not return within d.
+func returnsWithin(t *testing.T, d time.Duration, name string, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("%s did not return within %v", name, d)
+	}
+}
+
+func TestLocalQueueCloseDiscardsScheduled(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	attempted := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/unavailable" {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			attempted <- struct{}{}
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	policy := Policy{Retry: RetryPolicy{MaxAttempts: 3, MinBackoff: 10 * time.Minute}}
+	q := NewLocalQueueWithPolicy(ctx, server.Client(), 1, policy)
+	if _, err := q.Add(ctx, server.URL+"/later", schema.VersionRequest{}, TaskOptions{ScheduleTime: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if _, err := q.Add(ctx, server.URL+"/unavailable", schema.VersionRequest{}, TaskOptions{}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	<-attempted
+	returnsWithin(t, 5*time.Second, "Close()", q.Close)
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff(map[string]int{"/unavailable": 1}, requests); diff != "" {
+		t.Errorf("delivery attempts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLocalQueueCancel(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	q := NewLocalQueue(ctx, server.Client(), 1)
+	for i := 0; i < 3; i++ {
+		if _, err := q.Add(ctx, server.URL, schema.VersionRequest{}, TaskOptions{}); err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+	if _, err := q.Add(ctx, server.URL, schema.VersionRequest{}, TaskOptions{ScheduleTime: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	<-started
+	cancel()
+	returnsWithin(t, 5*time.Second, "Wait()", q.Wait)
+	returnsWithin(t, 5*time.Second, "Close()", q.Close)
+	if _, err := q.Add(context.Background(), server.URL, schema.VersionRequest{}, TaskOptions{}); err == nil {
+		t.Error("Add() after cancellation expected error")
+	}
+}
+
+func TestMakeQueueUnknown(t *testing.T) {
+	if _, err := MakeQueue(context.Background(), Config{Kind: "carrier-pigeon"}, nil); err == nil {
+		t.Error("MakeQueue() expected error")
+	}
+}
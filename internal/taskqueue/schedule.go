@@ -0,0 +1,203 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Priority is the scheduling class of a task.
+// Pending tasks of a higher priority are always delivered before those of a lower one.
+type Priority int
+
+const (
+	// PriorityBackfill is the default priority, for bulk work with no urgency.
+	PriorityBackfill Priority = iota
+	// PriorityNewRelease is for rebuilds of fresh releases which should be verified promptly.
+	PriorityNewRelease
+)
+
+// Policy controls the order and concurrency of task delivery across groups.
+//
+// Within a priority class, groups are served round-robin so that a group with
+// a large backlog does not starve the others.
+type Policy struct {
+	// GroupLimits caps the number of concurrent deliveries for tasks of a group.
+	// Groups without a limit are bounded only by the number of workers.
+	GroupLimits map[string]int
+	// Retry controls the redelivery of failed tasks.
+	Retry RetryPolicy
+	// DeadLetters, if provided, records tasks that exhaust their delivery attempts.
+	DeadLetters DeadLetterStore
+}
+
+// ParseGroupLimits parses a comma-separated list of "<group>=<limit>" pairs.
+func ParseGroupLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	if s == "" {
+		return limits, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		group, limit, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, errors.Errorf("malformed group limit: %q", pair)
+		}
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, errors.Errorf("invalid limit for group %s: %q", group, limit)
+		}
+		limits[group] = n
+	}
+	return limits, nil
+}
+
+// class is the set of pending tasks of a single priority.
+type class struct {
+	// groups is the round-robin order in which groups are served.
+	groups []string
+	next   int
+	tasks  map[string][]*Task
+}
+
+// scheduler orders pending tasks by priority and fair share across groups.
+type scheduler struct {
+	policy  Policy
+	mu      sync.Mutex
+	cond    *sync.Cond
+	classes map[Priority]*class
+	active  map[string]int
+	closed  bool
+}
+
+func newScheduler(policy Policy) *scheduler {
+	s := &scheduler{policy: policy, classes: make(map[Priority]*class), active: make(map[string]int)}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// push adds a task to be delivered.
+func (s *scheduler) push(t *Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.classes[t.Priority]
+	if !ok {
+		c = &class{tasks: make(map[string][]*Task)}
+		s.classes[t.Priority] = c
+	}
+	if _, ok := c.tasks[t.Group]; !ok {
+		c.groups = append(c.groups, t.Group)
+	}
+	c.tasks[t.Group] = append(c.tasks[t.Group], t)
+	s.cond.Broadcast()
+}
+
+// available returns whether another task from group may be delivered.
+func (s *scheduler) available(group string) bool {
+	limit, ok := s.policy.GroupLimits[group]
+	return !ok || s.active[group] < limit
+}
+
+// take removes and returns the next deliverable task, if any.
+func (s *scheduler) take() *Task {
+	priorities := make([]Priority, 0, len(s.classes))
+	for p := range s.classes {
+		priorities = append(priorities, p)
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] > priorities[j] })
+	for _, p := range priorities {
+		c := s.classes[p]
+		for i := 0; i < len(c.groups); i++ {
+			idx := (c.next + i) % len(c.groups)
+			group := c.groups[idx]
+			if !s.available(group) {
+				continue
+			}
+			t := c.tasks[group][0]
+			c.tasks[group] = c.tasks[group][1:]
+			if len(c.tasks[group]) == 0 {
+				delete(c.tasks, group)
+				c.groups = append(c.groups[:idx], c.groups[idx+1:]...)
+				if len(c.groups) == 0 {
+					delete(s.classes, p)
+				} else {
+					c.next = idx % len(c.groups)
+				}
+			} else {
+				c.next = (idx + 1) % len(c.groups)
+			}
+			s.active[group]++
+			return t
+		}
+	}
+	return nil
+}
+
+// pop blocks until a task may be delivered, returning nil once the scheduler
+// is closed or ctx is cancelled.
+func (s *scheduler) pop(ctx context.Context) *Task {
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cond.Broadcast()
+	})
+	defer stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if s.closed || ctx.Err() != nil {
+			return nil
+		}
+		if t := s.take(); t != nil {
+			return t
+		}
+		s.cond.Wait()
+	}
+}
+
+// done records that delivery of a task returned by pop has completed.
+func (s *scheduler) done(t *Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[t.Group]--
+	s.cond.Broadcast()
+}
+
+// drain removes and returns all tasks not yet taken for delivery.
+func (s *scheduler) drain() []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tasks []*Task
+	for _, c := range s.classes {
+		for _, group := range c.groups {
+			tasks = append(tasks, c.tasks[group]...)
+		}
+	}
+	s.classes = make(map[Priority]*class)
+	return tasks
+}
+
+// close releases any workers blocked in pop.
+func (s *scheduler) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+}
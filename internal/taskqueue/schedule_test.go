@@ -0,0 +1,70 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSchedulerOrder(t *testing.T) {
+	s := newScheduler(Policy{GroupLimits: map[string]int{"npm": 1}})
+	for _, task := range []*Task{
+		{Name: "debian-1", Group: "debian"},
+		{Name: "debian-2", Group: "debian"},
+		{Name: "debian-3", Group: "debian"},
+		{Name: "pypi-1", Group: "pypi"},
+		{Name: "npm-new-1", Group: "npm", Priority: PriorityNewRelease},
+		{Name: "npm-new-2", Group: "npm", Priority: PriorityNewRelease},
+		{Name: "pypi-new-1", Group: "pypi", Priority: PriorityNewRelease},
+	} {
+		s.push(task)
+	}
+	ctx := context.Background()
+	var got []string
+	for i := 0; i < 6; i++ {
+		got = append(got, s.pop(ctx).Name)
+	}
+	// npm-new-2 is held back by the npm limit until npm-new-1 completes.
+	want := []string{"npm-new-1", "pypi-new-1", "debian-1", "pypi-1", "debian-2", "debian-3"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("delivery order mismatch (-want +got):\n%s", diff)
+	}
+	s.done(&Task{Group: "npm"})
+	if got := s.pop(ctx).Name; got != "npm-new-2" {
+		t.Errorf("pop() after done: want=npm-new-2 got=%s", got)
+	}
+	s.close()
+	if got := s.pop(ctx); got != nil {
+		t.Errorf("pop() after close: want=nil got=%v", got)
+	}
+}
+
+func TestParseGroupLimits(t *testing.T) {
+	got, err := ParseGroupLimits("npm=4,pypi=2")
+	if err != nil {
+		t.Fatalf("ParseGroupLimits() error: %v", err)
+	}
+	if diff := cmp.Diff(map[string]int{"npm": 4, "pypi": 2}, got); diff != "" {
+		t.Errorf("ParseGroupLimits() mismatch (-want +got):\n%s", diff)
+	}
+	for _, bad := range []string{"npm", "npm=0", "npm=x"} {
+		if _, err := ParseGroupLimits(bad); err == nil {
+			t.Errorf("ParseGroupLimits(%q) expected error", bad)
+		}
+	}
+}
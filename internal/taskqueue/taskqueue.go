@@ -0,0 +1,131 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package taskqueue provides asynchronous delivery of API requests to services.
+package taskqueue
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+)
+
+// Queue schedules requests for asynchronous delivery to a service endpoint.
+type Queue interface {
+	// Add enqueues msg for delivery as a POST request to url.
+	Add(ctx context.Context, url string, msg schema.Message, opts TaskOptions) (*Task, error)
+}
+
+// ErrTaskExists is returned when adding a task whose name matches one previously added to the queue.
+var ErrTaskExists = errors.New("task already exists")
+
+// TaskOptions controls the scheduling and delivery of a single task.
+type TaskOptions struct {
+	// Name, if provided, identifies the task. Adding a task with the name of
+	// one previously added will fail with ErrTaskExists.
+	Name string
+	// ScheduleTime, if non-zero, is the earliest time at which the task will be delivered.
+	ScheduleTime time.Time
+	// Headers are added to the request when the task is delivered.
+	Headers http.Header
+	// Priority is the scheduling class of the task.
+	Priority Priority
+	// Group identifies the class of work, such as an ecosystem, across which
+	// deliveries are limited and shared fairly.
+	Group string
+}
+
+// Task describes a request that has been added to a Queue.
+type Task struct {
+	// Name is the identifier for the task, either provided in TaskOptions or assigned by the queue.
+	Name         string
+	URL          string
+	Body         []byte
+	ScheduleTime time.Time
+	Headers      http.Header
+	Priority     Priority
+	Group        string
+	// Attempts is the number of deliveries of the task that have been made.
+	Attempts int
+	// added is the time at which the task was added to the queue.
+	added time.Time
+}
+
+// readyTime returns the earliest time at which the task could be delivered.
+func (t *Task) readyTime() time.Time {
+	if t.ScheduleTime.After(t.added) {
+		return t.ScheduleTime
+	}
+	return t.added
+}
+
+// encode serializes msg into the form body used for task delivery.
+func encode(msg schema.Message) ([]byte, error) {
+	if err := msg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validating message")
+	}
+	values, err := schema.Encode(msg)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing message")
+	}
+	return []byte(values.Encode()), nil
+}
+
+// Config is the configuration for selecting a Queue implementation.
+type Config struct {
+	// Kind is the Queue implementation to use. Currently only "memory" is supported.
+	Kind string
+	// Workers is the number of concurrent deliveries for the "memory" queue.
+	Workers int
+	// GroupLimits is the per-group concurrency limit for the "memory" queue
+	// in the form accepted by ParseGroupLimits.
+	GroupLimits string
+	// Retry controls the redelivery of failed tasks.
+	Retry RetryPolicy
+	// DeadLetters, if provided, records tasks that exhaust their delivery attempts.
+	DeadLetters DeadLetterStore
+}
+
+// RegisterFlags registers the flags for selecting a Queue implementation.
+func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Kind, "task-queue", "memory", "the task queue implementation to use. Options: memory")
+	fs.IntVar(&cfg.Workers, "task-queue-workers", 4, "the number of concurrent task deliveries for the in-memory task queue")
+	fs.StringVar(&cfg.GroupLimits, "task-queue-group-limits", "", "per-group concurrency limits for the in-memory task queue, e.g. 'npm=4,pypi=2'")
+	fs.IntVar(&cfg.Retry.MaxAttempts, "task-queue-max-attempts", 3, "the number of delivery attempts made for a task before it is dead-lettered")
+	fs.DurationVar(&cfg.Retry.MinBackoff, "task-queue-min-backoff", 10*time.Second, "the delay before the first retry of a failed task")
+	fs.DurationVar(&cfg.Retry.MaxBackoff, "task-queue-max-backoff", 10*time.Minute, "the maximum delay between retries of a failed task")
+}
+
+// MakeQueue creates the Queue described by cfg.
+// The client is used to deliver tasks for in-process queue implementations.
+func MakeQueue(ctx context.Context, cfg Config, client httpx.BasicClient) (Queue, error) {
+	switch cfg.Kind {
+	case "", "memory":
+		if client == nil {
+			client = http.DefaultClient
+		}
+		limits, err := ParseGroupLimits(cfg.GroupLimits)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing group limits")
+		}
+		return NewLocalQueueWithPolicy(ctx, client, cfg.Workers, Policy{GroupLimits: limits, Retry: cfg.Retry, DeadLetters: cfg.DeadLetters}), nil
+	default:
+		return nil, errors.Errorf("unknown task queue kind: %s", cfg.Kind)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package telemetry provides the metrics and traces emitted by the rebuild services.
+//
+// Instruments are registered against the global OpenTelemetry providers so
+// recording is a no-op until a binary calls Setup.
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/google/oss-rebuild"
+
+var (
+	meter  = otel.Meter(instrumentationName)
+	tracer = otel.Tracer(instrumentationName)
+)
+
+var (
+	rebuildDuration = must(meter.Float64Histogram(
+		"rebuild.duration",
+		metric.WithDescription("Wall time taken to execute a rebuild."),
+		metric.WithUnit("s"),
+	))
+	queueLatency = must(meter.Float64Histogram(
+		"queue.latency",
+		metric.WithDescription("Time between a task becoming eligible for delivery and its delivery."),
+		metric.WithUnit("s"),
+	))
+	verdicts = must(meter.Int64Counter(
+		"rebuild.verdicts",
+		metric.WithDescription("Count of rebuild verdicts."),
+	))
+	registryErrors = must(meter.Int64Counter(
+		"registry.fetch.errors",
+		metric.WithDescription("Count of failed requests to package registries."),
+	))
+)
+
+func must[T any](t T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Setup installs the global meter provider, tracer provider, and trace propagator.
+// The returned handler serves the recorded metrics in the Prometheus exposition format.
+func Setup(ctx context.Context) (http.Handler, error) {
+	reg := prometheus.NewRegistry()
+	exporter, err := otelprom.New(otelprom.WithRegisterer(reg))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating prometheus exporter")
+	}
+	tp, err := newTracerProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(exporter)))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), nil
+}
+
+// newTracerProvider returns a TracerProvider that exports spans over OTLP/HTTP.
+//
+// The exporter is configured using the standard OTEL_EXPORTER_OTLP_* environment
+// variables and is only installed when an endpoint is provided. Otherwise, spans
+// are still created and propagated but are not exported.
+func newTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(resource.Default())}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating trace exporter")
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// WrapHandler returns h instrumented to trace the requests it serves as the named operation.
+func WrapHandler(h http.Handler, operation string) http.Handler {
+	return otelhttp.NewHandler(h, operation)
+}
+
+// WrapClient returns a copy of c that propagates the trace context of outgoing requests.
+func WrapClient(c *http.Client) *http.Client {
+	wrapped := *c
+	wrapped.Transport = otelhttp.NewTransport(c.Transport)
+	return &wrapped
+}
+
+// StartSpan starts a span named name as a child of any span present in ctx.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordRebuild records the duration and outcome of a rebuild of an artifact from ecosystem.
+// The service identifies the component that executed the rebuild.
+func RecordRebuild(ctx context.Context, service, ecosystem string, d time.Duration, success bool) {
+	attrs := metric.WithAttributes(
+		attribute.String("service", service),
+		attribute.String("ecosystem", ecosystem),
+		attribute.Bool("success", success),
+	)
+	rebuildDuration.Record(ctx, d.Seconds(), attrs)
+	verdicts.Add(ctx, 1, attrs)
+}
+
+// RecordQueueLatency records the delay in delivering a task from the named queue.
+func RecordQueueLatency(ctx context.Context, queue string, d time.Duration) {
+	queueLatency.Record(ctx, d.Seconds(), metric.WithAttributes(attribute.String("queue", queue)))
+}
+
+// RecordRegistryError records a failed request to the registry at host.
+func RecordRegistryError(ctx context.Context, host string) {
+	registryErrors.Add(ctx, 1, metric.WithAttributes(attribute.String("registry", host)))
+}
+
+// RegistryClient is a BasicClient that records failed requests as registry fetch errors.
+type RegistryClient struct {
+	httpx.BasicClient
+}
+
+var _ httpx.BasicClient = &RegistryClient{}
+
+// Do sends the request and records any transport failure or error status.
+func (c *RegistryClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.BasicClient.Do(req)
+	if err != nil || resp.StatusCode >= 400 {
+		RecordRegistryError(req.Context(), req.URL.Host)
+	}
+	return resp, err
+}
@@ -0,0 +1,247 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NOTE: Instruments bind to the global providers on first use so these are
+// installed once for the package rather than per-test.
+var (
+	spans  = tracetest.NewSpanRecorder()
+	reader = sdkmetric.NewManualReader()
+)
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	os.Exit(m.Run())
+}
+
+func endedSpan(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, s := range spans.Ended() {
+		if s.Name() == name {
+			return s
+		}
+	}
+	t.Fatalf("no ended span named %q", name)
+	return nil
+}
+
+func collect(t *testing.T) metricdata.ResourceMetrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() returned error: %v", err)
+	}
+	return rm
+}
+
+func findMetric(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Aggregation {
+	t.Helper()
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+	t.Fatalf("no metric named %q", name)
+	return nil
+}
+
+func TestStartSpan(t *testing.T) {
+	ctx, parent := StartSpan(context.Background(), "TestStartSpan.parent")
+	_, child := StartSpan(ctx, "TestStartSpan.child", attribute.String("ecosystem", "npm"))
+	child.End()
+	parent.End()
+	p := endedSpan(t, "TestStartSpan.parent")
+	c := endedSpan(t, "TestStartSpan.child")
+	if c.Parent().SpanID() != p.SpanContext().SpanID() {
+		t.Errorf("child parent = %v, want %v", c.Parent().SpanID(), p.SpanContext().SpanID())
+	}
+	if c.SpanContext().TraceID() != p.SpanContext().TraceID() {
+		t.Errorf("child trace = %v, want %v", c.SpanContext().TraceID(), p.SpanContext().TraceID())
+	}
+	if got := c.Attributes(); len(got) != 1 || got[0] != attribute.String("ecosystem", "npm") {
+		t.Errorf("child attributes = %v, want [ecosystem=npm]", got)
+	}
+}
+
+func TestWrapClientAndHandler(t *testing.T) {
+	var gotParent trace.SpanContext
+	srv := httptest.NewServer(WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotParent = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}), "TestWrapClientAndHandler.server"))
+	defer srv.Close()
+	ctx, span := StartSpan(context.Background(), "TestWrapClientAndHandler.caller")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := WrapClient(srv.Client()).Do(req)
+	if err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	resp.Body.Close()
+	span.End()
+	want := span.SpanContext().TraceID()
+	if gotParent.TraceID() != want {
+		t.Errorf("handler trace = %v, want %v", gotParent.TraceID(), want)
+	}
+	server := endedSpan(t, "TestWrapClientAndHandler.server")
+	if server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("server span kind = %v, want %v", server.SpanKind(), trace.SpanKindServer)
+	}
+	if server.SpanContext().TraceID() != want {
+		t.Errorf("server span trace = %v, want %v", server.SpanContext().TraceID(), want)
+	}
+}
+
+func TestRecordRebuild(t *testing.T) {
+	ctx := context.Background()
+	RecordRebuild(ctx, "TestRecordRebuild", "pypi", 3*time.Second, true)
+	RecordRebuild(ctx, "TestRecordRebuild", "pypi", time.Second, true)
+	RecordRebuild(ctx, "TestRecordRebuild", "pypi", time.Second, false)
+	rm := collect(t)
+	want := attribute.NewSet(
+		attribute.String("service", "TestRecordRebuild"),
+		attribute.String("ecosystem", "pypi"),
+		attribute.Bool("success", true),
+	)
+	sum := findMetric(t, rm, "rebuild.verdicts").(metricdata.Sum[int64])
+	var found bool
+	for _, dp := range sum.DataPoints {
+		if dp.Attributes.Equals(&want) {
+			found = true
+			if dp.Value != 2 {
+				t.Errorf("verdicts = %d, want 2", dp.Value)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no verdict data point with attributes %v", want.Encoded(attribute.DefaultEncoder()))
+	}
+	hist := findMetric(t, rm, "rebuild.duration").(metricdata.Histogram[float64])
+	found = false
+	for _, dp := range hist.DataPoints {
+		if dp.Attributes.Equals(&want) {
+			found = true
+			if dp.Count != 2 || dp.Sum != 4 {
+				t.Errorf("duration count, sum = %d, %v, want 2, 4", dp.Count, dp.Sum)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no duration data point with attributes %v", want.Encoded(attribute.DefaultEncoder()))
+	}
+}
+
+func TestRegistryClient(t *testing.T) {
+	c := &RegistryClient{BasicClient: &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{URL: "https://registry.test/ok", Response: &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}},
+			{URL: "https://registry.test/missing", Response: &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}},
+		},
+	}}
+	for _, path := range []string{"/ok", "/missing"} {
+		req, err := http.NewRequest(http.MethodGet, "https://registry.test"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Do(req); err != nil {
+			t.Fatalf("Do(%s) returned error: %v", path, err)
+		}
+	}
+	want := attribute.NewSet(attribute.String("registry", "registry.test"))
+	sum := findMetric(t, collect(t), "registry.fetch.errors").(metricdata.Sum[int64])
+	for _, dp := range sum.DataPoints {
+		if dp.Attributes.Equals(&want) {
+			if dp.Value != 1 {
+				t.Errorf("registry errors = %d, want 1", dp.Value)
+			}
+			return
+		}
+	}
+	t.Error("no registry error data point for registry.test")
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	t.Run("exports to configured endpoint", func(t *testing.T) {
+		var mu sync.Mutex
+		var paths []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			paths = append(paths, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+		ctx := context.Background()
+		tp, err := newTracerProvider(ctx)
+		if err != nil {
+			t.Fatalf("newTracerProvider() returned error: %v", err)
+		}
+		defer tp.Shutdown(ctx)
+		_, span := tp.Tracer("test").Start(ctx, "exported")
+		span.End()
+		if err := tp.ForceFlush(ctx); err != nil {
+			t.Fatalf("ForceFlush() returned error: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(paths) != 1 || paths[0] != "/v1/traces" {
+			t.Errorf("exporter requests = %v, want [/v1/traces]", paths)
+		}
+	})
+	t.Run("no endpoint", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+		ctx := context.Background()
+		tp, err := newTracerProvider(ctx)
+		if err != nil {
+			t.Fatalf("newTracerProvider() returned error: %v", err)
+		}
+		defer tp.Shutdown(ctx)
+		_, span := tp.Tracer("test").Start(ctx, "unexported")
+		defer span.End()
+		if !span.SpanContext().IsValid() {
+			t.Error("span context is invalid, want a recorded span")
+		}
+	})
+}
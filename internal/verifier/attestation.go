@@ -19,7 +19,10 @@ import (
 	"encoding/json"
 	"io"
 	"path"
+	"sort"
+	"strings"
 
+	"github.com/google/oss-rebuild/pkg/archive"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	"github.com/in-toto/in-toto-golang/in_toto"
@@ -35,6 +38,20 @@ const (
 	ArtifactEquivalenceBuildType = "https://docs.oss-rebuild.dev/builds/ArtifactEquivalence@v0.1"
 )
 
+// CompatibleBuildType returns whether buildType identifies the same build as
+// want at a version readable by this package.
+//
+// Build types are versioned as "<name>@v<major>.<minor>". Minor revisions only
+// add fields so attestations with any minor version of a supported major
+// version are compatible.
+func CompatibleBuildType(buildType, want string) bool {
+	name, version, _ := strings.Cut(buildType, "@")
+	wantName, wantVersion, _ := strings.Cut(want, "@")
+	major, _, _ := strings.Cut(version, ".")
+	wantMajor, _, _ := strings.Cut(wantVersion, ".")
+	return name == wantName && major == wantMajor
+}
+
 // CreateAttestations creates the SLSA attestations associated with a rebuild.
 func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy rebuild.Strategy, id string, rb, up ArtifactSummary, metadata rebuild.AssetStore, buildDef rebuild.Location) (equivalence, build *in_toto.ProvenanceStatementSLSA1, err error) {
 	t, manualStrategy := input.Target, input.Strategy
@@ -61,6 +78,24 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 			return nil, nil, errors.Wrap(err, "parsing rebuild build info file")
 		}
 	}
+	// NOTE: The environment is absent for rebuilds that predate its collection.
+	var envBytes []byte
+	var env rebuild.Environment
+	{
+		r, _, err := metadata.Reader(ctx, rebuild.Asset{Target: t, Type: rebuild.EnvironmentAsset})
+		if err == nil {
+			defer checkClose(r)
+			envBytes, err = io.ReadAll(r)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "reading rebuild environment")
+			}
+			if err := json.Unmarshal(envBytes, &env); err != nil {
+				return nil, nil, errors.Wrap(err, "parsing rebuild environment")
+			}
+		} else if !errors.Is(err, rebuild.ErrAssetNotFound) {
+			return nil, nil, errors.Wrap(err, "opening rebuild environment")
+		}
+	}
 	builder := slsa1.Builder{
 		// TODO: Make the host configurable.
 		ID: "https://docs.oss-rebuild.dev/hosts/Google",
@@ -68,6 +103,18 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 	}
 	publicRebuildURI := path.Join("rebuild", buildInfo.Target.Artifact)
 	publicNormalizedURI := path.Join("normalized", buildInfo.Target.Artifact)
+	// NOTE: The stabilizers are recorded so the comparison can be replayed faithfully.
+	eqParams := map[string]any{"stabilizers": archive.Stabilizers}
+	if len(up.Checks) > 0 {
+		eqParams["upstreamVerification"] = up.Checks
+	}
+	if up.Fallback != nil {
+		eqParams["upstreamFallback"] = up.Fallback
+	}
+	// NOTE: Content removed by the stabilizers is recorded so that it can be audited.
+	if removals := stabilizationRemovals(rb, up); len(removals) > 0 {
+		eqParams["stabilization"] = removals
+	}
 	// Create comparison attestation.
 	eqStmt := &in_toto.ProvenanceStatementSLSA1{
 		StatementHeader: in_toto.StatementHeader{
@@ -82,8 +129,7 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 					"candidate": publicRebuildURI,
 					"target":    up.URI,
 				},
-				// NOTE: Could include comparison settings here when they're non-trivial.
-				InternalParameters: nil,
+				InternalParameters: eqParams,
 				ResolvedDependencies: []slsa1.ResourceDescriptor{
 					{Name: publicRebuildURI, Digest: makeDigestSet(rb.Hash...)},
 					{Name: up.URI, Digest: makeDigestSet(up.Hash...)},
@@ -109,6 +155,15 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 	for n, s := range buildInfo.BuildImages {
 		rd = append(rd, slsa1.ResourceDescriptor{Name: n, Digest: common.DigestSet{"sha256": s}})
 	}
+	var refs []string
+	for ref := range env.Images {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		algo, digest, _ := strings.Cut(env.Images[ref], ":")
+		rd = append(rd, slsa1.ResourceDescriptor{Name: ref, Digest: common.DigestSet{algo: digest}})
+	}
 	// Empty the PullTiming and Status fields since they are superfluous to
 	// downstream users.
 	for _, s := range buildInfo.Steps {
@@ -142,6 +197,12 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 			"path":       buildDef.Dir,
 		}
 	}
+	var buildParams any
+	if input.DisableInstallHooks {
+		// NOTE: Dependencies were installed without running their install hooks
+		// so consumers can distinguish such rebuilds from the upstream process.
+		buildParams = map[string]any{"installHooksDisabled": true}
+	}
 	stmt := &in_toto.ProvenanceStatementSLSA1{
 		StatementHeader: in_toto.StatementHeader{
 			Type:          in_toto.StatementInTotoV1,
@@ -153,7 +214,7 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 				BuildType:            RebuildBuildType,
 				ExternalParameters:   externalParams,
 				ResolvedDependencies: rd,
-				InternalParameters:   nil,
+				InternalParameters:   buildParams,
 			},
 			RunDetails: slsa1.ProvenanceRunDetails{
 				Builder: builder,
@@ -171,6 +232,9 @@ func CreateAttestations(ctx context.Context, input rebuild.Input, finalStrategy
 			},
 		},
 	}
+	if envBytes != nil {
+		stmt.Predicate.RunDetails.Byproducts = append(stmt.Predicate.RunDetails.Byproducts, slsa1.ResourceDescriptor{Name: "environment.json", Content: envBytes})
+	}
 	return eqStmt, stmt, nil
 }
 
@@ -179,3 +243,15 @@ func checkClose(closer io.Closer) {
 		panic(errors.Wrap(err, "deferred close failed"))
 	}
 }
+
+// stabilizationRemovals returns the content removed from each artifact by stabilization, keyed by "rebuild" and "upstream".
+func stabilizationRemovals(rb, up ArtifactSummary) map[string][]archive.Removal {
+	removals := make(map[string][]archive.Removal)
+	if rb.Stabilization != nil && len(rb.Stabilization.Removals) > 0 {
+		removals["rebuild"] = rb.Stabilization.Removals
+	}
+	if up.Stabilization != nil && len(up.Stabilization.Removals) > 0 {
+		removals["upstream"] = up.Stabilization.Removals
+	}
+	return removals
+}
@@ -104,6 +104,34 @@ func TestCreateAttestations(t *testing.T) {
         "candidate": "rebuild/bytes-1.0.0.crate",
         "target": "https://up.stream/bytes-1.0.0.crate"
       },
+      "internalParameters": {
+        "stabilizers": [
+          "zip-sort-entries",
+          "zip-clear-mtime",
+          "zip-deterministic-headers",
+          "tar-sort-entries",
+          "tar-clear-times",
+          "tar-clear-owner",
+          "tar-fixed-mode",
+          "tar-pax-format",
+          "oci-flatten-layers",
+          "oci-clear-config-times",
+          "oci-clear-diff-ids",
+          "xml-strip-whitespace",
+          "xml-normalize-namespaces",
+          "xml-sort-attributes",
+          "xml-utf8-encoding",
+          "zip-canonicalize-xml-descriptors",
+          "zip-strip-properties-timestamp",
+          "zip-normalize-build-info",
+          "zip-sort-kotlin-module-parts",
+          "npm-normalize-package-json",
+          "crate-strip-vcs-info",
+          "crate-normalize-cargo-toml",
+          "unicode-nfc-entry-names",
+          "zip-decode-entry-names"
+        ]
+      },
       "resolvedDependencies": [
         {
           "digest": {
@@ -217,3 +245,19 @@ func TestCreateAttestations(t *testing.T) {
 		}
 	})
 }
+
+func TestCompatibleBuildType(t *testing.T) {
+	for _, tc := range []struct {
+		buildType string
+		want      bool
+	}{
+		{RebuildBuildType, true},
+		{"https://docs.oss-rebuild.dev/builds/Rebuild@v0.2", true},
+		{"https://docs.oss-rebuild.dev/builds/Rebuild@v1.0", false},
+		{ArtifactEquivalenceBuildType, false},
+	} {
+		if got := CompatibleBuildType(tc.buildType, RebuildBuildType); got != tc.want {
+			t.Errorf("CompatibleBuildType(%q) = %v, want %v", tc.buildType, got, tc.want)
+		}
+	}
+}
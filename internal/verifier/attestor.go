@@ -19,17 +19,24 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"time"
 
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/pkg/errors"
 )
 
+// ErrIndexNotUpdated indicates a bundle was published but could not be
+// recorded in its package's index.
+var ErrIndexNotUpdated = errors.New("package index not updated")
+
 // Attestor is a verifier that signs and publishes attestation bundles.
 type Attestor struct {
 	Store          rebuild.AssetStore
 	Signer         InTotoEnvelopeSigner
 	AllowOverwrite bool
+	// Layout is the arrangement of objects written to Store. Defaults to LayoutV1.
+	Layout StorageLayout
 }
 
 // BundleExists returns whether an existing attestation bundle exists.
@@ -45,7 +52,27 @@ func (a Attestor) BundleExists(ctx context.Context, t rebuild.Target) (bool, err
 	}
 }
 
+// UpstreamDigest returns the digest, in "sha256:<hex>" form, of the upstream
+// artifact attested by the existing bundle or "" if there is no bundle.
+func (a Attestor) UpstreamDigest(ctx context.Context, t rebuild.Target) (string, error) {
+	r, _, err := a.Store.Reader(ctx, rebuild.Asset{Target: t, Type: rebuild.AttestationBundleAsset})
+	if errors.Is(err, rebuild.ErrAssetNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	b, err := ReadBundle(r)
+	if err != nil {
+		return "", err
+	}
+	return b.UpstreamDigest()
+}
+
 // PublishBundle signs and publishes an attestation bundle.
+//
+// Under LayoutV2, the bundle is additionally recorded in its package's index.
+// Should this fail, the returned error wraps ErrIndexNotUpdated.
 func (a Attestor) PublishBundle(ctx context.Context, t rebuild.Target, stmts ...*in_toto.ProvenanceStatementSLSA1) error {
 	if exists, err := a.BundleExists(ctx, t); err != nil {
 		return errors.Wrap(err, "checking for existing bundle")
@@ -63,7 +90,7 @@ func (a Attestor) PublishBundle(ctx context.Context, t rebuild.Target, stmts ...
 			return errors.Wrap(err, "marshalling DSSE")
 		}
 	}
-	w, _, err := a.Store.Writer(ctx, rebuild.Asset{Target: t, Type: rebuild.AttestationBundleAsset})
+	w, uri, err := a.Store.Writer(ctx, rebuild.Asset{Target: t, Type: rebuild.AttestationBundleAsset})
 	if err != nil {
 		return errors.Wrap(err, "creating writer for bundle")
 	}
@@ -73,5 +100,11 @@ func (a Attestor) PublishBundle(ctx context.Context, t rebuild.Target, stmts ...
 	if err := w.Close(); err != nil {
 		return errors.Wrap(err, "closing bundle upload")
 	}
+	if a.Layout == LayoutV2 {
+		e := IndexEntry{Version: t.Version, Artifact: t.Artifact, Bundle: uri, Published: time.Now().UTC()}
+		if err := updateIndex(ctx, a.Store, t, e); err != nil {
+			return errors.Wrapf(ErrIndexNotUpdated, "%v", err)
+		}
+	}
 	return nil
 }
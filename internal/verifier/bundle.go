@@ -0,0 +1,175 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// Bundle is a SLSA rebuild attestation bundle.
+type Bundle struct {
+	Bytes []byte
+}
+
+// ReadBundle reads an attestation bundle from r.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading bundle")
+	}
+	return &Bundle{b}, nil
+}
+
+func unwrapEnvelope(e *dsse.Envelope) (*in_toto.ProvenanceStatementSLSA1, error) {
+	if e.Payload == "" {
+		return nil, errors.New("no payload")
+	}
+	b, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, errors.New("payload is not b64 encoded")
+	}
+	var decoded *in_toto.ProvenanceStatementSLSA1
+	if json.Unmarshal(b, &decoded) != nil {
+		return nil, errors.New("payload is not valid json")
+	}
+	return decoded, nil
+}
+
+// Payloads returns all payloads in the bundle.
+func (b *Bundle) Payloads() ([]*in_toto.ProvenanceStatementSLSA1, error) {
+	bundle := bytes.NewBuffer(b.Bytes)
+	d := json.NewDecoder(bundle)
+	var payloads []*in_toto.ProvenanceStatementSLSA1
+	for {
+		var envelope dsse.Envelope
+		err := d.Decode(&envelope)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding envelope")
+		}
+		p, err := unwrapEnvelope(&envelope)
+		if err != nil {
+			return nil, errors.Wrap(err, "unwrapping envelope")
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads, nil
+}
+
+func (b *Bundle) attestation(buildType string) (*in_toto.ProvenanceStatementSLSA1, error) {
+	payloads, err := b.Payloads()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range payloads {
+		if CompatibleBuildType(p.Predicate.BuildDefinition.BuildType, buildType) {
+			return p, nil
+		}
+	}
+	return nil, errors.Errorf("no attestation of type %s found", buildType)
+}
+
+// RebuildAttestation returns the rebuild attestation from the bundle.
+func (b *Bundle) RebuildAttestation() (*in_toto.ProvenanceStatementSLSA1, error) {
+	return b.attestation(RebuildBuildType)
+}
+
+// EquivalenceAttestation returns the artifact equivalence attestation from the bundle.
+func (b *Bundle) EquivalenceAttestation() (*in_toto.ProvenanceStatementSLSA1, error) {
+	return b.attestation(ArtifactEquivalenceBuildType)
+}
+
+// Byproduct returns the named byproduct from the rebuild attestation.
+func (b *Bundle) Byproduct(name string) ([]byte, error) {
+	att, err := b.RebuildAttestation()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range att.Predicate.RunDetails.Byproducts {
+		if b.Name == name {
+			return b.Content, nil
+		}
+	}
+	return nil, fmt.Errorf("byproduct named %s not found", name)
+}
+
+// Target returns the rebuild target described by the rebuild attestation.
+func (b *Bundle) Target() (rebuild.Target, error) {
+	att, err := b.RebuildAttestation()
+	if err != nil {
+		return rebuild.Target{}, err
+	}
+	// NOTE: Round-trip through JSON to access the parameters regardless of their decoded type.
+	raw, err := json.Marshal(att.Predicate.BuildDefinition.ExternalParameters)
+	if err != nil {
+		return rebuild.Target{}, errors.Wrap(err, "marshalling external parameters")
+	}
+	var params struct {
+		Ecosystem string `json:"ecosystem"`
+		Package   string `json:"package"`
+		Version   string `json:"version"`
+		Artifact  string `json:"artifact"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return rebuild.Target{}, errors.Wrap(err, "parsing external parameters")
+	}
+	return rebuild.Target{Ecosystem: rebuild.Ecosystem(params.Ecosystem), Package: params.Package, Version: params.Version, Artifact: params.Artifact}, nil
+}
+
+// Stabilizers returns the stabilizers applied when comparing the rebuild to upstream.
+// A nil result indicates the bundle predates their recording.
+func (b *Bundle) Stabilizers() ([]string, error) {
+	att, err := b.EquivalenceAttestation()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(att.Predicate.BuildDefinition.InternalParameters)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshalling internal parameters")
+	}
+	var params struct {
+		Stabilizers []string `json:"stabilizers"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, errors.Wrap(err, "parsing internal parameters")
+	}
+	return params.Stabilizers, nil
+}
+
+// UpstreamDigest returns the SHA-256 digest, in "sha256:<hex>" form, of the
+// upstream artifact attested by the equivalence attestation.
+func (b *Bundle) UpstreamDigest() (string, error) {
+	att, err := b.EquivalenceAttestation()
+	if err != nil {
+		return "", err
+	}
+	for _, s := range att.Subject {
+		if d, ok := s.Digest["sha256"]; ok {
+			return "sha256:" + d, nil
+		}
+	}
+	return "", errors.New("no sha256 digest of the upstream artifact")
+}
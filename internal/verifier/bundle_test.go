@@ -0,0 +1,138 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/in-toto/in-toto-golang/in_toto"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+func makeBundle(t *testing.T, stmts ...*in_toto.ProvenanceStatementSLSA1) *Bundle {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	e := json.NewEncoder(buf)
+	for _, s := range stmts {
+		payload, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Encode(dsse.Envelope{PayloadType: in_toto.PayloadType, Payload: base64.StdEncoding.EncodeToString(payload)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := ReadBundle(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBundle(t *testing.T) {
+	rb := &in_toto.ProvenanceStatementSLSA1{
+		Predicate: slsa1.ProvenancePredicate{
+			BuildDefinition: slsa1.ProvenanceBuildDefinition{
+				BuildType: RebuildBuildType,
+				ExternalParameters: map[string]any{
+					"ecosystem": "cratesio",
+					"package":   "bytes",
+					"version":   "1.0.0",
+					"artifact":  "bytes-1.0.0.crate",
+				},
+			},
+			RunDetails: slsa1.ProvenanceRunDetails{
+				Byproducts: []slsa1.ResourceDescriptor{{Name: "Dockerfile", Content: []byte("FROM alpine:3.19")}},
+			},
+		},
+	}
+	eq := &in_toto.ProvenanceStatementSLSA1{
+		StatementHeader: in_toto.StatementHeader{
+			Subject: []in_toto.Subject{{Name: "bytes-1.0.0.crate", Digest: map[string]string{"sha256": "abcd"}}},
+		},
+		Predicate: slsa1.ProvenancePredicate{
+			BuildDefinition: slsa1.ProvenanceBuildDefinition{
+				BuildType:          ArtifactEquivalenceBuildType,
+				InternalParameters: map[string]any{"stabilizers": []string{"zip-sort-entries"}},
+			},
+		},
+	}
+	t.Run("Target", func(t *testing.T) {
+		got, err := makeBundle(t, eq, rb).Target()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "bytes", Version: "1.0.0", Artifact: "bytes-1.0.0.crate"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Target() mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("Byproduct", func(t *testing.T) {
+		b := makeBundle(t, eq, rb)
+		got, err := b.Byproduct("Dockerfile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "FROM alpine:3.19" {
+			t.Errorf("Byproduct() = %q, want %q", got, "FROM alpine:3.19")
+		}
+		if _, err := b.Byproduct("missing"); err == nil {
+			t.Error("Byproduct() expected error for missing byproduct")
+		}
+	})
+	t.Run("Stabilizers", func(t *testing.T) {
+		got, err := makeBundle(t, eq, rb).Stabilizers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"zip-sort-entries"}, got); diff != "" {
+			t.Errorf("Stabilizers() mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("StabilizersUnrecorded", func(t *testing.T) {
+		legacy := &in_toto.ProvenanceStatementSLSA1{
+			Predicate: slsa1.ProvenancePredicate{
+				BuildDefinition: slsa1.ProvenanceBuildDefinition{BuildType: ArtifactEquivalenceBuildType},
+			},
+		}
+		got, err := makeBundle(t, legacy, rb).Stabilizers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != nil {
+			t.Errorf("Stabilizers() = %v, want nil", got)
+		}
+	})
+	t.Run("UpstreamDigest", func(t *testing.T) {
+		got, err := makeBundle(t, eq, rb).UpstreamDigest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "sha256:abcd" {
+			t.Errorf("UpstreamDigest() = %q, want %q", got, "sha256:abcd")
+		}
+	})
+	t.Run("MissingAttestation", func(t *testing.T) {
+		if _, err := makeBundle(t, rb).EquivalenceAttestation(); err == nil {
+			t.Error("EquivalenceAttestation() expected error")
+		}
+	})
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// UpstreamSource provides upstream artifacts that are no longer served by
+// their registry, such as versions that have been yanked or removed.
+type UpstreamSource interface {
+	// Name identifies the source in attestations.
+	Name() string
+	// Open returns the artifact published at upstreamURI along with the URI
+	// from which it was read. rebuild.ErrAssetNotFound is returned if the
+	// source does not hold the artifact.
+	Open(ctx context.Context, t rebuild.Target, upstreamURI string) (io.ReadCloser, string, error)
+}
+
+// UpstreamFallback records that the upstream artifact was read from a source
+// other than its registry.
+type UpstreamFallback struct {
+	Source string `json:"source"`
+	URI    string `json:"uri"`
+}
+
+// UpstreamOptions configures how upstream artifacts are fetched.
+type UpstreamOptions struct {
+	// Fallbacks are consulted, in order, for artifacts no longer served at their upstream URI.
+	Fallbacks []UpstreamSource
+	// Archive, if provided, retains a copy of each artifact fetched from its
+	// registry so it remains available to a StoreSource should it be removed.
+	// The first copy retained for a target is never replaced.
+	Archive rebuild.AssetStore
+	// KnownDigests returns the digests, in "sha256:<hex>" form, previously
+	// observed for the upstream artifact from its registry. An artifact read
+	// from a fallback source is only used if it matches one of them so, if
+	// not provided, fallback sources are never used.
+	KnownDigests func(ctx context.Context, t rebuild.Target) ([]string, error)
+}
+
+// openURL fetches the resource at the URL, mapping its absence to rebuild.ErrAssetNotFound.
+func openURL(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rebuild.DoContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, rebuild.ErrAssetNotFound
+	default:
+		resp.Body.Close()
+		return nil, errors.Errorf("non-OK status: %s", resp.Status)
+	}
+}
+
+// WaybackSource reads upstream artifacts captured by the Internet Archive's Wayback Machine.
+type WaybackSource struct{}
+
+var _ UpstreamSource = WaybackSource{}
+
+func (WaybackSource) Name() string { return "wayback" }
+
+func (WaybackSource) Open(ctx context.Context, _ rebuild.Target, upstreamURI string) (io.ReadCloser, string, error) {
+	// NOTE: The "id_" modifier requests the original capture without the
+	// archive's rewriting and the partial timestamp redirects to the capture
+	// closest to it.
+	uri := "https://web.archive.org/web/2id_/" + upstreamURI
+	r, err := openURL(ctx, uri)
+	return r, uri, err
+}
+
+// MirrorSource reads upstream artifacts from a mirror which serves them at
+// the upstream URI with Prefix replaced by URL.
+type MirrorSource struct {
+	Prefix string
+	URL    string
+}
+
+var _ UpstreamSource = MirrorSource{}
+
+func (s MirrorSource) Name() string { return "mirror:" + s.URL }
+
+func (s MirrorSource) Open(ctx context.Context, _ rebuild.Target, upstreamURI string) (io.ReadCloser, string, error) {
+	rest, ok := strings.CutPrefix(upstreamURI, s.Prefix)
+	if !ok {
+		return nil, "", rebuild.ErrAssetNotFound
+	}
+	uri := s.URL + rest
+	r, err := openURL(ctx, uri)
+	return r, uri, err
+}
+
+// StoreSource reads upstream artifacts previously retained in an UpstreamOptions.Archive.
+type StoreSource struct {
+	Store rebuild.AssetStore
+}
+
+var _ UpstreamSource = StoreSource{}
+
+func (StoreSource) Name() string { return "archive" }
+
+func (s StoreSource) Open(ctx context.Context, t rebuild.Target, _ string) (io.ReadCloser, string, error) {
+	return s.Store.Reader(ctx, rebuild.Asset{Target: t, Type: rebuild.DebugUpstreamAsset})
+}
+
+// fetchUpstream reads the upstream artifact, consulting the fallback sources
+// if it is no longer served at its upstream URI.
+//
+// Fallback sources are not authenticated so their content is only accepted if
+// it matches a digest previously observed from the registry.
+func fetchUpstream(ctx context.Context, t rebuild.Target, upstreamURI string, opts UpstreamOptions) ([]byte, *UpstreamFallback, error) {
+	r, err := openURL(ctx, upstreamURI)
+	if err == nil {
+		defer checkClose(r)
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "reading upstream artifact")
+		}
+		if opts.Archive != nil {
+			archiveUpstream(ctx, opts.Archive, t, b)
+		}
+		return b, nil, nil
+	} else if !errors.Is(err, rebuild.ErrAssetNotFound) {
+		return nil, nil, errors.Wrap(err, "fetching upstream artifact")
+	}
+	if len(opts.Fallbacks) == 0 {
+		return nil, nil, errors.New("upstream artifact not found")
+	}
+	var known []string
+	if opts.KnownDigests != nil {
+		known, err = opts.KnownDigests(ctx, t)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "reading known upstream digests")
+		}
+	}
+	if len(known) == 0 {
+		return nil, nil, errors.New("upstream artifact not found and no known digest with which to verify a fallback")
+	}
+	var mismatched []string
+	for _, s := range opts.Fallbacks {
+		r, uri, err := s.Open(ctx, t, upstreamURI)
+		if errors.Is(err, rebuild.ErrAssetNotFound) {
+			continue
+		} else if err != nil {
+			return nil, nil, errors.Wrapf(err, "fetching upstream artifact from %s", s.Name())
+		}
+		defer checkClose(r)
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "reading upstream artifact from %s", s.Name())
+		}
+		sum := sha256.Sum256(b)
+		if !slices.Contains(known, "sha256:"+hex.EncodeToString(sum[:])) {
+			mismatched = append(mismatched, s.Name())
+			continue
+		}
+		return b, &UpstreamFallback{Source: s.Name(), URI: uri}, nil
+	}
+	if len(mismatched) > 0 {
+		return nil, nil, errors.Errorf("upstream artifact from %s does not match a known digest", strings.Join(mismatched, ", "))
+	}
+	return nil, nil, errors.New("upstream artifact not found")
+}
+
+// archiveUpstream retains a copy of the upstream artifact unless one has
+// already been retained. Failures are ignored since they do not affect the
+// verification of the artifact.
+func archiveUpstream(ctx context.Context, store rebuild.AssetStore, t rebuild.Target, b []byte) {
+	asset := rebuild.Asset{Target: t, Type: rebuild.DebugUpstreamAsset}
+	// NOTE: The first copy is retained so that a later mutation of the
+	// artifact by its registry cannot replace it.
+	if r, _, err := store.Reader(ctx, asset); err == nil {
+		r.Close()
+		return
+	} else if !errors.Is(err, rebuild.ErrAssetNotFound) {
+		return
+	}
+	w, _, err := store.Writer(ctx, asset)
+	if err != nil {
+		return
+	}
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return
+	}
+	w.Close()
+}
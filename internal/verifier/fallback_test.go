@@ -0,0 +1,196 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestFetchUpstream(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "foo", Version: "1.0.0", Artifact: "foo-1.0.0.tgz"}
+	upstreamURI := "https://registry.npmjs.org/foo/-/foo-1.0.0.tgz"
+	ok := func(body string) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+	}
+	notFound := func() *http.Response {
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(&bytes.Buffer{})}
+	}
+	mirror := MirrorSource{Prefix: "https://registry.npmjs.org/", URL: "https://mirror.example.com/npm/"}
+	digest := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	for _, tc := range []struct {
+		name         string
+		calls        []httpxtest.Call
+		archived     string
+		fallbacks    func(archive rebuild.AssetStore) []UpstreamSource
+		known        []string
+		want         string
+		wantFallback *UpstreamFallback
+		wantArchived string
+		wantErr      bool
+	}{
+		{
+			name:         "registry",
+			calls:        []httpxtest.Call{{URL: upstreamURI, Response: ok("upstream")}},
+			want:         "upstream",
+			wantArchived: "upstream",
+		},
+		{
+			name:         "registry already archived",
+			calls:        []httpxtest.Call{{URL: upstreamURI, Response: ok("mutated")}},
+			archived:     "upstream",
+			want:         "mutated",
+			wantArchived: "upstream",
+		},
+		{
+			name: "mirror",
+			calls: []httpxtest.Call{
+				{URL: upstreamURI, Response: notFound()},
+				{URL: "https://mirror.example.com/npm/foo/-/foo-1.0.0.tgz", Response: ok("mirrored")},
+			},
+			fallbacks:    func(rebuild.AssetStore) []UpstreamSource { return []UpstreamSource{mirror} },
+			known:        []string{digest("mirrored")},
+			want:         "mirrored",
+			wantFallback: &UpstreamFallback{Source: "mirror:https://mirror.example.com/npm/", URI: "https://mirror.example.com/npm/foo/-/foo-1.0.0.tgz"},
+		},
+		{
+			name: "archive before wayback",
+			calls: []httpxtest.Call{
+				{URL: upstreamURI, Response: notFound()},
+			},
+			archived: "archived",
+			fallbacks: func(archive rebuild.AssetStore) []UpstreamSource {
+				return []UpstreamSource{StoreSource{Store: archive}, WaybackSource{}}
+			},
+			known:        []string{digest("archived")},
+			want:         "archived",
+			wantFallback: &UpstreamFallback{Source: "archive"},
+			wantArchived: "archived",
+		},
+		{
+			name: "wayback",
+			calls: []httpxtest.Call{
+				{URL: upstreamURI, Response: notFound()},
+				{URL: "https://web.archive.org/web/2id_/" + upstreamURI, Response: ok("captured")},
+			},
+			fallbacks: func(archive rebuild.AssetStore) []UpstreamSource {
+				return []UpstreamSource{StoreSource{Store: archive}, WaybackSource{}}
+			},
+			known:        []string{digest("captured")},
+			want:         "captured",
+			wantFallback: &UpstreamFallback{Source: "wayback", URI: "https://web.archive.org/web/2id_/" + upstreamURI},
+		},
+		{
+			name: "unknown digest",
+			calls: []httpxtest.Call{
+				{URL: upstreamURI, Response: notFound()},
+				{URL: "https://mirror.example.com/npm/foo/-/foo-1.0.0.tgz", Response: ok("forged")},
+			},
+			fallbacks: func(rebuild.AssetStore) []UpstreamSource { return []UpstreamSource{mirror} },
+			known:     []string{digest("upstream")},
+			wantErr:   true,
+		},
+		{
+			name: "mismatched source skipped",
+			calls: []httpxtest.Call{
+				{URL: upstreamURI, Response: notFound()},
+				{URL: "https://mirror.example.com/npm/foo/-/foo-1.0.0.tgz", Response: ok("forged")},
+				{URL: "https://web.archive.org/web/2id_/" + upstreamURI, Response: ok("upstream")},
+			},
+			fallbacks:    func(rebuild.AssetStore) []UpstreamSource { return []UpstreamSource{mirror, WaybackSource{}} },
+			known:        []string{digest("upstream")},
+			want:         "upstream",
+			wantFallback: &UpstreamFallback{Source: "wayback", URI: "https://web.archive.org/web/2id_/" + upstreamURI},
+		},
+		{
+			name:      "no known digest",
+			calls:     []httpxtest.Call{{URL: upstreamURI, Response: notFound()}},
+			fallbacks: func(rebuild.AssetStore) []UpstreamSource { return []UpstreamSource{mirror} },
+			wantErr:   true,
+		},
+		{
+			name:    "not found",
+			calls:   []httpxtest.Call{{URL: upstreamURI, Response: notFound()}},
+			wantErr: true,
+		},
+		{
+			name: "registry error",
+			calls: []httpxtest.Call{
+				{URL: upstreamURI, Response: &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error", Body: io.NopCloser(&bytes.Buffer{})}},
+			},
+			fallbacks: func(rebuild.AssetStore) []UpstreamSource { return []UpstreamSource{mirror} },
+			wantErr:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), rebuild.HTTPBasicClientID, &httpxtest.MockClient{Calls: tc.calls})
+			archive := rebuild.NewFilesystemAssetStore(memfs.New())
+			if tc.archived != "" {
+				w, _, err := archive.Writer(ctx, rebuild.Asset{Target: target, Type: rebuild.DebugUpstreamAsset})
+				orDie(err)
+				must(w.Write([]byte(tc.archived)))
+				orDie(w.Close())
+			}
+			opts := UpstreamOptions{
+				Archive: archive,
+				KnownDigests: func(context.Context, rebuild.Target) ([]string, error) {
+					return tc.known, nil
+				},
+			}
+			if tc.fallbacks != nil {
+				opts.Fallbacks = tc.fallbacks(archive)
+			}
+			got, fallback, err := fetchUpstream(ctx, target, upstreamURI, opts)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("fetchUpstream() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if string(got) != tc.want {
+				t.Errorf("fetchUpstream() = %q, want %q", got, tc.want)
+			}
+			// NOTE: The URI of a store is specific to its implementation.
+			if fallback != nil && fallback.Source == "archive" {
+				fallback.URI = ""
+			}
+			if diff := cmp.Diff(tc.wantFallback, fallback); diff != "" {
+				t.Errorf("fetchUpstream() fallback mismatch (-want +got):\n%s", diff)
+			}
+			var archived string
+			if r, _, err := archive.Reader(ctx, rebuild.Asset{Target: target, Type: rebuild.DebugUpstreamAsset}); err == nil {
+				archived = string(must(io.ReadAll(r)))
+				r.Close()
+			}
+			if archived != tc.wantArchived {
+				t.Errorf("archived artifact = %q, want %q", archived, tc.wantArchived)
+			}
+		})
+	}
+}
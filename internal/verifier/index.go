@@ -0,0 +1,183 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// StorageLayout is the arrangement of objects in an attestation store.
+type StorageLayout string
+
+const (
+	// LayoutV1 stores only the attestation bundle of each artifact.
+	LayoutV1 StorageLayout = "v1"
+	// LayoutV2 additionally stores an index of the bundles of each package.
+	//
+	// Bundles remain at their v1 locations so stores written using v2 remain
+	// readable by v1 clients. Indexes of packages attested before adopting v2
+	// are created using RebuildIndexes.
+	LayoutV2 StorageLayout = "v2"
+)
+
+// PackageIndex lists the attested artifacts of a package so clients can
+// determine its verified versions with a single read.
+type PackageIndex struct {
+	Ecosystem rebuild.Ecosystem `json:"ecosystem"`
+	Package   string            `json:"package"`
+	// Entries are ordered by version and artifact.
+	Entries []IndexEntry `json:"entries"`
+}
+
+// IndexEntry locates the attestation bundle of an artifact.
+type IndexEntry struct {
+	Version   string    `json:"version"`
+	Artifact  string    `json:"artifact"`
+	Bundle    string    `json:"bundle"`
+	Published time.Time `json:"published"`
+}
+
+// Add records an entry, replacing any existing entry for the same artifact.
+func (idx *PackageIndex) Add(e IndexEntry) {
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		o := idx.Entries[i]
+		return o.Version > e.Version || (o.Version == e.Version && o.Artifact >= e.Artifact)
+	})
+	if i < len(idx.Entries) && idx.Entries[i].Version == e.Version && idx.Entries[i].Artifact == e.Artifact {
+		idx.Entries[i] = e
+		return
+	}
+	idx.Entries = append(idx.Entries, IndexEntry{})
+	copy(idx.Entries[i+1:], idx.Entries[i:])
+	idx.Entries[i] = e
+}
+
+// Versions returns the versions with at least one attested artifact.
+func (idx *PackageIndex) Versions() []string {
+	var versions []string
+	for _, e := range idx.Entries {
+		if len(versions) == 0 || versions[len(versions)-1] != e.Version {
+			versions = append(versions, e.Version)
+		}
+	}
+	return versions
+}
+
+func indexAsset(eco rebuild.Ecosystem, pkg string) rebuild.Asset {
+	return rebuild.Asset{Type: rebuild.PackageIndexAsset, Target: rebuild.Target{Ecosystem: eco, Package: pkg}}
+}
+
+// ReadIndex reads the index of a package's bundles.
+// rebuild.ErrAssetNotFound is returned if the package has no index.
+func ReadIndex(ctx context.Context, store rebuild.AssetStore, eco rebuild.Ecosystem, pkg string) (*PackageIndex, error) {
+	r, _, err := store.Reader(ctx, indexAsset(eco, pkg))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	idx := new(PackageIndex)
+	if err := json.NewDecoder(r).Decode(idx); err != nil {
+		return nil, errors.Wrap(err, "decoding index")
+	}
+	return idx, nil
+}
+
+// WriteIndex writes the index of a package's bundles.
+func WriteIndex(ctx context.Context, store rebuild.AssetStore, idx *PackageIndex) error {
+	w, _, err := store.Writer(ctx, indexAsset(idx.Ecosystem, idx.Package))
+	if err != nil {
+		return errors.Wrap(err, "creating writer for index")
+	}
+	if err := json.NewEncoder(w).Encode(idx); err != nil {
+		w.Close()
+		return errors.Wrap(err, "writing index")
+	}
+	return errors.Wrap(w.Close(), "closing index upload")
+}
+
+// updateIndex adds an entry to the index of the target's package.
+//
+// NOTE: Concurrent updates to the same package's index may be lost. Since the
+// index can be recreated from the bundles themselves using RebuildIndexes,
+// this is preferred to serializing all publication.
+func updateIndex(ctx context.Context, store rebuild.AssetStore, t rebuild.Target, e IndexEntry) error {
+	idx, err := ReadIndex(ctx, store, t.Ecosystem, t.Package)
+	if errors.Is(err, rebuild.ErrAssetNotFound) {
+		idx = &PackageIndex{Ecosystem: t.Ecosystem, Package: t.Package}
+	} else if err != nil {
+		return errors.Wrap(err, "reading index")
+	}
+	idx.Add(e)
+	return WriteIndex(ctx, store, idx)
+}
+
+// BundleTarget returns the target whose bundle is stored at the provided
+// object path, relative to the root of a store without run partitioning.
+func BundleTarget(objectPath string) (rebuild.Target, bool) {
+	parts := strings.Split(objectPath, "/")
+	// NOTE: Package names may contain separators (e.g. npm scopes).
+	if len(parts) < 5 || parts[len(parts)-1] != string(rebuild.AttestationBundleAsset) {
+		return rebuild.Target{}, false
+	}
+	n := len(parts)
+	return rebuild.Target{
+		Ecosystem: rebuild.Ecosystem(parts[0]),
+		Package:   path.Join(parts[1 : n-3]...),
+		Version:   parts[n-3],
+		Artifact:  parts[n-2],
+	}, true
+}
+
+// IndexedBundle is an existing bundle to be recorded in a package index.
+type IndexedBundle struct {
+	Target rebuild.Target
+	IndexEntry
+}
+
+// RebuildIndexes groups existing bundles by package and writes each
+// package's index, replacing any existing index. It returns the number of
+// indexes written.
+func RebuildIndexes(ctx context.Context, store rebuild.AssetStore, bundles []IndexedBundle) (int, error) {
+	type key struct {
+		eco rebuild.Ecosystem
+		pkg string
+	}
+	indexes := make(map[key]*PackageIndex)
+	var order []key
+	for _, b := range bundles {
+		k := key{b.Target.Ecosystem, b.Target.Package}
+		if _, ok := indexes[k]; !ok {
+			indexes[k] = &PackageIndex{Ecosystem: k.eco, Package: k.pkg}
+			order = append(order, k)
+		}
+		e := b.IndexEntry
+		e.Version, e.Artifact = b.Target.Version, b.Target.Artifact
+		indexes[k].Add(e)
+	}
+	for i, k := range order {
+		if err := WriteIndex(ctx, store, indexes[k]); err != nil {
+			return i, errors.Wrapf(err, "writing index for %s %s", k.eco, k.pkg)
+		}
+	}
+	return len(order), nil
+}
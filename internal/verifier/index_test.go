@@ -0,0 +1,95 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+func TestBundleTarget(t *testing.T) {
+	for _, tc := range []struct {
+		path   string
+		want   rebuild.Target
+		wantOK bool
+	}{
+		{
+			path:   "npm/left-pad/1.3.0/left-pad-1.3.0.tgz/rebuild.intoto.jsonl",
+			want:   rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz"},
+			wantOK: true,
+		},
+		{
+			path:   "npm/@scope/pkg/1.0.0/scope-pkg-1.0.0.tgz/rebuild.intoto.jsonl",
+			want:   rebuild.Target{Ecosystem: rebuild.NPM, Package: "@scope/pkg", Version: "1.0.0", Artifact: "scope-pkg-1.0.0.tgz"},
+			wantOK: true,
+		},
+		{path: "npm/left-pad/index.json"},
+		{path: "npm/left-pad/1.3.0/left-pad-1.3.0.tgz/other.json"},
+	} {
+		got, ok := BundleTarget(tc.path)
+		if ok != tc.wantOK {
+			t.Errorf("BundleTarget(%q) ok = %v, want %v", tc.path, ok, tc.wantOK)
+		} else if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("BundleTarget(%q) mismatch (-want +got):\n%s", tc.path, diff)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	store := rebuild.NewFilesystemAssetStore(memfs.New())
+	if _, err := ReadIndex(ctx, store, rebuild.NPM, "left-pad"); !errors.Is(err, rebuild.ErrAssetNotFound) {
+		t.Fatalf("ReadIndex() error = %v, want ErrAssetNotFound", err)
+	}
+	published := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bundle := func(version string) IndexedBundle {
+		t := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: version, Artifact: "left-pad-" + version + ".tgz"}
+		return IndexedBundle{Target: t, IndexEntry: IndexEntry{Bundle: "gs://bucket/" + version, Published: published}}
+	}
+	other := IndexedBundle{
+		Target:     rebuild.Target{Ecosystem: rebuild.PyPI, Package: "absl-py", Version: "2.0.0", Artifact: "absl_py-2.0.0-py3-none-any.whl"},
+		IndexEntry: IndexEntry{Bundle: "gs://bucket/absl", Published: published},
+	}
+	n, err := RebuildIndexes(ctx, store, []IndexedBundle{bundle("1.3.0"), other, bundle("1.1.0")})
+	if err != nil {
+		t.Fatalf("RebuildIndexes() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("RebuildIndexes() = %d, want 2", n)
+	}
+	// Publishing an existing artifact replaces its entry.
+	if err := updateIndex(ctx, store, bundle("1.1.0").Target, IndexEntry{Version: "1.1.0", Artifact: "left-pad-1.1.0.tgz", Bundle: "gs://bucket/new", Published: published}); err != nil {
+		t.Fatalf("updateIndex() error = %v", err)
+	}
+	if err := updateIndex(ctx, store, bundle("1.2.0").Target, IndexEntry{Version: "1.2.0", Artifact: "left-pad-1.2.0.tgz", Bundle: "gs://bucket/1.2.0", Published: published}); err != nil {
+		t.Fatalf("updateIndex() error = %v", err)
+	}
+	idx, err := ReadIndex(ctx, store, rebuild.NPM, "left-pad")
+	if err != nil {
+		t.Fatalf("ReadIndex() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"1.1.0", "1.2.0", "1.3.0"}, idx.Versions()); diff != "" {
+		t.Errorf("Versions() mismatch (-want +got):\n%s", diff)
+	}
+	if got := idx.Entries[0].Bundle; got != "gs://bucket/new" {
+		t.Errorf("Entries[0].Bundle = %q, want gs://bucket/new", got)
+	}
+}
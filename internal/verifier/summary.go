@@ -16,10 +16,10 @@
 package verifier
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"io"
-	"net/http"
 
 	"github.com/google/oss-rebuild/internal/hashext"
 	"github.com/google/oss-rebuild/pkg/archive"
@@ -32,10 +32,18 @@ type ArtifactSummary struct {
 	URI           string
 	Hash          hashext.MultiHash
 	CanonicalHash hashext.MultiHash
+	// Checks are the results of authenticating an upstream artifact.
+	Checks []UpstreamCheck
+	// Fallback, if set, is the source from which an upstream artifact no
+	// longer served at URI was read.
+	Fallback *UpstreamFallback
+	// Stabilization records the content removed when computing CanonicalHash.
+	Stabilization *archive.StabilizationReport
 }
 
 // SummarizeArtifacts fetches and summarizes the rebuild and upstream artifacts.
-func SummarizeArtifacts(ctx context.Context, metadata rebuild.AssetStore, t rebuild.Target, upstreamURI string, hashes []crypto.Hash) (rb, up ArtifactSummary, err error) {
+// The opt-in stabilizers configured by stab are applied to both.
+func SummarizeArtifacts(ctx context.Context, metadata rebuild.AssetStore, t rebuild.Target, upstreamURI string, hashes []crypto.Hash, stab archive.Options, opts UpstreamOptions) (rb, up ArtifactSummary, err error) {
 	rb = ArtifactSummary{Hash: hashext.NewMultiHash(hashes...), CanonicalHash: hashext.NewMultiHash(hashes...)}
 	up = ArtifactSummary{Hash: hashext.NewMultiHash(hashes...), CanonicalHash: hashext.NewMultiHash(hashes...), URI: upstreamURI}
 	// Fetch and process rebuild.
@@ -46,24 +54,18 @@ func SummarizeArtifacts(ctx context.Context, metadata rebuild.AssetStore, t rebu
 		return
 	}
 	defer checkClose(r)
-	err = archive.Canonicalize(rb.CanonicalHash, io.TeeReader(r, rb.Hash), t.ArchiveType())
+	rb.Stabilization, err = archive.CanonicalizeWithOptions(rb.CanonicalHash, io.TeeReader(r, rb.Hash), t.ArchiveType(), stab)
 	if err != nil {
 		err = errors.Wrap(err, "fingerprinting rebuild")
 		return
 	}
 	// Fetch and process upstream.
-	req, _ := http.NewRequest(http.MethodGet, up.URI, nil)
-	resp, err := rebuild.DoContext(ctx, req)
+	var b []byte
+	b, up.Fallback, err = fetchUpstream(ctx, t, up.URI, opts)
 	if err != nil {
-		err = errors.Wrap(err, "error fetching upstream artifact")
 		return
 	}
-	if resp.StatusCode != 200 {
-		err = errors.Errorf("non-OK status fetching upstream artifact")
-		return
-	}
-	err = archive.Canonicalize(up.CanonicalHash, io.TeeReader(resp.Body, up.Hash), t.ArchiveType())
-	checkClose(resp.Body)
+	up.Stabilization, err = archive.CanonicalizeWithOptions(up.CanonicalHash, io.TeeReader(bytes.NewReader(b), up.Hash), t.ArchiveType(), stab)
 	if err != nil {
 		err = errors.Wrap(err, "fingerprinting upstream")
 		return
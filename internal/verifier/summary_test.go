@@ -21,7 +21,6 @@ import (
 	"io"
 	"net/http"
 	"testing"
-	"time"
 
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/google/go-cmp/cmp"
@@ -67,11 +66,10 @@ func TestSummarizeArtifacts(t *testing.T) {
 			},
 		})
 		canonicalizedHash := hashext.NewMultiHash(crypto.SHA256)
-		canonicalizedZip := must(archivetest.ZipFile([]archive.ZipEntry{
-			{FileHeader: &zip.FileHeader{Name: "foo-0.0.1.dist-info/WHEEL", Modified: time.UnixMilli(0)}, Body: []byte("data")},
-		}))
-		must(canonicalizedHash.Write(canonicalizedZip.Bytes()))
-		rb, up, err := SummarizeArtifacts(ctx, metadata, target, upstreamURI, []crypto.Hash{crypto.SHA256})
+		dw := archive.NewDeterministicZipWriter(canonicalizedHash)
+		dw.Add("foo-0.0.1.dist-info/WHEEL", []byte("data"))
+		orDie(dw.Close())
+		rb, up, err := SummarizeArtifacts(ctx, metadata, target, upstreamURI, []crypto.Hash{crypto.SHA256}, archive.Options{}, UpstreamOptions{})
 		if err != nil {
 			t.Fatalf("SummarizeArtifacts() returned error: %v", err)
 		}
@@ -0,0 +1,282 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/registry/cratesio"
+	"github.com/google/oss-rebuild/pkg/registry/maven"
+	"github.com/google/oss-rebuild/pkg/registry/npm"
+	"github.com/pkg/errors"
+)
+
+// UpstreamCheck is the outcome of authenticating the upstream artifact using
+// cryptographic metadata published alongside it.
+type UpstreamCheck struct {
+	// Method identifies the metadata that was checked.
+	Method string `json:"method"`
+	// Verified is whether the metadata authenticated the upstream artifact.
+	Verified bool `json:"verified"`
+	// Detail identifies the key or digest used, or why verification failed.
+	Detail string `json:"detail,omitempty"`
+}
+
+const (
+	// RegistrySignatureMethod is the verification of a registry's signature over the artifact's metadata.
+	RegistrySignatureMethod = "registry-signature"
+	// RegistryChecksumMethod is the comparison of the artifact against the digest published by the registry.
+	RegistryChecksumMethod = "registry-checksum"
+	// PGPSignatureMethod is the verification of a detached PGP signature over the artifact.
+	PGPSignatureMethod = "pgp-signature"
+	// CargoVCSInfoMethod is the comparison of the commit recorded by Cargo when packaging a crate against the rebuilt commit.
+	CargoVCSInfoMethod = "cargo-vcs-info"
+)
+
+// VerifyRegistrySignature verifies the signature published by the target's
+// registry over its metadata. Since this requires only the registry metadata,
+// it can be performed before rebuilding.
+//
+// A nil check is returned if the registry publishes no signature for the target.
+func VerifyRegistrySignature(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (*UpstreamCheck, error) {
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		v, err := mux.NPM.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching version metadata")
+		}
+		if len(v.Dist.Signatures) == 0 {
+			return nil, nil
+		}
+		keys, err := mux.NPM.SigningKeys(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching registry keys")
+		}
+		return verifyNPMSignatures(v.Name, v.Version, v.Dist, keys), nil
+	default:
+		// NOTE: PyPI and crates.io do not sign artifact metadata.
+		return nil, nil
+	}
+}
+
+// verifyNPMSignatures checks that one of the dist's signatures was produced by a registry key.
+//
+// See https://docs.npmjs.com/about-registry-signatures
+func verifyNPMSignatures(name, version string, dist npm.Dist, keys []npm.SigningKey) *UpstreamCheck {
+	check := &UpstreamCheck{Method: RegistrySignatureMethod}
+	msg := sha256.Sum256([]byte(fmt.Sprintf("%s@%s:%s", name, version, dist.SHA512)))
+	for _, sig := range dist.Signatures {
+		for _, k := range keys {
+			if k.KeyID != sig.KeyID {
+				continue
+			}
+			der, err := base64.StdEncoding.DecodeString(k.Key)
+			if err != nil {
+				check.Detail = "malformed key " + k.KeyID
+				continue
+			}
+			pub, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				check.Detail = "malformed key " + k.KeyID
+				continue
+			}
+			ecpub, ok := pub.(*ecdsa.PublicKey)
+			if !ok {
+				check.Detail = "unsupported key type " + k.KeyType
+				continue
+			}
+			raw, err := base64.StdEncoding.DecodeString(sig.Sig)
+			if err != nil || !ecdsa.VerifyASN1(ecpub, msg[:], raw) {
+				check.Detail = "invalid signature from " + k.KeyID
+				continue
+			}
+			check.Verified = true
+			check.Detail = k.KeyID
+			return check
+		}
+	}
+	if check.Detail == "" {
+		check.Detail = "no signature from a known registry key"
+	}
+	return check
+}
+
+// VerifyRegistryChecksum compares the upstream artifact summary against the
+// digest published by the target's registry.
+//
+// A nil check is returned if the registry publishes no digest comparable to
+// those in the summary.
+func VerifyRegistryChecksum(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, up ArtifactSummary) (*UpstreamCheck, error) {
+	var algo crypto.Hash
+	var want string
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		v, err := mux.NPM.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching version metadata")
+		}
+		// NOTE: The integrity field is a Subresource Integrity string.
+		b64, found := strings.CutPrefix(v.Dist.SHA512, "sha512-")
+		if !found {
+			return nil, nil
+		}
+		raw, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding integrity")
+		}
+		algo, want = crypto.SHA512, hex.EncodeToString(raw)
+	case rebuild.PyPI:
+		release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching release metadata")
+		}
+		for _, a := range release.Artifacts {
+			if a.Filename == t.Artifact {
+				algo, want = crypto.SHA256, a.SHA256
+			}
+		}
+	case rebuild.CratesIO:
+		v, err := mux.CratesIO.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching version metadata")
+		}
+		algo, want = crypto.SHA256, v.Checksum
+	case rebuild.Maven:
+		typ, err := mavenFileType(t)
+		if err != nil {
+			return nil, err
+		}
+		r, err := mux.Maven.ReleaseFile(ctx, t.Package, t.Version, typ.Checksum())
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching checksum")
+		}
+		defer r.Close()
+		b, err := io.ReadAll(io.LimitReader(r, 1<<10))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading checksum")
+		}
+		// NOTE: Some publishers follow the digest with the file name.
+		if fields := strings.Fields(string(b)); len(fields) > 0 {
+			algo, want = crypto.SHA1, fields[0]
+		}
+	}
+	if want == "" {
+		return nil, nil
+	}
+	for _, h := range up.Hash {
+		if h.Algorithm != algo {
+			continue
+		}
+		got := hex.EncodeToString(h.Sum(nil))
+		check := &UpstreamCheck{Method: RegistryChecksumMethod, Verified: strings.EqualFold(got, want)}
+		if check.Verified {
+			check.Detail = fmt.Sprintf("%s:%s", toNISTName(algo), want)
+		} else {
+			check.Detail = fmt.Sprintf("%s mismatch: registry published %s", toNISTName(algo), want)
+		}
+		return check, nil
+	}
+	return nil, nil
+}
+
+// VerifyPGPSignature verifies an armored detached PGP signature over the
+// artifact, as published alongside Maven and Debian artifacts, using the
+// provided keyring.
+func VerifyPGPSignature(artifact, signature io.Reader, keyring openpgp.KeyRing) *UpstreamCheck {
+	check := &UpstreamCheck{Method: PGPSignatureMethod}
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, artifact, signature, nil)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	check.Verified = true
+	check.Detail = strings.ToUpper(hex.EncodeToString(signer.PrimaryKey.Fingerprint))
+	return check
+}
+
+// VerifyMavenSignature verifies the detached PGP signature published alongside
+// the target's artifact in its Maven repository using the provided keyring.
+// Since this requires only the published files, it can be performed before
+// rebuilding.
+func VerifyMavenSignature(ctx context.Context, t rebuild.Target, reg maven.Registry, keyring openpgp.KeyRing) (*UpstreamCheck, error) {
+	typ, err := mavenFileType(t)
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := reg.ReleaseFile(ctx, t.Package, t.Version, typ)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching artifact")
+	}
+	defer artifact.Close()
+	sig, err := reg.ReleaseFile(ctx, t.Package, t.Version, typ.Signature())
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching signature")
+	}
+	defer sig.Close()
+	return VerifyPGPSignature(artifact, sig, keyring), nil
+}
+
+// mavenFileType returns the type of the Maven release file named by the target's artifact.
+func mavenFileType(t rebuild.Target) (maven.FileType, error) {
+	_, artifactID, _ := strings.Cut(t.Package, ":")
+	typ, ok := maven.ParseFileName(artifactID, t.Version, t.Artifact)
+	if !ok {
+		return "", errors.Errorf("unsupported maven artifact: %s", t.Artifact)
+	}
+	return typ, nil
+}
+
+// VerifyCargoVCSInfo compares the commit recorded in the upstream crate's
+// .cargo_vcs_info.json against the commit from which it was rebuilt.
+//
+// The file is written by the publisher's own tooling so this does not
+// authenticate the artifact but corroborates the inferred source location.
+//
+// A nil check is returned if the upstream crate recorded no commit.
+func VerifyCargoVCSInfo(up ArtifactSummary, loc rebuild.Location) *UpstreamCheck {
+	if up.Stabilization == nil {
+		return nil
+	}
+	for _, r := range up.Stabilization.Removals {
+		if r.Stabilizer != archive.VCSInfoStabilizer {
+			continue
+		}
+		var info cratesio.CargoVCSInfo
+		if err := json.Unmarshal([]byte(r.Value), &info); err != nil || info.SHA1 == "" {
+			return nil
+		}
+		check := &UpstreamCheck{Method: CargoVCSInfoMethod, Verified: strings.EqualFold(info.SHA1, loc.Ref)}
+		if check.Verified {
+			check.Detail = info.SHA1
+		} else {
+			check.Detail = fmt.Sprintf("commit mismatch: crate records %s", info.SHA1)
+		}
+		return check
+	}
+	return nil
+}
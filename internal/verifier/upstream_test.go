@@ -0,0 +1,234 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verifier
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/google/oss-rebuild/internal/hashext"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/registry/cratesio"
+	"github.com/google/oss-rebuild/pkg/registry/maven"
+	"github.com/google/oss-rebuild/pkg/registry/npm"
+)
+
+func TestVerifyNPMSignatures(t *testing.T) {
+	priv := must(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))
+	key := npm.SigningKey{KeyID: "SHA256:test", KeyType: "ecdsa-sha2-nistp256", Key: base64.StdEncoding.EncodeToString(must(x509.MarshalPKIXPublicKey(&priv.PublicKey)))}
+	integrity := "sha512-deadbeef"
+	digest := sha256.Sum256([]byte("pkg@1.0.0:" + integrity))
+	sig := base64.StdEncoding.EncodeToString(must(ecdsa.SignASN1(rand.Reader, priv, digest[:])))
+	for _, tc := range []struct {
+		name     string
+		dist     npm.Dist
+		verified bool
+	}{
+		{"Valid", npm.Dist{SHA512: integrity, Signatures: []npm.Signature{{KeyID: key.KeyID, Sig: sig}}}, true},
+		{"TamperedIntegrity", npm.Dist{SHA512: "sha512-cafebabe", Signatures: []npm.Signature{{KeyID: key.KeyID, Sig: sig}}}, false},
+		{"UnknownKey", npm.Dist{SHA512: integrity, Signatures: []npm.Signature{{KeyID: "SHA256:other", Sig: sig}}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			check := verifyNPMSignatures("pkg", "1.0.0", tc.dist, []npm.SigningKey{key})
+			if check.Verified != tc.verified {
+				t.Errorf("verifyNPMSignatures() verified = %v, want %v: %s", check.Verified, tc.verified, check.Detail)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistryChecksum(t *testing.T) {
+	ctx := context.Background()
+	target := rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "bytes", Version: "1.0.0", Artifact: "bytes-1.0.0.crate"}
+	up := ArtifactSummary{Hash: hashext.NewMultiHash(crypto.SHA256)}
+	must(up.Hash.Write([]byte("content")))
+	for _, tc := range []struct {
+		name     string
+		checksum string
+		verified bool
+	}{
+		{"Match", "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73", true},
+		{"Mismatch", "0000000000000000000000000000000000000000000000000000000000000000", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &httpxtest.MockClient{
+				Calls: []httpxtest.Call{{
+					URL: "https://crates.io/api/v1/crates/bytes/1.0.0",
+					Response: &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(strings.NewReader(`{"version":{"num":"1.0.0","checksum":"` + tc.checksum + `"}}`)),
+					},
+				}},
+			}
+			mux := rebuild.RegistryMux{CratesIO: cratesio.HTTPRegistry{Client: client}}
+			check, err := VerifyRegistryChecksum(ctx, target, mux, up)
+			if err != nil {
+				t.Fatalf("VerifyRegistryChecksum() error: %v", err)
+			}
+			if check == nil || check.Verified != tc.verified {
+				t.Errorf("VerifyRegistryChecksum() = %+v, want verified = %v", check, tc.verified)
+			}
+		})
+	}
+}
+
+func TestVerifyPGPSignature(t *testing.T) {
+	signer := must(openpgp.NewEntity("Test", "", "test@example.com", nil))
+	artifact := []byte("artifact contents")
+	sig := new(bytes.Buffer)
+	orDie(openpgp.ArmoredDetachSign(sig, signer, bytes.NewReader(artifact), nil))
+	t.Run("Valid", func(t *testing.T) {
+		check := VerifyPGPSignature(bytes.NewReader(artifact), bytes.NewReader(sig.Bytes()), openpgp.EntityList{signer})
+		if !check.Verified {
+			t.Errorf("VerifyPGPSignature() not verified: %s", check.Detail)
+		}
+	})
+	t.Run("Modified", func(t *testing.T) {
+		check := VerifyPGPSignature(strings.NewReader("other contents"), bytes.NewReader(sig.Bytes()), openpgp.EntityList{signer})
+		if check.Verified {
+			t.Error("VerifyPGPSignature() verified modified artifact")
+		}
+	})
+	t.Run("UnknownSigner", func(t *testing.T) {
+		other := must(openpgp.NewEntity("Other", "", "other@example.com", nil))
+		check := VerifyPGPSignature(bytes.NewReader(artifact), bytes.NewReader(sig.Bytes()), openpgp.EntityList{other})
+		if check.Verified {
+			t.Error("VerifyPGPSignature() verified signature from unknown key")
+		}
+	})
+}
+
+func TestVerifyMavenChecksum(t *testing.T) {
+	ctx := context.Background()
+	target := rebuild.Target{Ecosystem: rebuild.Maven, Package: "junit:junit", Version: "4.13", Artifact: "junit-4.13.jar"}
+	up := ArtifactSummary{Hash: hashext.NewMultiHash(crypto.SHA256, crypto.SHA1)}
+	must(up.Hash.Write([]byte("content")))
+	for _, tc := range []struct {
+		name     string
+		checksum string
+		verified bool
+	}{
+		{"Match", "040f06fd774092478d450774f5ba30c5da78acc8", true},
+		{"MatchWithFileName", "040f06fd774092478d450774f5ba30c5da78acc8  junit-4.13.jar\n", true},
+		{"Mismatch", "0000000000000000000000000000000000000000", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &httpxtest.MockClient{
+				Calls: []httpxtest.Call{{
+					URL: "https://search.maven.org/remotecontent?filepath=junit/junit/4.13/junit-4.13.jar.sha1",
+					Response: &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(strings.NewReader(tc.checksum)),
+					},
+				}},
+			}
+			mux := rebuild.RegistryMux{Maven: maven.HTTPRegistry{Client: client}}
+			check, err := VerifyRegistryChecksum(ctx, target, mux, up)
+			if err != nil {
+				t.Fatalf("VerifyRegistryChecksum() error: %v", err)
+			}
+			if check == nil || check.Verified != tc.verified {
+				t.Errorf("VerifyRegistryChecksum() = %+v, want verified = %v", check, tc.verified)
+			}
+		})
+	}
+}
+
+func TestVerifyMavenSignature(t *testing.T) {
+	ctx := context.Background()
+	target := rebuild.Target{Ecosystem: rebuild.Maven, Package: "junit:junit", Version: "4.13", Artifact: "junit-4.13.jar"}
+	signer := must(openpgp.NewEntity("Test", "", "test@example.com", nil))
+	artifact := []byte("artifact contents")
+	sig := new(bytes.Buffer)
+	orDie(openpgp.ArmoredDetachSign(sig, signer, bytes.NewReader(artifact), nil))
+	for _, tc := range []struct {
+		name     string
+		served   string
+		verified bool
+	}{
+		{"Valid", string(artifact), true},
+		{"Modified", "other contents", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &httpxtest.MockClient{
+				Calls: []httpxtest.Call{
+					{
+						URL:      "https://search.maven.org/remotecontent?filepath=junit/junit/4.13/junit-4.13.jar",
+						Response: &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(tc.served))},
+					},
+					{
+						URL:      "https://search.maven.org/remotecontent?filepath=junit/junit/4.13/junit-4.13.jar.asc",
+						Response: &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(sig.Bytes()))},
+					},
+				},
+			}
+			check, err := VerifyMavenSignature(ctx, target, maven.HTTPRegistry{Client: client}, openpgp.EntityList{signer})
+			if err != nil {
+				t.Fatalf("VerifyMavenSignature() error: %v", err)
+			}
+			if check.Method != PGPSignatureMethod || check.Verified != tc.verified {
+				t.Errorf("VerifyMavenSignature() = %+v, want verified = %v", check, tc.verified)
+			}
+		})
+	}
+}
+
+func TestVerifyCargoVCSInfo(t *testing.T) {
+	const sha1 = "0123456789abcdef0123456789abcdef01234567"
+	withVCSInfo := func(value string) ArtifactSummary {
+		return ArtifactSummary{Stabilization: &archive.StabilizationReport{Removals: []archive.Removal{
+			{Stabilizer: archive.VCSInfoStabilizer, Path: "foo-1.0.0/.cargo_vcs_info.json", Value: value},
+		}}}
+	}
+	for _, tc := range []struct {
+		name    string
+		up      ArtifactSummary
+		ref     string
+		want    bool
+		wantNil bool
+	}{
+		{name: "Match", up: withVCSInfo(`{"git":{"sha1":"` + sha1 + `"},"path_in_vcs":""}`), ref: sha1, want: true},
+		{name: "Mismatch", up: withVCSInfo(`{"git":{"sha1":"` + sha1 + `"},"path_in_vcs":""}`), ref: strings.Repeat("f", 40)},
+		{name: "NoCommit", up: withVCSInfo(`{"path_in_vcs":""}`), wantNil: true},
+		{name: "NoVCSInfo", up: ArtifactSummary{Stabilization: &archive.StabilizationReport{}}, wantNil: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			check := VerifyCargoVCSInfo(tc.up, rebuild.Location{Ref: tc.ref})
+			if tc.wantNil {
+				if check != nil {
+					t.Errorf("VerifyCargoVCSInfo() = %+v, want nil", check)
+				}
+				return
+			}
+			if check == nil || check.Verified != tc.want {
+				t.Errorf("VerifyCargoVCSInfo() = %+v, want verified = %v", check, tc.want)
+			}
+		})
+	}
+}
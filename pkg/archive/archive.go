@@ -25,38 +25,61 @@ import (
 
 // Canonicalize selects and applies the canonicalization routine for the given archive format.
 func Canonicalize(dst io.Writer, src io.Reader, f Format) error {
+	_, err := CanonicalizeWithReport(dst, src, f)
+	return err
+}
+
+// CanonicalizeWithReport applies Canonicalize and reports the content it removed.
+func CanonicalizeWithReport(dst io.Writer, src io.Reader, f Format) (*StabilizationReport, error) {
+	return CanonicalizeWithOptions(dst, src, f, Options{})
+}
+
+// CanonicalizeWithOptions applies CanonicalizeWithReport along with the
+// configured opt-in stabilizers, which apply only to zip and tar archives.
+func CanonicalizeWithOptions(dst io.Writer, src io.Reader, f Format, opts Options) (*StabilizationReport, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	report := &StabilizationReport{Removals: []Removal{}}
 	switch f {
 	case ZipFormat:
 		srcReader, size, err := toZipCompatibleReader(src)
 		if err != nil {
-			return errors.Wrap(err, "converting reader")
+			return nil, errors.Wrap(err, "converting reader")
 		}
 		zr, err := zip.NewReader(srcReader, size)
 		if err != nil {
-			return errors.Wrap(err, "initializing zip reader")
+			return nil, errors.Wrap(err, "initializing zip reader")
 		}
-		zw := zip.NewWriter(dst)
-		defer zw.Close()
-		err = CanonicalizeZip(zr, zw)
+		err = canonicalizeZip(zr, NewDeterministicZipWriter(dst), opts, report)
 		if err != nil {
-			return errors.Wrap(err, "canonicalizing zip")
+			return nil, errors.Wrap(err, "canonicalizing zip")
 		}
 	case TarGzFormat:
 		gzr, err := gzip.NewReader(src)
 		if err != nil {
-			return errors.Wrap(err, "initializing gzip reader")
+			return nil, errors.Wrap(err, "initializing gzip reader")
 		}
 		defer gzr.Close()
 		gzw := gzip.NewWriter(dst)
 		defer gzw.Close()
-		err = CanonicalizeTar(tar.NewReader(gzr), tar.NewWriter(gzw))
+		err = canonicalizeTar(tar.NewReader(gzr), tar.NewWriter(gzw), opts, report)
 		if err != nil {
-			return errors.Wrap(err, "canonicalizing tar")
+			return nil, errors.Wrap(err, "canonicalizing tar")
+		}
+	case OCIFormat:
+		err := CanonicalizeOCI(tar.NewReader(src), tar.NewWriter(dst))
+		if err != nil {
+			return nil, errors.Wrap(err, "canonicalizing oci image")
+		}
+	case XMLFormat:
+		if err := CanonicalizeXML(dst, src); err != nil {
+			return nil, errors.Wrap(err, "canonicalizing xml")
 		}
 	default:
-		return errors.New("unsupported archive type")
+		return nil, errors.New("unsupported archive type")
 	}
-	return nil
+	return report, nil
 }
 
 // NewContentSummary constructs a ContentSummary for the given archive format.
@@ -79,7 +102,81 @@ func NewContentSummary(src io.Reader, f Format) (*ContentSummary, error) {
 		}
 		defer gzr.Close()
 		return NewContentSummaryFromTar(tar.NewReader(gzr))
+	case OCIFormat:
+		// NOTE: Only canonicalized images, which are uncompressed tars, are supported.
+		return NewContentSummaryFromTar(tar.NewReader(src))
+	default:
+		return nil, errors.New("unsupported archive type")
+	}
+}
+
+// ReadEntries returns the contents of the named entries in an archive of the given format.
+// Names absent from the archive are omitted from the result. Names are
+// matched in the normalized form reported by NewContentSummary.
+func ReadEntries(src io.Reader, f Format, names []string) (map[string][]byte, error) {
+	want := make(map[string]bool, len(names))
+	for _, n := range names {
+		want[n] = true
+	}
+	out := make(map[string][]byte)
+	switch f {
+	case ZipFormat:
+		srcReader, size, err := toZipCompatibleReader(src)
+		if err != nil {
+			return nil, errors.Wrap(err, "converting reader")
+		}
+		zr, err := zip.NewReader(srcReader, size)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing zip reader")
+		}
+		for _, zf := range zr.File {
+			name := zipEntryName(&zf.FileHeader)
+			if !want[name] {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, errors.Wrapf(err, "opening zip entry %s", zf.Name)
+			}
+			buf, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading zip entry %s", zf.Name)
+			}
+			out[name] = buf
+		}
+	case TarGzFormat, OCIFormat:
+		var tr *tar.Reader
+		if f == OCIFormat {
+			// NOTE: Only canonicalized images, which are uncompressed tars, are supported.
+			tr = tar.NewReader(src)
+		} else {
+			gzr, err := gzip.NewReader(src)
+			if err != nil {
+				return nil, errors.Wrap(err, "initializing gzip reader")
+			}
+			defer gzr.Close()
+			tr = tar.NewReader(gzr)
+		}
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, errors.Wrap(err, "reading tar header")
+			}
+			name := normalizeName(header.Name)
+			if !want[name] {
+				continue
+			}
+			buf, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading tar entry %s", header.Name)
+			}
+			out[name] = buf
+		}
 	default:
 		return nil, errors.New("unsupported archive type")
 	}
+	return out, nil
 }
@@ -15,6 +15,12 @@
 // Package archive provides common types and functions for archive processing.
 package archive
 
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
 // Format represents the archive types of packages.
 type Format int
 
@@ -25,8 +31,58 @@ const (
 	TarFormat
 	ZipFormat
 	RawFormat
+	// OCIFormat is a tar archive in the OCI image layout.
+	OCIFormat
+	// XMLFormat is a standalone XML document such as a Maven POM.
+	XMLFormat
 )
 
+// Stabilizers names the normalizations applied by CanonicalizeZip, CanonicalizeTar, CanonicalizeOCI, and CanonicalizeXML.
+// Entries must be updated alongside changes to canonicalization so that results
+// recorded by earlier runs can be distinguished from those of later ones.
+var Stabilizers = []string{
+	"zip-sort-entries",
+	"zip-clear-mtime",
+	"zip-deterministic-headers",
+	"tar-sort-entries",
+	"tar-clear-times",
+	"tar-clear-owner",
+	"tar-fixed-mode",
+	"tar-pax-format",
+	"oci-flatten-layers",
+	"oci-clear-config-times",
+	"oci-clear-diff-ids",
+	"xml-strip-whitespace",
+	"xml-normalize-namespaces",
+	"xml-sort-attributes",
+	"xml-utf8-encoding",
+	"zip-canonicalize-xml-descriptors",
+	"zip-strip-properties-timestamp",
+	"zip-normalize-build-info",
+	"zip-sort-kotlin-module-parts",
+	packageJSONStabilizer,
+	VCSInfoStabilizer,
+	cargoTOMLStabilizer,
+	nfcNameStabilizer,
+	zipNameEncodingStabilizer,
+}
+
+// StabilizationReport records the content removed from an archive by canonicalization.
+type StabilizationReport struct {
+	Removals []Removal `json:"removals"`
+}
+
+// Removal is content removed from an archive entry by a stabilizer.
+type Removal struct {
+	Stabilizer string `json:"stabilizer"`
+	Path       string `json:"path"`
+	// Field identifies the content removed from within the entry. Empty if
+	// the entry was removed entirely.
+	Field string `json:"field,omitempty"`
+	// Value, if recorded by the stabilizer, is the content removed.
+	Value string `json:"value,omitempty"`
+}
+
 // ContentSummary is a summary of rebuild-relevant features of an archive.
 type ContentSummary struct {
 	Files      []string
@@ -63,3 +119,74 @@ func (cs *ContentSummary) Diff(other *ContentSummary) (leftOnly, diffs, rightOnl
 	}
 	return
 }
+
+// Move is a file whose content is identical in two summaries but whose path differs.
+type Move struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// DiffWithMoves returns the result of Diff with files only in this summary
+// paired, as moves, with content-identical files only in the other summary.
+//
+// Where several files share content, pairs whose paths differ only in their
+// top-level directory (e.g. "foo-1.0/" and "foo-1.0.post1/") are preferred,
+// followed by pairs with the same base name.
+func (cs *ContentSummary) DiffWithMoves(other *ContentSummary) (leftOnly, diffs, rightOnly []string, moves []Move) {
+	leftOnly, diffs, rightOnly = cs.Diff(other)
+	leftHashes, rightHashes := cs.hashes(), other.hashes()
+	byHash := make(map[string][]string)
+	for _, f := range rightOnly {
+		byHash[rightHashes[f]] = append(byHash[rightHashes[f]], f)
+	}
+	paired := make(map[string]bool)
+	for _, affinity := range []func(a, b string) bool{
+		func(a, b string) bool { return stripTopDir(a) == stripTopDir(b) },
+		func(a, b string) bool { return path.Base(a) == path.Base(b) },
+		func(a, b string) bool { return true },
+	} {
+		for _, l := range leftOnly {
+			if paired[l] {
+				continue
+			}
+			candidates := byHash[leftHashes[l]]
+			for i, r := range candidates {
+				if affinity(l, r) {
+					moves = append(moves, Move{From: l, To: r})
+					paired[l], paired[r] = true, true
+					byHash[leftHashes[l]] = append(candidates[:i:i], candidates[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	if len(moves) == 0 {
+		return
+	}
+	unpaired := func(files []string) (out []string) {
+		for _, f := range files {
+			if !paired[f] {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+	leftOnly, rightOnly = unpaired(leftOnly), unpaired(rightOnly)
+	sort.Slice(moves, func(i, j int) bool { return moves[i].From < moves[j].From })
+	return
+}
+
+func (cs *ContentSummary) hashes() map[string]string {
+	m := make(map[string]string, len(cs.Files))
+	for i, f := range cs.Files {
+		m[f] = cs.FileHashes[i]
+	}
+	return m
+}
+
+func stripTopDir(p string) string {
+	if _, rest, found := strings.Cut(p, "/"); found {
+		return rest
+	}
+	return p
+}
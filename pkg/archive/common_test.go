@@ -106,3 +106,32 @@ func TestContentSummary_Diff(t *testing.T) {
 		})
 	}
 }
+
+func TestContentSummary_DiffWithMoves(t *testing.T) {
+	left := &ContentSummary{
+		Files:      []string{"foo-1.0/LICENSE", "foo-1.0/a/empty", "foo-1.0/b/empty", "foo-1.0/changed", "foo-1.0/setup.py"},
+		FileHashes: []string{"license", "empty", "empty", "hash1", "setup"},
+	}
+	right := &ContentSummary{
+		Files:      []string{"foo-1.0.post1/LICENSE", "foo-1.0.post1/b/empty", "foo-1.0.post1/c/empty", "foo-1.0/changed", "foo-1.0/extra"},
+		FileHashes: []string{"license", "empty", "empty", "hash2", "extra"},
+	}
+	gotLeft, gotDiffs, gotRight, gotMoves := left.DiffWithMoves(right)
+	if diff := cmp.Diff([]string{"foo-1.0/setup.py"}, gotLeft); diff != "" {
+		t.Errorf("leftOnly mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"foo-1.0/changed"}, gotDiffs); diff != "" {
+		t.Errorf("diffs mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"foo-1.0/extra"}, gotRight); diff != "" {
+		t.Errorf("rightOnly mismatch (-want +got):\n%s", diff)
+	}
+	wantMoves := []Move{
+		{From: "foo-1.0/LICENSE", To: "foo-1.0.post1/LICENSE"},
+		{From: "foo-1.0/a/empty", To: "foo-1.0.post1/c/empty"},
+		{From: "foo-1.0/b/empty", To: "foo-1.0.post1/b/empty"},
+	}
+	if diff := cmp.Diff(wantMoves, gotMoves); diff != "" {
+		t.Errorf("moves mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// VCSInfoStabilizer is the name under which the removal of Cargo's VCS info is reported.
+	// The removed content is recorded as the Removal's Value.
+	VCSInfoStabilizer = "crate-strip-vcs-info"
+	// cargoTOMLStabilizer is the name under which the normalization of a generated Cargo.toml is reported.
+	cargoTOMLStabilizer = "crate-normalize-cargo-toml"
+)
+
+// cargoGeneratedHeader begins the Cargo.toml rewritten by "cargo package".
+const cargoGeneratedHeader = "# THIS FILE IS AUTOMATICALLY GENERATED BY CARGO"
+
+// isCrateRootFile returns whether the tar entry at p is the named file at the root of a packaged crate.
+func isCrateRootFile(p, name string) bool {
+	// NOTE: Packaged crates nest the crate root within a single directory.
+	return path.Base(p) == name && strings.Count(p, "/") == 1
+}
+
+// canonicalizeCargoTOML rewrites a Cargo.toml generated by "cargo package"
+// with sorted keys and consistent formatting, dropping its comments, since the
+// formatting of the generated file varies between versions of Cargo. Files not
+// generated by Cargo, or that cannot be parsed, are returned unchanged.
+func canonicalizeCargoTOML(b []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(b, []byte(cargoGeneratedHeader)) {
+		return b, false
+	}
+	var m map[string]any
+	if err := toml.Unmarshal(b, &m); err != nil {
+		return b, false
+	}
+	out, err := toml.Marshal(m)
+	if err != nil {
+		return b, false
+	}
+	return out, true
+}
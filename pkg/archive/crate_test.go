@@ -0,0 +1,109 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCanonicalizeCargoTOML(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		input       string
+		want        string
+		wantChanged bool
+	}{
+		{
+			name:        "generated",
+			input:       cargoGeneratedHeader + "\n# more comments\n\n[package]\nversion = \"1.0.0\"\nname = \"foo\"\n\n[dependencies.bar]\nversion = \"2\"\n",
+			want:        "[dependencies]\n[dependencies.bar]\nversion = '2'\n\n[package]\nname = 'foo'\nversion = '1.0.0'\n",
+			wantChanged: true,
+		},
+		{
+			name:  "not generated",
+			input: "[package]\nname = \"foo\"\n",
+			want:  "[package]\nname = \"foo\"\n",
+		},
+		{
+			name:  "malformed",
+			input: cargoGeneratedHeader + "\n[package\n",
+			want:  cargoGeneratedHeader + "\n[package\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, changed := canonicalizeCargoTOML([]byte(tc.input))
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("canonicalizeCargoTOML() mismatch (-want +got):\n%s", diff)
+			}
+			if changed != tc.wantChanged {
+				t.Errorf("canonicalizeCargoTOML() changed = %v, want %v", changed, tc.wantChanged)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeCrate(t *testing.T) {
+	vcsInfo := `{"git":{"sha1":"0123456789abcdef0123456789abcdef01234567"},"path_in_vcs":""}`
+	cargoTOML := cargoGeneratedHeader + "\n[package]\nname = \"foo\"\n"
+	var input bytes.Buffer
+	{
+		gzw := gzip.NewWriter(&input)
+		tw := tar.NewWriter(gzw)
+		for _, e := range []TarEntry{
+			{&tar.Header{Name: "foo-1.0.0/.cargo_vcs_info.json", Size: int64(len(vcsInfo)), Mode: 0644}, []byte(vcsInfo)},
+			{&tar.Header{Name: "foo-1.0.0/Cargo.toml", Size: int64(len(cargoTOML)), Mode: 0644}, []byte(cargoTOML)},
+			{&tar.Header{Name: "foo-1.0.0/Cargo.toml.orig", Size: 17, Mode: 0644}, []byte("[package]\nname=1\n")},
+		} {
+			orDie(e.WriteTo(tw))
+		}
+		orDie(tw.Close())
+		orDie(gzw.Close())
+	}
+	var output bytes.Buffer
+	report, err := CanonicalizeWithReport(&output, &input, TarGzFormat)
+	if err != nil {
+		t.Fatalf("CanonicalizeWithReport() error = %v", err)
+	}
+	want := &StabilizationReport{Removals: []Removal{
+		{Stabilizer: VCSInfoStabilizer, Path: "foo-1.0.0/.cargo_vcs_info.json", Value: vcsInfo},
+		{Stabilizer: cargoTOMLStabilizer, Path: "foo-1.0.0/Cargo.toml", Field: "comments"},
+	}}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("CanonicalizeWithReport() report mismatch (-want +got):\n%s", diff)
+	}
+	tr := tar.NewReader(must(gzip.NewReader(&output)))
+	got := make(map[string]string)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		orDie(err)
+		got[h.Name] = string(must(io.ReadAll(tr)))
+	}
+	wantFiles := map[string]string{
+		"foo-1.0.0/Cargo.toml":      "[package]\nname = 'foo'\n",
+		"foo-1.0.0/Cargo.toml.orig": "[package]\nname=1\n",
+	}
+	if diff := cmp.Diff(wantFiles, got); diff != "" {
+		t.Errorf("CanonicalizeWithReport() output mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	toml "github.com/pelletier/go-toml/v2"
+)
+
+// The seeds below mirror the layout of artifacts published to each registry.
+
+func tgzSeed(t testing.TB, entries ...*TarEntry) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.ModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		if err := e.WriteTo(tw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipSeed(t testing.TB, entries ...*ZipEntry) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		if err := e.WriteTo(zw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarFile(name, body string) *TarEntry {
+	return &TarEntry{&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(body)), ModTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Uname: "user"}, []byte(body)}
+}
+
+func zipFile(name, body string) *ZipEntry {
+	return &ZipEntry{&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, []byte(body)}
+}
+
+// checkIdempotent verifies that canonicalizing a canonicalized archive leaves it unchanged.
+func checkIdempotent(t *testing.T, data []byte, f Format) {
+	var first bytes.Buffer
+	if _, err := CanonicalizeWithReport(&first, bytes.NewReader(data), f); err != nil {
+		return
+	}
+	var second bytes.Buffer
+	if _, err := CanonicalizeWithReport(&second, bytes.NewReader(first.Bytes()), f); err != nil {
+		t.Fatalf("canonicalizing canonical archive: %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatal("canonicalization is not idempotent")
+	}
+	if _, err := NewContentSummary(bytes.NewReader(first.Bytes()), f); err != nil {
+		t.Fatalf("summarizing canonical archive: %v", err)
+	}
+}
+
+func FuzzCanonicalizeTarGz(f *testing.F) {
+	f.Add(tgzSeed(f,
+		tarFile("package/package.json", `{"name":"left-pad","version":"1.3.0","gitHead":"abc","_id":"left-pad@1.3.0"}`),
+		tarFile("package/index.js", "module.exports = leftPad;\r\n"),
+	))
+	f.Add(tgzSeed(f,
+		tarFile("bytes-1.0.0/.cargo_vcs_info.json", `{"git":{"sha1":"0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"},"path_in_vcs":""}`),
+		tarFile("bytes-1.0.0/Cargo.toml", cargoGeneratedHeader+"\n[package]\nname = \"bytes\"\nversion = \"1.0.0\"\n"),
+		tarFile("bytes-1.0.0/src/lib.rs", "pub fn f() {}\n"),
+	))
+	f.Add(tgzSeed(f,
+		tarFile("absl-py-2.0.0/PKG-INFO", "Metadata-Version: 2.1\nName: absl-py\nVersion: 2.0.0\n"),
+		&TarEntry{&tar.Header{Name: "absl-py-2.0.0/absl/", Typeflag: tar.TypeDir, Mode: 0755}, nil},
+		&TarEntry{&tar.Header{Name: "absl-py-2.0.0/link", Typeflag: tar.TypeSymlink, Linkname: "PKG-INFO"}, nil},
+	))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkIdempotent(t, data, TarGzFormat)
+	})
+}
+
+func FuzzCanonicalizeZip(f *testing.F) {
+	f.Add(zipSeed(f,
+		zipFile("absl/__init__.py", ""),
+		zipFile("absl_py-2.0.0.dist-info/METADATA", "Metadata-Version: 2.1\nName: absl-py\nVersion: 2.0.0\n"),
+		zipFile("absl_py-2.0.0.dist-info/RECORD", "absl/__init__.py,sha256=47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU,0\n"),
+	))
+	f.Add(zipSeed(f,
+		zipFile("META-INF/MANIFEST.MF", "Manifest-Version: 1.0\r\nBuild-Jdk-Spec: 17\r\nBnd-LastModified: 1704067200000\r\n"),
+		zipFile("com/example/App.class", "\xca\xfe\xba\xbe"),
+		zipFile("META-INF/maven/com.example/app/pom.properties", "#Mon Jan 01 00:00:00 UTC 2024\nversion=1.0.0\n"),
+	))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkIdempotent(t, data, ZipFormat)
+	})
+}
+
+func FuzzEntryTimestamps(f *testing.F) {
+	f.Add("META-INF/MANIFEST.MF", []byte("Manifest-Version: 1.0\r\nBuild-Date: 2024-01-01 00:00:00\r\nBnd-LastModified: 1704067200000\r\n"))
+	f.Add("lib/native.dll", []byte("MZ\x90\x00"))
+	f.Fuzz(func(t *testing.T, name string, content []byte) {
+		for _, ts := range entryTimestamps(name, content) {
+			if ts.Path != name {
+				t.Errorf("timestamp path = %q, want %q", ts.Path, name)
+			}
+		}
+	})
+}
+
+func FuzzCanonicalizePackageJSON(f *testing.F) {
+	f.Add([]byte(`{"name":"left-pad","version":"1.3.0","gitHead":"abc","_id":"left-pad@1.3.0","scripts":{"test":"jest"}}`))
+	f.Add([]byte(`{"name":"x","version":1e400,"nested":{"b":1,"a":[null,true]}}`))
+	f.Add([]byte(`[]`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		out, _ := canonicalizePackageJSON(b)
+		again, removed := canonicalizePackageJSON(out)
+		if len(removed) > 0 {
+			t.Errorf("canonical package.json retained injected fields: %v", removed)
+		}
+		if !bytes.Equal(out, again) {
+			t.Error("package.json canonicalization is not idempotent")
+		}
+	})
+}
+
+func FuzzCanonicalizeCargoTOML(f *testing.F) {
+	f.Add([]byte(cargoGeneratedHeader + "\n[package]\nname = \"bytes\"\nversion = \"1.0.0\"\n\n[dependencies.serde]\nversion = \"1\"\noptional = true\n"))
+	f.Add([]byte(cargoGeneratedHeader + "\n[[bin]]\nname = \"a\"\n[[bin]]\nname = \"b\"\n"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		out, ok := canonicalizeCargoTOML(b)
+		if !ok {
+			return
+		}
+		var m map[string]any
+		if err := toml.Unmarshal(out, &m); err != nil {
+			t.Errorf("canonical Cargo.toml does not parse: %v", err)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// canonicalizeEntry returns the canonical form of the body of a zip archive
+// entry. Entries not generated by a known tool, or that cannot be parsed as
+// expected, are returned unchanged.
+func canonicalizeEntry(name string, b []byte) []byte {
+	switch base := path.Base(name); {
+	case base == "pom.properties" && strings.HasPrefix(name, "META-INF/maven/"):
+		return canonicalizeProperties(b, false)
+	case base == "build-info.properties":
+		return canonicalizeProperties(b, true, "build.time")
+	case strings.HasSuffix(base, ".kotlin_module") && strings.HasPrefix(name, "META-INF/"):
+		return canonicalizeKotlinModule(b)
+	case isXMLDescriptor(name):
+		return canonicalizeXMLEntry(b)
+	default:
+		return b
+	}
+}
+
+// propertiesTimestampLayout is the format of the timestamp comment written by java.util.Properties.store.
+const propertiesTimestampLayout = "Mon Jan 02 15:04:05 MST 2006"
+
+// canonicalizeProperties removes the timestamp comment from a Java properties
+// file, along with the named volatile properties, and normalizes line endings.
+// If sortEntries is set, the remaining properties are sorted since their order
+// depends on the iteration order of the writer's hash table.
+func canonicalizeProperties(b []byte, sortEntries bool, drop ...string) []byte {
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	var comments, entries []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!"):
+			if _, err := time.Parse(propertiesTimestampLayout, strings.TrimSpace(trimmed[1:])); err != nil {
+				comments = append(comments, line)
+			}
+		case strings.HasSuffix(trimmed, `\`):
+			// NOTE: Values continued across lines are not supported.
+			return b
+		default:
+			key, _, _ := strings.Cut(strings.NewReplacer(":", "=", " ", "=").Replace(trimmed), "=")
+			if !slices.Contains(drop, key) {
+				entries = append(entries, line)
+			}
+		}
+	}
+	if sortEntries {
+		slices.Sort(entries)
+	}
+	var out strings.Builder
+	for _, line := range append(comments, entries...) {
+		out.WriteString(line + "\n")
+	}
+	return []byte(out.String())
+}
+
+// canonicalizeKotlinModule sorts the package parts listed in Kotlin module
+// metadata, the order of which depends on the order in which the compiler
+// visited the module's files.
+//
+// The metadata is a big-endian, length-prefixed list of version numbers,
+// optionally followed by a flags word, followed by a Module protobuf message.
+func canonicalizeKotlinModule(b []byte) []byte {
+	if len(b) < 4 {
+		return b
+	}
+	n := int(binary.BigEndian.Uint32(b))
+	header := 4 + 4*n
+	if n > 8 || len(b) < header {
+		return b
+	}
+	for _, offset := range []int{header, header + 4} {
+		if offset > len(b) {
+			break
+		}
+		if body, ok := sortModuleParts(b[offset:]); ok {
+			return append(slices.Clone(b[:offset]), body...)
+		}
+	}
+	return b
+}
+
+// Field numbers of the Module message containing lists of package parts.
+const (
+	modulePackageParts  = 1
+	moduleMetadataParts = 2
+)
+
+// sortModuleParts sorts the package parts fields of the encoded Module message
+// among their own positions, retaining the order of all other fields.
+func sortModuleParts(b []byte) ([]byte, bool) {
+	var fields [][]byte
+	var nums []protowire.Number
+	for rest := b; len(rest) > 0; {
+		num, typ, n := protowire.ConsumeTag(rest)
+		if n < 0 {
+			return nil, false
+		}
+		m := protowire.ConsumeFieldValue(num, typ, rest[n:])
+		if m < 0 {
+			return nil, false
+		}
+		fields = append(fields, rest[:n+m])
+		nums = append(nums, num)
+		rest = rest[n+m:]
+	}
+	for _, num := range []protowire.Number{modulePackageParts, moduleMetadataParts} {
+		var idx []int
+		var parts [][]byte
+		for i := range fields {
+			if nums[i] == num {
+				idx = append(idx, i)
+				parts = append(parts, fields[i])
+			}
+		}
+		slices.SortFunc(parts, bytes.Compare)
+		for j, i := range idx {
+			fields[i] = parts[j]
+		}
+	}
+	return bytes.Join(fields, nil), true
+}
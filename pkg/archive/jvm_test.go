@@ -0,0 +1,93 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestCanonicalizeEntry(t *testing.T) {
+	// Version 1.9.0 followed by empty flags.
+	kotlinHeader := []byte{0, 0, 0, 3, 0, 0, 0, 1, 0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 0}
+	kotlinModule := func(header []byte, parts ...string) []byte {
+		b := append([]byte{}, header...)
+		for _, p := range parts {
+			b = protowire.AppendTag(b, modulePackageParts, protowire.BytesType)
+			b = protowire.AppendString(b, p)
+		}
+		// A field other than the package parts which must retain its position.
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		return protowire.AppendString(b, "strings")
+	}
+	for _, tc := range []struct {
+		name  string
+		entry string
+		body  string
+		want  string
+	}{
+		{
+			name:  "pom.properties",
+			entry: "META-INF/maven/com.example/foo/pom.properties",
+			body:  "#Generated by Maven\r\n#Tue Mar 05 14:21:08 CET 2024\r\nversion=1.0\r\ngroupId=com.example\r\nartifactId=foo\r\n",
+			want:  "#Generated by Maven\nversion=1.0\ngroupId=com.example\nartifactId=foo\n",
+		},
+		{
+			name:  "unrelated properties",
+			entry: "config/pom.properties",
+			body:  "#Tue Mar 05 14:21:08 CET 2024\nversion=1.0\n",
+			want:  "#Tue Mar 05 14:21:08 CET 2024\nversion=1.0\n",
+		},
+		{
+			name:  "build-info.properties",
+			entry: "META-INF/build-info.properties",
+			body:  "#Properties\n#Tue Mar 05 14:21:08 UTC 2024\nbuild.version=1.0\nbuild.time=2024-03-05T14\\:21\\:08.123Z\nbuild.artifact=foo\n",
+			want:  "#Properties\nbuild.artifact=foo\nbuild.version=1.0\n",
+		},
+		{
+			name:  "continued properties",
+			entry: "META-INF/build-info.properties",
+			body:  "build.version=1.0\nbuild.description=a \\\n  b\n",
+			want:  "build.version=1.0\nbuild.description=a \\\n  b\n",
+		},
+		{
+			name:  "kotlin module",
+			entry: "META-INF/foo.kotlin_module",
+			body:  string(kotlinModule(kotlinHeader, "com/example/b", "com/example/a")),
+			want:  string(kotlinModule(kotlinHeader, "com/example/a", "com/example/b")),
+		},
+		{
+			name:  "kotlin module without flags",
+			entry: "META-INF/foo.kotlin_module",
+			body:  string(kotlinModule(kotlinHeader[:16], "com/example/b", "com/example/a")),
+			want:  string(kotlinModule(kotlinHeader[:16], "com/example/a", "com/example/b")),
+		},
+		{
+			name:  "malformed kotlin module",
+			entry: "META-INF/foo.kotlin_module",
+			body:  "\x00\x00\x00\x01\x00\x00\x00\x01\xff",
+			want:  "\x00\x00\x00\x01\x00\x00\x00\x01\xff",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := canonicalizeEntry(tc.entry, []byte(tc.body))
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("canonicalizeEntry() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LineEndingStabilizer is the name under which the normalization of line endings is reported.
+const LineEndingStabilizer = "line-endings-lf"
+
+// Options configures the opt-in stabilizers applied by CanonicalizeWithOptions.
+//
+// Unlike those listed in Stabilizers, these may mask meaningful differences
+// and so are applied only to the entries for which they are configured.
+type Options struct {
+	// LineEndingPaths are patterns, in path.Match syntax, of the text entries
+	// whose CRLF line endings are normalized to LF. Patterns containing no
+	// separator are matched against the entry's base name and others against
+	// its full path.
+	LineEndingPaths []string
+}
+
+// Validate returns an error if any of the configured patterns is malformed.
+func (o Options) Validate() error {
+	for _, p := range o.LineEndingPaths {
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Wrapf(err, "line ending pattern %q", p)
+		}
+	}
+	return nil
+}
+
+func (o Options) normalizesLineEndings(name string) bool {
+	for _, p := range o.LineEndingPaths {
+		target := name
+		if !strings.Contains(p, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(p, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stabilizeLineEndings normalizes the line endings of the entry if
+// configured, recording the number of carriage returns removed.
+func (o Options) stabilizeLineEndings(name string, b []byte, report *StabilizationReport) []byte {
+	if !o.normalizesLineEndings(name) {
+		return b
+	}
+	// NOTE: Entries with NUL bytes are presumed binary despite matching.
+	if bytes.IndexByte(b, 0) != -1 {
+		return b
+	}
+	n := bytes.Count(b, []byte("\r\n"))
+	if n == 0 {
+		return b
+	}
+	report.Removals = append(report.Removals, Removal{Stabilizer: LineEndingStabilizer, Path: name, Field: fmt.Sprintf("%d CRLF", n)})
+	return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+}
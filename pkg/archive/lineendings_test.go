@@ -0,0 +1,88 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCanonicalizeLineEndings(t *testing.T) {
+	var input bytes.Buffer
+	{
+		zw := zip.NewWriter(&input)
+		for _, e := range []ZipEntry{
+			{&zip.FileHeader{Name: "pkg/README.md"}, []byte("a\r\nb\r\n")},
+			{&zip.FileHeader{Name: "pkg/docs/guide.txt"}, []byte("a\r\nb")},
+			{&zip.FileHeader{Name: "pkg/data.txt"}, []byte("a\r\n\x00")},
+			{&zip.FileHeader{Name: "pkg/main.py"}, []byte("a\r\n")},
+		} {
+			orDie(e.WriteTo(zw))
+		}
+		orDie(zw.Close())
+	}
+	opts := Options{LineEndingPaths: []string{"*.md", "*.txt"}}
+	var output bytes.Buffer
+	report, err := CanonicalizeWithOptions(&output, bytes.NewReader(input.Bytes()), ZipFormat, opts)
+	if err != nil {
+		t.Fatalf("CanonicalizeWithOptions() error = %v", err)
+	}
+	want := &StabilizationReport{Removals: []Removal{
+		{Stabilizer: LineEndingStabilizer, Path: "pkg/README.md", Field: "2 CRLF"},
+		{Stabilizer: LineEndingStabilizer, Path: "pkg/docs/guide.txt", Field: "1 CRLF"},
+	}}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("CanonicalizeWithOptions() report mismatch (-want +got):\n%s", diff)
+	}
+	zr := must(zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len())))
+	got := make(map[string]string)
+	for _, f := range zr.File {
+		got[f.Name] = string(must(io.ReadAll(must(f.Open()))))
+	}
+	wantFiles := map[string]string{
+		"pkg/README.md":      "a\nb\n",
+		"pkg/docs/guide.txt": "a\nb",
+		"pkg/data.txt":       "a\r\n\x00",
+		"pkg/main.py":        "a\r\n",
+	}
+	if diff := cmp.Diff(wantFiles, got); diff != "" {
+		t.Errorf("CanonicalizeWithOptions() output mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestOptionsNormalizesLineEndings(t *testing.T) {
+	opts := Options{LineEndingPaths: []string{"*.md", "package/lib/*.js"}}
+	for _, tc := range []struct {
+		name string
+		want bool
+	}{
+		{"package/README.md", true},
+		{"package/docs/a/b.md", true},
+		{"package/lib/index.js", true},
+		{"package/lib/sub/index.js", false},
+		{"package/index.js", false},
+	} {
+		if got := opts.normalizesLineEndings(tc.name); got != tc.want {
+			t.Errorf("normalizesLineEndings(%q) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if err := (Options{LineEndingPaths: []string{"[a"}}).Validate(); err == nil {
+		t.Error("Validate() succeeded for malformed pattern")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"hash/crc32"
+	"strconv"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// nfcNameStabilizer is the name under which entry names rewritten to Unicode NFC are reported.
+	nfcNameStabilizer = "unicode-nfc-entry-names"
+	// zipNameEncodingStabilizer is the name under which zip entry names decoded to UTF-8 are reported.
+	zipNameEncodingStabilizer = "zip-decode-entry-names"
+)
+
+// unicodePathExtraID identifies the Info-ZIP Unicode Path extra field.
+const unicodePathExtraID = 0x7075
+
+// normalizeName returns the NFC form of an entry name.
+//
+// Archives produced on macOS commonly use the decomposed (NFD) form while
+// those produced elsewhere use the composed (NFC) form of the same name.
+func normalizeName(name string) string {
+	return norm.NFC.String(name)
+}
+
+// stabilizeName normalizes an entry name, recording the original if it changed.
+func stabilizeName(name string, report *StabilizationReport) string {
+	normalized := normalizeName(name)
+	if normalized != name {
+		report.Removals = append(report.Removals, Removal{Stabilizer: nfcNameStabilizer, Path: normalized, Field: "name", Value: strconv.QuoteToASCII(name)})
+	}
+	return normalized
+}
+
+// decodeZipName returns the UTF-8 name of a zip entry and whether it was
+// decoded from another encoding.
+//
+// Names are read from the Info-ZIP Unicode Path extra field, if present and
+// consistent with the header's name. Otherwise names that are not valid
+// UTF-8 are decoded as CP437, the encoding specified by the zip format absent
+// the UTF-8 flag. Valid UTF-8 names are retained regardless of the flag since
+// many tools write UTF-8 names without setting it.
+func decodeZipName(fh *zip.FileHeader) (string, bool) {
+	if name, ok := unicodePathExtra(fh); ok && name != fh.Name {
+		return name, true
+	}
+	if utf8.ValidString(fh.Name) {
+		return fh.Name, false
+	}
+	name, err := charmap.CodePage437.NewDecoder().String(fh.Name)
+	if err != nil {
+		return fh.Name, false
+	}
+	return name, true
+}
+
+// unicodePathExtra returns the name recorded in the Info-ZIP Unicode Path
+// extra field if it was derived from the header's current name.
+func unicodePathExtra(fh *zip.FileHeader) (string, bool) {
+	extra := fh.Extra
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			return "", false
+		}
+		field := extra[4 : 4+size]
+		extra = extra[4+size:]
+		if id != unicodePathExtraID {
+			continue
+		}
+		// NOTE: The field is a version byte, the CRC-32 of the header's name,
+		// and the UTF-8 name. A mismatched CRC indicates the name was since
+		// changed by a tool unaware of the field, which must then be ignored.
+		if len(field) < 5 || field[0] != 1 {
+			return "", false
+		}
+		if binary.LittleEndian.Uint32(field[1:5]) != crc32.ChecksumIEEE([]byte(fh.Name)) {
+			return "", false
+		}
+		if name := string(field[5:]); utf8.ValidString(name) {
+			return name, true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// zipEntryName returns the normalized UTF-8 name of a zip entry.
+func zipEntryName(fh *zip.FileHeader) string {
+	name, _ := decodeZipName(fh)
+	return normalizeName(name)
+}
+
+// stabilizeZipName decodes and normalizes the name of a zip entry, recording the original if it changed.
+func stabilizeZipName(fh *zip.FileHeader, report *StabilizationReport) string {
+	name, decoded := decodeZipName(fh)
+	if decoded {
+		report.Removals = append(report.Removals, Removal{Stabilizer: zipNameEncodingStabilizer, Path: name, Field: "name", Value: strconv.QuoteToASCII(fh.Name)})
+	}
+	return stabilizeName(name, report)
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func unicodePathField(header, name string) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, unicodePathExtraID)
+	b = binary.LittleEndian.AppendUint16(b, uint16(5+len(name)))
+	b = append(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE([]byte(header)))
+	return append(b, name...)
+}
+
+func TestDecodeZipName(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		header      zip.FileHeader
+		want        string
+		wantDecoded bool
+	}{
+		{
+			name:   "ascii",
+			header: zip.FileHeader{Name: "a/b.txt"},
+			want:   "a/b.txt",
+		},
+		{
+			name:   "utf-8 without flag",
+			header: zip.FileHeader{Name: "café.txt", NonUTF8: true},
+			want:   "café.txt",
+		},
+		{
+			name:        "cp437",
+			header:      zip.FileHeader{Name: "caf\x82.txt", NonUTF8: true},
+			want:        "café.txt",
+			wantDecoded: true,
+		},
+		{
+			name:        "unicode path extra",
+			header:      zip.FileHeader{Name: "caf?.txt", Extra: unicodePathField("caf?.txt", "café.txt")},
+			want:        "café.txt",
+			wantDecoded: true,
+		},
+		{
+			name:   "stale unicode path extra",
+			header: zip.FileHeader{Name: "renamed.txt", Extra: unicodePathField("caf?.txt", "café.txt")},
+			want:   "renamed.txt",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, decoded := decodeZipName(&tc.header)
+			if got != tc.want || decoded != tc.wantDecoded {
+				t.Errorf("decodeZipName() = (%q, %v), want (%q, %v)", got, decoded, tc.want, tc.wantDecoded)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeEntryNames(t *testing.T) {
+	const nfd, nfc = "pkg/cafe\u0301.txt", "pkg/caf\u00e9.txt"
+	t.Run("zip", func(t *testing.T) {
+		var input bytes.Buffer
+		zw := zip.NewWriter(&input)
+		for _, e := range []ZipEntry{
+			{&zip.FileHeader{Name: nfd}, []byte("a")},
+			{&zip.FileHeader{Name: "pkg/r\x82sum\x82.txt", NonUTF8: true}, []byte("b")},
+		} {
+			orDie(e.WriteTo(zw))
+		}
+		orDie(zw.Close())
+		var output bytes.Buffer
+		report, err := CanonicalizeWithReport(&output, bytes.NewReader(input.Bytes()), ZipFormat)
+		if err != nil {
+			t.Fatalf("CanonicalizeWithReport() error = %v", err)
+		}
+		want := []Removal{
+			{Stabilizer: nfcNameStabilizer, Path: nfc, Field: "name", Value: `"pkg/cafe\u0301.txt"`},
+			{Stabilizer: zipNameEncodingStabilizer, Path: "pkg/résumé.txt", Field: "name", Value: `"pkg/r\x82sum\x82.txt"`},
+		}
+		if diff := cmp.Diff(want, report.Removals); diff != "" {
+			t.Errorf("CanonicalizeWithReport() report mismatch (-want +got):\n%s", diff)
+		}
+		zr := must(zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len())))
+		var got []string
+		for _, f := range zr.File {
+			got = append(got, f.Name)
+		}
+		if diff := cmp.Diff([]string{nfc, "pkg/résumé.txt"}, got); diff != "" {
+			t.Errorf("CanonicalizeWithReport() names mismatch (-want +got):\n%s", diff)
+		}
+		entries := must(ReadEntries(bytes.NewReader(input.Bytes()), ZipFormat, []string{nfc}))
+		if diff := cmp.Diff(map[string][]byte{nfc: []byte("a")}, entries); diff != "" {
+			t.Errorf("ReadEntries() mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("tar", func(t *testing.T) {
+		var input bytes.Buffer
+		gzw := gzip.NewWriter(&input)
+		tw := tar.NewWriter(gzw)
+		orDie(TarEntry{&tar.Header{Name: nfd, Size: 1, Mode: 0644}, []byte("a")}.WriteTo(tw))
+		orDie(tw.Close())
+		orDie(gzw.Close())
+		var output bytes.Buffer
+		report, err := CanonicalizeWithReport(&output, bytes.NewReader(input.Bytes()), TarGzFormat)
+		if err != nil {
+			t.Fatalf("CanonicalizeWithReport() error = %v", err)
+		}
+		want := []Removal{{Stabilizer: nfcNameStabilizer, Path: nfc, Field: "name", Value: `"pkg/cafe\u0301.txt"`}}
+		if diff := cmp.Diff(want, report.Removals); diff != "" {
+			t.Errorf("CanonicalizeWithReport() report mismatch (-want +got):\n%s", diff)
+		}
+		tr := tar.NewReader(must(gzip.NewReader(&output)))
+		h := must(tr.Next())
+		if h.Name != nfc {
+			t.Errorf("CanonicalizeWithReport() name = %q, want %q", h.Name, nfc)
+		}
+		if _, err := tr.Next(); err != io.EOF {
+			t.Errorf("CanonicalizeWithReport() unexpected entry: %v", err)
+		}
+	})
+}
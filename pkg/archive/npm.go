@@ -0,0 +1,66 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"slices"
+	"strings"
+)
+
+// packageJSONStabilizer is the name under which package.json removals are reported.
+const packageJSONStabilizer = "npm-normalize-package-json"
+
+// npmInjectedFields are the package.json fields added by npm clients and the
+// registry at publish time rather than by the package's author. Fields
+// prefixed with an underscore are likewise injected.
+var npmInjectedFields = []string{"gitHead", "readmeFilename"}
+
+// isPackageManifest returns whether the tar entry at p is the manifest of the package it contains.
+func isPackageManifest(p string) bool {
+	// NOTE: Packed npm archives nest the package root within a single directory.
+	return path.Base(p) == "package.json" && strings.Count(p, "/") == 1
+}
+
+// canonicalizePackageJSON removes the publish-time fields from a package.json
+// and rewrites it with sorted keys and consistent formatting, returning the
+// fields removed. Manifests that cannot be parsed are returned unchanged.
+func canonicalizePackageJSON(b []byte) ([]byte, []string) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var m map[string]any
+	if err := d.Decode(&m); err != nil || m == nil {
+		return b, nil
+	}
+	var removed []string
+	for k := range m {
+		if strings.HasPrefix(k, "_") || slices.Contains(npmInjectedFields, k) {
+			removed = append(removed, k)
+			delete(m, k)
+		}
+	}
+	slices.Sort(removed)
+	// NOTE: Maps are encoded with sorted keys, including those of nested objects.
+	var out bytes.Buffer
+	e := json.NewEncoder(&out)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	if err := e.Encode(m); err != nil {
+		return b, nil
+	}
+	return out.Bytes(), removed
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCanonicalizePackageJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		input       string
+		want        string
+		wantRemoved []string
+	}{
+		{
+			name:  "sorted",
+			input: "{\r\n\"version\": \"1.0.0\", \"name\": \"foo\",\r\n\"scripts\": {\"test\": \"a && b\", \"build\": \"tsc\"}, \"n\": 1.50}",
+			want:  "{\n  \"n\": 1.50,\n  \"name\": \"foo\",\n  \"scripts\": {\n    \"build\": \"tsc\",\n    \"test\": \"a && b\"\n  },\n  \"version\": \"1.0.0\"\n}\n",
+		},
+		{
+			name:        "injected fields",
+			input:       `{"name":"foo","_id":"foo@1.0.0","_from":"foo@latest","gitHead":"abc123","readmeFilename":"README.md"}`,
+			want:        "{\n  \"name\": \"foo\"\n}\n",
+			wantRemoved: []string{"_from", "_id", "gitHead", "readmeFilename"},
+		},
+		{
+			name:  "malformed",
+			input: `{"name":`,
+			want:  `{"name":`,
+		},
+		{
+			name:  "not an object",
+			input: `["foo"]`,
+			want:  `["foo"]`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, removed := canonicalizePackageJSON([]byte(tc.input))
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("canonicalizePackageJSON() mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantRemoved, removed); diff != "" {
+				t.Errorf("canonicalizePackageJSON() removed mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeWithReport(t *testing.T) {
+	var input bytes.Buffer
+	{
+		gzw := gzip.NewWriter(&input)
+		tw := tar.NewWriter(gzw)
+		for _, e := range []TarEntry{
+			{&tar.Header{Name: "package/package.json", Size: 30, Mode: 0644}, []byte(`{"name":"foo","_id":"foo@1.0"}`)},
+			{&tar.Header{Name: "package/lib/package.json", Size: 14, Mode: 0644}, []byte(`{"_id":"nest"}`)},
+		} {
+			orDie(e.WriteTo(tw))
+		}
+		orDie(tw.Close())
+		orDie(gzw.Close())
+	}
+	var output bytes.Buffer
+	report, err := CanonicalizeWithReport(&output, &input, TarGzFormat)
+	if err != nil {
+		t.Fatalf("CanonicalizeWithReport() error = %v", err)
+	}
+	want := &StabilizationReport{Removals: []Removal{
+		{Stabilizer: packageJSONStabilizer, Path: "package/package.json", Field: "_id"},
+	}}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("CanonicalizeWithReport() report mismatch (-want +got):\n%s", diff)
+	}
+	tr := tar.NewReader(must(gzip.NewReader(&output)))
+	got := make(map[string]string)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		orDie(err)
+		got[h.Name] = string(must(io.ReadAll(tr)))
+	}
+	wantFiles := map[string]string{
+		"package/package.json":     "{\n  \"name\": \"foo\"\n}\n",
+		"package/lib/package.json": `{"_id":"nest"}`,
+	}
+	if diff := cmp.Diff(wantFiles, got); diff != "" {
+		t.Errorf("CanonicalizeWithReport() output mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// OCIConfigPath is the path of the image configuration within a canonicalized OCI image.
+const OCIConfigPath = "config.json"
+
+// OCILayerDir returns the directory containing the files of the i-th layer
+// within a canonicalized OCI image.
+func OCILayerDir(i int) string {
+	return fmt.Sprintf("layers/%d", i)
+}
+
+// ociDescriptor is the subset of an OCI content descriptor used for canonicalization.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		OS string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// ociDocument is the union of the OCI index and manifest fields used for canonicalization.
+type ociDocument struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Config    *ociDescriptor  `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// CanonicalizeOCI re-writes a tar archive in the OCI image layout as a tar of
+// the image's stabilized config followed by the canonicalized files of each
+// layer, in order. This allows images to be compared layer-by-layer and
+// file-by-file using the same machinery as other archives.
+//
+// The image must contain exactly one platform-specific manifest. Attestation
+// manifests, marked by an "unknown" platform, are ignored.
+func CanonicalizeOCI(tr *tar.Reader, tw *tar.Writer) error {
+	defer tw.Close()
+	// TODO: Memory-intensive. Layers could be streamed if the layout was read twice.
+	blobs := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		buf, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		blobs[path.Clean(header.Name)] = buf
+	}
+	index, ok := blobs["index.json"]
+	if !ok {
+		return errors.New("missing index.json")
+	}
+	m, err := resolveOCIManifest(index, blobs)
+	if err != nil {
+		return err
+	}
+	if m.Config == nil {
+		return errors.New("manifest missing config")
+	}
+	rawConfig, ok := blobs[ociBlobPath(m.Config.Digest)]
+	if !ok {
+		return errors.Errorf("missing config blob %s", m.Config.Digest)
+	}
+	config, err := stabilizeOCIConfig(rawConfig)
+	if err != nil {
+		return errors.Wrap(err, "stabilizing config")
+	}
+	if err := (TarEntry{&tar.Header{Typeflag: tar.TypeReg, Name: OCIConfigPath, ModTime: arbitraryTime, AccessTime: arbitraryTime, Mode: 0777, Size: int64(len(config)), Format: tar.FormatPAX}, config}).WriteTo(tw); err != nil {
+		return err
+	}
+	for i, l := range m.Layers {
+		layer, ok := blobs[ociBlobPath(l.Digest)]
+		if !ok {
+			return errors.Errorf("missing layer blob %s", l.Digest)
+		}
+		if err := writeOCILayer(tw, OCILayerDir(i), layer); err != nil {
+			return errors.Wrapf(err, "canonicalizing layer %s", l.Digest)
+		}
+	}
+	return nil
+}
+
+func ociBlobPath(digest string) string {
+	alg, hex, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", alg, hex)
+}
+
+func isOCIIndex(mediaType string) bool {
+	return mediaType == "application/vnd.oci.image.index.v1+json" || mediaType == "application/vnd.docker.distribution.manifest.list.v2+json"
+}
+
+// resolveOCIManifest follows an index, and any nested indexes, to the single image manifest it references.
+func resolveOCIManifest(index []byte, blobs map[string][]byte) (*ociDocument, error) {
+	var doc ociDocument
+	if err := json.Unmarshal(index, &doc); err != nil {
+		return nil, errors.Wrap(err, "decoding index")
+	}
+	var manifests []ociDescriptor
+	for _, d := range doc.Manifests {
+		if d.Platform != nil && d.Platform.OS == "unknown" {
+			continue
+		}
+		manifests = append(manifests, d)
+	}
+	if len(manifests) != 1 {
+		return nil, errors.Errorf("expected one manifest, found %d", len(manifests))
+	}
+	raw, ok := blobs[ociBlobPath(manifests[0].Digest)]
+	if !ok {
+		return nil, errors.Errorf("missing manifest blob %s", manifests[0].Digest)
+	}
+	if isOCIIndex(manifests[0].MediaType) {
+		return resolveOCIManifest(raw, blobs)
+	}
+	var m ociDocument
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, errors.Wrap(err, "decoding manifest")
+	}
+	if isOCIIndex(m.MediaType) {
+		return resolveOCIManifest(raw, blobs)
+	}
+	return &m, nil
+}
+
+// stabilizeOCIConfig removes the volatile fields from an image configuration:
+// the creation times of the image and of each history entry, and the digests
+// of the uncompressed layers which are affected by file timestamps.
+func stabilizeOCIConfig(raw []byte) ([]byte, error) {
+	var config map[string]any
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, err
+	}
+	delete(config, "created")
+	if history, ok := config["history"].([]any); ok {
+		for _, h := range history {
+			if entry, ok := h.(map[string]any); ok {
+				delete(entry, "created")
+			}
+		}
+	}
+	if rootfs, ok := config["rootfs"].(map[string]any); ok {
+		delete(rootfs, "diff_ids")
+	}
+	// NOTE: Maps are marshalled with sorted keys so the output is stable.
+	return json.MarshalIndent(config, "", "  ")
+}
+
+// writeOCILayer writes the canonicalized files of a layer, which may be gzip-compressed, under dir.
+func writeOCILayer(tw *tar.Writer, dir string, layer []byte) error {
+	br := bufio.NewReader(bytes.NewReader(layer))
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return errors.Wrap(err, "initializing gzip reader")
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	lr := tar.NewReader(r)
+	var ents []TarEntry
+	for {
+		header, err := lr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		canonicalized, err := canonicalizeTarHeader(header)
+		if err != nil {
+			return err
+		}
+		canonicalized.Name = path.Join(dir, header.Name)
+		// NOTE: Unlike package archives, images commonly contain links whose
+		// targets are significant to the image's behavior.
+		canonicalized.Linkname = header.Linkname
+		if header.FileInfo().IsDir() {
+			canonicalized.Name += "/"
+		}
+		buf, err := io.ReadAll(lr)
+		if err != nil {
+			return err
+		}
+		ents = append(ents, TarEntry{canonicalized, buf})
+	}
+	sort.Slice(ents, func(i, j int) bool {
+		return ents[i].Header.Name < ents[j].Header.Name
+	})
+	for _, ent := range ents {
+		if err := ent.WriteTo(tw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func ociDigest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ociLayout constructs an OCI image layout tar with a single manifest.
+func ociLayout(config string, layers ...[]byte) []byte {
+	type blob struct {
+		name    string
+		content []byte
+	}
+	var blobs []blob
+	var layerDescs string
+	for i, l := range layers {
+		if i > 0 {
+			layerDescs += ","
+		}
+		layerDescs += fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":"%s","size":%d}`, ociDigest(l), len(l))
+		blobs = append(blobs, blob{ociBlobPath(ociDigest(l)), l})
+	}
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%s","size":%d},"layers":[%s]}`, ociDigest([]byte(config)), len(config), layerDescs)
+	index := fmt.Sprintf(`{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"%s","size":%d},{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:a77e57","size":1,"platform":{"os":"unknown","architecture":"unknown"}}]}`, ociDigest([]byte(manifest)), len(manifest))
+	blobs = append(blobs, blob{"index.json", []byte(index)}, blob{ociBlobPath(ociDigest([]byte(manifest))), []byte(manifest)}, blob{ociBlobPath(ociDigest([]byte(config))), []byte(config)})
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, b := range blobs {
+		orDie(TarEntry{&tar.Header{Name: b.name, Typeflag: tar.TypeReg, Size: int64(len(b.content)), Mode: 0644}, b.content}.WriteTo(tw))
+	}
+	orDie(tw.Close())
+	return buf.Bytes()
+}
+
+func ociLayer(mtime time.Time, files ...*TarEntry) []byte {
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for _, f := range files {
+		f.ModTime = mtime
+		orDie(f.WriteTo(tw))
+	}
+	orDie(tw.Close())
+	orDie(gzw.Close())
+	return buf.Bytes()
+}
+
+func TestCanonicalizeOCI(t *testing.T) {
+	canonicalize := func(mtime time.Time, created string) []byte {
+		config := `{"architecture":"amd64","os":"linux","created":"` + created + `","history":[{"created":"` + created + `","created_by":"RUN make"}],"rootfs":{"type":"layers","diff_ids":["sha256:` + created + `"]}}`
+		layout := ociLayout(config,
+			ociLayer(mtime, &TarEntry{&tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0755}, nil}, &TarEntry{&tar.Header{Name: "etc/os-release", Typeflag: tar.TypeReg, Size: 5, Mode: 0644}, []byte("alpha")}),
+			ociLayer(mtime, &TarEntry{&tar.Header{Name: "usr/bin/tool", Typeflag: tar.TypeReg, Size: 4, Mode: 0755}, []byte("tool")}, &TarEntry{&tar.Header{Name: "bin/tool", Typeflag: tar.TypeSymlink, Linkname: "/usr/bin/tool"}, nil}),
+		)
+		out := new(bytes.Buffer)
+		if err := Canonicalize(out, bytes.NewReader(layout), OCIFormat); err != nil {
+			t.Fatalf("Canonicalize() error = %v", err)
+		}
+		return out.Bytes()
+	}
+	first := canonicalize(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "2024-01-01T00:00:00Z")
+	second := canonicalize(time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC), "2024-02-02T00:00:00Z")
+	if !bytes.Equal(first, second) {
+		t.Error("Canonicalize() output differs for images differing only in timestamps")
+	}
+	cs, err := NewContentSummary(bytes.NewReader(first), OCIFormat)
+	if err != nil {
+		t.Fatalf("NewContentSummary() error = %v", err)
+	}
+	want := []string{"config.json", "layers/0/etc/", "layers/0/etc/os-release", "layers/1/bin/tool", "layers/1/usr/bin/tool"}
+	if diff := cmp.Diff(want, cs.Files); diff != "" {
+		t.Errorf("Canonicalize() entries mismatch (-want +got):\n%s", diff)
+	}
+	entries, err := ReadEntries(bytes.NewReader(first), OCIFormat, []string{OCIConfigPath})
+	if err != nil {
+		t.Fatalf("ReadEntries() error = %v", err)
+	}
+	wantConfig := `{
+  "architecture": "amd64",
+  "history": [
+    {
+      "created_by": "RUN make"
+    }
+  ],
+  "os": "linux",
+  "rootfs": {
+    "type": "layers"
+  }
+}`
+	if diff := cmp.Diff(wantConfig, string(entries[OCIConfigPath])); diff != "" {
+		t.Errorf("Canonicalize() config mismatch (-want +got):\n%s", diff)
+	}
+}
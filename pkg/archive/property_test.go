@@ -0,0 +1,218 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// genArchive is a randomly generated set of archive entries with distinct
+// names. Entries are drawn to exercise the content stabilizers, the name
+// normalization, and the metadata stripped from headers.
+type genArchive struct {
+	Entries []genEntry
+}
+
+type genEntry struct {
+	Name    string
+	Body    []byte
+	Dir     bool
+	ModTime time.Time
+	Mode    int64
+	Uid     int
+	Uname   string
+}
+
+var (
+	genDirs  = []string{"", "src/", "lib/nested/", "META-INF/", "META-INF/maven/com.example/app/", "café/"}
+	genBases = []string{"package.json", "Cargo.toml", ".cargo_vcs_info.json", "pom.properties", "build-info.properties", "pom.xml", "app.kotlin_module", "README.md", "notes.txt", "index.js", "lib.rs", "data.bin", "résumé.txt"}
+	genTexts = []string{
+		`{"name":"x","version":"1.0.0","gitHead":"abc","_id":"x@1.0.0","b":{"z":1,"a":2}}`,
+		cargoGeneratedHeader + "\n[package]\nname = \"x\"\nversion = \"1.0.0\"\n",
+		`{"git":{"sha1":"0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33"}}`,
+		"#Mon Jan 01 00:00:00 UTC 2024\r\nversion=1.0.0\r\ngroupId=com.example\r\n",
+		"<project>\r\n  <version>1.0</version>\r\n</project>\r\n",
+		"line one\r\nline two\n",
+	}
+)
+
+func (genArchive) Generate(r *rand.Rand, size int) reflect.Value {
+	root := []string{"pkg/", "x-1.0.0/"}[r.Intn(2)]
+	seen := make(map[string]bool)
+	var a genArchive
+	n := r.Intn(size + 1)
+	for i := 0; i < n; i++ {
+		e := genEntry{
+			ModTime: time.Unix(r.Int63n(2e9), 0).UTC(),
+			Mode:    []int64{0644, 0755, 0600}[r.Intn(3)],
+			Uid:     r.Intn(2000),
+			Uname:   []string{"", "root", "builder"}[r.Intn(3)],
+		}
+		dir := genDirs[r.Intn(len(genDirs))]
+		if r.Intn(8) == 0 && dir != "" {
+			e.Name, e.Dir = root+dir, true
+		} else {
+			e.Name = root + dir + genBases[r.Intn(len(genBases))]
+			if r.Intn(3) == 0 {
+				e.Body = make([]byte, r.Intn(64))
+				r.Read(e.Body)
+			} else {
+				e.Body = []byte(genTexts[r.Intn(len(genTexts))])
+			}
+		}
+		// NOTE: Names must remain distinct once normalized.
+		if seen[normalizeName(e.Name)] {
+			continue
+		}
+		seen[normalizeName(e.Name)] = true
+		a.Entries = append(a.Entries, e)
+	}
+	return reflect.ValueOf(a)
+}
+
+// shuffled returns the entries in a random order.
+func (a genArchive) shuffled(r *rand.Rand) []genEntry {
+	ents := append([]genEntry(nil), a.Entries...)
+	r.Shuffle(len(ents), func(i, j int) { ents[i], ents[j] = ents[j], ents[i] })
+	return ents
+}
+
+func genTarGz(ents []genEntry) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range ents {
+		h := &tar.Header{Name: e.Name, Typeflag: tar.TypeReg, Size: int64(len(e.Body)), ModTime: e.ModTime, Mode: e.Mode, Uid: e.Uid, Uname: e.Uname}
+		if e.Dir {
+			h.Typeflag = tar.TypeDir
+		}
+		orDie(TarEntry{h, e.Body}.WriteTo(tw))
+	}
+	orDie(tw.Close())
+	orDie(gw.Close())
+	return buf.Bytes()
+}
+
+func genZip(ents []genEntry) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range ents {
+		fh := &zip.FileHeader{Name: e.Name, Method: zip.Deflate, Modified: e.ModTime}
+		fh.SetMode(0644)
+		orDie((&ZipEntry{fh, e.Body}).WriteTo(zw))
+	}
+	orDie(zw.Close())
+	return buf.Bytes()
+}
+
+func canonicalize(t *testing.T, b []byte, f Format, opts Options) []byte {
+	var out bytes.Buffer
+	if _, err := CanonicalizeWithOptions(&out, bytes.NewReader(b), f, opts); err != nil {
+		t.Fatalf("canonicalizing: %v", err)
+	}
+	return out.Bytes()
+}
+
+// targeted returns whether a content stabilizer may rewrite the entry.
+func targeted(name string, f Format, opts Options) bool {
+	if opts.normalizesLineEndings(name) {
+		return true
+	}
+	switch f {
+	case TarGzFormat:
+		return isPackageManifest(name) || isCrateRootFile(name, ".cargo_vcs_info.json") || isCrateRootFile(name, "Cargo.toml")
+	case ZipFormat:
+		base := path.Base(name)
+		return (base == "pom.properties" && strings.HasPrefix(name, "META-INF/maven/")) ||
+			base == "build-info.properties" ||
+			(strings.HasSuffix(base, ".kotlin_module") && strings.HasPrefix(name, "META-INF/")) ||
+			isXMLDescriptor(name)
+	default:
+		return false
+	}
+}
+
+func TestStabilizerProperties(t *testing.T) {
+	formats := []struct {
+		name  string
+		f     Format
+		write func([]genEntry) []byte
+	}{
+		{"tar.gz", TarGzFormat, genTarGz},
+		{"zip", ZipFormat, genZip},
+	}
+	for _, format := range formats {
+		for _, opts := range []Options{{}, {LineEndingPaths: []string{"*.txt", "META-INF/*"}}} {
+			name := format.name
+			if len(opts.LineEndingPaths) > 0 {
+				name += "/line-endings"
+			}
+			cfg := &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(1))}
+			t.Run(name+"/idempotence", func(t *testing.T) {
+				prop := func(a genArchive) bool {
+					once := canonicalize(t, format.write(a.Entries), format.f, opts)
+					return bytes.Equal(once, canonicalize(t, once, format.f, opts))
+				}
+				if err := quick.Check(prop, cfg); err != nil {
+					t.Error(err)
+				}
+			})
+			t.Run(name+"/ordering", func(t *testing.T) {
+				r := rand.New(rand.NewSource(2))
+				prop := func(a genArchive) bool {
+					want := canonicalize(t, format.write(a.Entries), format.f, opts)
+					return bytes.Equal(want, canonicalize(t, format.write(a.shuffled(r)), format.f, opts))
+				}
+				if err := quick.Check(prop, cfg); err != nil {
+					t.Error(err)
+				}
+			})
+			t.Run(name+"/preservation", func(t *testing.T) {
+				prop := func(a genArchive) bool {
+					orig := format.write(a.Entries)
+					canon := canonicalize(t, orig, format.f, opts)
+					var names []string
+					for _, e := range a.Entries {
+						if n := normalizeName(e.Name); !targeted(n, format.f, opts) {
+							names = append(names, n)
+						}
+					}
+					before := must(ReadEntries(bytes.NewReader(orig), format.f, names))
+					after := must(ReadEntries(bytes.NewReader(canon), format.f, names))
+					for _, n := range names {
+						if !bytes.Equal(before[n], after[n]) {
+							t.Logf("content of %q changed", n)
+							return false
+						}
+					}
+					return len(before) == len(after)
+				}
+				if err := quick.Check(prop, cfg); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
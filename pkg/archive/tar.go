@@ -77,7 +77,13 @@ func (e TarEntry) WriteTo(tw *tar.Writer) error {
 }
 
 // CanonicalizeTar strips volatile metadata and re-writes the provided archive in a canonical form.
+// Entry names are normalized to Unicode NFC and metadata injected into npm
+// packages and crates at publish time is additionally normalized.
 func CanonicalizeTar(tr *tar.Reader, tw *tar.Writer) error {
+	return canonicalizeTar(tr, tw, Options{}, &StabilizationReport{})
+}
+
+func canonicalizeTar(tr *tar.Reader, tw *tar.Writer, opts Options, report *StabilizationReport) error {
 	defer tw.Close()
 	var ents []TarEntry
 	for {
@@ -96,6 +102,13 @@ func CanonicalizeTar(tr *tar.Reader, tw *tar.Writer) error {
 		if err != nil {
 			return err
 		}
+		canonicalized.Name = stabilizeName(header.Name, report)
+		buf, keep := stabilizeTarEntry(canonicalized.Name, buf, report)
+		if !keep {
+			continue
+		}
+		buf = opts.stabilizeLineEndings(canonicalized.Name, buf, report)
+		canonicalized.Size = int64(len(buf))
 		// TODO: Memory-intensive. We're buffering the full file in memory (again).
 		// One option would be to do two passes and only buffer what's necessary.
 		ents = append(ents, TarEntry{canonicalized, buf[:]})
@@ -111,6 +124,30 @@ func CanonicalizeTar(tr *tar.Reader, tw *tar.Writer) error {
 	return nil
 }
 
+// stabilizeTarEntry applies the stabilizers for metadata injected into
+// packages at publish time, returning the entry's new body and whether it
+// should be retained.
+func stabilizeTarEntry(name string, body []byte, report *StabilizationReport) ([]byte, bool) {
+	switch {
+	case isPackageManifest(name):
+		var removed []string
+		body, removed = canonicalizePackageJSON(body)
+		for _, field := range removed {
+			report.Removals = append(report.Removals, Removal{Stabilizer: packageJSONStabilizer, Path: name, Field: field})
+		}
+	case isCrateRootFile(name, ".cargo_vcs_info.json"):
+		// NOTE: The file is absent from crates packaged outside of a git checkout.
+		report.Removals = append(report.Removals, Removal{Stabilizer: VCSInfoStabilizer, Path: name, Value: string(body)})
+		return nil, false
+	case isCrateRootFile(name, "Cargo.toml"):
+		var changed bool
+		if body, changed = canonicalizeCargoTOML(body); changed {
+			report.Removals = append(report.Removals, Removal{Stabilizer: cargoTOMLStabilizer, Path: name, Field: "comments"})
+		}
+	}
+	return body, true
+}
+
 // ExtractOptions provides options modifying ExtractTar behavior.
 type ExtractOptions struct {
 	// SubDir is a directory within the TAR to extract relative to the provided filesystem.
@@ -194,7 +231,7 @@ func NewContentSummaryFromTar(tr *tar.Reader) (*ContentSummary, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to read tar entry %s", header.Name)
 		}
-		cs.Files = append(cs.Files, header.Name)
+		cs.Files = append(cs.Files, normalizeName(header.Name))
 		cs.CRLFCount += bytes.Count(buf, []byte{'\r', '\n'})
 		cs.FileHashes = append(cs.FileHashes, hex.EncodeToString(sha256.New().Sum(buf)))
 	}
@@ -17,6 +17,7 @@ package archive
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"io"
 	"testing"
 	"time"
@@ -102,3 +103,31 @@ func TestCanonicalizeTar(t *testing.T) {
 		})
 	}
 }
+
+func TestReadEntries(t *testing.T) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, e := range []*TarEntry{
+		{&tar.Header{Name: "a", Typeflag: tar.TypeReg, Size: 1, Mode: 0644}, []byte("a")},
+		{&tar.Header{Name: "b", Typeflag: tar.TypeReg, Size: 2, Mode: 0644}, []byte("bb")},
+	} {
+		if err := e.WriteTo(tw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadEntries(&buf, TarGzFormat, []string{"b", "missing"})
+	if err != nil {
+		t.Fatalf("ReadEntries() error = %v", err)
+	}
+	want := map[string][]byte{"b": []byte("bb")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadEntries() mismatch (-want +got):\n%s", diff)
+	}
+}
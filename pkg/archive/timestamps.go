@@ -0,0 +1,193 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"debug/pe"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TimestampSource identifies the archive feature in which a timestamp was found.
+type TimestampSource string
+
+const (
+	// ZipEntrySource is the modification time of a zip entry.
+	ZipEntrySource TimestampSource = "zip-entry"
+	// TarEntrySource is the modification time of a tar entry.
+	TarEntrySource TimestampSource = "tar-entry"
+	// GzipHeaderSource is the modification time recorded in a gzip header.
+	GzipHeaderSource TimestampSource = "gzip-header"
+	// ManifestSource is a build time attribute of a JAR manifest.
+	ManifestSource TimestampSource = "manifest"
+	// PEHeaderSource is the link time recorded in a Windows executable's header.
+	PEHeaderSource TimestampSource = "pe-header"
+)
+
+// Timestamp is a time embedded within an archive.
+type Timestamp struct {
+	Source TimestampSource `json:"source"`
+	// Path is the entry with which the timestamp is associated, if any.
+	Path string    `json:"path,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// manifestTimeAttrs are the JAR manifest attributes that conventionally record the build time.
+var manifestTimeAttrs = []string{"Build-Date", "Built-Date", "Build-Time", "Build-Timestamp", "Bnd-LastModified"}
+
+// manifestTimeLayouts are the layouts in which manifest build times are commonly written.
+var manifestTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", time.UnixDate}
+
+var peExts = []string{".exe", ".dll", ".pyd", ".sys"}
+
+// ExtractTimestamps returns the timestamps embedded within an archive of the given format.
+//
+// In addition to archive metadata, entries known to embed their own build time
+// (JAR manifests and Windows executables) are inspected.
+func ExtractTimestamps(src io.Reader, f Format) ([]Timestamp, error) {
+	var ts []Timestamp
+	switch f {
+	case ZipFormat:
+		srcReader, size, err := toZipCompatibleReader(src)
+		if err != nil {
+			return nil, errors.Wrap(err, "converting reader")
+		}
+		zr, err := zip.NewReader(srcReader, size)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing zip reader")
+		}
+		for _, zf := range zr.File {
+			if !zf.Modified.IsZero() {
+				ts = append(ts, Timestamp{Source: ZipEntrySource, Path: zf.Name, Time: zf.Modified.UTC()})
+			}
+			if !embedsTimestamp(zf.Name) {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, errors.Wrapf(err, "opening zip entry %s", zf.Name)
+			}
+			buf, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading zip entry %s", zf.Name)
+			}
+			ts = append(ts, entryTimestamps(zf.Name, buf)...)
+		}
+	case TarGzFormat:
+		gzr, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing gzip reader")
+		}
+		defer gzr.Close()
+		if !gzr.ModTime.IsZero() {
+			ts = append(ts, Timestamp{Source: GzipHeaderSource, Time: gzr.ModTime.UTC()})
+		}
+		tr := tar.NewReader(gzr)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, errors.Wrap(err, "reading tar header")
+			}
+			if !header.ModTime.IsZero() {
+				ts = append(ts, Timestamp{Source: TarEntrySource, Path: header.Name, Time: header.ModTime.UTC()})
+			}
+			if !embedsTimestamp(header.Name) {
+				continue
+			}
+			buf, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading tar entry %s", header.Name)
+			}
+			ts = append(ts, entryTimestamps(header.Name, buf)...)
+		}
+	default:
+		return nil, errors.New("unsupported archive type")
+	}
+	return ts, nil
+}
+
+func embedsTimestamp(name string) bool {
+	if strings.HasSuffix(name, "META-INF/MANIFEST.MF") {
+		return true
+	}
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range peExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// entryTimestamps returns the timestamps recorded within the content of an entry.
+// Malformed content is ignored since it is not the subject of the analysis.
+func entryTimestamps(name string, content []byte) []Timestamp {
+	var ts []Timestamp
+	if strings.HasSuffix(name, "META-INF/MANIFEST.MF") {
+		s := bufio.NewScanner(bytes.NewReader(content))
+		for s.Scan() {
+			attr, val, found := strings.Cut(s.Text(), ":")
+			if !found {
+				continue
+			}
+			if t, ok := parseManifestTime(strings.TrimSpace(attr), strings.TrimSpace(val)); ok {
+				ts = append(ts, Timestamp{Source: ManifestSource, Path: name, Time: t})
+			}
+		}
+		return ts
+	}
+	f, err := pe.NewFile(bytes.NewReader(content))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if f.TimeDateStamp != 0 {
+		ts = append(ts, Timestamp{Source: PEHeaderSource, Path: name, Time: time.Unix(int64(f.TimeDateStamp), 0).UTC()})
+	}
+	return ts
+}
+
+func parseManifestTime(attr, val string) (time.Time, bool) {
+	var known bool
+	for _, a := range manifestTimeAttrs {
+		known = known || strings.EqualFold(a, attr)
+	}
+	if !known || val == "" {
+		return time.Time{}, false
+	}
+	// NOTE: Bnd-LastModified and some Build-Timestamp values are epoch milliseconds.
+	if ms, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), true
+	}
+	for _, layout := range manifestTimeLayouts {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExtractTimestamps(t *testing.T) {
+	entryTime := time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)
+	t.Run("Zip", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		zw := zip.NewWriter(buf)
+		for _, e := range []*ZipEntry{
+			{&zip.FileHeader{Name: "META-INF/MANIFEST.MF", Modified: entryTime}, []byte("Manifest-Version: 1.0\r\nBuild-Date: 2024-01-01 12:00:00\r\nBnd-LastModified: 1704067200000\r\n")},
+			{&zip.FileHeader{Name: "com/foo/Bar.class", Modified: entryTime}, []byte("bar")},
+		} {
+			orDie(e.WriteTo(zw))
+		}
+		orDie(zw.Close())
+		got, err := ExtractTimestamps(bytes.NewReader(buf.Bytes()), ZipFormat)
+		if err != nil {
+			t.Fatalf("ExtractTimestamps() error = %v", err)
+		}
+		want := []Timestamp{
+			{Source: ZipEntrySource, Path: "META-INF/MANIFEST.MF", Time: entryTime},
+			{Source: ManifestSource, Path: "META-INF/MANIFEST.MF", Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+			{Source: ManifestSource, Path: "META-INF/MANIFEST.MF", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{Source: ZipEntrySource, Path: "com/foo/Bar.class", Time: entryTime},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("ExtractTimestamps() mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("TarGz", func(t *testing.T) {
+		gzTime := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		buf := new(bytes.Buffer)
+		gzw := gzip.NewWriter(buf)
+		gzw.ModTime = gzTime
+		tw := tar.NewWriter(gzw)
+		orDie((&TarEntry{&tar.Header{Name: "package/index.js", Typeflag: tar.TypeReg, Size: 3, Mode: 0644, ModTime: entryTime}, []byte("foo")}).WriteTo(tw))
+		orDie(tw.Close())
+		orDie(gzw.Close())
+		got, err := ExtractTimestamps(bytes.NewReader(buf.Bytes()), TarGzFormat)
+		if err != nil {
+			t.Fatalf("ExtractTimestamps() error = %v", err)
+		}
+		want := []Timestamp{
+			{Source: GzipHeaderSource, Time: gzTime},
+			{Source: TarEntrySource, Path: "package/index.js", Time: entryTime},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("ExtractTimestamps() mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// xmlNamespace is the namespace bound to the reserved "xml" prefix.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// CanonicalizeXML rewrites an XML document, such as a Maven POM, in a canonical form.
+//
+// Whitespace surrounding text and between elements is removed and the
+// document re-indented, namespaces are re-declared on the root element using
+// generated prefixes, attributes are sorted, and the document is re-encoded
+// as UTF-8. Comments are retained.
+func CanonicalizeXML(dst io.Writer, src io.Reader) error {
+	toks, err := readXMLTokens(src)
+	if err != nil {
+		return err
+	}
+	prefixes := make(map[string]string)
+	var spaces []string
+	declare := func(space string) {
+		if _, ok := prefixes[space]; !ok && space != "" && space != xmlNamespace {
+			spaces = append(spaces, space)
+			prefixes[space] = fmt.Sprintf("ns%d", len(spaces))
+		}
+	}
+	var root *xml.StartElement
+	for _, tok := range toks {
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root == nil {
+			root = &se
+		}
+		if se.Name.Space != root.Name.Space {
+			declare(se.Name.Space)
+		}
+		for _, a := range se.Attr {
+			// NOTE: Attributes do not take the default namespace so any namespace must be prefixed.
+			if !isNamespaceDecl(a) {
+				declare(a.Name.Space)
+			}
+		}
+	}
+	if root == nil {
+		return errors.New("parsing xml: no root element")
+	}
+	w := bufio.NewWriter(dst)
+	w.WriteString(xml.Header)
+	var names, defaults []string
+	for i := 0; i < len(toks); i++ {
+		indent := strings.Repeat("  ", len(names))
+		switch tok := toks[i].(type) {
+		case xml.StartElement:
+			var scope string
+			if len(defaults) > 0 {
+				scope = defaults[len(defaults)-1]
+			}
+			var attrs []string
+			name := tok.Name.Local
+			if p, ok := prefixes[tok.Name.Space]; ok && tok.Name.Space != root.Name.Space {
+				name = p + ":" + name
+			} else if tok.Name.Space != scope {
+				// NOTE: Unprefixed elements are in either the root's namespace or none.
+				attrs = append(attrs, "xmlns="+quoteXMLAttr(tok.Name.Space))
+				scope = tok.Name.Space
+			}
+			if len(defaults) == 0 {
+				for _, s := range spaces {
+					attrs = append(attrs, "xmlns:"+prefixes[s]+"="+quoteXMLAttr(s))
+				}
+			}
+			var own []string
+			for _, a := range tok.Attr {
+				if isNamespaceDecl(a) {
+					continue
+				}
+				n := a.Name.Local
+				if a.Name.Space == xmlNamespace {
+					n = "xml:" + n
+				} else if p, ok := prefixes[a.Name.Space]; ok {
+					n = p + ":" + n
+				}
+				own = append(own, n+"="+quoteXMLAttr(a.Value))
+			}
+			sort.Strings(own)
+			attrs = append(attrs, own...)
+			w.WriteString(indent + "<" + name)
+			for _, a := range attrs {
+				w.WriteString(" " + a)
+			}
+			switch {
+			case i+1 < len(toks) && isEndElement(toks[i+1]):
+				w.WriteString("/>\n")
+				i++
+			case i+2 < len(toks) && isCharData(toks[i+1]) && isEndElement(toks[i+2]):
+				w.WriteString(">" + escapeXMLText(toks[i+1].(xml.CharData)) + "</" + name + ">\n")
+				i += 2
+			default:
+				w.WriteString(">\n")
+				names = append(names, name)
+				defaults = append(defaults, scope)
+			}
+		case xml.EndElement:
+			name := names[len(names)-1]
+			names, defaults = names[:len(names)-1], defaults[:len(defaults)-1]
+			w.WriteString(strings.Repeat("  ", len(names)) + "</" + name + ">\n")
+		case xml.CharData:
+			w.WriteString(indent + escapeXMLText(tok) + "\n")
+		case xml.Comment:
+			w.WriteString(indent + "<!--" + string(tok) + "-->\n")
+		case xml.ProcInst:
+			w.WriteString(indent + "<?" + tok.Target + " " + string(tok.Inst) + "?>\n")
+		case xml.Directive:
+			w.WriteString(indent + "<!" + string(tok) + ">\n")
+		}
+	}
+	return w.Flush()
+}
+
+// isXMLDescriptor returns whether the archive entry at p is an XML descriptor,
+// such as a Maven POM or a servlet deployment descriptor, whose formatting
+// commonly varies between builds.
+func isXMLDescriptor(p string) bool {
+	if !strings.HasSuffix(p, ".xml") {
+		return false
+	}
+	switch path.Base(p) {
+	case "pom.xml", "plugin.xml", "web.xml":
+		return true
+	}
+	return strings.HasPrefix(p, "META-INF/") || strings.HasPrefix(p, "WEB-INF/")
+}
+
+// canonicalizeXMLEntry returns the canonical form of an XML archive entry or,
+// if it cannot be parsed, the entry unchanged.
+func canonicalizeXMLEntry(b []byte) []byte {
+	var buf bytes.Buffer
+	if err := CanonicalizeXML(&buf, bytes.NewReader(b)); err != nil {
+		return b
+	}
+	return buf.Bytes()
+}
+
+// decodeCharset converts documents in the single-byte encodings commonly
+// declared by XML descriptors to UTF-8.
+func decodeCharset(label string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "us-ascii", "ascii", "iso-8859-1", "iso8859-1", "latin1", "latin-1":
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		// NOTE: ISO-8859-1 bytes are the first 256 Unicode code points.
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return strings.NewReader(string(runes)), nil
+	default:
+		return nil, errors.Errorf("unsupported charset: %s", label)
+	}
+}
+
+// readXMLTokens returns the tokens of the document with whitespace trimmed
+// from text, omitting the XML declaration and any text left empty.
+func readXMLTokens(src io.Reader) ([]xml.Token, error) {
+	d := xml.NewDecoder(src)
+	d.CharsetReader = decodeCharset
+	var toks []xml.Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "parsing xml")
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			if t = bytes.TrimSpace(t); len(t) == 0 {
+				continue
+			}
+			tok = t
+		case xml.ProcInst:
+			if t.Target == "xml" {
+				continue
+			}
+		}
+		toks = append(toks, xml.CopyToken(tok))
+	}
+	return toks, nil
+}
+
+func isNamespaceDecl(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns"
+}
+
+func isEndElement(tok xml.Token) bool {
+	_, ok := tok.(xml.EndElement)
+	return ok
+}
+
+func isCharData(tok xml.Token) bool {
+	_, ok := tok.(xml.CharData)
+	return ok
+}
+
+func escapeXMLText(b []byte) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, b)
+	return buf.String()
+}
+
+func quoteXMLAttr(s string) string {
+	return `"` + escapeXMLText([]byte(s)) + `"`
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCanonicalizeXML(t *testing.T) {
+	canonicalPOM := `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0" xmlns:ns1="http://www.w3.org/2001/XMLSchema-instance" ns1:schemaLocation="http://maven.apache.org/POM/4.0.0 https://maven.apache.org/xsd/maven-4.0.0.xsd">
+  <!-- Parent of the build. -->
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>com.example</groupId>
+  <artifactId>parent</artifactId>
+  <version>1.0</version>
+  <packaging>pom</packaging>
+  <modules/>
+</project>
+`
+	for _, tc := range []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "canonical",
+			input: canonicalPOM,
+			want:  canonicalPOM,
+		},
+		{
+			name: "reformatted",
+			input: `<?xml version='1.0' encoding='UTF-8'?>
+<project xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://maven.apache.org/POM/4.0.0 https://maven.apache.org/xsd/maven-4.0.0.xsd" xmlns="http://maven.apache.org/POM/4.0.0">
+	<!-- Parent of the build. -->
+	<modelVersion>4.0.0</modelVersion>
+
+	<groupId>com.example</groupId>
+	<artifactId>
+		parent
+	</artifactId>
+	<version>1.0</version>
+	<packaging>pom</packaging>
+	<modules></modules>
+</project>`,
+			want: canonicalPOM,
+		},
+		{
+			name: "prefixed root namespace",
+			input: `<?xml version="1.0"?>
+<pom:project xmlns:pom="http://maven.apache.org/POM/4.0.0" xmlns:s="http://www.w3.org/2001/XMLSchema-instance" s:schemaLocation="http://maven.apache.org/POM/4.0.0 https://maven.apache.org/xsd/maven-4.0.0.xsd">
+  <!-- Parent of the build. -->
+  <pom:modelVersion>4.0.0</pom:modelVersion>
+  <pom:groupId>com.example</pom:groupId>
+  <pom:artifactId>parent</pom:artifactId>
+  <pom:version>1.0</pom:version>
+  <pom:packaging>pom</pom:packaging>
+  <pom:modules/>
+</pom:project>`,
+			want: canonicalPOM,
+		},
+		{
+			name:  "sorted attributes and escaped text",
+			input: `<a c="2" b="1&amp;"><b>x &lt; y</b><c xmlns="urn:other"><d/></c></a>`,
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<a xmlns:ns1="urn:other" b="1&amp;" c="2">
+  <b>x &lt; y</b>
+  <ns1:c>
+    <ns1:d/>
+  </ns1:c>
+</a>
+`,
+		},
+		{
+			name:  "unnamespaced child",
+			input: `<a xmlns="urn:a"><b xmlns=""><c/></b><d/></a>`,
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<a xmlns="urn:a">
+  <b xmlns="">
+    <c/>
+  </b>
+  <d/>
+</a>
+`,
+		},
+		{
+			name:  "latin1 encoding",
+			input: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><name>Fran\xe7ois</name>",
+			want:  xml.Header + "<name>François</name>\n",
+		},
+		{
+			name:    "malformed",
+			input:   `<project><groupId></project>`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := CanonicalizeXML(&out, strings.NewReader(tc.input))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("CanonicalizeXML() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("CanonicalizeXML() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -19,9 +19,11 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"hash/crc32"
 	"io"
 	"sort"
-	"time"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
@@ -43,7 +45,7 @@ func NewContentSummaryFromZip(zr *zip.Reader) (*ContentSummary, error) {
 		if err != nil {
 			return nil, err
 		}
-		cs.Files = append(cs.Files, f.Name)
+		cs.Files = append(cs.Files, zipEntryName(&f.FileHeader))
 		cs.CRLFCount += bytes.Count(buf, []byte{'\r', '\n'})
 		cs.FileHashes = append(cs.FileHashes, hex.EncodeToString(sha256.New().Sum(buf)))
 	}
@@ -69,9 +71,14 @@ func (e ZipEntry) WriteTo(zw *zip.Writer) error {
 }
 
 // CanonicalizeZip strips volatile metadata and rewrites the provided archive in a canonical form.
+// Entry names are decoded to UTF-8 and normalized to Unicode NFC.
+// Metadata generated by JVM build tools, such as XML descriptors, properties
+// files, and Kotlin module metadata, is additionally normalized.
 func CanonicalizeZip(zr *zip.Reader, zw *zip.Writer) error {
-	defer zw.Close()
-	var ents []ZipEntry
+	return canonicalizeZip(zr, &DeterministicZipWriter{zw: zw}, Options{}, &StabilizationReport{})
+}
+
+func canonicalizeZip(zr *zip.Reader, dw *DeterministicZipWriter, opts Options, report *StabilizationReport) error {
 	for _, f := range zr.File {
 		r, err := f.Open()
 		if err != nil {
@@ -85,23 +92,101 @@ func CanonicalizeZip(zr *zip.Reader, zw *zip.Writer) error {
 		if err := r.Close(); err != nil {
 			return err
 		}
+		name := stabilizeZipName(&f.FileHeader, report)
+		b = canonicalizeEntry(name, b)
+		b = opts.stabilizeLineEndings(name, b, report)
 		// TODO: Memory-intensive. We're buffering the full file in memory (again).
 		// One option would be to do two passes and only buffer what's necessary.
-		ents = append(ents, ZipEntry{&zip.FileHeader{Name: f.Name, Modified: time.UnixMilli(0)}, b})
+		dw.Add(name, b)
 	}
-	sort.Slice(ents, func(i, j int) bool {
-		return ents[i].FileHeader.Name < ents[j].FileHeader.Name
+	return dw.Close()
+}
+
+// DOS-format date and time of 1980-01-01 00:00:00, the earliest representable in a zip header.
+const (
+	zipEpochDate = 1<<5 | 1
+	zipEpochTime = 0
+)
+
+// zipEpochExtra is an extended timestamp extra field recording a modification
+// time of the Unix epoch, which readers prefer to the DOS-format time.
+var zipEpochExtra = []byte{0x55, 0x54, 5, 0, 1, 0, 0, 0, 0}
+
+// DeterministicZipWriter writes zip archives whose content depends only on
+// the names and contents of the entries added to them.
+//
+// Entries are written in name order, uncompressed, with fixed times, creator
+// versions, and attributes, and with their sizes and checksums in the local
+// header rather than in trailing data descriptors. Unlike zip.Writer, the
+// DOS-format time written does not depend on the local time zone.
+type DeterministicZipWriter struct {
+	zw   *zip.Writer
+	ents []ZipEntry
+}
+
+// NewDeterministicZipWriter returns a DeterministicZipWriter writing to w.
+func NewDeterministicZipWriter(w io.Writer) *DeterministicZipWriter {
+	return &DeterministicZipWriter{zw: zip.NewWriter(w)}
+}
+
+// Add adds an entry to the archive. Names ending in "/" denote directories,
+// whose body must be empty.
+func (dw *DeterministicZipWriter) Add(name string, body []byte) {
+	dw.ents = append(dw.ents, ZipEntry{&zip.FileHeader{Name: name}, body})
+}
+
+// Close writes the added entries and the central directory.
+// It does not close the underlying writer.
+func (dw *DeterministicZipWriter) Close() error {
+	// NOTE: Entries sharing a name retain the order in which they were added.
+	sort.SliceStable(dw.ents, func(i, j int) bool {
+		return dw.ents[i].Name < dw.ents[j].Name
 	})
-	for _, ent := range ents {
-		w, err := zw.CreateHeader(ent.FileHeader)
+	for _, ent := range dw.ents {
+		fh := &zip.FileHeader{
+			Name:               ent.Name,
+			Method:             zip.Store,
+			CreatorVersion:     zipVersion20,
+			ReaderVersion:      zipVersion20,
+			ModifiedDate:       zipEpochDate,
+			ModifiedTime:       zipEpochTime,
+			Extra:              zipEpochExtra,
+			CRC32:              crc32.ChecksumIEEE(ent.Body),
+			CompressedSize64:   uint64(len(ent.Body)),
+			UncompressedSize64: uint64(len(ent.Body)),
+		}
+		if !isASCII(ent.Name) {
+			fh.Flags |= zipFlagUTF8
+		}
+		if strings.HasSuffix(ent.Name, "/") && len(ent.Body) > 0 {
+			return errors.Errorf("directory entry %s has content", ent.Name)
+		}
+		w, err := dw.zw.CreateRaw(fh)
 		if err != nil {
 			return err
 		}
-		if _, err := io.Copy(w, bytes.NewReader(ent.Body)); err != nil {
+		if _, err := w.Write(ent.Body); err != nil {
 			return err
 		}
 	}
-	return nil
+	return dw.zw.Close()
+}
+
+const (
+	// zipVersion20 is version 2.0 of the zip format, the first supporting the
+	// features used, recorded with the MS-DOS compatibility byte of zero.
+	zipVersion20 = 20
+	// zipFlagUTF8 indicates the entry name is UTF-8 encoded.
+	zipFlagUTF8 = 0x800
+)
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
 }
 
 // toZipCompatibleReader coerces an io.Reader into an io.ReaderAt required to construct a zip.Reader.
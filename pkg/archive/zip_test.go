@@ -17,9 +17,13 @@ package archive
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/xml"
 	"io"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestCanonicalizeZip(t *testing.T) {
@@ -69,6 +73,19 @@ func TestCanonicalizeZip(t *testing.T) {
 				{&zip.FileHeader{Name: "foo", Modified: time.UnixMilli(0)}, []byte("foo")},
 			},
 		},
+		{
+			test: "canonicalize-xml-descriptors",
+			input: []*ZipEntry{
+				{&zip.FileHeader{Name: "META-INF/maven/com.example/foo/pom.xml"}, []byte("<project>\n\t<artifactId> foo </artifactId>\n</project>")},
+				{&zip.FileHeader{Name: "META-INF/broken.xml"}, []byte("<project>")},
+				{&zip.FileHeader{Name: "data/table.xml"}, []byte("<table> </table>")},
+			},
+			expected: []*ZipEntry{
+				{&zip.FileHeader{Name: "META-INF/broken.xml", Modified: time.UnixMilli(0)}, []byte("<project>")},
+				{&zip.FileHeader{Name: "META-INF/maven/com.example/foo/pom.xml", Modified: time.UnixMilli(0)}, []byte(xml.Header + "<project>\n  <artifactId>foo</artifactId>\n</project>\n")},
+				{&zip.FileHeader{Name: "data/table.xml", Modified: time.UnixMilli(0)}, []byte("<table> </table>")},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.test, func(t *testing.T) {
@@ -111,6 +128,52 @@ func TestCanonicalizeZip(t *testing.T) {
 	}
 }
 
+func TestDeterministicZipWriter(t *testing.T) {
+	write := func(names ...string) []byte {
+		var buf bytes.Buffer
+		dw := NewDeterministicZipWriter(&buf)
+		for _, n := range names {
+			if strings.HasSuffix(n, "/") {
+				dw.Add(n, nil)
+			} else {
+				dw.Add(n, []byte(n))
+			}
+		}
+		orDie(dw.Close())
+		return buf.Bytes()
+	}
+	a := write("foo", "dir/", "dir/bar", "caf\u00e9")
+	b := write("caf\u00e9", "dir/bar", "foo", "dir/")
+	if !bytes.Equal(a, b) {
+		t.Fatalf("NewDeterministicZipWriter() output depends on insertion order")
+	}
+	zr := must(zip.NewReader(bytes.NewReader(a), int64(len(a))))
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Flags&0x8 != 0 {
+			t.Errorf("%s: uses data descriptor", f.Name)
+		}
+		if f.Method != zip.Store || f.CreatorVersion != 20 || f.ExternalAttrs != 0 {
+			t.Errorf("%s: header = {Method: %d, CreatorVersion: %d, ExternalAttrs: %d}, want fixed values", f.Name, f.Method, f.CreatorVersion, f.ExternalAttrs)
+		}
+		if !f.Modified.Equal(time.UnixMilli(0)) {
+			t.Errorf("%s: Modified = %v, want %v", f.Name, f.Modified, time.UnixMilli(0))
+		}
+		if body := string(must(io.ReadAll(must(f.Open())))); !strings.HasSuffix(f.Name, "/") && body != f.Name {
+			t.Errorf("%s: body = %q, want %q", f.Name, body, f.Name)
+		}
+	}
+	if diff := cmp.Diff([]string{"caf\u00e9", "dir/", "dir/bar", "foo"}, names); diff != "" {
+		t.Errorf("NewDeterministicZipWriter() names mismatch (-want +got):\n%s", diff)
+	}
+	dw := NewDeterministicZipWriter(io.Discard)
+	dw.Add("dir/", []byte("content"))
+	if err := dw.Close(); err == nil {
+		t.Error("Close() succeeded with content in directory entry")
+	}
+}
+
 func must[T any](t T, err error) T {
 	orDie(err)
 	return t
@@ -23,6 +23,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 )
@@ -51,10 +52,14 @@ func (s *FilesystemBuildDefinitionSet) Get(ctx context.Context, t rebuild.Target
 		return nil, errors.Wrap(err, "reading build definition")
 	}
 	defer r.Close()
-	var strategy rebuild.Strategy
-	if err := yaml.NewDecoder(r).Decode(strategy); err != nil {
+	var oneof schema.StrategyOneOf
+	if err := yaml.NewDecoder(r).Decode(&oneof); err != nil {
 		return nil, errors.Wrap(err, "parsing build definition")
 	}
+	strategy, err := oneof.Strategy()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading build definition")
+	}
 	return strategy, nil
 }
 
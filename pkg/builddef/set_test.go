@@ -0,0 +1,60 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builddef
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFilesystemBuildDefinitionSet(t *testing.T) {
+	ctx := context.Background()
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz"}
+	want := &npm.NPMPackBuild{
+		Location:   rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: "5f4a4c6", Dir: "."},
+		NPMVersion: "5.6.0",
+	}
+	fs := memfs.New()
+	w, _, err := rebuild.NewFilesystemAssetStore(fs).Writer(ctx, rebuild.Asset{Type: rebuild.BuildDef, Target: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("# A comment preceding the definition.\n"))
+	oneof := schema.NewStrategyOneOf(want)
+	if err := yaml.NewEncoder(w).Encode(&oneof); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	defs := NewFilesystemBuildDefinitionSet(fs)
+	got, err := defs.Get(ctx, target)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if diff := cmp.Diff(rebuild.Strategy(want), got); diff != "" {
+		t.Errorf("Get() mismatch (-want +got):\n%s", diff)
+	}
+	missing := target
+	missing.Version = "1.2.0"
+	if got, err := defs.Get(ctx, missing); err != nil || got != nil {
+		t.Errorf("Get(missing) = %v, %v, want nil, nil", got, err)
+	}
+}
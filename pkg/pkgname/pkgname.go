@@ -0,0 +1,147 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pkgname resolves the common spellings and aliases of package names
+// to the canonical identity under which their rebuilds are recorded.
+package pkgname
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	mavenreg "github.com/google/oss-rebuild/pkg/registry/maven"
+	"github.com/pkg/errors"
+)
+
+// pypiSeparators matches the runs of separators that PEP 503 treats as equivalent.
+var pypiSeparators = regexp.MustCompile(`[-_.]+`)
+
+// Normalize returns the canonical spelling of the package name in the
+// ecosystem using only the ecosystem's naming rules:
+//   - PyPI: The PEP 503 normalized name e.g. "Typing_Extensions" becomes "typing-extensions".
+//   - npm: Percent-encoded scopes are decoded e.g. "@types%2fnode" becomes "@types/node".
+//   - Maven: The "group:artifact" coordinates without surrounding whitespace.
+//
+// Names which are only equivalent according to the registry, such as
+// differently-cased crates, are resolved by a Resolver.
+func Normalize(eco rebuild.Ecosystem, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("empty package name")
+	}
+	switch eco {
+	case rebuild.PyPI:
+		return strings.ToLower(pypiSeparators.ReplaceAllString(name, "-")), nil
+	case rebuild.NPM:
+		return normalizeNPM(name)
+	case rebuild.Maven:
+		g, a, found := strings.Cut(name, ":")
+		g, a = strings.TrimSpace(g), strings.TrimSpace(a)
+		if !found || g == "" || a == "" || strings.Contains(a, ":") {
+			return "", errors.Errorf("maven package not of form 'group:artifact': %q", name)
+		}
+		return g + ":" + a, nil
+	default:
+		return name, nil
+	}
+}
+
+func normalizeNPM(name string) (string, error) {
+	// NOTE: Legacy packages may contain uppercase letters so case is preserved.
+	decoded, err := url.PathUnescape(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "decoding npm package %q", name)
+	}
+	scope, pkg, scoped := strings.Cut(decoded, "/")
+	switch {
+	case strings.HasPrefix(decoded, "@") != scoped:
+		return "", errors.Errorf("npm package has invalid scope: %q", name)
+	case scoped && (len(scope) == 1 || pkg == "" || strings.Contains(pkg, "/")):
+		return "", errors.Errorf("npm package has invalid scope: %q", name)
+	}
+	return decoded, nil
+}
+
+// Resolver resolves package names to their canonical identity, consulting
+// the registries for aliases not expressed by the ecosystem's naming rules.
+type Resolver struct {
+	Mux rebuild.RegistryMux
+}
+
+// maxRelocations bounds the chain of Maven relocations followed.
+const maxRelocations = 5
+
+// Resolve returns the Target with its package name in canonical form:
+//   - crates.io: The crate's published name e.g. "inflector" becomes "Inflector".
+//   - Maven: The coordinates to which the version was relocated, if any.
+func (r Resolver) Resolve(ctx context.Context, t rebuild.Target) (rebuild.Target, error) {
+	var err error
+	t.Package, err = Normalize(t.Ecosystem, t.Package)
+	if err != nil {
+		return t, err
+	}
+	switch t.Ecosystem {
+	case rebuild.CratesIO:
+		c, err := r.Mux.CratesIO.Crate(ctx, t.Package)
+		if err != nil {
+			return t, errors.Wrap(err, "fetching crate")
+		}
+		t.Package = c.Name
+	case rebuild.Maven:
+		if t.Version == "" {
+			break
+		}
+		for i := 0; i < maxRelocations; i++ {
+			pom, err := r.Mux.Maven.VersionPomXML(ctx, t.Package, t.Version)
+			if err != nil {
+				return t, errors.Wrap(err, "fetching pom")
+			}
+			relocated, ok := relocate(t, pom.Relocation)
+			if !ok {
+				return t, nil
+			}
+			t = relocated
+		}
+		return t, errors.Errorf("too many maven relocations resolving %s", t.Package)
+	}
+	return t, nil
+}
+
+// relocate returns the Target to which t was moved by the Maven relocation, if any.
+func relocate(t rebuild.Target, r *mavenreg.Relocation) (rebuild.Target, bool) {
+	if r == nil {
+		return t, false
+	}
+	g, a, _ := strings.Cut(t.Package, ":")
+	moved := t
+	if r.GroupID != "" {
+		g = r.GroupID
+	}
+	if r.ArtifactID != "" {
+		a = r.ArtifactID
+	}
+	moved.Package = g + ":" + a
+	if r.VersionID != "" {
+		moved.Version = r.VersionID
+	}
+	if moved == t {
+		return t, false
+	}
+	// NOTE: The artifact name is derived from the coordinates so must be re-selected.
+	moved.Artifact = ""
+	return moved, true
+}
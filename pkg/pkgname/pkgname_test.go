@@ -0,0 +1,157 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgname
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
+	mavenreg "github.com/google/oss-rebuild/pkg/registry/maven"
+)
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		eco     rebuild.Ecosystem
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{rebuild.PyPI, "requests", "requests", false},
+		{rebuild.PyPI, "Typing_Extensions", "typing-extensions", false},
+		{rebuild.PyPI, "zope.interface", "zope-interface", false},
+		{rebuild.PyPI, "  Foo__-.Bar ", "foo-bar", false},
+		{rebuild.NPM, "JSONStream", "JSONStream", false},
+		{rebuild.NPM, "@types/node", "@types/node", false},
+		{rebuild.NPM, "@types%2fnode", "@types/node", false},
+		{rebuild.NPM, "%40babel%2Fcore", "@babel/core", false},
+		{rebuild.NPM, "types/node", "", true},
+		{rebuild.NPM, "@types", "", true},
+		{rebuild.NPM, "@/node", "", true},
+		{rebuild.NPM, "@types/node/extra", "", true},
+		{rebuild.Maven, "com.google.guava:guava", "com.google.guava:guava", false},
+		{rebuild.Maven, " com.google.guava : guava ", "com.google.guava:guava", false},
+		{rebuild.Maven, "com.google.guava", "", true},
+		{rebuild.Maven, "com.google.guava:guava:33.0.0", "", true},
+		{rebuild.CratesIO, "Inflector", "Inflector", false},
+		{rebuild.CratesIO, " ", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.eco)+"/"+tc.name, func(t *testing.T) {
+			got, err := Normalize(tc.eco, tc.name)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Normalize() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	t1 := rebuild.Target{Ecosystem: rebuild.Maven, Package: "junit:junit-dep", Version: "4.11", Artifact: "junit-dep-4.11.jar"}
+	testCases := []struct {
+		name   string
+		r      *mavenreg.Relocation
+		want   rebuild.Target
+		wantOK bool
+	}{
+		{"none", nil, t1, false},
+		{"artifact", &mavenreg.Relocation{ArtifactID: "junit"}, rebuild.Target{Ecosystem: rebuild.Maven, Package: "junit:junit", Version: "4.11"}, true},
+		{"group and version", &mavenreg.Relocation{GroupID: "org.junit", VersionID: "4.11.1"}, rebuild.Target{Ecosystem: rebuild.Maven, Package: "org.junit:junit-dep", Version: "4.11.1"}, true},
+		{"self", &mavenreg.Relocation{GroupID: "junit", ArtifactID: "junit-dep"}, t1, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := relocate(t1, tc.r)
+			if ok != tc.wantOK {
+				t.Errorf("relocate() ok = %v, want %v", ok, tc.wantOK)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("relocate() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResolveCrate(t *testing.T) {
+	mockClient := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://crates.io/api/v1/crates/inflector",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`{"crate": {"id": "Inflector"}, "versions": []}`))),
+				},
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+	r := Resolver{Mux: rebuild.RegistryMux{CratesIO: cratesreg.HTTPRegistry{Client: mockClient}}}
+	got, err := r.Resolve(context.Background(), rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "inflector", Version: "0.11.4"})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	want := rebuild.Target{Ecosystem: rebuild.CratesIO, Package: "Inflector", Version: "0.11.4"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolveMavenRelocation(t *testing.T) {
+	mockClient := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://search.maven.org/remotecontent?filepath=junit/junit-dep/4.11/junit-dep-4.11.pom",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`<project><groupId>junit</groupId><artifactId>junit-dep</artifactId><version>4.11</version><packaging>pom</packaging><distributionManagement><relocation><artifactId>junit</artifactId></relocation></distributionManagement></project>`))),
+				},
+			},
+			{
+				URL: "https://search.maven.org/remotecontent?filepath=junit/junit/4.11/junit-4.11.pom",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`<project><groupId>junit</groupId><artifactId>junit</artifactId><version>4.11</version></project>`))),
+				},
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+	r := Resolver{Mux: rebuild.RegistryMux{Maven: mavenreg.HTTPRegistry{Client: mockClient}}}
+	got, err := r.Resolve(context.Background(), rebuild.Target{Ecosystem: rebuild.Maven, Package: "junit:junit-dep", Version: "4.11"})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	want := rebuild.Target{Ecosystem: rebuild.Maven, Package: "junit:junit", Version: "4.11"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -71,10 +71,10 @@ func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Ins
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Source); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Source")
 	}
-	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Deps); err != nil {
+	if _, err := rebuild.ExecuteScriptWithLimits(ctx, fs.Root(), inst.Deps); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Deps")
 	}
-	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Build); err != nil {
+	if _, err := rebuild.ExecuteScriptWithLimits(ctx, fs.Root(), inst.Build); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Build")
 	}
 	return nil
@@ -95,6 +95,7 @@ var (
 	verdictUpstreamOnly    = errors.New("file(s) found in upstream but not rebuild")
 	verdictRebuildOnly     = errors.New("file(s) found in rebuild but not upstream")
 	verdictContentDiff     = errors.New("content differences found")
+	verdictLayout          = errors.New("file(s) moved between upstream and rebuild")
 )
 
 func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.Asset, assets rebuild.AssetStore, inst rebuild.Instructions) (msg error, err error) {
@@ -102,7 +103,7 @@ func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.A
 	if err != nil {
 		return nil, errors.Wrapf(err, "summarizing assets")
 	}
-	upOnly, diffs, rbOnly := csUP.Diff(csRB)
+	upOnly, diffs, rbOnly, moves := csUP.DiffWithMoves(csRB)
 	var foundDSStore bool
 	for _, f := range upOnly {
 		if strings.HasSuffix(f, "/.DS_STORE") {
@@ -126,6 +127,9 @@ func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.A
 		allDiffs := append([]string{}, rbOnly...)
 		allDiffs = append(allDiffs, upOnly...)
 		allDiffs = append(allDiffs, diffs...)
+		for _, m := range moves {
+			allDiffs = append(allDiffs, m.From)
+		}
 		cargoVersionDiff = len(allDiffs) > 0
 		for _, f := range allDiffs {
 			if !slices.Contains(metadataFiles, f) {
@@ -155,7 +159,9 @@ func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.A
 				}
 			}
 		}
-		gitRefDiff = upRef != inst.Location.Ref
+		// NOTE: Canonicalization strips the VCS info so, when comparing
+		// canonicalized crates, whether the ref differs is unknown.
+		gitRefDiff = upRef != "" && upRef != inst.Location.Ref
 	}
 	switch {
 	case foundDSStore:
@@ -174,6 +180,8 @@ func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.A
 		return verdictRebuildOnly, nil
 	case len(diffs) > 0:
 		return verdictContentDiff, nil
+	case len(moves) > 0:
+		return verdictLayout, nil
 	default:
 		return nil, nil
 	}
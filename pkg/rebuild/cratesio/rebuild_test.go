@@ -138,7 +138,7 @@ func TestCompare(t *testing.T) {
 				{Header: &tar.Header{Name: "foo-0.0.1/.cargo_vcs_info.json", Typeflag: tar.TypeReg, Size: 22, Mode: 0644}, Body: []byte(`{"git":{"sha1":"abc"}}`)},
 				{Header: &tar.Header{Name: "foo-0.0.1/Cargo.toml", Typeflag: tar.TypeReg, Size: 2, Mode: 0644}, Body: []byte("#b")},
 				{Header: &tar.Header{Name: "foo-0.0.1/Cargo.toml.orig", Typeflag: tar.TypeReg, Size: 2, Mode: 0644}, Body: []byte("#a")},
-				{Header: &tar.Header{Name: "foo-0.0.1/file2", Typeflag: tar.TypeReg, Size: 5, Mode: 0644}, Body: []byte("stuff")},
+				{Header: &tar.Header{Name: "foo-0.0.1/file2", Typeflag: tar.TypeReg, Size: 5, Mode: 0644}, Body: []byte("other")},
 			},
 			inst:     rebuild.Instructions{Location: rebuild.Location{Ref: "abc"}},
 			expected: verdictMismatchedFiles,
@@ -17,6 +17,7 @@ package cratesio
 import (
 	"path"
 
+	"github.com/google/oss-rebuild/internal/semver"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 )
 
@@ -43,33 +44,21 @@ func (b *CratesIOCargoPackage) GenerateFor(t rebuild.Target, be rebuild.BuildEnv
 	// Unless PreferPreciseToolchain is specified, we ignore CargoBuildExplicitLockfile.Version. Too
 	// many of these predate sparse index support introduced in 1.68.0. Without this, the full index
 	// requires ~700MB and minutes to fetch.
-	deps, err := rebuild.PopulateTemplate(`
-{{if ne .ExplicitLockfile nil -}}
-echo '{{.ExplicitLockfile.LockfileBase64}}' | base64 -d > Cargo.lock
-{{end -}}
-{{if .BuildEnv.PreferPreciseToolchain -}}
-/usr/bin/rustup-init -y --profile minimal --default-toolchain {{.RustVersion}}
-{{end -}}
-`, struct {
-		CratesIOCargoPackage
-		BuildEnv rebuild.BuildEnv
-	}{*b, be})
-	if err != nil {
-		return rebuild.Instructions{}, err
+	var deps rebuild.Script
+	if b.ExplicitLockfile != nil {
+		deps.Raw(rebuild.Command("echo", b.ExplicitLockfile.LockfileBase64) + " | base64 -d > Cargo.lock")
 	}
-	build, err := rebuild.PopulateTemplate(`
-/root/.cargo/bin/cargo package --no-verify{{if or (not .BuildEnv.PreferPreciseToolchain) (gt 0 (SemverCmp "1.56.0" .RustVersion))}} --package "path+file://$(readlink -f {{.Location.Dir}})"{{end}}
-`, struct {
-		CratesIOCargoPackage
-		BuildEnv rebuild.BuildEnv
-	}{*b, be})
-	if err != nil {
-		return rebuild.Instructions{}, err
+	if be.PreferPreciseToolchain {
+		deps.Cmd("/usr/bin/rustup-init", "-y", "--profile", "minimal", "--default-toolchain", b.RustVersion)
+	}
+	build := rebuild.Command("/root/.cargo/bin/cargo", "package", "--no-verify")
+	if !be.PreferPreciseToolchain || semver.Cmp("1.56.0", b.RustVersion) < 0 {
+		build += ` --package "path+file://$(readlink -f ` + rebuild.Quote(b.Location.Dir) + `)"`
 	}
 	return rebuild.Instructions{
 		Location:   b.Location,
 		Source:     src,
-		Deps:       deps,
+		Deps:       deps.String(),
 		Build:      build,
 		SystemDeps: []string{"git", "rustup"},
 		OutputPath: path.Join("target", "package", t.Artifact),
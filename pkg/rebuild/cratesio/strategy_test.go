@@ -43,7 +43,7 @@ func TestCratesIOCargoPackage(t *testing.T) {
 			rebuild.BuildEnv{HasRepo: true},
 			rebuild.Instructions{
 				Location:   defaultLocation,
-				Source:     "git checkout --force 'the_ref'",
+				Source:     "git checkout --force the_ref",
 				Deps:       "",
 				Build:      `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f the_dir)"`,
 				SystemDeps: []string{"git", "rustup"},
@@ -62,8 +62,8 @@ func TestCratesIOCargoPackage(t *testing.T) {
 			rebuild.BuildEnv{HasRepo: true},
 			rebuild.Instructions{
 				Location:   defaultLocation,
-				Source:     "git checkout --force 'the_ref'",
-				Deps:       "echo 'lock_base64' | base64 -d > Cargo.lock\n",
+				Source:     "git checkout --force the_ref",
+				Deps:       "echo lock_base64 | base64 -d > Cargo.lock",
 				Build:      `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f the_dir)"`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
@@ -82,8 +82,8 @@ func TestCratesIOCargoPackage(t *testing.T) {
 			rebuild.BuildEnv{HasRepo: true, PreferPreciseToolchain: true},
 			rebuild.Instructions{
 				Location:   defaultLocation,
-				Source:     "git checkout --force 'the_ref'",
-				Deps:       "/usr/bin/rustup-init -y --profile minimal --default-toolchain 1.77.0\n",
+				Source:     "git checkout --force the_ref",
+				Deps:       "/usr/bin/rustup-init -y --profile minimal --default-toolchain 1.77.0",
 				Build:      `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f the_dir)"`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
@@ -101,10 +101,9 @@ func TestCratesIOCargoPackage(t *testing.T) {
 			rebuild.BuildEnv{HasRepo: true, PreferPreciseToolchain: true},
 			rebuild.Instructions{
 				Location: defaultLocation,
-				Source:   "git checkout --force 'the_ref'",
-				Deps: `echo 'lock_base64' | base64 -d > Cargo.lock
-/usr/bin/rustup-init -y --profile minimal --default-toolchain 1.77.0
-`,
+				Source:   "git checkout --force the_ref",
+				Deps: `echo lock_base64 | base64 -d > Cargo.lock
+/usr/bin/rustup-init -y --profile minimal --default-toolchain 1.77.0`,
 				Build:      `/root/.cargo/bin/cargo package --no-verify --package "path+file://$(readlink -f the_dir)"`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
@@ -119,8 +118,8 @@ func TestCratesIOCargoPackage(t *testing.T) {
 			rebuild.BuildEnv{HasRepo: true, PreferPreciseToolchain: true},
 			rebuild.Instructions{
 				Location:   defaultLocation,
-				Source:     "git checkout --force 'the_ref'",
-				Deps:       "/usr/bin/rustup-init -y --profile minimal --default-toolchain 1.55.0\n",
+				Source:     "git checkout --force the_ref",
+				Deps:       "/usr/bin/rustup-init -y --profile minimal --default-toolchain 1.55.0",
 				Build:      `/root/.cargo/bin/cargo package --no-verify`,
 				SystemDeps: []string{"git", "rustup"},
 				OutputPath: "target/package/the_artifact",
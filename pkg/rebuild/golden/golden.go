@@ -0,0 +1,98 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package golden records inferred strategies alongside the instructions they
+// generate so that changes to instruction generation are caught in review.
+//
+// Fixtures are stored under the testdata/golden directory of the ecosystem's
+// package, e.g. pkg/rebuild/npm/testdata/golden.
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Env is the build environment from which fixture instructions are generated.
+var Env = rebuild.BuildEnv{HasRepo: true, TimewarpHost: "localhost:8081"}
+
+// Fixture is a strategy and the instructions it is expected to generate.
+type Fixture struct {
+	Target       rebuild.Target       `yaml:"target"`
+	Strategy     schema.StrategyOneOf `yaml:"strategy"`
+	Instructions rebuild.Instructions `yaml:"instructions"`
+}
+
+// New creates the Fixture for the strategy using the instructions it
+// currently generates for t.
+func New(t rebuild.Target, oneof schema.StrategyOneOf) (*Fixture, error) {
+	f := &Fixture{Target: t, Strategy: oneof}
+	var err error
+	f.Instructions, err = f.Generate()
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Generate returns the instructions the fixture's strategy generates.
+func (f *Fixture) Generate() (rebuild.Instructions, error) {
+	s, err := f.Strategy.Strategy()
+	if err != nil {
+		return rebuild.Instructions{}, errors.Wrap(err, "reading strategy")
+	}
+	inst, err := s.GenerateFor(f.Target, Env)
+	if err != nil {
+		return rebuild.Instructions{}, errors.Wrap(err, "generating instructions")
+	}
+	return inst, nil
+}
+
+// Path returns the location of t's fixture within root, the directory
+// containing the ecosystem packages.
+func Path(root string, t rebuild.Target) string {
+	name := strings.ReplaceAll(t.Package, "/", "!") + "@" + t.Version + "@" + strings.ReplaceAll(t.Artifact, "/", "!") + ".yaml"
+	return filepath.Join(root, string(t.Ecosystem), "testdata", "golden", name)
+}
+
+// Read parses the fixture at path.
+func Read(path string) (*Fixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{}
+	if err := yaml.Unmarshal(b, f); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", path)
+	}
+	return f, nil
+}
+
+// Write stores the fixture at path, creating its parent directories.
+func Write(path string, f *Fixture) error {
+	b, err := yaml.Marshal(f)
+	if err != nil {
+		return errors.Wrap(err, "marshalling fixture")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "creating fixture directory")
+	}
+	return os.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package golden
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+)
+
+// TestFixtures checks the fixtures of every ecosystem package.
+func TestFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "*", "testdata", "golden", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			f, err := Read(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := f.Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(f.Instructions, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("instructions mismatch (-golden +got):\n%s\nRegenerate with \"ctl gen-golden\" if the change is intended.", diff)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "@scope/pkg", Version: "1.0.0", Artifact: "scope-pkg-1.0.0.tgz"}
+	oneof := schema.NewStrategyOneOf(&npm.NPMPackBuild{
+		Location:   rebuild.Location{Repo: "https://github.com/scope/pkg", Ref: "0beec7b5ea3f0fdbc95d0dd47f3c5bc275da8a33", Dir: "packages/pkg"},
+		NPMVersion: "10.2.4",
+	})
+	want, err := New(target, oneof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := Path(t.TempDir(), target)
+	if got := filepath.Base(path); got != "@scope!pkg@1.0.0@scope-pkg-1.0.0.tgz.yaml" {
+		t.Errorf("Path() base = %q", got)
+	}
+	if err := Write(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,327 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint statically checks build definitions for mistakes that would
+// otherwise only be detected after executing the rebuild.
+package lint
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/cratesio"
+	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/oci"
+	"github.com/google/oss-rebuild/pkg/rebuild/pypi"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+// Check identifies a class of problem detected in a build definition.
+type Check string
+
+const (
+	// CheckGenerate marks definitions from which instructions cannot be generated.
+	CheckGenerate Check = "generate"
+	// CheckLocation marks missing or imprecise source locations.
+	CheckLocation Check = "location"
+	// CheckOutputPath marks missing or malformed output paths.
+	CheckOutputPath Check = "output-path"
+	// CheckShellSyntax marks generated scripts that the shell cannot parse.
+	CheckShellSyntax Check = "shell-syntax"
+	// CheckChecksum marks malformed digests and encoded content.
+	CheckChecksum Check = "checksum"
+	// CheckFlags marks missing or conflicting strategy options.
+	CheckFlags Check = "flags"
+	// CheckURL marks URLs that could not be reached.
+	CheckURL Check = "url"
+)
+
+// Severity is the degree to which a Finding is expected to impact the rebuild.
+type Severity string
+
+const (
+	// Error findings are expected to cause the rebuild to fail.
+	Error Severity = "error"
+	// Warning findings may cause the rebuild to fail or to be irreproducible.
+	Warning Severity = "warning"
+)
+
+// Finding is a problem detected in a build definition.
+type Finding struct {
+	Check    Check
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Check, f.Message)
+}
+
+// Options configures the checks performed by Lint.
+type Options struct {
+	// Env is the build environment for which instructions are generated.
+	Env rebuild.BuildEnv
+	// Shell, if provided, is the shell used to check the syntax of the generated scripts.
+	Shell string
+	// Client, if provided, is used to check that the URLs referenced are reachable.
+	Client httpx.BasicClient
+}
+
+// Lint returns the problems detected in the strategy when rebuilding the
+// target. The target's artifact may be empty if not yet known.
+func Lint(ctx context.Context, t rebuild.Target, s rebuild.Strategy, opts Options) []Finding {
+	var fs findings
+	lintStrategy(&fs, s, opts.Env)
+	// NOTE: Location hints are expanded by inference so cannot be generated here.
+	if _, ok := s.(*rebuild.LocationHint); ok {
+		return fs
+	}
+	inst, err := s.GenerateFor(t, opts.Env)
+	if err != nil {
+		fs.add(CheckGenerate, Error, "generating instructions: %v", err)
+		return fs
+	}
+	lintLocation(&fs, inst.Location, opts.Env)
+	lintOutputPath(&fs, inst.OutputPath, t)
+	if opts.Shell != "" && inst.Platform.IsLinux() {
+		lintShellSyntax(ctx, &fs, opts.Shell, inst)
+	}
+	if opts.Client != nil {
+		lintURLs(ctx, &fs, opts.Client, inst, opts.Env)
+	}
+	return fs
+}
+
+// HasErrors returns whether any of the findings are errors.
+func HasErrors(fs []Finding) bool {
+	return slices.ContainsFunc(fs, func(f Finding) bool { return f.Severity == Error })
+}
+
+type findings []Finding
+
+func (fs *findings) add(c Check, s Severity, format string, args ...any) {
+	*fs = append(*fs, Finding{Check: c, Severity: s, Message: fmt.Sprintf(format, args...)})
+}
+
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+func lintLocation(fs *findings, loc rebuild.Location, env rebuild.BuildEnv) {
+	if loc.Repo == "" && !env.HasRepo {
+		fs.add(CheckLocation, Error, "repo not provided")
+	}
+	if loc.Ref == "" {
+		fs.add(CheckLocation, Error, "ref not provided")
+	} else if !commitPattern.MatchString(loc.Ref) {
+		fs.add(CheckLocation, Warning, "ref %q is not a full commit hash and may resolve differently over time", loc.Ref)
+	}
+	if path.IsAbs(loc.Dir) || escapes(loc.Dir) {
+		fs.add(CheckLocation, Error, "dir %q is not within the repo", loc.Dir)
+	}
+}
+
+func lintOutputPath(fs *findings, p string, t rebuild.Target) {
+	switch {
+	case p == "":
+		fs.add(CheckOutputPath, Error, "output path not provided")
+	case path.IsAbs(p) || escapes(p):
+		fs.add(CheckOutputPath, Error, "output path %q is not within the repo", p)
+	case strings.HasSuffix(p, "/"):
+		fs.add(CheckOutputPath, Error, "output path %q is a directory", p)
+	case strings.ContainsAny(p, "*?["):
+		fs.add(CheckOutputPath, Error, "output path %q contains a glob which is not expanded", p)
+	case t.Artifact != "" && path.Base(p) != t.Artifact:
+		fs.add(CheckOutputPath, Warning, "output path %q does not name the artifact %s", p, t.Artifact)
+	}
+}
+
+// escapes returns whether the relative path refers outside its root.
+func escapes(p string) bool {
+	c := path.Clean(p)
+	return c == ".." || strings.HasPrefix(c, "../")
+}
+
+func lintShellSyntax(ctx context.Context, fs *findings, shell string, inst rebuild.Instructions) {
+	for _, step := range []struct{ name, script string }{
+		{"source", inst.Source},
+		{"deps", inst.Deps},
+		{"build", inst.Build},
+	} {
+		if strings.TrimSpace(step.script) == "" {
+			continue
+		}
+		cmd := exec.CommandContext(ctx, shell, "-n")
+		cmd.Stdin = strings.NewReader(step.script)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				// The shell could not be executed so no conclusion can be drawn.
+				return
+			}
+			fs.add(CheckShellSyntax, Error, "%s script: %s", step.name, strings.TrimSpace(stderr.String()))
+		}
+	}
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s'"\x60)]+`)
+
+func lintURLs(ctx context.Context, fs *findings, client httpx.BasicClient, inst rebuild.Instructions, env rebuild.BuildEnv) {
+	var urls []string
+	if strings.HasPrefix(inst.Location.Repo, "http") {
+		urls = append(urls, inst.Location.Repo)
+	}
+	for _, script := range []string{inst.Source, inst.Deps, inst.Build} {
+		urls = append(urls, urlPattern.FindAllString(script, -1)...)
+	}
+	seen := make(map[string]bool)
+	for _, u := range urls {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		parsed, err := url.Parse(u)
+		if err != nil {
+			fs.add(CheckURL, Error, "malformed URL %q", u)
+			continue
+		}
+		// NOTE: Timewarp is only available from within the build environment.
+		if env.TimewarpHost != "" && parsed.Host == env.TimewarpHost {
+			continue
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+		if err != nil {
+			fs.add(CheckURL, Error, "malformed URL %q", u)
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			fs.add(CheckURL, Warning, "unable to reach %s: %v", u, err)
+			continue
+		}
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		switch {
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			fs.add(CheckURL, Error, "%s not found: %s", u, resp.Status)
+		case resp.StatusCode >= 400 && resp.StatusCode != http.StatusMethodNotAllowed:
+			fs.add(CheckURL, Warning, "unable to reach %s: %s", u, resp.Status)
+		}
+	}
+}
+
+var (
+	// digestLengths are the hex lengths of the digest algorithms accepted in definitions.
+	digestLengths = map[string]int{"sha256": 64, "sha384": 96, "sha512": 128}
+	// platformPattern matches OCI platforms of the form "os/arch[/variant]".
+	platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+)
+
+// checkDigest returns a description of the problem with the "algo:hex" digest, if any.
+func checkDigest(d string) string {
+	algo, digest, found := strings.Cut(d, ":")
+	if !found {
+		return fmt.Sprintf("digest %q not of form 'algorithm:hex'", d)
+	}
+	n, ok := digestLengths[algo]
+	if !ok {
+		return fmt.Sprintf("digest %q uses unsupported algorithm %q", d, algo)
+	}
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != n || strings.ToLower(digest) != digest {
+		return fmt.Sprintf("digest %q is not %d lowercase hex characters", d, n)
+	}
+	return ""
+}
+
+// lintStrategy performs the checks specific to each strategy's options.
+func lintStrategy(fs *findings, s rebuild.Strategy, env rebuild.BuildEnv) {
+	switch s := s.(type) {
+	case *npm.NPMPackBuild:
+		if s.NPMVersion == "" {
+			fs.add(CheckFlags, Error, "npm_version not provided")
+		}
+	case *npm.NPMCustomBuild:
+		if s.NPMVersion == "" {
+			fs.add(CheckFlags, Error, "npm_version not provided")
+		}
+		if s.NodeVersion == "" {
+			fs.add(CheckFlags, Error, "node_version not provided")
+		} else if strings.HasPrefix(s.NodeVersion, "v") {
+			fs.add(CheckFlags, Error, "node_version %q must not include the 'v' prefix", s.NodeVersion)
+		}
+		if s.Command == "" {
+			fs.add(CheckFlags, Error, "command not provided")
+		}
+		if s.RegistryTime.IsZero() {
+			fs.add(CheckFlags, Warning, "registry_time not provided so dependencies will resolve to their latest versions")
+		}
+	case *pypi.PureWheelBuild:
+		for _, req := range s.Requirements {
+			fields := strings.Fields(req)
+			for _, f := range fields {
+				switch {
+				case f == "-e" || f == "--editable":
+					fs.add(CheckFlags, Error, "requirement %q cannot be installed as editable", req)
+				case strings.HasPrefix(f, "--hash="):
+					if msg := checkDigest(strings.TrimPrefix(f, "--hash=")); msg != "" {
+						fs.add(CheckChecksum, Error, "requirement %q: %s", req, msg)
+					}
+				}
+			}
+		}
+	case *cratesio.CratesIOCargoPackage:
+		if env.PreferPreciseToolchain && s.RustVersion == "" {
+			fs.add(CheckFlags, Error, "rust_version required to use the precise toolchain")
+		}
+		if s.ExplicitLockfile != nil {
+			if _, err := base64.StdEncoding.DecodeString(s.ExplicitLockfile.LockfileBase64); err != nil {
+				fs.add(CheckChecksum, Error, "lockfile_base64 is not valid base64: %v", err)
+			}
+		}
+	case *oci.DockerfileBuild:
+		if s.Platform != "" && !platformPattern.MatchString(s.Platform) {
+			fs.add(CheckFlags, Error, "platform %q not of form 'os/arch[/variant]'", s.Platform)
+		}
+		var names []string
+		for name := range s.BaseImages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			digest := s.BaseImages[name]
+			if strings.Contains(name, "@") {
+				fs.add(CheckFlags, Error, "base image %q must not include a digest", name)
+			}
+			if msg := checkDigest(digest); msg != "" {
+				fs.add(CheckChecksum, Error, "base image %s: %s", name, msg)
+			}
+		}
+	case *rebuild.ManualStrategy:
+		if strings.TrimSpace(s.Build) == "" {
+			fs.add(CheckFlags, Error, "build not provided")
+		}
+	}
+}
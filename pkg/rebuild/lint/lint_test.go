@@ -0,0 +1,168 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os/exec"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/oci"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+const commit = "5f4a4c6ed5da9e3d4a6a4b8e8e8b1ea7c2f6a6d1"
+
+func checks(fs []Finding) []Check {
+	var cs []Check
+	for _, f := range fs {
+		cs = append(cs, f.Check)
+	}
+	return cs
+}
+
+func TestLint(t *testing.T) {
+	npmTarget := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz"}
+	ociTarget := rebuild.Target{Ecosystem: rebuild.OCI, Package: "example.com/app", Version: "v1", Artifact: "app.tar"}
+	repo := rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: commit, Dir: "."}
+	testCases := []struct {
+		name     string
+		target   rebuild.Target
+		strategy rebuild.Strategy
+		want     []Check
+	}{
+		{
+			name:     "valid",
+			target:   npmTarget,
+			strategy: &npm.NPMPackBuild{Location: repo, NPMVersion: "6.14.4"},
+		},
+		{
+			name:     "location hint",
+			target:   npmTarget,
+			strategy: &rebuild.LocationHint{Location: repo},
+		},
+		{
+			name:     "missing npm version",
+			target:   npmTarget,
+			strategy: &npm.NPMPackBuild{Location: repo},
+			want:     []Check{CheckFlags},
+		},
+		{
+			name:     "tag ref",
+			target:   npmTarget,
+			strategy: &npm.NPMPackBuild{Location: rebuild.Location{Repo: repo.Repo, Ref: "v1.3.0", Dir: "."}, NPMVersion: "6.14.4"},
+			want:     []Check{CheckLocation},
+		},
+		{
+			name:     "dir outside repo",
+			target:   npmTarget,
+			strategy: &npm.NPMPackBuild{Location: rebuild.Location{Repo: repo.Repo, Ref: commit, Dir: "../other"}, NPMVersion: "6.14.4"},
+			want:     []Check{CheckLocation, CheckOutputPath},
+		},
+		{
+			name:     "manual missing output path",
+			target:   npmTarget,
+			strategy: &rebuild.ManualStrategy{Location: repo, Build: "npm pack"},
+			want:     []Check{CheckOutputPath},
+		},
+		{
+			name:     "manual glob output path",
+			target:   npmTarget,
+			strategy: &rebuild.ManualStrategy{Location: repo, Build: "npm pack", OutputPath: "*.tgz"},
+			want:     []Check{CheckOutputPath},
+		},
+		{
+			name:     "manual missing build",
+			target:   npmTarget,
+			strategy: &rebuild.ManualStrategy{Location: repo, OutputPath: "left-pad-1.3.0.tgz"},
+			want:     []Check{CheckFlags},
+		},
+		{
+			name:     "oci malformed digest and platform",
+			target:   ociTarget,
+			strategy: &oci.DockerfileBuild{Location: repo, Platform: "amd64", BaseImages: map[string]string{"alpine:3.19": "sha256:abc"}},
+			want:     []Check{CheckFlags, CheckChecksum},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Lint(context.Background(), tc.target, tc.strategy, Options{})
+			if diff := cmp.Diff(tc.want, checks(got)); diff != "" {
+				t.Errorf("Lint() checks mismatch (-want +got):\n%s\nfindings: %v", diff, got)
+			}
+		})
+	}
+}
+
+func TestLintShellSyntax(t *testing.T) {
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz"}
+	loc := rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: commit, Dir: "."}
+	for _, tc := range []struct {
+		build string
+		want  []Check
+	}{
+		{"npm pack && echo done", nil},
+		{"if [ -f package.json ]; then npm pack", []Check{CheckShellSyntax}},
+		{"echo 'unterminated", []Check{CheckShellSyntax}},
+	} {
+		s := &rebuild.ManualStrategy{Location: loc, Build: tc.build, OutputPath: target.Artifact}
+		got := Lint(context.Background(), target, s, Options{Shell: shell})
+		if diff := cmp.Diff(tc.want, checks(got)); diff != "" {
+			t.Errorf("Lint(%q) checks mismatch (-want +got):\n%s\nfindings: %v", tc.build, diff, got)
+		}
+	}
+}
+
+func TestLintURLs(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Artifact: "left-pad-1.3.0.tgz"}
+	s := &rebuild.ManualStrategy{
+		Location:   rebuild.Location{Repo: "https://github.com/left-pad/left-pad", Ref: commit, Dir: "."},
+		Deps:       "wget https://example.com/missing.tar.gz\nnpm config set registry http://localhost:8081/npm/2024-01-01T00:00:00Z",
+		Build:      "npm pack",
+		OutputPath: target.Artifact,
+	}
+	client := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL:      "https://github.com/left-pad/left-pad",
+				Response: &http.Response{StatusCode: 200, Status: "200 OK", Body: io.NopCloser(bytes.NewReader(nil))},
+			},
+			{
+				URL:      "https://example.com/missing.tar.gz",
+				Response: &http.Response{StatusCode: 404, Status: "404 Not Found", Body: io.NopCloser(bytes.NewReader(nil))},
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+	got := Lint(context.Background(), target, s, Options{Env: rebuild.BuildEnv{TimewarpHost: "localhost:8081"}, Client: client})
+	want := []Finding{{Check: CheckURL, Severity: Error, Message: "https://example.com/missing.tar.gz not found: 404 Not Found"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Lint() mismatch (-want +got):\n%s", diff)
+	}
+}
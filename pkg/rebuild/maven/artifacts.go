@@ -0,0 +1,48 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maven
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	mvnreg "github.com/google/oss-rebuild/pkg/registry/maven"
+	"github.com/pkg/errors"
+)
+
+func artifactID(pkg string) string {
+	_, a, _ := strings.Cut(pkg, ":")
+	return a
+}
+
+// ArtifactType returns the type of release file named by the target's artifact.
+func ArtifactType(t rebuild.Target) (mvnreg.FileType, error) {
+	typ, ok := mvnreg.ParseFileName(artifactID(t.Package), t.Version, t.Artifact)
+	if !ok || !slices.Contains(mvnreg.ReleaseTypes, typ) {
+		return "", errors.Errorf("unsupported maven artifact: %s", t.Artifact)
+	}
+	return typ, nil
+}
+
+// DefaultArtifact returns the main artifact of a package version: its jar or,
+// for POM-only packages such as parent POMs and BOMs, the POM itself.
+func DefaultArtifact(t rebuild.Target, pom mvnreg.PomXML) string {
+	typ := mvnreg.TypeJar
+	if pom.Packaging == "pom" {
+		typ = mvnreg.TypePOM
+	}
+	return mvnreg.FileName(artifactID(t.Package), t.Version, typ)
+}
@@ -15,8 +15,6 @@
 package maven
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
 	"encoding/xml"
 	"fmt"
@@ -24,6 +22,7 @@ import (
 	"log"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	billy "github.com/go-git/go-billy/v5"
@@ -54,8 +53,10 @@ type BuildConfig struct {
 }
 
 type MavenBuild struct {
-	// JDKVersion is the version of the JDK to use for the build.
+	// JDKVersion is the feature version of the JDK to use for the build, one of JDKs.
 	JDKVersion string
+	// JDKEvidence are the observations from which JDKVersion was inferred.
+	JDKEvidence []JDKEvidence
 }
 
 func getPomXML(tree *object.Tree, path string) (pomXML mavenreg.PomXML, err error) {
@@ -102,35 +103,49 @@ func doRepoInferenceAndClone(ctx context.Context, name string, pomXML *mavenreg.
 	return
 }
 
-func getJarJDK(name, version string) (string, error) {
+func getJarJDK(name, version string) ([]JDKEvidence, error) {
 	r, err := mavenreg.ReleaseFile(name, version, mavenreg.TypeJar)
 	if err != nil {
-		return "", errors.Wrap(err, "fetching jar file")
+		return nil, errors.Wrap(err, "fetching jar file")
 	}
+	defer r.Close()
 	b, err := io.ReadAll(r)
 	if err != nil {
-		return "", errors.Wrap(err, "reading jar file")
+		return nil, errors.Wrap(err, "reading jar file")
 	}
-	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
-	if err != nil {
-		return "", errors.Wrap(err, "unzipping jar file")
-	}
-	f, err := zr.Open("META-INF/MANIFEST.MF")
+	return jarJDK(b)
+}
+
+// getCompilerJDK returns the compiler configuration of the module's pom.xml
+// or, if it has none, that of the repo's root pom.xml.
+func getCompilerJDK(c *object.Commit, dir string) (*JDKEvidence, error) {
+	t, err := c.Tree()
 	if err != nil {
-		return "", errors.Wrap(err, "opening manifest file")
+		return nil, errors.Wrap(err, "fetching tree")
 	}
-	defer f.Close()
-	fr, err := io.ReadAll(f)
-	if err != nil {
-		return "", errors.Wrap(err, "reading manifest file")
+	paths := []string{path.Join(dir, "pom.xml")}
+	if paths[0] != "pom.xml" {
+		paths = append(paths, "pom.xml")
 	}
-	for _, line := range strings.Split(string(fr), "\n") {
-		if strings.HasPrefix(line, "Build-Jdk:") {
-			_, value, _ := strings.Cut(line, ":")
-			return strings.TrimSpace(value), nil
+	for _, p := range paths {
+		f, err := t.File(p)
+		if err == object.ErrFileNotFound {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "fetching %s", p)
+		}
+		contents, err := f.Contents()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", p)
+		}
+		if e, err := compilerJDK([]byte(contents)); err != nil {
+			return nil, errors.Wrapf(err, "reading %s", p)
+		} else if e != nil {
+			e.Detail = fmt.Sprintf("%s in %s", e.Detail, p)
+			return e, nil
 		}
 	}
-	return "", nil
+	return nil, nil
 }
 
 func doInference(ctx context.Context, t rebuild.Target, rcfg *RepoConfig) (BuildConfig, error) {
@@ -185,15 +200,25 @@ func doInference(ctx context.Context, t rebuild.Target, rcfg *RepoConfig) (Build
 		}
 		return cfg, errors.Errorf("no valid git ref")
 	}
-	jdk, err := getJarJDK(name, version)
+	evidence, err := getJarJDK(name, version)
+	if err != nil {
+		// NOTE: The POM may still identify the JDK, e.g. for packages with no jar.
+		log.Printf("jar JDK inference failed: %v", err)
+	}
+	if e, err := getCompilerJDK(c, dir); err != nil {
+		log.Printf("compiler JDK inference failed: %v", err)
+	} else if e != nil {
+		evidence = append(evidence, *e)
+	}
+	jdk, err := selectJDK(evidence)
 	if err != nil {
-		return cfg, errors.Wrap(err, "fetching JDK")
+		return cfg, errors.Wrap(err, "selecting JDK")
 	}
-	if jdk == "" {
-		return cfg, errors.New("no JDK found")
+	for _, e := range evidence {
+		log.Printf("JDK evidence: %s", e)
 	}
-	// TODO: Normalize JDK
-	return BuildConfig{Dir: dir, Ref: ref, Build: MavenBuild{JDKVersion: jdk}}, nil
+	log.Printf("using JDK %d", jdk)
+	return BuildConfig{Dir: dir, Ref: ref, Build: MavenBuild{JDKVersion: strconv.Itoa(jdk), JDKEvidence: evidence}}, nil
 }
 
 // findAndValidatePomXML ensures the package config has the expected name and version,
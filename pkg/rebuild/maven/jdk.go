@@ -0,0 +1,262 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maven
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JDKs are the feature versions of the JDKs available to builds, in ascending order.
+var JDKs = []int{8, 11, 17, 21}
+
+// minTarget returns the oldest bytecode target supported by the javac of the given JDK.
+func minTarget(jdk int) int {
+	switch {
+	case jdk >= 20:
+		return 8
+	case jdk >= 12:
+		return 7
+	case jdk >= 9:
+		return 6
+	default:
+		return 1
+	}
+}
+
+// The sources of evidence for the JDK used to build an artifact.
+const (
+	// BytecodeEvidence is the newest class file version in the jar.
+	BytecodeEvidence = "bytecode"
+	// CompilerEvidence is the release or target configured for maven-compiler-plugin.
+	CompilerEvidence = "maven-compiler-plugin"
+	// ManifestEvidence is the Build-Jdk recorded in the jar's manifest.
+	ManifestEvidence = "manifest"
+)
+
+// JDKEvidence is an observation constraining the JDK used to build an artifact.
+type JDKEvidence struct {
+	Source string
+	// Detail is the observed value, e.g. "Build-Jdk: 1.8.0_292".
+	Detail string
+	// Version is the JDK feature version implied by the observation.
+	Version int
+}
+
+func (e JDKEvidence) String() string {
+	return fmt.Sprintf("%s (%s) implies JDK %d", e.Source, e.Detail, e.Version)
+}
+
+// jdkFeature returns the feature version of a JDK or bytecode target
+// version string, e.g. 8 for "1.8.0_292" and 11 for "11.0.2".
+func jdkFeature(version string) (int, bool) {
+	version = strings.TrimSpace(version)
+	version = strings.TrimPrefix(version, "1.")
+	if i := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' }); i != -1 {
+		version = version[:i]
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil || v == 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// classFileMajorOffset is the difference between the major version of a
+// class file and the feature version of the JDK targeted, e.g. 52 for JDK 8.
+const classFileMajorOffset = 44
+
+// bytecodeJDK returns the newest JDK targeted by the class files in the jar.
+func bytecodeJDK(zr *zip.Reader) (*JDKEvidence, error) {
+	var major uint16
+	var newest string
+	for _, f := range zr.File {
+		// NOTE: Module descriptors and multi-release classes target newer JDKs
+		// than the rest of the jar by design so they don't reflect the build.
+		if !strings.HasSuffix(f.Name, ".class") || strings.HasSuffix(f.Name, "module-info.class") || strings.HasPrefix(f.Name, "META-INF/versions/") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "opening %s", f.Name)
+		}
+		var header struct {
+			Magic        uint32
+			Minor, Major uint16
+		}
+		err = binary.Read(r, binary.BigEndian, &header)
+		r.Close()
+		if err != nil || header.Magic != 0xCAFEBABE {
+			// Resources named like class files are not evidence of the build.
+			continue
+		}
+		if header.Major > major {
+			major, newest = header.Major, f.Name
+		}
+	}
+	if major == 0 {
+		return nil, nil
+	}
+	return &JDKEvidence{
+		Source:  BytecodeEvidence,
+		Detail:  fmt.Sprintf("%s has major version %d", newest, major),
+		Version: max(int(major)-classFileMajorOffset, 1),
+	}, nil
+}
+
+// manifestJDK returns the JDK recorded in a jar manifest, if any.
+func manifestJDK(manifest []byte) *JDKEvidence {
+	// NOTE: Build-Jdk-Spec is recorded by newer versions of maven-archiver
+	// in place of Build-Jdk.
+	for _, key := range []string{"Build-Jdk", "Build-Jdk-Spec"} {
+		for _, line := range strings.Split(string(manifest), "\n") {
+			k, value, found := strings.Cut(strings.TrimSpace(line), ":")
+			if !found || k != key {
+				continue
+			}
+			if v, ok := jdkFeature(value); ok {
+				return &JDKEvidence{Source: ManifestEvidence, Detail: fmt.Sprintf("%s: %s", key, strings.TrimSpace(value)), Version: v}
+			}
+		}
+	}
+	return nil
+}
+
+// jarJDK returns the evidence of the JDK used to build a jar.
+func jarJDK(b []byte) ([]JDKEvidence, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, errors.Wrap(err, "unzipping jar file")
+	}
+	var evidence []JDKEvidence
+	if f, err := zr.Open("META-INF/MANIFEST.MF"); err == nil {
+		manifest, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "reading manifest file")
+		}
+		if e := manifestJDK(manifest); e != nil {
+			evidence = append(evidence, *e)
+		}
+	}
+	e, err := bytecodeJDK(zr)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil {
+		evidence = append(evidence, *e)
+	}
+	return evidence, nil
+}
+
+// compilerPOM is the subset of a POM configuring the Java compiler.
+type compilerPOM struct {
+	Properties struct {
+		Entries []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:",any"`
+	} `xml:"properties"`
+	Plugins []struct {
+		ArtifactID    string `xml:"artifactId"`
+		Configuration struct {
+			Release string `xml:"release"`
+			Target  string `xml:"target"`
+		} `xml:"configuration"`
+	} `xml:"build>plugins>plugin"`
+}
+
+var propertyRef = regexp.MustCompile(`^\$\{([^}]+)\}$`)
+
+// compilerJDK returns the bytecode target configured for maven-compiler-plugin in the POM, if any.
+func compilerJDK(pom []byte) (*JDKEvidence, error) {
+	var p compilerPOM
+	if err := xml.Unmarshal(pom, &p); err != nil {
+		return nil, errors.Wrap(err, "parsing pom.xml")
+	}
+	props := make(map[string]string)
+	for _, e := range p.Properties.Entries {
+		props[e.XMLName.Local] = strings.TrimSpace(e.Value)
+	}
+	resolve := func(v string) string {
+		v = strings.TrimSpace(v)
+		if m := propertyRef.FindStringSubmatch(v); m != nil {
+			return props[m[1]]
+		}
+		return v
+	}
+	var candidates [][2]string
+	for _, plugin := range p.Plugins {
+		if plugin.ArtifactID == "maven-compiler-plugin" {
+			candidates = append(candidates,
+				[2]string{"<release>", resolve(plugin.Configuration.Release)},
+				[2]string{"<target>", resolve(plugin.Configuration.Target)})
+		}
+	}
+	for _, prop := range []string{"maven.compiler.release", "maven.compiler.target"} {
+		candidates = append(candidates, [2]string{prop, props[prop]})
+	}
+	for _, c := range candidates {
+		if v, ok := jdkFeature(c[1]); ok {
+			return &JDKEvidence{Source: CompilerEvidence, Detail: fmt.Sprintf("%s %s", c[0], c[1]), Version: v}, nil
+		}
+	}
+	return nil, nil
+}
+
+// selectJDK chooses the builder JDK from the evidence.
+//
+// Only those JDKs able to target the newest bytecode version observed or
+// configured are considered. Of these, the JDK recorded in the manifest is
+// preferred since it produced the upstream artifact, then the nearest newer
+// one. Without a recorded JDK, the oldest is used.
+func selectJDK(evidence []JDKEvidence) (int, error) {
+	var target, recorded int
+	for _, e := range evidence {
+		switch e.Source {
+		case ManifestEvidence:
+			recorded = e.Version
+		case BytecodeEvidence, CompilerEvidence:
+			target = max(target, e.Version)
+		}
+	}
+	if target == 0 && recorded == 0 {
+		return 0, errors.New("no JDK evidence found")
+	}
+	var candidates []int
+	for _, jdk := range JDKs {
+		if jdk >= target && minTarget(jdk) <= max(target, 1) {
+			candidates = append(candidates, jdk)
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, errors.Errorf("no available JDK can target %d", target)
+	}
+	for _, jdk := range candidates {
+		if jdk >= recorded {
+			return jdk, nil
+		}
+	}
+	return candidates[len(candidates)-1], nil
+}
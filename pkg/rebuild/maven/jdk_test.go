@@ -0,0 +1,124 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maven
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func classFile(major byte) string {
+	return string([]byte{0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, major})
+}
+
+func TestJarJDK(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, body string }{
+		{"META-INF/MANIFEST.MF", "Manifest-Version: 1.0\r\nCreated-By: Apache Maven 3.8.1\r\nBuild-Jdk: 1.8.0_292\r\n"},
+		{"com/example/A.class", classFile(50)},
+		{"com/example/B.class", classFile(52)},
+		{"module-info.class", classFile(53)},
+		{"META-INF/versions/11/com/example/A.class", classFile(55)},
+		{"com/example/resource.class", "not a class"},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := jarJDK(buf.Bytes())
+	if err != nil {
+		t.Fatalf("jarJDK() failed: %v", err)
+	}
+	want := []JDKEvidence{
+		{Source: ManifestEvidence, Detail: "Build-Jdk: 1.8.0_292", Version: 8},
+		{Source: BytecodeEvidence, Detail: "com/example/B.class has major version 52", Version: 8},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("jarJDK() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompilerJDK(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pom  string
+		want *JDKEvidence
+	}{
+		{
+			name: "PluginRelease",
+			pom: `<project><properties><java.version>17</java.version></properties>
+<build><plugins><plugin><artifactId>maven-compiler-plugin</artifactId><configuration><release>${java.version}</release></configuration></plugin></plugins></build></project>`,
+			want: &JDKEvidence{Source: CompilerEvidence, Detail: "<release> 17", Version: 17},
+		},
+		{
+			name: "Property",
+			pom:  `<project><properties><maven.compiler.source>1.7</maven.compiler.source><maven.compiler.target>1.7</maven.compiler.target></properties></project>`,
+			want: &JDKEvidence{Source: CompilerEvidence, Detail: "maven.compiler.target 1.7", Version: 7},
+		},
+		{
+			name: "Unconfigured",
+			pom:  `<project><build><plugins><plugin><artifactId>maven-jar-plugin</artifactId></plugin></plugins></build></project>`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := compilerJDK([]byte(tc.pom))
+			if err != nil {
+				t.Fatalf("compilerJDK() failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("compilerJDK() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelectJDK(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		evidence []JDKEvidence
+		want     int
+		wantErr  bool
+	}{
+		{"RecordedAvailable", []JDKEvidence{{Source: ManifestEvidence, Version: 11}, {Source: BytecodeEvidence, Version: 8}}, 11, false},
+		{"RecordedUnavailable", []JDKEvidence{{Source: ManifestEvidence, Version: 14}, {Source: BytecodeEvidence, Version: 8}}, 17, false},
+		{"RecordedCannotTarget", []JDKEvidence{{Source: ManifestEvidence, Version: 14}, {Source: BytecodeEvidence, Version: 6}}, 11, false},
+		{"RecordedTooOld", []JDKEvidence{{Source: ManifestEvidence, Version: 8}, {Source: CompilerEvidence, Version: 11}}, 11, false},
+		{"TargetOnly", []JDKEvidence{{Source: BytecodeEvidence, Version: 9}}, 11, false},
+		{"OldTarget", []JDKEvidence{{Source: BytecodeEvidence, Version: 5}}, 8, false},
+		{"TooNew", []JDKEvidence{{Source: BytecodeEvidence, Version: 25}}, 0, true},
+		{"NoEvidence", nil, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := selectJDK(tc.evidence)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("selectJDK() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("selectJDK() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
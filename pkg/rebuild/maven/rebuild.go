@@ -35,12 +35,17 @@ import (
 	"github.com/pkg/errors"
 )
 
-func rebuildOne(ctx context.Context, input rebuild.Input, rcfg *RepoConfig, fs billy.Filesystem, s storage.Storer) (verdict error, err error) {
+func rebuildOne(ctx context.Context, input *rebuild.Input, rcfg *RepoConfig, fs billy.Filesystem, s storage.Storer) (verdict error, err error) {
 	t := input.Target
 	pom, err := mvnreg.VersionPomXML(t.Package, t.Version)
 	if err != nil {
 		return
 	}
+	if t.Artifact == "" {
+		input.Target.Artifact = DefaultArtifact(t, pom)
+	} else if _, err := ArtifactType(t); err != nil {
+		return err, nil
+	}
 	uri, err := uri.CanonicalizeRepoURI(pom.Repo())
 	if err != nil {
 		return
@@ -94,7 +99,7 @@ func RebuildMany(ctx context.Context, name string, inputs []rebuild.Input) ([]re
 	var failures []error
 	// TODO: Setup logging for each version.
 	// Protect against panics in rebuildOne.
-	safeRebuildOne := func(input rebuild.Input) {
+	safeRebuildOne := func(input *rebuild.Input) {
 		defer func() {
 			if panicval := recover(); panicval != nil {
 				log.Printf("Rebuild panic: %v\n", panicval)
@@ -109,18 +114,20 @@ func RebuildMany(ctx context.Context, name string, inputs []rebuild.Input) ([]re
 			failures = append(failures, failure)
 		}
 	}
+	for i := range inputs {
+		log.Printf("Running a maven rebuildOne: %s %s", inputs[i].Target.Package, inputs[i].Target.Version)
+		safeRebuildOne(&inputs[i])
+	}
 	verdicts := make([]rebuild.Verdict, 0, len(failures))
 	for i := range inputs {
-		// TODO find the correct artifact name.
-		inputs[i].Target.Artifact = "default-artifact"
+		if inputs[i].Target.Artifact == "" {
+			// NOTE: The POM could not be read to determine the main artifact.
+			inputs[i].Target.Artifact = mvnreg.FileName(artifactID(inputs[i].Target.Package), inputs[i].Target.Version, mvnreg.TypeJar)
+		}
 		verdicts = append(verdicts, rebuild.Verdict{
 			Target:  inputs[i].Target,
 			Message: failures[i].Error(),
 		})
 	}
-	for _, input := range inputs {
-		log.Printf("Running a maven rebuildOne: %s %s", input.Target.Package, input.Target.Version)
-		safeRebuildOne(input)
-	}
 	return verdicts, nil
 }
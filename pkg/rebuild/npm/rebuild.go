@@ -88,10 +88,10 @@ func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Ins
 	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Source); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Source")
 	}
-	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Deps); err != nil {
+	if _, err := rebuild.ExecuteScriptWithLimits(ctx, fs.Root(), inst.Deps); err != nil {
 		return errors.Wrap(err, "failed to execute strategy.Deps")
 	}
-	if output, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Build); err != nil {
+	if output, err := rebuild.ExecuteScriptWithLimits(ctx, fs.Root(), inst.Build); err != nil {
 		// Build failed. Let's try to figure out why.
 		switch {
 		case strings.Contains(output, "primordials is not defined"):
@@ -121,6 +121,7 @@ var (
 	verdictRebuildOnly        = errors.New("file(s) found in rebuild but not upstream")
 	verdictPackageJSONDiff    = errors.New("package.json differences found")
 	verdictContentDiff        = errors.New("content differences found")
+	verdictLayout             = errors.New("file(s) moved between upstream and rebuild")
 )
 
 func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.Asset, assets rebuild.AssetStore, _ rebuild.Instructions) (msg error, err error) {
@@ -128,7 +129,7 @@ func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.A
 	if err != nil {
 		return nil, errors.Wrapf(err, "summarizing assets")
 	}
-	upOnly, diffs, rbOnly := csUP.Diff(csRB)
+	upOnly, diffs, rbOnly, moves := csUP.DiffWithMoves(csRB)
 	var foundDist, foundDSStore bool
 	allHidden := true
 	for _, f := range upOnly {
@@ -166,6 +167,8 @@ func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.A
 		return verdictPackageJSONDiff, nil
 	case len(diffs) > 0:
 		return verdictContentDiff, nil
+	case len(moves) > 0:
+		return verdictLayout, nil
 	default:
 		return nil, nil
 	}
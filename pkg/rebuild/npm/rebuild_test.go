@@ -99,7 +99,7 @@ func TestCompare(t *testing.T) {
 			},
 			upstream: []*archive.TarEntry{
 				{Header: &tar.Header{Name: "package/package.json", Typeflag: tar.TypeReg, Size: 2, Mode: 0644}, Body: []byte("#b")},
-				{Header: &tar.Header{Name: "package/file2", Typeflag: tar.TypeReg, Size: 5, Mode: 0644}, Body: []byte("stuff")},
+				{Header: &tar.Header{Name: "package/file2", Typeflag: tar.TypeReg, Size: 5, Mode: 0644}, Body: []byte("other")},
 			},
 			inst:     rebuild.Instructions{},
 			expected: verdictMismatchedFiles,
@@ -15,6 +15,7 @@
 package npm
 
 import (
+	"fmt"
 	"path"
 	"time"
 
@@ -37,23 +38,18 @@ func (b *NPMPackBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebui
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
-	deps := ""
+	var build rebuild.Script
 	// NOTE: Use builtin npm for 'npm version' as it wasn't introduced until NPM v6.
-	build, err := rebuild.PopulateTemplate(`
-{{if ne .VersionOverride "" -}}
-PATH=/usr/bin:/bin:/usr/local/bin /usr/bin/npm version --prefix {{.Location.Dir}} --no-git-tag-version {{.VersionOverride}}
-{{end -}}
-/usr/bin/npx --package=npm@{{.NPMVersion}} -- "cd {{.Location.Dir}} && npm pack"
-`, b)
-	if err != nil {
-		return rebuild.Instructions{}, err
+	if b.VersionOverride != "" {
+		build.Cmd("PATH=/usr/bin:/bin:/usr/local/bin", "/usr/bin/npm", "version", "--prefix", b.Location.Dir, "--no-git-tag-version", b.VersionOverride)
 	}
+	build.Cmd("/usr/bin/npx", "--package=npm@"+b.NPMVersion, "--", rebuild.Command("cd", b.Location.Dir)+" && npm pack")
 	return rebuild.Instructions{
 		Location:   b.Location,
 		SystemDeps: []string{"git", "npm"},
 		Source:     src,
-		Deps:       deps,
-		Build:      build,
+		Deps:       "",
+		Build:      build.String(),
 		OutputPath: path.Join(b.Location.Dir, t.Artifact),
 	}, nil
 }
@@ -76,38 +72,38 @@ func (b *NPMCustomBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (reb
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
-	buildAndEnv := struct {
-		*NPMCustomBuild
-		BuildEnv *rebuild.BuildEnv
-	}{
-		NPMCustomBuild: b,
-		BuildEnv:       &be,
-	}
-	deps, err := rebuild.PopulateTemplate(`
-/usr/bin/npm config --location-global set registry {{.BuildEnv.TimewarpURL "npm" .RegistryTime}}
-trap '/usr/bin/npm config --location-global delete registry' EXIT
-wget -O - https://unofficial-builds.nodejs.org/download/release/v{{.NodeVersion}}/node-v{{.NodeVersion}}-linux-x64-musl.tar.gz | tar xzf - --strip-components=1 -C /usr/local/
-/usr/local/bin/npx --package=npm@{{.NPMVersion}} -- "cd {{.Location.Dir}} && npm install --force"
-`, buildAndEnv)
+	registry, err := be.TimewarpURL("npm", b.RegistryTime)
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
+	var deps rebuild.Script
+	deps.Cmd("/usr/bin/npm", "config", "--location-global", "set", "registry", registry)
+	deps.Cmd("trap", rebuild.Command("/usr/bin/npm", "config", "--location-global", "delete", "registry"), "EXIT")
+	deps.Pipe(
+		[]string{"wget", "-O", "-", fmt.Sprintf("https://unofficial-builds.nodejs.org/download/release/v%[1]s/node-v%[1]s-linux-x64-musl.tar.gz", b.NodeVersion)},
+		[]string{"tar", "xzf", "-", "--strip-components=1", "-C", "/usr/local/"},
+	)
+	install := []string{"npm", "install", "--force"}
+	if be.DisableInstallHooks {
+		install = append(install, "--ignore-scripts")
+	}
+	deps.Cmd("/usr/local/bin/npx", "--package=npm@"+b.NPMVersion, "--", rebuild.Command("cd", b.Location.Dir)+" && "+rebuild.Command(install...))
+	var build rebuild.Script
 	// NOTE: Use builtin npm for 'npm version' as it wasn't introduced until NPM v6.
-	build, err := rebuild.PopulateTemplate(`
-{{if ne .VersionOverride "" -}}
-PATH=/usr/bin:/bin:/usr/local/bin /usr/bin/npm version --prefix {{.Location.Dir}} --no-git-tag-version {{.VersionOverride}}
-{{end -}}
-/usr/local/bin/npx --package=npm@{{.NPMVersion}} -- "cd {{.Location.Dir}} && npm run {{.Command}}" && rm -rf node_modules && npm pack
-`, b)
-	if err != nil {
-		return rebuild.Instructions{}, err
+	if b.VersionOverride != "" {
+		build.Cmd("PATH=/usr/bin:/bin:/usr/local/bin", "/usr/bin/npm", "version", "--prefix", b.Location.Dir, "--no-git-tag-version", b.VersionOverride)
 	}
+	build.And(
+		[]string{"/usr/local/bin/npx", "--package=npm@" + b.NPMVersion, "--", rebuild.Command("cd", b.Location.Dir) + " && " + rebuild.Command("npm", "run", b.Command)},
+		[]string{"rm", "-rf", "node_modules"},
+		[]string{"npm", "pack"},
+	)
 	return rebuild.Instructions{
 		Location:   b.Location,
 		SystemDeps: []string{"git", "npm"},
 		Source:     src,
-		Deps:       deps,
-		Build:      build,
+		Deps:       deps.String(),
+		Build:      build.String(),
 		OutputPath: path.Join(b.Location.Dir, t.Artifact),
 	}, nil
 }
@@ -43,10 +43,10 @@ func TestNPMCustomBuild(t *testing.T) {
 			rebuild.Instructions{
 				Location:   defaultLocation,
 				SystemDeps: []string{"git", "npm"},
-				Source:     "git checkout --force 'the_ref'",
+				Source:     "git checkout --force the_ref",
 				Deps:       "",
 				Build: `PATH=/usr/bin:/bin:/usr/local/bin /usr/bin/npm version --prefix the_dir --no-git-tag-version green
-/usr/bin/npx --package=npm@red -- "cd the_dir && npm pack"`,
+/usr/bin/npx --package=npm@red -- 'cd the_dir && npm pack'`,
 				OutputPath: "the_dir/the_artifact",
 			},
 		},
@@ -60,9 +60,9 @@ func TestNPMCustomBuild(t *testing.T) {
 			rebuild.Instructions{
 				Location:   defaultLocation,
 				SystemDeps: []string{"git", "npm"},
-				Source:     "git checkout --force 'the_ref'",
+				Source:     "git checkout --force the_ref",
 				Deps:       "",
-				Build:      `/usr/bin/npx --package=npm@red -- "cd the_dir && npm pack"`,
+				Build:      `/usr/bin/npx --package=npm@red -- 'cd the_dir && npm pack'`,
 				OutputPath: "the_dir/the_artifact",
 			},
 		},
@@ -79,13 +79,13 @@ func TestNPMCustomBuild(t *testing.T) {
 			rebuild.Instructions{
 				Location:   defaultLocation,
 				SystemDeps: []string{"git", "npm"},
-				Source:     "git checkout --force 'the_ref'",
+				Source:     "git checkout --force the_ref",
 				Deps: `/usr/bin/npm config --location-global set registry http://npm:2006-01-02T03:04:05Z@orange
 trap '/usr/bin/npm config --location-global delete registry' EXIT
 wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue-linux-x64-musl.tar.gz | tar xzf - --strip-components=1 -C /usr/local/
-/usr/local/bin/npx --package=npm@red -- "cd the_dir && npm install --force"`,
+/usr/local/bin/npx --package=npm@red -- 'cd the_dir && npm install --force'`,
 				Build: `PATH=/usr/bin:/bin:/usr/local/bin /usr/bin/npm version --prefix the_dir --no-git-tag-version green
-/usr/local/bin/npx --package=npm@red -- "cd the_dir && npm run yellow" && rm -rf node_modules && npm pack`,
+/usr/local/bin/npx --package=npm@red -- 'cd the_dir && npm run yellow' && rm -rf node_modules && npm pack`,
 				OutputPath: "the_dir/the_artifact",
 			},
 		},
@@ -102,12 +102,12 @@ wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue
 			rebuild.Instructions{
 				Location:   defaultLocation,
 				SystemDeps: []string{"git", "npm"},
-				Source:     "git checkout --force 'the_ref'",
+				Source:     "git checkout --force the_ref",
 				Deps: `/usr/bin/npm config --location-global set registry http://npm:2006-01-02T03:04:05Z@orange
 trap '/usr/bin/npm config --location-global delete registry' EXIT
 wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue-linux-x64-musl.tar.gz | tar xzf - --strip-components=1 -C /usr/local/
-/usr/local/bin/npx --package=npm@red -- "cd the_dir && npm install --force"`,
-				Build:      `/usr/local/bin/npx --package=npm@red -- "cd the_dir && npm run yellow" && rm -rf node_modules && npm pack`,
+/usr/local/bin/npx --package=npm@red -- 'cd the_dir && npm install --force'`,
+				Build:      `/usr/local/bin/npx --package=npm@red -- 'cd the_dir && npm run yellow' && rm -rf node_modules && npm pack`,
 				OutputPath: "the_dir/the_artifact",
 			},
 		},
@@ -124,3 +124,24 @@ wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue
 		})
 	}
 }
+
+func TestNPMCustomBuildDisableInstallHooks(t *testing.T) {
+	strategy := &NPMCustomBuild{
+		Location:     rebuild.Location{Dir: "the_dir", Ref: "the_ref", Repo: "the_repo"},
+		NPMVersion:   "red",
+		NodeVersion:  "blue",
+		Command:      "yellow",
+		RegistryTime: time.Date(2006, time.January, 2, 3, 4, 5, 0, time.UTC),
+	}
+	inst, err := strategy.GenerateFor(rebuild.Target{Ecosystem: rebuild.NPM, Package: "the_package", Version: "the_version", Artifact: "the_artifact"}, rebuild.BuildEnv{TimewarpHost: "orange", HasRepo: true, DisableInstallHooks: true})
+	if err != nil {
+		t.Fatalf("GenerateFor() failed unexpectedly: %v", err)
+	}
+	want := `/usr/bin/npm config --location-global set registry http://npm:2006-01-02T03:04:05Z@orange
+trap '/usr/bin/npm config --location-global delete registry' EXIT
+wget -O - https://unofficial-builds.nodejs.org/download/release/vblue/node-vblue-linux-x64-musl.tar.gz | tar xzf - --strip-components=1 -C /usr/local/
+/usr/local/bin/npx --package=npm@red -- 'cd the_dir && npm install --force --ignore-scripts'`
+	if diff := cmp.Diff(inst.Deps, want); diff != "" {
+		t.Errorf("GenerateFor() returned Deps diff (-got +want):\n%s", diff)
+	}
+}
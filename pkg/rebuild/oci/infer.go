@@ -0,0 +1,186 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"path"
+	"slices"
+	"strings"
+
+	billy "github.com/go-git/go-billy/v5"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/google/oss-rebuild/internal/uri"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	reg "github.com/google/oss-rebuild/pkg/registry/oci"
+	"github.com/pkg/errors"
+)
+
+func (Rebuilder) InferRepo(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux) (string, error) {
+	m, err := mux.OCI.Manifest(ctx, t.Package, t.Version)
+	if err != nil {
+		return "", err
+	}
+	c, err := mux.OCI.Config(ctx, t.Package, m)
+	if err != nil {
+		return "", err
+	}
+	src := c.Labels()[reg.SourceLabel]
+	if src == "" {
+		return "", errors.Errorf("image has no %s label", reg.SourceLabel)
+	}
+	return uri.CanonicalizeRepoURI(src)
+}
+
+func (Rebuilder) CloneRepo(ctx context.Context, t rebuild.Target, repoURI string, fs billy.Filesystem, s storage.Storer) (r rebuild.RepoConfig, err error) {
+	r.URI = repoURI
+	r.Repository, err = rebuild.LoadRepo(ctx, t.Package, s, fs, git.CloneOptions{URL: r.URI, RecurseSubmodules: git.DefaultSubmoduleRecursionDepth})
+	switch err {
+	case nil:
+	case transport.ErrAuthenticationRequired:
+		err = errors.Errorf("Repo invalid or private")
+		return
+	default:
+		err = errors.Wrapf(err, "Clone failed [repo=%s]", r.URI)
+		return
+	}
+	r.Dir = "."
+	r.RefMap = make(map[string]string)
+	return
+}
+
+// inferRef determines the commit from which the image was built.
+func inferRef(t rebuild.Target, c *reg.ImageConfig, rcfg *rebuild.RepoConfig) (string, error) {
+	if rev := c.Labels()[reg.RevisionLabel]; rev != "" {
+		if _, err := rcfg.Repository.CommitObject(plumbing.NewHash(rev)); err == nil {
+			log.Printf("using revision label ref: %s", rev[:min(9, len(rev))])
+			return rev, nil
+		} else if err != plumbing.ErrObjectNotFound {
+			return "", errors.Wrapf(err, "[INTERNAL] Failed ref resolve from revision label [repo=%s,ref=%s]", rcfg.URI, rev)
+		}
+		log.Printf("revision label ref not found in repo")
+	}
+	tagGuess, err := rebuild.FindTagMatch(path.Base(t.Package), t.Version, rcfg.Repository)
+	if err != nil {
+		return "", errors.Wrapf(err, "[INTERNAL] tag heuristic error")
+	}
+	if tagGuess == "" {
+		return "", errors.Errorf("no git ref")
+	}
+	log.Printf("using tag heuristic ref: %s", tagGuess[:9])
+	return tagGuess, nil
+}
+
+// unpinnedBaseImages returns the base images referenced by the Dockerfile without a digest.
+func unpinnedBaseImages(dockerfile string) []string {
+	var images []string
+	stages := make(map[string]bool)
+	s := bufio.NewScanner(strings.NewReader(dockerfile))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		args := fields[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+		image := args[0]
+		// NOTE: References to earlier stages, scratch, and build args cannot be pinned.
+		if !stages[strings.ToLower(image)] && image != "scratch" && !strings.ContainsAny(image, "$@") && !slices.Contains(images, image) {
+			images = append(images, image)
+		}
+		if len(args) == 3 && strings.EqualFold(args[1], "AS") {
+			stages[strings.ToLower(args[2])] = true
+		}
+	}
+	return images
+}
+
+func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, rcfg *rebuild.RepoConfig, hint rebuild.Strategy) (rebuild.Strategy, error) {
+	m, err := mux.OCI.Manifest(ctx, t.Package, t.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to fetch image manifest")
+	}
+	c, err := mux.OCI.Config(ctx, t.Package, m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to fetch image config")
+	}
+	lh, ok := hint.(*rebuild.LocationHint)
+	if hint != nil && !ok {
+		return nil, errors.Errorf("unsupported hint type: %T", hint)
+	}
+	var ref, dir string
+	if lh != nil && lh.Ref != "" {
+		ref = lh.Ref
+		dir = lh.Dir
+	} else {
+		ref, err = inferRef(t, c, rcfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dir == "" {
+		dir = rcfg.Dir
+	}
+	commit, err := rcfg.Repository.CommitObject(plumbing.NewHash(ref))
+	if err != nil {
+		return nil, err
+	}
+	tree, _ := commit.Tree()
+	f, err := tree.File(path.Join(dir, "Dockerfile"))
+	if err == object.ErrFileNotFound {
+		return nil, errors.Errorf("Dockerfile not found [dir=%s]", dir)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to read Dockerfile")
+	}
+	contents, err := f.Contents()
+	if err != nil {
+		return nil, errors.Wrapf(err, "[INTERNAL] Failed to read Dockerfile")
+	}
+	var bases map[string]string
+	name, digest := m.BaseImage()
+	for _, image := range unpinnedBaseImages(contents) {
+		// NOTE: The base image annotation only records the final stage's base.
+		if digest != "" && (image == name || strings.HasSuffix(name, "/"+image)) {
+			bases = map[string]string{image: digest}
+		} else {
+			log.Printf("base image digest unknown, using latest [image=%s]", image)
+		}
+	}
+	platform := DefaultPlatform
+	if c.OS != "" && c.Architecture != "" {
+		platform = c.OS + "/" + c.Architecture
+	}
+	return &DockerfileBuild{
+		Location: rebuild.Location{
+			Repo: rcfg.URI,
+			Ref:  ref,
+			Dir:  dir,
+		},
+		Dockerfile: "Dockerfile",
+		BaseImages: bases,
+		Platform:   platform,
+	}, nil
+}
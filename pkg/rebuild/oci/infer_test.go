@@ -0,0 +1,39 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnpinnedBaseImages(t *testing.T) {
+	dockerfile := `
+ARG GO_VERSION=1.22
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+RUN go build ./...
+FROM golang:1.22 as test
+FROM build AS lint
+from alpine:3.19
+COPY --from=build /out /bin
+FROM gcr.io/distroless/static@sha256:abc
+FROM scratch
+`
+	want := []string{"golang:1.22", "alpine:3.19"}
+	if diff := cmp.Diff(want, unpinnedBaseImages(dockerfile)); diff != "" {
+		t.Errorf("unpinnedBaseImages() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oci provides the rebuild of OCI container images from their Dockerfiles.
+package oci
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	billy "github.com/go-git/go-billy/v5"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+func artifactName(t rebuild.Target) string {
+	// NOTE: Digests contain a colon which is not portable in file names.
+	return fmt.Sprintf("%s-%s.oci.tar", path.Base(t.Package), strings.ReplaceAll(t.Version, ":", "-"))
+}
+
+type Rebuilder struct{}
+
+var _ rebuild.Rebuilder = Rebuilder{}
+
+func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Instructions, fs billy.Filesystem) error {
+	if _, err := rebuild.ExecuteScript(ctx, fs.Root(), inst.Source); err != nil {
+		return errors.Wrap(err, "failed to execute strategy.Source")
+	}
+	if _, err := rebuild.ExecuteScriptWithLimits(ctx, fs.Root(), inst.Deps); err != nil {
+		return errors.Wrap(err, "failed to execute strategy.Deps")
+	}
+	if _, err := rebuild.ExecuteScriptWithLimits(ctx, fs.Root(), inst.Build); err != nil {
+		return errors.Wrap(err, "failed to execute strategy.Build")
+	}
+	return nil
+}
+
+var (
+	verdictLayerCount      = errors.New("layer count differs")
+	verdictConfig          = errors.New("image config differs")
+	verdictMismatchedFiles = errors.New("mismatched file(s) in upstream and rebuild")
+	verdictUpstreamOnly    = errors.New("file(s) found in upstream but not rebuild")
+	verdictRebuildOnly     = errors.New("file(s) found in rebuild but not upstream")
+	verdictContentDiff     = errors.New("content differences found")
+)
+
+// layerCount returns the number of layers, up to the last containing files, in a summary of a canonicalized image.
+func layerCount(cs *archive.ContentSummary) int {
+	var n int
+	for _, f := range cs.Files {
+		var i int
+		if _, err := fmt.Sscanf(f, "layers/%d/", &i); err == nil && i >= n {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+func (Rebuilder) Compare(ctx context.Context, t rebuild.Target, rb, up rebuild.Asset, assets rebuild.AssetStore, inst rebuild.Instructions) (msg error, err error) {
+	csRB, csUP, err := rebuild.Summarize(ctx, t, rb, up, assets)
+	if err != nil {
+		return nil, errors.Wrapf(err, "summarizing assets")
+	}
+	upOnly, diffs, rbOnly := csUP.Diff(csRB)
+	var configDiff bool
+	for _, f := range diffs {
+		if f == archive.OCIConfigPath {
+			configDiff = true
+		}
+	}
+	switch {
+	case layerCount(csUP) != layerCount(csRB):
+		return errors.Wrapf(verdictLayerCount, "upstream has %d, rebuild has %d", layerCount(csUP), layerCount(csRB)), nil
+	case len(upOnly) > 0 && len(rbOnly) > 0:
+		return verdictMismatchedFiles, nil
+	case len(upOnly) > 0:
+		return verdictUpstreamOnly, nil
+	case len(rbOnly) > 0:
+		return verdictRebuildOnly, nil
+	case configDiff && len(diffs) == 1:
+		return verdictConfig, nil
+	case len(diffs) > 0:
+		return verdictContentDiff, nil
+	default:
+		return nil, nil
+	}
+}
+
+// RebuildMany executes rebuilds for each provided rebuild.Input returning their rebuild.Verdicts.
+func RebuildMany(ctx context.Context, inputs []rebuild.Input, mux rebuild.RegistryMux) ([]rebuild.Verdict, error) {
+	for i := range inputs {
+		inputs[i].Target.Artifact = artifactName(inputs[i].Target)
+	}
+	return rebuild.RebuildMany(ctx, Rebuilder{}, inputs, mux)
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"sort"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+// DefaultPlatform is the platform for which images are built when unspecified.
+const DefaultPlatform = "linux/amd64"
+
+// DockerfileBuild aggregates the options controlling a Dockerfile build of an OCI image.
+type DockerfileBuild struct {
+	rebuild.Location
+	// Dockerfile is the path of the Dockerfile relative to Location.Dir.
+	Dockerfile string `json:"dockerfile" yaml:"dockerfile,omitempty"`
+	// BaseImages maps each base image referenced by the Dockerfile to the digest used by the upstream build.
+	BaseImages map[string]string `json:"base_images" yaml:"base_images,omitempty"`
+	// Platform is the platform for which the image is built.
+	Platform string `json:"platform" yaml:"platform,omitempty"`
+}
+
+var _ rebuild.Strategy = &DockerfileBuild{}
+
+// GenerateFor generates the instructions for a DockerfileBuild.
+func (b *DockerfileBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (rebuild.Instructions, error) {
+	src, err := rebuild.BasicSourceSetup(b.Location, &be)
+	if err != nil {
+		return rebuild.Instructions{}, err
+	}
+	platform := b.Platform
+	if platform == "" {
+		platform = DefaultPlatform
+	}
+	dockerfile := b.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	// NOTE: Base images are pulled by digest and tagged with the name used in
+	// the Dockerfile so that the build uses the same base as the upstream build.
+	// Since buildah only pulls missing images by default, these tags take
+	// precedence over the registry while unpinned bases are pulled as usual.
+	var deps rebuild.Script
+	names := make([]string, 0, len(b.BaseImages))
+	for name := range b.BaseImages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pinned := name + "@" + b.BaseImages[name]
+		deps.Cmd("buildah", "pull", "--platform", platform, pinned)
+		deps.Cmd("buildah", "tag", pinned, name)
+	}
+	var build rebuild.Script
+	build.Cmd("buildah", "build", "--format", "oci", "--timestamp", "0", "--platform", platform, "-f", b.Location.Dir+"/"+dockerfile, "-t", "oss-rebuild", b.Location.Dir)
+	build.Cmd("buildah", "push", "oss-rebuild", "oci-archive:"+t.Artifact)
+	return rebuild.Instructions{
+		Location:   b.Location,
+		Source:     src,
+		Deps:       deps.String(),
+		Build:      build.String(),
+		SystemDeps: []string{"git", "buildah"},
+		OutputPath: t.Artifact,
+		// NOTE: buildah creates the mounts and namespaces of its own build
+		// containers which the hardened sandbox denies.
+		Sandbox: rebuild.Sandbox{Unconfined: true},
+	}, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestDockerfileBuild(t *testing.T) {
+	defaultLocation := rebuild.Location{
+		Dir:  "the_dir",
+		Ref:  "the_ref",
+		Repo: "the_repo",
+	}
+	tests := []struct {
+		name     string
+		strategy rebuild.Strategy
+		want     rebuild.Instructions
+	}{
+		{
+			"Defaults",
+			&DockerfileBuild{
+				Location: defaultLocation,
+			},
+			rebuild.Instructions{
+				Location:   defaultLocation,
+				Source:     "git checkout --force the_ref",
+				Deps:       "",
+				Build:      "buildah build --format oci --timestamp 0 --platform linux/amd64 -f the_dir/Dockerfile -t oss-rebuild the_dir\nbuildah push oss-rebuild oci-archive:the_artifact",
+				SystemDeps: []string{"git", "buildah"},
+				OutputPath: "the_artifact",
+				Sandbox:    rebuild.Sandbox{Unconfined: true},
+			},
+		},
+		{
+			"PinnedBase",
+			&DockerfileBuild{
+				Location:   defaultLocation,
+				Dockerfile: "build/Containerfile",
+				BaseImages: map[string]string{"golang:1.22": "sha256:abc", "alpine:3.19": "sha256:def"},
+				Platform:   "linux/arm64",
+			},
+			rebuild.Instructions{
+				Location:   defaultLocation,
+				Source:     "git checkout --force the_ref",
+				Deps:       "buildah pull --platform linux/arm64 alpine:3.19@sha256:def\nbuildah tag alpine:3.19@sha256:def alpine:3.19\nbuildah pull --platform linux/arm64 golang:1.22@sha256:abc\nbuildah tag golang:1.22@sha256:abc golang:1.22",
+				Build:      "buildah build --format oci --timestamp 0 --platform linux/arm64 -f the_dir/build/Containerfile -t oss-rebuild the_dir\nbuildah push oss-rebuild oci-archive:the_artifact",
+				SystemDeps: []string{"git", "buildah"},
+				OutputPath: "the_artifact",
+				Sandbox:    rebuild.Sandbox{Unconfined: true},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			inst, err := tc.strategy.GenerateFor(rebuild.Target{Ecosystem: rebuild.OCI, Package: "ghcr.io/org/image", Version: "1.0.0", Artifact: "the_artifact"}, rebuild.BuildEnv{HasRepo: true})
+			if err != nil {
+				t.Fatalf("%s: Strategy%v.GenerateFor() failed unexpectedly: %v", tc.name, tc.strategy, err)
+			}
+			if diff := cmp.Diff(inst, tc.want); diff != "" {
+				t.Errorf("GenerateFor() returned diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pypi
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"path"
+	re "regexp"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+)
+
+// legacyBackend is the backend used to build projects which declare none.
+// See https://peps.python.org/pep-0517/#source-trees
+const legacyBackend = "setuptools.build_meta:__legacy__"
+
+// backendPackages maps the supported build-backend modules to the
+// distribution which provides them.
+var backendPackages = map[string]string{
+	"setuptools.build_meta":   "setuptools",
+	legacyBackend:             "setuptools",
+	"hatchling.build":         "hatchling",
+	"flit_core.buildapi":      "flit_core",
+	"poetry.core.masonry.api": "poetry-core",
+	"poetry.masonry.api":      "poetry",
+	"pdm.backend":             "pdm-backend",
+	"maturin":                 "maturin",
+}
+
+// pyProject is the subset of pyproject.toml describing how a project is built.
+type pyProject struct {
+	BuildSystem struct {
+		Requires []string `toml:"requires"`
+		Backend  string   `toml:"build-backend"`
+	} `toml:"build-system"`
+}
+
+func parsePyProject(b []byte) (*pyProject, error) {
+	var p pyProject
+	if err := toml.Unmarshal(b, &p); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode pyproject.toml")
+	}
+	return &p, nil
+}
+
+// backend returns the project's build-backend module.
+func (p *pyProject) backend() string {
+	if p.BuildSystem.Backend == "" {
+		return legacyBackend
+	}
+	return p.BuildSystem.Backend
+}
+
+// exactVersion returns the version to which the requirements pin pkg, if any.
+func exactVersion(reqs []string, pkg string) string {
+	for _, r := range reqs {
+		r = strings.ReplaceAll(r, " ", "")
+		if name, version, found := strings.Cut(r, "=="); found && normalizeName(name) == normalizeName(pkg) && !strings.ContainsAny(version, "*,;") {
+			return version
+		}
+	}
+	return ""
+}
+
+var nameSeparators = re.MustCompile(`[-_.]+`)
+
+// normalizeName normalizes a distribution name for comparison.
+// See https://packaging.python.org/en/latest/specifications/name-normalization/
+func normalizeName(name string) string {
+	return strings.ToLower(nameSeparators.ReplaceAllString(name, "-"))
+}
+
+var requirementName = re.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*`)
+
+// mergeRequirements returns reqs without those of the packages pinned,
+// followed by pinned. Installing the pins last ensures they are not
+// displaced by a looser requirement on the same package.
+func mergeRequirements(pinned, reqs []string) []string {
+	pins := make(map[string]bool)
+	for _, p := range pinned {
+		pins[normalizeName(requirementName.FindString(p))] = true
+	}
+	var out []string
+	for _, r := range reqs {
+		if !pins[normalizeName(requirementName.FindString(r))] {
+			out = append(out, r)
+		}
+	}
+	return append(out, pinned...)
+}
+
+// maxPyProjectBytes bounds the pyproject.toml read from an sdist.
+const maxPyProjectBytes = 1 << 20
+
+// sdistPyProject returns the contents of the top-level pyproject.toml in a gzipped sdist.
+func sdistPyProject(r io.Reader) ([]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to initialize sdist gzip reader")
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("no pyproject.toml in sdist")
+		} else if err != nil {
+			return nil, errors.Wrap(err, "Failed to read sdist")
+		}
+		// Sdists contain a single top-level directory named for the release.
+		dir, base := path.Split(h.Name)
+		if base == "pyproject.toml" && strings.Count(strings.Trim(dir, "/"), "/") == 0 && dir != "" {
+			return io.ReadAll(io.LimitReader(tr, maxPyProjectBytes))
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pypi
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSdistPyProject(t *testing.T) {
+	const pyproject = `[build-system]
+requires = ["hatchling==1.18.0", "hatch-vcs"]
+build-backend = "hatchling.build"
+`
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct{ name, body string }{
+		{"foo-1.0/tests/pyproject.toml", "[build-system]\n"},
+		{"foo-1.0/pyproject.toml", pyproject},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := sdistPyProject(&buf)
+	if err != nil {
+		t.Fatalf("sdistPyProject() failed: %v", err)
+	}
+	p, err := parsePyProject(b)
+	if err != nil {
+		t.Fatalf("parsePyProject() failed: %v", err)
+	}
+	if got := backendPackages[p.backend()]; got != "hatchling" {
+		t.Errorf("backend package = %q, want hatchling", got)
+	}
+	if got := exactVersion(p.BuildSystem.Requires, "hatchling"); got != "1.18.0" {
+		t.Errorf("exactVersion() = %q, want 1.18.0", got)
+	}
+	if got := (&pyProject{}).backend(); got != legacyBackend {
+		t.Errorf("backend() without declaration = %q, want %q", got, legacyBackend)
+	}
+}
+
+func TestExactVersion(t *testing.T) {
+	reqs := []string{"Flit_Core == 3.9.0", "poetry-core>=1.0", "pdm-backend==2.*", "maturin==1.4,<2"}
+	for _, tc := range []struct {
+		pkg, want string
+	}{
+		{"flit-core", "3.9.0"},
+		{"poetry-core", ""},
+		{"pdm-backend", ""},
+		{"maturin", ""},
+		{"hatchling", ""},
+	} {
+		if got := exactVersion(reqs, tc.pkg); got != tc.want {
+			t.Errorf("exactVersion(%s) = %q, want %q", tc.pkg, got, tc.want)
+		}
+	}
+}
+
+func TestMergeRequirements(t *testing.T) {
+	got := mergeRequirements(
+		[]string{"flit_core==3.9.0", "flit==3.9.0"},
+		[]string{"setuptools==67.7.2", "flit-core>=3.2,<4", "Flit", "tomli"},
+	)
+	want := []string{"setuptools==67.7.2", "tomli", "flit_core==3.9.0", "flit==3.9.0"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mergeRequirements() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -35,7 +35,7 @@ import (
 	"github.com/google/oss-rebuild/internal/uri"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
-	toml "github.com/pelletier/go-toml/v2"
+	pkgversion "github.com/google/oss-rebuild/pkg/version"
 	"github.com/pkg/errors"
 )
 
@@ -136,8 +136,7 @@ func (Rebuilder) CloneRepo(ctx context.Context, t rebuild.Target, repoURI string
 	return
 }
 
-func extractPyProjectRequirements(ctx context.Context, tree *object.Tree) ([]string, error) {
-	var reqs []string
+func readPyProject(tree *object.Tree) (*pyProject, error) {
 	log.Println("Looking for additional reqs in pyproject.toml")
 	// TODO: Maybe look for pyproject.toml in subdir?
 	f, err := tree.File("pyproject.toml")
@@ -148,37 +147,57 @@ func extractPyProjectRequirements(ctx context.Context, tree *object.Tree) ([]str
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to read pyproject.toml")
 	}
-	type BuildSystem struct {
-		Requirements []string `toml:"requires"`
-	}
-	type PyProject struct {
-		Build BuildSystem `toml:"build-system"`
-	}
-	var pyProject PyProject
-	if err := toml.Unmarshal([]byte(pyprojContents), &pyProject); err != nil {
-		return nil, errors.Wrap(err, "Failed to decode pyproject.toml")
-	}
-	for _, r := range pyProject.Build.Requirements {
-		// TODO: Some of these requirements are probably already in rbcfg.Requirements, should we skip
-		// them? To even know which package we're looking at would require parsing the dependency spec.
-		// https://packaging.python.org/en/latest/specifications/dependency-specifiers/#dependency-specifiers
-		reqs = append(reqs, strings.ReplaceAll(r, " ", ""))
+	return parsePyProject([]byte(pyprojContents))
+}
+
+// fetchSdistPyProject returns the pyproject.toml published in the release's sdist.
+func fetchSdistPyProject(ctx context.Context, mux rebuild.RegistryMux, release *pypireg.Release) (*pyProject, error) {
+	for _, a := range release.Artifacts {
+		if a.PackageType != "sdist" || !strings.HasSuffix(a.Filename, ".tar.gz") {
+			continue
+		}
+		r, err := mux.PyPI.Artifact(ctx, release.Name, release.Version, a.Filename)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to fetch sdist")
+		}
+		defer r.Close()
+		b, err := sdistPyProject(r)
+		if err != nil {
+			return nil, err
+		}
+		return parsePyProject(b)
 	}
-	log.Println("Added these reqs from pyproject.toml: " + strings.Join(reqs, ", "))
-	return reqs, nil
+	return nil, errors.New("no sdist found")
 }
 
 func findGitRef(pkg string, version string, rcfg *rebuild.RepoConfig) (string, error) {
-	tagHeuristic, err := rebuild.FindTagMatch(pkg, version, rcfg.Repository)
-	log.Printf("Version: %s, tag hash: \"%s\"", version, tagHeuristic)
-	if err != nil {
-		return "", errors.Wrapf(err, "[INTERNAL] tag heuristic error")
+	// NOTE: Tags often spell versions differently than PyPI (e.g. "v1.0-1" for "1.0.post1").
+	forms := []string{version}
+	if v, err := pkgversion.Parse(pkgversion.PEP440, version); err == nil {
+		forms = append(forms, v.TagForms()...)
+	}
+	var tagHeuristic string
+	tried := make(map[string]bool)
+	for _, form := range forms {
+		if tried[form] {
+			continue
+		}
+		tried[form] = true
+		var err error
+		tagHeuristic, err = rebuild.FindTagMatch(pkg, form, rcfg.Repository)
+		if err != nil {
+			return "", errors.Wrapf(err, "[INTERNAL] tag heuristic error")
+		}
+		if tagHeuristic != "" {
+			break
+		}
 	}
+	log.Printf("Version: %s, tag hash: \"%s\"", version, tagHeuristic)
 	// TODO: Look for the project.toml and check for version number.
 	if tagHeuristic == "" {
 		return "", errors.New("no git ref")
 	}
-	_, err = rcfg.Repository.CommitObject(plumbing.NewHash(tagHeuristic))
+	_, err := rcfg.Repository.CommitObject(plumbing.NewHash(tagHeuristic))
 	if err != nil {
 		switch err {
 		case plumbing.ErrObjectNotFound:
@@ -200,39 +219,45 @@ func FindPureWheel(artifacts []pypireg.Artifact) (*pypireg.Artifact, error) {
 	return nil, fs.ErrNotExist
 }
 
-func inferRequirements(name, version string, zr *zip.Reader) ([]string, error) {
+// inferRequirements returns the exact versions of the wheel's generator and,
+// for setuptools builds, an approximate setuptools version from the metadata.
+func inferRequirements(name, version string, zr *zip.Reader) (pinned []string, approx []string, err error) {
 	// Name and version have "-" replaced with "_". See https://packaging.python.org/en/latest/specifications/recording-installed-packages/#the-dist-info-directory
 	// TODO: Search for dist-info in the gzip using a regex. It sounds like many tools do varying amounts of normalization on the path name.
 	wheelPath := fmt.Sprintf("%s-%s.dist-info/WHEEL", strings.ReplaceAll(name, "-", "_"), strings.ReplaceAll(version, "-", "_"))
 	wheel, err := getFile(wheelPath, zr)
 	if err != nil {
-		return nil, errors.Wrapf(err, "[INTERNAL] Failed to extract upstream %s", wheelPath)
+		return nil, nil, errors.Wrapf(err, "[INTERNAL] Failed to extract upstream %s", wheelPath)
 	}
-	reqs, err := getGenerator(wheel)
+	pinned, err = getGenerator(wheel)
 	if err != nil {
-		return nil, errors.Wrapf(err, "[INTERNAL] Failed to get upstream generator")
+		return nil, nil, errors.Wrapf(err, "[INTERNAL] Failed to get upstream generator")
+	}
+	if !strings.HasPrefix(pinned[0], "wheel==") {
+		// Only setuptools builds are generated by bdist_wheel.
+		return pinned, nil, nil
 	}
 	// TODO: Also find this with a regex.
 	metadataPath := fmt.Sprintf("%s-%s.dist-info/METADATA", strings.ReplaceAll(name, "-", "_"), strings.ReplaceAll(version, "-", "_"))
 	metadata, err := getFile(metadataPath, zr)
 	if err != nil {
-		return nil, errors.Wrapf(err, "[INTERNAL] Failed to extract upstream dist-info/METADATA")
+		return nil, nil, errors.Wrapf(err, "[INTERNAL] Failed to extract upstream dist-info/METADATA")
 	}
 	switch {
 	case !bytes.Contains(metadata, []byte("License-File")):
 		// The License-File value was introduced in later versions so this is the
 		// most recent version it could be.
-		reqs = append(reqs, "setuptools==56.2.0")
+		approx = append(approx, "setuptools==56.2.0")
 	case bytes.Contains(metadata, []byte("Platform: UNKNOWN")):
 		// In later versions, unknown platform is omitted. If we see this pattern, it's an older version
 		// of setup tools.
 		// TODO: There's probably a more specific version where this behavior changed. I just chose the
 		// first version I found that worked.
-		reqs = append(reqs, "setuptools==57.5.0")
+		approx = append(approx, "setuptools==57.5.0")
 	default:
-		reqs = append(reqs, "setuptools==67.7.2")
+		approx = append(approx, "setuptools==67.7.2")
 	}
-	return reqs, nil
+	return pinned, approx, nil
 }
 
 func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuild.RegistryMux, rcfg *rebuild.RepoConfig, hint rebuild.Strategy) (rebuild.Strategy, error) {
@@ -279,11 +304,12 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 	if err != nil {
 		return nil, errors.Wrapf(err, "[INTERNAL] Failed to initialize upstream zip reader")
 	}
-	reqs, err := inferRequirements(release.Name, version, zr)
+	pinned, approx, err := inferRequirements(release.Name, version, zr)
 	if err != nil {
 		return cfg, err
 	}
 	// Extract pyproject.toml requirements.
+	var repoProj *pyProject
 	{
 		commit, err := rcfg.Repository.CommitObject(plumbing.NewHash(ref))
 		if err != nil {
@@ -293,12 +319,43 @@ func (Rebuilder) InferStrategy(ctx context.Context, t rebuild.Target, mux rebuil
 		if err != nil {
 			return cfg, errors.Wrapf(err, "Failed to get tree")
 		}
-		if pyprojReqs, err := extractPyProjectRequirements(ctx, tree); err != nil {
+		if repoProj, err = readPyProject(tree); err != nil {
 			log.Println(errors.Wrap(err, "Failed to extract reqs from pyproject.toml."))
-		} else {
-			reqs = append(reqs, pyprojReqs...)
 		}
 	}
+	// Identify the build backend, preferring the sdist's declaration since it
+	// is what was published while the repo's may differ at the inferred ref.
+	proj, err := fetchSdistPyProject(ctx, mux, release)
+	if err != nil {
+		log.Println(errors.Wrap(err, "Failed to read pyproject.toml from sdist"))
+		proj = repoProj
+	}
+	if proj == nil {
+		proj = &pyProject{}
+	}
+	// NOTE: Backend version drift is a leading cause of mismatches so the
+	// backend is pinned exactly when the wheel's generator does not record it.
+	backend, ok := backendPackages[proj.backend()]
+	if !ok {
+		log.Printf("Unsupported build backend %s, leaving its version unpinned", proj.backend())
+	} else if exactVersion(pinned, backend) == "" {
+		if v := exactVersion(proj.BuildSystem.Requires, backend); v != "" {
+			pinned = append(pinned, backend+"=="+v)
+			approx = nil
+		}
+	}
+	var reqs []string
+	reqs = append(reqs, approx...)
+	if repoProj != nil {
+		for _, r := range repoProj.BuildSystem.Requires {
+			// TODO: Some of these requirements are probably already in rbcfg.Requirements, should we skip
+			// them? To even know which package we're looking at would require parsing the dependency spec.
+			// https://packaging.python.org/en/latest/specifications/dependency-specifiers/#dependency-specifiers
+			reqs = append(reqs, strings.ReplaceAll(r, " ", ""))
+		}
+	}
+	reqs = mergeRequirements(pinned, reqs)
+	log.Printf("Build backend %s with requirements: %s", proj.backend(), strings.Join(reqs, ", "))
 	return &PureWheelBuild{
 		Location: rebuild.Location{
 			Repo: rcfg.URI,
@@ -316,6 +373,8 @@ var hatchlingPat = re.MustCompile(`^Generator: hatchling ([\d\.]+)`)
 // poetry-core is a subset of poetry. We can treat them as different builders.
 var poetryPat = re.MustCompile(`^Generator: poetry ([\d\.]+)`)
 var poetryCorePat = re.MustCompile(`^Generator: poetry-core ([\d\.]+)`)
+var pdmBackendPat = re.MustCompile(`^Generator: pdm-backend \(([\d\.]+)\)`)
+var maturinPat = re.MustCompile(`^Generator: maturin \(([\d\.]+)\)`)
 
 func getGenerator(wheel []byte) (reqs []string, err error) {
 	var eol int
@@ -339,6 +398,10 @@ func getGenerator(wheel []byte) (reqs []string, err error) {
 				return []string{"poetry==" + string(matches[1])}, nil
 			} else if matches := poetryCorePat.FindSubmatch(line); matches != nil {
 				return []string{"poetry-core==" + string(matches[1])}, nil
+			} else if matches := pdmBackendPat.FindSubmatch(line); matches != nil {
+				return []string{"pdm-backend==" + string(matches[1])}, nil
+			} else if matches := maturinPat.FindSubmatch(line); matches != nil {
+				return []string{"maturin==" + string(matches[1])}, nil
 			} else {
 				return nil, errors.Errorf("unsupported generator: %s", value)
 			}
@@ -33,10 +33,10 @@ func (Rebuilder) Rebuild(ctx context.Context, t rebuild.Target, inst rebuild.Ins
 	if _, err := rebuild.ExecuteScript(ctx, projectfs.Root(), inst.Source); err != nil {
 		return errors.Wrap(err, "fetching source")
 	}
-	if _, err := rebuild.ExecuteScript(ctx, projectfs.Root(), inst.Deps); err != nil {
+	if _, err := rebuild.ExecuteScriptWithLimits(ctx, projectfs.Root(), inst.Deps); err != nil {
 		return errors.Wrap(err, "configuring build deps")
 	}
-	if _, err := rebuild.ExecuteScript(ctx, projectfs.Root(), inst.Build); err != nil {
+	if _, err := rebuild.ExecuteScriptWithLimits(ctx, projectfs.Root(), inst.Build); err != nil {
 		return errors.Wrap(err, "executing build")
 	}
 	return nil
@@ -50,18 +50,19 @@ var (
 	verdictRebuildOnly     = errors.New("file(s) found in rebuild but not upstream")
 	verdictWheelDiff       = errors.New("wheel metadata mismatch")
 	verdictContentDiff     = errors.New("content differences found")
+	verdictLayout          = errors.New("file(s) moved between upstream and rebuild")
 )
 
 func compareTwoFiles(csRB, csUP *archive.ContentSummary) (verdict error, err error) {
-	upOnly, diffs, rbOnly := csUP.Diff(csRB)
-	log.Println(upOnly, diffs, rbOnly)
+	upOnly, diffs, rbOnly, moves := csUP.DiffWithMoves(csRB)
+	log.Println(upOnly, diffs, rbOnly, moves)
 	var foundDSStore bool
 	for _, f := range upOnly {
 		if strings.HasSuffix(f, "/.DS_STORE") {
 			foundDSStore = true
 		}
 	}
-	onlyMetadataDiffs := len(upOnly) == 0 && len(rbOnly) == 0 && len(diffs) > 0
+	onlyMetadataDiffs := len(upOnly) == 0 && len(rbOnly) == 0 && len(moves) == 0 && len(diffs) > 0
 	for _, f := range diffs {
 		onlyMetadataDiffs = onlyMetadataDiffs && strings.Contains(f, ".dist-info/")
 	}
@@ -80,6 +81,8 @@ func compareTwoFiles(csRB, csUP *archive.ContentSummary) (verdict error, err err
 		return verdictWheelDiff, nil
 	case len(diffs) > 0:
 		return verdictContentDiff, nil
+	case len(moves) > 0:
+		return verdictLayout, nil
 	default:
 		return nil, nil
 	}
@@ -85,11 +85,25 @@ func TestCompare(t *testing.T) {
 			},
 			upstream: []*archive.ZipEntry{
 				{FileHeader: &zip.FileHeader{Name: "foo-0.0.1.dist-info/WHEEL"}, Body: []byte("#b")},
-				{FileHeader: &zip.FileHeader{Name: "foo/file2"}, Body: []byte("stuff")},
+				{FileHeader: &zip.FileHeader{Name: "foo/file2"}, Body: []byte("other")},
 			},
 			inst:     rebuild.Instructions{},
 			expected: verdictMismatchedFiles,
 		},
+		{
+			test:   "moved_files",
+			target: rebuild.Target{Ecosystem: rebuild.PyPI, Package: "foo", Version: "0.0.1", Artifact: "foo-0.0.1.zip"},
+			rebuild: []*archive.ZipEntry{
+				{FileHeader: &zip.FileHeader{Name: "foo-0.0.1/PKG-INFO"}, Body: []byte("info")},
+				{FileHeader: &zip.FileHeader{Name: "foo-0.0.1/foo/file"}, Body: []byte("stuff")},
+			},
+			upstream: []*archive.ZipEntry{
+				{FileHeader: &zip.FileHeader{Name: "foo-0.0.1.post0/PKG-INFO"}, Body: []byte("info")},
+				{FileHeader: &zip.FileHeader{Name: "foo-0.0.1.post0/foo/file"}, Body: []byte("stuff")},
+			},
+			inst:     rebuild.Instructions{},
+			expected: verdictLayout,
+		},
 		{
 			test:   "upstream_files",
 			target: rebuild.Target{Ecosystem: rebuild.PyPI, Package: "foo", Version: "0.0.1", Artifact: "foo-0.0.1-py3-none-any.whl"},
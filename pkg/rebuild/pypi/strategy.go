@@ -34,25 +34,25 @@ func (b *PureWheelBuild) GenerateFor(t rebuild.Target, be rebuild.BuildEnv) (reb
 	if err != nil {
 		return rebuild.Instructions{}, err
 	}
-	deps, err := rebuild.PopulateTemplate(`
-/usr/bin/python3 -m venv /deps
-/deps/bin/pip install build
-{{range .Requirements -}}
-/deps/bin/pip install {{.}}
-{{end -}}
-`, b)
-	if err != nil {
-		return rebuild.Instructions{}, err
+	var deps rebuild.Script
+	deps.Cmd("/usr/bin/python3", "-m", "venv", "/deps")
+	install := []string{"/deps/bin/pip", "install"}
+	if be.DisableInstallHooks {
+		// NOTE: Installing only wheels avoids executing the setup scripts of
+		// dependencies distributed as source.
+		install = append(install, "--only-binary=:all:")
 	}
-	build, err := rebuild.PopulateTemplate("/deps/bin/python3 -m build --wheel -n {{.Location.Dir}}", b)
-	if err != nil {
-		return rebuild.Instructions{}, err
+	deps.Cmd(append(install, "build")...)
+	for _, r := range b.Requirements {
+		deps.Cmd(append(install, r)...)
 	}
+	var build rebuild.Script
+	build.Cmd("/deps/bin/python3", "-m", "build", "--wheel", "-n", b.Location.Dir)
 	return rebuild.Instructions{
 		Location:   b.Location,
 		Source:     src,
-		Deps:       deps,
-		Build:      build,
+		Deps:       deps.String(),
+		Build:      build.String(),
 		SystemDeps: []string{"git", "python3"},
 		OutputPath: path.Join("dist", t.Artifact),
 	}, nil
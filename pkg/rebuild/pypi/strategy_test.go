@@ -36,16 +36,15 @@ func TestPureWheelBuild(t *testing.T) {
 			"WithDeps",
 			&PureWheelBuild{
 				Location:     defaultLocation,
-				Requirements: []string{"req_1", "req_2"},
+				Requirements: []string{"req_1", "req_2>=1.0"},
 			},
 			rebuild.Instructions{
 				Location: defaultLocation,
-				Source:   "git checkout --force 'the_ref'",
+				Source:   "git checkout --force the_ref",
 				Deps: `/usr/bin/python3 -m venv /deps
 /deps/bin/pip install build
 /deps/bin/pip install req_1
-/deps/bin/pip install req_2
-`,
+/deps/bin/pip install 'req_2>=1.0'`,
 				Build:      "/deps/bin/python3 -m build --wheel -n the_dir",
 				SystemDeps: []string{"git", "python3"},
 				OutputPath: "dist/the_artifact",
@@ -58,10 +57,9 @@ func TestPureWheelBuild(t *testing.T) {
 			},
 			rebuild.Instructions{
 				Location: defaultLocation,
-				Source:   "git checkout --force 'the_ref'",
+				Source:   "git checkout --force the_ref",
 				Deps: `/usr/bin/python3 -m venv /deps
-/deps/bin/pip install build
-`,
+/deps/bin/pip install build`,
 				Build:      "/deps/bin/python3 -m build --wheel -n the_dir",
 				SystemDeps: []string{"git", "python3"},
 				OutputPath: "dist/the_artifact",
@@ -80,3 +78,21 @@ func TestPureWheelBuild(t *testing.T) {
 		})
 	}
 }
+
+func TestPureWheelBuildDisableInstallHooks(t *testing.T) {
+	strategy := &PureWheelBuild{
+		Location:     rebuild.Location{Dir: "the_dir", Ref: "the_ref", Repo: "the_repo"},
+		Requirements: []string{"req_1", "req_2>=1.0"},
+	}
+	inst, err := strategy.GenerateFor(rebuild.Target{Ecosystem: rebuild.PyPI, Package: "the_package", Version: "the_version", Artifact: "the_artifact"}, rebuild.BuildEnv{HasRepo: true, DisableInstallHooks: true})
+	if err != nil {
+		t.Fatalf("GenerateFor() failed unexpectedly: %v", err)
+	}
+	want := `/usr/bin/python3 -m venv /deps
+/deps/bin/pip install --only-binary=:all: build
+/deps/bin/pip install --only-binary=:all: req_1
+/deps/bin/pip install --only-binary=:all: 'req_2>=1.0'`
+	if diff := cmp.Diff(inst.Deps, want); diff != "" {
+		t.Errorf("GenerateFor() returned Deps diff (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/pkg/errors"
+)
+
+// AdditionReason describes why a file present only upstream warrants attention.
+type AdditionReason string
+
+const (
+	// ReasonInjected marks a file present upstream but absent from the rebuild.
+	ReasonInjected AdditionReason = "injected"
+	// ReasonExecutable marks a file whose extension indicates native or executable code.
+	ReasonExecutable AdditionReason = "executable"
+	// ReasonScript marks a file whose extension indicates interpreted code.
+	ReasonScript AdditionReason = "script"
+	// ReasonInstallHook marks a file conventionally run at install or import time.
+	ReasonInstallHook AdditionReason = "install-hook"
+	// ReasonHidden marks a dotfile or a file within a hidden directory.
+	ReasonHidden AdditionReason = "hidden"
+)
+
+var (
+	executableExts = []string{".exe", ".dll", ".so", ".dylib", ".node", ".bin", ".elf", ".wasm"}
+	scriptExts     = []string{".js", ".cjs", ".mjs", ".ts", ".py", ".pth", ".sh", ".bash", ".ps1", ".bat", ".cmd", ".vbs", ".rb", ".pl"}
+	installHooks   = []string{"setup.py", "__init__.py", "build.rs", "preinstall.js", "install.js", "postinstall.js"}
+)
+
+// SuspiciousAddition is a single file found upstream that the rebuild did not produce.
+type SuspiciousAddition struct {
+	Path    string           `json:"path"`
+	Hash    string           `json:"hash"`
+	Reasons []AdditionReason `json:"reasons"`
+}
+
+// AdditionsReport summarizes the files present upstream but absent from the rebuilt artifact.
+type AdditionsReport struct {
+	Target    Target               `json:"target"`
+	Additions []SuspiciousAddition `json:"additions"`
+}
+
+// Suspicious returns whether any addition was flagged for more than just being injected.
+func (r *AdditionsReport) Suspicious() bool {
+	for _, a := range r.Additions {
+		if len(a.Reasons) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalyzeAdditions identifies files present in the upstream artifact that are absent from the rebuild.
+//
+// A file that cannot be produced from source is the signature of an injected
+// payload so each such file is reported alongside heuristics that indicate
+// whether it is likely to be executed by consumers of the package.
+func AnalyzeAdditions(t Target, csRB, csUP *archive.ContentSummary) *AdditionsReport {
+	report := &AdditionsReport{Target: t, Additions: []SuspiciousAddition{}}
+	_, _, upOnly := csRB.Diff(csUP)
+	hashes := make(map[string]string, len(csUP.Files))
+	for i, f := range csUP.Files {
+		hashes[f] = csUP.FileHashes[i]
+	}
+	for _, f := range upOnly {
+		report.Additions = append(report.Additions, SuspiciousAddition{
+			Path:    f,
+			Hash:    hashes[f],
+			Reasons: classifyAddition(f),
+		})
+	}
+	return report
+}
+
+func classifyAddition(p string) []AdditionReason {
+	reasons := []AdditionReason{ReasonInjected}
+	base := path.Base(p)
+	ext := strings.ToLower(path.Ext(base))
+	if hasAny(executableExts, ext) {
+		reasons = append(reasons, ReasonExecutable)
+	}
+	if hasAny(scriptExts, ext) {
+		reasons = append(reasons, ReasonScript)
+	}
+	if hasAny(installHooks, base) {
+		reasons = append(reasons, ReasonInstallHook)
+	}
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			reasons = append(reasons, ReasonHidden)
+			break
+		}
+	}
+	return reasons
+}
+
+func hasAny(set []string, s string) bool {
+	for _, e := range set {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteAdditionsReport stores the report as a SuspiciousAdditionsAsset.
+func WriteAdditionsReport(ctx context.Context, assets AssetStore, r *AdditionsReport) (Asset, error) {
+	a := Asset{Type: SuspiciousAdditionsAsset, Target: r.Target}
+	w, _, err := assets.Writer(ctx, a)
+	if err != nil {
+		return a, errors.Wrap(err, "creating additions report writer")
+	}
+	defer w.Close()
+	if err := json.NewEncoder(w).Encode(r); err != nil {
+		return a, errors.Wrap(err, "writing additions report")
+	}
+	return a, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/archive"
+)
+
+func TestAnalyzeAdditions(t *testing.T) {
+	target := Target{Ecosystem: NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}
+	rb := &archive.ContentSummary{
+		Files:      []string{"package/index.js", "package/package.json"},
+		FileHashes: []string{"a", "b"},
+	}
+	up := &archive.ContentSummary{
+		Files:      []string{"package/.hidden/x.txt", "package/README.md", "package/index.js", "package/install.js", "package/lib.node", "package/package.json"},
+		FileHashes: []string{"h", "r", "a2", "i", "n", "b"},
+	}
+	got := AnalyzeAdditions(target, rb, up)
+	want := &AdditionsReport{
+		Target: target,
+		Additions: []SuspiciousAddition{
+			{Path: "package/.hidden/x.txt", Hash: "h", Reasons: []AdditionReason{ReasonInjected, ReasonHidden}},
+			{Path: "package/README.md", Hash: "r", Reasons: []AdditionReason{ReasonInjected}},
+			{Path: "package/install.js", Hash: "i", Reasons: []AdditionReason{ReasonInjected, ReasonScript, ReasonInstallHook}},
+			{Path: "package/lib.node", Hash: "n", Reasons: []AdditionReason{ReasonInjected, ReasonExecutable}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("AnalyzeAdditions() mismatch (-want +got):\n%s", diff)
+	}
+	if !got.Suspicious() {
+		t.Error("Suspicious() = false, want true")
+	}
+	benign := AnalyzeAdditions(target, rb, &archive.ContentSummary{
+		Files:      []string{"package/LICENSE", "package/index.js", "package/package.json"},
+		FileHashes: []string{"l", "a", "b"},
+	})
+	if benign.Suspicious() {
+		t.Error("Suspicious() = true for benign additions, want false")
+	}
+}
+
+func TestWriteAdditionsReport(t *testing.T) {
+	ctx := context.Background()
+	assets := NewFilesystemAssetStore(memfs.New())
+	report := &AdditionsReport{
+		Target:    Target{Ecosystem: PyPI, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tar.gz"},
+		Additions: []SuspiciousAddition{{Path: "pkg/evil.pth", Hash: "x", Reasons: []AdditionReason{ReasonInjected, ReasonScript}}},
+	}
+	a, err := WriteAdditionsReport(ctx, assets, report)
+	if err != nil {
+		t.Fatalf("WriteAdditionsReport() error = %v", err)
+	}
+	r, _, err := assets.Reader(ctx, a)
+	if err != nil {
+		t.Fatalf("Reader() error = %v", err)
+	}
+	defer r.Close()
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	var got AdditionsReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(*report, got); diff != "" {
+		t.Errorf("report mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// BuildCache reuses the outputs of remote builds whose inputs are identical.
+//
+// Entries are keyed on the target and the build definition. The build
+// definition captures the source ref, the strategy's steps, and the builder
+// image digests so builds are only reused when their base images are pinned.
+type BuildCache struct {
+	// Store holds the cached outputs and should be shared across runs.
+	Store AssetStore
+}
+
+// cachedAssetTypes are the build outputs stored in the cache.
+// NOTE: BuildInfoAsset is last since its presence marks a complete entry.
+var cachedAssetTypes = []AssetType{RebuildAsset, DockerfileAsset, EnvironmentAsset, BuildInfoAsset}
+
+// buildCacheKey returns the cache key for a build of t from the provided definition.
+func buildCacheKey(t Target, definition string) (string, error) {
+	b, err := json.Marshal(struct {
+		Target     Target
+		Definition string
+	}{t, definition})
+	if err != nil {
+		return "", errors.Wrap(err, "marshalling key")
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *BuildCache) asset(key string, t Target, typ AssetType) Asset {
+	return Asset{Type: typ, Target: Target{Ecosystem: "buildcache", Package: key[:2], Version: key, Artifact: t.Artifact}}
+}
+
+// Restore copies the outputs of the cached build to metadata, returning false if none exists.
+func (c *BuildCache) Restore(ctx context.Context, key string, t Target, metadata AssetStore) (bool, error) {
+	r, _, err := c.Store.Reader(ctx, c.asset(key, t, BuildInfoAsset))
+	if errors.Is(err, ErrAssetNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "checking for entry")
+	}
+	r.Close()
+	for _, typ := range cachedAssetTypes {
+		if err := copyAsset(ctx, c.Store, c.asset(key, t, typ), metadata, Asset{Target: t, Type: typ}); err != nil {
+			return false, errors.Wrapf(err, "restoring %s", typ)
+		}
+	}
+	return true, nil
+}
+
+// Save copies the outputs of a completed build from metadata to the cache.
+func (c *BuildCache) Save(ctx context.Context, key string, t Target, metadata AssetStore) error {
+	for _, typ := range cachedAssetTypes {
+		if err := copyAsset(ctx, metadata, Asset{Target: t, Type: typ}, c.Store, c.asset(key, t, typ)); err != nil {
+			return errors.Wrapf(err, "saving %s", typ)
+		}
+	}
+	return nil
+}
+
+func copyAsset(ctx context.Context, from AssetStore, src Asset, to AssetStore, dst Asset) error {
+	r, _, err := from.Reader(ctx, src)
+	if err != nil {
+		return errors.Wrap(err, "creating reader")
+	}
+	defer r.Close()
+	w, _, err := to.Writer(ctx, dst)
+	if err != nil {
+		return errors.Wrap(err, "creating writer")
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return errors.Wrap(err, "copying")
+	}
+	return w.Close()
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildCacheKey(t *testing.T) {
+	target := Target{Ecosystem: NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}
+	base := must(buildCacheKey(target, "FROM alpine:3.19@sha256:aaaa"))
+	if again := must(buildCacheKey(target, "FROM alpine:3.19@sha256:aaaa")); again != base {
+		t.Errorf("buildCacheKey() not deterministic: %s != %s", again, base)
+	}
+	other := target
+	other.Version = "1.0.1"
+	for name, key := range map[string]string{
+		"image digest": must(buildCacheKey(target, "FROM alpine:3.19@sha256:bbbb")),
+		"target":       must(buildCacheKey(other, "FROM alpine:3.19@sha256:aaaa")),
+	} {
+		if key == base {
+			t.Errorf("buildCacheKey() unchanged by %s", name)
+		}
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	ctx := context.Background()
+	target := Target{Ecosystem: NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}
+	key := must(buildCacheKey(target, "FROM alpine:3.19@sha256:aaaa"))
+	cache := &BuildCache{Store: NewFilesystemAssetStore(memfs.New())}
+	fresh := NewFilesystemAssetStore(memfs.New())
+	if ok, err := cache.Restore(ctx, key, target, fresh); err != nil || ok {
+		t.Fatalf("Restore() on empty cache = %v, %v; want false, nil", ok, err)
+	}
+	built := NewFilesystemAssetStore(memfs.New())
+	want := map[AssetType]string{
+		RebuildAsset:     "artifact",
+		DockerfileAsset:  "FROM alpine:3.19@sha256:aaaa",
+		EnvironmentAsset: "{}",
+		BuildInfoAsset:   `{"ID":"first"}`,
+	}
+	for typ, content := range want {
+		if err := writeAsset(ctx, built, Asset{Target: target, Type: typ}, []byte(content)); err != nil {
+			t.Fatalf("writeAsset(%s) error: %v", typ, err)
+		}
+	}
+	if err := cache.Save(ctx, key, target, built); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if ok, err := cache.Restore(ctx, key, target, fresh); err != nil || !ok {
+		t.Fatalf("Restore() = %v, %v; want true, nil", ok, err)
+	}
+	for typ, content := range want {
+		r, _, err := fresh.Reader(ctx, Asset{Target: target, Type: typ})
+		if err != nil {
+			t.Fatalf("Reading %s: %v", typ, err)
+		}
+		if diff := cmp.Diff(content, string(must(io.ReadAll(r)))); diff != "" {
+			t.Errorf("%s mismatch (-want +got):\n%s", typ, diff)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// cgroupRoot is the mount point of the cgroup v2 hierarchy.
+	cgroupRoot = "/sys/fs/cgroup"
+	// procSelfCgroup lists the cgroup membership of the current process.
+	procSelfCgroup = "/proc/self/cgroup"
+)
+
+var (
+	buildCgroupsOnce sync.Once
+	buildCgroups     string
+	buildCgroupsErr  error
+)
+
+// buildCgroupParent returns the cgroup below which the cgroups of limited
+// builds are created, delegating the memory controller to it on first use.
+func buildCgroupParent() (string, error) {
+	buildCgroupsOnce.Do(func() {
+		buildCgroups, buildCgroupsErr = setupBuildCgroups()
+	})
+	return buildCgroups, buildCgroupsErr
+}
+
+// setupBuildCgroups enables the memory controller for children of the current
+// process's cgroup.
+//
+// cgroup v2 does not permit a cgroup containing processes to distribute
+// resources to its children so the processes in the current cgroup are first
+// moved to a "service" child.
+func setupBuildCgroups() (string, error) {
+	b, err := os.ReadFile(procSelfCgroup)
+	if err != nil {
+		return "", errors.Wrap(err, "reading cgroup membership")
+	}
+	var rel string
+	var found bool
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		if rel, found = strings.CutPrefix(s.Text(), "0::"); found {
+			break
+		}
+	}
+	if !found {
+		return "", errors.New("cgroup v2 hierarchy not found")
+	}
+	dir := filepath.Join(cgroupRoot, rel)
+	svc := filepath.Join(dir, "service")
+	if err := os.Mkdir(svc, 0755); err != nil && !os.IsExist(err) {
+		return "", errors.Wrap(err, "creating service cgroup")
+	}
+	procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return "", errors.Wrap(err, "listing cgroup processes")
+	}
+	for _, pid := range strings.Fields(string(procs)) {
+		if err := os.WriteFile(filepath.Join(svc, "cgroup.procs"), []byte(pid), 0644); err != nil {
+			return "", errors.Wrapf(err, "moving process %s", pid)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+memory"), 0644); err != nil {
+		return "", errors.Wrap(err, "enabling memory controller")
+	}
+	return dir, nil
+}
+
+// memoryCgroup is a cgroup limiting the memory available to its members.
+type memoryCgroup struct {
+	dir string
+}
+
+// newMemoryCgroup creates a cgroup whose members may use at most limitMB megabytes of memory, without swap.
+func newMemoryCgroup(limitMB int64) (*memoryCgroup, error) {
+	parent, err := buildCgroupParent()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(parent, "build-")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating build cgroup")
+	}
+	c := &memoryCgroup{dir: dir}
+	if err := os.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.FormatInt(limitMB<<20, 10)), 0644); err != nil {
+		c.Close()
+		return nil, errors.Wrap(err, "setting memory limit")
+	}
+	// NOTE: Swap accounting is optional so its absence is not an error.
+	if err := os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0644); err != nil && !os.IsNotExist(err) {
+		c.Close()
+		return nil, errors.Wrap(err, "disabling swap")
+	}
+	return c, nil
+}
+
+// enter returns a shell prologue that moves the executing shell, and hence
+// all of the processes it starts, into the cgroup.
+func (c *memoryCgroup) enter() string {
+	return "echo $$ > " + Quote(filepath.Join(c.dir, "cgroup.procs")) + " || exit 1"
+}
+
+// OOMKilled returns whether any member of the cgroup was killed for exceeding the limit.
+func (c *memoryCgroup) OOMKilled() bool {
+	b, err := os.ReadFile(filepath.Join(c.dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		if n, ok := strings.CutPrefix(s.Text(), "oom_kill "); ok {
+			count, _ := strconv.Atoi(n)
+			return count > 0
+		}
+	}
+	return false
+}
+
+// Close kills any remaining members of the cgroup and removes it.
+func (c *memoryCgroup) Close() error {
+	// NOTE: cgroup.kill is only available from Linux 5.14 so failure is tolerated.
+	os.WriteFile(filepath.Join(c.dir, "cgroup.kill"), []byte("1"), 0644)
+	return os.Remove(c.dir)
+}
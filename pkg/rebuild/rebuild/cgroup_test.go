@@ -0,0 +1,97 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeCgroupRoot creates a cgroup v2 hierarchy in which the current process is a member of "/svc.slice/rebuilder".
+func fakeCgroupRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "svc.slice", "rebuilder")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte("1\n7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	self := filepath.Join(root, "self-cgroup")
+	if err := os.WriteFile(self, []byte("0::/svc.slice/rebuilder\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	oldRoot, oldSelf := cgroupRoot, procSelfCgroup
+	cgroupRoot, procSelfCgroup = root, self
+	t.Cleanup(func() { cgroupRoot, procSelfCgroup = oldRoot, oldSelf })
+	return dir
+}
+
+func TestSetupBuildCgroups(t *testing.T) {
+	want := fakeCgroupRoot(t)
+	dir, err := setupBuildCgroups()
+	if err != nil {
+		t.Fatalf("setupBuildCgroups() returned error: %v", err)
+	}
+	if dir != want {
+		t.Errorf("setupBuildCgroups() = %s, want %s", dir, want)
+	}
+	// NOTE: Each write replaces the file so only the last pid moved remains.
+	if b, err := os.ReadFile(filepath.Join(dir, "service", "cgroup.procs")); err != nil || string(b) != "7" {
+		t.Errorf("service cgroup.procs = %q, %v, want %q", b, err, "7")
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control")); err != nil || string(b) != "+memory" {
+		t.Errorf("cgroup.subtree_control = %q, %v, want %q", b, err, "+memory")
+	}
+}
+
+func TestSetupBuildCgroupsV1(t *testing.T) {
+	fakeCgroupRoot(t)
+	if err := os.WriteFile(procSelfCgroup, []byte("4:memory:/rebuilder\n1:name=systemd:/rebuilder\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setupBuildCgroups(); err == nil {
+		t.Error("setupBuildCgroups() succeeded without a cgroup v2 hierarchy")
+	}
+}
+
+func TestMemoryCgroup(t *testing.T) {
+	parent := fakeCgroupRoot(t)
+	c, err := newMemoryCgroup(512)
+	if err != nil {
+		t.Fatalf("newMemoryCgroup() returned error: %v", err)
+	}
+	if filepath.Dir(c.dir) != parent {
+		t.Errorf("cgroup %s is not a child of %s", c.dir, parent)
+	}
+	if b, err := os.ReadFile(filepath.Join(c.dir, "memory.max")); err != nil || string(b) != "536870912" {
+		t.Errorf("memory.max = %q, %v, want %q", b, err, "536870912")
+	}
+	if got, want := c.enter(), "echo $$ > "+filepath.Join(c.dir, "cgroup.procs")+" || exit 1"; got != want {
+		t.Errorf("enter() = %q, want %q", got, want)
+	}
+	if c.OOMKilled() {
+		t.Error("OOMKilled() = true without memory.events")
+	}
+	events := "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"
+	if err := os.WriteFile(filepath.Join(c.dir, "memory.events"), []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !c.OOMKilled() {
+		t.Error("OOMKilled() = false, want true")
+	}
+}
@@ -33,6 +33,8 @@ func artifactReader(ctx context.Context, t Target, mux RegistryMux) (io.ReadClos
 		return mux.PyPI.Artifact(ctx, t.Package, t.Version, t.Artifact)
 	case CratesIO:
 		return mux.CratesIO.Artifact(ctx, t.Package, t.Version)
+	case OCI:
+		return mux.OCI.Artifact(ctx, t.Package, t.Version)
 	default:
 		return nil, errors.New("unsupported ecosystem")
 	}
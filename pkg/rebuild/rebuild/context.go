@@ -28,4 +28,6 @@ const (
 	TimewarpID
 	RunID
 	GCSClientOptionsID
+	ResourcesID
+	S3ConfigID
 )
@@ -0,0 +1,196 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	casManifestKind = "oss-rebuild/cas-manifest"
+	// casManifestSuffix distinguishes the name of an asset's manifest from that of the asset.
+	casManifestSuffix = ".cas-manifest"
+	// maxManifestSize bounds the size of a serialized casManifest.
+	maxManifestSize = 512
+	blobAssetType   = AssetType("blob")
+)
+
+// casManifest is stored alongside an asset in place of its content and references the blob holding it.
+type casManifest struct {
+	Kind   string `json:"kind"`
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+func parseManifest(b []byte) (casManifest, bool) {
+	var m casManifest
+	if err := json.Unmarshal(b, &m); err != nil || m.Kind != casManifestKind {
+		return m, false
+	}
+	hexDigest, ok := strings.CutPrefix(m.Digest, "sha256:")
+	if !ok || len(hexDigest) != sha256.Size*2 {
+		return m, false
+	}
+	return m, true
+}
+
+// manifestAsset returns the asset under which the manifest of a is stored.
+//
+// Manifests are stored under a name of their own, rather than that of the
+// asset, so that an asset uploaded directly to the manifest store, such as the
+// output of a remote build, cannot pose as a manifest referencing another blob.
+func manifestAsset(a Asset) Asset {
+	name := string(a.Type)
+	if a.Type == RebuildAsset {
+		name = a.Target.Artifact
+	}
+	return Asset{Type: AssetType(name + casManifestSuffix), Target: a.Target}
+}
+
+func blobAsset(hexDigest string) Asset {
+	return Asset{Type: blobAssetType, Target: Target{Ecosystem: "sha256", Package: hexDigest[:2], Version: hexDigest}}
+}
+
+// ContentAddressedStore is an AssetStore that stores each distinct asset content once.
+//
+// Asset contents are written to the blob store keyed by their SHA-256 digest
+// and a manifest referencing the blob is written to the manifest store in
+// place of the content. Assets without a manifest, such as those uploaded
+// directly by remote builds, are read as-is from the manifest store.
+// Their content is never interpreted as a manifest.
+type ContentAddressedStore struct {
+	blobs     AssetStore
+	manifests AssetStore
+}
+
+// NewContentAddressedStore creates a new ContentAddressedStore.
+//
+// The blob store should be shared across runs for assets to be deduplicated.
+func NewContentAddressedStore(blobs, manifests AssetStore) *ContentAddressedStore {
+	return &ContentAddressedStore{blobs: blobs, manifests: manifests}
+}
+
+// Reader returns a reader for the given asset.
+func (s *ContentAddressedStore) Reader(ctx context.Context, a Asset) (r io.ReadCloser, uri string, err error) {
+	mr, uri, err := s.manifests.Reader(ctx, manifestAsset(a))
+	if errors.Is(err, ErrAssetNotFound) {
+		return s.manifests.Reader(ctx, a)
+	} else if err != nil {
+		return nil, "", err
+	}
+	defer mr.Close()
+	b, err := io.ReadAll(io.LimitReader(mr, maxManifestSize+1))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "reading manifest for %v", a)
+	}
+	m, ok := parseManifest(b)
+	if len(b) > maxManifestSize || !ok {
+		return nil, "", errors.Errorf("malformed manifest for %v", a)
+	}
+	blob, _, err := s.blobs.Reader(ctx, blobAsset(strings.TrimPrefix(m.Digest, "sha256:")))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "reading blob %s", m.Digest)
+	}
+	return blob, uri, nil
+}
+
+// Writer returns a writer for the given asset.
+//
+// Content is staged in a temporary file and stored when the writer is closed.
+func (s *ContentAddressedStore) Writer(ctx context.Context, a Asset) (w io.WriteCloser, uri string, err error) {
+	mw, uri, err := s.manifests.Writer(ctx, manifestAsset(a))
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := os.CreateTemp("", "asset-")
+	if err != nil {
+		mw.Close()
+		return nil, "", errors.Wrap(err, "creating staging file")
+	}
+	return &casWriter{ctx: ctx, s: s, manifest: mw, f: f, h: sha256.New()}, uri, nil
+}
+
+var _ AssetStore = &ContentAddressedStore{}
+
+type casWriter struct {
+	ctx      context.Context
+	s        *ContentAddressedStore
+	manifest io.WriteCloser
+	f        *os.File
+	h        hash.Hash
+	n        int64
+}
+
+func (w *casWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.h.Write(p[:n])
+	w.n += int64(n)
+	return n, err
+}
+
+// Close stores the blob if not already present and writes the manifest referencing it.
+func (w *casWriter) Close() error {
+	defer os.Remove(w.f.Name())
+	defer w.f.Close()
+	if err := w.commit(); err != nil {
+		w.manifest.Close()
+		return err
+	}
+	return errors.Wrap(w.manifest.Close(), "closing manifest")
+}
+
+func (w *casWriter) commit() error {
+	hexDigest := hex.EncodeToString(w.h.Sum(nil))
+	blob := blobAsset(hexDigest)
+	if r, _, err := w.s.blobs.Reader(w.ctx, blob); err == nil {
+		r.Close()
+	} else if errors.Is(err, ErrAssetNotFound) {
+		if err := w.storeBlob(blob); err != nil {
+			return err
+		}
+	} else {
+		return errors.Wrapf(err, "checking for blob %s", hexDigest)
+	}
+	b, err := json.Marshal(casManifest{Kind: casManifestKind, Digest: "sha256:" + hexDigest, Size: w.n})
+	if err != nil {
+		return errors.Wrap(err, "marshalling manifest")
+	}
+	_, err = w.manifest.Write(b)
+	return errors.Wrap(err, "writing manifest")
+}
+
+func (w *casWriter) storeBlob(blob Asset) error {
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "rewinding staging file")
+	}
+	bw, _, err := w.s.blobs.Writer(w.ctx, blob)
+	if err != nil {
+		return errors.Wrap(err, "creating blob writer")
+	}
+	if _, err := io.Copy(bw, w.f); err != nil {
+		bw.Close()
+		return errors.Wrap(err, "writing blob")
+	}
+	return errors.Wrap(bw.Close(), "closing blob")
+}
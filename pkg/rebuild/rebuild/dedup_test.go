@@ -0,0 +1,91 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestContentAddressedStore(t *testing.T) {
+	ctx := context.Background()
+	mfs := memfs.New()
+	blobs := NewFilesystemAssetStore(mfs)
+	write := func(t *testing.T, s AssetStore, a Asset, content string) {
+		t.Helper()
+		w, _, err := s.Writer(ctx, a)
+		if err != nil {
+			t.Fatalf("Writer() error: %v", err)
+		}
+		io.WriteString(w, content)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() error: %v", err)
+		}
+	}
+	read := func(t *testing.T, s AssetStore, a Asset) string {
+		t.Helper()
+		r, _, err := s.Reader(ctx, a)
+		if err != nil {
+			t.Fatalf("Reader() error: %v", err)
+		}
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll() error: %v", err)
+		}
+		return string(b)
+	}
+	a := Asset{Type: DebugUpstreamAsset, Target: Target{Ecosystem: NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}}
+	run1 := NewContentAddressedStore(blobs, NewFilesystemAssetStoreWithRunID(mfs, "run1"))
+	run2 := NewContentAddressedStore(blobs, NewFilesystemAssetStoreWithRunID(mfs, "run2"))
+	write(t, run1, a, "content")
+	write(t, run2, a, "content")
+	for _, s := range []AssetStore{run1, run2} {
+		if diff := cmp.Diff("content", read(t, s, a)); diff != "" {
+			t.Errorf("Reader() content mismatch (-want +got):\n%s", diff)
+		}
+	}
+	var blobCount int
+	util.Walk(mfs, "sha256", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			blobCount++
+		}
+		return nil
+	})
+	if blobCount != 1 {
+		t.Errorf("blob count = %d, want 1", blobCount)
+	}
+	// Assets written without the manifest layer are read as-is.
+	legacy := Asset{Type: DebugRebuildAsset, Target: a.Target}
+	write(t, NewFilesystemAssetStoreWithRunID(mfs, "run1"), legacy, "raw")
+	if diff := cmp.Diff("raw", read(t, run1, legacy)); diff != "" {
+		t.Errorf("Reader() legacy content mismatch (-want +got):\n%s", diff)
+	}
+	// An asset uploaded directly is not followed even if it resembles a manifest.
+	forged := fmt.Sprintf(`{"kind":%q,"digest":"sha256:%x","size":7}`, casManifestKind, sha256.Sum256([]byte("content")))
+	uploaded := Asset{Type: RebuildAsset, Target: a.Target}
+	write(t, NewFilesystemAssetStoreWithRunID(mfs, "run1"), uploaded, forged)
+	if diff := cmp.Diff(forged, read(t, run1, uploaded)); diff != "" {
+		t.Errorf("Reader() followed uploaded manifest (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DependencyCache is a persistent directory in which an ecosystem's tooling caches downloaded dependencies.
+//
+// Mounting these directories across rebuilds avoids re-fetching the dependency
+// closure on each run. Because a warm cache can mask fetch failures, rebuilds
+// executed with caches mounted should record the cache key with their results.
+type DependencyCache struct {
+	Ecosystem Ecosystem
+	// Path is the location of the cache within the rebuilder container.
+	Path string
+}
+
+// DependencyCaches are the caches used by the tooling of each supported ecosystem.
+var DependencyCaches = []DependencyCache{
+	{Ecosystem: NPM, Path: "/root/.npm"},
+	{Ecosystem: PyPI, Path: "/root/.cache/pip"},
+	{Ecosystem: CratesIO, Path: "/root/.cargo/registry"},
+	{Ecosystem: Maven, Path: "/root/.m2"},
+}
+
+// dependencyCacheSeedDir is the directory of the rebuilder container at which
+// the persistent caches are mounted read-only.
+var dependencyCacheSeedDir = "/var/cache/oss-rebuild/deps"
+
+func (c DependencyCache) seedPath() string {
+	return filepath.Join(dependencyCacheSeedDir, string(c.Ecosystem))
+}
+
+// DependencyCacheMounts returns the volume mounts, in SOURCE:TARGET form, that
+// attach a named volume for each of the DependencyCaches. Volume names are
+// derived from key so distinct keys never share cache contents.
+//
+// Unless populating, the volumes are mounted read-only and each rebuild is
+// given a copy by AcquireDependencyCache so that rebuilds neither modify the
+// persistent caches nor observe each other's writes. When populating, the
+// volumes are mounted writable in place of the caches so the rebuilds executed
+// fill them. A populating rebuilder should execute one rebuild at a time.
+func DependencyCacheMounts(key string, populate bool) []string {
+	var mounts []string
+	for _, c := range DependencyCaches {
+		if populate {
+			mounts = append(mounts, fmt.Sprintf("%s-%s:%s", key, c.Ecosystem, c.Path))
+		} else {
+			mounts = append(mounts, fmt.Sprintf("%s-%s:%s:ro", key, c.Ecosystem, c.seedPath()))
+		}
+	}
+	return mounts
+}
+
+var (
+	dependencyCacheLocksMu sync.Mutex
+	dependencyCacheLocks   = make(map[Ecosystem]*sync.RWMutex)
+)
+
+func dependencyCacheLock(e Ecosystem) *sync.RWMutex {
+	dependencyCacheLocksMu.Lock()
+	defer dependencyCacheLocksMu.Unlock()
+	if dependencyCacheLocks[e] == nil {
+		dependencyCacheLocks[e] = new(sync.RWMutex)
+	}
+	return dependencyCacheLocks[e]
+}
+
+// AcquireDependencyCache prepares the ecosystem's dependency cache for a
+// rebuild, returning a function to call once the rebuild completes.
+//
+// If the persistent cache is mounted read-only, the cache is replaced with a
+// fresh copy of it. The copy waits for rebuilds holding the cache to release
+// it. If the persistent cache is being populated, the cache is used as is.
+func AcquireDependencyCache(e Ecosystem) (release func(), err error) {
+	for _, c := range DependencyCaches {
+		if c.Ecosystem != e {
+			continue
+		}
+		if _, err := os.Stat(c.seedPath()); os.IsNotExist(err) {
+			return func() {}, nil
+		}
+		mu := dependencyCacheLock(e)
+		mu.Lock()
+		err := os.RemoveAll(c.Path)
+		if err == nil {
+			err = copyDir(c.Path, c.seedPath())
+		}
+		mu.Unlock()
+		if err != nil {
+			return nil, errors.Wrapf(err, "copying %s dependency cache", e)
+		}
+		mu.RLock()
+		return mu.RUnlock, nil
+	}
+	return func() {}, nil
+}
+
+// copyDir recursively copies the directories, regular files, and symlinks of src to dst.
+func copyDir(dst, src string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(target, path, info.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
+
+func copyFile(dst, src string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDependencyCacheMounts(t *testing.T) {
+	old := DependencyCaches
+	DependencyCaches = []DependencyCache{{Ecosystem: NPM, Path: "/root/.npm"}}
+	t.Cleanup(func() { DependencyCaches = old })
+	if got, want := DependencyCacheMounts("k", false), []string{"k-npm:/var/cache/oss-rebuild/deps/npm:ro"}; !slices.Equal(got, want) {
+		t.Errorf("DependencyCacheMounts(populate=false) = %v, want %v", got, want)
+	}
+	if got, want := DependencyCacheMounts("k", true), []string{"k-npm:/root/.npm"}; !slices.Equal(got, want) {
+		t.Errorf("DependencyCacheMounts(populate=true) = %v, want %v", got, want)
+	}
+}
+
+func TestAcquireDependencyCache(t *testing.T) {
+	root := t.TempDir()
+	oldCaches, oldSeed := DependencyCaches, dependencyCacheSeedDir
+	cache := filepath.Join(root, "npm-cache")
+	DependencyCaches = []DependencyCache{{Ecosystem: NPM, Path: cache}}
+	dependencyCacheSeedDir = filepath.Join(root, "seed")
+	t.Cleanup(func() { DependencyCaches, dependencyCacheSeedDir = oldCaches, oldSeed })
+	t.Run("populating", func(t *testing.T) {
+		release, err := AcquireDependencyCache(NPM)
+		if err != nil {
+			t.Fatalf("AcquireDependencyCache() returned error: %v", err)
+		}
+		release()
+		if _, err := os.Stat(cache); !os.IsNotExist(err) {
+			t.Errorf("cache created without a seed: %v", err)
+		}
+	})
+	seed := filepath.Join(dependencyCacheSeedDir, "npm")
+	if err := os.MkdirAll(filepath.Join(seed, "_cacache"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(seed, "_cacache", "index"), []byte("left-pad"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("_cacache/index", filepath.Join(seed, "latest")); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("read-only", func(t *testing.T) {
+		// Writes of a previous rebuild must not be observed.
+		if err := os.MkdirAll(cache, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(cache, "stale"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		release, err := AcquireDependencyCache(NPM)
+		if err != nil {
+			t.Fatalf("AcquireDependencyCache() returned error: %v", err)
+		}
+		defer release()
+		if b, err := os.ReadFile(filepath.Join(cache, "latest")); err != nil || string(b) != "left-pad" {
+			t.Errorf("cache contents = %q, %v, want %q", b, err, "left-pad")
+		}
+		if _, err := os.Stat(filepath.Join(cache, "stale")); !os.IsNotExist(err) {
+			t.Errorf("stale cache entry retained: %v", err)
+		}
+	})
+}
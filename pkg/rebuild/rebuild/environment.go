@@ -0,0 +1,102 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ImageResolver resolves container image references to manifest digests.
+type ImageResolver interface {
+	Resolve(ctx context.Context, ref string) (digest string, err error)
+}
+
+// Environment is a machine-readable description of the environment in which a rebuild was executed.
+type Environment struct {
+	// Images maps each base image reference used by the build to its manifest digest.
+	Images map[string]string `json:"images,omitempty"`
+	// Packages are the system packages installed in the build container.
+	Packages []InstalledPackage `json:"packages,omitempty"`
+	// Platform is the platform of the runner if the build was not executed on Linux.
+	Platform Platform `json:"platform,omitempty"`
+	// Runner identifies the host that executed a build on a non-Linux platform.
+	Runner string `json:"runner,omitempty"`
+}
+
+// InstalledPackage is a system package present in the build container.
+type InstalledPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// pinnedImages maps image references to their digest-qualified form.
+type pinnedImages map[string]string
+
+// resolveImages returns the digests of the provided image references.
+func resolveImages(ctx context.Context, resolver ImageResolver, refs ...string) (pinnedImages, error) {
+	pinned := make(pinnedImages)
+	for _, ref := range refs {
+		digest, err := resolver.Resolve(ctx, ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolving %s", ref)
+		}
+		pinned[ref] = digest
+	}
+	return pinned, nil
+}
+
+// Ref returns ref qualified with its resolved digest, if one exists.
+func (p pinnedImages) Ref(ref string) string {
+	if digest, ok := p[ref]; ok {
+		return ref + "@" + digest
+	}
+	return ref
+}
+
+// parseApkInstalled reads the packages from an apk installed database (/lib/apk/db/installed).
+func parseApkInstalled(r io.Reader) ([]InstalledPackage, error) {
+	var pkgs []InstalledPackage
+	var cur InstalledPackage
+	flush := func() {
+		if cur.Name != "" {
+			pkgs = append(pkgs, cur)
+		}
+		cur = InstalledPackage{}
+	}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "P:"):
+			cur.Name = line[2:]
+		case strings.HasPrefix(line, "V:"):
+			cur.Version = line[2:]
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading apk database")
+	}
+	flush()
+	return pkgs, nil
+}
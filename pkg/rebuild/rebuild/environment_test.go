@@ -0,0 +1,56 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseApkInstalled(t *testing.T) {
+	db := `C:Q1abc=
+P:musl
+V:1.2.4_git20230717-r4
+A:x86_64
+
+C:Q1def=
+P:git
+V:2.43.0-r0
+A:x86_64
+`
+	got, err := parseApkInstalled(strings.NewReader(db))
+	if err != nil {
+		t.Fatalf("parseApkInstalled() error: %v", err)
+	}
+	want := []InstalledPackage{
+		{Name: "musl", Version: "1.2.4_git20230717-r4"},
+		{Name: "git", Version: "2.43.0-r0"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseApkInstalled() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPinnedImagesRef(t *testing.T) {
+	p := pinnedImages{"alpine:3.19": "sha256:aaaa"}
+	if got := p.Ref("alpine:3.19"); got != "alpine:3.19@sha256:aaaa" {
+		t.Errorf("Ref() = %s, want alpine:3.19@sha256:aaaa", got)
+	}
+	if got := p.Ref("debian"); got != "debian" {
+		t.Errorf("Ref() = %s, want debian", got)
+	}
+}
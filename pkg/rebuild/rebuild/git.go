@@ -53,12 +53,18 @@ func MatchTag(tag, pkg, version string) (strict bool, approx bool) {
 }
 
 // FindTagMatch searches a repositories tags for a possible version match and returns the commit hash.
+//
+// Tags following a known convention (see CandidateTags) are preferred. Otherwise,
+// the first tag accepted by MatchTag is used.
 func FindTagMatch(pkg, version string, repo *git.Repository) (commit string, err error) {
 	var matches, nearMatches []string
 	tags, err := allTags(repo)
 	if err != nil {
 		return
 	}
+	if tag, ok := findConventionalTag(tags, pkg, version); ok {
+		return tagCommit(repo, tag)
+	}
 	for _, tag := range tags {
 		strict, approx := MatchTag(tag, pkg, version)
 		if strict {
@@ -74,21 +80,25 @@ func FindTagMatch(pkg, version string, repo *git.Repository) (commit string, err
 		if len(matches) > 1 {
 			log.Printf("Multiple tag matches [pkg=%s,ver=%s,matches=%v]\n", pkg, version, matches)
 		}
-		ref, err := repo.Tag(matches[0])
-		if err != nil {
-			return "", err
-		}
-		if t, err := repo.TagObject(ref.Hash()); err == nil {
-			// Annotated tag. Use the Target pointer as the ref hash.
-			commit = t.Target.String()
-		} else {
-			// Lightweight tag. Use the ref hash itself.
-			commit = ref.Hash().String()
-		}
+		return tagCommit(repo, matches[0])
 	}
 	return
 }
 
+// tagCommit returns the hash of the commit to which the tag refers.
+func tagCommit(repo *git.Repository, tag string) (string, error) {
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		return "", err
+	}
+	if t, err := repo.TagObject(ref.Hash()); err == nil {
+		// Annotated tag. Use the Target pointer as the ref hash.
+		return t.Target.String(), nil
+	}
+	// Lightweight tag. Use the ref hash itself.
+	return ref.Hash().String(), nil
+}
+
 func allTags(repo *git.Repository) (tags []string, err error) {
 	ri, err := repo.Tags()
 	if err != nil {
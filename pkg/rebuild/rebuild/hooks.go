@@ -0,0 +1,117 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/pkg/errors"
+)
+
+// InstallHookReport records the install hooks declared by an upstream artifact
+// for a rebuild run with install hooks disabled.
+//
+// The upstream build was free to run these hooks while installing the package's
+// dependencies so, unlike the rebuild, its artifact could have been affected by them.
+type InstallHookReport struct {
+	Hooks []RiskEvidence `json:"hooks"`
+}
+
+// Affected returns whether the upstream artifact could have been affected by install hooks.
+func (r InstallHookReport) Affected() bool {
+	return len(r.Hooks) > 0
+}
+
+// npmLocalInstallHooks are the lifecycle scripts run by "npm install" within the package.
+var npmLocalInstallHooks = append(slices.Clone(npmInstallHooks), "prepare")
+
+// isInstallHookCandidate returns whether the archive entry at p may declare
+// hooks run when installing the package it contains.
+func isInstallHookCandidate(p string) bool {
+	// NOTE: Only entries at the package root are considered. Packaged archives
+	// nest the root within at most one directory (e.g. "package/" for npm).
+	if strings.Count(p, "/") > 1 {
+		return false
+	}
+	base := path.Base(p)
+	return base == "package.json" || base == "setup.py" || path.Ext(base) == ".pth"
+}
+
+// InstallHooks returns the install hooks declared by the files of an artifact, keyed by path.
+func InstallHooks(files map[string][]byte) []RiskEvidence {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		if isInstallHookCandidate(p) {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+	hooks := []RiskEvidence{}
+	for _, p := range paths {
+		add := func(detail string) {
+			hooks = append(hooks, RiskEvidence{Path: p, Indicator: IndicatorInstallHook, Detail: detail})
+		}
+		switch base := path.Base(p); {
+		case base == "package.json":
+			var m struct {
+				Scripts map[string]string `json:"scripts"`
+			}
+			if err := json.Unmarshal(files[p], &m); err != nil {
+				continue
+			}
+			for _, h := range npmLocalInstallHooks {
+				if cmd, ok := m.Scripts[h]; ok {
+					add(truncate("scripts."+h+": "+cmd, 80))
+				}
+			}
+		case base == "setup.py":
+			if m := setupHookPattern.Find(files[p]); m != nil {
+				add(string(m))
+			}
+		default:
+			add(base + " executed at interpreter startup")
+		}
+	}
+	return hooks
+}
+
+// upstreamInstallHooks reports the install hooks declared by the upstream artifact.
+func upstreamInstallHooks(ctx context.Context, t Target, up Asset, assets AssetStore) (*InstallHookReport, error) {
+	r, _, err := assets.Reader(ctx, up)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening upstream artifact")
+	}
+	cs, err := archive.NewContentSummary(r, t.ArchiveType())
+	r.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "summarizing upstream artifact")
+	}
+	var names []string
+	for _, f := range cs.Files {
+		if isInstallHookCandidate(f) {
+			names = append(names, f)
+		}
+	}
+	files, err := readAssetEntries(ctx, t, up, assets, names)
+	if err != nil {
+		return nil, err
+	}
+	return &InstallHookReport{Hooks: InstallHooks(files)}, nil
+}
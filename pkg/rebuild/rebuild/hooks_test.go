@@ -0,0 +1,70 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInstallHooks(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string][]byte
+		want  []RiskEvidence
+	}{
+		{
+			name:  "no hooks",
+			files: map[string][]byte{"package/package.json": []byte(`{"name":"x","scripts":{"test":"jest"}}`)},
+			want:  []RiskEvidence{},
+		},
+		{
+			name: "npm lifecycle scripts",
+			files: map[string][]byte{
+				"package/package.json": []byte(`{"name":"x","scripts":{"prepare":"tsc","postinstall":"node setup.js"}}`),
+				// Manifests of nested packages are not run when installing the package.
+				"package/fixtures/a/package.json": []byte(`{"scripts":{"install":"node-gyp rebuild"}}`),
+			},
+			want: []RiskEvidence{
+				{Path: "package/package.json", Indicator: IndicatorInstallHook, Detail: "scripts.postinstall: node setup.js"},
+				{Path: "package/package.json", Indicator: IndicatorInstallHook, Detail: "scripts.prepare: tsc"},
+			},
+		},
+		{
+			name: "python setup and path config",
+			files: map[string][]byte{
+				"x-1.0/setup.py": []byte("from setuptools import setup\nsetup(name='x', cmdclass={'install': Hook})\n"),
+				"x.pth":          []byte("import x._init\n"),
+			},
+			want: []RiskEvidence{
+				{Path: "x-1.0/setup.py", Indicator: IndicatorInstallHook, Detail: "cmdclass="},
+				{Path: "x.pth", Indicator: IndicatorInstallHook, Detail: "x.pth executed at interpreter startup"},
+			},
+		},
+		{
+			name:  "malformed manifest",
+			files: map[string][]byte{"package/package.json": []byte(`{"scripts":`)},
+			want:  []RiskEvidence{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := InstallHooks(tc.files)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("InstallHooks() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -17,6 +17,9 @@ package rebuild
 import (
 	"io"
 	"log"
+	"regexp"
+	"slices"
+	"strings"
 )
 
 func ScopedLogCapture(l *log.Logger, w io.Writer) func() {
@@ -25,3 +28,76 @@ func ScopedLogCapture(l *log.Logger, w io.Writer) func() {
 	l.SetOutput(mw)
 	return func() { l.SetOutput(orig) }
 }
+
+// LogFailure identifies the line of a build log most likely to explain a failed rebuild.
+type LogFailure struct {
+	// Line is the zero-based index of the failing line within the log.
+	Line int
+	// Snippet is the failing line followed by those elaborating on it.
+	Snippet string
+}
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	// failureSignatures are the error signatures of each ecosystem's tools in descending priority.
+	failureSignatures = map[Ecosystem][]*regexp.Regexp{
+		NPM: {
+			regexp.MustCompile(`^npm (ERR!|error) `),
+			regexp.MustCompile(`ERR_PNPM_`),
+			regexp.MustCompile(`^error Command failed`),
+		},
+		Maven: {
+			regexp.MustCompile(`^\[ERROR\] Failed to execute goal`),
+			regexp.MustCompile(`^\[ERROR\] `),
+			regexp.MustCompile(`BUILD FAILURE`),
+		},
+		PyPI: {
+			regexp.MustCompile(`^ERROR: `),
+			regexp.MustCompile(`^Traceback \(most recent call last\)`),
+			regexp.MustCompile(`^error: `),
+		},
+		CratesIO: {
+			regexp.MustCompile(`^error(\[E\d+\])?: `),
+		},
+	}
+	// commonFailureSignatures are tried for all ecosystems after the ecosystem's own.
+	commonFailureSignatures = []*regexp.Regexp{
+		regexp.MustCompile(`dpkg-buildpackage: error`),
+		regexp.MustCompile(`^dh_\S+: error`),
+		regexp.MustCompile(`^make(\[\d+\])?: \*\*\*`),
+		regexp.MustCompile(`(?i)\b(fatal|error):`),
+	}
+)
+
+const (
+	// failureContextLines is the maximum number of lines following the failing line included in its snippet.
+	failureContextLines = 4
+	// maxFailureSnippet is the maximum length of a snippet in bytes.
+	maxFailureSnippet = 500
+)
+
+// FindLogFailure returns the likely failure in the logs of a rebuild of an
+// artifact of the ecosystem, or nil if none is found.
+//
+// The first line matching the highest priority signature is chosen.
+func FindLogFailure(eco Ecosystem, logs string) *LogFailure {
+	lines := strings.Split(ansiEscape.ReplaceAllString(logs, ""), "\n")
+	signatures := append(slices.Clip(failureSignatures[eco]), commonFailureSignatures...)
+	for _, sig := range signatures {
+		for i, line := range lines {
+			if !sig.MatchString(line) {
+				continue
+			}
+			end := i + 1
+			for end < len(lines) && end <= i+failureContextLines && strings.TrimSpace(lines[end]) != "" {
+				end++
+			}
+			snippet := strings.Join(lines[i:end], "\n")
+			if len(snippet) > maxFailureSnippet {
+				snippet = snippet[:maxFailureSnippet]
+			}
+			return &LogFailure{Line: i, Snippet: snippet}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindLogFailure(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		eco  Ecosystem
+		logs []string
+		want *LogFailure
+	}{
+		{
+			name: "npm",
+			eco:  NPM,
+			logs: []string{
+				"> x@1.0.0 build",
+				"> tsc",
+				"src/index.ts(1,1): error TS2304: Cannot find name 'y'.",
+				"npm ERR! code ELIFECYCLE",
+				"npm ERR! errno 2",
+				"",
+				"npm ERR! A complete log of this run can be found in:",
+			},
+			want: &LogFailure{Line: 3, Snippet: "npm ERR! code ELIFECYCLE\nnpm ERR! errno 2"},
+		},
+		{
+			name: "maven goal preferred over build failure",
+			eco:  Maven,
+			logs: []string{
+				"[INFO] BUILD FAILURE",
+				"[ERROR] Failed to execute goal org.apache.maven.plugins:maven-compiler-plugin:3.8.1:compile",
+				"[ERROR] ",
+			},
+			want: &LogFailure{Line: 1, Snippet: "[ERROR] Failed to execute goal org.apache.maven.plugins:maven-compiler-plugin:3.8.1:compile\n[ERROR] "},
+		},
+		{
+			name: "cargo with ansi colors",
+			eco:  CratesIO,
+			logs: []string{
+				"   Compiling x v1.0.0",
+				"\x1b[1;31merror[E0425]\x1b[0m: cannot find value `y` in this scope",
+				" --> src/lib.rs:1:1",
+			},
+			want: &LogFailure{Line: 1, Snippet: "error[E0425]: cannot find value `y` in this scope\n --> src/lib.rs:1:1"},
+		},
+		{
+			name: "common signature",
+			eco:  PyPI,
+			logs: []string{
+				"dh_auto_build: error: make -j1 returned exit code 2",
+				"make: *** [debian/rules:4: build] Error 25",
+				"dpkg-buildpackage: error: debian/rules build subprocess returned exit status 2",
+			},
+			want: &LogFailure{Line: 2, Snippet: "dpkg-buildpackage: error: debian/rules build subprocess returned exit status 2"},
+		},
+		{
+			name: "no failure",
+			eco:  NPM,
+			logs: []string{"added 1 package", "done"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := FindLogFailure(tc.eco, strings.Join(tc.logs, "\n"))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("FindLogFailure() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
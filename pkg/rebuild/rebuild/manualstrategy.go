@@ -21,6 +21,10 @@ type ManualStrategy struct {
 	Build      string   `json:"build" yaml:"build,omitempty"`
 	SystemDeps []string `json:"system_deps" yaml:"system_deps,omitempty"`
 	OutputPath string   `json:"output_path" yaml:"output_path,omitempty"`
+	// Platform is the platform on which the build must be executed, Linux if unset.
+	Platform Platform `json:"platform,omitempty" yaml:"platform,omitempty"`
+	// Sandbox configures the isolation of the build, hardened if unset.
+	Sandbox *Sandbox `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
 }
 
 var _ Strategy = &ManualStrategy{}
@@ -31,12 +35,17 @@ func (s *ManualStrategy) GenerateFor(t Target, be BuildEnv) (Instructions, error
 	if err != nil {
 		return Instructions{}, err
 	}
-	return Instructions{
+	inst := Instructions{
 		Location:   s.Location,
 		Source:     src,
 		Deps:       s.Deps,
 		Build:      s.Build,
 		SystemDeps: s.SystemDeps,
 		OutputPath: s.OutputPath,
-	}, nil
+		Platform:   s.Platform,
+	}
+	if s.Sandbox != nil {
+		inst.Sandbox = *s.Sandbox
+	}
+	return inst, nil
 }
@@ -29,4 +29,16 @@ type BuildInfo struct {
 	BuildEnd    time.Time
 	BuildImages map[string]string
 	Steps       []*cloudbuild.BuildStep
+	// Resources are the limits applied to the build.
+	Resources Resources
+}
+
+// RemoteBuild identifies the Cloud Build execution of a remote rebuild.
+//
+// It is recorded when the build is started so that its logs can be followed
+// while it is in progress.
+type RemoteBuild struct {
+	// Operation is the name of the long-running operation tracking the build.
+	Operation string
+	BuildID   string
 }
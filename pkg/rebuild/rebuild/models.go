@@ -30,6 +30,7 @@ const (
 	PyPI     Ecosystem = "pypi"
 	CratesIO Ecosystem = "cratesio"
 	Maven    Ecosystem = "maven"
+	OCI      Ecosystem = "oci"
 )
 
 // Target is a single target we might attempt to rebuild.
@@ -40,6 +41,12 @@ type Target struct {
 	Artifact  string
 }
 
+// WithArtifact returns the Target for another artifact of the same package version.
+func (t Target) WithArtifact(artifact string) Target {
+	t.Artifact = artifact
+	return t
+}
+
 // ArchiveType provide the Target's archive.Format.
 func (t Target) ArchiveType() archive.Format {
 	switch t.Ecosystem {
@@ -70,9 +77,11 @@ func (t Target) ArchiveType() archive.Format {
 		if strings.HasSuffix(t.Artifact, ".jar") {
 			return archive.ZipFormat
 		} else if strings.HasSuffix(t.Artifact, ".pom") {
-			return archive.RawFormat
+			return archive.XMLFormat
 		}
 		return archive.UnknownFormat
+	case OCI:
+		return archive.OCIFormat
 	default:
 		return archive.UnknownFormat
 	}
@@ -82,6 +91,23 @@ func (t Target) ArchiveType() archive.Format {
 type Input struct {
 	Target   Target
 	Strategy Strategy
+	// Resources are the limits to apply to the build.
+	Resources Resources
+	// Siblings are the additional artifacts of the Target's version to be
+	// produced by the same build, sharing its source and build steps.
+	Siblings []string
+	// DisableInstallHooks prevents install-time scripts from running while the
+	// build's dependencies are installed.
+	DisableInstallHooks bool
+}
+
+// Targets returns the Target followed by the Target of each sibling artifact.
+func (i Input) Targets() []Target {
+	ts := []Target{i.Target}
+	for _, a := range i.Siblings {
+		ts = append(ts, i.Target.WithArtifact(a))
+	}
+	return ts
 }
 
 // Timings describe how long different sections of the rebuild took.
@@ -106,4 +132,11 @@ type Verdict struct {
 	Message  string
 	Strategy Strategy
 	Timings  Timings
+	// Risk, if present, scores the content differences of a mismatched rebuild.
+	Risk *RiskAssessment
+	// InstallHooks, if present, records the upstream install hooks that could
+	// have affected the artifact of a rebuild run with install hooks disabled.
+	InstallHooks *InstallHookReport
+	// LogFailure, if present, identifies the likely cause of a failure in the build logs.
+	LogFailure *LogFailure
 }
@@ -0,0 +1,116 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Platform is the operating system on which a rebuild must be executed.
+type Platform string
+
+// Platforms on which rebuilds can be executed.
+const (
+	// LinuxPlatform is the default platform, used by the Cloud Build and Kubernetes backends.
+	LinuxPlatform   Platform = "linux"
+	WindowsPlatform Platform = "windows"
+	MacOSPlatform   Platform = "macos"
+)
+
+// IsLinux returns whether p identifies the default Linux platform.
+func (p Platform) IsLinux() bool {
+	return p == "" || p == LinuxPlatform
+}
+
+// platformTimewarpHost is where platform runners serve timewarp.
+// NOTE: This differs from the container builds since the runner API occupies port 8080.
+const platformTimewarpHost = "localhost:8081"
+
+// PlatformRunRequest is a build to be executed on a host of a particular platform.
+type PlatformRunRequest struct {
+	Target Target
+	// Script is the bash script that produces the artifact in the "out" directory.
+	Script    string
+	Resources Resources
+}
+
+// PlatformRunResult is the outcome of a build executed on a platform runner.
+type PlatformRunResult struct {
+	Artifact []byte
+	Logs     string
+	// Runner identifies the host that executed the build.
+	Runner string
+}
+
+// PlatformRunner executes builds directly on a host of a particular platform.
+type PlatformRunner interface {
+	Run(context.Context, PlatformRunRequest) (*PlatformRunResult, error)
+}
+
+// makePlatformScript returns the script executed by a platform runner to produce t's artifact.
+func makePlatformScript(t Target, instructions Instructions) string {
+	var script Script
+	if len(instructions.SystemDeps) > 0 {
+		switch {
+		case instructions.Platform == WindowsPlatform:
+			script.Cmd(append([]string{"choco", "install", "-y", "--no-progress"}, instructions.SystemDeps...)...)
+		case instructions.Platform.IsLinux():
+			// NOTE: Linux runners use the same Alpine-based toolchain as the builder image.
+			script.Cmd(append([]string{"apk", "add", "--no-cache"}, instructions.SystemDeps...)...)
+		default:
+			script.Cmd(append([]string{"brew", "install"}, instructions.SystemDeps...)...)
+		}
+	}
+	script.Cmd("mkdir", "src", "out")
+	script.Cmd("cd", "src")
+	script.Raw(instructions.Source)
+	script.Raw(instructions.Deps)
+	script.Raw(instructions.Build)
+	script.Cmd("cp", instructions.OutputPath, "../out/"+t.Artifact)
+	return script.Bash()
+}
+
+// doPlatformBuild executes the script on the runner and stores its outputs.
+func doPlatformBuild(ctx context.Context, runner PlatformRunner, req PlatformRunRequest, opts RemoteOptions, bi *BuildInfo) (*PlatformRunResult, error) {
+	res, err := runner.Run(ctx, req)
+	if res != nil && res.Logs != "" {
+		if werr := writeAsset(ctx, opts.MetadataStore, Asset{Target: req.Target, Type: DebugLogsAsset}, []byte(res.Logs)); werr != nil {
+			return nil, errors.Wrap(werr, "writing logs")
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "running build")
+	}
+	if err := writeAsset(ctx, opts.MetadataStore, Asset{Target: req.Target, Type: RebuildAsset}, res.Artifact); err != nil {
+		return nil, errors.Wrap(err, "writing artifact")
+	}
+	bi.BuildEnd = time.Now()
+	return res, nil
+}
+
+func writeAsset(ctx context.Context, store AssetStore, a Asset, content []byte) error {
+	w, _, err := store.Writer(ctx, a)
+	if err != nil {
+		return errors.Wrap(err, "creating writer")
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,186 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type fakeRunner struct {
+	req    PlatformRunRequest
+	result *PlatformRunResult
+	err    error
+}
+
+func (r *fakeRunner) Run(ctx context.Context, req PlatformRunRequest) (*PlatformRunResult, error) {
+	r.req = req
+	return r.result, r.err
+}
+
+func TestMakePlatformScript(t *testing.T) {
+	target := Target{Ecosystem: PyPI, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0-cp312-cp312-win_amd64.whl"}
+	testCases := []struct {
+		name         string
+		instructions Instructions
+		want         string
+	}{
+		{
+			name: "windows",
+			instructions: Instructions{
+				SystemDeps: []string{"git", "python312"},
+				Source:     "git clone ...",
+				Deps:       "pip install build",
+				Build:      "python -m build --wheel",
+				OutputPath: "dist/pkg-1.0.0-cp312-cp312-win_amd64.whl",
+				Platform:   WindowsPlatform,
+			},
+			want: `set -eux
+choco install -y --no-progress git python312
+mkdir src out
+cd src
+git clone ...
+pip install build
+python -m build --wheel
+cp dist/pkg-1.0.0-cp312-cp312-win_amd64.whl ../out/pkg-1.0.0-cp312-cp312-win_amd64.whl
+`,
+		},
+		{
+			name: "macos without system deps",
+			instructions: Instructions{
+				Source:     "git clone ...",
+				Build:      "python -m build --wheel",
+				OutputPath: "dist/pkg.whl",
+				Platform:   MacOSPlatform,
+			},
+			want: `set -eux
+mkdir src out
+cd src
+git clone ...
+python -m build --wheel
+cp dist/pkg.whl ../out/pkg-1.0.0-cp312-cp312-win_amd64.whl
+`,
+		},
+		{
+			name: "linux",
+			instructions: Instructions{
+				SystemDeps: []string{"git", "python3"},
+				Source:     "git clone ...",
+				Build:      "python3 -m build --wheel",
+				OutputPath: "dist/pkg.whl",
+			},
+			want: `set -eux
+apk add --no-cache git python3
+mkdir src out
+cd src
+git clone ...
+python3 -m build --wheel
+cp dist/pkg.whl ../out/pkg-1.0.0-cp312-cp312-win_amd64.whl
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := makePlatformScript(target, tc.instructions)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("makePlatformScript() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRebuildRemotePlatform(t *testing.T) {
+	target := Target{Ecosystem: PyPI, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0-cp312-cp312-macosx_11_0_arm64.whl"}
+	strategy := &ManualStrategy{
+		Location:   Location{Repo: "https://github.com/example/pkg", Ref: "v1.0.0"},
+		Build:      "python -m build --wheel",
+		OutputPath: "dist/" + target.Artifact,
+		Platform:   MacOSPlatform,
+	}
+	t.Run("dispatched", func(t *testing.T) {
+		metadata := NewFilesystemAssetStore(memfs.New())
+		runner := &fakeRunner{result: &PlatformRunResult{Artifact: []byte("wheel"), Logs: "built\n", Runner: "mac-1"}}
+		opts := RemoteOptions{MetadataStore: metadata, PlatformRunners: map[Platform]PlatformRunner{MacOSPlatform: runner}}
+		if err := RebuildRemote(context.Background(), Input{Target: target, Strategy: strategy}, "id", opts); err != nil {
+			t.Fatalf("RebuildRemote() error: %v", err)
+		}
+		if runner.req.Target != target {
+			t.Errorf("Run() target = %v, want %v", runner.req.Target, target)
+		}
+		for typ, want := range map[AssetType]string{
+			RebuildAsset:    "wheel",
+			DebugLogsAsset:  "built\n",
+			DockerfileAsset: runner.req.Script,
+		} {
+			r, _, err := metadata.Reader(context.Background(), Asset{Target: target, Type: typ})
+			if err != nil {
+				t.Fatalf("Reading %s: %v", typ, err)
+			}
+			if diff := cmp.Diff(want, string(must(io.ReadAll(r)))); diff != "" {
+				t.Errorf("%s mismatch (-want +got):\n%s", typ, diff)
+			}
+		}
+		r, _, err := metadata.Reader(context.Background(), Asset{Target: target, Type: EnvironmentAsset})
+		if err != nil {
+			t.Fatalf("Reading environment: %v", err)
+		}
+		var env Environment
+		if err := json.NewDecoder(r).Decode(&env); err != nil {
+			t.Fatalf("Decoding environment: %v", err)
+		}
+		if diff := cmp.Diff(Environment{Platform: MacOSPlatform, Runner: "mac-1"}, env); diff != "" {
+			t.Errorf("Environment mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("failed build keeps logs", func(t *testing.T) {
+		metadata := NewFilesystemAssetStore(memfs.New())
+		runner := &fakeRunner{result: &PlatformRunResult{Logs: "error: missing compiler\n"}, err: errors.New("exit status 1")}
+		opts := RemoteOptions{MetadataStore: metadata, PlatformRunners: map[Platform]PlatformRunner{MacOSPlatform: runner}}
+		if err := RebuildRemote(context.Background(), Input{Target: target, Strategy: strategy}, "id", opts); err == nil {
+			t.Fatal("RebuildRemote() expected error")
+		}
+		if _, _, err := metadata.Reader(context.Background(), Asset{Target: target, Type: DebugLogsAsset}); err != nil {
+			t.Errorf("Reading logs: %v", err)
+		}
+	})
+	t.Run("linux runner", func(t *testing.T) {
+		linux := *strategy
+		linux.Platform = ""
+		metadata := NewFilesystemAssetStore(memfs.New())
+		runner := &fakeRunner{result: &PlatformRunResult{Artifact: []byte("wheel"), Runner: "linux-1"}}
+		opts := RemoteOptions{MetadataStore: metadata, PlatformRunners: map[Platform]PlatformRunner{LinuxPlatform: runner}}
+		if err := RebuildRemote(context.Background(), Input{Target: target, Strategy: &linux}, "id", opts); err != nil {
+			t.Fatalf("RebuildRemote() error: %v", err)
+		}
+		if runner.req.Target != target {
+			t.Errorf("Run() target = %v, want %v", runner.req.Target, target)
+		}
+		if _, _, err := metadata.Reader(context.Background(), Asset{Target: target, Type: RebuildAsset}); err != nil {
+			t.Errorf("Reading artifact: %v", err)
+		}
+	})
+	t.Run("no runner", func(t *testing.T) {
+		opts := RemoteOptions{MetadataStore: NewFilesystemAssetStore(memfs.New())}
+		if err := RebuildRemote(context.Background(), Input{Target: target, Strategy: strategy}, "id", opts); err == nil {
+			t.Error("RebuildRemote() expected error for unconfigured platform")
+		}
+	})
+}
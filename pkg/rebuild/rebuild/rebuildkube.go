@@ -0,0 +1,262 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/kube"
+	"github.com/pkg/errors"
+)
+
+// KubeOptions provides the configuration to execute rebuilds as Kubernetes Jobs.
+type KubeOptions struct {
+	Client    kube.Client
+	Namespace string
+	// ServiceAccount, if provided, is the identity of the rebuild pods.
+	// It must be able to write to the MetadataStore.
+	ServiceAccount string
+	// NodeSelector constrains the nodes to which rebuild pods are scheduled.
+	NodeSelector map[string]string
+	// UploaderImage is the image used to copy build outputs to the MetadataStore.
+	// It must provide gsutil for "gs://" stores or the AWS CLI for "s3://" stores.
+	UploaderImage string
+	// PollInterval is the period at which the Job's status is checked.
+	PollInterval time.Duration
+}
+
+const (
+	kubeOutDir  = "/out"
+	kubeUtilDir = "/util"
+	// kubeJobTTL is how long a finished Job and its logs are retained by the cluster.
+	kubeJobTTL = int32(time.Hour / time.Second)
+)
+
+type kubeScriptArgs struct {
+	Instructions
+	UseTimewarp bool
+	Artifact    string
+}
+
+var kubeBuildScriptTpl = template.Must(
+	template.New(
+		"kube build script",
+	).Funcs(template.FuncMap{
+		"indent": func(s string) string { return strings.ReplaceAll(s, "\n", "\n ") },
+		"join":   func(sep string, s []string) string { return strings.Join(s, sep) },
+		"quote":  Quote,
+	}).Parse(
+		// NOTE: This mirrors rebuildContainerTpl with the setup and build stages
+		// run in sequence within a single container.
+		`set -eux
+{{- if .UseTimewarp}}
+` + kubeUtilDir + `/timewarp -port 8080 &
+while ! nc -z localhost 8080;do sleep 1;done
+{{- end}}
+(
+ set -eux
+ apk add {{join " " .Instructions.SystemDeps}}
+ mkdir /src && cd /src
+ {{.Instructions.Source | indent}}
+ {{.Instructions.Deps | indent}}
+)
+(
+ set -eux
+ cd /src
+ {{.Instructions.Build | indent}}
+ cp /src/{{quote .Instructions.OutputPath}} ` + kubeOutDir + `/{{quote .Artifact}}
+{{- range $artifact, $path := .Instructions.SiblingOutputs}}
+ cp /src/{{quote $path}} ` + kubeOutDir + `/{{quote $artifact}}
+{{- end}}
+)
+cp ` + apkInstalledPath + ` ` + kubeOutDir + `/apk-installed
+`))
+
+// uploadCommand returns the command copying the local file src to the store URI dst.
+func uploadCommand(src, dst string) ([]string, error) {
+	switch {
+	case strings.HasPrefix(dst, "gs://"):
+		return []string{"gsutil", "cp", src, dst}, nil
+	case strings.HasPrefix(dst, "s3://"):
+		return []string{"aws", "s3", "cp", src, dst}, nil
+	default:
+		return nil, errors.Errorf("unsupported upload destination: %s", dst)
+	}
+}
+
+// kubeResources returns the container resources enforcing the rebuild's limits.
+func kubeResources(res Resources) kube.ResourceRequirements {
+	var rr kube.ResourceRequirements
+	if res.CPUs > 0 || res.MemoryMB > 0 {
+		rr.Limits = make(map[string]string)
+	}
+	if res.CPUs > 0 {
+		rr.Limits["cpu"] = fmt.Sprintf("%g", res.CPUs)
+	}
+	if res.MemoryMB > 0 {
+		rr.Limits["memory"] = fmt.Sprintf("%dMi", res.MemoryMB)
+	}
+	return rr
+}
+
+// makeJob returns the Job executing the instructions and uploading their
+// outputs, where rebuildUploadPaths maps each artifact built to its destination.
+func makeJob(t Target, instructions Instructions, images pinnedImages, rebuildUploadPaths map[string]string, packagesUploadPath string, res Resources, opts RemoteOptions) (*kube.Job, error) {
+	script := new(bytes.Buffer)
+	if err := kubeBuildScriptTpl.Execute(script, kubeScriptArgs{Instructions: instructions, UseTimewarp: opts.UseTimewarp, Artifact: t.Artifact}); err != nil {
+		return nil, errors.Wrap(err, "populating template")
+	}
+	var artifacts []string
+	for a := range rebuildUploadPaths {
+		artifacts = append(artifacts, a)
+	}
+	slices.Sort(artifacts)
+	type file struct{ src, dst string }
+	var files []file
+	for _, a := range artifacts {
+		files = append(files, file{path.Join(kubeOutDir, a), rebuildUploadPaths[a]})
+	}
+	files = append(files, file{path.Join(kubeOutDir, "apk-installed"), packagesUploadPath})
+	var upload Script
+	for _, u := range files {
+		cmd, err := uploadCommand(u.src, u.dst)
+		if err != nil {
+			return nil, err
+		}
+		upload.Cmd(cmd...)
+	}
+	out := kube.VolumeMount{Name: "out", MountPath: kubeOutDir}
+	util := kube.VolumeMount{Name: "util", MountPath: kubeUtilDir}
+	spec := kube.PodSpec{
+		RestartPolicy:      "Never",
+		ServiceAccountName: opts.Kube.ServiceAccount,
+		NodeSelector:       opts.Kube.NodeSelector,
+		Volumes: []kube.Volume{
+			{Name: out.Name, EmptyDir: &kube.EmptyDirVolumeSource{}},
+		},
+	}
+	buildMounts := []kube.VolumeMount{out}
+	if opts.UseTimewarp {
+		spec.Volumes = append(spec.Volumes, kube.Volume{Name: util.Name, EmptyDir: &kube.EmptyDirVolumeSource{}})
+		spec.InitContainers = append(spec.InitContainers, kube.Container{
+			Name:         "timewarp",
+			Image:        images.Ref(gsutilImage),
+			Command:      []string{"gsutil", "cp", "-P", fmt.Sprintf("gs://%s/timewarp", opts.UtilPrebuildBucket), path.Join(kubeUtilDir, "timewarp")},
+			VolumeMounts: []kube.VolumeMount{util},
+		})
+		buildMounts = append(buildMounts, util)
+	}
+	spec.InitContainers = append(spec.InitContainers, kube.Container{
+		Name:            "build",
+		Image:           images.Ref(builderImage),
+		Command:         []string{"/bin/sh", "-c", script.String()},
+		Resources:       kubeResources(res),
+		VolumeMounts:    buildMounts,
+		SecurityContext: instructions.Sandbox.securityContext(),
+	})
+	spec.Containers = []kube.Container{{
+		Name:         "upload",
+		Image:        opts.Kube.UploaderImage,
+		Command:      []string{"/bin/sh", "-c", upload.Bash()},
+		VolumeMounts: []kube.VolumeMount{out},
+	}}
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "oss-rebuild",
+		"app.kubernetes.io/component": "rebuild",
+		"oss-rebuild/ecosystem":       string(t.Ecosystem),
+	}
+	backoff := int32(0)
+	ttl := kubeJobTTL
+	jobSpec := kube.JobSpec{
+		BackoffLimit:            &backoff,
+		TTLSecondsAfterFinished: &ttl,
+		Template:                kube.PodTemplateSpec{Metadata: kube.ObjectMeta{Labels: labels}, Spec: spec},
+	}
+	if res.Timeout > 0 {
+		deadline := int64(res.Timeout.Seconds())
+		jobSpec.ActiveDeadlineSeconds = &deadline
+	}
+	return kube.NewJob(kube.ObjectMeta{GenerateName: "rebuild-", Namespace: opts.Kube.Namespace, Labels: labels}, jobSpec), nil
+}
+
+// collectKubeLogs copies the logs of the Job's containers to the DebugLogsAsset.
+func collectKubeLogs(ctx context.Context, job *kube.Job, t Target, opts RemoteOptions) error {
+	pods, err := opts.Kube.Client.ListPods(ctx, job.Metadata.Namespace, "job-name="+job.Metadata.Name)
+	if err != nil {
+		return errors.Wrap(err, "listing pods")
+	}
+	w, _, err := opts.MetadataStore.Writer(ctx, Asset{Target: t, Type: DebugLogsAsset})
+	if err != nil {
+		return errors.Wrap(err, "creating writer for logs")
+	}
+	spec := job.Spec.Template.Spec
+	for _, p := range pods {
+		for _, c := range append(append([]kube.Container{}, spec.InitContainers...), spec.Containers...) {
+			fmt.Fprintf(w, "==> %s/%s <==\n", p.Metadata.Name, c.Name)
+			r, err := opts.Kube.Client.PodLogs(ctx, job.Metadata.Namespace, p.Metadata.Name, c.Name)
+			if err != nil {
+				// NOTE: Containers that never started have no logs.
+				fmt.Fprintf(w, "logs unavailable: %v\n", err)
+				continue
+			}
+			_, err = io.Copy(w, r)
+			r.Close()
+			if err != nil {
+				w.Close()
+				return errors.Wrap(err, "copying logs")
+			}
+		}
+	}
+	return w.Close()
+}
+
+func doKubeBuild(ctx context.Context, job *kube.Job, t Target, opts RemoteOptions, bi *BuildInfo) error {
+	created, err := opts.Kube.Client.CreateJob(ctx, job)
+	if err != nil {
+		return errors.Wrap(err, "creating job")
+	}
+	interval := opts.Kube.PollInterval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	done, waitErr := kube.WaitJob(ctx, opts.Kube.Client, created.Metadata.Namespace, created.Metadata.Name, interval)
+	if err := collectKubeLogs(ctx, created, t, opts); err != nil {
+		// NOTE: Logs aid debugging but are not required for the rebuild.
+		log.Println(errors.Wrap(err, "collecting job logs"))
+	}
+	if waitErr != nil {
+		return errors.Wrap(waitErr, "doing build")
+	}
+	bi.BuildID = created.Metadata.Name
+	bi.BuildEnd = time.Now()
+	if done.Status.CompletionTime != nil {
+		bi.BuildEnd = *done.Status.CompletionTime
+	}
+	bi.BuildImages = make(map[string]string)
+	spec := created.Spec.Template.Spec
+	for _, c := range append(append([]kube.Container{}, spec.InitContainers...), spec.Containers...) {
+		bi.BuildImages[c.Name] = c.Image
+	}
+	return nil
+}
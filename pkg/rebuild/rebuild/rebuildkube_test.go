@@ -0,0 +1,180 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/kube"
+	"github.com/google/oss-rebuild/internal/kube/kubetest"
+)
+
+func TestKubeBuildScriptTpl(t *testing.T) {
+	var got strings.Builder
+	err := kubeBuildScriptTpl.Execute(&got, kubeScriptArgs{
+		Instructions: Instructions{
+			SystemDeps: []string{"git", "npm"},
+			Source:     "git clone foo .\ngit checkout bar",
+			Deps:       "npm install",
+			Build:      "npm pack",
+			OutputPath: "foo-0.0.1.tgz",
+		},
+		Artifact: "foo-0.0.1.tgz",
+	})
+	if err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	want := `set -eux
+(
+ set -eux
+ apk add git npm
+ mkdir /src && cd /src
+ git clone foo .
+ git checkout bar
+ npm install
+)
+(
+ set -eux
+ cd /src
+ npm pack
+ cp /src/foo-0.0.1.tgz /out/foo-0.0.1.tgz
+)
+cp /lib/apk/db/installed /out/apk-installed
+`
+	if diff := cmp.Diff(want, got.String()); diff != "" {
+		t.Errorf("kubeBuildScriptTpl mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMakeJob(t *testing.T) {
+	target := Target{Ecosystem: NPM, Package: "pkg", Version: "version", Artifact: "pkg-version.tgz"}
+	opts := RemoteOptions{Kube: &KubeOptions{Namespace: "rebuild", ServiceAccount: "builder", NodeSelector: map[string]string{"pool": "rebuild"}, UploaderImage: "amazon/aws-cli"}}
+	res := Resources{Timeout: time.Hour, CPUs: 2, MemoryMB: 4096}
+	job, err := makeJob(target, Instructions{Build: "npm pack", OutputPath: "pkg-version.tgz"}, nil, map[string]string{target.Artifact: "s3://bucket/pkg-version.tgz"}, "s3://bucket/apk-installed", res, opts)
+	if err != nil {
+		t.Fatalf("makeJob() error: %v", err)
+	}
+	if job.Metadata.Namespace != "rebuild" || job.Metadata.GenerateName != "rebuild-" {
+		t.Errorf("unexpected metadata: %+v", job.Metadata)
+	}
+	if job.Spec.ActiveDeadlineSeconds == nil || *job.Spec.ActiveDeadlineSeconds != 3600 {
+		t.Errorf("unexpected deadline: %v", job.Spec.ActiveDeadlineSeconds)
+	}
+	spec := job.Spec.Template.Spec
+	if diff := cmp.Diff(map[string]string{"pool": "rebuild"}, spec.NodeSelector); diff != "" {
+		t.Errorf("NodeSelector mismatch (-want +got):\n%s", diff)
+	}
+	if spec.ServiceAccountName != "builder" || spec.RestartPolicy != "Never" {
+		t.Errorf("unexpected pod spec: %+v", spec)
+	}
+	if len(spec.InitContainers) != 1 || spec.InitContainers[0].Image != builderImage {
+		t.Fatalf("unexpected init containers: %+v", spec.InitContainers)
+	}
+	if diff := cmp.Diff(kube.ResourceRequirements{Limits: map[string]string{"cpu": "2", "memory": "4096Mi"}}, spec.InitContainers[0].Resources); diff != "" {
+		t.Errorf("Resources mismatch (-want +got):\n%s", diff)
+	}
+	noEscalation := false
+	wantSecurity := &kube.SecurityContext{
+		AllowPrivilegeEscalation: &noEscalation,
+		SeccompProfile:           &kube.SeccompProfile{Type: "RuntimeDefault"},
+		AppArmorProfile:          &kube.AppArmorProfile{Type: "RuntimeDefault"},
+	}
+	if diff := cmp.Diff(wantSecurity, spec.InitContainers[0].SecurityContext); diff != "" {
+		t.Errorf("SecurityContext mismatch (-want +got):\n%s", diff)
+	}
+	unconfined, err := makeJob(target, Instructions{Build: "npm pack", OutputPath: "pkg-version.tgz", Sandbox: Sandbox{Unconfined: true}}, nil, map[string]string{target.Artifact: "s3://bucket/pkg-version.tgz"}, "s3://bucket/apk-installed", res, opts)
+	if err != nil {
+		t.Fatalf("makeJob() error: %v", err)
+	}
+	if sc := unconfined.Spec.Template.Spec.InitContainers[0].SecurityContext; sc != nil {
+		t.Errorf("unconfined SecurityContext = %+v, want nil", sc)
+	}
+	wantUpload := []string{"/bin/sh", "-c", "set -eux\naws s3 cp /out/pkg-version.tgz s3://bucket/pkg-version.tgz\naws s3 cp /out/apk-installed s3://bucket/apk-installed\n"}
+	if len(spec.Containers) != 1 {
+		t.Fatalf("unexpected containers: %+v", spec.Containers)
+	}
+	if diff := cmp.Diff(wantUpload, spec.Containers[0].Command); diff != "" {
+		t.Errorf("upload command mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := makeJob(target, Instructions{}, nil, map[string]string{target.Artifact: "file:///tmp/pkg-version.tgz"}, "file:///tmp/apk-installed", res, opts); err == nil {
+		t.Error("makeJob() expected error for unsupported store")
+	}
+}
+
+func TestDoKubeBuild(t *testing.T) {
+	target := Target{Ecosystem: NPM, Package: "pkg", Version: "version", Artifact: "pkg-version.tgz"}
+	finished := must(time.Parse(time.RFC3339, "2024-05-08T15:23:00Z"))
+	var polls int
+	client := &kubetest.MockClient{
+		CreateJobFunc: func(ctx context.Context, job *kube.Job) (*kube.Job, error) {
+			created := *job
+			created.Metadata.Name = "rebuild-abcde"
+			return &created, nil
+		},
+		GetJobFunc: func(ctx context.Context, namespace, name string) (*kube.Job, error) {
+			if namespace != "rebuild" || name != "rebuild-abcde" {
+				t.Errorf("GetJob(%s, %s) unexpected job", namespace, name)
+			}
+			polls++
+			if polls < 2 {
+				return &kube.Job{Status: kube.JobStatus{Active: 1}}, nil
+			}
+			return &kube.Job{Status: kube.JobStatus{CompletionTime: &finished, Conditions: []kube.JobCondition{{Type: "Complete", Status: "True"}}}}, nil
+		},
+		ListPodsFunc: func(ctx context.Context, namespace, selector string) ([]kube.Pod, error) {
+			if selector != "job-name=rebuild-abcde" {
+				t.Errorf("ListPods() unexpected selector: %s", selector)
+			}
+			return []kube.Pod{{Metadata: kube.ObjectMeta{Name: "rebuild-abcde-xyz"}}}, nil
+		},
+		PodLogsFunc: func(ctx context.Context, namespace, pod, container string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(container + " output\n")), nil
+		},
+	}
+	metadata := NewFilesystemAssetStore(memfs.New())
+	opts := RemoteOptions{MetadataStore: metadata, Kube: &KubeOptions{Client: client, Namespace: "rebuild", UploaderImage: "gcr.io/cloud-builders/gsutil", PollInterval: time.Millisecond}}
+	job, err := makeJob(target, Instructions{}, nil, map[string]string{target.Artifact: "gs://bucket/pkg-version.tgz"}, "gs://bucket/apk-installed", Resources{}, opts)
+	if err != nil {
+		t.Fatalf("makeJob() error: %v", err)
+	}
+	bi := &BuildInfo{Target: target}
+	if err := doKubeBuild(context.Background(), job, target, opts, bi); err != nil {
+		t.Fatalf("doKubeBuild() error: %v", err)
+	}
+	want := &BuildInfo{
+		Target:      target,
+		BuildID:     "rebuild-abcde",
+		BuildEnd:    finished,
+		BuildImages: map[string]string{"build": builderImage, "upload": "gcr.io/cloud-builders/gsutil"},
+	}
+	if diff := cmp.Diff(want, bi); diff != "" {
+		t.Errorf("BuildInfo mismatch (-want +got):\n%s", diff)
+	}
+	r, _, err := metadata.Reader(context.Background(), Asset{Target: target, Type: DebugLogsAsset})
+	if err != nil {
+		t.Fatalf("Reading logs: %v", err)
+	}
+	logs := string(must(io.ReadAll(r)))
+	wantLogs := "==> rebuild-abcde-xyz/build <==\nbuild output\n==> rebuild-abcde-xyz/upload <==\nupload output\n"
+	if diff := cmp.Diff(wantLogs, logs); diff != "" {
+		t.Errorf("logs mismatch (-want +got):\n%s", diff)
+	}
+}
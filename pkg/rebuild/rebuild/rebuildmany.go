@@ -121,6 +121,9 @@ func RebuildMany(ctx context.Context, rebuilder Rebuilder, inputs []Input, regis
 			verdicts = append(verdicts, *verdict)
 		}
 		resetLogger()
+		if v := &verdicts[len(verdicts)-1]; v.Message != "" {
+			v.LogFailure = FindLogFailure(t.Ecosystem, logbuf.String())
+		}
 		{
 			asset := Asset{Type: DebugLogsAsset, Target: t}
 			w, _, err := localAssets.Writer(ctx, asset)
@@ -25,9 +25,28 @@ import (
 	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/google/oss-rebuild/internal/gitx"
+	"github.com/google/oss-rebuild/pkg/archive"
 	"github.com/pkg/errors"
 )
 
+// rebuildWithLimits runs the build subject to the provided resource limits.
+func rebuildWithLimits(ctx context.Context, r Rebuilder, t Target, inst Instructions, fs billy.Filesystem, res Resources) error {
+	if res != (Resources{}) {
+		log.Printf("[%s] Applying resource limits: %+v\n", t.Package, res)
+	}
+	ctx = context.WithValue(ctx, ResourcesID, res)
+	if res.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, res.Timeout)
+		defer cancel()
+	}
+	err := r.Rebuild(ctx, t, inst, fs)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.Errorf("build exceeded timeout of %v", res.Timeout)
+	}
+	return err
+}
+
 // RebuildOne runs a rebuild for the given package artifact.
 func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux, rcfg *RepoConfig, fs billy.Filesystem, s storage.Storer, assets AssetStore) (*Verdict, []Asset, error) {
 	t := input.Target
@@ -100,7 +119,7 @@ func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux,
 		}
 	}
 	inferenceTime := time.Since(inferenceStart)
-	rbenv := BuildEnv{HasRepo: true}
+	rbenv := BuildEnv{HasRepo: true, DisableInstallHooks: input.DisableInstallHooks}
 	if tw, ok := ctx.Value(TimewarpID).(string); ok {
 		rbenv.TimewarpHost = tw
 	}
@@ -109,7 +128,7 @@ func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux,
 		return nil, nil, errors.Wrap(err, "failed to generate strategy")
 	}
 	buildStart := time.Now()
-	err = r.Rebuild(ctx, t, inst, fs)
+	err = rebuildWithLimits(ctx, r, t, inst, fs, input.Resources)
 	buildTime := time.Since(buildStart)
 	if err != nil {
 		return nil, nil, err
@@ -133,6 +152,34 @@ func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux,
 	} else if cmpErr != nil {
 		msg = cmpErr.Error()
 	}
+	outAssets := []Asset{rb, up}
+	if a, err := analyzeTimestamps(ctx, t, mux, s, inst, assets); err != nil {
+		log.Printf("[%s] Failed to analyze timestamps: %v\n", t.Package, err)
+	} else {
+		outAssets = append(outAssets, *a)
+	}
+	var hooks *InstallHookReport
+	if input.DisableInstallHooks {
+		hooks, err = upstreamInstallHooks(ctx, t, up, assets)
+		if err != nil {
+			log.Printf("[%s] Failed to analyze install hooks: %v\n", t.Package, err)
+		}
+	}
+	var risk *RiskAssessment
+	if cmpErr != nil {
+		a, ra, suspicious, err := analyzeMismatch(ctx, t, rb, up, assets)
+		if err != nil {
+			log.Printf("[%s] Failed to analyze mismatch: %v\n", t.Package, err)
+		} else {
+			risk = ra
+			if a != nil {
+				outAssets = append(outAssets, *a)
+			}
+			if suspicious {
+				msg = "suspicious upstream-only files: " + msg
+			}
+		}
+	}
 	return &Verdict{
 		Target:   t,
 		Message:  msg,
@@ -143,5 +190,44 @@ func RebuildOne(ctx context.Context, r Rebuilder, input Input, mux RegistryMux,
 			Infer:         inferenceTime,
 			Build:         buildTime,
 		},
-	}, []Asset{rb, up}, nil
+		Risk:         risk,
+		InstallHooks: hooks,
+	}, outAssets, nil
+}
+
+// analyzeMismatch stores an AdditionsReport when upstream contains files
+// absent from the rebuild and scores the differing content for risk.
+func analyzeMismatch(ctx context.Context, t Target, rb, up Asset, assets AssetStore) (*Asset, *RiskAssessment, bool, error) {
+	csRB, csUP, err := Summarize(ctx, t, rb, up, assets)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	_, diffs, upOnly := csRB.Diff(csUP)
+	rbFiles, err := readAssetEntries(ctx, t, rb, assets, diffs)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	upFiles, err := readAssetEntries(ctx, t, up, assets, append(upOnly, diffs...))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	risk := ScoreRisk(rbFiles, upFiles)
+	report := AnalyzeAdditions(t, csRB, csUP)
+	if len(report.Additions) == 0 {
+		return nil, risk, false, nil
+	}
+	a, err := WriteAdditionsReport(ctx, assets, report)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return &a, risk, report.Suspicious(), nil
+}
+
+func readAssetEntries(ctx context.Context, t Target, a Asset, assets AssetStore, names []string) (map[string][]byte, error) {
+	r, _, err := assets.Reader(ctx, a)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening %s asset", a.Type)
+	}
+	defer r.Close()
+	return archive.ReadEntries(r, t.ArchiveType(), names)
 }
@@ -19,9 +19,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log"
 	"os"
 	"path"
+	"slices"
 	"strings"
 	"text/template"
 	"time"
@@ -31,7 +32,7 @@ import (
 	"google.golang.org/api/cloudbuild/v1"
 )
 
-// RemoteOptions provides the configuration to execute rebuilds on Cloud Build.
+// RemoteOptions provides the configuration to execute rebuilds on Cloud Build or Kubernetes.
 type RemoteOptions struct {
 	GCBClient           gcb.Client
 	Project             string
@@ -41,12 +42,44 @@ type RemoteOptions struct {
 	UtilPrebuildBucket  string
 	// TODO: Consider moving this to Strategy.
 	UseTimewarp bool
+	// ImageResolver, if provided, is used to pin the builder's base images to digests.
+	ImageResolver ImageResolver
+	// RemoteBuildStore, if provided, is where the RemoteBuild is recorded instead of MetadataStore.
+	RemoteBuildStore AssetStore
+	// Kube, if provided, executes rebuilds as Kubernetes Jobs instead of on Cloud Build.
+	Kube *KubeOptions
+	// PlatformRunners execute rebuilds whose instructions require a platform other than Linux.
+	// A runner for LinuxPlatform, if provided, executes Linux rebuilds in place of Cloud Build or Kubernetes.
+	PlatformRunners map[Platform]PlatformRunner
+	// BuildCache, if provided, reuses the outputs of identical builds with pinned base images.
+	BuildCache *BuildCache
+	// BypassBuildCache executes the build even if a cached result exists.
+	// The result of the build still replaces the cached one.
+	BypassBuildCache bool
 }
 
+const (
+	builderImage = "alpine:3.19"
+	gsutilImage  = "gcr.io/cloud-builders/gsutil"
+	// apkInstalledPath is the location of the apk package database in the builder image.
+	apkInstalledPath = "/lib/apk/db/installed"
+)
+
 type rebuildContainerArgs struct {
 	Instructions
 	UseTimewarp        bool
 	UtilPrebuildBucket string
+	Images             pinnedImages
+}
+
+// BuilderImage returns the reference to use for the builder's base image.
+func (a rebuildContainerArgs) BuilderImage() string {
+	return a.Images.Ref(builderImage)
+}
+
+// GsutilImage returns the reference to use for the image providing gsutil.
+func (a rebuildContainerArgs) GsutilImage() string {
+	return a.Images.Ref(gsutilImage)
 }
 
 var rebuildContainerTpl = template.Must(
@@ -55,14 +88,15 @@ var rebuildContainerTpl = template.Must(
 	).Funcs(template.FuncMap{
 		"indent": func(s string) string { return strings.ReplaceAll(s, "\n", "\n ") },
 		"join":   func(sep string, s []string) string { return strings.Join(s, sep) },
+		"quote":  Quote,
 	}).Parse(
 		// NOTE: For syntax docs, see https://docs.docker.com/build/dockerfile/release-notes/
 		`#syntax=docker/dockerfile:1.4
 {{- if .UseTimewarp}}
-FROM gcr.io/cloud-builders/gsutil AS timewarp_provider
+FROM {{.GsutilImage}} AS timewarp_provider
 RUN gsutil cp -P gs://{{.UtilPrebuildBucket}}/timewarp .
 {{- end}}
-FROM alpine:3.19
+FROM {{.BuilderImage}}
 {{- if .UseTimewarp}}
 COPY --from=timewarp_provider ./timewarp .
 {{- end}}
@@ -80,57 +114,86 @@ EOF
 RUN cat <<'EOF' >build
  set -eux
  {{.Instructions.Build | indent}}
- mkdir /out && cp /src/{{.Instructions.OutputPath}} /out/
+ mkdir -p /out && cp /src/{{quote .Instructions.OutputPath}} /out/
+{{- range $artifact, $path := .Instructions.SiblingOutputs}}
+ cp /src/{{quote $path}} /out/{{quote $artifact}}
+{{- end}}
 EOF
 WORKDIR "/src"
 ENTRYPOINT ["/bin/sh","/build"]
 `))
 
-func makeBuild(t Target, dockerfile, imageUploadPath, rebuildUploadPath string, opts RemoteOptions) *cloudbuild.Build {
+// makeBuild returns the Cloud Build executing the Dockerfile and uploading its
+// outputs, where rebuildUploadPaths maps each artifact built to its destination.
+func makeBuild(dockerfile, imageUploadPath string, rebuildUploadPaths map[string]string, packagesUploadPath string, sandbox Sandbox, res Resources, opts RemoteOptions) *cloudbuild.Build {
+	args := []string{"run", "--name=container"}
+	args = append(args, sandbox.dockerArgs()...)
+	args = append(args, res.DockerArgs()...)
+	runStep := &cloudbuild.BuildStep{
+		Name: "gcr.io/cloud-builders/docker",
+		Args: append(args, "img"),
+	}
+	if res.Timeout > 0 {
+		runStep.Timeout = fmt.Sprintf("%ds", int64(res.Timeout.Seconds()))
+	}
+	var artifacts []string
+	for a := range rebuildUploadPaths {
+		artifacts = append(artifacts, a)
+	}
+	slices.Sort(artifacts)
+	steps := []*cloudbuild.BuildStep{
+		{
+			Name:   "gcr.io/cloud-builders/docker",
+			Script: "cat <<'EOS' | docker buildx build --tag=img -\n" + dockerfile + "\nEOS",
+		},
+		runStep,
+	}
+	for _, a := range artifacts {
+		steps = append(steps, &cloudbuild.BuildStep{
+			Name: "gcr.io/cloud-builders/docker",
+			Args: []string{"cp", "container:" + path.Join("/out", a), path.Join("/workspace", a)},
+		})
+	}
+	var upload Script
+	upload.Cmd("gsutil", "cp", "-P", fmt.Sprintf("gs://%s/gsutil_writeonly", opts.UtilPrebuildBucket), ".")
+	upload.Cmd("./gsutil_writeonly", "cp", "/workspace/image.tgz", imageUploadPath)
+	for _, a := range artifacts {
+		upload.Cmd("./gsutil_writeonly", "cp", path.Join("/workspace", a), rebuildUploadPaths[a])
+	}
+	upload.Cmd("./gsutil_writeonly", "cp", "/workspace/apk-installed", packagesUploadPath)
+	steps = append(steps,
+		&cloudbuild.BuildStep{
+			Name: "gcr.io/cloud-builders/docker",
+			Args: []string{"cp", "container:" + apkInstalledPath, "/workspace/apk-installed"},
+		},
+		&cloudbuild.BuildStep{
+			Name:   "gcr.io/cloud-builders/docker",
+			Script: "docker save img | gzip > /workspace/image.tgz",
+		},
+		upload.BuildStep(gsutilImage),
+	)
 	return &cloudbuild.Build{
 		LogsBucket:     opts.LogsBucket,
 		Options:        &cloudbuild.BuildOptions{Logging: "GCS_ONLY"},
 		ServiceAccount: opts.BuildServiceAccount,
-		Steps: []*cloudbuild.BuildStep{
-			{
-				Name:   "gcr.io/cloud-builders/docker",
-				Script: "cat <<'EOS' | docker buildx build --tag=img -\n" + dockerfile + "\nEOS",
-			},
-			{
-				Name: "gcr.io/cloud-builders/docker",
-				Args: []string{"run", "--name=container", "img"},
-			},
-			{
-				Name: "gcr.io/cloud-builders/docker",
-				Args: []string{"cp", "container:" + path.Join("/out", t.Artifact), path.Join("/workspace", t.Artifact)},
-			},
-			{
-				Name:   "gcr.io/cloud-builders/docker",
-				Script: "docker save img | gzip > /workspace/image.tgz",
-			},
-			{
-				Name: "gcr.io/cloud-builders/gsutil",
-				Script: fmt.Sprintf(
-					"gsutil cp -P gs://%s/gsutil_writeonly . && ./gsutil_writeonly %s && ./gsutil_writeonly %s",
-					opts.UtilPrebuildBucket,
-					strings.Join([]string{
-						"cp",
-						"/workspace/image.tgz",
-						imageUploadPath,
-					}, " "),
-					strings.Join([]string{
-						"cp",
-						path.Join("/workspace", t.Artifact),
-						rebuildUploadPath,
-					}, " "),
-				),
-			},
-		},
+		Steps:          steps,
 	}
 }
 
 func doCloudBuild(ctx context.Context, client gcb.Client, build *cloudbuild.Build, opts RemoteOptions, bi *BuildInfo) error {
-	build, err := gcb.DoBuild(ctx, client, opts.Project, build)
+	op, err := client.CreateBuild(ctx, opts.Project, build)
+	if err != nil {
+		return errors.Wrap(err, "creating build")
+	}
+	store := opts.RemoteBuildStore
+	if store == nil {
+		store = opts.MetadataStore
+	}
+	if err := recordRemoteBuild(ctx, op, bi.Target, store); err != nil {
+		// NOTE: The record only enables following logs so the build can proceed without it.
+		log.Println(errors.Wrap(err, "recording remote build"))
+	}
+	build, err = gcb.WaitBuild(ctx, client, op)
 	if err != nil {
 		return errors.Wrap(err, "doing build")
 	}
@@ -147,20 +210,59 @@ func doCloudBuild(ctx context.Context, client gcb.Client, build *cloudbuild.Buil
 	return nil
 }
 
-func makeDockerfile(input Input, opts RemoteOptions) (string, error) {
-	env := BuildEnv{HasRepo: false, PreferPreciseToolchain: true}
+func recordRemoteBuild(ctx context.Context, op *cloudbuild.Operation, t Target, metadata AssetStore) error {
+	id, err := gcb.BuildID(op)
+	if err != nil {
+		return err
+	}
+	w, _, err := metadata.Writer(ctx, Asset{Target: t, Type: RemoteBuildAsset})
+	if err != nil {
+		return errors.Wrap(err, "creating writer")
+	}
+	if err := json.NewEncoder(w).Encode(RemoteBuild{Operation: op.Name, BuildID: id}); err != nil {
+		w.Close()
+		return errors.Wrap(err, "writing remote build")
+	}
+	return w.Close()
+}
+
+// remoteInstructions returns the instructions for executing the input on a remote builder.
+// The instructions for any sibling artifacts are merged into those of the Target.
+func remoteInstructions(input Input, opts RemoteOptions) (Instructions, error) {
+	env := BuildEnv{HasRepo: false, PreferPreciseToolchain: true, DisableInstallHooks: input.DisableInstallHooks}
 	if opts.UseTimewarp {
 		env.TimewarpHost = "localhost:8080"
 	}
 	instructions, err := input.Strategy.GenerateFor(input.Target, env)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to generate strategy")
+		return Instructions{}, errors.Wrap(err, "failed to generate strategy")
 	}
+	if _, ok := opts.runnerFor(instructions.Platform); ok && opts.UseTimewarp {
+		env.TimewarpHost = platformTimewarpHost
+		instructions, err = input.Strategy.GenerateFor(input.Target, env)
+		if err != nil {
+			return Instructions{}, errors.Wrap(err, "failed to generate strategy")
+		}
+	}
+	for _, t := range input.Targets()[1:] {
+		sib, err := input.Strategy.GenerateFor(t, env)
+		if err != nil {
+			return Instructions{}, errors.Wrapf(err, "failed to generate strategy for %s", t.Artifact)
+		}
+		if err := instructions.MergeSibling(t.Artifact, sib); err != nil {
+			return Instructions{}, err
+		}
+	}
+	return instructions, nil
+}
+
+func makeDockerfile(instructions Instructions, images pinnedImages, opts RemoteOptions) (string, error) {
 	dockerfile := new(bytes.Buffer)
-	err = rebuildContainerTpl.Execute(dockerfile, rebuildContainerArgs{
+	err := rebuildContainerTpl.Execute(dockerfile, rebuildContainerArgs{
 		UseTimewarp:        opts.UseTimewarp,
 		UtilPrebuildBucket: opts.UtilPrebuildBucket,
 		Instructions:       instructions,
+		Images:             images,
 	})
 	if err != nil {
 		return "", errors.Wrap(err, "populating template")
@@ -171,18 +273,54 @@ func makeDockerfile(input Input, opts RemoteOptions) (string, error) {
 // RebuildRemote executes the given target strategy on a remote builder.
 func RebuildRemote(ctx context.Context, input Input, id string, opts RemoteOptions) error {
 	t := input.Target
-	bi := BuildInfo{Target: t, ID: id, Builder: os.Getenv("K_REVISION"), BuildStart: time.Now()}
-	dockerfile, err := makeDockerfile(input, opts)
+	if err := input.Resources.Validate(); err != nil {
+		return errors.Wrap(err, "validating resources")
+	}
+	bi := BuildInfo{Target: t, ID: id, Builder: os.Getenv("K_REVISION"), BuildStart: time.Now(), Resources: input.Resources}
+	instructions, err := remoteInstructions(input, opts)
+	if err != nil {
+		return err
+	}
+	if _, ok := opts.runnerFor(instructions.Platform); ok || !instructions.Platform.IsLinux() {
+		if len(input.Siblings) > 0 {
+			return errors.New("sibling artifacts are not supported by platform runners")
+		}
+		return rebuildOnPlatform(ctx, t, instructions, input.Resources, opts, bi)
+	}
+	var images pinnedImages
+	if opts.ImageResolver != nil {
+		refs := []string{builderImage}
+		if opts.UseTimewarp {
+			refs = append(refs, gsutilImage)
+		}
+		images, err = resolveImages(ctx, opts.ImageResolver, refs...)
+		if err != nil {
+			return errors.Wrap(err, "pinning base images")
+		}
+	}
+	dockerfile, err := makeDockerfile(instructions, images, opts)
 	if err != nil {
 		return errors.Wrap(err, "creating dockerfile")
 	}
-	{
-		w, _, err := opts.MetadataStore.Writer(ctx, Asset{Target: t, Type: DockerfileAsset})
+	var cacheKey string
+	// NOTE: The build cache stores the outputs of a single artifact.
+	if opts.BuildCache != nil && len(images) > 0 && len(input.Siblings) == 0 {
+		cacheKey, err = buildCacheKey(t, dockerfile)
 		if err != nil {
-			return errors.Wrap(err, "creating writer for Dockerfile")
+			return errors.Wrap(err, "creating build cache key")
 		}
-		defer w.Close()
-		if _, err := io.WriteString(w, dockerfile); err != nil {
+		if !opts.BypassBuildCache {
+			if ok, err := opts.BuildCache.Restore(ctx, cacheKey, t, opts.MetadataStore); err != nil {
+				// NOTE: A cache failure only costs a rebuild.
+				log.Println(errors.Wrap(err, "restoring cached build"))
+			} else if ok {
+				log.Printf("Reusing cached build %s for %v", cacheKey, t)
+				return nil
+			}
+		}
+	}
+	for _, t := range input.Targets() {
+		if err := writeAsset(ctx, opts.MetadataStore, Asset{Target: t, Type: DockerfileAsset}, []byte(dockerfile)); err != nil {
 			return errors.Wrap(err, "writing Dockerfile")
 		}
 	}
@@ -192,16 +330,99 @@ func RebuildRemote(ctx context.Context, input Input, id string, opts RemoteOptio
 	if err != nil {
 		return errors.Wrap(err, "creating dummy writer for container image")
 	}
-	_, rebuildUploadPath, err := opts.MetadataStore.Writer(ctx, Asset{Target: t, Type: RebuildAsset})
+	rebuildUploadPaths := make(map[string]string)
+	for _, t := range input.Targets() {
+		_, rebuildUploadPaths[t.Artifact], err = opts.MetadataStore.Writer(ctx, Asset{Target: t, Type: RebuildAsset})
+		if err != nil {
+			return errors.Wrap(err, "creating dummy writer for rebuild")
+		}
+	}
+	_, packagesUploadPath, err := opts.MetadataStore.Writer(ctx, Asset{Target: t, Type: InstalledPackagesAsset})
 	if err != nil {
-		return errors.Wrap(err, "creating dummy writer for rebuild")
+		return errors.Wrap(err, "creating dummy writer for installed packages")
+	}
+	if opts.Kube != nil {
+		// NOTE: Kubernetes builds run the instructions directly so no container image is produced.
+		job, err := makeJob(t, instructions, images, rebuildUploadPaths, packagesUploadPath, input.Resources, opts)
+		if err != nil {
+			return errors.Wrap(err, "creating job")
+		}
+		if err := doKubeBuild(ctx, job, t, opts, &bi); err != nil {
+			return errors.Wrap(err, "performing build")
+		}
+	} else {
+		build := makeBuild(dockerfile, imageUploadPath, rebuildUploadPaths, packagesUploadPath, instructions.Sandbox, input.Resources, opts)
+		if err := doCloudBuild(ctx, opts.GCBClient, build, opts, &bi); err != nil {
+			return errors.Wrap(err, "performing build")
+		}
+	}
+	env := Environment{Images: images}
+	{
+		r, _, err := opts.MetadataStore.Reader(ctx, Asset{Target: t, Type: InstalledPackagesAsset})
+		if err != nil {
+			return errors.Wrap(err, "opening installed packages")
+		}
+		defer r.Close()
+		env.Packages, err = parseApkInstalled(r)
+		if err != nil {
+			return errors.Wrap(err, "parsing installed packages")
+		}
 	}
-	build := makeBuild(t, dockerfile, imageUploadPath, rebuildUploadPath, opts)
-	if err := doCloudBuild(ctx, opts.GCBClient, build, opts, &bi); err != nil {
+	for _, t := range input.Targets() {
+		bi.Target = t
+		if err := writeBuildMetadata(ctx, t, env, bi, opts.MetadataStore); err != nil {
+			return err
+		}
+	}
+	if cacheKey != "" {
+		if err := opts.BuildCache.Save(ctx, cacheKey, t, opts.MetadataStore); err != nil {
+			log.Println(errors.Wrap(err, "saving build to cache"))
+		}
+	}
+	return nil
+}
+
+// runnerFor returns the runner that executes rebuilds for platform p, if one is configured.
+func (opts RemoteOptions) runnerFor(p Platform) (PlatformRunner, bool) {
+	if p.IsLinux() {
+		p = LinuxPlatform
+	}
+	runner, ok := opts.PlatformRunners[p]
+	return runner, ok
+}
+
+// rebuildOnPlatform executes the instructions on the runner configured for their platform.
+func rebuildOnPlatform(ctx context.Context, t Target, instructions Instructions, res Resources, opts RemoteOptions, bi BuildInfo) error {
+	runner, ok := opts.runnerFor(instructions.Platform)
+	if !ok {
+		return errors.Errorf("no runner configured for platform %q", instructions.Platform)
+	}
+	script := makePlatformScript(t, instructions)
+	// NOTE: The script is recorded as the Dockerfile since it fully defines the build.
+	if err := writeAsset(ctx, opts.MetadataStore, Asset{Target: t, Type: DockerfileAsset}, []byte(script)); err != nil {
+		return errors.Wrap(err, "writing build script")
+	}
+	result, err := doPlatformBuild(ctx, runner, PlatformRunRequest{Target: t, Script: script, Resources: res}, opts, &bi)
+	if err != nil {
 		return errors.Wrap(err, "performing build")
 	}
+	env := Environment{Platform: instructions.Platform, Runner: result.Runner}
+	return writeBuildMetadata(ctx, t, env, bi, opts.MetadataStore)
+}
+
+func writeBuildMetadata(ctx context.Context, t Target, env Environment, bi BuildInfo, metadata AssetStore) error {
+	{
+		w, _, err := metadata.Writer(ctx, Asset{Target: t, Type: EnvironmentAsset})
+		if err != nil {
+			return errors.Wrap(err, "creating writer for environment")
+		}
+		defer w.Close()
+		if err := json.NewEncoder(w).Encode(env); err != nil {
+			return errors.Wrap(err, "marshalling and writing environment")
+		}
+	}
 	{
-		w, _, err := opts.MetadataStore.Writer(ctx, Asset{Target: t, Type: BuildInfoAsset})
+		w, _, err := metadata.Writer(ctx, Asset{Target: t, Type: BuildInfoAsset})
 		if err != nil {
 			return errors.Wrap(err, "creating writer for build info")
 		}
@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/oss-rebuild/internal/gcb/gcbtest"
 	"google.golang.org/api/cloudbuild/v1"
@@ -59,7 +60,39 @@ EOF
 RUN cat <<'EOF' >build
  set -eux
  make build ...
- mkdir /out && cp /src/output/foo.tgz /out/
+ mkdir -p /out && cp /src/output/foo.tgz /out/
+EOF
+WORKDIR "/src"
+ENTRYPOINT ["/bin/sh","/build"]
+`,
+		},
+		{
+			name: "With Siblings",
+			args: rebuildContainerArgs{
+				Instructions: Instructions{
+					Location:       Location{Repo: "github.com/example", Ref: "main", Dir: "/src"},
+					SystemDeps:     []string{"git", "make"},
+					Source:         "git clone ...",
+					Deps:           "make deps ...",
+					Build:          "make build ...",
+					OutputPath:     "output/foo.tgz",
+					SiblingOutputs: map[string]string{"foo.whl": "output/foo.whl"},
+				},
+			},
+			expected: `#syntax=docker/dockerfile:1.4
+FROM alpine:3.19
+RUN <<'EOF'
+ set -eux
+ apk add git make
+ mkdir /src && cd /src
+ git clone ...
+ make deps ...
+EOF
+RUN cat <<'EOF' >build
+ set -eux
+ make build ...
+ mkdir -p /out && cp /src/output/foo.tgz /out/
+ cp /src/output/foo.whl /out/foo.whl
 EOF
 WORKDIR "/src"
 ENTRYPOINT ["/bin/sh","/build"]
@@ -96,7 +129,48 @@ EOF
 RUN cat <<'EOF' >build
  set -eux
  make build ...
- mkdir /out && cp /src/output/foo.tgz /out/
+ mkdir -p /out && cp /src/output/foo.tgz /out/
+EOF
+WORKDIR "/src"
+ENTRYPOINT ["/bin/sh","/build"]
+`,
+		},
+		{
+			name: "With Pinned Images",
+			args: rebuildContainerArgs{
+				Instructions: Instructions{
+					Location:   Location{Repo: "github.com/example", Ref: "main", Dir: "/src"},
+					SystemDeps: []string{"git"},
+					Source:     "git clone ...",
+					Deps:       "make deps ...",
+					Build:      "make build ...",
+					OutputPath: "output/foo.tgz",
+				},
+				UseTimewarp:        true,
+				UtilPrebuildBucket: "my-bucket",
+				Images: pinnedImages{
+					builderImage: "sha256:aaaa",
+					gsutilImage:  "sha256:bbbb",
+				},
+			},
+			expected: `#syntax=docker/dockerfile:1.4
+FROM gcr.io/cloud-builders/gsutil@sha256:bbbb AS timewarp_provider
+RUN gsutil cp -P gs://my-bucket/timewarp .
+FROM alpine:3.19@sha256:aaaa
+COPY --from=timewarp_provider ./timewarp .
+RUN <<'EOF'
+ set -eux
+ ./timewarp -port 8080 &
+ while ! nc -z localhost 8080;do sleep 1;done
+ apk add git
+ mkdir /src && cd /src
+ git clone ...
+ make deps ...
+EOF
+RUN cat <<'EOF' >build
+ set -eux
+ make build ...
+ mkdir -p /out && cp /src/output/foo.tgz /out/
 EOF
 WORKDIR "/src"
 ENTRYPOINT ["/bin/sh","/build"]
@@ -139,7 +213,7 @@ RUN cat <<'EOF' >build
  # Compile and package
  python3 setup.py build
  python3 setup.py sdist
- mkdir /out && cp /src/dist/foo.whl /out/
+ mkdir -p /out && cp /src/dist/foo.whl /out/
 EOF
 WORKDIR "/src"
 ENTRYPOINT ["/bin/sh","/build"]
@@ -195,12 +269,26 @@ func TestDoCloudBuild(t *testing.T) {
 				}, nil
 			},
 		}
-		opts := RemoteOptions{Project: "test-project", LogsBucket: "test-logs-bucket", BuildServiceAccount: "test-service-account", UtilPrebuildBucket: "test-bootstrap"}
+		metadata := NewFilesystemAssetStore(memfs.New())
+		opts := RemoteOptions{Project: "test-project", LogsBucket: "test-logs-bucket", BuildServiceAccount: "test-service-account", UtilPrebuildBucket: "test-bootstrap", MetadataStore: metadata}
 		target := Target{Ecosystem: NPM, Package: "pkg", Version: "version", Artifact: "pkg-version.tgz"}
 		bi := &BuildInfo{Target: target}
 		err := doCloudBuild(context.Background(), client, beforeBuild, opts, bi)
 		if err != nil {
 		}
+		{
+			r, _, err := metadata.Reader(context.Background(), Asset{Target: target, Type: RemoteBuildAsset})
+			if err != nil {
+				t.Fatalf("Reading remote build: %v", err)
+			}
+			var rb RemoteBuild
+			if err := json.NewDecoder(r).Decode(&rb); err != nil {
+				t.Fatalf("Decoding remote build: %v", err)
+			}
+			if diff := cmp.Diff(RemoteBuild{Operation: "operations/build-id", BuildID: "build-id"}, rb); diff != "" {
+				t.Errorf("Unexpected RemoteBuild: diff %v", diff)
+			}
+		}
 		expectedBI := &BuildInfo{
 			Target:      target,
 			BuildID:     "build-id",
@@ -218,11 +306,12 @@ func TestMakeBuild(t *testing.T) {
 	dockerfile := "FROM alpine:3.19"
 	imageUploadPath := "gs://test-bucket/image.tgz"
 	rebuildUploadPath := "gs://test-bucket/pkg-version.tgz"
+	packagesUploadPath := "gs://test-bucket/apk-installed"
 	opts := RemoteOptions{LogsBucket: "test-logs-bucket", BuildServiceAccount: "test-service-account", UtilPrebuildBucket: "test-bootstrap"}
 
 	t.Run("Success", func(t *testing.T) {
 		target := Target{Ecosystem: NPM, Package: "pkg", Version: "version", Artifact: "pkg-version.tgz"}
-		build := makeBuild(target, dockerfile, imageUploadPath, rebuildUploadPath, opts)
+		build := makeBuild(dockerfile, imageUploadPath, map[string]string{target.Artifact: rebuildUploadPath}, packagesUploadPath, Sandbox{}, Resources{}, opts)
 		diff := cmp.Diff(build, &cloudbuild.Build{
 			LogsBucket:     "test-logs-bucket",
 			Options:        &cloudbuild.BuildOptions{Logging: "GCS_ONLY"},
@@ -234,22 +323,27 @@ func TestMakeBuild(t *testing.T) {
 				},
 				{
 					Name: "gcr.io/cloud-builders/docker",
-					Args: []string{"run", "--name=container", "img"},
+					Args: []string{"run", "--name=container", "--security-opt=no-new-privileges", "--read-only", "--tmpfs=/tmp", "--volume=/src", "--volume=/out", "--volume=/root", "img"},
 				},
 				{
 					Name: "gcr.io/cloud-builders/docker",
 					Args: []string{"cp", "container:/out/pkg-version.tgz", "/workspace/pkg-version.tgz"},
 				},
+				{
+					Name: "gcr.io/cloud-builders/docker",
+					Args: []string{"cp", "container:/lib/apk/db/installed", "/workspace/apk-installed"},
+				},
 				{
 					Name:   "gcr.io/cloud-builders/docker",
 					Script: "docker save img | gzip > /workspace/image.tgz",
 				},
 				{
 					Name: "gcr.io/cloud-builders/gsutil",
-					Script: ("" +
-						"gsutil cp -P gs://test-bootstrap/gsutil_writeonly . && " +
-						"./gsutil_writeonly cp /workspace/image.tgz gs://test-bucket/image.tgz && " +
-						"./gsutil_writeonly cp /workspace/pkg-version.tgz gs://test-bucket/pkg-version.tgz"),
+					Script: ("set -eux\n" +
+						"gsutil cp -P gs://test-bootstrap/gsutil_writeonly .\n" +
+						"./gsutil_writeonly cp /workspace/image.tgz gs://test-bucket/image.tgz\n" +
+						"./gsutil_writeonly cp /workspace/pkg-version.tgz gs://test-bucket/pkg-version.tgz\n" +
+						"./gsutil_writeonly cp /workspace/apk-installed gs://test-bucket/apk-installed\n"),
 				},
 			},
 		})
@@ -257,6 +351,110 @@ func TestMakeBuild(t *testing.T) {
 			t.Errorf("Unexpected Build: diff: %v", diff)
 		}
 	})
+	t.Run("WithSiblings", func(t *testing.T) {
+		paths := map[string]string{"pkg-version.whl": "gs://test-bucket/pkg-version.whl", "pkg-version.tar.gz": "gs://test-bucket/pkg-version.tar.gz"}
+		build := makeBuild(dockerfile, imageUploadPath, paths, packagesUploadPath, Sandbox{}, Resources{}, opts)
+		var copied []string
+		for _, s := range build.Steps[2:4] {
+			copied = append(copied, s.Args[1])
+		}
+		if diff := cmp.Diff([]string{"container:/out/pkg-version.tar.gz", "container:/out/pkg-version.whl"}, copied); diff != "" {
+			t.Errorf("copied artifacts mismatch (-want +got):\n%s", diff)
+		}
+		wantUpload := "set -eux\n" +
+			"gsutil cp -P gs://test-bootstrap/gsutil_writeonly .\n" +
+			"./gsutil_writeonly cp /workspace/image.tgz gs://test-bucket/image.tgz\n" +
+			"./gsutil_writeonly cp /workspace/pkg-version.tar.gz gs://test-bucket/pkg-version.tar.gz\n" +
+			"./gsutil_writeonly cp /workspace/pkg-version.whl gs://test-bucket/pkg-version.whl\n" +
+			"./gsutil_writeonly cp /workspace/apk-installed gs://test-bucket/apk-installed\n"
+		if diff := cmp.Diff(wantUpload, build.Steps[len(build.Steps)-1].Script); diff != "" {
+			t.Errorf("upload script mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("WithResources", func(t *testing.T) {
+		target := Target{Ecosystem: NPM, Package: "pkg", Version: "version", Artifact: "pkg-version.tgz"}
+		res := Resources{Timeout: 90 * time.Minute, CPUs: 2.5, MemoryMB: 4096}
+		build := makeBuild(dockerfile, imageUploadPath, map[string]string{target.Artifact: rebuildUploadPath}, packagesUploadPath, Sandbox{}, res, opts)
+		diff := cmp.Diff(build.Steps[1], &cloudbuild.BuildStep{
+			Name:    "gcr.io/cloud-builders/docker",
+			Args:    []string{"run", "--name=container", "--security-opt=no-new-privileges", "--read-only", "--tmpfs=/tmp", "--volume=/src", "--volume=/out", "--volume=/root", "--cpus=2.5", "--memory=4096m", "img"},
+			Timeout: "5400s",
+		})
+		if diff != "" {
+			t.Errorf("Unexpected run step: diff: %v", diff)
+		}
+	})
+	t.Run("Unconfined", func(t *testing.T) {
+		target := Target{Ecosystem: OCI, Package: "example.com/app", Version: "v1", Artifact: "app.tar"}
+		build := makeBuild(dockerfile, imageUploadPath, map[string]string{target.Artifact: rebuildUploadPath}, packagesUploadPath, Sandbox{Unconfined: true}, Resources{}, opts)
+		diff := cmp.Diff(build.Steps[1], &cloudbuild.BuildStep{
+			Name: "gcr.io/cloud-builders/docker",
+			Args: []string{"run", "--name=container", "img"},
+		})
+		if diff != "" {
+			t.Errorf("Unexpected run step: diff: %v", diff)
+		}
+	})
+}
+
+func TestMergeSibling(t *testing.T) {
+	base := Instructions{
+		Location:   Location{Repo: "https://github.com/example/foo", Ref: "abc", Dir: "."},
+		SystemDeps: []string{"git", "python3"},
+		Source:     "git clone ...",
+		Deps:       "pip install build",
+		Build:      "python3 -m build --sdist",
+		OutputPath: "dist/foo-1.0.tar.gz",
+	}
+	t.Run("Merged", func(t *testing.T) {
+		inst := base
+		inst.SystemDeps = []string{"git", "python3"}
+		sib := base
+		sib.SystemDeps = []string{"git", "py3-wheel"}
+		sib.Build = "python3 -m build --wheel"
+		sib.OutputPath = "dist/foo-1.0-py3-none-any.whl"
+		if err := inst.MergeSibling("foo-1.0-py3-none-any.whl", sib); err != nil {
+			t.Fatalf("MergeSibling() error = %v", err)
+		}
+		want := base
+		want.SystemDeps = []string{"git", "python3", "py3-wheel"}
+		want.Build = "python3 -m build --sdist\npython3 -m build --wheel"
+		want.SiblingOutputs = map[string]string{"foo-1.0-py3-none-any.whl": "dist/foo-1.0-py3-none-any.whl"}
+		if diff := cmp.Diff(want, inst); diff != "" {
+			t.Errorf("MergeSibling() mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("SharedBuild", func(t *testing.T) {
+		inst := base
+		sib := base
+		sib.OutputPath = "dist/foo-1.0-sources.tar.gz"
+		if err := inst.MergeSibling("foo-1.0-sources.tar.gz", sib); err != nil {
+			t.Fatalf("MergeSibling() error = %v", err)
+		}
+		if inst.Build != base.Build || inst.Deps != base.Deps {
+			t.Errorf("MergeSibling() repeated shared steps: %+v", inst)
+		}
+	})
+	t.Run("UnconfinedSibling", func(t *testing.T) {
+		inst := base
+		sib := base
+		sib.OutputPath = "dist/foo-1.0-sources.tar.gz"
+		sib.Sandbox = Sandbox{Unconfined: true}
+		if err := inst.MergeSibling("foo-1.0-sources.tar.gz", sib); err != nil {
+			t.Fatalf("MergeSibling() error = %v", err)
+		}
+		if !inst.Sandbox.Unconfined {
+			t.Error("MergeSibling() did not relax sandbox for unconfined sibling")
+		}
+	})
+	t.Run("DifferentSource", func(t *testing.T) {
+		inst := base
+		sib := base
+		sib.Location.Ref = "def"
+		if err := inst.MergeSibling("other", sib); err == nil {
+			t.Error("MergeSibling() expected error for different location")
+		}
+	})
 }
 
 func must[T any](t T, err error) T {
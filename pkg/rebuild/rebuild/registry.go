@@ -21,7 +21,9 @@ import (
 	cacheinternal "github.com/google/oss-rebuild/internal/cache"
 	"github.com/google/oss-rebuild/internal/httpx"
 	"github.com/google/oss-rebuild/pkg/registry/cratesio"
+	"github.com/google/oss-rebuild/pkg/registry/maven"
 	"github.com/google/oss-rebuild/pkg/registry/npm"
+	"github.com/google/oss-rebuild/pkg/registry/oci"
 	"github.com/google/oss-rebuild/pkg/registry/pypi"
 )
 
@@ -30,26 +32,36 @@ type RegistryMux struct {
 	NPM      npm.Registry
 	PyPI     pypi.Registry
 	CratesIO cratesio.Registry
+	Maven    maven.Registry
+	OCI      oci.Registry
 }
 
 // RegistryMuxWithCache returns a new RegistryMux with the provided cache wrapping each registry.
 func RegistryMuxWithCache(registry RegistryMux, c cacheinternal.Cache) (RegistryMux, error) {
 	var newmux RegistryMux
 	if httpreg, ok := registry.NPM.(npm.HTTPRegistry); ok {
-		newmux.NPM = npm.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c)}
+		newmux.NPM = npm.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c), URL: httpreg.URL}
 	} else {
 		return newmux, errors.New("unknown npm registry type")
 	}
 	if httpreg, ok := registry.PyPI.(pypi.HTTPRegistry); ok {
-		newmux.PyPI = pypi.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c)}
+		newmux.PyPI = pypi.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c), URL: httpreg.URL}
 	} else {
 		return newmux, errors.New("unknown PyPI registry type")
 	}
 	if httpreg, ok := registry.CratesIO.(cratesio.HTTPRegistry); ok {
-		newmux.CratesIO = cratesio.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c)}
+		newmux.CratesIO = cratesio.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c), URL: httpreg.URL}
 	} else {
 		return newmux, errors.New("unknown crates.io registry type")
 	}
+	// NOTE: Maven is optional so that callers not rebuilding Maven packages need not configure it.
+	if httpreg, ok := registry.Maven.(maven.HTTPRegistry); ok {
+		newmux.Maven = maven.HTTPRegistry{Client: httpx.NewCachedClient(httpreg.Client, c), URL: httpreg.URL}
+	} else if registry.Maven != nil {
+		return newmux, errors.New("unknown Maven registry type")
+	}
+	// NOTE: Image layers are too large to cache so OCI requests are not wrapped.
+	newmux.OCI = registry.OCI
 	return newmux, nil
 }
 
@@ -67,6 +79,8 @@ func warmCacheforArtifact(ctx context.Context, registry RegistryMux, t Target) {
 		registry.CratesIO.Crate(ctx, t.Package)
 		registry.CratesIO.Version(ctx, t.Package, t.Version)
 		registry.CratesIO.Artifact(ctx, t.Package, t.Version)
+	case OCI:
+		registry.OCI.Manifest(ctx, t.Package, t.Version)
 	}
 }
 
@@ -0,0 +1,71 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Resources describes the limits applied to the build of a single target.
+// Zero values indicate that no limit should be applied.
+type Resources struct {
+	// Timeout is the maximum duration of the build.
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// CPUs is the number of CPUs available to the build.
+	CPUs float64 `json:"cpus,omitempty" yaml:"cpus,omitempty"`
+	// MemoryMB is the maximum memory available to the build in megabytes.
+	MemoryMB int64 `json:"memory_mb,omitempty" yaml:"memory_mb,omitempty"`
+}
+
+// Validate returns an error if any of the limits are invalid.
+func (r Resources) Validate() error {
+	if r.Timeout < 0 {
+		return errors.Errorf("invalid timeout: %v", r.Timeout)
+	}
+	if r.CPUs < 0 {
+		return errors.Errorf("invalid cpus: %v", r.CPUs)
+	}
+	if r.MemoryMB < 0 {
+		return errors.Errorf("invalid memory: %dMB", r.MemoryMB)
+	}
+	return nil
+}
+
+// DockerArgs returns the arguments to "docker run" that enforce the CPU and memory limits.
+func (r Resources) DockerArgs() []string {
+	var args []string
+	if r.CPUs > 0 {
+		args = append(args, fmt.Sprintf("--cpus=%g", r.CPUs))
+	}
+	if r.MemoryMB > 0 {
+		args = append(args, fmt.Sprintf("--memory=%dm", r.MemoryMB))
+	}
+	return args
+}
+
+// command returns the argv used to execute script in a shell subject to the CPU limit.
+// The memory limit is enforced by running the shell in a memoryCgroup.
+func (r Resources) command(script string) []string {
+	argv := []string{"sh", "-c", script}
+	if r.CPUs > 0 {
+		cpus := int(math.Ceil(r.CPUs))
+		argv = append([]string{"taskset", "-c", fmt.Sprintf("0-%d", cpus-1)}, argv...)
+	}
+	return argv
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResourcesCommand(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		res  Resources
+		want []string
+	}{
+		{
+			name: "NoLimits",
+			res:  Resources{Timeout: time.Minute},
+			want: []string{"sh", "-c", "make"},
+		},
+		{
+			name: "CPUAndMemory",
+			res:  Resources{CPUs: 1.5, MemoryMB: 512},
+			want: []string{"taskset", "-c", "0-1", "sh", "-c", "make"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.res.command("make")); diff != "" {
+				t.Errorf("command() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResourcesValidate(t *testing.T) {
+	if err := (Resources{Timeout: time.Hour, CPUs: 2, MemoryMB: 1024}).Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+	if err := (Resources{MemoryMB: -1}).Validate(); err == nil {
+		t.Error("Validate() expected error for negative memory")
+	}
+}
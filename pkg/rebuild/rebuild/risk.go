@@ -0,0 +1,215 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+// RiskIndicator identifies a class of content change associated with malicious packages.
+type RiskIndicator string
+
+const (
+	// IndicatorObfuscation marks newly introduced encoded, packed, or dynamically evaluated code.
+	IndicatorObfuscation RiskIndicator = "obfuscation"
+	// IndicatorNetwork marks newly introduced network access.
+	IndicatorNetwork RiskIndicator = "network"
+	// IndicatorProcessExec marks newly introduced subprocess execution.
+	IndicatorProcessExec RiskIndicator = "process-exec"
+	// IndicatorInstallHook marks code newly configured to run at install or import time.
+	IndicatorInstallHook RiskIndicator = "install-hook"
+	// IndicatorBinaryBlob marks binary content appearing only in the published artifact.
+	IndicatorBinaryBlob RiskIndicator = "binary-blob"
+)
+
+// riskWeights assigns each indicator its contribution to the overall score.
+var riskWeights = map[RiskIndicator]int{
+	IndicatorInstallHook: 35,
+	IndicatorBinaryBlob:  30,
+	IndicatorObfuscation: 30,
+	IndicatorNetwork:     20,
+	IndicatorProcessExec: 20,
+}
+
+// MaxRiskScore is the upper bound of RiskAssessment.Score.
+const MaxRiskScore = 100
+
+var (
+	networkPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`https?://[^\s'"\x60)]+`),
+		regexp.MustCompile(`require\(\s*['"](?:node:)?(?:http|https|net|dns|dgram|tls)['"]\s*\)`),
+		regexp.MustCompile(`\bfetch\(|XMLHttpRequest|\bWebSocket\(`),
+		regexp.MustCompile(`\bimport\s+(?:socket|urllib|requests|http\.client)\b|\burllib\.request\b|\brequests\.(?:get|post)\(`),
+		regexp.MustCompile(`\bTcpStream::connect\b|\breqwest::`),
+	}
+	execPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`child_process|\bexecSync\(|\bspawnSync\(`),
+		regexp.MustCompile(`\bsubprocess\.|\bos\.system\(|\bos\.popen\(`),
+		regexp.MustCompile(`std::process::Command|\bCommand::new\(`),
+	}
+	obfuscationPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\beval\(|\bnew Function\(|\bexec\(\s*(?:compile|base64|zlib|marshal)`),
+		regexp.MustCompile(`String\.fromCharCode\(|\batob\(|Buffer\.from\([^)]*['"]base64['"]|\bb64decode\(|\bzlib\.decompress\(|\bmarshal\.loads\(`),
+		regexp.MustCompile(`(?:\\x[0-9a-fA-F]{2}){20,}`),
+		regexp.MustCompile(`[A-Za-z0-9+/]{200,}={0,2}`),
+	}
+	setupHookPattern = regexp.MustCompile(`\bcmdclass\s*=|class\s+\w+\((?:install|develop|egg_info|build_py)\)`)
+	npmInstallHooks  = []string{"preinstall", "install", "postinstall"}
+)
+
+// maxMinifiedLine is the line length beyond which source is considered packed.
+const maxMinifiedLine = 5000
+
+// RiskEvidence records a single indicator observed in a mismatched file.
+type RiskEvidence struct {
+	Path      string        `json:"path"`
+	Indicator RiskIndicator `json:"indicator"`
+	Detail    string        `json:"detail"`
+}
+
+// RiskAssessment is the result of scoring the content differences between a rebuild and upstream.
+type RiskAssessment struct {
+	// Score ranges from 0 (no indicators) to MaxRiskScore.
+	Score    int            `json:"score"`
+	Evidence []RiskEvidence `json:"evidence"`
+}
+
+// ScoreRisk evaluates the mismatched files between a rebuild and upstream for indicators of tampering.
+//
+// The rbFiles and upFiles maps contain the contents of the files that differ
+// between the two artifacts, keyed by path. Only behavior present upstream and
+// absent from the corresponding rebuilt file is counted so that pre-existing
+// package functionality does not contribute to the score.
+func ScoreRisk(rbFiles, upFiles map[string][]byte) *RiskAssessment {
+	ra := &RiskAssessment{Evidence: []RiskEvidence{}}
+	paths := make([]string, 0, len(upFiles))
+	for p := range upFiles {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	for _, p := range paths {
+		up := upFiles[p]
+		rb, inRB := rbFiles[p]
+		add := func(ind RiskIndicator, detail string) {
+			ra.Evidence = append(ra.Evidence, RiskEvidence{Path: p, Indicator: ind, Detail: detail})
+		}
+		if isBinary(up) {
+			if !inRB || !isBinary(rb) {
+				add(IndicatorBinaryBlob, "binary content absent from rebuild")
+			}
+			continue
+		}
+		for _, re := range networkPatterns {
+			if m := newMatch(re, rb, up); m != "" {
+				add(IndicatorNetwork, m)
+			}
+		}
+		for _, re := range execPatterns {
+			if m := newMatch(re, rb, up); m != "" {
+				add(IndicatorProcessExec, m)
+			}
+		}
+		for _, re := range obfuscationPatterns {
+			if m := newMatch(re, rb, up); m != "" {
+				add(IndicatorObfuscation, truncate(m, 80))
+			}
+		}
+		if longestLine(up) > maxMinifiedLine && longestLine(rb) <= maxMinifiedLine {
+			add(IndicatorObfuscation, "packed source line")
+		}
+		switch base := path.Base(p); {
+		case base == "package.json":
+			for _, hook := range newNPMHooks(rb, up) {
+				add(IndicatorInstallHook, "scripts."+hook)
+			}
+		case base == "setup.py":
+			if m := newMatch(setupHookPattern, rb, up); m != "" {
+				add(IndicatorInstallHook, m)
+			}
+		case path.Ext(base) == ".pth" || base == "build.rs":
+			if !inRB {
+				add(IndicatorInstallHook, base+" absent from rebuild")
+			}
+		}
+	}
+	seen := make(map[RiskIndicator]bool)
+	for _, e := range ra.Evidence {
+		if !seen[e.Indicator] {
+			seen[e.Indicator] = true
+			ra.Score += riskWeights[e.Indicator]
+		}
+	}
+	ra.Score = min(ra.Score, MaxRiskScore)
+	return ra
+}
+
+// newMatch returns the first match of re in up that has no equivalent in rb.
+func newMatch(re *regexp.Regexp, rb, up []byte) string {
+	have := make(map[string]bool)
+	for _, m := range re.FindAll(rb, -1) {
+		have[string(m)] = true
+	}
+	for _, m := range re.FindAll(up, -1) {
+		if !have[string(m)] {
+			return string(m)
+		}
+	}
+	return ""
+}
+
+// newNPMHooks returns the install lifecycle scripts in up that are absent or different in rb.
+func newNPMHooks(rb, up []byte) []string {
+	type manifest struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	var rbm, upm manifest
+	if err := json.Unmarshal(up, &upm); err != nil {
+		return nil
+	}
+	// A missing or malformed rebuild manifest means every hook is new.
+	_ = json.Unmarshal(rb, &rbm)
+	var hooks []string
+	for _, h := range npmInstallHooks {
+		if cmd, ok := upm.Scripts[h]; ok && rbm.Scripts[h] != cmd {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+func isBinary(b []byte) bool {
+	head := b[:min(len(b), 8000)]
+	return bytes.IndexByte(head, 0) != -1 || !utf8.Valid(b)
+}
+
+func longestLine(b []byte) int {
+	var longest int
+	for _, l := range bytes.Split(b, []byte{'\n'}) {
+		longest = max(longest, len(l))
+	}
+	return longest
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
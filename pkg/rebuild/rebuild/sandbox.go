@@ -0,0 +1,81 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"github.com/google/oss-rebuild/internal/kube"
+)
+
+// Sandbox describes the isolation of the container executing a build.
+//
+// Builds execute arbitrary upstream scripts so, by default, they run under the
+// runtime's default seccomp and AppArmor profiles, are unable to gain
+// privileges through setuid binaries or file capabilities, and, where system
+// dependencies are installed ahead of the build, use a read-only root
+// filesystem with writes confined to the source, output, home, and temporary
+// directories.
+type Sandbox struct {
+	// Unconfined disables the hardened profile for builds which require the
+	// privileges it denies, such as those running nested containers.
+	Unconfined bool `json:"unconfined,omitempty" yaml:"unconfined,omitempty"`
+}
+
+// sandboxWritableDirs are the directories to which a build may write when its
+// root filesystem is read-only.
+var sandboxWritableDirs = []string{"/src", "/out", "/root"}
+
+// SecurityOpts returns the "docker run --security-opt" values applying the
+// sandbox to a long-lived container such as a local rebuilder.
+func (s Sandbox) SecurityOpts() []string {
+	if s.Unconfined {
+		return nil
+	}
+	return []string{"no-new-privileges"}
+}
+
+// dockerArgs returns the arguments to "docker run" that apply the sandbox to a
+// build container whose system dependencies are already installed.
+func (s Sandbox) dockerArgs() []string {
+	if s.Unconfined {
+		return nil
+	}
+	var args []string
+	for _, o := range s.SecurityOpts() {
+		args = append(args, "--security-opt="+o)
+	}
+	args = append(args, "--read-only", "--tmpfs=/tmp")
+	// NOTE: Anonymous volumes are populated from the image so the source and
+	// dependencies set up while building the image remain available.
+	for _, d := range sandboxWritableDirs {
+		args = append(args, "--volume="+d)
+	}
+	return args
+}
+
+// securityContext returns the security context applying the sandbox to a
+// Kubernetes build container.
+func (s Sandbox) securityContext() *kube.SecurityContext {
+	if s.Unconfined {
+		return nil
+	}
+	// NOTE: The root filesystem remains writable since system dependencies are
+	// installed within the build container.
+	noEscalation := false
+	return &kube.SecurityContext{
+		AllowPrivilegeEscalation: &noEscalation,
+		SeccompProfile:           &kube.SeccompProfile{Type: kube.RuntimeDefaultProfile},
+		AppArmorProfile:          &kube.AppArmorProfile{Type: kube.RuntimeDefaultProfile},
+	}
+}
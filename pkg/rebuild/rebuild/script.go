@@ -0,0 +1,104 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+// safeWord matches the words which the shell interprets literally without quoting.
+var safeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote returns s quoted, if necessary, for use as a single shell word.
+func Quote(s string) string {
+	if safeWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Command returns the shell command executing the words, each quoted as necessary.
+func Command(words ...string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = Quote(w)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Script is a shell script built from structured commands.
+//
+// The words of each command are quoted as necessary so values such as package
+// names, versions, and paths are passed to the command verbatim regardless of
+// the characters they contain.
+type Script struct {
+	lines []string
+}
+
+// Cmd appends a command executing the words.
+func (s *Script) Cmd(words ...string) *Script {
+	s.lines = append(s.lines, Command(words...))
+	return s
+}
+
+// Pipe appends a pipeline connecting the output of each command to the input of the next.
+func (s *Script) Pipe(cmds ...[]string) *Script {
+	return s.join(" | ", cmds)
+}
+
+// And appends a list executing each command only if the previous one succeeded.
+func (s *Script) And(cmds ...[]string) *Script {
+	return s.join(" && ", cmds)
+}
+
+func (s *Script) join(op string, cmds [][]string) *Script {
+	parts := make([]string, len(cmds))
+	for i, c := range cmds {
+		parts[i] = Command(c...)
+	}
+	s.lines = append(s.lines, strings.Join(parts, op))
+	return s
+}
+
+// Raw appends the shell source verbatim. Empty source is ignored.
+//
+// Raw is intended for scripts provided in their entirety, such as those of a
+// ManualStrategy, and for constructs not expressible as commands. Any values
+// interpolated into src must be quoted using Quote or Command.
+func (s *Script) Raw(src string) *Script {
+	if src = strings.TrimSpace(src); src != "" {
+		s.lines = append(s.lines, src)
+	}
+	return s
+}
+
+// String returns the commands of the script, one per line.
+func (s Script) String() string {
+	return strings.Join(s.lines, "\n")
+}
+
+// Bash returns the script as a standalone script that traces each command and
+// exits on the first failure.
+func (s Script) Bash() string {
+	return "set -eux\n" + s.String() + "\n"
+}
+
+// BuildStep returns a Cloud Build step executing the script in the named image.
+func (s Script) BuildStep(image string) *cloudbuild.BuildStep {
+	return &cloudbuild.BuildStep{Name: image, Script: s.Bash()}
+}
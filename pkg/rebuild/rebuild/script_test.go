@@ -0,0 +1,61 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/cloudbuild/v1"
+)
+
+func TestQuote(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"left-pad", "left-pad"},
+		{"@types/node", "@types/node"},
+		{"1.0.0+build.1", "1.0.0+build.1"},
+		{"", "''"},
+		{"two words", "'two words'"},
+		{"req>=1.0", "'req>=1.0'"},
+		{"$(id)", "'$(id)'"},
+		{"it's", `'it'\''s'`},
+	} {
+		if got := Quote(tc.in); got != tc.want {
+			t.Errorf("Quote(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestScript(t *testing.T) {
+	var s Script
+	s.Cmd("git", "checkout", "--force", "v1.0 rc")
+	s.Pipe([]string{"echo", "a;b"}, []string{"base64", "-d"})
+	s.And([]string{"cd", "dir"}, []string{"npm", "pack"})
+	s.Raw("\n  for f in *; do echo $f; done\n")
+	s.Raw("  ")
+	want := "git checkout --force 'v1.0 rc'\necho 'a;b' | base64 -d\ncd dir && npm pack\nfor f in *; do echo $f; done"
+	if diff := cmp.Diff(want, s.String()); diff != "" {
+		t.Errorf("String() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("set -eux\n"+want+"\n", s.Bash()); diff != "" {
+		t.Errorf("Bash() mismatch (-want +got):\n%s", diff)
+	}
+	wantStep := &cloudbuild.BuildStep{Name: "alpine:3.19", Script: "set -eux\n" + want + "\n"}
+	if diff := cmp.Diff(wantStep, s.BuildStep("alpine:3.19")); diff != "" {
+		t.Errorf("BuildStep() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -20,11 +20,13 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net/url"
 	"path/filepath"
 	"strings"
 
 	gcs "cloud.google.com/go/storage"
 	billy "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/pkg/errors"
 	"google.golang.org/api/option"
 )
@@ -48,12 +50,36 @@ const (
 	BuildInfoAsset AssetType = "info.json"
 	// ContainerImageAsset is the container state after executing the rebuild.
 	ContainerImageAsset AssetType = "image.tgz"
+	// InstalledPackagesAsset is the package database of the build container after executing the rebuild.
+	InstalledPackagesAsset AssetType = "apk-installed"
+	// EnvironmentAsset is the serialized Environment in which the remote rebuild was executed.
+	EnvironmentAsset AssetType = "environment.json"
+	// RemoteBuildAsset is the serialized RemoteBuild identifying an in-progress or completed remote rebuild.
+	RemoteBuildAsset AssetType = "remote-build.json"
 
 	// AttestationBundleAsset is the signed attestation bundle generated for a rebuild.
 	AttestationBundleAsset AssetType = "rebuild.intoto.jsonl"
+	// PackageIndexAsset is the index of the attestation bundles published for a package.
+	// It is stored per package, i.e. for a Target with only Ecosystem and Package set.
+	PackageIndexAsset AssetType = "index.json"
+
+	// InferenceAsset is the serialized strategy inferred for a target.
+	InferenceAsset AssetType = "inference.json"
+
+	// SuspiciousAdditionsAsset is the report of files present upstream but absent from the rebuild.
+	SuspiciousAdditionsAsset AssetType = "suspicious-additions.json"
+
+	// TimestampsAsset is the report of timestamps embedded in the upstream artifact.
+	TimestampsAsset AssetType = "timestamps.json"
 
 	// BuildDef is the build definition, including strategy.
 	BuildDef AssetType = "build.yaml"
+
+	// BugReportAsset is a draft report of nondeterminism to be reviewed before filing with the package's maintainers.
+	BugReportAsset AssetType = "upstream-bug-report.md"
+
+	// FailureSummaryAsset is a language model's categorized summary of the cause of a failed rebuild.
+	FailureSummaryAsset AssetType = "failure-summary.json"
 )
 
 var (
@@ -98,15 +124,63 @@ func AssetCopy(ctx context.Context, to, from AssetStore, a Asset) (string, error
 // DebugStoreFromContext constructs a DebugStorer using values from the given context.
 func DebugStoreFromContext(ctx context.Context) (AssetStore, error) {
 	if uploadpath, ok := ctx.Value(UploadArtifactsPathID).(string); ok {
-		if strings.HasPrefix(uploadpath, "gs://") {
-			storer, err := NewGCSStore(ctx, uploadpath)
-			return storer, errors.Wrapf(err, "Failed to create GCS storer")
-		}
-		return nil, errors.New("unsupported upload path")
+		storer, err := NewAssetStoreFromURL(ctx, uploadpath)
+		return storer, errors.Wrapf(err, "Failed to create storer")
 	}
 	return nil, ErrNoUploadPath
 }
 
+// NewAssetStoreFromURL constructs the AssetStore located by the provided URL.
+//
+// Supported schemes are "gs://bucket/prefix" for GCS, "s3://bucket/prefix"
+// for S3-compatible stores, and "file:///path" for local or network
+// filesystems. The RunID context value is used to partition assets.
+//
+// Any of these may be prefixed with "cas+" (e.g. "cas+gs://bucket/prefix")
+// to deduplicate asset contents across runs using a ContentAddressedStore.
+func NewAssetStoreFromURL(ctx context.Context, location string) (AssetStore, error) {
+	if inner, ok := strings.CutPrefix(location, "cas+"); ok {
+		manifests, err := NewAssetStoreFromURL(ctx, inner)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating manifest store")
+		}
+		blobs, err := NewAssetStoreFromURL(context.WithValue(ctx, RunID, ""), inner)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating blob store")
+		}
+		return NewContentAddressedStore(blobs, manifests), nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing store URL")
+	}
+	switch u.Scheme {
+	case "gs":
+		s, err := NewGCSStore(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "s3":
+		s, err := NewS3Store(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, errors.Errorf("unsupported file URL host: %s", u.Host)
+		}
+		runID, ok := ctx.Value(RunID).(string)
+		if !ok {
+			return nil, errors.New("no run ID provided")
+		}
+		return NewFilesystemAssetStoreWithRunID(osfs.New(u.Path), runID), nil
+	default:
+		return nil, errors.Errorf("unsupported store URL: %s", location)
+	}
+}
+
 // GCSStore is a debug asset storage backed by GCS.
 type GCSStore struct {
 	gcsClient *gcs.Client
@@ -171,16 +245,16 @@ var _ AssetStore = &GCSStore{}
 
 // FilesystemAssetStore will store assets in a billy.Filesystem
 type FilesystemAssetStore struct {
-	fs billy.Filesystem
+	fs    billy.Filesystem
+	runID string
 }
 
-// TODO: Maybe this should include a runID?
 func (s *FilesystemAssetStore) resourcePath(a Asset) string {
 	name := string(a.Type)
 	if a.Type == RebuildAsset {
 		name = a.Target.Artifact
 	}
-	return filepath.Join(string(a.Target.Ecosystem), a.Target.Package, a.Target.Version, a.Target.Artifact, name)
+	return filepath.Join(string(a.Target.Ecosystem), a.Target.Package, a.Target.Version, a.Target.Artifact, s.runID, name)
 }
 
 // Reader returns a reader for the given asset.
@@ -212,3 +286,8 @@ var _ AssetStore = &FilesystemAssetStore{}
 func NewFilesystemAssetStore(fs billy.Filesystem) *FilesystemAssetStore {
 	return &FilesystemAssetStore{fs: fs}
 }
+
+// NewFilesystemAssetStoreWithRunID creates a new FilesystemAssetStore that partitions assets by runID.
+func NewFilesystemAssetStoreWithRunID(fs billy.Filesystem, runID string) *FilesystemAssetStore {
+	return &FilesystemAssetStore{fs: fs, runID: runID}
+}
@@ -21,11 +21,10 @@ import (
 	"io"
 	"log"
 	"os/exec"
+	"slices"
 	"strings"
-	"text/template"
 	"time"
 
-	"github.com/google/oss-rebuild/internal/semver"
 	"github.com/pkg/errors"
 )
 
@@ -46,6 +45,47 @@ type Instructions struct {
 	Build      string
 	// Where the generated artifact can be found.
 	OutputPath string
+	// SiblingOutputs are where the additional artifacts produced by the build
+	// can be found, keyed by artifact name.
+	SiblingOutputs map[string]string `json:",omitempty"`
+	// The platform on which the instructions must be executed.
+	Platform Platform
+	// Sandbox is the isolation of the container executing the instructions.
+	Sandbox Sandbox
+}
+
+// MergeSibling extends the instructions to also produce the artifact described by sib.
+//
+// The source and dependency steps are shared so sib must be executed from the
+// same location and on the same platform. Build steps not already performed
+// are appended.
+func (i *Instructions) MergeSibling(artifact string, sib Instructions) error {
+	if sib.Location != i.Location || sib.Platform != i.Platform {
+		return errors.Errorf("sibling %s must be built from the same location and platform", artifact)
+	}
+	if sib.Source != i.Source {
+		return errors.Errorf("sibling %s requires different source steps", artifact)
+	}
+	for _, d := range sib.SystemDeps {
+		if !slices.Contains(i.SystemDeps, d) {
+			i.SystemDeps = append(i.SystemDeps, d)
+		}
+	}
+	if sib.Deps != "" && !strings.Contains(i.Deps, sib.Deps) {
+		i.Deps = strings.TrimSuffix(i.Deps, "\n") + "\n" + sib.Deps
+	}
+	if sib.Build != "" && !strings.Contains(i.Build, sib.Build) {
+		i.Build = strings.TrimSuffix(i.Build, "\n") + "\n" + sib.Build
+	}
+	if i.SiblingOutputs == nil {
+		i.SiblingOutputs = make(map[string]string)
+	}
+	i.SiblingOutputs[artifact] = sib.OutputPath
+	// NOTE: The shared build must be granted any privileges the sibling requires.
+	if sib.Sandbox.Unconfined {
+		i.Sandbox.Unconfined = true
+	}
+	return nil
 }
 
 // BuildEnv contains resources provided by the build environment that a strategy may use.
@@ -53,6 +93,9 @@ type BuildEnv struct {
 	TimewarpHost           string
 	HasRepo                bool
 	PreferPreciseToolchain bool
+	// DisableInstallHooks prevents the lifecycle scripts of the package's
+	// dependencies from running while they are installed.
+	DisableInstallHooks bool
 }
 
 // TimewarpURL constructs the correct URL for this ecosystem and registryTime.
@@ -68,25 +111,11 @@ type Strategy interface {
 	GenerateFor(Target, BuildEnv) (Instructions, error)
 }
 
-// PopulateTemplate is a helper to execute a template string using a data object.
-func PopulateTemplate(tmpl string, data any) (string, error) {
-	tmpl = strings.TrimSpace(tmpl)
-	t, err := template.New("buildTmpl").Funcs(template.FuncMap{"SemverCmp": semver.Cmp}).Parse(tmpl)
-	if err != nil {
-		return "", errors.Wrapf(err, "failed to parse template with contents: %s", tmpl)
-	}
-	output := new(bytes.Buffer)
-	err = t.Execute(output, data)
-	if err != nil {
-		return "", errors.Wrapf(err, "failed to execute template with contents: %s", tmpl)
-	}
-	return output.String(), nil
-}
-
 // BasicSourceSetup provides a common source setup script.
 func BasicSourceSetup(s Location, env *BuildEnv) (string, error) {
+	var script Script
 	if env.HasRepo {
-		return PopulateTemplate("git checkout --force '{{.Ref}}'", s)
+		return script.Cmd("git", "checkout", "--force", s.Ref).String(), nil
 	}
 	// TODO: We should eventually support single commit checkout.
 	// This would be roughly:
@@ -94,17 +123,42 @@ func BasicSourceSetup(s Location, env *BuildEnv) (string, error) {
 	//   git remote add origin '{{.Repo}}'
 	//   git fetch --depth 1 origin '{{.Ref}}'
 	//   git checkout FETCH_HEAD
-	return PopulateTemplate(`
-git clone '{{.Repo}}' .
-git checkout --force '{{.Ref}}'
-`, s)
+	script.Cmd("git", "clone", s.Repo, ".")
+	script.Cmd("git", "checkout", "--force", s.Ref)
+	return script.String(), nil
 }
 
 // ExecuteScript executes a single step of the strategy and returns the output regardless of error.
 func ExecuteScript(ctx context.Context, dir string, script string) (string, error) {
+	return executeScript(ctx, dir, []string{"sh", "-c", script})
+}
+
+// ExecuteScriptWithLimits executes a single step of the strategy subject to
+// the resource limits of the rebuild and returns the output regardless of error.
+//
+// It is intended for the dependency and build steps. Source checkout uses
+// ExecuteScript so that it is not constrained by the build's limits.
+func ExecuteScriptWithLimits(ctx context.Context, dir string, script string) (string, error) {
+	res, _ := ctx.Value(ResourcesID).(Resources)
+	if res.MemoryMB <= 0 {
+		return executeScript(ctx, dir, res.command(script))
+	}
+	cg, err := newMemoryCgroup(res.MemoryMB)
+	if err != nil {
+		return "", errors.Wrap(err, "enforcing memory limit")
+	}
+	defer cg.Close()
+	output, err := executeScript(ctx, dir, res.command(cg.enter()+"\n"+script))
+	if err != nil && cg.OOMKilled() {
+		return output, errors.Errorf("build exceeded memory limit of %dMB", res.MemoryMB)
+	}
+	return output, err
+}
+
+func executeScript(ctx context.Context, dir string, argv []string) (string, error) {
 	output := new(bytes.Buffer)
 	outAndLog := io.MultiWriter(output, log.Default().Writer())
-	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
 	cmd.Stdout = outAndLog
 	cmd.Stderr = outAndLog
 	// CD into the package's directory (which is where we cloned the repo.)
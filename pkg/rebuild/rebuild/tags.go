@@ -0,0 +1,110 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"strings"
+)
+
+// TagConvention is a scheme by which a repository names the tag of a package release.
+type TagConvention string
+
+const (
+	// AtTag is "<pkg>@<version>", used by lerna's independent mode and changesets (e.g. "@scope/name@1.2.3").
+	AtTag TagConvention = "pkg@version"
+	// DashTag is "<pkg>-v<version>" or "<pkg>-<version>", common in cargo workspaces and release-please.
+	DashTag TagConvention = "pkg-version"
+	// PathTag is "<component>/v<version>", used by Go modules and other path-prefixed monorepos.
+	PathTag TagConvention = "component/version"
+	// VersionTag is a repository-wide "v<version>" or "<version>", including lerna's fixed mode.
+	VersionTag TagConvention = "version"
+)
+
+// TagCandidate is a tag that would identify a package version under a convention.
+type TagCandidate struct {
+	Tag        string
+	Convention TagConvention
+}
+
+// tagNames returns the names by which a package may be identified in its tags, most specific first.
+func tagNames(pkg string) []string {
+	names := []string{pkg}
+	if slash := strings.LastIndexByte(pkg, '/'); slash != -1 {
+		names = append(names, pkg[slash+1:])
+	}
+	// NOTE: PyPI and crates.io treat these separators as equivalent so repos may use either.
+	for _, n := range names {
+		for _, alt := range []string{strings.ReplaceAll(n, "_", "-"), strings.ReplaceAll(n, "-", "_")} {
+			if alt != n {
+				names = append(names, alt)
+			}
+		}
+	}
+	var out []string
+	seen := make(map[string]bool)
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// CandidateTags returns the tags that common conventions would use for a
+// release of pkg at version. Package-specific conventions are returned before
+// repository-wide ones so monorepos resolve to the tag of the intended package.
+func CandidateTags(pkg, version string) []TagCandidate {
+	var out []TagCandidate
+	for _, n := range tagNames(pkg) {
+		out = append(out,
+			TagCandidate{n + "@" + version, AtTag},
+			TagCandidate{n + "@v" + version, AtTag},
+			TagCandidate{n + "-v" + version, DashTag},
+			TagCandidate{n + "-" + version, DashTag},
+			TagCandidate{n + "/v" + version, PathTag},
+			TagCandidate{n + "/" + version, PathTag},
+		)
+	}
+	return append(out,
+		TagCandidate{"v" + version, VersionTag},
+		TagCandidate{version, VersionTag},
+	)
+}
+
+// MatchTagConvention returns the convention under which tag identifies the release of pkg at version.
+func MatchTagConvention(tag, pkg, version string) (TagConvention, bool) {
+	tag = strings.TrimPrefix(tag, "refs/tags/")
+	for _, c := range CandidateTags(pkg, version) {
+		if strings.EqualFold(c.Tag, tag) {
+			return c.Convention, true
+		}
+	}
+	return "", false
+}
+
+// findConventionalTag returns the tag that best identifies the release of pkg at version.
+func findConventionalTag(tags []string, pkg, version string) (string, bool) {
+	byName := make(map[string]string)
+	for _, tag := range tags {
+		byName[strings.ToLower(tag)] = tag
+	}
+	for _, c := range CandidateTags(pkg, version) {
+		if tag, ok := byName[strings.ToLower(c.Tag)]; ok {
+			return tag, true
+		}
+	}
+	return "", false
+}
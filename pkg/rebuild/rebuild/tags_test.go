@@ -0,0 +1,86 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestMatchTagConvention(t *testing.T) {
+	for _, tc := range []struct {
+		tag, pkg, version string
+		want              TagConvention
+		ok                bool
+	}{
+		{"@babel/core@7.24.0", "@babel/core", "7.24.0", AtTag, true},
+		{"core@7.24.0", "@babel/core", "7.24.0", AtTag, true},
+		{"tokio-macros-v2.1.0", "tokio-macros", "2.1.0", DashTag, true},
+		{"serde_derive-1.0.0", "serde-derive", "1.0.0", DashTag, true},
+		{"refs/tags/tools/v0.3.0", "tools", "0.3.0", PathTag, true},
+		{"V1.2.3", "foo", "1.2.3", VersionTag, true},
+		{"1.2.3", "foo", "1.2.3", VersionTag, true},
+		{"bar@1.2.3", "foo", "1.2.3", "", false},
+		{"v1.2.30", "foo", "1.2.3", "", false},
+	} {
+		t.Run(tc.tag, func(t *testing.T) {
+			got, ok := MatchTagConvention(tc.tag, tc.pkg, tc.version)
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("MatchTagConvention(%q, %q, %q) = %q, %v; want %q, %v", tc.tag, tc.pkg, tc.version, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestFindTagMatch(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(0, 0)}
+	commit := func(msg string) string {
+		h, err := w.Commit(msg, &git.CommitOptions{AllowEmptyCommits: true, Author: sig})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.String()
+	}
+	// NOTE: The sibling package is tagged at the same version.
+	other := commit("release other")
+	if _, err := repo.CreateTag("other@1.0.0", plumbing.NewHash(other), nil); err != nil {
+		t.Fatal(err)
+	}
+	want := commit("release pkg")
+	if _, err := repo.CreateTag("@scope/pkg@1.0.0", plumbing.NewHash(want), &git.CreateTagOptions{Tagger: sig, Message: "release"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := FindTagMatch("@scope/pkg", "1.0.0", repo)
+	if err != nil {
+		t.Fatalf("FindTagMatch() error: %v", err)
+	}
+	if got != want {
+		t.Errorf("FindTagMatch() = %s, want %s", got, want)
+	}
+}
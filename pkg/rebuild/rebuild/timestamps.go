@@ -0,0 +1,208 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/pkg/errors"
+)
+
+// AnomalyKind describes how an embedded timestamp conflicts with the package's history.
+type AnomalyKind string
+
+const (
+	// AnomalyAfterPublish marks a timestamp later than the registry publish time.
+	AnomalyAfterPublish AnomalyKind = "after-publish"
+	// AnomalyBuildAfterCommit marks a build time long after the source commit.
+	AnomalyBuildAfterCommit AnomalyKind = "build-after-commit"
+	// AnomalyPublishAfterBuild marks a publish time long after the build time.
+	AnomalyPublishAfterBuild AnomalyKind = "publish-after-build"
+)
+
+const (
+	// clockSkew is the tolerance applied when ordering times from different clocks.
+	clockSkew = time.Hour
+	// maxGap is the longest expected interval between commit, build, and publish.
+	maxGap = 30 * 24 * time.Hour
+)
+
+// fixedTimestamps are the constant times set by common reproducible
+// packaging tools which carry no information about when a build occurred.
+var fixedTimestamps = []time.Time{
+	time.Unix(0, 0).UTC(),
+	// The earliest time representable in a zip entry.
+	time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+	// The time npm sets on all tarball entries.
+	time.Date(1985, 10, 26, 8, 15, 0, 0, time.UTC),
+}
+
+// TimestampAnomaly is an embedded timestamp inconsistent with the package's history.
+type TimestampAnomaly struct {
+	Kind      AnomalyKind       `json:"kind"`
+	Timestamp archive.Timestamp `json:"timestamp"`
+	Detail    string            `json:"detail"`
+}
+
+// TimestampReport cross-references the timestamps embedded in an upstream
+// artifact with the source history and registry metadata.
+type TimestampReport struct {
+	Target Target `json:"target"`
+	// Published is the time the artifact was published to the registry, if known.
+	Published time.Time `json:"published,omitempty"`
+	// Committed is the commit time of the source used for the rebuild, if known.
+	Committed time.Time `json:"committed,omitempty"`
+	// Built is the latest informative embedded timestamp, an estimate of the build time.
+	Built      time.Time           `json:"built,omitempty"`
+	Timestamps []archive.Timestamp `json:"timestamps"`
+	Anomalies  []TimestampAnomaly  `json:"anomalies"`
+}
+
+// AnalyzeTimestamps identifies embedded timestamps that conflict with the
+// publish time and commit time of a package. Zero times are treated as unknown.
+//
+// Timestamps set to constant values by reproducible packaging tools are
+// excluded since they reflect neither the build nor the source.
+func AnalyzeTimestamps(t Target, ts []archive.Timestamp, published, committed time.Time) *TimestampReport {
+	r := &TimestampReport{Target: t, Published: published, Committed: committed, Timestamps: []archive.Timestamp{}, Anomalies: []TimestampAnomaly{}}
+	var latest *archive.Timestamp
+	for _, ts := range ts {
+		if isFixedTimestamp(ts.Time) {
+			continue
+		}
+		r.Timestamps = append(r.Timestamps, ts)
+		if latest == nil || ts.Time.After(latest.Time) {
+			latest = &ts
+		}
+		if !published.IsZero() && ts.Time.After(published.Add(clockSkew)) {
+			r.Anomalies = append(r.Anomalies, TimestampAnomaly{
+				Kind:      AnomalyAfterPublish,
+				Timestamp: ts,
+				Detail:    fmt.Sprintf("%s after publish", ts.Time.Sub(published).Round(time.Second)),
+			})
+		}
+	}
+	if latest == nil {
+		return r
+	}
+	r.Built = latest.Time
+	if !committed.IsZero() && latest.Time.Sub(committed) > maxGap {
+		r.Anomalies = append(r.Anomalies, TimestampAnomaly{
+			Kind:      AnomalyBuildAfterCommit,
+			Timestamp: *latest,
+			Detail:    fmt.Sprintf("built %s after commit", latest.Time.Sub(committed).Round(time.Hour)),
+		})
+	}
+	if !published.IsZero() && published.Sub(latest.Time) > maxGap {
+		r.Anomalies = append(r.Anomalies, TimestampAnomaly{
+			Kind:      AnomalyPublishAfterBuild,
+			Timestamp: *latest,
+			Detail:    fmt.Sprintf("published %s after build", published.Sub(latest.Time).Round(time.Hour)),
+		})
+	}
+	return r
+}
+
+func isFixedTimestamp(t time.Time) bool {
+	for _, f := range fixedTimestamps {
+		// NOTE: Allow for the 2s resolution of zip and any local time offset.
+		if d := t.Sub(f); d > -24*time.Hour && d < 24*time.Hour {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTimestampReport stores the report as an asset.
+func WriteTimestampReport(ctx context.Context, assets AssetStore, r *TimestampReport) (Asset, error) {
+	a := Asset{Type: TimestampsAsset, Target: r.Target}
+	w, _, err := assets.Writer(ctx, a)
+	if err != nil {
+		return a, errors.Wrap(err, "creating timestamp report writer")
+	}
+	defer w.Close()
+	if err := json.NewEncoder(w).Encode(r); err != nil {
+		return a, errors.Wrap(err, "writing timestamp report")
+	}
+	return a, nil
+}
+
+// analyzeTimestamps stores a TimestampReport for the upstream artifact.
+func analyzeTimestamps(ctx context.Context, t Target, mux RegistryMux, s storage.Storer, inst Instructions, assets AssetStore) (*Asset, error) {
+	// NOTE: The upstream asset is canonicalized which strips timestamps so the
+	// original artifact must be fetched.
+	r, err := artifactReader(ctx, t, mux)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching upstream artifact")
+	}
+	defer r.Close()
+	ts, err := archive.ExtractTimestamps(r, t.ArchiveType())
+	if err != nil {
+		return nil, errors.Wrap(err, "extracting timestamps")
+	}
+	published, err := publishTime(ctx, t, mux)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching publish time")
+	}
+	var committed time.Time
+	if plumbing.IsHash(inst.Location.Ref) {
+		if c, err := object.GetCommit(s, plumbing.NewHash(inst.Location.Ref)); err == nil {
+			committed = c.Committer.When.UTC()
+		}
+	}
+	a, err := WriteTimestampReport(ctx, assets, AnalyzeTimestamps(t, ts, published, committed))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// publishTime returns the time the target was published to its registry.
+func publishTime(ctx context.Context, t Target, mux RegistryMux) (time.Time, error) {
+	switch t.Ecosystem {
+	case NPM:
+		p, err := mux.NPM.Package(ctx, t.Package)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return p.UploadTimes[t.Version], nil
+	case PyPI:
+		release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, a := range release.Artifacts {
+			if a.Filename == t.Artifact {
+				return a.UploadTime, nil
+			}
+		}
+		return time.Time{}, nil
+	case CratesIO:
+		v, err := mux.CratesIO.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return v.Created, nil
+	default:
+		return time.Time{}, errors.New("unsupported ecosystem")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/archive"
+)
+
+func TestAnalyzeTimestamps(t *testing.T) {
+	target := Target{Ecosystem: NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"}
+	committed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	built := archive.Timestamp{Source: archive.GzipHeaderSource, Time: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}
+	npmFixed := archive.Timestamp{Source: archive.TarEntrySource, Path: "package/index.js", Time: time.Date(1985, 10, 26, 8, 15, 0, 0, time.UTC)}
+	late := archive.Timestamp{Source: archive.TarEntrySource, Path: "package/payload.js", Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	for _, tc := range []struct {
+		name      string
+		ts        []archive.Timestamp
+		published time.Time
+		committed time.Time
+		wantBuilt time.Time
+		wantTS    []archive.Timestamp
+		wantKinds []AnomalyKind
+	}{
+		{
+			name:      "Consistent",
+			ts:        []archive.Timestamp{npmFixed, built},
+			published: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+			committed: committed,
+			wantBuilt: built.Time,
+			wantTS:    []archive.Timestamp{built},
+		},
+		{
+			name:      "AfterPublish",
+			ts:        []archive.Timestamp{built, late},
+			published: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+			wantBuilt: late.Time,
+			wantTS:    []archive.Timestamp{built, late},
+			wantKinds: []AnomalyKind{AnomalyAfterPublish},
+		},
+		{
+			name:      "LongGaps",
+			ts:        []archive.Timestamp{late},
+			published: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			committed: committed,
+			wantBuilt: late.Time,
+			wantTS:    []archive.Timestamp{late},
+			wantKinds: []AnomalyKind{AnomalyBuildAfterCommit, AnomalyPublishAfterBuild},
+		},
+		{
+			name:      "OnlyFixed",
+			ts:        []archive.Timestamp{npmFixed},
+			published: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+			committed: committed,
+			wantTS:    []archive.Timestamp{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := AnalyzeTimestamps(target, tc.ts, tc.published, tc.committed)
+			if !r.Built.Equal(tc.wantBuilt) {
+				t.Errorf("AnalyzeTimestamps() built = %v, want %v", r.Built, tc.wantBuilt)
+			}
+			if diff := cmp.Diff(tc.wantTS, r.Timestamps); diff != "" {
+				t.Errorf("AnalyzeTimestamps() timestamps mismatch (-want +got):\n%s", diff)
+			}
+			var kinds []AnomalyKind
+			for _, a := range r.Anomalies {
+				kinds = append(kinds, a.Kind)
+			}
+			if diff := cmp.Diff(tc.wantKinds, kinds); diff != "" {
+				t.Errorf("AnalyzeTimestamps() anomalies mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
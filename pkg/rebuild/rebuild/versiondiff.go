@@ -0,0 +1,120 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/pkg/errors"
+)
+
+// ChangeKind describes how a file differs between two versions of a package.
+type ChangeKind string
+
+const (
+	// ChangeAdded marks a file present only in the newer version.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved marks a file present only in the older version.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified marks a file present in both versions with different content.
+	ChangeModified ChangeKind = "modified"
+)
+
+// versionPlaceholder replaces the version within paths so that files in
+// versioned directories (e.g. "pkg-1.0.0/" or "pkg-1.0.0.dist-info/") are
+// matched across versions.
+const versionPlaceholder = "{version}"
+
+// FileChange is a single file that differs between two versions of a package.
+type FileChange struct {
+	// Path is the file's path with the version replaced by a placeholder.
+	Path    string     `json:"path"`
+	Change  ChangeKind `json:"change"`
+	OldHash string     `json:"old_hash,omitempty"`
+	NewHash string     `json:"new_hash,omitempty"`
+	// Reasons are the heuristics indicating the file is likely to be executed.
+	Reasons []AdditionReason `json:"reasons,omitempty"`
+}
+
+// VersionDiff summarizes the differences between the stabilized artifacts
+// of two versions of the same package.
+type VersionDiff struct {
+	Old     Target       `json:"old"`
+	New     Target       `json:"new"`
+	Changes []FileChange `json:"changes"`
+}
+
+// Count returns the number of changes of the given kind.
+func (d *VersionDiff) Count(kind ChangeKind) int {
+	var n int
+	for _, c := range d.Changes {
+		if c.Change == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// DiffVersions compares the content summaries of the stabilized artifacts of
+// two versions of a package.
+//
+// Unlike a diff of the upstream artifacts, the inputs are produced by
+// rebuilding each version from source so the report reflects what changed in
+// the build output rather than any packaging noise.
+func DiffVersions(old, new Target, csOld, csNew *archive.ContentSummary) (*VersionDiff, error) {
+	if old.Ecosystem != new.Ecosystem || old.Package != new.Package {
+		return nil, errors.Errorf("targets are not versions of the same package: %s/%s and %s/%s", old.Ecosystem, old.Package, new.Ecosystem, new.Package)
+	}
+	oldFiles := versionlessFiles(csOld, old.Version)
+	newFiles := versionlessFiles(csNew, new.Version)
+	d := &VersionDiff{Old: old, New: new, Changes: []FileChange{}}
+	for p, h := range oldFiles {
+		if nh, ok := newFiles[p]; !ok {
+			d.Changes = append(d.Changes, FileChange{Path: p, Change: ChangeRemoved, OldHash: h})
+		} else if nh != h {
+			d.Changes = append(d.Changes, FileChange{Path: p, Change: ChangeModified, OldHash: h, NewHash: nh, Reasons: executionReasons(p)})
+		}
+	}
+	for p, h := range newFiles {
+		if _, ok := oldFiles[p]; !ok {
+			d.Changes = append(d.Changes, FileChange{Path: p, Change: ChangeAdded, NewHash: h, Reasons: executionReasons(p)})
+		}
+	}
+	sort.Slice(d.Changes, func(i, j int) bool { return d.Changes[i].Path < d.Changes[j].Path })
+	return d, nil
+}
+
+// executionReasons returns the heuristics indicating the file is likely to be executed.
+func executionReasons(p string) []AdditionReason {
+	// NOTE: Omit ReasonInjected which applies only to comparisons with upstream.
+	if r := classifyAddition(p)[1:]; len(r) > 0 {
+		return r
+	}
+	return nil
+}
+
+// versionlessFiles maps the summary's files, with the version replaced by a placeholder, to their hashes.
+func versionlessFiles(cs *archive.ContentSummary, version string) map[string]string {
+	files := make(map[string]string, len(cs.Files))
+	for i, f := range cs.Files {
+		if version != "" {
+			f = strings.ReplaceAll(f, version, versionPlaceholder)
+		}
+		files[f] = cs.FileHashes[i]
+	}
+	return files
+}
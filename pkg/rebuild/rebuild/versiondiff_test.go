@@ -0,0 +1,58 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rebuild
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/archive"
+)
+
+func TestDiffVersions(t *testing.T) {
+	old := Target{Ecosystem: CratesIO, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.crate"}
+	new := Target{Ecosystem: CratesIO, Package: "pkg", Version: "1.1.0", Artifact: "pkg-1.1.0.crate"}
+	csOld := &archive.ContentSummary{
+		Files:      []string{"pkg-1.0.0/Cargo.toml", "pkg-1.0.0/README.md", "pkg-1.0.0/src/lib.rs"},
+		FileHashes: []string{"c1", "r", "l"},
+	}
+	csNew := &archive.ContentSummary{
+		Files:      []string{"pkg-1.1.0/Cargo.toml", "pkg-1.1.0/build.rs", "pkg-1.1.0/src/lib.rs"},
+		FileHashes: []string{"c2", "b", "l"},
+	}
+	got, err := DiffVersions(old, new, csOld, csNew)
+	if err != nil {
+		t.Fatalf("DiffVersions() error = %v", err)
+	}
+	want := &VersionDiff{
+		Old: old,
+		New: new,
+		Changes: []FileChange{
+			{Path: "pkg-{version}/Cargo.toml", Change: ChangeModified, OldHash: "c1", NewHash: "c2"},
+			{Path: "pkg-{version}/README.md", Change: ChangeRemoved, OldHash: "r"},
+			{Path: "pkg-{version}/build.rs", Change: ChangeAdded, NewHash: "b", Reasons: []AdditionReason{ReasonInstallHook}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DiffVersions() mismatch (-want +got):\n%s", diff)
+	}
+	if n := got.Count(ChangeAdded); n != 1 {
+		t.Errorf("Count(ChangeAdded) = %d, want 1", n)
+	}
+	other := Target{Ecosystem: CratesIO, Package: "other", Version: "1.1.0"}
+	if _, err := DiffVersions(old, other, csOld, csNew); err == nil {
+		t.Error("DiffVersions() expected error for different packages")
+	}
+}
@@ -17,10 +17,16 @@
 package schema
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/google/oss-rebuild/pkg/rebuild/cratesio"
 	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/oci"
 	"github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/pkg/errors"
@@ -35,6 +41,7 @@ type StrategyOneOf struct {
 	NPMPackBuild         *npm.NPMPackBuild              `json:"npm_pack_build,omitempty" yaml:"npm_pack_build,omitempty"`
 	NPMCustomBuild       *npm.NPMCustomBuild            `json:"npm_custom_build,omitempty" yaml:"npm_custom_build,omitempty"`
 	CratesIOCargoPackage *cratesio.CratesIOCargoPackage `json:"cratesio_cargo_package,omitempty" yaml:"cratesio_cargo_package,omitempty"`
+	OCIDockerfileBuild   *oci.DockerfileBuild           `json:"oci_dockerfile_build,omitempty" yaml:"oci_dockerfile_build,omitempty"`
 	ManualStrategy       *rebuild.ManualStrategy        `json:"manual,omitempty" yaml:"manual,omitempty"`
 }
 
@@ -52,6 +59,8 @@ func NewStrategyOneOf(s rebuild.Strategy) StrategyOneOf {
 		oneof.NPMCustomBuild = t
 	case *cratesio.CratesIOCargoPackage:
 		oneof.CratesIOCargoPackage = t
+	case *oci.DockerfileBuild:
+		oneof.OCIDockerfileBuild = t
 	case *rebuild.ManualStrategy:
 		oneof.ManualStrategy = t
 	}
@@ -83,6 +92,10 @@ func (oneof *StrategyOneOf) Strategy() (rebuild.Strategy, error) {
 			num++
 			s = oneof.CratesIOCargoPackage
 		}
+		if oneof.OCIDockerfileBuild != nil {
+			num++
+			s = oneof.OCIDockerfileBuild
+		}
 		if oneof.ManualStrategy != nil {
 			num++
 			s = oneof.ManualStrategy
@@ -112,28 +125,42 @@ type VersionResponse struct {
 
 // SmoketestRequest is a single request to the smoketest endpoint.
 type SmoketestRequest struct {
-	Ecosystem rebuild.Ecosystem `form:",required"`
-	Package   string            `form:",required"`
-	Versions  []string          `form:",required"`
-	ID        string            `form:",required"`
-	Strategy  *StrategyOneOf    `form:""`
+	Ecosystem rebuild.Ecosystem  `form:",required"`
+	Package   string             `form:",required"`
+	Versions  []string           `form:",required"`
+	ID        string             `form:",required"`
+	Strategy  *StrategyOneOf     `form:""`
+	Resources *rebuild.Resources `form:""`
+	// DisableInstallHooks prevents install-time scripts from running while
+	// dependencies are installed.
+	DisableInstallHooks bool `form:""`
 }
 
 var _ Message = SmoketestRequest{}
 
-func (SmoketestRequest) Validate() error { return nil }
+func (req SmoketestRequest) Validate() error {
+	if req.Resources != nil {
+		return req.Resources.Validate()
+	}
+	return nil
+}
 
 // ToInputs converts a SmoketestRequest into rebuild.Input objects.
 func (req SmoketestRequest) ToInputs() ([]rebuild.Input, error) {
 	var inputs []rebuild.Input
 	for _, v := range req.Versions {
-		inputs = append(inputs, rebuild.Input{
+		input := rebuild.Input{
 			Target: rebuild.Target{
 				Ecosystem: req.Ecosystem,
 				Package:   req.Package,
 				Version:   v,
 			},
-		})
+			DisableInstallHooks: req.DisableInstallHooks,
+		}
+		if req.Resources != nil {
+			input.Resources = *req.Resources
+		}
+		inputs = append(inputs, input)
 	}
 	if req.Strategy != nil {
 		if len(inputs) != 1 {
@@ -153,27 +180,49 @@ type Verdict struct {
 	Message       string
 	StrategyOneof StrategyOneOf
 	Timings       rebuild.Timings
+	Risk          *rebuild.RiskAssessment `json:",omitempty"`
+	// InstallHooks is present if the rebuild was run with install hooks disabled.
+	InstallHooks *rebuild.InstallHookReport `json:",omitempty"`
+	// LogFailure is present if the likely cause of a failure was found in the build logs.
+	LogFailure *rebuild.LogFailure `json:",omitempty"`
 }
 
 // SmoketestResponse is the result of a rebuild smoketest.
 type SmoketestResponse struct {
 	Verdicts []Verdict
 	Executor string
+	// DependencyCacheKey, if non-empty, identifies the dependency caches available to the rebuilds.
+	DependencyCacheKey string
 }
 
 // RebuildPackageRequest is a single request to the rebuild package endpoint.
 type RebuildPackageRequest struct {
-	// TODO: Should this also include Artifact?
-	Ecosystem        rebuild.Ecosystem `form:",required"`
-	Package          string            `form:",required"`
-	Version          string            `form:""`
-	ID               string            `form:",required"`
-	StrategyFromRepo bool              `form:""`
+	Ecosystem rebuild.Ecosystem `form:",required"`
+	Package   string            `form:",required"`
+	Version   string            `form:""`
+	// Artifact, if provided, selects the artifact to rebuild instead of the ecosystem default.
+	Artifact         string             `form:""`
+	ID               string             `form:",required"`
+	StrategyFromRepo bool               `form:""`
+	Resources        *rebuild.Resources `form:""`
+	// BypassCache executes the build even if the result of an identical build is cached.
+	BypassCache bool `form:""`
+	// AllArtifacts additionally rebuilds and attests the other supported
+	// artifacts of the version within the same build.
+	AllArtifacts bool `form:""`
+	// DisableInstallHooks prevents install-time scripts from running while
+	// dependencies are installed.
+	DisableInstallHooks bool `form:""`
 }
 
 var _ Message = RebuildPackageRequest{}
 
-func (RebuildPackageRequest) Validate() error { return nil }
+func (req RebuildPackageRequest) Validate() error {
+	if req.Resources != nil {
+		return req.Resources.Validate()
+	}
+	return nil
+}
 
 // InferenceRequest is a single request to the inference endpoint.
 type InferenceRequest struct {
@@ -200,10 +249,31 @@ func (req InferenceRequest) LocationHint() *rebuild.LocationHint {
 	return s.(*rebuild.LocationHint)
 }
 
+// LogsRequest is a request to follow the logs of a remote rebuild.
+type LogsRequest struct {
+	Ecosystem rebuild.Ecosystem `form:",required"`
+	Package   string            `form:",required"`
+	Version   string            `form:",required"`
+	Artifact  string            `form:",required"`
+	ID        string            `form:",required"`
+}
+
+var _ Message = LogsRequest{}
+
+func (LogsRequest) Validate() error { return nil }
+
+// Target returns the rebuild target whose logs are requested.
+func (req LogsRequest) Target() rebuild.Target {
+	return rebuild.Target{Ecosystem: req.Ecosystem, Package: req.Package, Version: req.Version, Artifact: req.Artifact}
+}
+
 type CreateRunRequest struct {
 	Name string `form:","`
 	Type string `form:","`
 	Hash string `form:","`
+	// Repo and Commit identify the revision of the benchmark repository from which the benchmark was read, if any.
+	Repo   string `form:""`
+	Commit string `form:""`
 }
 
 var _ Message = CreateRunRequest{}
@@ -220,6 +290,429 @@ type CreateRunResponse struct {
 	ID string
 }
 
+// RunConfig is a snapshot of the service configuration recorded when a run is created.
+//
+// Results from a run should be interpreted against this snapshot rather than
+// the current state of the services, which may have changed since.
+type RunConfig struct {
+	BenchmarkName     string   `json:"benchmark_name" firestore:"benchmark_name,omitempty"`
+	BenchmarkHash     string   `json:"benchmark_hash" firestore:"benchmark_hash,omitempty"`
+	BenchmarkRepo     string   `json:"benchmark_repo,omitempty" firestore:"benchmark_repo,omitempty"`
+	BenchmarkCommit   string   `json:"benchmark_commit,omitempty" firestore:"benchmark_commit,omitempty"`
+	RunType           string   `json:"run_type" firestore:"run_type,omitempty"`
+	APIVersion        string   `json:"api_version" firestore:"api_version,omitempty"`
+	BuildLocalVersion string   `json:"build_local_version" firestore:"build_local_version,omitempty"`
+	InferenceVersion  string   `json:"inference_version" firestore:"inference_version,omitempty"`
+	BuildDefRepo      string   `json:"build_def_repo,omitempty" firestore:"build_def_repo,omitempty"`
+	PrebuildBucket    string   `json:"prebuild_bucket,omitempty" firestore:"prebuild_bucket,omitempty"`
+	Stabilizers       []string `json:"stabilizers" firestore:"stabilizers,omitempty"`
+}
+
+// Modes in which a batch of targets may be executed.
+const (
+	BatchSmoketestMode = "smoketest"
+	BatchAttestMode    = "attest"
+)
+
+// MaxBatchSize is the largest number of targets accepted in a single BatchRequest.
+const MaxBatchSize = 10000
+
+// BatchRequest requests the execution of a group of targets as a single job.
+//
+// The targets are provided either directly or as a reference to a benchmark.
+type BatchRequest struct {
+	Mode    string           `form:",required"`
+	Targets []rebuild.Target `form:""`
+	// Benchmark is the path of a benchmark in the service's benchmark
+	// repository whose packages are to be executed, optionally suffixed with
+	// "@<ref>" to select the revision from which it is read.
+	Benchmark string `form:""`
+	// Name optionally identifies the group of targets, such as the benchmark or SBOM from which they were drawn.
+	Name string `form:""`
+}
+
+var _ Message = BatchRequest{}
+
+func (req BatchRequest) Validate() error {
+	if req.Mode != BatchSmoketestMode && req.Mode != BatchAttestMode {
+		return errors.Errorf("unknown mode: %s", req.Mode)
+	}
+	if req.Benchmark != "" {
+		if len(req.Targets) > 0 {
+			return errors.New("targets and benchmark are mutually exclusive")
+		}
+		return nil
+	}
+	if len(req.Targets) == 0 {
+		return errors.New("no targets or benchmark provided")
+	}
+	if len(req.Targets) > MaxBatchSize {
+		return errors.Errorf("too many targets: %d > %d", len(req.Targets), MaxBatchSize)
+	}
+	for _, t := range req.Targets {
+		if t.Ecosystem == "" || t.Package == "" || t.Version == "" {
+			return errors.Errorf("incomplete target: %v", t)
+		}
+	}
+	return nil
+}
+
+// Hash returns a hex-encoded digest identifying the set of targets in the batch.
+func (req BatchRequest) Hash() string {
+	keys := make([]string, len(req.Targets))
+	for i, t := range req.Targets {
+		keys[i] = strings.Join([]string{string(t.Ecosystem), t.Package, t.Version, t.Artifact}, "\x00")
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// BatchResponse identifies the job created for a BatchRequest.
+type BatchResponse struct {
+	// ID is the job handle and the ID of the run under which results are recorded.
+	ID    string
+	Total int
+}
+
+// BatchTargetRequest requests the execution of a single target of a batch job.
+// It is delivered by the task queue for each target of a BatchRequest.
+type BatchTargetRequest struct {
+	BatchID   string            `form:",required"`
+	Mode      string            `form:",required"`
+	Ecosystem rebuild.Ecosystem `form:",required"`
+	Package   string            `form:",required"`
+	Version   string            `form:",required"`
+	Artifact  string            `form:""`
+}
+
+var _ Message = BatchTargetRequest{}
+
+func (req BatchTargetRequest) Validate() error {
+	if req.Mode != BatchSmoketestMode && req.Mode != BatchAttestMode {
+		return errors.Errorf("unknown mode: %s", req.Mode)
+	}
+	return nil
+}
+
+// Target returns the target to be executed.
+func (req BatchTargetRequest) Target() rebuild.Target {
+	return rebuild.Target{Ecosystem: req.Ecosystem, Package: req.Package, Version: req.Version, Artifact: req.Artifact}
+}
+
+// BatchStatusRequest requests the progress of a batch job.
+type BatchStatusRequest struct {
+	ID string `form:",required"`
+	// Results, if set, requests the outcome of each completed target.
+	Results bool `form:""`
+}
+
+var _ Message = BatchStatusRequest{}
+
+func (BatchStatusRequest) Validate() error { return nil }
+
+// BatchStatus is the aggregate progress of a batch job.
+type BatchStatus struct {
+	ID        string `firestore:"-"`
+	Mode      string `firestore:"mode,omitempty"`
+	Name      string `firestore:"name,omitempty"`
+	Total     int    `firestore:"total"`
+	Succeeded int    `firestore:"succeeded"`
+	Failed    int    `firestore:"failed"`
+	Created   int64  `firestore:"created,omitempty"`
+	// Finished is the time at which all targets completed, or zero if the job is in progress.
+	Finished int64 `firestore:"finished,omitempty"`
+	// Results are the outcomes of the completed targets, if requested.
+	Results []BatchResult `firestore:"-"`
+}
+
+// BatchResult is the outcome of a single target from a batch job.
+type BatchResult struct {
+	Target  rebuild.Target `firestore:"target"`
+	Success bool           `firestore:"success"`
+	Message string         `firestore:"message,omitempty"`
+}
+
+// Done returns whether all targets in the job have completed.
+func (s BatchStatus) Done() bool {
+	return s.Succeeded+s.Failed >= s.Total
+}
+
+// StatusRequest is a request for the latest attested rebuild status of a package version.
+type StatusRequest struct {
+	Ecosystem rebuild.Ecosystem `form:",required"`
+	Package   string            `form:",required"`
+	Version   string            `form:",required"`
+	// Artifact, if provided, restricts the status to that of a single artifact.
+	Artifact string `form:""`
+}
+
+var _ Message = StatusRequest{}
+
+func (StatusRequest) Validate() error { return nil }
+
+// RebuildStatus is the outcome of the latest attested rebuild of an artifact.
+type RebuildStatus struct {
+	Ecosystem string `firestore:"ecosystem,omitempty"`
+	Package   string `firestore:"package,omitempty"`
+	Version   string `firestore:"version,omitempty"`
+	Artifact  string `firestore:"artifact,omitempty"`
+	Success   bool   `firestore:"success"`
+	Message   string `firestore:"message,omitempty"`
+	RunID     string `firestore:"run_id,omitempty"`
+	Updated   int64  `firestore:"updated,omitempty"`
+	// Match describes how a successful rebuild corresponded to upstream.
+	// Empty for failures and for statuses recorded before it was tracked.
+	Match MatchKind `firestore:"match,omitempty"`
+}
+
+// MatchKind describes how a successful rebuild corresponded to upstream.
+type MatchKind string
+
+const (
+	// ExactMatch indicates the rebuild was bit-for-bit identical to upstream.
+	ExactMatch MatchKind = "exact"
+	// StabilizedMatch indicates the rebuild was equivalent to upstream only after stabilization.
+	StabilizedMatch MatchKind = "stabilized"
+)
+
+// StatusResponse reports the latest rebuild status of each of a version's artifacts.
+type StatusResponse struct {
+	Artifacts []RebuildStatus
+	// Annotations are the notes attached to the version's results, oldest first.
+	Annotations []Annotation
+}
+
+// maxAnnotationLength is the maximum size in bytes of the text of an Annotation.
+const maxAnnotationLength = 4096
+
+// Annotation is a note attached to the rebuild results of a package version,
+// such as a maintainer's explanation of a mismatch.
+type Annotation struct {
+	Ecosystem string `firestore:"ecosystem,omitempty"`
+	Package   string `firestore:"package,omitempty"`
+	Version   string `firestore:"version,omitempty"`
+	// Artifact, if non-empty, is the single artifact to which the annotation applies.
+	Artifact string `firestore:"artifact"`
+	Author   string `firestore:"author,omitempty"`
+	Text     string `firestore:"text,omitempty"`
+	// Link is an optional URL with further context, such as an upstream issue.
+	Link    string `firestore:"link,omitempty"`
+	Created int64  `firestore:"created,omitempty"`
+}
+
+// AnnotateRequest attaches an Annotation to the rebuild results of a package version.
+// The annotation's author is the authenticated caller.
+type AnnotateRequest struct {
+	Ecosystem rebuild.Ecosystem `form:",required"`
+	Package   string            `form:",required"`
+	Version   string            `form:",required"`
+	Artifact  string            `form:""`
+	Text      string            `form:",required"`
+	Link      string            `form:""`
+}
+
+var _ Message = AnnotateRequest{}
+
+func (req AnnotateRequest) Validate() error {
+	if len(req.Text) > maxAnnotationLength {
+		return errors.Errorf("text exceeds %d bytes", maxAnnotationLength)
+	}
+	if req.Link != "" {
+		u, err := url.Parse(req.Link)
+		if err != nil {
+			return errors.Wrap(err, "parsing link")
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return errors.Errorf("unsupported link scheme: %s", u.Scheme)
+		}
+	}
+	return nil
+}
+
+// AnnotateResponse identifies the Annotation created for an AnnotateRequest.
+type AnnotateResponse struct {
+	ID string
+}
+
+// Reproducible returns whether any artifact was rebuilt and none failed to be.
+func (r StatusResponse) Reproducible() bool {
+	for _, a := range r.Artifacts {
+		if !a.Success {
+			return false
+		}
+	}
+	return len(r.Artifacts) > 0
+}
+
+// StatsRequest requests aggregate reproducibility statistics of attested rebuilds.
+type StatsRequest struct {
+	// Ecosystem, if provided, restricts the statistics to a single ecosystem.
+	Ecosystem rebuild.Ecosystem `form:""`
+}
+
+var _ Message = StatsRequest{}
+
+func (StatsRequest) Validate() error { return nil }
+
+// ReproducibilityStats counts the outcomes of attested rebuilds.
+type ReproducibilityStats struct {
+	Attempted    int `firestore:"attempted"`
+	Reproducible int `firestore:"reproducible"`
+	// Exact and Stabilized break down the reproducible artifacts by how they
+	// matched upstream. Their sum is less than Reproducible if some statuses
+	// predate the recording of the match.
+	Exact      int `firestore:"exact"`
+	Stabilized int `firestore:"stabilized"`
+	Failed     int `firestore:"failed"`
+}
+
+// OutcomeStats returns the statistics counting the single outcome of status.
+func OutcomeStats(status RebuildStatus) ReproducibilityStats {
+	s := ReproducibilityStats{Attempted: 1}
+	if !status.Success {
+		s.Failed = 1
+		return s
+	}
+	s.Reproducible = 1
+	switch status.Match {
+	case ExactMatch:
+		s.Exact = 1
+	case StabilizedMatch:
+		s.Stabilized = 1
+	}
+	return s
+}
+
+// Add returns the sum of the counts of s and o.
+func (s ReproducibilityStats) Add(o ReproducibilityStats) ReproducibilityStats {
+	return ReproducibilityStats{
+		Attempted:    s.Attempted + o.Attempted,
+		Reproducible: s.Reproducible + o.Reproducible,
+		Exact:        s.Exact + o.Exact,
+		Stabilized:   s.Stabilized + o.Stabilized,
+		Failed:       s.Failed + o.Failed,
+	}
+}
+
+// Sub returns the difference of the counts of s and o.
+func (s ReproducibilityStats) Sub(o ReproducibilityStats) ReproducibilityStats {
+	return ReproducibilityStats{
+		Attempted:    s.Attempted - o.Attempted,
+		Reproducible: s.Reproducible - o.Reproducible,
+		Exact:        s.Exact - o.Exact,
+		Stabilized:   s.Stabilized - o.Stabilized,
+		Failed:       s.Failed - o.Failed,
+	}
+}
+
+// EcosystemStats are the reproducibility statistics of a single ecosystem.
+type EcosystemStats struct {
+	Ecosystem rebuild.Ecosystem
+	// ReproducibilityStats counts the latest outcome of each artifact.
+	ReproducibilityStats
+	// Months counts the outcomes of every attested rebuild executed in each
+	// month, in YYYY-MM form, including those since superseded.
+	Months map[string]ReproducibilityStats
+}
+
+// StatsMonth returns the key within EcosystemStats.Months of the month of the
+// time, in milliseconds since the Unix epoch.
+func StatsMonth(updated int64) string {
+	return time.UnixMilli(updated).UTC().Format("2006-01")
+}
+
+// StatsResponse is the aggregate reproducibility statistics of each ecosystem.
+type StatsResponse struct {
+	// Ecosystems are ordered by name.
+	Ecosystems []EcosystemStats
+}
+
+// SBOMRequest requests the execution of the components of an SBOM as a batch job.
+type SBOMRequest struct {
+	Mode string `form:",required"`
+	// SBOM is a CycloneDX or SPDX document in its JSON encoding.
+	SBOM string `form:",required"`
+	Name string `form:""`
+}
+
+var _ Message = SBOMRequest{}
+
+func (req SBOMRequest) Validate() error {
+	if req.Mode != BatchSmoketestMode && req.Mode != BatchAttestMode {
+		return errors.Errorf("unknown mode: %s", req.Mode)
+	}
+	return nil
+}
+
+// SBOMResponse identifies the batch job created for an SBOMRequest.
+type SBOMResponse struct {
+	BatchResponse
+	// Unsupported lists the SBOM components that could not be mapped to a rebuild target.
+	Unsupported []string
+}
+
+// QuarantineReviewRequest records a reviewer's action on a quarantined rebuild result.
+// The reviewer is the authenticated caller of the request.
+type QuarantineReviewRequest struct {
+	Ecosystem rebuild.Ecosystem `form:",required"`
+	Package   string            `form:",required"`
+	Version   string            `form:",required"`
+	Artifact  string            `form:",required"`
+	// Action is one of "propose_release", "propose_escalation", "approve", or "reject".
+	Action string `form:",required"`
+	Note   string `form:""`
+}
+
+var _ Message = QuarantineReviewRequest{}
+
+func (req QuarantineReviewRequest) Validate() error {
+	if len(req.Note) > maxAnnotationLength {
+		return errors.Errorf("note exceeds %d bytes", maxAnnotationLength)
+	}
+	return nil
+}
+
+// QuarantineReviewResponse is the state of a quarantined rebuild result after a review.
+type QuarantineReviewResponse struct {
+	State string
+	// AdvisoryURL locates the advisory draft opened upon escalation, if any.
+	AdvisoryURL string `json:",omitempty"`
+}
+
+// PlatformRunRequest is a request to execute a build script on a platform runner.
+type PlatformRunRequest struct {
+	Target    rebuild.Target     `form:",required"`
+	Script    string             `form:",required"`
+	Resources *rebuild.Resources `form:""`
+}
+
+var _ Message = PlatformRunRequest{}
+
+func (req PlatformRunRequest) Validate() error {
+	if req.Target.Artifact == "" {
+		return errors.New("artifact must be provided")
+	}
+	if req.Resources != nil {
+		return req.Resources.Validate()
+	}
+	return nil
+}
+
+// PlatformRunResponse is the result of executing a build on a platform runner.
+type PlatformRunResponse struct {
+	Artifact []byte
+	Logs     string
+	// Runner identifies the host that executed the build.
+	Runner string
+	// Error describes the failure of the build, in which case Artifact is empty.
+	Error string
+}
+
 // SmoketestAttempt stores rebuild and execution metadata on a single smoketest run.
 type SmoketestAttempt struct {
 	Ecosystem         string  `firestore:"ecosystem,omitempty"`
@@ -234,6 +727,57 @@ type SmoketestAttempt struct {
 	TimeInfer         float64 `firestore:"time_infer,omitempty"`
 	TimeBuild         float64 `firestore:"time_build,omitempty"`
 	ExecutorVersion   string  `firestore:"executor_version,omitempty"`
+	DependencyCache   string  `firestore:"dependency_cache,omitempty"`
 	RunID             string  `firestore:"run_id,omitempty"`
 	Created           int64   `firestore:"created,omitempty"`
+	// RiskScore and RiskEvidence record the rebuild.RiskAssessment of a mismatch, if any.
+	// RiskEvidence is the JSON-encoded list of rebuild.RiskEvidence.
+	RiskScore    int    `firestore:"risk_score,omitempty"`
+	RiskEvidence string `firestore:"risk_evidence,omitempty"`
+	// InstallHooks, present only if the rebuild ran with install hooks disabled,
+	// is the JSON-encoded list of rebuild.RiskEvidence describing the upstream
+	// hooks that could have affected the artifact.
+	InstallHooks string `firestore:"install_hooks,omitempty"`
+	// FailureSnippet is the excerpt of the build logs likely to explain a failure.
+	FailureSnippet string `firestore:"failure_snippet,omitempty"`
+}
+
+// UpstreamDigest records the digest of the upstream artifact served by the
+// registry across the rebuilds of a target.
+//
+// Published artifacts are expected to be immutable so a change in the digest
+// indicates the registry has served different content for the same version.
+type UpstreamDigest struct {
+	Ecosystem string `firestore:"ecosystem,omitempty"`
+	Package   string `firestore:"package,omitempty"`
+	Version   string `firestore:"version,omitempty"`
+	Artifact  string `firestore:"artifact,omitempty"`
+	// Digest is the most recently observed digest, in "sha256:<hex>" form.
+	Digest    string `firestore:"digest,omitempty"`
+	FirstRun  string `firestore:"first_run,omitempty"`
+	LastRun   string `firestore:"last_run,omitempty"`
+	FirstSeen int64  `firestore:"first_seen,omitempty"`
+	LastSeen  int64  `firestore:"last_seen,omitempty"`
+	// PreviousDigest and Mutated record the most recent change of the digest, if any.
+	PreviousDigest string `firestore:"previous_digest,omitempty"`
+	Mutated        int64  `firestore:"mutated,omitempty"`
+}
+
+// Observe updates the record with a digest served by the registry and returns
+// whether it differs from that previously observed.
+func (d *UpstreamDigest) Observe(digest, runID string, now time.Time) (changed bool) {
+	ts := now.UTC().UnixMilli()
+	switch d.Digest {
+	case "":
+		d.FirstRun, d.FirstSeen = runID, ts
+	case digest:
+	default:
+		changed = true
+		d.PreviousDigest, d.Mutated = d.Digest, ts
+		// NOTE: The first observation is reset so it refers to the current content.
+		d.FirstRun, d.FirstSeen = runID, ts
+	}
+	d.Digest = digest
+	d.LastRun, d.LastSeen = runID, ts
+	return changed
 }
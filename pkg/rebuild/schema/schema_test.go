@@ -24,6 +24,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/oss-rebuild/pkg/rebuild/cratesio"
 	"github.com/google/oss-rebuild/pkg/rebuild/npm"
+	"github.com/google/oss-rebuild/pkg/rebuild/oci"
 	"github.com/google/oss-rebuild/pkg/rebuild/pypi"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	yaml "gopkg.in/yaml.v3"
@@ -149,6 +150,31 @@ cratesio_cargo_package:
     repo: the_repo
     ref: the_ref
     dir: the_dir
+`,
+	},
+	{
+		name: "OCIDockerfileBuild",
+		strategy: &oci.DockerfileBuild{
+			Location: rebuild.Location{
+				Dir:  "the_dir",
+				Ref:  "the_ref",
+				Repo: "the_repo",
+			},
+			Dockerfile: "Dockerfile",
+			BaseImages: map[string]string{"alpine:3.19": "sha256:abc"},
+			Platform:   "linux/amd64",
+		},
+		jsonEncoded: `{"oci_dockerfile_build":{"repo":"the_repo","ref":"the_ref","dir":"the_dir","dockerfile":"Dockerfile","base_images":{"alpine:3.19":"sha256:abc"},"platform":"linux/amd64"}}`,
+		yamlEncoded: `
+oci_dockerfile_build:
+  location:
+    repo: the_repo
+    ref: the_ref
+    dir: the_dir
+  dockerfile: Dockerfile
+  base_images:
+    alpine:3.19: sha256:abc
+  platform: linux/amd64
 `,
 	},
 	{
@@ -284,3 +310,113 @@ func TestJsonMarshalStrategyRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestBatchRequest(t *testing.T) {
+	a := rebuild.Target{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0"}
+	b := rebuild.Target{Ecosystem: rebuild.PyPI, Package: "absl-py", Version: "2.0.0"}
+	for _, tc := range []struct {
+		name    string
+		req     BatchRequest
+		wantErr bool
+	}{
+		{"valid", BatchRequest{Mode: BatchSmoketestMode, Targets: []rebuild.Target{a, b}}, false},
+		{"unknown mode", BatchRequest{Mode: "bogus", Targets: []rebuild.Target{a}}, true},
+		{"no targets", BatchRequest{Mode: BatchAttestMode}, true},
+		{"benchmark", BatchRequest{Mode: BatchAttestMode, Benchmark: "npm/top100.json@main"}, false},
+		{"targets and benchmark", BatchRequest{Mode: BatchAttestMode, Targets: []rebuild.Target{a}, Benchmark: "npm/top100.json"}, true},
+		{"incomplete target", BatchRequest{Mode: BatchAttestMode, Targets: []rebuild.Target{{Ecosystem: rebuild.NPM, Package: "left-pad"}}}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.req.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() = %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+	forward := BatchRequest{Mode: BatchSmoketestMode, Targets: []rebuild.Target{a, b}}
+	reverse := BatchRequest{Mode: BatchSmoketestMode, Targets: []rebuild.Target{b, a}}
+	if forward.Hash() != reverse.Hash() {
+		t.Errorf("Hash() depends on target order: %s != %s", forward.Hash(), reverse.Hash())
+	}
+	if single := (BatchRequest{Mode: BatchSmoketestMode, Targets: []rebuild.Target{a}}); single.Hash() == forward.Hash() {
+		t.Errorf("Hash() collision between distinct target sets")
+	}
+}
+
+func TestAnnotateRequest(t *testing.T) {
+	base := AnnotateRequest{Ecosystem: rebuild.NPM, Package: "left-pad", Version: "1.3.0", Text: "mismatch caused by signing"}
+	withLink := func(link string) AnnotateRequest {
+		req := base
+		req.Link = link
+		return req
+	}
+	long := base
+	long.Text = strings.Repeat("x", maxAnnotationLength+1)
+	for _, tc := range []struct {
+		name    string
+		req     AnnotateRequest
+		wantErr bool
+	}{
+		{"valid", base, false},
+		{"valid link", withLink("https://github.com/left-pad/left-pad/issues/1"), false},
+		{"unsupported link scheme", withLink("javascript:alert(1)"), true},
+		{"text too long", long, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.req.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() = %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestOutcomeStats(t *testing.T) {
+	for _, tc := range []struct {
+		status RebuildStatus
+		want   ReproducibilityStats
+	}{
+		{RebuildStatus{Success: true, Match: ExactMatch}, ReproducibilityStats{Attempted: 1, Reproducible: 1, Exact: 1}},
+		{RebuildStatus{Success: true, Match: StabilizedMatch}, ReproducibilityStats{Attempted: 1, Reproducible: 1, Stabilized: 1}},
+		// Legacy statuses have no match.
+		{RebuildStatus{Success: true}, ReproducibilityStats{Attempted: 1, Reproducible: 1}},
+		{RebuildStatus{Success: false}, ReproducibilityStats{Attempted: 1, Failed: 1}},
+	} {
+		if got := OutcomeStats(tc.status); got != tc.want {
+			t.Errorf("OutcomeStats(%+v) = %+v, want %+v", tc.status, got, tc.want)
+		}
+	}
+	// Replacing a failure with a stabilized success moves a count between fields.
+	got := OutcomeStats(RebuildStatus{Success: true, Match: StabilizedMatch}).Sub(OutcomeStats(RebuildStatus{}))
+	want := ReproducibilityStats{Reproducible: 1, Stabilized: 1, Failed: -1}
+	if got != want {
+		t.Errorf("Sub() = %+v, want %+v", got, want)
+	}
+	if got := want.Add(ReproducibilityStats{Attempted: 1, Failed: 1}); got != (ReproducibilityStats{Attempted: 1, Reproducible: 1, Stabilized: 1}) {
+		t.Errorf("Add() = %+v", got)
+	}
+	if got, want := StatsMonth(time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC).UnixMilli()), "2024-02"; got != want {
+		t.Errorf("StatsMonth() = %q, want %q", got, want)
+	}
+}
+
+func TestUpstreamDigestObserve(t *testing.T) {
+	t1 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	t2, t3 := t1.Add(time.Hour), t1.Add(2*time.Hour)
+	var d UpstreamDigest
+	if d.Observe("sha256:aaaa", "run-1", t1) {
+		t.Error("Observe() reported change on first observation")
+	}
+	if d.Observe("sha256:aaaa", "run-2", t2) {
+		t.Error("Observe() reported change for identical digest")
+	}
+	want := UpstreamDigest{Digest: "sha256:aaaa", FirstRun: "run-1", LastRun: "run-2", FirstSeen: t1.UnixMilli(), LastSeen: t2.UnixMilli()}
+	if diff := cmp.Diff(want, d); diff != "" {
+		t.Errorf("UpstreamDigest mismatch (-want +got):\n%s", diff)
+	}
+	if !d.Observe("sha256:bbbb", "run-3", t3) {
+		t.Error("Observe() did not report change for differing digest")
+	}
+	want = UpstreamDigest{Digest: "sha256:bbbb", FirstRun: "run-3", LastRun: "run-3", FirstSeen: t3.UnixMilli(), LastSeen: t3.UnixMilli(), PreviousDigest: "sha256:aaaa", Mutated: t3.UnixMilli()}
+	if diff := cmp.Diff(want, d); diff != "" {
+		t.Errorf("UpstreamDigest mismatch (-want +got):\n%s", diff)
+	}
+}
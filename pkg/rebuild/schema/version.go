@@ -0,0 +1,110 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/schema/form"
+	"github.com/pkg/errors"
+)
+
+// Version identifies a revision of the wire encoding of the API messages.
+type Version int
+
+const (
+	// V0 is the unversioned encoding used before versions were made explicit.
+	V0 Version = iota
+	// V1 is the first explicitly versioned encoding.
+	// It is a superset of V0: Resources and DependencyCacheKey are optional.
+	V1
+
+	// CurrentVersion is the version produced by this build.
+	CurrentVersion = V1
+)
+
+// VersionField is the form field carrying the Version of an encoded request.
+const VersionField = "schema_version"
+
+// VersionHeader is the HTTP header carrying the Version supported by a server.
+const VersionHeader = "X-Schema-Version"
+
+// ErrUnsupportedVersion indicates a message was encoded with a Version newer than CurrentVersion.
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// ParseVersion parses a serialized Version. The empty string denotes V0.
+func ParseVersion(s string) (Version, error) {
+	if s == "" {
+		return V0, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, errors.Errorf("invalid schema version: %q", s)
+	}
+	return Version(v), nil
+}
+
+// Upgrader rewrites the encoding of a message from one Version to the next.
+type Upgrader func(url.Values) (url.Values, error)
+
+type upgradeKey struct {
+	msg  reflect.Type
+	from Version
+}
+
+var upgraders = map[upgradeKey]Upgrader{}
+
+// RegisterUpgrader registers the function to upgrade the encoding of M from
+// the provided Version to the next. Steps without a registered Upgrader are
+// treated as compatible encodings.
+func RegisterUpgrader[M Message](from Version, u Upgrader) {
+	upgraders[upgradeKey{typeOf[M](), from}] = u
+}
+
+func typeOf[M any]() reflect.Type {
+	return reflect.TypeOf((*M)(nil)).Elem()
+}
+
+// Encode serializes the message at CurrentVersion.
+func Encode(m Message) (url.Values, error) {
+	values, err := form.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	values.Set(VersionField, strconv.Itoa(int(CurrentVersion)))
+	return values, nil
+}
+
+// Decode deserializes a message encoded at any Version up to CurrentVersion.
+func Decode[M Message](values url.Values, m *M) error {
+	v, err := ParseVersion(values.Get(VersionField))
+	if err != nil {
+		return err
+	}
+	if v > CurrentVersion {
+		return errors.Wrapf(ErrUnsupportedVersion, "got %d, support up to %d", v, CurrentVersion)
+	}
+	t := typeOf[M]()
+	for ; v < CurrentVersion; v++ {
+		if u, ok := upgraders[upgradeKey{t, v}]; ok {
+			if values, err = u(values); err != nil {
+				return errors.Wrapf(err, "upgrading from version %d", v)
+			}
+		}
+	}
+	return form.Unmarshal(values, m)
+}
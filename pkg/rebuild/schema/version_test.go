@@ -0,0 +1,141 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// TestDecodeCompat ensures requests as encoded by clients of prior versions remain decodable.
+// NOTE: Entries must not be modified once added. Encoding changes require a new Version.
+func TestDecodeCompat(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		encoded string
+		decode  func(url.Values) (any, error)
+		want    any
+	}{
+		{
+			name:    "V0/SmoketestRequest",
+			encoded: "ecosystem=npm&package=pkg&versions=1.0.0&versions=1.0.1&id=run",
+			decode:  decodeAs[SmoketestRequest],
+			want:    &SmoketestRequest{Ecosystem: rebuild.NPM, Package: "pkg", Versions: []string{"1.0.0", "1.0.1"}, ID: "run"},
+		},
+		{
+			name:    "V0/RebuildPackageRequest",
+			encoded: "ecosystem=pypi&package=pkg&version=1.0.0&id=run&strategyfromrepo=true",
+			decode:  decodeAs[RebuildPackageRequest],
+			want:    &RebuildPackageRequest{Ecosystem: rebuild.PyPI, Package: "pkg", Version: "1.0.0", ID: "run", StrategyFromRepo: true},
+		},
+		{
+			name:    "V0/InferenceRequest",
+			encoded: "ecosystem=cratesio&package=pkg&version=1.0.0",
+			decode:  decodeAs[InferenceRequest],
+			want:    &InferenceRequest{Ecosystem: rebuild.CratesIO, Package: "pkg", Version: "1.0.0"},
+		},
+		{
+			name:    "V0/VersionRequest",
+			encoded: "service=api",
+			decode:  decodeAs[VersionRequest],
+			want:    &VersionRequest{Service: "api"},
+		},
+		{
+			name:    "V1/SmoketestRequest",
+			encoded: `ecosystem=npm&package=pkg&versions=1.0.0&id=run&resources={"timeout":60000000000,"cpus":2}&schema_version=1`,
+			decode:  decodeAs[SmoketestRequest],
+			want:    &SmoketestRequest{Ecosystem: rebuild.NPM, Package: "pkg", Versions: []string{"1.0.0"}, ID: "run", Resources: &rebuild.Resources{Timeout: time.Minute, CPUs: 2}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.encoded)
+			if err != nil {
+				t.Fatalf("ParseQuery() error: %v", err)
+			}
+			got, err := tc.decode(values)
+			if err != nil {
+				t.Fatalf("Decode() error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Decode() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func decodeAs[M Message](values url.Values) (any, error) {
+	m := new(M)
+	return m, Decode(values, m)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	want := RebuildPackageRequest{Ecosystem: rebuild.NPM, Package: "pkg", Version: "1.0.0", ID: "run", Resources: &rebuild.Resources{MemoryMB: 512}}
+	values, err := Encode(want)
+	if err != nil {
+		t.Fatalf("Encode() error: %v", err)
+	}
+	if got := values.Get(VersionField); got != "1" {
+		t.Errorf("Encode() version = %q, want 1", got)
+	}
+	var got RebuildPackageRequest
+	if err := Decode(values, &got); err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDecodeUnsupportedVersion(t *testing.T) {
+	values := url.Values{"service": {"api"}, VersionField: {"99"}}
+	var req VersionRequest
+	if err := Decode(values, &req); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Decode() error = %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+type upgradedRequest struct {
+	Name string `form:",required"`
+}
+
+func (upgradedRequest) Validate() error { return nil }
+
+func TestDecodeUpgrader(t *testing.T) {
+	RegisterUpgrader[upgradedRequest](V0, func(v url.Values) (url.Values, error) {
+		v.Set("name", v.Get("legacy_name"))
+		v.Del("legacy_name")
+		return v, nil
+	})
+	defer delete(upgraders, upgradeKey{msg: typeOf[upgradedRequest](), from: V0})
+	var got upgradedRequest
+	if err := Decode(url.Values{"legacy_name": {"foo"}}, &got); err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if diff := cmp.Diff(upgradedRequest{Name: "foo"}, got); diff != "" {
+		t.Errorf("Decode() mismatch (-want +got):\n%s", diff)
+	}
+	// Messages already at the current version are not upgraded.
+	if err := Decode(url.Values{"name": {"bar"}, VersionField: {"1"}}, &got); err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if got.Name != "bar" {
+		t.Errorf("Decode() Name = %q, want bar", got.Name)
+	}
+}
@@ -40,6 +40,7 @@ type Crate struct {
 type Metadata struct {
 	Name       string    `json:"id"`
 	Repository string    `json:"repository"`
+	Homepage   string    `json:"homepage"`
 	Created    time.Time `json:"created_at"`
 	Updated    time.Time `json:"updated_at"`
 }
@@ -49,6 +50,7 @@ type Version struct {
 	Version      string    `json:"num"`
 	RustVersion  string    `json:"rust_version"`
 	DownloadPath string    `json:"dl_path"`
+	Checksum     string    `json:"checksum"`
 	Created      time.Time `json:"created_at"`
 	Updated      time.Time `json:"updated_at"`
 	Yanked       bool      `json:"yanked"`
@@ -70,15 +72,41 @@ type Registry interface {
 // HTTPRegistry is a Registry implementation that uses the crates.io HTTP API.
 type HTTPRegistry struct {
 	Client httpx.BasicClient
+	// URL, if provided, is the base URL of a registry serving the crates.io
+	// API to use in place of crates.io e.g. an Artifactory or Nexus mirror.
+	URL *url.URL
+}
+
+// endpoint returns the URL of the API path p relative to the registry's base URL.
+func (r HTTPRegistry) endpoint(p ...string) (string, error) {
+	base := registryURL
+	if r.URL != nil {
+		base = r.URL
+	}
+	pathURL, err := url.Parse(path.Join(append([]string{"/", base.Path}, p...)...))
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(pathURL).String(), nil
+}
+
+// downloadURL resolves the download path returned by the API against the registry's base URL.
+func (r HTTPRegistry) downloadURL(dlPath string) string {
+	base := registryURL
+	if r.URL != nil {
+		base = r.URL
+	}
+	downloadPath, _ := url.Parse(dlPath)
+	return base.ResolveReference(downloadPath).String()
 }
 
 // Crate provides all API information related to the given crate.
 func (r HTTPRegistry) Crate(ctx context.Context, pkg string) (*Crate, error) {
-	pathURL, err := url.Parse(path.Join("/api/v1/crates", pkg))
+	u, err := r.endpoint("api/v1/crates", pkg)
 	if err != nil {
 		return nil, err
 	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, registryURL.ResolveReference(pathURL).String(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
@@ -91,19 +119,18 @@ func (r HTTPRegistry) Crate(ctx context.Context, pkg string) (*Crate, error) {
 		return nil, err
 	}
 	for i := range c.Versions {
-		downloadPath, _ := url.Parse(c.Versions[i].DownloadPath)
-		c.Versions[i].DownloadURL = registryURL.ResolveReference(downloadPath).String()
+		c.Versions[i].DownloadURL = r.downloadURL(c.Versions[i].DownloadPath)
 	}
 	return &c, nil
 }
 
 // Version provides all API information related to the given version of a crate.
 func (r HTTPRegistry) Version(ctx context.Context, pkg, version string) (*CrateVersion, error) {
-	pathURL, err := url.Parse(path.Join("/api/v1/crates", pkg, version))
+	u, err := r.endpoint("api/v1/crates", pkg, version)
 	if err != nil {
 		return nil, err
 	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, registryURL.ResolveReference(pathURL).String(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
@@ -115,8 +142,7 @@ func (r HTTPRegistry) Version(ctx context.Context, pkg, version string) (*CrateV
 	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
 		return nil, err
 	}
-	downloadPath, _ := url.Parse(v.DownloadPath)
-	v.DownloadURL = registryURL.ResolveReference(downloadPath).String()
+	v.DownloadURL = r.downloadURL(v.DownloadPath)
 	return &v, nil
 }
 
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -204,6 +205,7 @@ func TestHTTPRegistry_Version(t *testing.T) {
 func TestHTTPRegistry_Artifact(t *testing.T) {
 	testCases := []struct {
 		name               string
+		registryURL        string
 		pkg                string
 		version            string
 		calls              []httpxtest.Call
@@ -232,6 +234,29 @@ func TestHTTPRegistry_Artifact(t *testing.T) {
 			},
 			expectedReadCloser: io.NopCloser(bytes.NewReader([]byte("This is the artifact content"))),
 		},
+		{
+			name:        "Mirror",
+			registryURL: "https://example.jfrog.io/artifactory/api/cargo/cargo-remote",
+			pkg:         "serde",
+			version:     "1.0.150",
+			calls: []httpxtest.Call{
+				{
+					URL: "https://example.jfrog.io/artifactory/api/cargo/cargo-remote/api/v1/crates/serde/1.0.150",
+					Response: &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(bytes.NewReader([]byte(`{"version":{"num":"1.0.150", "dl_path":"/artifactory/api/cargo/cargo-remote/v1/crates/serde/1.0.150/download"}}`))),
+					},
+				},
+				{
+					URL: "https://example.jfrog.io/artifactory/api/cargo/cargo-remote/v1/crates/serde/1.0.150/download",
+					Response: &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(bytes.NewReader([]byte("This is the artifact content"))),
+					},
+				},
+			},
+			expectedReadCloser: io.NopCloser(bytes.NewReader([]byte("This is the artifact content"))),
+		},
 		{
 			name:    "Version Fetch Error",
 			pkg:     "serde",
@@ -285,7 +310,11 @@ func TestHTTPRegistry_Artifact(t *testing.T) {
 					}
 				},
 			}
-			actual, err := HTTPRegistry{Client: mockClient}.Artifact(context.Background(), tc.pkg, tc.version)
+			reg := HTTPRegistry{Client: mockClient}
+			if tc.registryURL != "" {
+				reg.URL, _ = url.Parse(tc.registryURL)
+			}
+			actual, err := reg.Artifact(context.Background(), tc.pkg, tc.version)
 			if err != nil && tc.expectedErr != nil && err.Error() != tc.expectedErr.Error() {
 				t.Errorf("Error mismatch: got %v, want %v", err, tc.expectedErr)
 			}
@@ -16,26 +16,42 @@
 package maven
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
-	"path/filepath"
+	"net/url"
+	"path"
 	"strings"
 	"time"
 
+	"github.com/google/oss-rebuild/internal/httpx"
 	"github.com/pkg/errors"
 )
 
 // PomXML is the root element of a Maven POM file.
 type PomXML struct {
-	GroupID    string `xml:"project>groupId"`
-	ArtifactID string `xml:"project>artifactId"`
-	VersionID  string `xml:"project>version"`
-	URL        string `xml:"project>url"`
-	SCMURL     string `xml:"project>scm>url"`
-	Parent     Parent `xml:"project>parent"`
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	VersionID  string `xml:"version"`
+	URL        string `xml:"url"`
+	SCMURL     string `xml:"scm>url"`
+	Parent     Parent `xml:"parent"`
+	// Packaging is the type of the main artifact. Parent POMs and BOMs have
+	// "pom" packaging and publish no artifact other than the POM itself.
+	Packaging string `xml:"packaging"`
+	// Relocation, if present, identifies the coordinates to which the artifact has moved.
+	Relocation *Relocation `xml:"distributionManagement>relocation"`
+}
+
+// Relocation is the new location of a relocated artifact within a Maven POM file.
+// Fields left empty are unchanged from the relocated artifact.
+type Relocation struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	VersionID  string `xml:"version"`
 }
 
 // Parent represents the parent package ref within a Maven POM file.
@@ -88,30 +104,97 @@ type MavenMetadata struct {
 	LastUpdated       time.Time
 }
 
+// Registry is a Maven package repository.
+type Registry interface {
+	PackageMetadata(context.Context, string) (MavenPackage, error)
+	ReleaseFile(context.Context, string, string, FileType) (io.ReadCloser, error)
+	VersionPomXML(context.Context, string, string) (PomXML, error)
+}
+
+// HTTPRegistry is a Registry implementation that reads from Maven Central over HTTP.
+type HTTPRegistry struct {
+	Client httpx.BasicClient
+	// URL, if provided, is the base URL of a Maven repository to use in place
+	// of Maven Central e.g. an Artifactory or Nexus mirror.
+	URL *url.URL
+}
+
+var _ Registry = HTTPRegistry{}
+
+// fileURL returns the URL of the file at path p within the repository.
+func (r HTTPRegistry) fileURL(p string) string {
+	if r.URL == nil {
+		return "https://search.maven.org/remotecontent?filepath=" + p
+	}
+	return r.URL.JoinPath(p).String()
+}
+
+// get returns the body of the file at path p within the repository.
+func (r HTTPRegistry) get(ctx context.Context, p string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.fileURL(p), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("maven registry error: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
 // PackageMetadata returns the metadata for a Maven package.
-func PackageMetadata(pkg string) (result MavenPackage, err error) {
+func (r HTTPRegistry) PackageMetadata(ctx context.Context, pkg string) (result MavenPackage, err error) {
 	g, a, found := strings.Cut(pkg, ":")
 	if !found {
 		err = errors.New("package identifier not of form 'group:artifact'")
 		return
 	}
-	path := filepath.Join(strings.ReplaceAll(g, ".", "/"), a, "maven-metadata.xml")
-	resp, err := http.Get(fmt.Sprintf("https://search.maven.org/remotecontent?filepath=%s", path))
+	body, err := r.get(ctx, path.Join(strings.ReplaceAll(g, ".", "/"), a, "maven-metadata.xml"))
 	if err != nil {
 		return
 	}
-	if resp.StatusCode != http.StatusOK {
-		err = errors.Errorf("maven registry error: %s", resp.Status)
+	defer body.Close()
+	err = xml.NewDecoder(body).Decode(&result)
+	if err != nil {
 		return
 	}
-	err = xml.NewDecoder(resp.Body).Decode(&result)
+	result.LastUpdated, err = time.Parse("20060102150405", result.LastUpdatedString)
+	return
+}
+
+// ReleaseFile returns a release file for a Maven package version.
+func (r HTTPRegistry) ReleaseFile(ctx context.Context, pkg, version string, typ FileType) (io.ReadCloser, error) {
+	g, a, found := strings.Cut(pkg, ":")
+	if !found {
+		return nil, errors.New("package identifier not of form 'group:artifact'")
+	}
+	return r.get(ctx, path.Join(strings.ReplaceAll(g, ".", "/"), a, version, FileName(a, version, typ)))
+}
+
+// VersionPomXML returns the POM file for a Maven package version.
+func (r HTTPRegistry) VersionPomXML(ctx context.Context, pkg, version string) (p PomXML, err error) {
+	var body io.ReadCloser
+	body, err = r.ReleaseFile(ctx, pkg, version, TypePOM)
 	if err != nil {
 		return
 	}
-	result.LastUpdated, err = time.Parse("20060102150405", result.LastUpdatedString)
+	defer body.Close()
+	err = xml.NewDecoder(body).Decode(&p)
 	return
 }
 
+// defaultRegistry is the Maven Central registry used by the package-level functions.
+var defaultRegistry = HTTPRegistry{Client: http.DefaultClient}
+
+// PackageMetadata returns the metadata for a Maven package from Maven Central.
+func PackageMetadata(pkg string) (MavenPackage, error) {
+	return defaultRegistry.PackageMetadata(context.Background(), pkg)
+}
+
 // search is a Maven JSON search API response.
 type search struct {
 	Response response `json:"response"`
@@ -147,6 +230,38 @@ const (
 	TypeModule FileType = ".module"
 )
 
+// ReleaseTypes are the types of release file that are built from a package's source.
+var ReleaseTypes = []FileType{TypeJar, TypePOM, TypeSources, TypeJavadoc}
+
+// FileName returns the name of the release file of type t for a Maven package version.
+func FileName(artifactID, version string, t FileType) string {
+	return fmt.Sprintf("%s-%s%s", artifactID, version, t)
+}
+
+// ParseFileName returns the type of the release file of a Maven package version with the given name.
+func ParseFileName(artifactID, version, name string) (FileType, bool) {
+	suffix, ok := strings.CutPrefix(name, artifactID+"-"+version)
+	if !ok {
+		return "", false
+	}
+	switch t := FileType(suffix); t {
+	case TypePOM, TypeSources, TypeJar, TypeJavadoc, TypeModule:
+		return t, true
+	default:
+		return "", false
+	}
+}
+
+// Signature returns the type of the detached PGP signature published for files of type t.
+func (t FileType) Signature() FileType {
+	return t + ".asc"
+}
+
+// Checksum returns the type of the SHA-1 checksum file published for files of type t.
+func (t FileType) Checksum() FileType {
+	return t + ".sha1"
+}
+
 // VersionMetadata returns the metadata for a Maven package version.
 func VersionMetadata(pkg, version string) (result MavenVersion, err error) {
 	g, a, found := strings.Cut(pkg, ":")
@@ -178,34 +293,12 @@ func VersionMetadata(pkg, version string) (result MavenVersion, err error) {
 	return
 }
 
-// ReleaseFile returns a release file for a Maven package version.
-func ReleaseFile(pkg, version string, typ FileType) (r io.ReadCloser, err error) {
-	g, a, found := strings.Cut(pkg, ":")
-	if !found {
-		err = errors.New("package identifier not of form 'group:artifact'")
-		return
-	}
-	path := filepath.Join(strings.ReplaceAll(g, ".", "/"), a, version, fmt.Sprintf("%s-%s%s", a, version, typ))
-	resp, err := http.Get("https://search.maven.org/remotecontent?filepath=" + path)
-	if err != nil {
-		return
-	}
-	if resp.StatusCode != http.StatusOK {
-		err = errors.Errorf("maven registry error: %s", resp.Status)
-		return
-	}
-	r = resp.Body
-	return
+// ReleaseFile returns a release file for a Maven package version from Maven Central.
+func ReleaseFile(pkg, version string, typ FileType) (io.ReadCloser, error) {
+	return defaultRegistry.ReleaseFile(context.Background(), pkg, version, typ)
 }
 
-// VersionPomXML returns the POM file for a Maven package version.
-func VersionPomXML(pkg, version string) (p PomXML, err error) {
-	var r io.ReadCloser
-	r, err = ReleaseFile(pkg, version, TypePOM)
-	if err != nil {
-		return
-	}
-	defer r.Close()
-	err = xml.NewDecoder(r).Decode(&p)
-	return
+// VersionPomXML returns the POM file for a Maven package version from Maven Central.
+func VersionPomXML(pkg, version string) (PomXML, error) {
+	return defaultRegistry.VersionPomXML(context.Background(), pkg, version)
 }
@@ -0,0 +1,123 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package maven
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+)
+
+func TestHTTPRegistry_ReleaseFile(t *testing.T) {
+	pom := `<project><artifactId>junit</artifactId></project>`
+	testCases := []struct {
+		name        string
+		registryURL string
+		call        httpxtest.Call
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name: "Success",
+			call: httpxtest.Call{
+				URL:      "https://search.maven.org/remotecontent?filepath=junit/junit/4.13/junit-4.13.pom",
+				Response: &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader([]byte(pom)))},
+			},
+			expected: pom,
+		},
+		{
+			name:        "Mirror",
+			registryURL: "https://nexus.example.com/repository/maven-central",
+			call: httpxtest.Call{
+				URL:      "https://nexus.example.com/repository/maven-central/junit/junit/4.13/junit-4.13.pom",
+				Response: &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader([]byte(pom)))},
+			},
+			expected: pom,
+		},
+		{
+			name: "Not Found",
+			call: httpxtest.Call{
+				URL:      "https://search.maven.org/remotecontent?filepath=junit/junit/4.13/junit-4.13.pom",
+				Response: &http.Response{StatusCode: 404, Status: http.StatusText(404), Body: io.NopCloser(&bytes.Buffer{})},
+			},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockClient := &httpxtest.MockClient{
+				Calls: []httpxtest.Call{tc.call},
+				URLValidator: func(expected, actual string) {
+					if diff := cmp.Diff(expected, actual); diff != "" {
+						t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+					}
+				},
+			}
+			reg := HTTPRegistry{Client: mockClient}
+			if tc.registryURL != "" {
+				reg.URL, _ = url.Parse(tc.registryURL)
+			}
+			r, err := reg.ReleaseFile(context.Background(), "junit:junit", "4.13", TypePOM)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("ReleaseFile() error = %v, expectedErr %v", err, tc.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			defer r.Close()
+			actual, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual) != tc.expected {
+				t.Errorf("ReleaseFile() = %q, want %q", actual, tc.expected)
+			}
+		})
+	}
+}
+
+func TestPomXML(t *testing.T) {
+	pom := `<project>
+  <parent><groupId>org.junit</groupId><version>4.11</version></parent>
+  <artifactId>junit-dep</artifactId>
+  <packaging>pom</packaging>
+  <scm><url>https://github.com/junit-team/junit4</url></scm>
+  <distributionManagement><relocation><artifactId>junit</artifactId></relocation></distributionManagement>
+</project>`
+	var p PomXML
+	if err := xml.Unmarshal([]byte(pom), &p); err != nil {
+		t.Fatal(err)
+	}
+	want := PomXML{
+		ArtifactID: "junit-dep",
+		SCMURL:     "https://github.com/junit-team/junit4",
+		Parent:     Parent{GroupID: "org.junit", VersionID: "4.11"},
+		Packaging:  "pom",
+		Relocation: &Relocation{ArtifactID: "junit"},
+	}
+	if diff := cmp.Diff(want, p); diff != "" {
+		t.Errorf("PomXML mismatch (-want +got):\n%s", diff)
+	}
+	if got := p.Name(); got != "org.junit:junit-dep" {
+		t.Errorf("Name() = %q, want %q", got, "org.junit:junit-dep")
+	}
+}
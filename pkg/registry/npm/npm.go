@@ -52,9 +52,26 @@ type Repository struct {
 	Directory string `json:"directory"`
 }
 type Dist struct {
-	URL    string `json:"tarball"`
-	SHA1   string `json:"shasum"`
-	SHA512 string `json:"integrity"`
+	URL        string      `json:"tarball"`
+	SHA1       string      `json:"shasum"`
+	SHA512     string      `json:"integrity"`
+	Signatures []Signature `json:"signatures"`
+}
+
+// Signature is the registry's signature over a package version's identity and integrity.
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// SigningKey is a public key used by the registry to sign package versions.
+type SigningKey struct {
+	Expires *time.Time `json:"expires"`
+	KeyID   string     `json:"keyid"`
+	KeyType string     `json:"keytype"`
+	Scheme  string     `json:"scheme"`
+	// Key is the base64-encoded DER public key.
+	Key string `json:"key"`
 }
 
 type NPMVersion struct {
@@ -67,7 +84,10 @@ type NPMVersion struct {
 	Dist          `json:"dist"`
 	RawRepository json.RawMessage `json:"repository"`
 	Repository
-	Scripts map[string]string `json:"scripts"`
+	Scripts  map[string]string `json:"scripts"`
+	Homepage string            `json:"homepage"`
+	// Deprecated, if non-empty, is the maintainer's reason for deprecating the version.
+	Deprecated string `json:"deprecated"`
 }
 
 type PackageJSON struct {
@@ -83,20 +103,37 @@ type Registry interface {
 	Package(context.Context, string) (*NPMPackage, error)
 	Version(context.Context, string, string) (*NPMVersion, error)
 	Artifact(context.Context, string, string) (io.ReadCloser, error)
+	SigningKeys(context.Context) ([]SigningKey, error)
 }
 
 // HTTPRegistry is a Registry implementation that uses the npmjs.org HTTP API.
 type HTTPRegistry struct {
 	Client httpx.BasicClient
+	// URL, if provided, is the base URL of an npm-compatible registry to use
+	// in place of registry.npmjs.org e.g. an Artifactory, Nexus, or Verdaccio mirror.
+	URL *url.URL
+}
+
+// endpoint returns the URL of the API path p relative to the registry's base URL.
+func (r HTTPRegistry) endpoint(p ...string) (string, error) {
+	base := registryURL
+	if r.URL != nil {
+		base = r.URL
+	}
+	pathURL, err := url.Parse(path.Join(append([]string{"/", base.Path}, p...)...))
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(pathURL).String(), nil
 }
 
 // Package returns the package metadata for the given package.
 func (r HTTPRegistry) Package(ctx context.Context, pkg string) (*NPMPackage, error) {
-	pathURL, err := url.Parse(path.Join("/", pkg))
+	u, err := r.endpoint(pkg)
 	if err != nil {
 		return nil, err
 	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, registryURL.ResolveReference(pathURL).String(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
@@ -125,11 +162,11 @@ func (r HTTPRegistry) Package(ctx context.Context, pkg string) (*NPMPackage, err
 
 // Version returns the package metadata for the given package version.
 func (r HTTPRegistry) Version(ctx context.Context, pkg, version string) (*NPMVersion, error) {
-	pathURL, err := url.Parse(path.Join("/", pkg, version))
+	u, err := r.endpoint(pkg, version)
 	if err != nil {
 		return nil, err
 	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, registryURL.ResolveReference(pathURL).String(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
@@ -170,4 +207,27 @@ func (r HTTPRegistry) Artifact(ctx context.Context, pkg, version string) (io.Rea
 	return resp.Body, nil
 }
 
+// SigningKeys returns the public keys used by the registry to sign package versions.
+func (r HTTPRegistry) SigningKeys(ctx context.Context) ([]SigningKey, error) {
+	u, err := r.endpoint("/-/npm/v1/keys")
+	if err != nil {
+		return nil, err
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.Errorf("npm registry error: %v", resp.Status)
+	}
+	var keys struct {
+		Keys []SigningKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return keys.Keys, nil
+}
+
 var _ Registry = &HTTPRegistry{}
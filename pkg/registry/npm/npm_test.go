@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -245,6 +246,7 @@ func TestHTTPRegistry_Version(t *testing.T) {
 func TestHTTPRegistry_Artifact(t *testing.T) {
 	testCases := []struct {
 		name               string
+		registryURL        string
 		pkg                string
 		version            string
 		calls              []httpxtest.Call
@@ -273,6 +275,29 @@ func TestHTTPRegistry_Artifact(t *testing.T) {
 			},
 			expectedReadCloser: io.NopCloser(bytes.NewReader([]byte("This is the artifact content"))),
 		},
+		{
+			name:        "Mirror",
+			registryURL: "https://nexus.example.com/repository/npm-proxy",
+			pkg:         "express",
+			version:     "4.18.2",
+			calls: []httpxtest.Call{
+				{
+					URL: "https://nexus.example.com/repository/npm-proxy/express/4.18.2",
+					Response: &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(bytes.NewReader([]byte(`{"name":"express","dist-tags":{"latest":"4.18.2"},"dist":{"tarball":"https://nexus.example.com/repository/npm-proxy/express/-/express-4.18.2.tgz"}}`))),
+					},
+				},
+				{
+					URL: "https://nexus.example.com/repository/npm-proxy/express/-/express-4.18.2.tgz",
+					Response: &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(bytes.NewReader([]byte("This is the artifact content"))),
+					},
+				},
+			},
+			expectedReadCloser: io.NopCloser(bytes.NewReader([]byte("This is the artifact content"))),
+		},
 		{
 			name:    "Version Fetch Error",
 			pkg:     "express",
@@ -328,7 +353,11 @@ func TestHTTPRegistry_Artifact(t *testing.T) {
 					}
 				},
 			}
-			actual, err := HTTPRegistry{Client: mockClient}.Artifact(context.Background(), tc.pkg, tc.version)
+			reg := HTTPRegistry{Client: mockClient}
+			if tc.registryURL != "" {
+				reg.URL, _ = url.Parse(tc.registryURL)
+			}
+			actual, err := reg.Artifact(context.Background(), tc.pkg, tc.version)
 			if err != nil && tc.expectedErr != nil && err.Error() != tc.expectedErr.Error() {
 				t.Errorf("Error mismatch: got %v, want %v", err, tc.expectedErr)
 			}
@@ -344,6 +373,33 @@ func TestHTTPRegistry_Artifact(t *testing.T) {
 	}
 }
 
+func TestHTTPRegistry_SigningKeys(t *testing.T) {
+	mockClient := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://registry.npmjs.org/-/npm/v1/keys",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`{"keys":[{"expires":null,"keyid":"SHA256:abc","keytype":"ecdsa-sha2-nistp256","scheme":"ecdsa-sha2-nistp256","key":"MFkw"}]}`))),
+				},
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+	keys, err := HTTPRegistry{Client: mockClient}.SigningKeys(context.Background())
+	if err != nil {
+		t.Fatalf("SigningKeys() error: %v", err)
+	}
+	expected := []SigningKey{{KeyID: "SHA256:abc", KeyType: "ecdsa-sha2-nistp256", Scheme: "ecdsa-sha2-nistp256", Key: "MFkw"}}
+	if diff := cmp.Diff(expected, keys); diff != "" {
+		t.Errorf("SigningKeys() mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func must[T any](t T, err error) T {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,408 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oci provides interfaces for interacting with OCI distribution registries and image formats.
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/pkg/errors"
+)
+
+// Media types of the image documents supported by this package.
+const (
+	MediaTypeImageIndex         = "application/vnd.oci.image.index.v1+json"
+	MediaTypeImageManifest      = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// Pre-defined annotation and label keys.
+// See https://github.com/opencontainers/image-spec/blob/main/annotations.md
+const (
+	SourceLabel          = "org.opencontainers.image.source"
+	RevisionLabel        = "org.opencontainers.image.revision"
+	BaseNameAnnotation   = "org.opencontainers.image.base.name"
+	BaseDigestAnnotation = "org.opencontainers.image.base.digest"
+)
+
+// DefaultPlatform is the platform selected from multi-platform images.
+var DefaultPlatform = Platform{OS: "linux", Architecture: "amd64"}
+
+// Platform describes the platform an image runs on.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	if p.Variant != "" {
+		return fmt.Sprintf("%s/%s/%s", p.OS, p.Architecture, p.Variant)
+	}
+	return fmt.Sprintf("%s/%s", p.OS, p.Architecture)
+}
+
+// Descriptor references a blob or manifest by its content digest.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Index is a list of manifests, typically one for each platform.
+type Index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// IsIndex returns whether the media type identifies an Index.
+func IsIndex(mediaType string) bool {
+	return mediaType == MediaTypeImageIndex || mediaType == MediaTypeDockerManifestList
+}
+
+// Manifest describes the config and layers of a single-platform image.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+	// Digest is the content digest of the manifest.
+	Digest string `json:"-"`
+	raw    []byte
+}
+
+// ImageConfig is the subset of the image configuration used for rebuilds.
+type ImageConfig struct {
+	Created      *time.Time `json:"created,omitempty"`
+	Architecture string     `json:"architecture"`
+	OS           string     `json:"os"`
+	Config       struct {
+		Labels map[string]string `json:"Labels,omitempty"`
+	} `json:"config"`
+}
+
+// Reference identifies an image repository within a registry.
+type Reference struct {
+	Registry   string
+	Repository string
+}
+
+// ParseRepository parses a repository name (e.g. "ghcr.io/org/image" or
+// "alpine") applying the Docker Hub defaults to names without a registry.
+func ParseRepository(name string) (Reference, error) {
+	if name == "" || strings.ContainsAny(name, "@ ") {
+		return Reference{}, errors.Errorf("invalid repository name: %q", name)
+	}
+	first, rest, found := strings.Cut(name, "/")
+	if !found || !(strings.ContainsAny(first, ".:") || first == "localhost") {
+		first, rest = "docker.io", name
+	}
+	if first == "docker.io" && !strings.Contains(rest, "/") {
+		rest = "library/" + rest
+	}
+	return Reference{Registry: first, Repository: rest}, nil
+}
+
+// String returns the canonical repository name.
+func (r Reference) String() string {
+	return r.Registry + "/" + r.Repository
+}
+
+func (r Reference) endpoint() *url.URL {
+	host := r.Registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	return &url.URL{Scheme: "https", Host: host, Path: "/v2/" + r.Repository + "/"}
+}
+
+// Registry is an OCI distribution registry.
+type Registry interface {
+	Manifest(context.Context, string, string) (*Manifest, error)
+	Config(context.Context, string, *Manifest) (*ImageConfig, error)
+	Blob(context.Context, string, string) (io.ReadCloser, error)
+	Artifact(context.Context, string, string) (io.ReadCloser, error)
+}
+
+// HTTPRegistry is a Registry implementation that uses the OCI distribution HTTP API.
+//
+// Requests requiring authorization use anonymous bearer tokens so only public
+// images are supported.
+type HTTPRegistry struct {
+	Client httpx.BasicClient
+}
+
+var manifestAccept = strings.Join([]string{MediaTypeImageIndex, MediaTypeImageManifest, MediaTypeDockerManifestList, MediaTypeDockerManifest}, ", ")
+
+func (r HTTPRegistry) get(ctx context.Context, ref Reference, p string, accept string) (*http.Response, error) {
+	u := ref.endpoint().JoinPath(p).String()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("Www-Authenticate")
+		resp.Body.Close()
+		token, err := r.token(ctx, challenge)
+		if err != nil {
+			return nil, errors.Wrap(err, "authorizing")
+		}
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err = r.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, errors.Errorf("oci registry error: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// challengeParam matches a quoted parameter of a WWW-Authenticate challenge.
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// token fetches an anonymous bearer token in response to an authorization challenge.
+// See https://distribution.github.io/distribution/spec/auth/token/
+func (r HTTPRegistry) token(ctx context.Context, challenge string) (string, error) {
+	params, found := strings.CutPrefix(challenge, "Bearer ")
+	if !found {
+		return "", errors.Errorf("unsupported challenge: %q", challenge)
+	}
+	attrs := make(map[string]string)
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		attrs[m[1]] = m[2]
+	}
+	realm, err := url.Parse(attrs["realm"])
+	if err != nil || realm.Scheme == "" {
+		return "", errors.Errorf("invalid realm: %q", attrs["realm"])
+	}
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if attrs[k] != "" {
+			q.Set(k, attrs[k])
+		}
+	}
+	realm.RawQuery = q.Encode()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", errors.Errorf("token error: %s", resp.Status)
+	}
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.Token != "" {
+		return t.Token, nil
+	}
+	return t.AccessToken, nil
+}
+
+// Manifest provides the manifest of the given tag or digest of an image.
+// For multi-platform images, the manifest for DefaultPlatform is returned.
+func (r HTTPRegistry) Manifest(ctx context.Context, repo, ref string) (*Manifest, error) {
+	name, err := ParseRepository(repo)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.get(ctx, name, "manifests/"+ref, manifestAccept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, errors.Wrap(err, "decoding manifest")
+	}
+	if doc.MediaType == "" {
+		doc.MediaType = resp.Header.Get("Content-Type")
+	}
+	if IsIndex(doc.MediaType) {
+		var idx Index
+		if err := json.Unmarshal(raw, &idx); err != nil {
+			return nil, errors.Wrap(err, "decoding index")
+		}
+		for _, m := range idx.Manifests {
+			if m.Platform != nil && m.Platform.OS == DefaultPlatform.OS && m.Platform.Architecture == DefaultPlatform.Architecture {
+				return r.Manifest(ctx, repo, m.Digest)
+			}
+		}
+		return nil, errors.Errorf("no manifest for platform %s", DefaultPlatform)
+	}
+	m := &Manifest{Digest: digestOf(raw), raw: raw}
+	if err := json.Unmarshal(raw, m); err != nil {
+		return nil, errors.Wrap(err, "decoding manifest")
+	}
+	if strings.HasPrefix(ref, "sha256:") && ref != m.Digest {
+		return nil, errors.Errorf("manifest digest mismatch: got %s, want %s", m.Digest, ref)
+	}
+	return m, nil
+}
+
+// Config provides the image configuration referenced by a manifest.
+func (r HTTPRegistry) Config(ctx context.Context, repo string, m *Manifest) (*ImageConfig, error) {
+	rc, err := r.Blob(ctx, repo, m.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var c ImageConfig
+	if err := json.NewDecoder(rc).Decode(&c); err != nil {
+		return nil, errors.Wrap(err, "decoding config")
+	}
+	return &c, nil
+}
+
+// Blob provides the content of the blob with the given digest.
+func (r HTTPRegistry) Blob(ctx context.Context, repo, digest string) (io.ReadCloser, error) {
+	name, err := ParseRepository(repo)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.get(ctx, name, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Artifact provides the image as a tar archive in the OCI image layout.
+// See https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+func (r HTTPRegistry) Artifact(ctx context.Context, repo, ref string) (io.ReadCloser, error) {
+	m, err := r.Manifest(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(r.writeLayout(ctx, repo, m, pw))
+	}()
+	return pr, nil
+}
+
+func (r HTTPRegistry) writeLayout(ctx context.Context, repo string, m *Manifest, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	mediaType := m.MediaType
+	if mediaType == "" {
+		mediaType = MediaTypeImageManifest
+	}
+	idx, err := json.Marshal(Index{SchemaVersion: 2, MediaType: MediaTypeImageIndex, Manifests: []Descriptor{{MediaType: mediaType, Digest: m.Digest, Size: int64(len(m.raw))}}})
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name    string
+		content []byte
+	}{
+		{"oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)},
+		{"index.json", idx},
+		{BlobPath(m.Digest), m.raw},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.content); err != nil {
+			return err
+		}
+	}
+	for _, d := range append([]Descriptor{m.Config}, m.Layers...) {
+		if err := r.writeBlob(ctx, repo, d, tw); err != nil {
+			return errors.Wrapf(err, "writing blob %s", d.Digest)
+		}
+	}
+	return tw.Close()
+}
+
+func (r HTTPRegistry) writeBlob(ctx context.Context, repo string, d Descriptor, tw *tar.Writer) error {
+	rc, err := r.Blob(ctx, repo, d.Digest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := tw.WriteHeader(&tar.Header{Name: BlobPath(d.Digest), Mode: 0644, Size: d.Size, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(tw, h), rc, d.Size); err != nil {
+		return err
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != d.Digest {
+		return errors.Errorf("digest mismatch: got %s", got)
+	}
+	return nil
+}
+
+var _ Registry = &HTTPRegistry{}
+
+// BlobPath returns the path of a blob within an OCI image layout.
+func BlobPath(digest string) string {
+	alg, hex, _ := strings.Cut(digest, ":")
+	return "blobs/" + alg + "/" + hex
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Labels returns the labels of the image configuration.
+func (c *ImageConfig) Labels() map[string]string {
+	return c.Config.Labels
+}
+
+// BaseImage returns the base image recorded in the manifest annotations, if any.
+func (m *Manifest) BaseImage() (name, digest string) {
+	return m.Annotations[BaseNameAnnotation], m.Annotations[BaseDigestAnnotation]
+}
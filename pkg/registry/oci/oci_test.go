@@ -0,0 +1,147 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+)
+
+func TestParseRepository(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		want    Reference
+		wantErr bool
+	}{
+		{"alpine", Reference{"docker.io", "library/alpine"}, false},
+		{"org/image", Reference{"docker.io", "org/image"}, false},
+		{"ghcr.io/org/image", Reference{"ghcr.io", "org/image"}, false},
+		{"localhost:5000/image", Reference{"localhost:5000", "image"}, false},
+		{"alpine@sha256:abc", Reference{}, true},
+		{"", Reference{}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseRepository(tc.name)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseRepository() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseRepository() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func response(body string, header http.Header) *http.Response {
+	return &http.Response{StatusCode: 200, Header: header, Body: io.NopCloser(bytes.NewReader([]byte(body)))}
+}
+
+func TestHTTPRegistry_Manifest(t *testing.T) {
+	manifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:c0","size":2},"layers":[],"annotations":{"org.opencontainers.image.base.name":"alpine:3.19","org.opencontainers.image.base.digest":"sha256:ba5e"}}`
+	digest := digestOf([]byte(manifest))
+	index := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:a4","size":1,"platform":{"architecture":"arm64","os":"linux"}},{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"` + digest + `","size":1,"platform":{"architecture":"amd64","os":"linux"}}]}`
+	client := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://ghcr.io/v2/org/image/manifests/1.0.0",
+				Response: &http.Response{
+					StatusCode: http.StatusUnauthorized,
+					Header:     http.Header{"Www-Authenticate": []string{`Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:org/image:pull"`}},
+					Body:       io.NopCloser(bytes.NewReader(nil)),
+				},
+			},
+			{
+				URL:      "https://ghcr.io/token?scope=repository%3Aorg%2Fimage%3Apull&service=ghcr.io",
+				Response: response(`{"token":"t0k3n"}`, nil),
+			},
+			{
+				URL:      "https://ghcr.io/v2/org/image/manifests/1.0.0",
+				Response: response(index, nil),
+			},
+			{
+				URL:      "https://ghcr.io/v2/org/image/manifests/" + digest,
+				Response: response(manifest, nil),
+			},
+		},
+	}
+	m, err := HTTPRegistry{Client: client}.Manifest(context.Background(), "ghcr.io/org/image", "1.0.0")
+	if err != nil {
+		t.Fatalf("Manifest() error = %v", err)
+	}
+	if m.Digest != digest {
+		t.Errorf("Manifest() digest = %s, want %s", m.Digest, digest)
+	}
+	if name, d := m.BaseImage(); name != "alpine:3.19" || d != "sha256:ba5e" {
+		t.Errorf("BaseImage() = (%s, %s), want (alpine:3.19, sha256:ba5e)", name, d)
+	}
+}
+
+func TestHTTPRegistry_Artifact(t *testing.T) {
+	config := `{"architecture":"amd64","os":"linux"}`
+	layer := "layer content"
+	manifest := `{"schemaVersion":2,"config":{"digest":"` + digestOf([]byte(config)) + `","size":` + fmt.Sprint(len(config)) + `},"layers":[{"digest":"` + digestOf([]byte(layer)) + `","size":` + fmt.Sprint(len(layer)) + `}]}`
+	for _, tc := range []struct {
+		name      string
+		layerBody string
+		wantErr   bool
+	}{
+		{"Success", layer, false},
+		{"DigestMismatch", "LAYER CONTENT", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &httpxtest.MockClient{
+				Calls: []httpxtest.Call{
+					{URL: "https://registry-1.docker.io/v2/library/image/manifests/1.0.0", Response: response(manifest, nil)},
+					{URL: "https://registry-1.docker.io/v2/library/image/blobs/" + digestOf([]byte(config)), Response: response(config, nil)},
+					{URL: "https://registry-1.docker.io/v2/library/image/blobs/" + digestOf([]byte(layer)), Response: response(tc.layerBody, nil)},
+				},
+			}
+			rc, err := HTTPRegistry{Client: client}.Artifact(context.Background(), "image", "1.0.0")
+			if err != nil {
+				t.Fatalf("Artifact() error = %v", err)
+			}
+			defer rc.Close()
+			var names []string
+			tr := tar.NewReader(rc)
+			for {
+				h, err := tr.Next()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					if !tc.wantErr {
+						t.Fatalf("reading layout: %v", err)
+					}
+					return
+				}
+				names = append(names, h.Name)
+			}
+			if tc.wantErr {
+				t.Fatal("Artifact() expected error")
+			}
+			want := []string{"oci-layout", "index.json", BlobPath(digestOf([]byte(manifest))), BlobPath(digestOf([]byte(config))), BlobPath(digestOf([]byte(layer)))}
+			if diff := cmp.Diff(want, names); diff != "" {
+				t.Errorf("Artifact() entries mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
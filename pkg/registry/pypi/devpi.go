@@ -0,0 +1,180 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pypi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/version"
+	"github.com/pkg/errors"
+)
+
+// devpiVersion is the metadata for a single version as served by the devpi JSON API.
+type devpiVersion struct {
+	Name        string      `json:"name"`
+	Version     string      `json:"version"`
+	Description string      `json:"description"`
+	Homepage    string      `json:"home_page"`
+	ProjectURLs []string    `json:"project_urls"`
+	Links       []devpiLink `json:"+links"`
+}
+
+// devpiLink is a file associated with a version in the devpi JSON API.
+type devpiLink struct {
+	Rel      string `json:"rel"`
+	Href     string `json:"href"`
+	HashSpec string `json:"hash_spec"`
+	Log      []struct {
+		What string `json:"what"`
+		// When is the UTC time of the event as [year, month, day, hour, minute, second].
+		When []int `json:"when"`
+	} `json:"log"`
+}
+
+// DevpiRegistry is a Registry implementation that uses the JSON API of a devpi index.
+type DevpiRegistry struct {
+	Client httpx.BasicClient
+	// URL is the URL of the index e.g. https://devpi.example.com/root/pypi.
+	URL *url.URL
+}
+
+func (r DevpiRegistry) get(ctx context.Context, result any, p ...string) error {
+	pathURL, err := url.Parse(path.Join(append([]string{"/", r.URL.Path}, p...)...))
+	if err != nil {
+		return err
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, r.URL.ResolveReference(pathURL).String(), nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return errors.Errorf("devpi registry error: %v", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(&struct {
+		Result any `json:"result"`
+	}{result})
+}
+
+// Project provides all API information related to the given package.
+// The project's Info is that of its latest release.
+func (r DevpiRegistry) Project(ctx context.Context, pkg string) (*Project, error) {
+	var versions map[string]devpiVersion
+	if err := r.get(ctx, &versions, pkg); err != nil {
+		return nil, err
+	}
+	p := Project{Releases: make(map[string][]Artifact)}
+	var vs []string
+	for v, dv := range versions {
+		p.Releases[v] = dv.artifacts()
+		vs = append(vs, v)
+	}
+	if latest, ok := version.Latest(version.PEP440, vs); ok {
+		for _, v := range vs {
+			if version.Equal(version.PEP440, v, latest) {
+				p.Info = versions[v].info()
+			}
+		}
+	}
+	return &p, nil
+}
+
+// Release provides all API information related to the given version of a package.
+func (r DevpiRegistry) Release(ctx context.Context, pkg, version string) (*Release, error) {
+	var dv devpiVersion
+	if err := r.get(ctx, &dv, pkg, version); err != nil {
+		return nil, err
+	}
+	return &Release{Info: dv.info(), Artifacts: dv.artifacts()}, nil
+}
+
+// Artifact provides the artifact associated with a specific package version.
+func (r DevpiRegistry) Artifact(ctx context.Context, pkg, version, filename string) (io.ReadCloser, error) {
+	release, err := r.Release(ctx, pkg, version)
+	if err != nil {
+		return nil, err
+	}
+	for _, artifact := range release.Artifacts {
+		if artifact.Filename == filename {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, artifact.URL, nil)
+			resp, err := r.Client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode != 200 {
+				return nil, errors.Errorf("fetching artifact: %v", resp.Status)
+			}
+			return resp.Body, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func (dv devpiVersion) info() Info {
+	info := Info{
+		Name:        dv.Name,
+		Description: dv.Description,
+		Version:     dv.Version,
+		Homepage:    dv.Homepage,
+	}
+	// NOTE: devpi preserves the upload metadata form of project URLs: "<label>, <url>".
+	for _, pu := range dv.ProjectURLs {
+		if label, u, found := strings.Cut(pu, ","); found {
+			if info.ProjectURLs == nil {
+				info.ProjectURLs = make(map[string]string)
+			}
+			info.ProjectURLs[strings.TrimSpace(label)] = strings.TrimSpace(u)
+		}
+	}
+	return info
+}
+
+func (dv devpiVersion) artifacts() []Artifact {
+	var artifacts []Artifact
+	for _, l := range dv.Links {
+		if l.Rel != "releasefile" {
+			continue
+		}
+		a := Artifact{URL: l.Href, PackageType: "sdist"}
+		if u, err := url.Parse(l.Href); err == nil {
+			a.Filename = path.Base(u.Path)
+		}
+		if strings.HasSuffix(a.Filename, ".whl") {
+			a.PackageType = "bdist_wheel"
+		}
+		if alg, digest, found := strings.Cut(l.HashSpec, "="); found && alg == "sha256" {
+			a.SHA256 = digest
+		}
+		for _, e := range l.Log {
+			if e.What == "upload" && len(e.When) == 6 {
+				a.UploadTime = time.Date(e.When[0], time.Month(e.When[1]), e.When[2], e.When[3], e.When[4], e.When[5], 0, time.UTC)
+			}
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts
+}
+
+var _ Registry = &DevpiRegistry{}
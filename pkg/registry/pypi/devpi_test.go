@@ -0,0 +1,129 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pypi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/internal/httpx/httpxtest"
+)
+
+const devpiProject = `{
+    "type": "projectconfig",
+    "result": {
+        "2.30.0": {
+            "name": "requests",
+            "version": "2.30.0",
+            "home_page": "https://requests.readthedocs.io",
+            "+links": [
+                {"rel": "releasefile", "href": "https://devpi.example.com/root/pypi/+f/aaa/requests-2.30.0.tar.gz", "hash_spec": "sha256=aaa"}
+            ]
+        },
+        "2.31.0": {
+            "name": "requests",
+            "version": "2.31.0",
+            "home_page": "https://requests.readthedocs.io",
+            "project_urls": ["Source, https://github.com/psf/requests"],
+            "+links": [
+                {"rel": "releasefile", "href": "https://devpi.example.com/root/pypi/+f/bbb/requests-2.31.0-py3-none-any.whl", "hash_spec": "sha256=bbb", "log": [{"what": "upload", "when": [2023, 5, 22, 15, 12, 44]}]},
+                {"rel": "doczip", "href": "https://devpi.example.com/root/pypi/+f/ccc/requests-2.31.0.doc.zip"}
+            ]
+        }
+    }
+}`
+
+func TestDevpiRegistry_Project(t *testing.T) {
+	mockClient := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://devpi.example.com/root/pypi/requests",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(devpiProject))),
+				},
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+	u, _ := url.Parse("https://devpi.example.com/root/pypi")
+	actual, err := DevpiRegistry{Client: mockClient, URL: u}.Project(context.Background(), "requests")
+	if err != nil {
+		t.Fatalf("Project() error = %v", err)
+	}
+	expected := &Project{
+		Info: Info{
+			Name:        "requests",
+			Version:     "2.31.0",
+			Homepage:    "https://requests.readthedocs.io",
+			ProjectURLs: map[string]string{"Source": "https://github.com/psf/requests"},
+		},
+		Releases: map[string][]Artifact{
+			"2.30.0": {
+				{Digests: Digests{SHA256: "aaa"}, Filename: "requests-2.30.0.tar.gz", PackageType: "sdist", URL: "https://devpi.example.com/root/pypi/+f/aaa/requests-2.30.0.tar.gz"},
+			},
+			"2.31.0": {
+				{Digests: Digests{SHA256: "bbb"}, Filename: "requests-2.31.0-py3-none-any.whl", PackageType: "bdist_wheel", URL: "https://devpi.example.com/root/pypi/+f/bbb/requests-2.31.0-py3-none-any.whl", UploadTime: time.Date(2023, 5, 22, 15, 12, 44, 0, time.UTC)},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Project mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDevpiRegistry_Artifact(t *testing.T) {
+	mockClient := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://devpi.example.com/root/pypi/requests/2.30.0",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`{"type": "versiondata", "result": {"name": "requests", "version": "2.30.0", "+links": [{"rel": "releasefile", "href": "https://devpi.example.com/root/pypi/+f/aaa/requests-2.30.0.tar.gz", "hash_spec": "sha256=aaa"}]}}`))),
+				},
+			},
+			{
+				URL: "https://devpi.example.com/root/pypi/+f/aaa/requests-2.30.0.tar.gz",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte("This is the artifact content"))),
+				},
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+	u, _ := url.Parse("https://devpi.example.com/root/pypi")
+	r, err := DevpiRegistry{Client: mockClient, URL: u}.Artifact(context.Background(), "requests", "2.30.0", "requests-2.30.0.tar.gz")
+	if err != nil {
+		t.Fatalf("Artifact() error = %v", err)
+	}
+	if diff := cmp.Diff("This is the artifact content", string(must(io.ReadAll(r)))); diff != "" {
+		t.Errorf("Artifact content mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -62,6 +62,8 @@ type Artifact struct {
 	PythonVersion string    `json:"python_version"`
 	URL           string    `json:"url"`
 	UploadTime    time.Time `json:"upload_time_iso_8601"`
+	Yanked        bool      `json:"yanked"`
+	YankedReason  string    `json:"yanked_reason"`
 }
 
 // Digests are the hashes of the artifact.
@@ -80,15 +82,31 @@ type Registry interface {
 // HTTPRegistry is a Registry implementation that uses the pypi.org HTTP API.
 type HTTPRegistry struct {
 	Client httpx.BasicClient
+	// URL, if provided, is the base URL of a registry serving the PyPI JSON API
+	// to use in place of pypi.org e.g. an Artifactory or Nexus mirror.
+	URL *url.URL
+}
+
+// endpoint returns the URL of the API path p relative to the registry's base URL.
+func (r HTTPRegistry) endpoint(p ...string) (string, error) {
+	base := registryURL
+	if r.URL != nil {
+		base = r.URL
+	}
+	pathURL, err := url.Parse(path.Join(append([]string{"/", base.Path}, p...)...))
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(pathURL).String(), nil
 }
 
 // Project provides all API information related to the given package.
 func (r HTTPRegistry) Project(ctx context.Context, pkg string) (*Project, error) {
-	pathURL, err := url.Parse(path.Join("/pypi", pkg, "json"))
+	u, err := r.endpoint("pypi", pkg, "json")
 	if err != nil {
 		return nil, err
 	}
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, registryURL.ResolveReference(pathURL).String(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
@@ -105,11 +123,11 @@ func (r HTTPRegistry) Project(ctx context.Context, pkg string) (*Project, error)
 
 // Release provides all API information related to the given version of a package.
 func (r HTTPRegistry) Release(ctx context.Context, pkg, version string) (*Release, error) {
-	pathURL, err := url.Parse(path.Join("/pypi", pkg, version, "json"))
+	u, err := r.endpoint("pypi", pkg, version, "json")
 	if err != nil {
 		return nil, err
 	}
-	req, _ := http.NewRequest(http.MethodGet, registryURL.ResolveReference(pathURL).String(), nil)
+	req, _ := http.NewRequest(http.MethodGet, u, nil)
 	resp, err := r.Client.Do(req)
 	if err != nil {
 		return nil, err
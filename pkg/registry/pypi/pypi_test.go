@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -339,6 +340,33 @@ func TestHTTPRegistry_Artifact(t *testing.T) {
 	}
 }
 
+func TestHTTPRegistry_Mirror(t *testing.T) {
+	mockClient := &httpxtest.MockClient{
+		Calls: []httpxtest.Call{
+			{
+				URL: "https://example.jfrog.io/artifactory/api/pypi/pypi-remote/pypi/requests/2.31.0/json",
+				Response: &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`{"info": {"name": "requests", "version": "2.31.0"}}`))),
+				},
+			},
+		},
+		URLValidator: func(expected, actual string) {
+			if diff := cmp.Diff(expected, actual); diff != "" {
+				t.Fatalf("URL mismatch (-want +got):\n%s", diff)
+			}
+		},
+	}
+	u, _ := url.Parse("https://example.jfrog.io/artifactory/api/pypi/pypi-remote")
+	actual, err := HTTPRegistry{Client: mockClient, URL: u}.Release(context.Background(), "requests", "2.31.0")
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if diff := cmp.Diff(&Release{Info: Info{Name: "requests", Version: "2.31.0"}}, actual); diff != "" {
+		t.Errorf("Release mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func must[T any](t T, err error) T {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,143 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"cmp"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var debianRE = regexp.MustCompile(`^(?:(?P<epoch>[0-9]+):)?(?P<upstream>[0-9][A-Za-z0-9.+~:-]*?)(?:-(?P<revision>[A-Za-z0-9.+~]+))?$`)
+
+type debianVersion struct {
+	epoch    int
+	upstream string
+	revision string
+}
+
+func parseDebian(s string) (Version, error) {
+	m := debianRE.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, errors.Errorf("invalid Debian version: %q", s)
+	}
+	v := &debianVersion{
+		upstream: m[debianRE.SubexpIndex("upstream")],
+		revision: m[debianRE.SubexpIndex("revision")],
+	}
+	if e := m[debianRE.SubexpIndex("epoch")]; e != "" {
+		v.epoch, _ = strconv.Atoi(e)
+	}
+	return v, nil
+}
+
+func (v *debianVersion) Scheme() Scheme { return Debian }
+
+func (v *debianVersion) String() string {
+	s := v.upstream
+	if v.epoch != 0 {
+		s = strconv.Itoa(v.epoch) + ":" + s
+	}
+	if v.revision != "" {
+		s += "-" + v.revision
+	}
+	return s
+}
+
+func (v *debianVersion) IsPrerelease() bool { return strings.ContainsRune(v.upstream, '~') }
+
+// TagForms returns the upstream version, as used by upstream tags, followed by
+// the DEP-14 encoding of the full version used by packaging repositories.
+func (v *debianVersion) TagForms() []string {
+	dep14 := strings.NewReplacer(":", "%", "~", "_").Replace(v.String())
+	return dedup([]string{v.upstream, strings.ReplaceAll(v.upstream, "~", "-"), dep14})
+}
+
+func (v *debianVersion) compare(o Version) int {
+	w := o.(*debianVersion)
+	if c := cmp.Compare(v.epoch, w.epoch); c != 0 {
+		return c
+	}
+	if c := verrevcmp(v.upstream, w.upstream); c != 0 {
+		return c
+	}
+	return verrevcmp(v.revision, w.revision)
+}
+
+// debianOrder returns the sort weight of a character in the non-digit portion of a version.
+func debianOrder(c byte) int {
+	switch {
+	case c == '~':
+		return -1
+	case c >= '0' && c <= '9':
+		return 0
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// verrevcmp compares version fragments using the algorithm of dpkg.
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		// Compare the non-digit prefixes.
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			var ac, bc int
+			if i < len(a) {
+				ac = debianOrder(a[i])
+			}
+			if j < len(b) {
+				bc = debianOrder(b[j])
+			}
+			if ac != bc {
+				return cmp.Compare(ac, bc)
+			}
+			i++
+			j++
+		}
+		// Compare the numeric portions.
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		var firstDiff int
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = cmp.Compare(a[i], b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
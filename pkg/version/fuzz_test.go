@@ -0,0 +1,44 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	for _, v := range []string{"1.0.0", "v1.2.3-rc.1+build.5", "1!2.0.post1.dev3+local.7", "2:1.0~rc1-0ubuntu1", "1.0-alpha-1", "2.0.0-M1", "1.0.Final"} {
+		f.Add(v)
+	}
+	f.Fuzz(func(t *testing.T, v string) {
+		for _, s := range []Scheme{SemVer, PEP440, Debian, Maven} {
+			pv, err := Parse(s, v)
+			if err != nil {
+				continue
+			}
+			// The normalized form must name the same version.
+			again, err := Parse(s, pv.String())
+			if err != nil {
+				t.Fatalf("Parse(%s, %q) of normalized %q: %v", s, pv.String(), v, err)
+			}
+			if Compare(pv, again) != 0 {
+				t.Errorf("%s version %q normalized to %q which compares unequal", s, v, pv.String())
+			}
+			if Compare(pv, pv) != 0 {
+				t.Errorf("%s version %q compares unequal to itself", s, v)
+			}
+		}
+	})
+}
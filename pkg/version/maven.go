@@ -0,0 +1,179 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"cmp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// mavenQualifiers are the well-known qualifiers in ascending order. The empty
+// qualifier denotes a final release.
+var mavenQualifiers = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+
+// mavenAliases maps alternate qualifier spellings to their canonical form.
+var mavenAliases = map[string]string{
+	"a":       "alpha",
+	"b":       "beta",
+	"m":       "milestone",
+	"cr":      "rc",
+	"ga":      "",
+	"final":   "",
+	"release": "",
+}
+
+// mavenItem is a component of a Maven version, either numeric or a qualifier.
+type mavenItem struct {
+	numeric bool
+	// value holds the digits of a numeric item without leading zeros or the
+	// canonical form of a qualifier.
+	value string
+}
+
+// isNull returns whether the item is zero or the final-release qualifier.
+func (i mavenItem) isNull() bool {
+	return i.value == ""
+}
+
+func qualifierRank(q string) (int, bool) {
+	for i, known := range mavenQualifiers {
+		if q == known {
+			return i, true
+		}
+	}
+	return len(mavenQualifiers), false
+}
+
+func compareMavenItems(a, b mavenItem) int {
+	switch {
+	case a.numeric && b.numeric:
+		if c := cmp.Compare(len(a.value), len(b.value)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.value, b.value)
+	case a.numeric:
+		return 1
+	case b.numeric:
+		return -1
+	}
+	ar, aok := qualifierRank(a.value)
+	br, bok := qualifierRank(b.value)
+	if c := cmp.Compare(ar, br); c != 0 || (aok && bok) {
+		return c
+	}
+	return strings.Compare(a.value, b.value)
+}
+
+type mavenVersion struct {
+	raw   string
+	items []mavenItem
+}
+
+func parseMaven(s string) (Version, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty Maven version")
+	}
+	v := &mavenVersion{raw: s}
+	lower := strings.ToLower(s)
+	var tok strings.Builder
+	var tokDigits bool
+	flush := func(followedByDigit bool) {
+		t := tok.String()
+		tok.Reset()
+		if tokDigits {
+			v.items = append(v.items, mavenItem{numeric: true, value: strings.TrimLeft(t, "0")})
+			return
+		}
+		// NOTE: Single letter qualifiers are only aliases when directly followed by a number (e.g. "1.0a1").
+		if alias, ok := mavenAliases[t]; ok && (len(t) > 1 || followedByDigit) {
+			t = alias
+		}
+		v.items = append(v.items, mavenItem{value: t})
+	}
+	for i := 0; i < len(lower); i++ {
+		c := lower[i]
+		switch {
+		case c == '.' || c == '-':
+			if tok.Len() > 0 {
+				flush(false)
+			}
+		case isDigit(c):
+			if tok.Len() > 0 && !tokDigits {
+				flush(true)
+			}
+			tokDigits = true
+			tok.WriteByte(c)
+		default:
+			if tok.Len() > 0 && tokDigits {
+				flush(false)
+			}
+			tokDigits = false
+			tok.WriteByte(c)
+		}
+	}
+	if tok.Len() > 0 {
+		flush(false)
+	}
+	// NOTE: Trailing zeros and final-release qualifiers do not affect ordering (e.g. "1.0.0" == "1").
+	for len(v.items) > 0 && v.items[len(v.items)-1].isNull() {
+		v.items = v.items[:len(v.items)-1]
+	}
+	return v, nil
+}
+
+func (v *mavenVersion) Scheme() Scheme { return Maven }
+
+func (v *mavenVersion) String() string { return v.raw }
+
+func (v *mavenVersion) IsPrerelease() bool {
+	for _, i := range v.items {
+		if i.numeric {
+			continue
+		}
+		if r, ok := qualifierRank(i.value); ok && r < len(mavenQualifiers)-2 {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *mavenVersion) TagForms() []string { return []string{v.raw} }
+
+func (v *mavenVersion) compare(o Version) int {
+	w := o.(*mavenVersion)
+	for i := 0; i < max(len(v.items), len(w.items)); i++ {
+		a, b := mavenItem{}, mavenItem{}
+		if i < len(v.items) {
+			a = v.items[i]
+		}
+		if i < len(w.items) {
+			b = w.items[i]
+		}
+		// NOTE: Missing items are padded with the null value of the other's type.
+		if i >= len(v.items) {
+			a.numeric = b.numeric
+		}
+		if i >= len(w.items) {
+			b.numeric = a.numeric
+		}
+		if c := compareMavenItems(a, b); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,235 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Adapted from: https://packaging.python.org/en/latest/specifications/version-specifiers/#appendix-parsing-version-strings-with-regular-expressions
+var pep440RE = regexp.MustCompile(`(?i)^v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?P<pre>[-_\.]?(?P<pre_l>alpha|a|beta|b|preview|pre|c|rc)[-_\.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?P<post>(?:-(?P<post_n1>[0-9]+))|(?:[-_\.]?(?P<post_l>post|rev|r)[-_\.]?(?P<post_n2>[0-9]+)?))?` +
+	`(?P<dev>[-_\.]?(?P<dev_l>dev)[-_\.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_\.][a-z0-9]+)*))?$`)
+
+// pep440PreLabels maps each prerelease spelling to its normalized form.
+var pep440PreLabels = map[string]string{
+	"a": "a", "alpha": "a",
+	"b": "b", "beta": "b",
+	"c": "rc", "rc": "rc", "pre": "rc", "preview": "rc",
+}
+
+type pep440Version struct {
+	epoch   int
+	release []int
+	// pre is the normalized prerelease label ("a", "b", or "rc") or empty.
+	pre   string
+	preN  int
+	postN int // -1 if absent
+	devN  int // -1 if absent
+	local string
+	// rawRelease is the release as written, including any trailing zeros.
+	rawRelease string
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func parsePEP440(s string) (Version, error) {
+	m := pep440RE.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, errors.Errorf("invalid PEP 440 version: %q", s)
+	}
+	group := func(name string) string { return m[pep440RE.SubexpIndex(name)] }
+	v := &pep440Version{epoch: atoi(group("epoch")), postN: -1, devN: -1}
+	for _, part := range strings.Split(group("release"), ".") {
+		v.release = append(v.release, atoi(part))
+	}
+	v.rawRelease = group("release")
+	if l := group("pre_l"); l != "" {
+		v.pre = pep440PreLabels[strings.ToLower(l)]
+		v.preN = atoi(group("pre_n"))
+	}
+	if n := group("post_n1"); n != "" {
+		v.postN = atoi(n)
+	} else if group("post_l") != "" {
+		v.postN = atoi(group("post_n2"))
+	}
+	if group("dev_l") != "" {
+		v.devN = atoi(group("dev_n"))
+	}
+	v.local = strings.ToLower(strings.NewReplacer("-", ".", "_", ".").Replace(group("local")))
+	return v, nil
+}
+
+func (v *pep440Version) Scheme() Scheme { return PEP440 }
+
+func (v *pep440Version) normalizedRelease() string {
+	var parts []string
+	for _, n := range v.release {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return strings.Join(parts, ".")
+}
+
+func (v *pep440Version) String() string {
+	var b strings.Builder
+	if v.epoch != 0 {
+		fmt.Fprintf(&b, "%d!", v.epoch)
+	}
+	b.WriteString(v.normalizedRelease())
+	if v.pre != "" {
+		fmt.Fprintf(&b, "%s%d", v.pre, v.preN)
+	}
+	if v.postN >= 0 {
+		fmt.Fprintf(&b, ".post%d", v.postN)
+	}
+	if v.devN >= 0 {
+		fmt.Fprintf(&b, ".dev%d", v.devN)
+	}
+	if v.local != "" {
+		b.WriteString("+" + v.local)
+	}
+	return b.String()
+}
+
+func (v *pep440Version) IsPrerelease() bool { return v.pre != "" || v.devN >= 0 }
+
+// TagForms returns the normalized version followed by the spellings commonly
+// used in tags, such as "1.0-1" for "1.0.post1" and "1.0-rc1" for "1.0rc1".
+func (v *pep440Version) TagForms() []string {
+	// NOTE: Tags use the release as written (e.g. "1.0" rather than "1").
+	forms := []string{v.rawRelease}
+	if v.pre != "" {
+		var next []string
+		for _, f := range forms {
+			p := v.pre
+			n := strconv.Itoa(v.preN)
+			next = append(next, f+p+n, f+"-"+p+n, f+"-"+p+"."+n, f+"."+p+n)
+		}
+		forms = next
+	}
+	if v.postN >= 0 {
+		var next []string
+		for _, f := range forms {
+			n := strconv.Itoa(v.postN)
+			next = append(next, f+".post"+n, f+"-"+n, f+"-post"+n, f+"post"+n)
+		}
+		forms = next
+	}
+	if v.devN >= 0 {
+		var next []string
+		for _, f := range forms {
+			n := strconv.Itoa(v.devN)
+			next = append(next, f+".dev"+n, f+"-dev"+n, f+"dev"+n)
+		}
+		forms = next
+	}
+	return dedup(append([]string{v.String()}, forms...))
+}
+
+// trimmedRelease returns the release without trailing zeros, which do not affect ordering.
+func (v *pep440Version) trimmedRelease() []int {
+	r := v.release
+	for len(r) > 1 && r[len(r)-1] == 0 {
+		r = r[:len(r)-1]
+	}
+	return r
+}
+
+func (v *pep440Version) preKey() (int, int) {
+	switch {
+	case v.pre == "" && v.postN < 0 && v.devN >= 0:
+		// NOTE: Dev releases of a final release sort before its prereleases.
+		return -1, 0
+	case v.pre == "":
+		return math.MaxInt, 0
+	default:
+		return slices.Index([]string{"a", "b", "rc"}, v.pre), v.preN
+	}
+}
+
+func (v *pep440Version) compare(o Version) int {
+	w := o.(*pep440Version)
+	if c := cmp.Compare(v.epoch, w.epoch); c != 0 {
+		return c
+	}
+	if c := slices.Compare(v.trimmedRelease(), w.trimmedRelease()); c != 0 {
+		return c
+	}
+	vl, vn := v.preKey()
+	wl, wn := w.preKey()
+	if c := cmp.Compare(vl, wl); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(vn, wn); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.postN, w.postN); c != 0 {
+		return c
+	}
+	// NOTE: Absent dev segments sort after present ones.
+	vd, wd := v.devN, w.devN
+	if vd < 0 {
+		vd = math.MaxInt
+	}
+	if wd < 0 {
+		wd = math.MaxInt
+	}
+	if c := cmp.Compare(vd, wd); c != 0 {
+		return c
+	}
+	return compareLocal(v.local, w.local)
+}
+
+// compareLocal orders local version labels, treating numeric segments as greater than alphanumeric ones.
+func compareLocal(a, b string) int {
+	if a == "" || b == "" {
+		return cmp.Compare(len(a), len(b))
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < min(len(as), len(bs)); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		var c int
+		switch {
+		case aerr == nil && berr == nil:
+			c = cmp.Compare(an, bn)
+		case aerr == nil:
+			c = 1
+		case berr == nil:
+			c = -1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/semver"
+)
+
+type semVersion struct {
+	raw string
+	v   semver.Semver
+}
+
+func parseSemVer(s string) (Version, error) {
+	v, err := semver.New(s)
+	if err != nil {
+		return nil, err
+	}
+	return &semVersion{raw: strings.TrimPrefix(s, "v"), v: v}, nil
+}
+
+func (v *semVersion) Scheme() Scheme { return SemVer }
+
+func (v *semVersion) String() string { return v.raw }
+
+func (v *semVersion) IsPrerelease() bool { return v.v.Prerelease != "" }
+
+func (v *semVersion) TagForms() []string { return []string{v.raw} }
+
+func (v *semVersion) compare(o Version) int { return semver.Cmp(v.raw, o.(*semVersion).raw) }
@@ -0,0 +1,149 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package version parses, compares, and maps between the version schemes of package ecosystems.
+package version
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// Scheme is a versioning scheme.
+type Scheme string
+
+const (
+	// SemVer is Semantic Versioning 2.0.0, used by npm and crates.io.
+	SemVer Scheme = "semver"
+	// PEP440 is the Python version specification, used by PyPI.
+	PEP440 Scheme = "pep440"
+	// Debian is the Debian package version format.
+	Debian Scheme = "debian"
+	// Maven is the format ordered by Maven's ComparableVersion.
+	Maven Scheme = "maven"
+)
+
+// Version is a version parsed according to a Scheme.
+type Version interface {
+	// Scheme returns the scheme according to which the version was parsed.
+	Scheme() Scheme
+	// String returns the normalized form of the version.
+	String() string
+	// IsPrerelease returns whether the version precedes the final release it names.
+	IsPrerelease() bool
+	// TagForms returns the spellings by which the version may appear in a
+	// repository's tags, most conventional first.
+	TagForms() []string
+	// compare orders the version relative to another of the same scheme.
+	compare(Version) int
+}
+
+// Parse parses v according to the scheme s.
+func Parse(s Scheme, v string) (Version, error) {
+	switch s {
+	case SemVer:
+		return parseSemVer(v)
+	case PEP440:
+		return parsePEP440(v)
+	case Debian:
+		return parseDebian(v)
+	case Maven:
+		return parseMaven(v)
+	default:
+		return nil, errors.Errorf("unknown version scheme: %s", s)
+	}
+}
+
+// Compare orders two versions of the same scheme, returning -1, 0, or +1.
+// Versions of different schemes are ordered by scheme name.
+func Compare(a, b Version) int {
+	if a.Scheme() != b.Scheme() {
+		return cmp.Compare(a.Scheme(), b.Scheme())
+	}
+	return a.compare(b)
+}
+
+// Cmp orders two version strings according to the scheme s.
+// Unparseable versions are ordered before all valid ones.
+func Cmp(s Scheme, a, b string) int {
+	av, aerr := Parse(s, a)
+	bv, berr := Parse(s, b)
+	switch {
+	case aerr != nil && berr != nil:
+		return cmp.Compare(a, b)
+	case aerr != nil:
+		return -1
+	case berr != nil:
+		return 1
+	default:
+		return Compare(av, bv)
+	}
+}
+
+// Equal returns whether a and b name the same version according to the scheme s.
+func Equal(s Scheme, a, b string) bool {
+	av, err := Parse(s, a)
+	if err != nil {
+		return false
+	}
+	bv, err := Parse(s, b)
+	if err != nil {
+		return false
+	}
+	return Compare(av, bv) == 0
+}
+
+// IsPrerelease returns whether v is a valid prerelease version according to the scheme s.
+func IsPrerelease(s Scheme, v string) bool {
+	pv, err := Parse(s, v)
+	return err == nil && pv.IsPrerelease()
+}
+
+// Sort sorts the versions in ascending order according to the scheme s.
+func Sort(s Scheme, vs []string) {
+	slices.SortStableFunc(vs, func(a, b string) int { return Cmp(s, a, b) })
+}
+
+// Latest returns the greatest valid final release among vs according to the scheme s.
+func Latest(s Scheme, vs []string) (string, bool) {
+	var latest Version
+	for _, v := range vs {
+		pv, err := Parse(s, v)
+		if err != nil || pv.IsPrerelease() {
+			continue
+		}
+		if latest == nil || Compare(pv, latest) > 0 {
+			latest = pv
+		}
+	}
+	if latest == nil {
+		return "", false
+	}
+	return latest.String(), true
+}
+
+// dedup returns the distinct elements of ss in their original order.
+func dedup(ss []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
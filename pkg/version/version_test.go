@@ -0,0 +1,130 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCmp(t *testing.T) {
+	tests := []struct {
+		scheme   Scheme
+		a        string
+		b        string
+		expected int
+	}{
+		{SemVer, "1.0.0", "1.0.0", 0},
+		{SemVer, "1.0.0-rc.1", "1.0.0", -1},
+		{SemVer, "v1.2.0", "1.10.0", -1},
+		{PEP440, "1.0", "1.0.0", 0},
+		{PEP440, "1.0.post1", "v1.0-1", 0},                 // Implicit post release
+		{PEP440, "1.0rc1", "1.0-RC.1", 0},                  // Separators and case
+		{PEP440, "1.0.dev1", "1.0a1", -1},                  // Dev release before prerelease
+		{PEP440, "1.0a1", "1.0b1", -1},                     // Prerelease labels
+		{PEP440, "1.0c1", "1.0rc2", -1},                    // Alternate rc spelling
+		{PEP440, "1.0rc1", "1.0", -1},                      // Prerelease before release
+		{PEP440, "1.0", "1.0.post1", -1},                   // Post release after release
+		{PEP440, "1.0.post1.dev1", "1.0.post1", -1},        // Dev release of post release
+		{PEP440, "1!0.1", "2.0", 1},                        // Epoch
+		{PEP440, "1.0+local.1", "1.0+local.abc", 1},        // Numeric local segments sort last
+		{PEP440, "1.0", "1.0+local", -1},                   // Local after public
+		{Debian, "1.0-1", "1.0-2", -1},                     // Revision
+		{Debian, "1.0~rc1-1", "1.0-1", -1},                 // Tilde sorts first
+		{Debian, "1:0.9", "2.0", 1},                        // Epoch
+		{Debian, "1.0a", "1.0+", -1},                       // Letters before symbols
+		{Debian, "1.10", "1.9", 1},                         // Numeric comparison
+		{Maven, "1.0", "1", 0},                             // Trailing zeros
+		{Maven, "1.0-SNAPSHOT", "1.0", -1},                 // Snapshot before release
+		{Maven, "1.0-alpha-1", "1.0-beta-1", -1},           // Qualifier order
+		{Maven, "1.0a1", "1.0-alpha-1", 0},                 // Qualifier alias
+		{Maven, "1.0-RC1", "1.0-cr1", 0},                   // rc alias
+		{Maven, "1.0", "1.0-sp1", -1},                      // Service pack after release
+		{Maven, "1.0.Final", "1.0", 0},                     // Final qualifier
+		{Maven, "1.0.1", "1.0-foo", 1},                     // Numbers after qualifiers
+		{Maven, "2.0.0-M1", "2.0.0-RC1", -1},               // Milestone
+		{PEP440, "not a version", "1.0", -1},               // Invalid sorts first
+		{PEP440, "not a version", "also not a version", 1}, // Invalid compare lexically
+	}
+	for _, tt := range tests {
+		if actual := Cmp(tt.scheme, tt.a, tt.b); actual != tt.expected {
+			t.Errorf("Cmp(%s, %q, %q) = %d, expected %d", tt.scheme, tt.a, tt.b, actual, tt.expected)
+		}
+	}
+}
+
+func TestIsPrerelease(t *testing.T) {
+	tests := []struct {
+		scheme   Scheme
+		v        string
+		expected bool
+	}{
+		{SemVer, "1.0.0", false},
+		{SemVer, "1.0.0-beta", true},
+		{PEP440, "1.0", false},
+		{PEP440, "1.0.post1", false},
+		{PEP440, "1.0b2", true},
+		{PEP440, "1.0.dev0", true},
+		{Debian, "1.0-1", false},
+		{Debian, "1.0~beta1-1", true},
+		{Maven, "1.0", false},
+		{Maven, "1.0-SNAPSHOT", true},
+		{Maven, "1.0.0-M2", true},
+		{Maven, "1.0.Final", false},
+		{Maven, "1.0-sp1", false},
+	}
+	for _, tt := range tests {
+		if actual := IsPrerelease(tt.scheme, tt.v); actual != tt.expected {
+			t.Errorf("IsPrerelease(%s, %q) = %v, expected %v", tt.scheme, tt.v, actual, tt.expected)
+		}
+	}
+}
+
+func TestTagForms(t *testing.T) {
+	tests := []struct {
+		scheme   Scheme
+		v        string
+		expected []string
+	}{
+		{SemVer, "v1.2.3", []string{"1.2.3"}},
+		{PEP440, "1.0.post1", []string{"1.0.post1", "1.0-1", "1.0-post1", "1.0post1"}},
+		{PEP440, "1.0RC1", []string{"1.0rc1", "1.0-rc1", "1.0-rc.1", "1.0.rc1"}},
+		{PEP440, "2.0.0.dev3", []string{"2.0.0.dev3", "2.0.0-dev3", "2.0.0dev3"}},
+		{Debian, "1:1.0~rc1-2", []string{"1.0~rc1", "1.0-rc1", "1%1.0_rc1-2"}},
+		{Maven, "1.0-beta-1", []string{"1.0-beta-1"}},
+	}
+	for _, tt := range tests {
+		v, err := Parse(tt.scheme, tt.v)
+		if err != nil {
+			t.Fatalf("Parse(%s, %q) error: %v", tt.scheme, tt.v, err)
+		}
+		if diff := cmp.Diff(tt.expected, v.TagForms()); diff != "" {
+			t.Errorf("TagForms(%s, %q) mismatch (-want +got):\n%s", tt.scheme, tt.v, diff)
+		}
+	}
+}
+
+func TestLatest(t *testing.T) {
+	got, ok := Latest(PEP440, []string{"1.9", "1.10rc1", "1.10.dev0", "1.2", "bogus"})
+	if !ok || got != "1.9" {
+		t.Errorf("Latest() = %q, %v; expected %q, true", got, ok, "1.9")
+	}
+	vs := []string{"1.0-1", "1.0~rc1-1", "0.9-3"}
+	Sort(Debian, vs)
+	if diff := cmp.Diff([]string{"0.9-3", "1.0~rc1-1", "1.0-1"}, vs); diff != "" {
+		t.Errorf("Sort() mismatch (-want +got):\n%s", diff)
+	}
+}
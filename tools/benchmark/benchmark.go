@@ -51,6 +51,7 @@ type Metadata struct {
 //
 // * Only the versions provided will be rebuilt.
 // * All supported artifacts will be built for each provided version.
+// * Pinned packages retain their versions when the set is refreshed.
 //
 // TODO: Possible extension of this form would include specific artifacts:
 //
@@ -62,4 +63,5 @@ type Package struct {
 	Ecosystem string
 	Name      string
 	Versions  []string
+	Pinned    bool `json:",omitempty"`
 }
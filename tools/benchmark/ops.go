@@ -0,0 +1,122 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Target is a single package version within a PackageSet.
+type Target struct {
+	Ecosystem string
+	Name      string
+	Version   string
+	// Pinned is whether the target's package is pinned.
+	// It does not contribute to the target's identity within set operations.
+	Pinned bool
+}
+
+func compareTargets(a, b Target) int {
+	if c := cmp.Compare(a.Ecosystem, b.Ecosystem); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Name, b.Name); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Version, b.Version)
+}
+
+// Targets returns the package versions contained in the set.
+func (ps *PackageSet) Targets() []Target {
+	var ts []Target
+	for _, p := range ps.Packages {
+		for _, v := range p.Versions {
+			ts = append(ts, Target{Ecosystem: p.Ecosystem, Name: p.Name, Version: v, Pinned: p.Pinned})
+		}
+	}
+	return ts
+}
+
+// FromTargets returns a PackageSet containing exactly the provided targets.
+// Duplicate targets are removed and packages are ordered canonically.
+// A package is pinned if any of its targets is pinned.
+func FromTargets(ts []Target) PackageSet {
+	ts = slices.Clone(ts)
+	slices.SortFunc(ts, compareTargets)
+	var ps PackageSet
+	for _, t := range ts {
+		if n := len(ps.Packages); n == 0 || ps.Packages[n-1].Ecosystem != t.Ecosystem || ps.Packages[n-1].Name != t.Name {
+			ps.Packages = append(ps.Packages, Package{Ecosystem: t.Ecosystem, Name: t.Name})
+		}
+		p := &ps.Packages[len(ps.Packages)-1]
+		p.Pinned = p.Pinned || t.Pinned
+		if n := len(p.Versions); n > 0 && p.Versions[n-1] == t.Version {
+			continue
+		}
+		p.Versions = append(p.Versions, t.Version)
+		ps.Count++
+	}
+	ps.Updated = time.Now()
+	return ps
+}
+
+// key returns the identity of the target within set operations.
+func (t Target) key() Target {
+	return Target{Ecosystem: t.Ecosystem, Name: t.Name, Version: t.Version}
+}
+
+func targetSet(ps PackageSet) map[Target]bool {
+	s := make(map[Target]bool)
+	for _, t := range ps.Targets() {
+		s[t.key()] = true
+	}
+	return s
+}
+
+// Diff returns the targets added to and removed from "before" in "after".
+func Diff(before, after PackageSet) (added, removed PackageSet) {
+	return Subtract(after, before), Subtract(before, after)
+}
+
+// Merge returns the union of the provided sets.
+func Merge(sets ...PackageSet) PackageSet {
+	var ts []Target
+	for _, ps := range sets {
+		ts = append(ts, ps.Targets()...)
+	}
+	return FromTargets(ts)
+}
+
+// Intersect returns the targets present in every one of the provided sets.
+func Intersect(first PackageSet, rest ...PackageSet) PackageSet {
+	ts := first.Targets()
+	for _, ps := range rest {
+		s := targetSet(ps)
+		ts = slices.DeleteFunc(ts, func(t Target) bool { return !s[t.key()] })
+	}
+	return FromTargets(ts)
+}
+
+// Subtract returns the targets in "ps" that are not present in any of "others".
+func Subtract(ps PackageSet, others ...PackageSet) PackageSet {
+	ts := ps.Targets()
+	for _, o := range others {
+		s := targetSet(o)
+		ts = slices.DeleteFunc(ts, func(t Target) bool { return s[t.key()] })
+	}
+	return FromTargets(ts)
+}
@@ -0,0 +1,264 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var ignoreUpdated = cmpopts.IgnoreFields(Metadata{}, "Updated")
+
+func set(ps ...Package) PackageSet {
+	var count int
+	for _, p := range ps {
+		count += len(p.Versions)
+	}
+	return PackageSet{Metadata: Metadata{Count: count}, Packages: ps}
+}
+
+func TestFromTargets(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ts   []Target
+		want PackageSet
+	}{
+		{
+			name: "empty",
+			want: set(),
+		},
+		{
+			name: "ordered and deduplicated",
+			ts: []Target{
+				{Ecosystem: "pypi", Name: "absl-py", Version: "2.0.0"},
+				{Ecosystem: "npm", Name: "lodash", Version: "4.17.21"},
+				{Ecosystem: "npm", Name: "left-pad", Version: "1.3.0"},
+				{Ecosystem: "npm", Name: "lodash", Version: "4.17.20"},
+				{Ecosystem: "npm", Name: "lodash", Version: "4.17.21"},
+			},
+			want: set(
+				Package{Ecosystem: "npm", Name: "left-pad", Versions: []string{"1.3.0"}},
+				Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20", "4.17.21"}},
+				Package{Ecosystem: "pypi", Name: "absl-py", Versions: []string{"2.0.0"}},
+			),
+		},
+		{
+			name: "pinned",
+			ts: []Target{
+				{Ecosystem: "npm", Name: "lodash", Version: "4.17.21"},
+				{Ecosystem: "npm", Name: "lodash", Version: "4.17.20", Pinned: true},
+				{Ecosystem: "npm", Name: "left-pad", Version: "1.3.0"},
+			},
+			want: set(
+				Package{Ecosystem: "npm", Name: "left-pad", Versions: []string{"1.3.0"}},
+				Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20", "4.17.21"}, Pinned: true},
+			),
+		},
+		{
+			name: "pinned duplicate",
+			ts: []Target{
+				{Ecosystem: "npm", Name: "lodash", Version: "4.17.21"},
+				{Ecosystem: "npm", Name: "lodash", Version: "4.17.21", Pinned: true},
+			},
+			want: set(
+				Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21"}, Pinned: true},
+			),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := FromTargets(tc.ts)
+			if diff := cmp.Diff(tc.want, got, ignoreUpdated, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("FromTargets() diff (-want +got):\n%s", diff)
+			}
+			if got.Updated.IsZero() {
+				t.Error("FromTargets() did not set Updated")
+			}
+		})
+	}
+}
+
+func TestTargetsRoundTrip(t *testing.T) {
+	ps := set(
+		Package{Ecosystem: "npm", Name: "left-pad", Versions: []string{"1.3.0"}},
+		Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20", "4.17.21"}, Pinned: true},
+	)
+	if diff := cmp.Diff(ps, FromTargets(ps.Targets()), ignoreUpdated); diff != "" {
+		t.Errorf("FromTargets(Targets()) diff (-want +got):\n%s", diff)
+	}
+}
+
+var (
+	leftPad  = Package{Ecosystem: "npm", Name: "left-pad", Versions: []string{"1.3.0"}}
+	lodash   = Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20", "4.17.21"}}
+	lodashV2 = Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.21"}}
+	absl     = Package{Ecosystem: "pypi", Name: "absl-py", Versions: []string{"2.0.0"}, Pinned: true}
+)
+
+func pinned(p Package) Package {
+	p.Pinned = true
+	return p
+}
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name                   string
+		before, after          PackageSet
+		wantAdded, wantRemoved PackageSet
+	}{
+		{
+			name:        "identical",
+			before:      set(leftPad, lodash),
+			after:       set(leftPad, lodash),
+			wantAdded:   set(),
+			wantRemoved: set(),
+		},
+		{
+			name:        "added and removed",
+			before:      set(leftPad, lodashV2),
+			after:       set(lodash, absl),
+			wantAdded:   set(Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20"}}, absl),
+			wantRemoved: set(leftPad),
+		},
+		{
+			name:        "pinning alone is not a change",
+			before:      set(lodash),
+			after:       set(pinned(lodash)),
+			wantAdded:   set(),
+			wantRemoved: set(),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			added, removed := Diff(tc.before, tc.after)
+			if diff := cmp.Diff(tc.wantAdded, added, ignoreUpdated, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Diff() added diff (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantRemoved, removed, ignoreUpdated, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Diff() removed diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		sets []PackageSet
+		want PackageSet
+	}{
+		{
+			name: "none",
+			want: set(),
+		},
+		{
+			name: "union",
+			sets: []PackageSet{set(absl, lodashV2), set(leftPad, lodash)},
+			want: set(leftPad, lodash, absl),
+		},
+		{
+			name: "pinned in one set",
+			sets: []PackageSet{set(lodashV2), set(pinned(lodash))},
+			want: set(pinned(lodash)),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Merge(tc.sets...)
+			if diff := cmp.Diff(tc.want, got, ignoreUpdated, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Merge() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		first PackageSet
+		rest  []PackageSet
+		want  PackageSet
+	}{
+		{
+			name:  "single set",
+			first: set(leftPad, absl),
+			want:  set(leftPad, absl),
+		},
+		{
+			name:  "common versions",
+			first: set(leftPad, lodash, absl),
+			rest:  []PackageSet{set(lodashV2, absl), set(leftPad, lodash, absl)},
+			want:  set(lodashV2, absl),
+		},
+		{
+			name:  "disjoint",
+			first: set(leftPad),
+			rest:  []PackageSet{set(lodash)},
+			want:  set(),
+		},
+		{
+			name:  "pinned in first",
+			first: set(pinned(lodash)),
+			rest:  []PackageSet{set(lodashV2)},
+			want:  set(pinned(lodashV2)),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Intersect(tc.first, tc.rest...)
+			if diff := cmp.Diff(tc.want, got, ignoreUpdated, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Intersect() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		ps     PackageSet
+		others []PackageSet
+		want   PackageSet
+	}{
+		{
+			name: "no others",
+			ps:   set(leftPad, lodash),
+			want: set(leftPad, lodash),
+		},
+		{
+			name:   "several others",
+			ps:     set(leftPad, lodash, absl),
+			others: []PackageSet{set(lodashV2), set(absl)},
+			want:   set(leftPad, Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20"}}),
+		},
+		{
+			name:   "pinned retained",
+			ps:     set(pinned(lodash)),
+			others: []PackageSet{set(lodashV2)},
+			want:   set(Package{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20"}, Pinned: true}),
+		},
+		{
+			name:   "pinning does not affect membership",
+			ps:     set(lodash),
+			others: []PackageSet{set(pinned(lodash))},
+			want:   set(),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Subtract(tc.ps, tc.others...)
+			if diff := cmp.Diff(tc.want, got, ignoreUpdated, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Subtract() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+)
+
+const (
+	runFile      = "run.json"
+	progressFile = "progress.jsonl"
+)
+
+// Run describes a benchmark execution sufficiently to resume it.
+type Run struct {
+	ID   string
+	Mode string
+	API  string
+	// Local is whether the run's requests go directly to a rebuilder rather
+	// than through the API.
+	Local bool `json:",omitempty"`
+	// DependencyCache, if set, is the key of the dependency caches mounted
+	// into the local rebuilder started to execute the run.
+	DependencyCache string `json:",omitempty"`
+	// PopulateDependencyCache is whether the run fills DependencyCache.
+	PopulateDependencyCache bool `json:",omitempty"`
+	Set                     PackageSet
+}
+
+// Progress is the on-disk record of the targets completed by a benchmark run.
+//
+// Verdicts are appended to a write-ahead log and synced as they are received
+// so that a run interrupted at any point can be resumed from its unfinished
+// targets. Damage left by a crash mid-write is repaired when the record is
+// next opened.
+type Progress struct {
+	Run
+	mu        sync.Mutex
+	dir       string
+	f         *os.File
+	completed map[string]schema.Verdict
+}
+
+// DefaultProgressDir returns the directory in which run progress is stored by default.
+func DefaultProgressDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "oss-rebuild", "runs"), nil
+}
+
+// CreateProgress creates the progress record for a new run within dir.
+func CreateProgress(dir string, run Run) (*Progress, error) {
+	rundir := filepath.Join(dir, run.ID)
+	if err := os.MkdirAll(rundir, 0755); err != nil {
+		return nil, errors.Wrap(err, "creating run directory")
+	}
+	b, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "marshalling run")
+	}
+	if err := writeFileAtomic(filepath.Join(rundir, runFile), b); err != nil {
+		return nil, errors.Wrap(err, "writing run")
+	}
+	if err := writeFileAtomic(filepath.Join(rundir, progressFile), nil); err != nil {
+		return nil, errors.Wrap(err, "creating progress file")
+	}
+	p := &Progress{Run: run, dir: rundir, completed: make(map[string]schema.Verdict)}
+	if err := p.openLog(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// OpenProgress loads the progress record of the run with the provided ID from dir.
+func OpenProgress(dir, id string) (*Progress, error) {
+	rundir := filepath.Join(dir, id)
+	b, err := os.ReadFile(filepath.Join(rundir, runFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading run")
+	}
+	p := &Progress{dir: rundir, completed: make(map[string]schema.Verdict)}
+	if err := json.Unmarshal(b, &p.Run); err != nil {
+		return nil, errors.Wrap(err, "parsing run")
+	}
+	data, err := os.ReadFile(filepath.Join(rundir, progressFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "reading progress file")
+	}
+	var damaged bool
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var v schema.Verdict
+		if err := json.Unmarshal(line, &v); err != nil {
+			// NOTE: A partial record can be left by an abrupt exit. Its target is simply rerun.
+			damaged = true
+			continue
+		}
+		p.completed[targetKey(string(v.Target.Ecosystem), v.Target.Package, v.Target.Version)] = v
+	}
+	if damaged || (len(data) > 0 && data[len(data)-1] != '\n') {
+		if err := p.Compact(); err != nil {
+			return nil, errors.Wrap(err, "repairing progress file")
+		}
+		return p, nil
+	}
+	if err := p.openLog(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func targetKey(ecosystem, pkg, version string) string {
+	return strings.Join([]string{ecosystem, pkg, version}, "|")
+}
+
+// Record appends the verdict to the progress file and marks its target complete.
+func (p *Progress) Record(v schema.Verdict) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "marshalling verdict")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.f.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "writing progress")
+	}
+	if err := p.f.Sync(); err != nil {
+		return errors.Wrap(err, "syncing progress")
+	}
+	p.completed[targetKey(string(v.Target.Ecosystem), v.Target.Package, v.Target.Version)] = v
+	return nil
+}
+
+// Completed returns the verdicts recorded for the run.
+func (p *Progress) Completed() []schema.Verdict {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	verdicts := make([]schema.Verdict, 0, len(p.completed))
+	for _, v := range p.completed {
+		verdicts = append(verdicts, v)
+	}
+	return verdicts
+}
+
+// Remaining returns the packages and versions from the run's benchmark with no recorded verdict.
+func (p *Progress) Remaining() []Package {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var remaining []Package
+	for _, pkg := range p.Set.Packages {
+		var versions []string
+		for _, v := range pkg.Versions {
+			if _, ok := p.completed[targetKey(pkg.Ecosystem, pkg.Name, v)]; !ok {
+				versions = append(versions, v)
+			}
+		}
+		if len(versions) > 0 {
+			remaining = append(remaining, Package{Ecosystem: pkg.Ecosystem, Name: pkg.Name, Versions: versions})
+		}
+	}
+	return remaining
+}
+
+// Compact rewrites the progress file to contain exactly one record per completed target.
+//
+// The rewritten log atomically replaces the existing one so a crash during
+// compaction leaves either the old or new log intact.
+func (p *Progress) Compact() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := make([]string, 0, len(p.completed))
+	for k := range p.completed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, k := range keys {
+		b, err := json.Marshal(p.completed[k])
+		if err != nil {
+			return errors.Wrap(err, "marshalling verdict")
+		}
+		buf.Write(append(b, '\n'))
+	}
+	if p.f != nil {
+		if err := p.f.Close(); err != nil {
+			return errors.Wrap(err, "closing progress file")
+		}
+		p.f = nil
+	}
+	if err := writeFileAtomic(filepath.Join(p.dir, progressFile), buf.Bytes()); err != nil {
+		return errors.Wrap(err, "writing progress")
+	}
+	return p.openLog()
+}
+
+// openLog opens the progress file for appending.
+func (p *Progress) openLog() error {
+	f, err := os.OpenFile(filepath.Join(p.dir, progressFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "opening progress file")
+	}
+	p.f = f
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with data such that a crash leaves either the old or new contents.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// Sync the directory so the rename itself survives a crash.
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Close closes the underlying progress file.
+func (p *Progress) Close() error {
+	return p.f.Close()
+}
@@ -0,0 +1,224 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+)
+
+var testRun = Run{
+	ID:              "2024-01-01T00:00:00Z",
+	Mode:            "smoketest",
+	API:             "http://localhost:8080",
+	Local:           true,
+	DependencyCache: "deps",
+	Set: PackageSet{
+		Metadata: Metadata{Count: 3, Updated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Packages: []Package{
+			{Ecosystem: "npm", Name: "left-pad", Versions: []string{"1.3.0"}},
+			{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20", "4.17.21"}},
+		},
+	},
+}
+
+func verdict(pkg, version, msg string) schema.Verdict {
+	return schema.Verdict{Target: rebuild.Target{Ecosystem: rebuild.NPM, Package: pkg, Version: version}, Message: msg}
+}
+
+var sortVerdicts = cmpopts.SortSlices(func(a, b schema.Verdict) bool { return a.Target.Version < b.Target.Version })
+
+// progressLog returns the contents of the run's progress file.
+func progressLog(t *testing.T, dir string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, testRun.ID, progressFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestOpenProgress(t *testing.T) {
+	dir := t.TempDir()
+	p, err := CreateProgress(dir, testRun)
+	if err != nil {
+		t.Fatalf("CreateProgress() returned error: %v", err)
+	}
+	want := []schema.Verdict{verdict("lodash", "4.17.20", ""), verdict("left-pad", "1.3.0", "failed")}
+	for _, v := range want {
+		if err := p.Record(v); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p, err = OpenProgress(dir, testRun.ID)
+	if err != nil {
+		t.Fatalf("OpenProgress() returned error: %v", err)
+	}
+	defer p.Close()
+	if diff := cmp.Diff(testRun, p.Run); diff != "" {
+		t.Errorf("Run diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, p.Completed(), sortVerdicts); diff != "" {
+		t.Errorf("Completed() diff (-want +got):\n%s", diff)
+	}
+	// Records appended after reopening are retained.
+	if err := p.Record(verdict("lodash", "4.17.21", "")); err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+	if got := strings.Count(progressLog(t, dir), "\n"); got != 3 {
+		t.Errorf("progress file has %d records, want 3", got)
+	}
+}
+
+func TestOpenProgressDamaged(t *testing.T) {
+	dir := t.TempDir()
+	p, err := CreateProgress(dir, testRun)
+	if err != nil {
+		t.Fatalf("CreateProgress() returned error: %v", err)
+	}
+	if err := p.Record(verdict("lodash", "4.17.20", "")); err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+	// Simulate a crash part way through writing a record.
+	if _, err := p.f.WriteString(`{"Target":{"Ecosys`); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	p, err = OpenProgress(dir, testRun.ID)
+	if err != nil {
+		t.Fatalf("OpenProgress() returned error: %v", err)
+	}
+	defer p.Close()
+	if diff := cmp.Diff([]schema.Verdict{verdict("lodash", "4.17.20", "")}, p.Completed()); diff != "" {
+		t.Errorf("Completed() diff (-want +got):\n%s", diff)
+	}
+	if log := progressLog(t, dir); !strings.HasSuffix(log, "\n") || strings.Count(log, "\n") != 1 {
+		t.Errorf("progress file not repaired: %q", log)
+	}
+	if err := p.Record(verdict("lodash", "4.17.21", "")); err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+	if got := strings.Count(progressLog(t, dir), "\n"); got != 2 {
+		t.Errorf("progress file has %d records after repair, want 2", got)
+	}
+}
+
+func TestOpenProgressMissing(t *testing.T) {
+	if _, err := OpenProgress(t.TempDir(), "missing"); err == nil {
+		t.Error("OpenProgress() succeeded for a missing run")
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		recorded []schema.Verdict
+		want     []Package
+	}{
+		{
+			name: "none completed",
+			want: testRun.Set.Packages,
+		},
+		{
+			name:     "failures are complete",
+			recorded: []schema.Verdict{verdict("left-pad", "1.3.0", "failed"), verdict("lodash", "4.17.21", "")},
+			want:     []Package{{Ecosystem: "npm", Name: "lodash", Versions: []string{"4.17.20"}}},
+		},
+		{
+			name:     "all completed",
+			recorded: []schema.Verdict{verdict("left-pad", "1.3.0", ""), verdict("lodash", "4.17.20", ""), verdict("lodash", "4.17.21", "")},
+		},
+		{
+			name:     "unknown targets ignored",
+			recorded: []schema.Verdict{verdict("react", "18.0.0", "")},
+			want:     testRun.Set.Packages,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := CreateProgress(t.TempDir(), testRun)
+			if err != nil {
+				t.Fatalf("CreateProgress() returned error: %v", err)
+			}
+			defer p.Close()
+			for _, v := range tc.recorded {
+				if err := p.Record(v); err != nil {
+					t.Fatalf("Record() returned error: %v", err)
+				}
+			}
+			if diff := cmp.Diff(tc.want, p.Remaining()); diff != "" {
+				t.Errorf("Remaining() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	p, err := CreateProgress(dir, testRun)
+	if err != nil {
+		t.Fatalf("CreateProgress() returned error: %v", err)
+	}
+	defer p.Close()
+	// A rerun target is recorded again, superseding its earlier verdict.
+	for _, v := range []schema.Verdict{
+		verdict("lodash", "4.17.21", "failed"),
+		verdict("lodash", "4.17.20", ""),
+		verdict("lodash", "4.17.21", ""),
+	} {
+		if err := p.Record(v); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+	if got := strings.Count(progressLog(t, dir), "\n"); got != 3 {
+		t.Fatalf("progress file has %d records before compaction, want 3", got)
+	}
+	if err := p.Compact(); err != nil {
+		t.Fatalf("Compact() returned error: %v", err)
+	}
+	log := progressLog(t, dir)
+	if got := strings.Count(log, "\n"); got != 2 {
+		t.Errorf("progress file has %d records after compaction, want 2", got)
+	}
+	if strings.Contains(log, "failed") {
+		t.Errorf("progress file retains superseded verdict: %q", log)
+	}
+	// The log remains writable after compaction.
+	if err := p.Record(verdict("left-pad", "1.3.0", "")); err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+	reopened, err := OpenProgress(dir, testRun.ID)
+	if err != nil {
+		t.Fatalf("OpenProgress() returned error: %v", err)
+	}
+	defer reopened.Close()
+	want := []schema.Verdict{verdict("left-pad", "1.3.0", ""), verdict("lodash", "4.17.20", ""), verdict("lodash", "4.17.21", "")}
+	if diff := cmp.Diff(want, reopened.Completed(), sortVerdicts); diff != "" {
+		t.Errorf("Completed() diff (-want +got):\n%s", diff)
+	}
+	if len(reopened.Remaining()) != 0 {
+		t.Errorf("Remaining() = %v, want none", reopened.Remaining())
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/registry/maven"
+	"github.com/google/oss-rebuild/pkg/version"
+	"github.com/pkg/errors"
+)
+
+// Refresh returns a copy of "ps" with each unpinned package's versions replaced
+// by its most recent releases from the registry.
+//
+// If "count" is zero, each package retains its current number of versions.
+// Packages whose versions cannot be fetched are left unchanged.
+func Refresh(ctx context.Context, ps PackageSet, mux rebuild.RegistryMux, count int) PackageSet {
+	out := PackageSet{Packages: make([]Package, 0, len(ps.Packages))}
+	for _, p := range ps.Packages {
+		if !p.Pinned {
+			n := count
+			if n == 0 {
+				n = len(p.Versions)
+			}
+			versions, err := latestVersions(ctx, mux, p, n)
+			switch {
+			case err != nil:
+				log.Printf("Keeping versions of %s/%s: %v", p.Ecosystem, p.Name, err)
+			case len(versions) == 0:
+				log.Printf("Keeping versions of %s/%s: no candidate releases", p.Ecosystem, p.Name)
+			default:
+				p.Versions = versions
+			}
+		}
+		out.Packages = append(out.Packages, p)
+		out.Count += len(p.Versions)
+	}
+	out.Updated = time.Now()
+	return out
+}
+
+type release struct {
+	Version string
+	Created time.Time
+}
+
+// latestVersions returns up to "n" of the most recent non-prerelease versions of "p".
+func latestVersions(ctx context.Context, mux rebuild.RegistryMux, p Package, n int) ([]string, error) {
+	var releases []release
+	switch rebuild.Ecosystem(p.Ecosystem) {
+	case rebuild.NPM:
+		pkg, err := mux.NPM.Package(ctx, p.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching package")
+		}
+		for v := range pkg.Versions {
+			if version.IsPrerelease(version.SemVer, v) {
+				continue
+			}
+			releases = append(releases, release{v, pkg.UploadTimes[v]})
+		}
+	case rebuild.PyPI:
+		proj, err := mux.PyPI.Project(ctx, p.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching project")
+		}
+		for v, artifacts := range proj.Releases {
+			if len(artifacts) == 0 || version.IsPrerelease(version.PEP440, v) {
+				continue
+			}
+			created := artifacts[0].UploadTime
+			for _, a := range artifacts[1:] {
+				if a.UploadTime.Before(created) {
+					created = a.UploadTime
+				}
+			}
+			releases = append(releases, release{v, created})
+		}
+	case rebuild.CratesIO:
+		crate, err := mux.CratesIO.Crate(ctx, p.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching crate")
+		}
+		for _, v := range crate.Versions {
+			if v.Yanked || version.IsPrerelease(version.SemVer, v.Version) {
+				continue
+			}
+			releases = append(releases, release{v.Version, v.Created})
+		}
+	case rebuild.Maven:
+		meta, err := maven.PackageMetadata(p.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetching package metadata")
+		}
+		// NOTE: Maven metadata provides no release times but lists versions in publication order.
+		for i, v := range meta.Versions {
+			if version.IsPrerelease(version.Maven, v) {
+				continue
+			}
+			releases = append(releases, release{v, time.Unix(int64(i), 0)})
+		}
+	default:
+		return nil, errors.Errorf("unsupported ecosystem: %s", p.Ecosystem)
+	}
+	sort.SliceStable(releases, func(i, j int) bool { return releases[i].Created.After(releases[j].Created) })
+	var versions []string
+	for _, r := range releases[:min(n, len(releases))] {
+		versions = append(versions, r.Version)
+	}
+	return versions, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	billy "github.com/go-git/go-billy/v5"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/pkg/errors"
+)
+
+// Repository is a source of benchmark definitions.
+type Repository interface {
+	// List returns the paths of the available benchmarks.
+	List(ctx context.Context) ([]string, error)
+	// Load reads the benchmark at the provided path.
+	Load(ctx context.Context, name string) (PackageSet, error)
+}
+
+func decodePackageSet(r io.Reader) (ps PackageSet, err error) {
+	err = json.NewDecoder(r).Decode(&ps)
+	return
+}
+
+// FilesystemRepository reads benchmark definitions from a filesystem.
+type FilesystemRepository struct {
+	fs billy.Filesystem
+}
+
+var _ Repository = &FilesystemRepository{}
+
+// NewFilesystemRepository creates a new FilesystemRepository.
+func NewFilesystemRepository(fs billy.Filesystem) *FilesystemRepository {
+	return &FilesystemRepository{fs: fs}
+}
+
+// List returns the paths of the JSON files in the filesystem.
+func (r *FilesystemRepository) List(ctx context.Context) ([]string, error) {
+	var names []string
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := r.fs.ReadDir(dir)
+		if err != nil {
+			return errors.Wrapf(err, "reading %s", dir)
+		}
+		for _, e := range entries {
+			p := path.Join(dir, e.Name())
+			if e.IsDir() {
+				if err := walk(p); err != nil {
+					return err
+				}
+			} else if strings.HasSuffix(p, ".json") {
+				names = append(names, p)
+			}
+		}
+		return nil
+	}
+	if err := walk("."); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// Load reads the benchmark at the provided path.
+func (r *FilesystemRepository) Load(ctx context.Context, name string) (PackageSet, error) {
+	f, err := r.fs.Open(name)
+	if err != nil {
+		return PackageSet{}, errors.Wrap(err, "opening benchmark")
+	}
+	defer f.Close()
+	return decodePackageSet(f)
+}
+
+// Version is a revision of a benchmark in a GitRepository.
+type Version struct {
+	Commit  string
+	Time    time.Time
+	Subject string
+}
+
+// GitRepository reads benchmark definitions from a commit of a git repository.
+//
+// Benchmarks loaded from a GitRepository can be traced to the exact commit
+// from which they were read using Commit.
+type GitRepository struct {
+	url    string
+	repo   *git.Repository
+	commit *object.Commit
+}
+
+var _ Repository = &GitRepository{}
+
+// NewGitRepository clones the repository at url and resolves ref to the commit from which benchmarks will be read.
+//
+// The ref may be a branch, tag, or commit hash and defaults to HEAD.
+func NewGitRepository(ctx context.Context, url, ref string) (*GitRepository, error) {
+	if ref == "" {
+		ref = plumbing.HEAD.String()
+	}
+	// NOTE: The full history is fetched so any version may be resolved and listed.
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{URL: url})
+	if err != nil {
+		return nil, errors.Wrap(err, "cloning repository")
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving %s", ref)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading commit")
+	}
+	return &GitRepository{url: url, repo: repo, commit: commit}, nil
+}
+
+// URL returns the location of the repository.
+func (r *GitRepository) URL() string {
+	return r.url
+}
+
+// Commit returns the hash of the commit from which benchmarks are read.
+func (r *GitRepository) Commit() string {
+	return r.commit.Hash.String()
+}
+
+// List returns the paths of the JSON files in the commit.
+func (r *GitRepository) List(ctx context.Context) ([]string, error) {
+	files, err := r.commit.Files()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing files")
+	}
+	var names []string
+	err = files.ForEach(func(f *object.File) error {
+		if strings.HasSuffix(f.Name, ".json") {
+			names = append(names, f.Name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// Load reads the benchmark at the provided path in the commit.
+func (r *GitRepository) Load(ctx context.Context, name string) (PackageSet, error) {
+	f, err := r.commit.File(name)
+	if err != nil {
+		return PackageSet{}, errors.Wrapf(err, "finding %s", name)
+	}
+	rc, err := f.Reader()
+	if err != nil {
+		return PackageSet{}, errors.Wrap(err, "opening benchmark")
+	}
+	defer rc.Close()
+	return decodePackageSet(rc)
+}
+
+// Versions returns the commits modifying the benchmark at the provided path, most recent first.
+func (r *GitRepository) Versions(ctx context.Context, name string) ([]Version, error) {
+	iter, err := r.repo.Log(&git.LogOptions{From: r.commit.Hash, FileName: &name})
+	if err != nil {
+		return nil, errors.Wrap(err, "reading history")
+	}
+	defer iter.Close()
+	var versions []Version
+	err = iter.ForEach(func(c *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		versions = append(versions, Version{Commit: c.Hash.String(), Time: c.Committer.When, Subject: subject})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "walking history")
+	}
+	return versions, nil
+}
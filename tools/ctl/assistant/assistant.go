@@ -0,0 +1,257 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package assistant drafts triage artifacts using a generative language model.
+//
+// Everything produced here is a draft intended for human review before use.
+package assistant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/pkg/errors"
+)
+
+// Model generates text in response to a prompt.
+type Model interface {
+	Generate(ctx context.Context, prompt string) (string, error)
+}
+
+// VertexModel is a Model served by the Vertex AI generateContent API.
+type VertexModel struct {
+	// Client must attach credentials authorized to call Vertex AI in Project.
+	Client   httpx.BasicClient
+	Project  string
+	Location string
+	// Model is the name of the publisher model, e.g. "gemini-1.5-pro".
+	Model string
+	// BaseURL, if provided, overrides the regional Vertex AI endpoint.
+	BaseURL string
+	// Usage, if provided, accumulates the tokens consumed by Generate.
+	Usage *Usage
+	// MinAvgLogprob, if nonzero, is the least average log probability of the
+	// tokens of a response below which it is rejected with ErrLowConfidence.
+	MinAvgLogprob float64
+}
+
+var _ ToolModel = &VertexModel{}
+var _ TokenCounter = &VertexModel{}
+
+type vertexPart struct {
+	Text             string          `json:"text,omitempty"`
+	FunctionCall     *FunctionCall   `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResult `json:"functionResponse,omitempty"`
+}
+
+type vertexContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []vertexPart `json:"parts"`
+}
+
+type vertexFunction struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Parameters  *Schema `json:"parameters"`
+}
+
+type vertexTool struct {
+	FunctionDeclarations []vertexFunction `json:"functionDeclarations"`
+}
+
+type vertexRequest struct {
+	Contents         []vertexContent `json:"contents"`
+	Tools            []vertexTool    `json:"tools,omitempty"`
+	GenerationConfig struct {
+		Temperature float64 `json:"temperature"`
+	} `json:"generationConfig"`
+}
+
+type vertexResponse struct {
+	Candidates []struct {
+		Content      vertexContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+		AvgLogprobs  float64       `json:"avgLogprobs"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// temperature is kept low since drafts should restate the evidence, not embellish it.
+const temperature = 0.2
+
+// contextOverflow matches the errors returned by Vertex AI for prompts exceeding a model's context.
+var contextOverflow = regexp.MustCompile(`(?i)(input|prompt|token).*(exceeds|too long|limit)`)
+
+// call posts body to the model's method and decodes the response into out.
+func (m *VertexModel) call(ctx context.Context, method string, body, out any) error {
+	base := m.BaseURL
+	if base == "" {
+		base = fmt.Sprintf("https://%s-aiplatform.googleapis.com", m.Location)
+	}
+	u := fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s", strings.TrimSuffix(base, "/"), m.Project, m.Location, m.Model, method)
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "calling model")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		msg := strings.TrimSpace(string(b))
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return errors.Wrapf(ErrQuota, "calling model: %s: %s", resp.Status, msg)
+		case resp.StatusCode == http.StatusBadRequest && contextOverflow.MatchString(msg):
+			return errors.Wrapf(ErrContextOverflow, "calling model: %s: %s", resp.Status, msg)
+		default:
+			return errors.Errorf("calling model: %s: %s", resp.Status, msg)
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrap(err, "decoding model response")
+	}
+	return nil
+}
+
+// Generate returns the text of the first candidate generated for prompt.
+func (m *VertexModel) Generate(ctx context.Context, prompt string) (string, error) {
+	turn, err := m.Converse(ctx, []Turn{{Role: "user", Text: prompt}}, nil)
+	if err != nil {
+		return "", err
+	}
+	return turn.Text, nil
+}
+
+// Converse returns the first candidate generated for the conversation.
+func (m *VertexModel) Converse(ctx context.Context, turns []Turn, tools []Tool) (*Turn, error) {
+	var body vertexRequest
+	for _, t := range turns {
+		c := vertexContent{Role: t.Role}
+		if t.Text != "" {
+			c.Parts = append(c.Parts, vertexPart{Text: t.Text})
+		}
+		for i := range t.Calls {
+			c.Parts = append(c.Parts, vertexPart{FunctionCall: &t.Calls[i]})
+		}
+		for i := range t.Results {
+			c.Parts = append(c.Parts, vertexPart{FunctionResponse: &t.Results[i]})
+		}
+		body.Contents = append(body.Contents, c)
+	}
+	if len(tools) > 0 {
+		var decls []vertexFunction
+		for _, t := range tools {
+			decls = append(decls, vertexFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+		}
+		body.Tools = []vertexTool{{FunctionDeclarations: decls}}
+	}
+	body.GenerationConfig.Temperature = temperature
+	var out vertexResponse
+	if err := m.call(ctx, "generateContent", body, &out); err != nil {
+		return nil, err
+	}
+	if m.Usage != nil {
+		m.Usage.add(out.UsageMetadata.PromptTokenCount, out.UsageMetadata.CandidatesTokenCount)
+	}
+	if len(out.Candidates) == 0 {
+		return nil, errors.New("model returned no candidates")
+	}
+	c := out.Candidates[0]
+	turn := Turn{Role: "model"}
+	var text strings.Builder
+	for _, p := range c.Content.Parts {
+		text.WriteString(p.Text)
+		if p.FunctionCall != nil {
+			turn.Calls = append(turn.Calls, *p.FunctionCall)
+		}
+	}
+	turn.Text = text.String()
+	if turn.Text == "" && len(turn.Calls) == 0 {
+		return nil, errors.Wrapf(ErrLowConfidence, "model returned no text (finish reason: %s)", c.FinishReason)
+	}
+	if m.MinAvgLogprob != 0 && c.AvgLogprobs < m.MinAvgLogprob {
+		return nil, errors.Wrapf(ErrLowConfidence, "average log probability %.3f below %.3f", c.AvgLogprobs, m.MinAvgLogprob)
+	}
+	return &turn, nil
+}
+
+// CountTokens returns the number of tokens in text as seen by the model.
+func (m *VertexModel) CountTokens(ctx context.Context, text string) (int, error) {
+	body := struct {
+		Contents []vertexContent `json:"contents"`
+	}{Contents: []vertexContent{{Role: "user", Parts: []vertexPart{{Text: text}}}}}
+	var out struct {
+		TotalTokens int `json:"totalTokens"`
+	}
+	if err := m.call(ctx, "countTokens", body, &out); err != nil {
+		return 0, err
+	}
+	return out.TotalTokens, nil
+}
+
+// embedBatchSize is the most texts embedded in a single request.
+const embedBatchSize = 50
+
+// Embed returns the model's embedding of each of texts.
+// Model must be an embedding model, e.g. "text-embedding-004".
+func (m *VertexModel) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	type instance struct {
+		Content  string `json:"content"`
+		TaskType string `json:"task_type"`
+	}
+	var out [][]float32
+	for start := 0; start < len(texts); start += embedBatchSize {
+		var body struct {
+			Instances []instance `json:"instances"`
+		}
+		for _, t := range texts[start:min(start+embedBatchSize, len(texts))] {
+			body.Instances = append(body.Instances, instance{Content: t, TaskType: "SEMANTIC_SIMILARITY"})
+		}
+		var resp struct {
+			Predictions []struct {
+				Embeddings struct {
+					Values []float32 `json:"values"`
+				} `json:"embeddings"`
+			} `json:"predictions"`
+		}
+		if err := m.call(ctx, "predict", body, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.Predictions) != len(body.Instances) {
+			return nil, errors.Errorf("model returned %d embeddings for %d texts", len(resp.Predictions), len(body.Instances))
+		}
+		for _, p := range resp.Predictions {
+			out = append(out, p.Embeddings.Values)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,386 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/ctl/stabilizers"
+)
+
+func TestVertexModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/v1/projects/proj/locations/us-central1/publishers/google/models/gemini:generateContent"; r.URL.Path != want {
+			t.Errorf("path = %s, want %s", r.URL.Path, want)
+		}
+		var req vertexRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if len(req.Contents) != 1 || req.Contents[0].Parts[0].Text != "prompt" {
+			t.Errorf("request contents = %+v, want the prompt", req.Contents)
+		}
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"hello "},{"text":"world"}]}}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":2}}`))
+	}))
+	defer srv.Close()
+	usage := new(Usage)
+	m := &VertexModel{Client: srv.Client(), Project: "proj", Location: "us-central1", Model: "gemini", BaseURL: srv.URL, Usage: usage}
+	got, err := m.Generate(context.Background(), "prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello world" {
+		t.Errorf("Generate() = %q, want %q", got, "hello world")
+	}
+	if _, err := m.Generate(context.Background(), "prompt"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := usage.String(), "2 requests consuming 20 prompt and 4 output tokens"; got != want {
+		t.Errorf("Usage = %q, want %q", got, want)
+	}
+	if got := usage.Cost(Pricing{Prompt: 1e5, Output: 1e6}); got != 6 {
+		t.Errorf("Cost() = %v, want 6", got)
+	}
+}
+
+func TestVertexModelCountTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/models/gemini:countTokens") {
+			t.Errorf("path = %s, want countTokens method", r.URL.Path)
+		}
+		w.Write([]byte(`{"totalTokens":42}`))
+	}))
+	defer srv.Close()
+	m := &VertexModel{Client: srv.Client(), Project: "proj", Location: "us-central1", Model: "gemini", BaseURL: srv.URL}
+	got, err := m.CountTokens(context.Background(), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 {
+		t.Errorf("CountTokens() = %d, want 42", got)
+	}
+}
+
+func TestVertexModelEmbed(t *testing.T) {
+	var batches []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/models/embedder:predict") {
+			t.Errorf("path = %s, want predict method", r.URL.Path)
+		}
+		var req struct {
+			Instances []struct {
+				Content string `json:"content"`
+			} `json:"instances"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		batches = append(batches, len(req.Instances))
+		var preds []string
+		for _, inst := range req.Instances {
+			preds = append(preds, fmt.Sprintf(`{"embeddings":{"values":[%d]}}`, len(inst.Content)))
+		}
+		fmt.Fprintf(w, `{"predictions":[%s]}`, strings.Join(preds, ","))
+	}))
+	defer srv.Close()
+	m := &VertexModel{Client: srv.Client(), Project: "proj", Location: "us-central1", Model: "embedder", BaseURL: srv.URL}
+	var texts []string
+	for i := 0; i < embedBatchSize+1; i++ {
+		texts = append(texts, strings.Repeat("x", i))
+	}
+	got, err := m.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(texts) || got[embedBatchSize][0] != embedBatchSize {
+		t.Errorf("Embed() = %v, want an embedding of each text in order", got)
+	}
+	if diff := cmp.Diff([]int{embedBatchSize, 1}, batches); diff != "" {
+		t.Errorf("batches mismatch (-want +got):\n%s", diff)
+	}
+}
+
+type modelFunc func(context.Context, string) (string, error)
+
+func (f modelFunc) Generate(ctx context.Context, prompt string) (string, error) {
+	return f(ctx, prompt)
+}
+
+func TestDraftBugReport(t *testing.T) {
+	ev := BugReportEvidence{
+		Target:     rebuild.Target{Ecosystem: rebuild.NPM, Package: "pkg", Version: "1.0.0", Artifact: "pkg-1.0.0.tgz"},
+		Dockerfile: "RUN npm pack",
+		Diff:       "\x1b[1m--- rebuild\x1b[0m\n+++ upstream\n├── package/dist/index.js\n-a\n+b\n",
+	}
+	var prompt string
+	m := modelFunc(func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return "```markdown\n# Nondeterministic build\n```\n", nil
+	})
+	got, err := DraftBugReport(context.Background(), m, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "<!-- DRAFT") || !strings.HasSuffix(got, "\n\n# Nondeterministic build\n") {
+		t.Errorf("DraftBugReport() = %q, want unfenced draft with header", got)
+	}
+	for _, want := range []string{"npm pkg@1.0.0", "RUN npm pack", "--- rebuild\n+++ upstream"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q", want)
+		}
+	}
+	if _, err := DraftBugReport(context.Background(), m, BugReportEvidence{}); err == nil {
+		t.Error("DraftBugReport() without diff succeeded, want error")
+	}
+}
+
+func TestMinimalDiff(t *testing.T) {
+	var lines []string
+	lines = append(lines, "--- rebuild", "+++ upstream")
+	for _, f := range []string{"a", "b"} {
+		lines = append(lines, "├── "+f)
+		for i := 0; i < 10; i++ {
+			lines = append(lines, "-x", "+y")
+		}
+	}
+	got := MinimalDiff(strings.Join(lines, "\n"), 12)
+	want := strings.Join([]string{
+		"--- rebuild", "+++ upstream",
+		"├── a", "-x", "+y", "-x", "[17 lines omitted]",
+		"├── b", "-x", "+y", "-x", "[17 lines omitted]",
+	}, "\n")
+	if got != want {
+		t.Errorf("MinimalDiff() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitLog(t *testing.T) {
+	got := SplitLog("aa\nbb\ncc\ndddddddd\ne", 6)
+	want := []Segment{
+		{Start: 0, Text: "aa\nbb\n"},
+		{Start: 2, Text: "cc\n"},
+		{Start: 3, Text: "dddddd"},
+		{Start: 3, Text: "dd\ne"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SplitLog() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiagnoseLogs(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "pkg", Version: "1.0.0"}
+	logs := "first error\nsecond\nthird\n"
+	for _, tc := range []struct {
+		name    string
+		config  LogConfig
+		prompts int
+		want    []string
+	}{
+		{"fits", LogConfig{Strategy: TailStrategy, ContextBytes: 100}, 1, []string{"first error\nsecond\nthird"}},
+		{"tail", LogConfig{Strategy: TailStrategy, ContextBytes: 13}, 1, []string{"Only the end", "third"}},
+		{"map-reduce", LogConfig{Strategy: MapReduceStrategy, ContextBytes: 13, Concurrency: 2}, 3, []string{"segment 1 of 2 of the build logs, starting at line 1", "segment 2 of 2 of the build logs, starting at line 2", "Segment 2:\nsummary"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var prompts []string
+			m := modelFunc(func(_ context.Context, p string) (string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				prompts = append(prompts, p)
+				return "summary", nil
+			})
+			if _, err := DiagnoseLogs(context.Background(), m, target, logs, tc.config); err != nil {
+				t.Fatal(err)
+			}
+			if len(prompts) != tc.prompts {
+				t.Fatalf("got %d prompts, want %d", len(prompts), tc.prompts)
+			}
+			all := strings.Join(prompts, "\n")
+			for _, want := range tc.want {
+				if !strings.Contains(all, want) {
+					t.Errorf("prompts missing %q", want)
+				}
+			}
+			if tc.name == "tail" && strings.Contains(all, "first error") {
+				t.Error("tail prompt contains the start of the logs")
+			}
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	got := Compact("\x1b[32mok\x1b[0m\n10%\r50%\r100%\nretry\nretry\nretry\n\n\ndone")
+	want := "ok\n100%\nretry\n[previous line repeated 2 times]\n\n\ndone"
+	if got != want {
+		t.Errorf("Compact() = %q, want %q", got, want)
+	}
+}
+
+func TestPack(t *testing.T) {
+	var logLines []string
+	for i := 0; i < 100; i++ {
+		logLines = append(logLines, fmt.Sprintf("log line %02d", i))
+	}
+	sources := []Source{
+		{Name: "Logs", Text: strings.Join(logLines, "\n"), Priority: 1, Keep: KeepTail},
+		{Name: "Strategy", Lang: "yaml", Text: "build: npm pack", Priority: 2},
+		{Name: "Environment", Text: strings.Repeat("lengthy ", 100), Priority: 0, MinTokens: 50},
+	}
+	p, err := Pack(context.Background(), ApproximateCounter{}, 100, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Tokens() > 100 {
+		t.Errorf("Tokens() = %d, want at most 100", p.Tokens())
+	}
+	if diff := cmp.Diff([]string{"Environment"}, p.Omitted); diff != "" {
+		t.Errorf("Omitted mismatch (-want +got):\n%s", diff)
+	}
+	if len(p.Sections) != 2 || p.Sections[0].Name != "Logs" || p.Sections[1].Name != "Strategy" {
+		t.Fatalf("Sections = %+v, want Logs then Strategy", p.Sections)
+	}
+	if p.Sections[1].Truncated || !p.Sections[0].Truncated {
+		t.Errorf("want only Logs truncated")
+	}
+	logs := p.Sections[0].Text
+	if !strings.HasPrefix(logs, "[") || !strings.HasSuffix(logs, "log line 99") || strings.Contains(logs, "log line 00") {
+		t.Errorf("Logs = %q, want tail of logs", logs)
+	}
+	if got := p.String(); !strings.Contains(got, "Strategy:\n```yaml\nbuild: npm pack\n```") {
+		t.Errorf("String() = %q, want fenced strategy", got)
+	}
+}
+
+func TestSummarizeFailure(t *testing.T) {
+	target := rebuild.Target{Ecosystem: rebuild.NPM, Package: "pkg", Version: "1.0.0"}
+	for _, tc := range []struct {
+		name     string
+		response string
+		want     *FailureSummary
+	}{
+		{"listed", "**Category:** Test failure.\nSummary: a snapshot test fails.", &FailureSummary{Target: target, Category: "test failure", Summary: "a snapshot test fails."}},
+		{"unlisted", "Category: cosmic rays\nSummary: bits flipped.", &FailureSummary{Target: target, Category: "other", Summary: "bits flipped."}},
+		{"malformed", "The build failed.", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var prompt string
+			m := modelFunc(func(_ context.Context, p string) (string, error) {
+				prompt = p
+				return tc.response, nil
+			})
+			got, err := SummarizeFailure(context.Background(), m, target, "build failed", "FAIL snapshot", "log line")
+			if tc.want == nil {
+				if err == nil {
+					t.Errorf("SummarizeFailure() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("SummarizeFailure() mismatch (-want +got):\n%s", diff)
+			}
+			for _, want := range []string{"build failed", "FAIL snapshot", "log line"} {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterSummaries(t *testing.T) {
+	summaries := []FailureSummary{
+		{Category: "timeout", Summary: "a"},
+		{Category: "test failure", Summary: "b"},
+		{Category: "timeout", Summary: "c"},
+		{Category: "other", Summary: "d"},
+	}
+	var got []string
+	for _, c := range ClusterSummaries(summaries) {
+		got = append(got, fmt.Sprintf("%s:%d", c.Category, len(c.Summaries)))
+	}
+	if diff := cmp.Diff([]string{"timeout:2", "other:1", "test failure:1"}, got); diff != "" {
+		t.Errorf("ClusterSummaries() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	a := "Manifest-Version: 1.0\nCreated-By: 17.0.1\nBuild-Jdk: 17\na\nb\nc\nd\ne\nf\n"
+	b := "Manifest-Version: 1.0\nCreated-By: 11.0.2\nBuild-Jdk: 17\na\nb\nc\nd\ne\ng\n"
+	got := LineDiff(a, b, 1)
+	want := strings.Join([]string{
+		" Manifest-Version: 1.0", "-Created-By: 17.0.1", "+Created-By: 11.0.2", " Build-Jdk: 17",
+		"...",
+		" e", "-f", "+g",
+	}, "\n")
+	if got != want {
+		t.Errorf("LineDiff() = %q, want %q", got, want)
+	}
+	if got := LineDiff(a, a, 1); got != "" {
+		t.Errorf("LineDiff() of identical texts = %q, want empty", got)
+	}
+}
+
+func TestExplainDiff(t *testing.T) {
+	rebuilt := []byte("Manifest-Version: 1.0\nCreated-By: 17.0.1\n")
+	upstream := []byte("Manifest-Version: 1.0\nCreated-By: 11.0.2\n")
+	var conversation []Turn
+	m := converseFunc(func(_ context.Context, turns []Turn, tools []Tool) (*Turn, error) {
+		conversation = turns
+		args := `{"differences":[{"difference":"Created-By","cause":"tool version","explanation":"Different JDKs.","stabilizers":["zip-normalize-build-info"]},{"difference":"x","cause":"gremlins","explanation":"?"}]}`
+		if len(turns) > 1 {
+			args = strings.Replace(args, "gremlins", "unknown", 1)
+		}
+		return &Turn{Role: "model", Calls: []FunctionCall{{Name: "report_differences", Args: json.RawMessage(args)}}}, nil
+	})
+	got, err := ExplainDiff(context.Background(), m, "META-INF/MANIFEST.MF", rebuilt, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DiffExplanation{
+		Path:  "META-INF/MANIFEST.MF",
+		Class: stabilizers.Numeric,
+		Differences: []ExplainedDifference{
+			{Difference: "Created-By", Cause: "tool version", Explanation: "Different JDKs.", Stabilizers: []string{"zip-normalize-build-info"}},
+			{Difference: "x", Cause: "unknown", Explanation: "?"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ExplainDiff() mismatch (-want +got):\n%s", diff)
+	}
+	if len(conversation) != 3 {
+		t.Fatalf("ExplainDiff() took %d turns, want 3 after correcting the invalid cause", len(conversation))
+	}
+	if !strings.Contains(conversation[0].Text, "-Created-By: 17.0.1\n+Created-By: 11.0.2") {
+		t.Errorf("prompt missing diff: %s", conversation[0].Text)
+	}
+	if r := conversation[2].Results; len(r) != 1 || !strings.Contains(fmt.Sprint(r[0].Response["error"]), "gremlins") {
+		t.Errorf("invalid cause not reported to model: %v", r)
+	}
+	if _, err := ExplainDiff(context.Background(), m, "f", rebuilt, rebuilt); err == nil {
+		t.Error("ExplainDiff() of identical files succeeded, want error")
+	}
+}
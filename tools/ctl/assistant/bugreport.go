@@ -0,0 +1,144 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// BugReportEvidence is what is known of a rebuild which differed from upstream
+// due to nondeterminism in the package's build.
+type BugReportEvidence struct {
+	Target rebuild.Target
+	// Strategy is the YAML-encoded strategy used to rebuild the target.
+	Strategy string
+	// Dockerfile contains the commands which executed the rebuild.
+	Dockerfile string
+	// Environment describes the environment in which the rebuild executed, if known.
+	Environment string
+	// Diff is the diffoscope output comparing the rebuild to upstream.
+	Diff string
+	// Notes are the triager's observations, such as from annotations.
+	Notes []string
+}
+
+// maxDiffLines is the number of lines of diffoscope output provided as evidence.
+const maxDiffLines = 200
+
+// bugReportBudget is the number of tokens of evidence provided to the model.
+const bugReportBudget = 32 << 10
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// MinimalDiff reduces diffoscope output to roughly maxLines lines, keeping the
+// header of each differing file and dropping lines beyond each file's share.
+func MinimalDiff(diff string, maxLines int) string {
+	lines := strings.Split(strings.TrimRight(ansiEscape.ReplaceAllString(diff, ""), "\n"), "\n")
+	if len(lines) <= maxLines {
+		return strings.Join(lines, "\n")
+	}
+	// NOTE: diffoscope introduces each compared file with a line starting "├── ".
+	var sections [][]string
+	for _, line := range lines {
+		if len(sections) == 0 || strings.HasPrefix(line, "├── ") {
+			sections = append(sections, nil)
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+	share := max(maxLines/len(sections), 2)
+	var out []string
+	for i, s := range sections {
+		if len(out)+min(len(s), share) > maxLines {
+			out = append(out, fmt.Sprintf("[%d further sections omitted]", len(sections)-i))
+			break
+		}
+		if len(s) > share {
+			out = append(out, s[:share]...)
+			out = append(out, fmt.Sprintf("[%d lines omitted]", len(s)-share))
+		} else {
+			out = append(out, s...)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// BugReportPrompt returns the prompt requesting a bug report for the evidence,
+// packing as much of it as fits within the budget as counted by c.
+func BugReportPrompt(ctx context.Context, c TokenCounter, ev BugReportEvidence) (string, error) {
+	var b strings.Builder
+	b.WriteString(`You are helping the maintainers of OSS Rebuild report reproducibility issues to the maintainers of open source packages.
+A package artifact was rebuilt from source and the result differed from the artifact published to the registry.
+A human has confirmed that the difference is caused by nondeterminism in the package's own build, not by the rebuild.
+
+Draft a concise, courteous bug report suitable for filing on the package's source repository. It must:
+- Have a one-line title, then sections "Description", "Steps to reproduce", "Environment" and "Evidence".
+- Give the exact build commands from the provided Dockerfile in "Steps to reproduce".
+- Quote only the smallest excerpt of the diff that demonstrates the nondeterminism in "Evidence".
+- Suggest a likely cause and fix only if the evidence supports one, stated as a suggestion.
+- Not invent details absent from the evidence below; write "unknown" instead.
+Respond with the report in Markdown and nothing else.
+
+`)
+	fmt.Fprintf(&b, "Package: %s %s@%s\nArtifact: %s\n\n", ev.Target.Ecosystem, ev.Target.Package, ev.Target.Version, ev.Target.Artifact)
+	var notes strings.Builder
+	for _, n := range ev.Notes {
+		fmt.Fprintf(&notes, "- %s\n", n)
+	}
+	// NOTE: The commands and the diff are essential to the report while the
+	// environment is often lengthy and only occasionally relevant.
+	p, err := Pack(ctx, c, bugReportBudget, []Source{
+		{Name: "Rebuild strategy", Lang: "yaml", Text: ev.Strategy, Priority: 3},
+		{Name: "Dockerfile", Lang: "dockerfile", Text: ev.Dockerfile, Priority: 4},
+		{Name: "Environment", Lang: "json", Text: ev.Environment, Priority: 0, MinTokens: 100},
+		{Name: "Diff between rebuild and upstream (diffoscope, truncated)", Text: MinimalDiff(ev.Diff, maxDiffLines), Priority: 2, Keep: KeepHead, MinTokens: 100},
+		{Name: "Triage notes", Text: notes.String(), Priority: 1},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "packing evidence")
+	}
+	b.WriteString(p.String())
+	return b.String(), nil
+}
+
+// draftHeader precedes each draft so it is not mistaken for a reviewed report.
+const draftHeader = "<!-- DRAFT generated by a language model for %s. Review every detail before filing. -->\n\n"
+
+// DraftBugReport drafts a report of the nondeterminism evidenced by ev for filing upstream.
+func DraftBugReport(ctx context.Context, m Model, ev BugReportEvidence) (string, error) {
+	if strings.TrimSpace(ev.Diff) == "" {
+		return "", errors.New("no diff provided as evidence")
+	}
+	prompt, err := BugReportPrompt(ctx, counterFor(m), ev)
+	if err != nil {
+		return "", err
+	}
+	text, err := m.Generate(ctx, prompt)
+	if err != nil {
+		return "", errors.Wrap(err, "drafting bug report")
+	}
+	text = strings.TrimSpace(text)
+	// NOTE: Models often fence the report despite being asked not to.
+	if strings.HasPrefix(text, "```") && strings.HasSuffix(text, "```") {
+		_, text, _ = strings.Cut(text, "\n")
+		text = strings.TrimSpace(strings.TrimSuffix(text, "```"))
+	}
+	return fmt.Sprintf(draftHeader, ev.Target.Artifact) + text + "\n", nil
+}
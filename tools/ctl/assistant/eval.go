@@ -0,0 +1,271 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// EvalCase is a recorded failure whose cause is known, against which the
+// assistant's triage is evaluated.
+type EvalCase struct {
+	ID      string         `json:"id"`
+	Target  rebuild.Target `json:"target"`
+	Message string         `json:"message"`
+	Snippet string         `json:"snippet,omitempty"`
+	Logs    string         `json:"logs,omitempty"`
+	// Category is the member of FailureCategories describing the known cause.
+	Category string `json:"category"`
+	// Keywords are the terms, matched case-insensitively, that a correct
+	// summary of the cause mentions.
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// ReadCorpus reads the JSON lines of a corpus of EvalCases.
+func ReadCorpus(r io.Reader) ([]EvalCase, error) {
+	var cases []EvalCase
+	seen := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16<<20)
+	for s.Scan() {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var c EvalCase
+		if err := json.Unmarshal(s.Bytes(), &c); err != nil {
+			return nil, errors.Wrapf(err, "parsing case %d", len(cases)+1)
+		}
+		switch {
+		case c.ID == "":
+			return nil, errors.Errorf("case %d has no id", len(cases)+1)
+		case seen[c.ID]:
+			return nil, errors.Errorf("duplicate case %s", c.ID)
+		case !slices.Contains(FailureCategories, c.Category):
+			return nil, errors.Errorf("case %s has unknown category %q", c.ID, c.Category)
+		}
+		seen[c.ID] = true
+		cases = append(cases, c)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading corpus")
+	}
+	return cases, nil
+}
+
+// categoryWeight is the share of a case's score awarded for the category
+// when the case also has keywords.
+const categoryWeight = 0.6
+
+// Grade is the score of the assistant's triage of an EvalCase.
+type Grade struct {
+	ID       string `json:"id"`
+	Category string `json:"category,omitempty"`
+	Summary  string `json:"summary,omitempty"`
+	// Error is set if the triage failed, in which case Score is zero.
+	Error           string  `json:"error,omitempty"`
+	CategoryCorrect bool    `json:"category_correct"`
+	KeywordRecall   float64 `json:"keyword_recall"`
+	Score           float64 `json:"score"`
+}
+
+// GradeSummary scores a summary of c on a scale of 0 to 1, from whether it
+// identifies the known category and the share of c.Keywords it mentions.
+func GradeSummary(c EvalCase, s *FailureSummary) Grade {
+	g := Grade{ID: c.ID, Category: s.Category, Summary: s.Summary, CategoryCorrect: s.Category == c.Category}
+	var category float64
+	if g.CategoryCorrect {
+		category = 1
+	}
+	if len(c.Keywords) == 0 {
+		g.KeywordRecall = 1
+		g.Score = category
+		return g
+	}
+	summary := strings.ToLower(s.Summary)
+	var found int
+	for _, k := range c.Keywords {
+		if strings.Contains(summary, strings.ToLower(k)) {
+			found++
+		}
+	}
+	g.KeywordRecall = float64(found) / float64(len(c.Keywords))
+	g.Score = categoryWeight*category + (1-categoryWeight)*g.KeywordRecall
+	return g
+}
+
+// EvalReport is the result of evaluating a model against a corpus.
+type EvalReport struct {
+	// Label identifies the model or prompt evaluated.
+	Label  string  `json:"label,omitempty"`
+	Grades []Grade `json:"grades"`
+	// Score is the mean score of Grades.
+	Score float64 `json:"score"`
+	// CategoryAccuracy is the share of Grades with the correct category.
+	CategoryAccuracy float64 `json:"category_accuracy"`
+	Errors           int     `json:"errors"`
+}
+
+// Evaluate triages each case of the corpus with m and grades the results.
+// A case whose triage fails scores zero rather than aborting the evaluation.
+func Evaluate(ctx context.Context, m Model, label string, corpus []EvalCase) (*EvalReport, error) {
+	r := EvalReport{Label: label}
+	var correct int
+	for _, c := range corpus {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var g Grade
+		if s, err := SummarizeFailure(ctx, m, c.Target, c.Message, c.Snippet, c.Logs); err != nil {
+			g = Grade{ID: c.ID, Error: err.Error()}
+			r.Errors++
+		} else {
+			g = GradeSummary(c, s)
+		}
+		if g.CategoryCorrect {
+			correct++
+		}
+		r.Score += g.Score
+		r.Grades = append(r.Grades, g)
+	}
+	if len(corpus) > 0 {
+		r.Score /= float64(len(corpus))
+		r.CategoryAccuracy = float64(correct) / float64(len(corpus))
+	}
+	return &r, nil
+}
+
+// ScoreChange is the change in a case's score between two reports.
+type ScoreChange struct {
+	ID        string  `json:"id"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+}
+
+// Comparison is the difference between a baseline and a candidate EvalReport.
+type Comparison struct {
+	// Delta is the change in the mean score over the cases of both reports.
+	Delta        float64       `json:"delta"`
+	Regressions  []ScoreChange `json:"regressions,omitempty"`
+	Improvements []ScoreChange `json:"improvements,omitempty"`
+	// Added and Removed are the cases present in only the candidate or baseline.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Compare scores the candidate against the baseline, case by case.
+// Only cases graded in both reports contribute to Delta so that changes to the
+// corpus are not mistaken for changes in quality.
+func Compare(baseline, candidate *EvalReport) Comparison {
+	var c Comparison
+	base := make(map[string]float64)
+	for _, g := range baseline.Grades {
+		base[g.ID] = g.Score
+	}
+	var n int
+	for _, g := range candidate.Grades {
+		b, ok := base[g.ID]
+		if !ok {
+			c.Added = append(c.Added, g.ID)
+			continue
+		}
+		delete(base, g.ID)
+		n++
+		c.Delta += g.Score - b
+		change := ScoreChange{ID: g.ID, Baseline: b, Candidate: g.Score}
+		switch {
+		case g.Score < b:
+			c.Regressions = append(c.Regressions, change)
+		case g.Score > b:
+			c.Improvements = append(c.Improvements, change)
+		}
+	}
+	for id := range base {
+		c.Removed = append(c.Removed, id)
+	}
+	slices.Sort(c.Removed)
+	if n > 0 {
+		c.Delta /= float64(n)
+	}
+	return c
+}
+
+// Exchange is a prompt and the response a model generated for it.
+type Exchange struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+}
+
+// Session is a record of a model's exchanges, used to replay an evaluation
+// without access to the model. It is safe for concurrent use.
+type Session struct {
+	mu        sync.Mutex
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// RecordingModel is a Model that records the exchanges of the Model it wraps.
+type RecordingModel struct {
+	Model
+	Session *Session
+}
+
+// Generate implements Model.
+func (m *RecordingModel) Generate(ctx context.Context, prompt string) (string, error) {
+	text, err := m.Model.Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	m.Session.mu.Lock()
+	defer m.Session.mu.Unlock()
+	m.Session.Exchanges = append(m.Session.Exchanges, Exchange{Prompt: prompt, Response: text})
+	return text, nil
+}
+
+// ErrNotRecorded is returned by a ReplayModel for a prompt absent from its session.
+var ErrNotRecorded = errors.New("prompt not recorded")
+
+// ReplayModel is a Model that responds with the responses of a recorded Session.
+//
+// Since responses are matched by prompt, a change to a prompt requires the
+// session to be recorded again.
+type ReplayModel struct {
+	responses map[string]string
+}
+
+// NewReplayModel creates a ReplayModel serving the exchanges of s.
+func NewReplayModel(s *Session) *ReplayModel {
+	m := ReplayModel{responses: make(map[string]string)}
+	for _, e := range s.Exchanges {
+		m.responses[e.Prompt] = e.Response
+	}
+	return &m
+}
+
+// Generate implements Model.
+func (m *ReplayModel) Generate(ctx context.Context, prompt string) (string, error) {
+	text, ok := m.responses[prompt]
+	if !ok {
+		return "", ErrNotRecorded
+	}
+	return text, nil
+}
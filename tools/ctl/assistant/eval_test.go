@@ -0,0 +1,160 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+)
+
+func TestReadCorpus(t *testing.T) {
+	f, err := os.Open("testdata/eval_corpus.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cases, err := ReadCorpus(f)
+	if err != nil {
+		t.Fatalf("ReadCorpus() returned error: %v", err)
+	}
+	if len(cases) != 5 {
+		t.Errorf("ReadCorpus() returned %d cases, want 5", len(cases))
+	}
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{"no id", `{"category":"timeout"}`},
+		{"duplicate", `{"id":"a","category":"timeout"}` + "\n" + `{"id":"a","category":"timeout"}`},
+		{"unknown category", `{"id":"a","category":"gremlins"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ReadCorpus(strings.NewReader(tc.input)); err == nil {
+				t.Error("ReadCorpus() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestGradeSummary(t *testing.T) {
+	c := EvalCase{ID: "a", Category: "missing dependency", Keywords: []string{"Python", "node-gyp"}}
+	for _, tc := range []struct {
+		name    string
+		summary FailureSummary
+		want    float64
+	}{
+		{"exact", FailureSummary{Category: "missing dependency", Summary: "node-gyp cannot find python."}, 1},
+		{"category only", FailureSummary{Category: "missing dependency", Summary: "a tool is missing."}, categoryWeight},
+		{"partial keywords", FailureSummary{Category: "missing dependency", Summary: "python is not installed."}, categoryWeight + (1-categoryWeight)/2},
+		{"wrong category", FailureSummary{Category: "compilation error", Summary: "node-gyp cannot find python."}, 1 - categoryWeight},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GradeSummary(c, &tc.summary).Score; got != tc.want {
+				t.Errorf("Score = %v, want %v", got, tc.want)
+			}
+		})
+	}
+	noKeywords := EvalCase{ID: "b", Category: "timeout"}
+	if got := GradeSummary(noKeywords, &FailureSummary{Category: "timeout"}).Score; got != 1 {
+		t.Errorf("Score without keywords = %v, want 1", got)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	corpus := []EvalCase{
+		{ID: "right", Message: "gyp ERR! find Python", Category: "missing dependency", Keywords: []string{"python"}},
+		{ID: "wrong", Message: "npm ERR! EBADENGINE", Category: "unsupported toolchain"},
+		{ID: "broken", Message: "unanswerable", Category: "timeout"},
+	}
+	m := modelFunc(func(_ context.Context, p string) (string, error) {
+		switch {
+		case strings.Contains(p, "gyp ERR!"):
+			return "Category: missing dependency\nSummary: python is not installed.", nil
+		case strings.Contains(p, "EBADENGINE"):
+			return "Category: dependency resolution\nSummary: npm failed.", nil
+		default:
+			return "", errors.New("quota exhausted")
+		}
+	})
+	got, err := Evaluate(context.Background(), m, "test", corpus)
+	if err != nil {
+		t.Fatalf("Evaluate() returned error: %v", err)
+	}
+	want := &EvalReport{
+		Label: "test",
+		Grades: []Grade{
+			{ID: "right", Category: "missing dependency", Summary: "python is not installed.", CategoryCorrect: true, KeywordRecall: 1, Score: 1},
+			{ID: "wrong", Category: "dependency resolution", Summary: "npm failed.", KeywordRecall: 1},
+			{ID: "broken", Error: "summarizing failure: quota exhausted"},
+		},
+		Score:            1.0 / 3,
+		CategoryAccuracy: 1.0 / 3,
+		Errors:           1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Evaluate() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := &EvalReport{Grades: []Grade{{ID: "a", Score: 1}, {ID: "b", Score: 0.5}, {ID: "c", Score: 0.6}, {ID: "gone", Score: 1}}}
+	candidate := &EvalReport{Grades: []Grade{{ID: "a", Score: 0.4}, {ID: "b", Score: 1}, {ID: "c", Score: 0.6}, {ID: "new", Score: 0}}}
+	want := Comparison{
+		Delta:        (-0.6 + 0.5) / 3,
+		Regressions:  []ScoreChange{{ID: "a", Baseline: 1, Candidate: 0.4}},
+		Improvements: []ScoreChange{{ID: "b", Baseline: 0.5, Candidate: 1}},
+		Added:        []string{"new"},
+		Removed:      []string{"gone"},
+	}
+	if diff := cmp.Diff(want, Compare(baseline, candidate), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Compare() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	var calls int
+	live := modelFunc(func(_ context.Context, p string) (string, error) {
+		calls++
+		return "Category: timeout\nSummary: the build timed out.", nil
+	})
+	corpus := []EvalCase{{ID: "a", Message: "deadline exceeded", Category: "timeout", Keywords: []string{"timed out"}}}
+	var s Session
+	recorded, err := Evaluate(context.Background(), &RecordingModel{Model: live, Session: &s}, "", corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Exchanges) != 1 {
+		t.Fatalf("recorded %d exchanges, want 1", len(s.Exchanges))
+	}
+	replayed, err := Evaluate(context.Background(), NewReplayModel(&s), "", corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("live model called %d times, want 1", calls)
+	}
+	if diff := cmp.Diff(recorded, replayed); diff != "" {
+		t.Errorf("replayed report mismatch (-recorded +replayed):\n%s", diff)
+	}
+	if _, err := NewReplayModel(&s).Generate(context.Background(), "another prompt"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Generate() of unrecorded prompt returned %v, want ErrNotRecorded", err)
+	}
+}
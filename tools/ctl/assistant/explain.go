@@ -0,0 +1,220 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/tools/ctl/stabilizers"
+	"github.com/pkg/errors"
+)
+
+// DiffCause is a known cause of differences between a rebuilt and upstream file.
+type DiffCause struct {
+	Name        string
+	Description string
+}
+
+// DiffCauses are the causes to which differences are attributed.
+var DiffCauses = []DiffCause{
+	{"tool version", "the file records or is shaped by the version of a build tool, e.g. Created-By or Build-Jdk"},
+	{"signing", "signatures, digests, or signing metadata present in only one of the files"},
+	{"ordering", "the same entries or lines in a different order"},
+	{"timestamp", "dates, times, or build numbers recorded at build time"},
+	{"build environment", "the user, host, paths, or locale of the build machine"},
+	{"line endings", "CRLF versus LF line endings"},
+	{"encoding", "character encoding or Unicode normalization"},
+	{"source content", "different source was built, e.g. an incorrect commit or a modified file"},
+	{"unknown", "none of the above"},
+}
+
+// explainableStabilizers are the stabilizers a difference may be attributed to.
+var explainableStabilizers = append(slices.Clone(archive.Stabilizers), archive.LineEndingStabilizer)
+
+// ExplainedDifference is a single difference between the files and its likely cause.
+type ExplainedDifference struct {
+	// Difference quotes the differing lines.
+	Difference string `json:"difference"`
+	// Cause is the name of one of DiffCauses.
+	Cause       string `json:"cause"`
+	Explanation string `json:"explanation"`
+	// Stabilizers are those which remove the difference, if any.
+	Stabilizers []string `json:"stabilizers,omitempty"`
+}
+
+// DiffExplanation explains the differences between a rebuilt and upstream file.
+type DiffExplanation struct {
+	Path string `json:"path"`
+	// Class is the kind of content which differs, as determined without the model.
+	Class       stabilizers.RegionClass `json:"class"`
+	Differences []ExplainedDifference   `json:"differences"`
+}
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 2
+
+// maxExplainLines is the length of the longest file explained, bounding the cost of LineDiff.
+const maxExplainLines = 5000
+
+// LineDiff returns a line-based diff of the two texts, showing only the
+// changed lines and the n unchanged lines around them.
+func LineDiff(a, b string, n int) string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	type op struct {
+		prefix byte
+		line   string
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, op{' ', x[i]})
+			i, j = i+1, j+1
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', x[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', y[j]})
+			j++
+		}
+	}
+	var out []string
+	last := -1
+	for k, o := range ops {
+		near := false
+		for d := max(k-n, 0); d <= min(k+n, len(ops)-1); d++ {
+			if ops[d].prefix != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if last != -1 && k != last+1 {
+			out = append(out, "...")
+		}
+		out = append(out, string(o.prefix)+o.line)
+		last = k
+	}
+	return strings.Join(out, "\n")
+}
+
+// explainPrompt returns the prompt requesting an explanation of the diff of the file at path.
+func explainPrompt(path string, class stabilizers.RegionClass, diff string) string {
+	var b strings.Builder
+	b.WriteString(`You are helping someone unfamiliar with reproducible builds review why a file in a package rebuilt from source differs from the one published upstream.
+
+For each distinct difference in the diff below, in order, attribute it to one of these causes:
+`)
+	for _, c := range DiffCauses {
+		fmt.Fprintf(&b, "- %s: %s\n", c.Name, c.Description)
+	}
+	fmt.Fprintf(&b, "\nName the stabilizers, from only the following, which would remove the difference if any: %s.\n", strings.Join(explainableStabilizers, ", "))
+	b.WriteString("Explain each in one or two plain sentences a non-expert could follow.\n\n")
+	fmt.Fprintf(&b, "File: %s\n", path)
+	fmt.Fprintf(&b, "Kind of difference, as classified automatically: %s\n\n", class)
+	fmt.Fprintf(&b, "Diff (- rebuild, + upstream):\n```\n%s\n```\n", diff)
+	return b.String()
+}
+
+// explanationSchema describes the arguments with which the model reports the differences.
+func explanationSchema() *Schema {
+	var causes []string
+	for _, c := range DiffCauses {
+		causes = append(causes, c.Name)
+	}
+	return &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"differences": {
+				Type: TypeArray,
+				Items: &Schema{
+					Type: TypeObject,
+					Properties: map[string]*Schema{
+						"difference":  {Type: TypeString, Description: "The differing lines, quoted from the diff."},
+						"cause":       {Type: TypeString, Enum: causes},
+						"explanation": {Type: TypeString},
+						"stabilizers": {Type: TypeArray, Items: &Schema{Type: TypeString, Enum: explainableStabilizers}},
+					},
+					Required: []string{"difference", "cause", "explanation"},
+				},
+			},
+		},
+		Required: []string{"differences"},
+	}
+}
+
+// ExplainDiff asks the model to attribute each difference between the
+// rebuilt and upstream versions of the file at path to a known cause.
+func ExplainDiff(ctx context.Context, m ToolModel, path string, rebuilt, upstream []byte) (*DiffExplanation, error) {
+	e := DiffExplanation{Path: path, Class: stabilizers.Classify(rebuilt, upstream)}
+	if e.Class == stabilizers.Binary {
+		return nil, errors.New("cannot explain differences in binary content")
+	}
+	if bytes.Count(rebuilt, []byte("\n")) > maxExplainLines || bytes.Count(upstream, []byte("\n")) > maxExplainLines {
+		return nil, errors.Errorf("files exceed %d lines", maxExplainLines)
+	}
+	diff := LineDiff(string(rebuilt), string(upstream), diffContext)
+	if diff == "" {
+		return nil, errors.New("files do not differ")
+	}
+	// NOTE: The schema constrains causes and stabilizers to those known so the
+	// explanation never refers the reader to something which doesn't exist.
+	report, err := Extract[struct {
+		Differences []ExplainedDifference `json:"differences"`
+	}](ctx, m, explainPrompt(path, e.Class, diff), "report_differences", "Reports the explained differences, in order.", explanationSchema())
+	if err != nil {
+		return nil, errors.Wrap(err, "explaining diff")
+	}
+	e.Differences = report.Differences
+	return &e, nil
+}
+
+// FormatExplanation writes a human-readable rendering of the explanation.
+func FormatExplanation(w io.Writer, e *DiffExplanation) {
+	fmt.Fprintf(w, "%s (%s differences)\n", e.Path, e.Class)
+	for i, d := range e.Differences {
+		fmt.Fprintf(w, "\n%d. [%s] %s\n", i+1, d.Cause, d.Explanation)
+		for _, line := range strings.Split(strings.TrimSpace(d.Difference), "\n") {
+			fmt.Fprintf(w, "     %s\n", line)
+		}
+		if len(d.Stabilizers) > 0 {
+			fmt.Fprintf(w, "   Stabilizers: %s\n", strings.Join(d.Stabilizers, ", "))
+		}
+	}
+}
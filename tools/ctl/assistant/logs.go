@@ -0,0 +1,180 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// LogStrategy is how logs exceeding a model's context are provided to it.
+type LogStrategy string
+
+const (
+	// TailStrategy provides only the end of the logs.
+	TailStrategy LogStrategy = "tail"
+	// MapReduceStrategy summarizes each segment of the logs and diagnoses the failure from the summaries.
+	MapReduceStrategy LogStrategy = "map-reduce"
+)
+
+const (
+	// DefaultContextBytes is the log content provided in a single request to most models.
+	DefaultContextBytes = 100 << 10
+	// LongContextBytes is the log content provided in a single request to
+	// long-context models, such as gemini-1.5-pro, leaving room for the prompt
+	// within their context window of roughly 1M tokens.
+	LongContextBytes = 3 << 20
+)
+
+// LogConfig configures how build logs are provided to the model.
+type LogConfig struct {
+	Strategy LogStrategy
+	// ContextBytes is the most log content provided in a single request.
+	ContextBytes int
+	// Concurrency is the number of segments summarized concurrently by MapReduceStrategy.
+	Concurrency int
+}
+
+// DefaultLogConfig provides the logs in full to long-context models and
+// summarizes the segments of those exceeding even their context.
+var DefaultLogConfig = LogConfig{Strategy: MapReduceStrategy, ContextBytes: LongContextBytes, Concurrency: 4}
+
+// Validate returns an error if the config is unusable.
+func (c LogConfig) Validate() error {
+	switch c.Strategy {
+	case TailStrategy, MapReduceStrategy:
+	default:
+		return errors.Errorf("unknown log strategy %q", c.Strategy)
+	}
+	if c.ContextBytes <= 0 {
+		return errors.New("context bytes must be positive")
+	}
+	return nil
+}
+
+// Segment is a contiguous range of lines of a log.
+type Segment struct {
+	// Start is the zero-based index of the first line of the segment.
+	Start int
+	Text  string
+}
+
+// SplitLog divides logs into segments of at most size bytes, breaking only
+// between lines unless a single line exceeds size.
+func SplitLog(logs string, size int) []Segment {
+	var segs []Segment
+	var cur strings.Builder
+	start := 0
+	flush := func(next int) {
+		if cur.Len() > 0 {
+			segs = append(segs, Segment{Start: start, Text: cur.String()})
+			cur.Reset()
+		}
+		start = next
+	}
+	lines := strings.SplitAfter(logs, "\n")
+	for i, line := range lines {
+		if cur.Len()+len(line) > size {
+			flush(i)
+		}
+		for len(line) > size {
+			segs = append(segs, Segment{Start: i, Text: line[:size]})
+			line = line[size:]
+		}
+		cur.WriteString(line)
+	}
+	flush(len(lines))
+	return segs
+}
+
+const debugPreamble = `You are helping debug a failed attempt to rebuild an open source package from source.
+`
+
+func diagnosePrompt(t rebuild.Target, logs string, truncated bool) string {
+	var b strings.Builder
+	b.WriteString(debugPreamble)
+	fmt.Fprintf(&b, "Package: %s %s@%s\n\n", t.Ecosystem, t.Package, t.Version)
+	b.WriteString("Identify the root cause of the failure in the build logs below, citing the relevant log lines, and suggest how the build could be fixed. Be concise.\n")
+	if truncated {
+		b.WriteString("Only the end of the logs is provided.\n")
+	}
+	fmt.Fprintf(&b, "\nLogs:\n```\n%s\n```\n", logs)
+	return b.String()
+}
+
+func summarizePrompt(t rebuild.Target, seg Segment, n, total int) string {
+	var b strings.Builder
+	b.WriteString(debugPreamble)
+	fmt.Fprintf(&b, "Package: %s %s@%s\n\n", t.Ecosystem, t.Package, t.Version)
+	fmt.Fprintf(&b, "Below is segment %d of %d of the build logs, starting at line %d. ", n, total, seg.Start+1)
+	b.WriteString("List every error, warning, or unexpected event in it which could explain or foreshadow a build failure, quoting each with its line number. If there are none, respond with \"nothing notable\". Be concise.\n")
+	fmt.Fprintf(&b, "\nLogs:\n```\n%s\n```\n", seg.Text)
+	return b.String()
+}
+
+func reducePrompt(t rebuild.Target, summaries []string) string {
+	var b strings.Builder
+	b.WriteString(debugPreamble)
+	fmt.Fprintf(&b, "Package: %s %s@%s\n\n", t.Ecosystem, t.Package, t.Version)
+	b.WriteString("The build logs were too large to provide in full so each segment was summarized, in order, below. Failures early in the logs often cause later ones. ")
+	b.WriteString("Identify the root cause of the failure, citing the relevant log lines, and suggest how the build could be fixed. Be concise.\n\n")
+	for i, s := range summaries {
+		fmt.Fprintf(&b, "Segment %d:\n%s\n\n", i+1, strings.TrimSpace(s))
+	}
+	return b.String()
+}
+
+// DiagnoseLogs asks the model for the root cause of the failure recorded in a rebuild's logs.
+func DiagnoseLogs(ctx context.Context, m Model, t rebuild.Target, logs string, c LogConfig) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	if len(logs) <= c.ContextBytes {
+		return m.Generate(ctx, diagnosePrompt(t, logs, false))
+	}
+	if c.Strategy == TailStrategy {
+		tail := logs[len(logs)-c.ContextBytes:]
+		if _, rest, found := strings.Cut(tail, "\n"); found {
+			tail = rest
+		}
+		return m.Generate(ctx, diagnosePrompt(t, tail, true))
+	}
+	segs := SplitLog(logs, c.ContextBytes)
+	summaries := make([]string, len(segs))
+	errs := make([]error, len(segs))
+	sem := make(chan struct{}, max(c.Concurrency, 1))
+	done := make(chan struct{})
+	for i, seg := range segs {
+		go func(i int, seg Segment) {
+			sem <- struct{}{}
+			defer func() { <-sem; done <- struct{}{} }()
+			summaries[i], errs[i] = m.Generate(ctx, summarizePrompt(t, seg, i+1, len(segs)))
+		}(i, seg)
+	}
+	for range segs {
+		<-done
+	}
+	for i, err := range errs {
+		if err != nil {
+			return "", errors.Wrapf(err, "summarizing segment %d", i+1)
+		}
+	}
+	// NOTE: The summaries are assumed to fit within a single request.
+	return m.Generate(ctx, reducePrompt(t, summaries))
+}
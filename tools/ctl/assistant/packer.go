@@ -0,0 +1,214 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TokenCounter counts the tokens of text as seen by a model.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, text string) (int, error)
+}
+
+// ApproximateCounter estimates four bytes per token, typical of English text
+// and code, for models which do not count their own tokens.
+type ApproximateCounter struct{}
+
+var _ TokenCounter = ApproximateCounter{}
+
+// CountTokens returns the estimated number of tokens in text.
+func (ApproximateCounter) CountTokens(_ context.Context, text string) (int, error) {
+	return (len(text) + 3) / 4, nil
+}
+
+// counterFor returns the model's own TokenCounter, if it has one.
+func counterFor(m Model) TokenCounter {
+	if c, ok := m.(TokenCounter); ok {
+		return c
+	}
+	return ApproximateCounter{}
+}
+
+// Keep is the end of a Source retained when it is truncated to fit.
+type Keep int
+
+const (
+	// KeepHead retains the start of the source, as for diffs.
+	KeepHead Keep = iota
+	// KeepTail retains the end of the source, as for logs.
+	KeepTail
+)
+
+// Source is material which may be provided to a model as context.
+type Source struct {
+	Name string
+	// Lang, if provided, is the language with which the text is fenced.
+	Lang string
+	Text string
+	// Priority orders the sources when packing, highest first.
+	Priority int
+	Keep     Keep
+	// MinTokens is the least worth providing of the source.
+	// Sources which would be truncated below it are omitted.
+	MinTokens int
+}
+
+func (s Source) section(text string) string {
+	return fmt.Sprintf("%s:\n```%s\n%s\n```\n\n", s.Name, s.Lang, text)
+}
+
+// Packed is a Source as provided to the model.
+type Packed struct {
+	Source
+	// Tokens is the size of the source's section of the context.
+	Tokens    int
+	Truncated bool
+}
+
+// Packing is the context assembled from a set of sources.
+type Packing struct {
+	// Sections are the sources provided, in their original order.
+	Sections []Packed
+	// Omitted are the names of the sources which did not fit.
+	Omitted []string
+}
+
+// Tokens returns the size of the packed context.
+func (p Packing) Tokens() int {
+	var n int
+	for _, s := range p.Sections {
+		n += s.Tokens
+	}
+	return n
+}
+
+// String renders the packed context for inclusion in a prompt.
+func (p Packing) String() string {
+	var b strings.Builder
+	for _, s := range p.Sections {
+		b.WriteString(s.section(s.Text))
+	}
+	return b.String()
+}
+
+// Compact removes content from text which is meaningless to a model:
+// terminal escapes, progress output overwritten by carriage returns, and
+// runs of repeated lines.
+func Compact(text string) string {
+	text = ansiEscape.ReplaceAllString(text, "")
+	var out []string
+	var repeats int
+	flush := func() {
+		if repeats > 0 {
+			out = append(out, fmt.Sprintf("[previous line repeated %d times]", repeats))
+			repeats = 0
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if i := strings.LastIndex(line, "\r"); i != -1 {
+			line = line[i+1:]
+		}
+		if len(out) > 0 && line == out[len(out)-1] && line != "" {
+			repeats++
+			continue
+		}
+		flush()
+		out = append(out, line)
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// Pack assembles as much of the sources as fits within budget tokens.
+// Sources are compacted and then added in order of priority, each truncated
+// to the remaining budget if it does not fit in full.
+func Pack(ctx context.Context, c TokenCounter, budget int, sources []Source) (*Packing, error) {
+	order := make([]int, len(sources))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(sources[b].Priority, sources[a].Priority) })
+	packed := make([]*Packed, len(sources))
+	var p Packing
+	remaining := budget
+	for _, i := range order {
+		s := sources[i]
+		s.Text = strings.TrimSpace(Compact(s.Text))
+		if s.Text == "" {
+			continue
+		}
+		n, err := c.CountTokens(ctx, s.section(s.Text))
+		if err != nil {
+			return nil, errors.Wrapf(err, "counting tokens of %s", s.Name)
+		}
+		truncated := n > remaining
+		if truncated {
+			if s.Text, n, err = truncate(ctx, c, s, remaining); err != nil {
+				return nil, err
+			}
+		}
+		if s.Text == "" || (truncated && n < s.MinTokens) {
+			p.Omitted = append(p.Omitted, s.Name)
+			continue
+		}
+		packed[i] = &Packed{Source: s, Tokens: n, Truncated: truncated}
+		remaining -= n
+	}
+	for _, s := range packed {
+		if s != nil {
+			p.Sections = append(p.Sections, *s)
+		}
+	}
+	return &p, nil
+}
+
+// truncate returns the most lines of the source whose section fits within
+// budget tokens, and the size of that section.
+func truncate(ctx context.Context, c TokenCounter, s Source, budget int) (string, int, error) {
+	lines := strings.Split(s.Text, "\n")
+	kept := func(k int) string {
+		if s.Keep == KeepTail {
+			return fmt.Sprintf("[%d earlier lines omitted]\n", len(lines)-k) + strings.Join(lines[len(lines)-k:], "\n")
+		}
+		return strings.Join(lines[:k], "\n") + fmt.Sprintf("\n[%d further lines omitted]", len(lines)-k)
+	}
+	// NOTE: The number of lines kept is binary searched so that the calls to c
+	// grow only logarithmically with the length of the source.
+	lo, hi, size := 0, len(lines)-1, 0
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		n, err := c.CountTokens(ctx, s.section(kept(mid)))
+		if err != nil {
+			return "", 0, errors.Wrapf(err, "counting tokens of %s", s.Name)
+		}
+		if n <= budget {
+			lo, size = mid, n
+		} else {
+			hi = mid - 1
+		}
+	}
+	if lo == 0 {
+		return "", 0, nil
+	}
+	return kept(lo), size, nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrContextOverflow indicates the prompt exceeded the model's context.
+	ErrContextOverflow = errors.New("prompt exceeds model context")
+	// ErrQuota indicates the model's quota was exhausted.
+	ErrQuota = errors.New("model quota exhausted")
+	// ErrLowConfidence indicates the model's response was empty or too uncertain to use.
+	ErrLowConfidence = errors.New("low confidence response")
+)
+
+// shouldFallBack returns whether a failure of one model may be overcome by another.
+func shouldFallBack(err error) bool {
+	return errors.Is(err, ErrContextOverflow) || errors.Is(err, ErrQuota) || errors.Is(err, ErrLowConfidence)
+}
+
+// Fallback is a Model which generates with each of its models in turn,
+// falling back to the next on context overflow, exhausted quota, or a
+// low-confidence response, e.g. from a fast model to a stronger one.
+type Fallback []Model
+
+var _ ToolModel = Fallback{}
+var _ TokenCounter = Fallback{}
+
+// Generate returns the text generated by the first model to succeed.
+func (f Fallback) Generate(ctx context.Context, prompt string) (string, error) {
+	if len(f) == 0 {
+		return "", errors.New("no models configured")
+	}
+	var err error
+	for _, m := range f {
+		var text string
+		if text, err = m.Generate(ctx, prompt); err == nil {
+			return text, nil
+		} else if !shouldFallBack(err) {
+			return "", err
+		}
+	}
+	return "", errors.Wrapf(err, "all %d models failed", len(f))
+}
+
+// Converse returns the turn of the first model to succeed.
+// Models which cannot call tools are skipped.
+func (f Fallback) Converse(ctx context.Context, turns []Turn, tools []Tool) (*Turn, error) {
+	err := errors.New("no models support tools")
+	var n int
+	for _, m := range f {
+		tm, ok := m.(ToolModel)
+		if !ok {
+			continue
+		}
+		n++
+		var turn *Turn
+		if turn, err = tm.Converse(ctx, turns, tools); err == nil {
+			return turn, nil
+		} else if !shouldFallBack(err) {
+			return nil, err
+		}
+	}
+	if n == 0 {
+		return nil, err
+	}
+	return nil, errors.Wrapf(err, "all %d models failed", n)
+}
+
+// CountTokens counts tokens as the first model does, since prompts are sized for it.
+func (f Fallback) CountTokens(ctx context.Context, text string) (int, error) {
+	if len(f) == 0 {
+		return ApproximateCounter{}.CountTokens(ctx, text)
+	}
+	return counterFor(f[0]).CountTokens(ctx, text)
+}
+
+// Task is a use of the assistant for which a model may be configured.
+type Task string
+
+// The tasks performed by the assistant.
+const (
+	BugReportTask    Task = "bug-report"
+	DiagnoseLogsTask Task = "diagnose-logs"
+	TriageTask       Task = "triage"
+	ExplainDiffTask  Task = "explain-diff"
+)
+
+// Tasks are the tasks for which models may be configured.
+var Tasks = []Task{BugReportTask, DiagnoseLogsTask, TriageTask, ExplainDiffTask}
+
+// TaskNames returns the names of Tasks.
+func TaskNames() []string {
+	var names []string
+	for _, t := range Tasks {
+		names = append(names, string(t))
+	}
+	return names
+}
+
+// ModelConfig names the models used for each task, each an ordered fallback chain.
+type ModelConfig struct {
+	Default   []string
+	Overrides map[Task][]string
+}
+
+// ParseModelConfig parses the default chain of comma-separated model names
+// and overrides of the form "task=model,model;task=model".
+func ParseModelConfig(models, overrides string) (*ModelConfig, error) {
+	c := ModelConfig{Default: splitModels(models), Overrides: make(map[Task][]string)}
+	if len(c.Default) == 0 {
+		return nil, errors.New("no models provided")
+	}
+	for _, o := range strings.Split(overrides, ";") {
+		if strings.TrimSpace(o) == "" {
+			continue
+		}
+		task, chain, found := strings.Cut(o, "=")
+		t := Task(strings.TrimSpace(task))
+		if !found || len(splitModels(chain)) == 0 {
+			return nil, errors.Errorf("malformed override %q, want task=model[,model]", o)
+		}
+		if !slices.Contains(Tasks, t) {
+			return nil, errors.Errorf("unknown task %q in override", t)
+		}
+		c.Overrides[t] = splitModels(chain)
+	}
+	return &c, nil
+}
+
+func splitModels(s string) []string {
+	var out []string
+	for _, m := range strings.Split(s, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Chain returns the names of the models used for task.
+func (c ModelConfig) Chain(task Task) []string {
+	if chain, ok := c.Overrides[task]; ok {
+		return chain
+	}
+	return c.Default
+}
+
+// Selector provides the Model configured for each task.
+type Selector struct {
+	config ModelConfig
+	models map[string]Model
+}
+
+// NewSelector creates a Selector constructing each configured model with newModel.
+func NewSelector(c ModelConfig, newModel func(name string) Model) *Selector {
+	s := Selector{config: c, models: make(map[string]Model)}
+	add := func(chain []string) {
+		for _, name := range chain {
+			if _, ok := s.models[name]; !ok {
+				s.models[name] = newModel(name)
+			}
+		}
+	}
+	add(c.Default)
+	for _, chain := range c.Overrides {
+		add(chain)
+	}
+	return &s
+}
+
+// ToolsFor returns the Model configured for task, which must be able to call tools.
+func (s *Selector) ToolsFor(task Task) (ToolModel, error) {
+	m := s.For(task)
+	if tm, ok := m.(ToolModel); ok {
+		return tm, nil
+	}
+	return nil, errors.Errorf("models for %s cannot call tools", task)
+}
+
+// For returns the Model configured for task.
+func (s *Selector) For(task Task) Model {
+	chain := s.config.Chain(task)
+	if len(chain) == 1 {
+		return s.models[chain[0]]
+	}
+	var f Fallback
+	for _, name := range chain {
+		f = append(f, s.models[name])
+	}
+	return f
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestVertexModelErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		status  int
+		body    string
+		minProb float64
+		want    error
+	}{
+		{"quota", http.StatusTooManyRequests, `{"error":{"status":"RESOURCE_EXHAUSTED"}}`, 0, ErrQuota},
+		{"overflow", http.StatusBadRequest, `{"error":{"message":"The input token count (2000000) exceeds the maximum number of tokens allowed (1048576)."}}`, 0, ErrContextOverflow},
+		{"no text", http.StatusOK, `{"candidates":[{"content":{"parts":[]},"finishReason":"SAFETY"}]}`, 0, ErrLowConfidence},
+		{"low logprob", http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"maybe"}]},"avgLogprobs":-2.5}]}`, -1, ErrLowConfidence},
+		{"confident", http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"sure"}]},"avgLogprobs":-0.1}]}`, -1, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+			m := &VertexModel{Client: srv.Client(), Project: "proj", Location: "us-central1", Model: "gemini", BaseURL: srv.URL, MinAvgLogprob: tc.minProb}
+			_, err := m.Generate(context.Background(), "prompt")
+			if tc.want == nil && err != nil {
+				t.Errorf("Generate() = %v, want success", err)
+			} else if tc.want != nil && !errors.Is(err, tc.want) {
+				t.Errorf("Generate() = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestFallback(t *testing.T) {
+	var calls []string
+	model := func(name string, err error) Model {
+		return modelFunc(func(context.Context, string) (string, error) {
+			calls = append(calls, name)
+			if err != nil {
+				return "", err
+			}
+			return name, nil
+		})
+	}
+	for _, tc := range []struct {
+		name      string
+		f         Fallback
+		want      string
+		wantErr   bool
+		wantCalls []string
+	}{
+		{"first", Fallback{model("flash", nil), model("pro", nil)}, "flash", false, []string{"flash"}},
+		{"quota", Fallback{model("flash", errors.Wrap(ErrQuota, "429")), model("pro", nil)}, "pro", false, []string{"flash", "pro"}},
+		{"fatal", Fallback{model("flash", errors.New("bad request")), model("pro", nil)}, "", true, []string{"flash"}},
+		{"exhausted", Fallback{model("flash", ErrLowConfidence), model("pro", ErrContextOverflow)}, "", true, []string{"flash", "pro"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = nil
+			got, err := tc.f.Generate(context.Background(), "prompt")
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("Generate() = %q, %v, want %q (error: %t)", got, err, tc.want, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantCalls, calls); diff != "" {
+				t.Errorf("calls mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelector(t *testing.T) {
+	c, err := ParseModelConfig("flash, pro", "bug-report=pro; explain-diff=ultra,pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created []string
+	s := NewSelector(*c, func(name string) Model {
+		created = append(created, name)
+		return modelFunc(func(context.Context, string) (string, error) { return name, nil })
+	})
+	if len(created) != 3 {
+		t.Errorf("created %v, want each model once", created)
+	}
+	if f, ok := s.For(TriageTask).(Fallback); !ok || len(f) != 2 {
+		t.Errorf("For(TriageTask) = %T, want the default chain", s.For(TriageTask))
+	}
+	if got, _ := s.For(BugReportTask).Generate(context.Background(), ""); got != "pro" {
+		t.Errorf("For(BugReportTask) generated with %q, want pro", got)
+	}
+	if got, _ := s.For(ExplainDiffTask).Generate(context.Background(), ""); got != "ultra" {
+		t.Errorf("For(ExplainDiffTask) generated with %q, want ultra", got)
+	}
+	for _, bad := range []string{"unknown-task=pro", "bug-report", "bug-report="} {
+		if _, err := ParseModelConfig("flash", bad); err == nil {
+			t.Errorf("ParseModelConfig(%q) succeeded, want error", bad)
+		}
+	}
+	if _, err := ParseModelConfig(" , ", ""); err == nil {
+		t.Error("ParseModelConfig() without models succeeded, want error")
+	}
+}
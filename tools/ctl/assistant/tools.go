@@ -0,0 +1,286 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// The types of values described by a Schema.
+const (
+	TypeString  = "STRING"
+	TypeNumber  = "NUMBER"
+	TypeInteger = "INTEGER"
+	TypeBoolean = "BOOLEAN"
+	TypeArray   = "ARRAY"
+	TypeObject  = "OBJECT"
+)
+
+// Schema describes the JSON value of a tool's arguments, using the subset of
+// the OpenAPI schema understood by Gemini models.
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
+
+// Validate returns an error describing the first way in which v, a value
+// decoded from JSON, does not conform to the schema.
+func (s *Schema) Validate(v any) error {
+	return s.validate("$", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	mismatch := func() error { return errors.Errorf("%s: want %s, got %T", path, s.Type, v) }
+	switch s.Type {
+	case TypeString:
+		str, ok := v.(string)
+		if !ok {
+			return mismatch()
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return errors.Errorf("%s: %q is not one of %q", path, str, s.Enum)
+		}
+	case TypeNumber:
+		if _, ok := v.(float64); !ok {
+			return mismatch()
+		}
+	case TypeInteger:
+		if f, ok := v.(float64); !ok || f != math.Trunc(f) {
+			return mismatch()
+		}
+	case TypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return mismatch()
+		}
+	case TypeArray:
+		items, ok := v.([]any)
+		if !ok {
+			return mismatch()
+		}
+		for i, item := range items {
+			if s.Items == nil {
+				break
+			}
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case TypeObject:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		for _, r := range s.Required {
+			if _, ok := obj[r]; !ok {
+				return errors.Errorf("%s: missing required property %q", path, r)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := s.Properties[k]
+			if !ok {
+				// NOTE: Unknown properties are rejected since they are usually
+				// misnamed arguments which would otherwise be silently dropped.
+				return errors.Errorf("%s: unknown property %q", path, k)
+			}
+			if err := prop.validate(path+"."+k, obj[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.Errorf("%s: unsupported schema type %q", path, s.Type)
+	}
+	return nil
+}
+
+// Tool is a function which a model may call.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters describes the arguments, which must be an object.
+	Parameters *Schema
+	// Func is called with arguments which have been validated against Parameters.
+	Func func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+// NewTool creates a Tool whose validated arguments are decoded into a T for fn.
+func NewTool[T any](name, description string, params *Schema, fn func(context.Context, T) (any, error)) Tool {
+	return Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  params,
+		Func: func(ctx context.Context, args json.RawMessage) (any, error) {
+			var t T
+			if err := json.Unmarshal(args, &t); err != nil {
+				return nil, errors.Wrap(err, "decoding arguments")
+			}
+			return fn(ctx, t)
+		},
+	}
+}
+
+// FunctionCall is a model's request to call a tool.
+type FunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args"`
+}
+
+// FunctionResult is the outcome of a FunctionCall returned to the model.
+type FunctionResult struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// Turn is a message in a conversation with a model.
+type Turn struct {
+	// Role is "user" or "model".
+	Role    string
+	Text    string
+	Calls   []FunctionCall
+	Results []FunctionResult
+}
+
+// ToolModel is a Model which may call tools.
+type ToolModel interface {
+	Model
+	// Converse returns the model's next turn in the conversation, during which it may call any of tools.
+	Converse(ctx context.Context, turns []Turn, tools []Tool) (*Turn, error)
+}
+
+// Toolbox dispatches a model's function calls to its tools.
+type Toolbox struct {
+	tools []Tool
+}
+
+// NewToolbox returns a Toolbox of the tools, whose names must be unique.
+func NewToolbox(tools ...Tool) (*Toolbox, error) {
+	seen := make(map[string]bool)
+	for _, t := range tools {
+		if seen[t.Name] {
+			return nil, errors.Errorf("duplicate tool %q", t.Name)
+		}
+		seen[t.Name] = true
+		if t.Parameters == nil || t.Parameters.Type != TypeObject {
+			return nil, errors.Errorf("tool %q parameters must be an object", t.Name)
+		}
+	}
+	return &Toolbox{tools: tools}, nil
+}
+
+// Tools returns the tools of the toolbox.
+func (b *Toolbox) Tools() []Tool {
+	return b.tools
+}
+
+// Dispatch validates the call's arguments and calls the tool.
+// Failures are reported to the model in the result so that it may correct them.
+func (b *Toolbox) Dispatch(ctx context.Context, call FunctionCall) FunctionResult {
+	failed := func(err error) FunctionResult {
+		return FunctionResult{Name: call.Name, Response: map[string]any{"error": err.Error()}}
+	}
+	i := slices.IndexFunc(b.tools, func(t Tool) bool { return t.Name == call.Name })
+	if i == -1 {
+		return failed(errors.Errorf("unknown tool %q", call.Name))
+	}
+	args := call.Args
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	var v any
+	if err := json.Unmarshal(args, &v); err != nil {
+		return failed(errors.Wrap(err, "decoding arguments"))
+	}
+	if err := b.tools[i].Parameters.Validate(v); err != nil {
+		return failed(errors.Wrap(err, "invalid arguments"))
+	}
+	out, err := b.tools[i].Func(ctx, args)
+	if err != nil {
+		return failed(err)
+	}
+	return FunctionResult{Name: call.Name, Response: map[string]any{"result": out}}
+}
+
+// RunTools converses with the model, dispatching its function calls, until
+// it responds without calling any tool or maxSteps turns have been taken.
+func RunTools(ctx context.Context, m ToolModel, prompt string, b *Toolbox, maxSteps int) (string, error) {
+	turns := []Turn{{Role: "user", Text: prompt}}
+	for step := 0; step < maxSteps; step++ {
+		turn, err := m.Converse(ctx, turns, b.Tools())
+		if err != nil {
+			return "", err
+		}
+		if len(turn.Calls) == 0 {
+			return turn.Text, nil
+		}
+		results := Turn{Role: "user"}
+		for _, call := range turn.Calls {
+			results.Results = append(results.Results, b.Dispatch(ctx, call))
+		}
+		turns = append(turns, *turn, results)
+	}
+	return "", errors.Errorf("model did not finish within %d steps", maxSteps)
+}
+
+// maxExtractAttempts is the number of responses with which a model may fail to call the tool of Extract.
+const maxExtractAttempts = 3
+
+// Extract asks the model to respond to prompt by calling a tool whose
+// arguments, validated against params, are decoded into a T and returned.
+// Invalid calls are reported to the model so that it may correct them.
+func Extract[T any](ctx context.Context, m ToolModel, prompt, name, description string, params *Schema) (T, error) {
+	var out T
+	var done bool
+	b, err := NewToolbox(NewTool(name, description, params, func(_ context.Context, t T) (any, error) {
+		out, done = t, true
+		return "recorded", nil
+	}))
+	if err != nil {
+		return out, err
+	}
+	turns := []Turn{{Role: "user", Text: fmt.Sprintf("%s\n\nRespond by calling %s.", prompt, name)}}
+	for attempt := 0; attempt < maxExtractAttempts; attempt++ {
+		turn, err := m.Converse(ctx, turns, b.Tools())
+		if err != nil {
+			return out, err
+		}
+		reply := Turn{Role: "user"}
+		for _, call := range turn.Calls {
+			reply.Results = append(reply.Results, b.Dispatch(ctx, call))
+			if done {
+				return out, nil
+			}
+		}
+		if len(turn.Calls) == 0 {
+			reply.Text = fmt.Sprintf("You must respond by calling %s.", name)
+		}
+		turns = append(turns, *turn, reply)
+	}
+	return out, errors.Errorf("model did not call %s within %d attempts", name, maxExtractAttempts)
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+type converseFunc func(context.Context, []Turn, []Tool) (*Turn, error)
+
+func (f converseFunc) Generate(ctx context.Context, prompt string) (string, error) {
+	turn, err := f(ctx, []Turn{{Role: "user", Text: prompt}}, nil)
+	if err != nil {
+		return "", err
+	}
+	return turn.Text, nil
+}
+
+func (f converseFunc) Converse(ctx context.Context, turns []Turn, tools []Tool) (*Turn, error) {
+	return f(ctx, turns, tools)
+}
+
+var lookupSchema = &Schema{
+	Type: TypeObject,
+	Properties: map[string]*Schema{
+		"package": {Type: TypeString},
+		"limit":   {Type: TypeInteger},
+		"kinds":   {Type: TypeArray, Items: &Schema{Type: TypeString, Enum: []string{"source", "binary"}}},
+	},
+	Required: []string{"package"},
+}
+
+func TestSchemaValidate(t *testing.T) {
+	for _, tc := range []struct {
+		args    string
+		wantErr string
+	}{
+		{`{"package":"foo"}`, ""},
+		{`{"package":"foo","limit":3,"kinds":["source","binary"]}`, ""},
+		{`{"limit":3}`, `$: missing required property "package"`},
+		{`{"package":1}`, "$.package: want STRING, got float64"},
+		{`{"package":"foo","limit":1.5}`, "$.limit: want INTEGER, got float64"},
+		{`{"package":"foo","kinds":["source","other"]}`, `$.kinds[1]: "other" is not one of`},
+		{`{"package":"foo","version":"1"}`, `$: unknown property "version"`},
+		{`["foo"]`, "$: want OBJECT, got []interface {}"},
+	} {
+		t.Run(tc.args, func(t *testing.T) {
+			var v any
+			if err := json.Unmarshal([]byte(tc.args), &v); err != nil {
+				t.Fatal(err)
+			}
+			err := lookupSchema.Validate(v)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("Validate() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+type lookupArgs struct {
+	Package string `json:"package"`
+	Limit   int    `json:"limit"`
+}
+
+func TestRunTools(t *testing.T) {
+	var looked []lookupArgs
+	lookup := NewTool("lookup", "Looks up a package.", lookupSchema, func(_ context.Context, a lookupArgs) (any, error) {
+		looked = append(looked, a)
+		if a.Package == "missing" {
+			return nil, errors.New("not found")
+		}
+		return map[string]any{"versions": a.Limit}, nil
+	})
+	b, err := NewToolbox(lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results [][]FunctionResult
+	m := converseFunc(func(_ context.Context, turns []Turn, tools []Tool) (*Turn, error) {
+		if len(tools) != 1 || tools[0].Name != "lookup" {
+			t.Errorf("tools = %v, want lookup", tools)
+		}
+		if last := turns[len(turns)-1]; len(last.Results) > 0 {
+			results = append(results, last.Results)
+		}
+		switch len(turns) {
+		case 1:
+			return &Turn{Role: "model", Calls: []FunctionCall{
+				{Name: "lookup", Args: json.RawMessage(`{"package":"foo","limit":2}`)},
+				{Name: "lookup", Args: json.RawMessage(`{"limit":2}`)},
+				{Name: "fetch", Args: json.RawMessage(`{}`)},
+			}}, nil
+		case 3:
+			return &Turn{Role: "model", Calls: []FunctionCall{{Name: "lookup", Args: json.RawMessage(`{"package":"missing"}`)}}}, nil
+		default:
+			return &Turn{Role: "model", Text: "done"}, nil
+		}
+	})
+	text, err := RunTools(context.Background(), m, "find foo", b, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "done" {
+		t.Errorf("RunTools() = %q, want done", text)
+	}
+	if diff := cmp.Diff([]lookupArgs{{"foo", 2}, {"missing", 0}}, looked); diff != "" {
+		t.Errorf("tool calls mismatch (-want +got):\n%s", diff)
+	}
+	var got []string
+	for _, rs := range results {
+		for _, r := range rs {
+			got = append(got, fmt.Sprint(r.Response))
+		}
+	}
+	want := []string{
+		"map[result:map[versions:2]]",
+		`map[error:invalid arguments: $: missing required property "package"]`,
+		`map[error:unknown tool "fetch"]`,
+		"map[error:not found]",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("results mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := RunTools(context.Background(), m, "find foo", b, 1); err == nil {
+		t.Error("RunTools() exceeding steps succeeded, want error")
+	}
+	if _, err := NewToolbox(lookup, lookup); err == nil {
+		t.Error("NewToolbox() with duplicate tools succeeded, want error")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	var prompts []string
+	m := converseFunc(func(_ context.Context, turns []Turn, _ []Tool) (*Turn, error) {
+		prompts = append(prompts, turns[len(turns)-1].Text)
+		if len(turns) == 1 {
+			return &Turn{Role: "model", Text: "It's foo."}, nil
+		}
+		return &Turn{Role: "model", Calls: []FunctionCall{{Name: "lookup", Args: json.RawMessage(`{"package":"foo"}`)}}}, nil
+	})
+	got, err := Extract[lookupArgs](context.Background(), m, "Which package?", "lookup", "Looks up a package.", lookupSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (lookupArgs{Package: "foo"}); got != want {
+		t.Errorf("Extract() = %v, want %v", got, want)
+	}
+	want := []string{"Which package?\n\nRespond by calling lookup.", "You must respond by calling lookup."}
+	if diff := cmp.Diff(want, prompts); diff != "" {
+		t.Errorf("prompts mismatch (-want +got):\n%s", diff)
+	}
+	never := converseFunc(func(context.Context, []Turn, []Tool) (*Turn, error) {
+		return &Turn{Role: "model", Text: "no"}, nil
+	})
+	if _, err := Extract[lookupArgs](context.Background(), never, "Which package?", "lookup", "", lookupSchema); err == nil {
+		t.Error("Extract() without a call succeeded, want error")
+	}
+}
+
+func TestVertexModelConverse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req vertexRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if len(req.Tools) != 1 || len(req.Tools[0].FunctionDeclarations) != 1 || req.Tools[0].FunctionDeclarations[0].Parameters.Type != TypeObject {
+			t.Errorf("tools = %+v, want lookup declaration", req.Tools)
+		}
+		if len(req.Contents) != 3 || req.Contents[1].Parts[0].FunctionCall == nil || req.Contents[2].Parts[0].FunctionResponse == nil {
+			t.Errorf("contents = %+v, want prompt, call, and result", req.Contents)
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"lookup","args":{"package":"bar"}}}]}}]}`)
+	}))
+	defer srv.Close()
+	m := &VertexModel{Client: srv.Client(), Project: "proj", Location: "us-central1", Model: "gemini", BaseURL: srv.URL}
+	turns := []Turn{
+		{Role: "user", Text: "find foo"},
+		{Role: "model", Calls: []FunctionCall{{Name: "lookup", Args: json.RawMessage(`{"package":"foo"}`)}}},
+		{Role: "user", Results: []FunctionResult{{Name: "lookup", Response: map[string]any{"error": "not found"}}}},
+	}
+	turn, err := m.Converse(context.Background(), turns, []Tool{{Name: "lookup", Parameters: lookupSchema}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(turn.Calls) != 1 || turn.Calls[0].Name != "lookup" || string(turn.Calls[0].Args) != `{"package":"bar"}` {
+		t.Errorf("Converse() calls = %+v, want lookup of bar", turn.Calls)
+	}
+}
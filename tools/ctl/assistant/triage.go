@@ -0,0 +1,121 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// FailureCategories are the causes into which failures are triaged.
+// Summaries are clustered by category so a fixed set keeps the clusters
+// consistent across the many requests of a run.
+var FailureCategories = []string{
+	"missing dependency",
+	"dependency resolution",
+	"compilation error",
+	"test failure",
+	"unsupported toolchain",
+	"source mismatch",
+	"artifact mismatch",
+	"network error",
+	"timeout",
+	"out of resources",
+	"infrastructure error",
+	"other",
+}
+
+// FailureSummary is a model's summary of the cause of a failed rebuild.
+type FailureSummary struct {
+	Target rebuild.Target `json:"target"`
+	// Category is one of FailureCategories.
+	Category string `json:"category"`
+	Summary  string `json:"summary"`
+}
+
+// summaryBudget is the number of tokens of evidence provided to summarize a failure.
+const summaryBudget = 8 << 10
+
+// SummarizeFailure asks the model to categorize and summarize the cause of a
+// failure from its verdict message, log excerpt, and logs.
+func SummarizeFailure(ctx context.Context, m Model, t rebuild.Target, message, snippet, logs string) (*FailureSummary, error) {
+	evidence, err := Pack(ctx, counterFor(m), summaryBudget, []Source{
+		{Name: "Verdict", Text: message, Priority: 3},
+		{Name: "Likely failure", Text: snippet, Priority: 2},
+		{Name: "Logs", Text: logs, Priority: 1, Keep: KeepTail, MinTokens: 100},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "packing evidence")
+	}
+	var b strings.Builder
+	b.WriteString(debugPreamble)
+	fmt.Fprintf(&b, "Package: %s %s@%s\n\n", t.Ecosystem, t.Package, t.Version)
+	fmt.Fprintf(&b, "Categorize the cause of the failure as one of: %s.\n", strings.Join(FailureCategories, ", "))
+	b.WriteString("Respond with exactly two lines, \"Category: <category>\" and \"Summary: <one sentence describing the specific cause>\", and nothing else.\n\n")
+	b.WriteString(evidence.String())
+	text, err := m.Generate(ctx, b.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "summarizing failure")
+	}
+	s := FailureSummary{Target: t, Category: "other"}
+	for _, line := range strings.Split(text, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), ":")
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.Trim(key, "*# ")) {
+		case "category":
+			// NOTE: Categories outside the set would fragment the clusters.
+			if c := strings.ToLower(strings.Trim(value, "*\"'. ")); slices.Contains(FailureCategories, c) {
+				s.Category = c
+			}
+		case "summary":
+			s.Summary = value
+		}
+	}
+	if s.Summary == "" {
+		return nil, errors.Errorf("no summary in model response: %q", text)
+	}
+	return &s, nil
+}
+
+// Cluster is the failures sharing a category.
+type Cluster struct {
+	Category  string           `json:"category"`
+	Summaries []FailureSummary `json:"summaries"`
+}
+
+// ClusterSummaries groups the summaries by category, largest first.
+func ClusterSummaries(summaries []FailureSummary) []Cluster {
+	byCategory := make(map[string][]FailureSummary)
+	for _, s := range summaries {
+		byCategory[s.Category] = append(byCategory[s.Category], s)
+	}
+	var clusters []Cluster
+	for c, s := range byCategory {
+		clusters = append(clusters, Cluster{Category: c, Summaries: s})
+	}
+	slices.SortFunc(clusters, func(a, b Cluster) int {
+		if c := cmp.Compare(len(b.Summaries), len(a.Summaries)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Category, b.Category)
+	})
+	return clusters
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assistant
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Usage is the tokens consumed by a model's requests. It is safe for concurrent use.
+type Usage struct {
+	Requests     atomic.Int64
+	PromptTokens atomic.Int64
+	OutputTokens atomic.Int64
+}
+
+func (u *Usage) add(prompt, output int64) {
+	u.Requests.Add(1)
+	u.PromptTokens.Add(prompt)
+	u.OutputTokens.Add(output)
+}
+
+// Pricing is the cost of a model's tokens, in dollars per million.
+type Pricing struct {
+	Prompt float64
+	Output float64
+}
+
+// Cost returns the cost of the usage in dollars.
+func (u *Usage) Cost(p Pricing) float64 {
+	return (float64(u.PromptTokens.Load())*p.Prompt + float64(u.OutputTokens.Load())*p.Output) / 1e6
+}
+
+func (u *Usage) String() string {
+	return fmt.Sprintf("%d requests consuming %d prompt and %d output tokens", u.Requests.Load(), u.PromptTokens.Load(), u.OutputTokens.Load())
+}
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/csv"
@@ -26,25 +27,58 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
+	"net/mail"
 	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	gcs "cloud.google.com/go/storage"
 	"github.com/cheggaaa/pb"
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/oss-rebuild/internal/api/inferenceservice"
 	"github.com/google/oss-rebuild/internal/oauth"
+	"github.com/google/oss-rebuild/internal/oci"
+	"github.com/google/oss-rebuild/internal/taskqueue"
+	"github.com/google/oss-rebuild/internal/telemetry"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/pkgname"
+	"github.com/google/oss-rebuild/pkg/rebuild/golden"
+	"github.com/google/oss-rebuild/pkg/rebuild/lint"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
-	"github.com/google/oss-rebuild/pkg/rebuild/schema/form"
+	cratesreg "github.com/google/oss-rebuild/pkg/registry/cratesio"
+	npmreg "github.com/google/oss-rebuild/pkg/registry/npm"
+	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
 	"github.com/google/oss-rebuild/tools/benchmark"
+	"github.com/google/oss-rebuild/tools/ctl/assistant"
+	"github.com/google/oss-rebuild/tools/ctl/dev"
+	"github.com/google/oss-rebuild/tools/ctl/failures"
 	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/google/oss-rebuild/tools/ctl/freshness"
+	"github.com/google/oss-rebuild/tools/ctl/hooks"
 	"github.com/google/oss-rebuild/tools/ctl/ide"
+	"github.com/google/oss-rebuild/tools/ctl/promote"
+	"github.com/google/oss-rebuild/tools/ctl/replay"
+	"github.com/google/oss-rebuild/tools/ctl/stabilizers"
+	"github.com/google/oss-rebuild/tools/docker"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -80,10 +114,51 @@ func readBenchmark(filename string) (ps benchmark.PackageSet, err error) {
 		return
 	}
 	defer f.Close()
-	err = json.NewDecoder(f).Decode(&ps)
+	if err = json.NewDecoder(f).Decode(&ps); err != nil {
+		return
+	}
+	for i, p := range ps.Packages {
+		ps.Packages[i].Name, err = pkgname.Normalize(rebuild.Ecosystem(p.Ecosystem), p.Name)
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
+func writeBenchmark(w io.Writer, ps benchmark.PackageSet) error {
+	b, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshalling benchmark")
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func rebuildTargets(rebuilds []firestore.Rebuild) []benchmark.Target {
+	var ts []benchmark.Target
+	for _, r := range rebuilds {
+		ts = append(ts, benchmark.Target{Ecosystem: r.Ecosystem, Name: r.Package, Version: r.Version})
+	}
+	return ts
+}
+
+// loadBenchmark reads the benchmark at path from the local filesystem or, if
+// a benchmark repository is configured, from its selected revision.
+// The returned repository is nil for local benchmarks.
+func loadBenchmark(ctx context.Context, path string) (benchmark.PackageSet, *benchmark.GitRepository, error) {
+	if *benchRepo == "" {
+		ps, err := readBenchmark(path)
+		return ps, nil, err
+	}
+	repo, err := benchmark.NewGitRepository(ctx, *benchRepo, *benchRef)
+	if err != nil {
+		return benchmark.PackageSet{}, nil, errors.Wrap(err, "opening benchmark repository")
+	}
+	ps, err := repo.Load(ctx, path)
+	return ps, repo, err
+}
+
 func buildFetchRebuildRequest(ctx context.Context, bench, run, filter string, clean bool) (*firestore.FetchRebuildRequest, error) {
 	var runs []string
 	if run != "" {
@@ -113,24 +188,83 @@ func buildFetchRebuildRequest(ctx context.Context, bench, run, filter string, cl
 }
 
 var tui = &cobra.Command{
-	Use:   "tui --project <ID> [--debug-bucket <bucket>] [--clean]",
+	Use:   "tui --project <ID> [--debug-bucket <bucket>] [--clean] [--api <URI>] [--read-only]",
 	Short: "A terminal UI for the OSS-Rebuild debugging tools",
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		tctx := cmd.Context()
 		if *debugBucket != "" {
-			bucket, prefix, _ := strings.Cut(strings.TrimPrefix(*debugBucket, "gs://"), string(filepath.Separator))
-			if prefix != "" {
+			loc := *debugBucket
+			if !strings.Contains(loc, "://") {
+				loc = "gs://" + loc
+			}
+			u, err := url.Parse(loc)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "parsing --debug-bucket"))
+			}
+			if prefix := strings.Trim(u.Path, "/"); !strings.HasSuffix(u.Scheme, "file") && prefix != "" {
 				log.Fatalf("--debug-bucket cannot have additional path elements, found %s", prefix)
 			}
-			tctx = context.WithValue(tctx, rebuild.UploadArtifactsPathID, bucket)
+			tctx = context.WithValue(tctx, rebuild.UploadArtifactsPathID, loc)
+		}
+		var gcsScopes []string
+		if *readOnly {
+			gcsScopes = []string{gcs.ScopeReadOnly}
+		}
+		gcsOpts, err := googleClientOptions(tctx, gcsScopes...)
+		if err != nil {
+			log.Fatal(err)
 		}
+		tctx = context.WithValue(tctx, rebuild.GCSClientOptionsID, gcsOpts)
 		// TODO: Support filtering in the UI on TUI.
-		fireClient, err := firestore.NewClient(tctx, *project)
+		fireClient, err := newFirestoreClient(tctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rt, err := docker.RuntimeFor(*containerRuntime)
 		if err != nil {
 			log.Fatal(err)
 		}
-		tapp := ide.NewTuiApp(tctx, fireClient, firestore.FetchRebuildOpts{Clean: *clean})
+		opts := ide.TuiAppOpts{Runtime: rt, DependencyCacheKey: *dependencyCache, LocalWorkers: *localWorkers, Hooks: &hooks.Runner{Dir: *hooksDir}, ReadOnly: *readOnly, SortBy: *sortBy}
+		if *columns != "" {
+			opts.Columns = strings.Split(*columns, ",")
+		}
+		if *assistantModel != "" {
+			// NOTE: gcsOpts is not reused since its read-only storage scope does not permit calling Vertex AI.
+			if opts.Assistant, err = newAssistant(tctx, nil); err != nil {
+				log.Fatal(err)
+			}
+			opts.AssistantLogs = assistant.LogConfig{Strategy: assistant.LogStrategy(*assistantLogStrategy), ContextBytes: *assistantContextBytes, Concurrency: assistant.DefaultLogConfig.Concurrency}
+			if err := opts.AssistantLogs.Validate(); err != nil {
+				log.Fatal(errors.Wrap(err, "configuring assistant"))
+			}
+		}
+		// NOTE: The search for similar failures is only offered once failures have been indexed.
+		if index, _, embedder, err := openFailureIndex(tctx); err != nil {
+			log.Println(errors.Wrap(err, "opening failure index"))
+		} else if len(index.Entries) > 0 {
+			opts.Failures, opts.Embedder = index, embedder
+		}
+		if dir, err := os.UserCacheDir(); err == nil {
+			opts.SessionPath = filepath.Join(dir, "oss-rebuild", "tui-sessions", *project+".json")
+		}
+		if *api != "" {
+			apiURL, err := url.Parse(*api)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+			}
+			opts.RemoteAPI = apiURL
+			opts.RemoteClient = http.DefaultClient
+			if isCloudRun(apiURL) {
+				// If the api is on Cloud Run, we need to use an authorized client.
+				apiURL.Scheme = "https"
+				opts.RemoteClient, err = oauth.AuthorizedUserIDClient(tctx)
+				if err != nil {
+					log.Fatal(errors.Wrap(err, "creating authorized HTTP client"))
+				}
+			}
+		}
+		tapp := ide.NewTuiApp(tctx, fireClient, firestore.FetchRebuildOpts{Clean: *clean}, opts)
 		if err := tapp.Run(); err != nil {
 			// TODO: This cleanup will be unnecessary once NewTuiApp does split logging.
 			log.Default().SetOutput(os.Stdout)
@@ -151,7 +285,7 @@ var getResults = &cobra.Command{
 		if *format == "summary" && *sample > 0 {
 			log.Fatal("--sample option incompatible with --format=summary")
 		}
-		fireClient, err := firestore.NewClient(cmd.Context(), *project)
+		fireClient, err := newFirestoreClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -178,13 +312,11 @@ var getResults = &cobra.Command{
 			}
 			fmt.Printf("%d succeeded of %d  (%2.1f%%)\n", successes, len(rebuilds), 100.*float64(successes)/float64(len(rebuilds)))
 		case "bench":
-			var ps benchmark.PackageSet
+			count := len(rebuilds)
 			if *sample > 0 && *sample < len(rebuilds) {
-				ps.Count = *sample
-			} else {
-				ps.Count = len(rebuilds)
+				count = *sample
 			}
-			rng := rand.New(rand.NewSource(int64(ps.Count)))
+			rng := rand.New(rand.NewSource(int64(count)))
 			var rbs []firestore.Rebuild
 			for _, r := range rebuilds {
 				rbs = append(rbs, r)
@@ -193,26 +325,9 @@ var getResults = &cobra.Command{
 			rng.Shuffle(len(rbs), func(i int, j int) {
 				rbs[i], rbs[j] = rbs[j], rbs[i]
 			})
-			for _, r := range rbs[:ps.Count] {
-				idx := -1
-				for i, psp := range ps.Packages {
-					if psp.Name == r.Package {
-						idx = i
-						break
-					}
-				}
-				if idx == -1 {
-					ps.Packages = append(ps.Packages, benchmark.Package{Name: r.Package, Ecosystem: r.Ecosystem})
-					idx = len(ps.Packages) - 1
-				}
-				ps.Packages[idx].Versions = append(ps.Packages[idx].Versions, r.Version)
-			}
-			ps.Updated = time.Now()
-			b, err := json.MarshalIndent(ps, "", "  ")
-			if err != nil {
-				log.Fatal(errors.Wrap(err, "marshalling benchmark"))
+			if err := writeBenchmark(cmd.OutOrStdout(), benchmark.FromTargets(rebuildTargets(rbs[:count]))); err != nil {
+				log.Fatal(err)
 			}
-			fmt.Println(string(b))
 		default:
 			log.Fatalf("Unknown --format type: %s", *format)
 		}
@@ -230,14 +345,21 @@ type Executor struct {
 	Increment   func()
 }
 
+// Process executes the worker on each package, sending results to out.
+// Once ctx is cancelled, no new packages are started and out is closed after
+// the in-flight packages return.
 func (ex *Executor) Process(ctx context.Context, out chan schema.Verdict, packages []benchmark.Package) {
 	ex.Worker.Setup(ctx)
 	jobs := make(chan benchmark.Package)
 	go func() {
+		defer close(jobs)
 		for _, p := range packages {
-			jobs <- p
+			select {
+			case jobs <- p:
+			case <-ctx.Done():
+				return
+			}
 		}
-		close(jobs)
 	}()
 	var wg sync.WaitGroup
 	for i := 0; i < ex.Concurrency; i++ {
@@ -245,6 +367,9 @@ func (ex *Executor) Process(ctx context.Context, out chan schema.Verdict, packag
 		go func() {
 			defer wg.Done()
 			for p := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
 				ex.Worker.ProcessOne(ctx, p, out)
 				if ex.Increment != nil {
 					ex.Increment()
@@ -257,7 +382,7 @@ func (ex *Executor) Process(ctx context.Context, out chan schema.Verdict, packag
 }
 
 func makeHTTPRequest(ctx context.Context, u *url.URL, msg schema.Message) *http.Request {
-	values, err := form.Marshal(msg)
+	values, err := schema.Encode(msg)
 	if err != nil {
 		log.Fatal(errors.Wrap(err, "creating values"))
 	}
@@ -269,11 +394,41 @@ func makeHTTPRequest(ctx context.Context, u *url.URL, msg schema.Message) *http.
 	return req
 }
 
+// makeFormRequest is like makeHTTPRequest but sends the message in the request body to accommodate large messages.
+func makeFormRequest(ctx context.Context, u *url.URL, msg schema.Message) *http.Request {
+	values, err := schema.Encode(msg)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "creating values"))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(values.Encode()))
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "creating request"))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
 type WorkerConfig struct {
 	client   *http.Client
 	url      *url.URL
 	limiters map[string]<-chan time.Time
 	run      string
+	// bypassCache requests that builds execute even if a cached result exists.
+	bypassCache bool
+	// allArtifacts requests that the other artifacts of each version are rebuilt alongside it.
+	allArtifacts bool
+	// disableInstallHooks requests that dependencies be installed without running their install hooks.
+	disableInstallHooks bool
+}
+
+// wait blocks until a request may be made for the ecosystem, returning false if ctx is cancelled first.
+func (w *WorkerConfig) wait(ctx context.Context, ecosystem string) bool {
+	select {
+	case <-w.limiters[ecosystem]:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 type AttestWorker struct {
@@ -284,13 +439,23 @@ func (w *AttestWorker) Setup(ctx context.Context) {}
 
 func (w *AttestWorker) ProcessOne(ctx context.Context, p benchmark.Package, out chan schema.Verdict) {
 	for _, v := range p.Versions {
-		<-w.limiters[p.Ecosystem]
+		if !w.wait(ctx, p.Ecosystem) {
+			return
+		}
+		start := time.Now()
 		resp, err := w.client.Do(makeHTTPRequest(ctx, w.url.JoinPath("rebuild"), &schema.RebuildPackageRequest{
-			Ecosystem: rebuild.Ecosystem(p.Ecosystem),
-			Package:   p.Name,
-			Version:   v,
-			ID:        w.run,
+			Ecosystem:           rebuild.Ecosystem(p.Ecosystem),
+			Package:             p.Name,
+			Version:             v,
+			ID:                  w.run,
+			BypassCache:         w.bypassCache,
+			AllArtifacts:        w.allArtifacts,
+			DisableInstallHooks: w.disableInstallHooks,
 		}))
+		if ctx.Err() != nil {
+			// The request was interrupted so the target remains incomplete.
+			return
+		}
 		var errMsg string
 		if err != nil {
 			errMsg = errors.Wrap(err, "sending request").Error()
@@ -319,6 +484,7 @@ func (w *AttestWorker) ProcessOne(ctx context.Context, p benchmark.Package, out
 				Message: "",
 			}
 		}
+		telemetry.RecordRebuild(ctx, "ctl", p.Ecosystem, time.Since(start), verdict.Message == "")
 		out <- verdict
 	}
 }
@@ -333,7 +499,7 @@ func (w *SmoketestWorker) Setup(ctx context.Context) {
 		// First, warm up the instances to ensure it can handle actual load.
 		// Warm up requires the service fulfill sequentially successful version
 		// requests (which hit both the API and the builder jobs).
-		for i := 0; i < 5; {
+		for i := 0; i < 5 && ctx.Err() == nil; {
 			_, err := getExecutorVersion(ctx, w.client, w.url, "build-local")
 			if err != nil {
 				i = 0
@@ -345,22 +511,30 @@ func (w *SmoketestWorker) Setup(ctx context.Context) {
 }
 
 func (w *SmoketestWorker) ProcessOne(ctx context.Context, p benchmark.Package, out chan schema.Verdict) {
-	<-w.limiters[p.Ecosystem]
+	if !w.wait(ctx, p.Ecosystem) {
+		return
+	}
+	start := time.Now()
 	resp, err := w.client.Do(makeHTTPRequest(ctx, w.url.JoinPath("smoketest"), &schema.SmoketestRequest{
-		Ecosystem: rebuild.Ecosystem(p.Ecosystem),
-		Package:   p.Name,
-		Versions:  p.Versions,
-		ID:        w.run,
+		Ecosystem:           rebuild.Ecosystem(p.Ecosystem),
+		Package:             p.Name,
+		Versions:            p.Versions,
+		ID:                  w.run,
+		DisableInstallHooks: w.disableInstallHooks,
 	}))
+	if ctx.Err() != nil {
+		// The request was interrupted so the targets remain incomplete.
+		return
+	}
 	var errMsg string
 	if err != nil {
 		errMsg = errors.Wrap(err, "sending request").Error()
-	}
-	if resp.StatusCode != 200 {
+	} else if resp.StatusCode != 200 {
 		errMsg = errors.Wrapf(errors.New(resp.Status), "sending request").Error()
 	}
 	if errMsg != "" {
 		for _, v := range p.Versions {
+			telemetry.RecordRebuild(ctx, "ctl", p.Ecosystem, time.Since(start), false)
 			out <- schema.Verdict{
 				Target: rebuild.Target{
 					Ecosystem: rebuild.Ecosystem(p.Ecosystem),
@@ -376,6 +550,7 @@ func (w *SmoketestWorker) ProcessOne(ctx context.Context, p benchmark.Package, o
 			log.Fatalf("Failed to decode smoketest response: %v", err)
 		}
 		for _, v := range r.Verdicts {
+			telemetry.RecordRebuild(ctx, "ctl", p.Ecosystem, v.Timings.Source+v.Timings.Infer+v.Timings.Build, v.Message == "")
 			out <- v
 		}
 	}
@@ -386,6 +561,7 @@ func defaultLimiters() map[string]<-chan time.Time {
 		"pypi":  time.Tick(time.Second),
 		"npm":   time.Tick(2 * time.Second),
 		"maven": time.Tick(2 * time.Second),
+		"oci":   time.Tick(2 * time.Second),
 		// NOTE: cratesio needs to be especially slow given our registry API
 		// constraint of 1QPS. At minimum, we expect to make 4 calls per test.
 		"cratesio": time.Tick(8 * time.Second),
@@ -396,15 +572,259 @@ func isCloudRun(u *url.URL) bool {
 	return strings.HasSuffix(u.Host, ".run.app")
 }
 
+// cloudPlatformScope is the OAuth scope granting access to all Google Cloud APIs.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// googleClientOptions returns the options with which Google Cloud clients are
+// created, impersonating the --impersonate-service-account if provided.
+// Tokens are requested for scopes, defaulting to cloudPlatformScope.
+func googleClientOptions(ctx context.Context, scopes ...string) ([]option.ClientOption, error) {
+	if *impersonateServiceAccount == "" {
+		if len(scopes) == 0 {
+			return nil, nil
+		}
+		return []option.ClientOption{option.WithScopes(scopes...)}, nil
+	}
+	if len(scopes) == 0 {
+		scopes = []string{cloudPlatformScope}
+	}
+	// NOTE: The scopes must be requested when the token is minted since
+	// option.WithScopes does not apply to an explicit token source.
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: *impersonateServiceAccount,
+		Scopes:          scopes,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "impersonating service account")
+	}
+	return []option.ClientOption{option.WithTokenSource(ts)}, nil
+}
+
+// newFirestoreClient creates a client for the --project using googleClientOptions.
+func newFirestoreClient(ctx context.Context) (*firestore.Client, error) {
+	opts, err := googleClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return firestore.NewClient(ctx, *project, opts...)
+}
+
+// writesAnnotation marks the commands which modify production state or
+// trigger rebuilds, all of which are disallowed by --read-only.
+const writesAnnotation = "writes"
+
+func checkReadOnly(cmd *cobra.Command, args []string) error {
+	if *readOnly && cmd.Annotations[writesAnnotation] != "" {
+		return errors.Errorf("%s is disallowed in read-only mode", cmd.Name())
+	}
+	return nil
+}
+
+// apiClient returns the client with which to call the API at apiURL.
+func apiClient(ctx context.Context, apiURL *url.URL) (*http.Client, error) {
+	if isCloudRun(apiURL) {
+		// If the api is on Cloud Run, we need to use an authorized client.
+		apiURL.Scheme = "https"
+		client, err := oauth.AuthorizedUserIDClient(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating authorized HTTP client")
+		}
+		return client, nil
+	}
+	return http.DefaultClient, nil
+}
+
+// newVertexClient creates an HTTP client authorized to call Vertex AI using googleClientOptions.
+func newVertexClient(ctx context.Context) (*http.Client, error) {
+	opts, err := googleClientOptions(ctx, cloudPlatformScope)
+	if err != nil {
+		return nil, err
+	}
+	client, _, err := htransport.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating Vertex AI client")
+	}
+	return client, nil
+}
+
+// newAssistant creates the Vertex AI models configured by --assistant-model
+// and --assistant-overrides, accumulating their token usage in usage if provided.
+func newAssistant(ctx context.Context, usage *assistant.Usage) (*assistant.Selector, error) {
+	config, err := assistant.ParseModelConfig(*assistantModel, *assistantOverrides)
+	if err != nil {
+		return nil, errors.Wrap(err, "configuring assistant")
+	}
+	client, err := newVertexClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return assistant.NewSelector(*config, func(name string) assistant.Model {
+		return &assistant.VertexModel{Client: client, Project: *project, Location: *assistantLocation, Model: name, Usage: usage, MinAvgLogprob: *assistantMinLogprob}
+	}), nil
+}
+
+// openFailureIndex opens the --failure-index along with the embedder of its failures.
+func openFailureIndex(ctx context.Context) (*failures.Index, string, failures.Embedder, error) {
+	path := *failureIndexFlag
+	if path == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, "", nil, errors.Wrap(err, "locating failure index")
+		}
+		path = filepath.Join(dir, "oss-rebuild", "failures", *project+".json")
+	}
+	index, err := failures.Open(path, *embeddingModel)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	client, err := newVertexClient(ctx)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return index, path, &assistant.VertexModel{Client: client, Project: *project, Location: *assistantLocation, Model: *embeddingModel}, nil
+}
+
+// progressDir returns the directory in which benchmark progress is recorded.
+func progressDir() string {
+	if *progressDirFlag != "" {
+		return *progressDirFlag
+	}
+	dir, err := benchmark.DefaultProgressDir()
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "locating progress directory"))
+	}
+	return dir
+}
+
+// startCachingRebuilder starts a local rebuilder with the dependency caches
+// of the provided key mounted, limiting concurrency to one rebuild when the
+// caches are being populated. It returns the rebuilder and its endpoint.
+func startCachingRebuilder(ctx context.Context, key string, populate bool) (*ide.Rebuilder, string) {
+	rt, err := docker.RuntimeFor(*containerRuntime)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rb := &ide.Rebuilder{Runtime: rt, DependencyCacheKey: key, PopulateDependencyCache: populate}
+	inst, err := rb.Start(ctx)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "starting local rebuilder"))
+	}
+	if populate && *maxConcurrency > 1 {
+		log.Println("Populating the dependency cache one rebuild at a time")
+		*maxConcurrency = 1
+	}
+	return rb, inst.URL("").String()
+}
+
+// serveMetrics serves benchmark metrics in the background if requested.
+func serveMetrics() {
+	if *metricsPort == 0 {
+		return
+	}
+	metrics, err := telemetry.Setup(context.Background())
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "initializing telemetry"))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	go func() {
+		if err := http.ListenAndServe(fmt.Sprintf(":%d", *metricsPort), mux); err != nil {
+			log.Println(errors.Wrap(err, "serving metrics"))
+		}
+	}()
+}
+
+// executeBenchmark runs the remaining targets of the run and writes the
+// results of all of its completed targets to the command's output.
+func executeBenchmark(ctx context.Context, cmd *cobra.Command, progress *benchmark.Progress, client *http.Client, apiURL *url.URL) {
+	pipeline, err := pipelineFor(firestore.BenchmarkMode(progress.Mode))
+	if err != nil {
+		log.Fatal(err)
+	}
+	remaining := progress.Remaining()
+	conf := WorkerConfig{
+		client:              client,
+		url:                 apiURL,
+		limiters:            defaultLimiters(),
+		run:                 progress.ID,
+		bypassCache:         *bypassCache,
+		allArtifacts:        *allArtifacts,
+		disableInstallHooks: *noInstallHooks,
+	}
+	bar := pb.New(len(remaining))
+	bar.Output = cmd.OutOrStderr()
+	bar.ShowTimeLeft = true
+	ex := Executor{Concurrency: *maxConcurrency, Worker: pipeline.NewWorker(conf), Increment: func() { bar.Increment() }}
+	verdictChan := make(chan schema.Verdict)
+	bar.Start()
+	go ex.Process(ctx, verdictChan, remaining)
+	for v := range verdictChan {
+		if err := progress.Record(v); err != nil {
+			log.Fatal(errors.Wrap(err, "recording progress"))
+		}
+	}
+	bar.Finish()
+	verdicts := progress.Completed()
+	var successes int
+	for _, v := range verdicts {
+		if v.Message == "" {
+			successes++
+		}
+	}
+	if ctx.Err() != nil {
+		log.Printf("Run interrupted. Resume with: ctl resume %s\n", progress.ID)
+	} else {
+		runner := &hooks.Runner{Dir: *hooksDir, Output: cmd.ErrOrStderr()}
+		data := hooks.Benchmark{Run: progress.ID, Mode: progress.Mode, Total: len(verdicts), Successes: successes}
+		if err := runner.Fire(ctx, hooks.Event{Type: hooks.BenchmarkFinished, Data: data}); err != nil {
+			log.Println(errors.Wrap(err, "benchmark-finished hooks"))
+		}
+	}
+	sort.Slice(verdicts, func(i, j int) bool {
+		return fmt.Sprint(verdicts[i].Target) > fmt.Sprint(verdicts[j].Target)
+	})
+	switch *format {
+	// TODO: Maybe add more format options, or include more data in the csv?
+	case "csv":
+		w := csv.NewWriter(cmd.OutOrStdout())
+		defer w.Flush()
+		for _, v := range verdicts {
+			if err := w.Write([]string{fmt.Sprintf("%v", v.Target), v.Message}); err != nil {
+				log.Fatal(errors.Wrap(err, "writing CSV"))
+			}
+		}
+	case "summary":
+		io.WriteString(cmd.OutOrStdout(), fmt.Sprintf("Successes: %d/%d\n", successes, len(verdicts)))
+	default:
+		log.Fatalf("Unsupported format: %s", *format)
+	}
+}
+
 var runBenchmark = &cobra.Command{
-	Use:   "run-bench smoketest|attest -api <URI>  [-local] [-format=summary|csv] <benchmark.json>",
+	Use:   "run-bench smoketest|attest (-api <URI> [-local] | -local --dependency-cache <key> [--populate-dependency-cache]) [-format=summary|csv] [-bench-repo <URL> [-bench-ref <ref>]] <benchmark.json>",
 	Short: "Run benchmark",
-	Args:  cobra.ExactArgs(2),
+	Long: `Run benchmark.
+
+With -local and --dependency-cache, the benchmark is executed by a local
+rebuilder started with the persistent dependency caches mounted read-only.
+With --populate-dependency-cache, the caches are instead mounted writable and
+filled by the benchmark's rebuilds, which then execute one at a time.`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := cmd.Context()
-		mode := firestore.BenchmarkMode(args[0])
-		if mode != firestore.SmoketestMode && mode != firestore.AttestMode {
-			log.Fatalf("Unknown mode: %s. Expected one of 'smoketest' or 'attest'", string(mode))
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		pipeline, err := pipelineFor(firestore.BenchmarkMode(args[0]))
+		if err != nil {
+			log.Fatal(err)
+		}
+		mode := pipeline.Mode()
+		if *dependencyCache != "" {
+			if !*buildLocal {
+				log.Fatal("--dependency-cache requires -local")
+			}
+			rb, endpoint := startCachingRebuilder(ctx, *dependencyCache, *populateDepCache)
+			defer rb.Kill()
+			*api = endpoint
 		}
 		if *api == "" {
 			log.Fatal("API endpoint not provided")
@@ -413,34 +833,27 @@ var runBenchmark = &cobra.Command{
 		if err != nil {
 			log.Fatal(errors.Wrap(err, "parsing API endpoint"))
 		}
+		serveMetrics()
 		var set benchmark.PackageSet
+		var repo *benchmark.GitRepository
 		{
 			path := args[1]
 			log.Printf("Extracting benchmark %s...\n", filepath.Base(path))
-			set, err = readBenchmark(path)
+			set, repo, err = loadBenchmark(ctx, path)
 			if err != nil {
 				log.Fatal(errors.Wrap(err, "reading benchmark file"))
 			}
-			log.Printf("Loaded benchmark of %d artifacts...\n", set.Count)
-		}
-		var client *http.Client
-		if isCloudRun(apiURL) {
-			// If the api is on Cloud Run, we need to use an authorized client.
-			apiURL.Scheme = "https"
-			client, err = oauth.AuthorizedUserIDClient(ctx)
-			if err != nil {
-				log.Fatal(errors.Wrap(err, "creating authorized HTTP client"))
+			if repo != nil {
+				log.Printf("Loaded benchmark of %d artifacts from %s at %s...\n", set.Count, repo.URL(), repo.Commit())
+			} else {
+				log.Printf("Loaded benchmark of %d artifacts...\n", set.Count)
 			}
-		} else {
-			client = http.DefaultClient
 		}
-		var executor string
-		if mode == firestore.SmoketestMode {
-			executor, err = getExecutorVersion(ctx, client, apiURL, "build-local")
-		} else if mode == firestore.AttestMode {
-			// Empty string returns the API version.
-			executor, err = getExecutorVersion(ctx, client, apiURL, "")
+		client, err := apiClient(ctx, apiURL)
+		if err != nil {
+			log.Fatal(err)
 		}
+		executor, err := getExecutorVersion(ctx, client, apiURL, pipeline.ExecutorService())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -456,6 +869,10 @@ var runBenchmark = &cobra.Command{
 				"hash": []string{hex.EncodeToString(set.Hash(sha256.New()))},
 				"type": []string{string(mode)},
 			}
+			if repo != nil {
+				values.Set("repo", repo.URL())
+				values.Set("commit", repo.Commit())
+			}
 			u.RawQuery = values.Encode()
 			req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
 			if err != nil {
@@ -474,59 +891,62 @@ var runBenchmark = &cobra.Command{
 			}
 			run = string(runBytes)
 		}
-		conf := WorkerConfig{
-			client:   client,
-			url:      apiURL,
-			limiters: defaultLimiters(),
-			run:      run,
-		}
-		bar := pb.New(len(set.Packages))
-		bar.Output = cmd.OutOrStderr()
-		bar.ShowTimeLeft = true
-		ex := Executor{Concurrency: *maxConcurrency, Increment: func() { bar.Increment() }}
-		if mode == firestore.SmoketestMode {
-			ex.Worker = &SmoketestWorker{
-				WorkerConfig: conf,
-				warmup:       isCloudRun(apiURL),
-			}
-		} else {
-			ex.Worker = &AttestWorker{
-				WorkerConfig: conf,
-			}
+		progress, err := benchmark.CreateProgress(progressDir(), benchmark.Run{
+			ID:                      run,
+			Mode:                    string(mode),
+			API:                     *api,
+			Local:                   *buildLocal,
+			DependencyCache:         *dependencyCache,
+			PopulateDependencyCache: *populateDepCache,
+			Set:                     set,
+		})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating run progress"))
 		}
-		verdictChan := make(chan schema.Verdict)
+		defer progress.Close()
 		log.Printf("Triggering rebuilds on executor version '%s' with ID=%s...\n", executor, run)
-		bar.Start()
-		go ex.Process(ctx, verdictChan, set.Packages)
-		var verdicts []schema.Verdict
-		for v := range verdictChan {
-			verdicts = append(verdicts, v)
-		}
-		bar.Finish()
-		sort.Slice(verdicts, func(i, j int) bool {
-			return fmt.Sprint(verdicts[i].Target) > fmt.Sprint(verdicts[j].Target)
-		})
-		switch *format {
-		// TODO: Maybe add more format options, or include more data in the csv?
-		case "csv":
-			w := csv.NewWriter(cmd.OutOrStdout())
-			defer w.Flush()
-			for _, v := range verdicts {
-				if err := w.Write([]string{fmt.Sprintf("%v", v.Target), v.Message}); err != nil {
-					log.Fatal(errors.Wrap(err, "writing CSV"))
-				}
-			}
-		case "summary":
-			var successes int
-			for _, v := range verdicts {
-				if v.Message == "" {
-					successes++
-				}
-			}
-			io.WriteString(cmd.OutOrStdout(), fmt.Sprintf("Successes: %d/%d\n", successes, len(verdicts)))
-		default:
-			log.Fatalf("Unsupported format: %s", *format)
+		executeBenchmark(ctx, cmd, progress, client, apiURL)
+	},
+}
+
+var resumeBenchmark = &cobra.Command{
+	Use:   "resume [-api <URI>] [-format=summary|csv] <run-id>",
+	Short: "Resume an interrupted benchmark run",
+	Long: `Resume an interrupted benchmark run.
+
+The run resumes in the execution mode with which it was started. A run
+executed by a local rebuilder with dependency caches mounted is resumed by a
+new rebuilder mounting the same caches.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		progress, err := benchmark.OpenProgress(progressDir(), args[0])
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "loading run progress"))
+		}
+		defer progress.Close()
+		*buildLocal = progress.Local
+		endpoint := progress.API
+		if progress.DependencyCache != "" {
+			// The run's rebuilder exited with it so a new one is started in its place.
+			var rb *ide.Rebuilder
+			rb, endpoint = startCachingRebuilder(ctx, progress.DependencyCache, progress.PopulateDependencyCache)
+			defer rb.Kill()
+		} else if *api != "" {
+			endpoint = *api
+		}
+		apiURL, err := url.Parse(endpoint)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+		}
+		serveMetrics()
+		client, err := apiClient(ctx, apiURL)
+		if err != nil {
+			log.Fatal(err)
 		}
+		log.Printf("Resuming run %s with %d of %d packages remaining...\n", progress.ID, len(progress.Remaining()), len(progress.Set.Packages))
+		executeBenchmark(ctx, cmd, progress, client, apiURL)
 	},
 }
 
@@ -535,9 +955,9 @@ var runOne = &cobra.Command{
 	Short: "Run benchmark",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		mode := firestore.BenchmarkMode(args[0])
-		if mode != firestore.SmoketestMode && mode != firestore.AttestMode {
-			log.Fatalf("Unknown mode: %s. Expected one of 'smoketest' or 'attest'", string(mode))
+		pipeline, err := pipelineFor(firestore.BenchmarkMode(args[0]))
+		if err != nil {
+			log.Fatal(err)
 		}
 		if *api == "" {
 			log.Fatal("API endpoint not provided")
@@ -560,9 +980,6 @@ var runOne = &cobra.Command{
 		}
 		var strategy *schema.StrategyOneOf
 		if *strategyPath != "" {
-			if mode == firestore.AttestMode {
-				log.Fatal("--strategy not supported in attest mode, use --strategy-from-repo")
-			}
 			f, err := os.Open(*strategyPath)
 			if err != nil {
 				return
@@ -577,23 +994,17 @@ var runOne = &cobra.Command{
 		if *ecosystem == "" || *pkg == "" || *version == "" {
 			log.Fatal("ecosystem, package, and version must be provided")
 		}
-		var req *http.Request
-		if mode == firestore.SmoketestMode {
-			req = makeHTTPRequest(ctx, apiURL.JoinPath("smoketest"), &schema.SmoketestRequest{
-				Ecosystem: rebuild.Ecosystem(*ecosystem),
-				Package:   *pkg,
-				Versions:  []string{*version},
-				Strategy:  strategy,
-				ID:        "runOne",
-			})
-		} else if mode == firestore.AttestMode {
-			req = makeHTTPRequest(ctx, apiURL.JoinPath("rebuild"), &schema.RebuildPackageRequest{
-				Ecosystem:        rebuild.Ecosystem(*ecosystem),
-				Package:          *pkg,
-				Version:          *version,
-				StrategyFromRepo: *useStrategyRepo,
-				ID:               "runOne",
-			})
+		t := rebuild.Target{Ecosystem: rebuild.Ecosystem(*ecosystem), Package: *pkg, Version: *version}
+		req, err := pipeline.NewRequest(ctx, apiURL, t, PipelineOpts{
+			ID:                  "runOne",
+			Strategy:            strategy,
+			StrategyFromRepo:    *useStrategyRepo,
+			BypassCache:         *bypassCache,
+			AllArtifacts:        *allArtifacts,
+			DisableInstallHooks: *noInstallHooks,
+		})
+		if err != nil {
+			log.Fatal(err)
 		}
 		resp, err := client.Do(req)
 		if err != nil {
@@ -608,82 +1019,1616 @@ var runOne = &cobra.Command{
 	},
 }
 
-var listRuns = &cobra.Command{
-	Use:   "list-runs -project <ID> [ -bench <benchmark.json> ]",
-	Short: "List runs",
+// resubmit delivers a dead-lettered task directly to its original endpoint.
+func resubmit(ctx context.Context, dl taskqueue.DeadLetter) error {
+	u, err := url.Parse(dl.URL)
+	if err != nil {
+		return errors.Wrap(err, "parsing task URL")
+	}
+	client, err := apiClient(ctx, u)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(dl.Body))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	for k, vs := range dl.Headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return errors.Wrap(errors.New(resp.Status), "sending request")
+	}
+	return nil
+}
+
+var requeue = &cobra.Command{
+	Use:   "requeue -project <ID> [-filter <reason>]",
+	Short: "Resubmit dead-lettered rebuild tasks",
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
-		var opts firestore.FetchRunsOpts
-		if *bench != "" {
-			log.Printf("Extracting benchmark %s...\n", filepath.Base(*bench))
-			set, err := readBenchmark(*bench)
-			if err != nil {
-				log.Fatal(errors.Wrap(err, "reading benchmark file"))
-			}
-			log.Printf("Loaded benchmark of %d artifacts...\n", set.Count)
-			opts.BenchmarkHash = hex.EncodeToString(set.Hash(sha256.New()))
-		}
-		if *project == "" {
-			log.Fatal("project not provided")
-		}
-		client, err := firestore.NewClient(ctx, *project)
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		client, err := newFirestoreClient(ctx)
 		if err != nil {
 			log.Fatal(errors.Wrap(err, "creating firestore client"))
 		}
-		runs, err := client.FetchRuns(ctx, opts)
+		store := &taskqueue.FirestoreDeadLetters{Client: client.Client}
+		dls, err := store.List(ctx)
 		if err != nil {
-			log.Fatal("GetRuns error", err.Error())
+			log.Fatal(errors.Wrap(err, "listing dead letters"))
 		}
-		var count int
-		for _, r := range runs {
-			fmt.Printf("  %s [bench=%s hash=%s]\n", r.ID, r.BenchmarkName, r.BenchmarkHash)
-			count++
+		var selected []taskqueue.DeadLetter
+		for _, dl := range dls {
+			if strings.HasPrefix(dl.Reason.Message, *filter) {
+				selected = append(selected, dl)
+			}
 		}
-		switch count {
-		case 0:
-			fmt.Println("No results found")
-		case 1:
-			fmt.Println("1 result found")
+		log.Printf("Requeueing %d of %d dead-lettered tasks...\n", len(selected), len(dls))
+		var requeued atomic.Int32
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, *maxConcurrency)
+		for _, dl := range selected {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(dl taskqueue.DeadLetter) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if err := resubmit(ctx, dl); err != nil {
+					log.Printf("Task %s failed again: %v\n", dl.Name, err)
+					return
+				}
+				if err := store.Remove(ctx, dl.Name); err != nil {
+					log.Printf("Task %s succeeded but could not be removed: %v\n", dl.Name, err)
+					return
+				}
+				requeued.Add(1)
+			}(dl)
+		}
+		wg.Wait()
+		io.WriteString(cmd.OutOrStdout(), fmt.Sprintf("Requeued: %d/%d\n", requeued.Load(), len(selected)))
+	},
+}
+
+var submitBatch = &cobra.Command{
+	Use:   "submit-batch smoketest|attest -api <URI> <benchmark.json>",
+	Short: "Submit a benchmark to be executed by the API as a single batch",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		pipeline, err := pipelineFor(firestore.BenchmarkMode(args[0]))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *api == "" {
+			log.Fatal("API endpoint not provided")
+		}
+		apiURL, err := url.Parse(*api)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+		}
+		set, err := readBenchmark(args[1])
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "reading benchmark file"))
+		}
+		req := schema.BatchRequest{Mode: string(pipeline.Mode()), Name: filepath.Base(args[1])}
+		for _, p := range set.Packages {
+			for _, v := range p.Versions {
+				req.Targets = append(req.Targets, rebuild.Target{Ecosystem: rebuild.Ecosystem(p.Ecosystem), Package: p.Name, Version: v})
+			}
+		}
+		client, err := apiClient(ctx, apiURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var resp schema.BatchResponse
+		if err := doJSON(client, makeFormRequest(ctx, apiURL.JoinPath("batch"), &req), &resp); err != nil {
+			log.Fatal(errors.Wrap(err, "submitting batch"))
+		}
+		log.Printf("Submitted %d targets. Poll with: ctl batch-status %s\n", resp.Total, resp.ID)
+		fmt.Fprintln(cmd.OutOrStdout(), resp.ID)
+	},
+}
+
+var batchStatus = &cobra.Command{
+	Use:   "batch-status -api <URI> <batch-id>",
+	Short: "Show the progress of a batch submitted to the API",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *api == "" {
+			log.Fatal("API endpoint not provided")
+		}
+		apiURL, err := url.Parse(*api)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+		}
+		client, err := apiClient(ctx, apiURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var s schema.BatchStatus
+		req := &schema.BatchStatusRequest{ID: args[0], Results: *format == "csv"}
+		if err := doJSON(client, makeHTTPRequest(ctx, apiURL.JoinPath("batch", "status"), req), &s); err != nil {
+			log.Fatal(errors.Wrap(err, "fetching batch status"))
+		}
+		switch *format {
+		case "summary":
+			state := "running"
+			if s.Done() {
+				state = "done"
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s [%s mode=%s] succeeded=%d failed=%d total=%d\n", s.ID, state, s.Mode, s.Succeeded, s.Failed, s.Total)
+		case "csv":
+			sort.Slice(s.Results, func(i, j int) bool {
+				return fmt.Sprint(s.Results[i].Target) < fmt.Sprint(s.Results[j].Target)
+			})
+			w := csv.NewWriter(cmd.OutOrStdout())
+			defer w.Flush()
+			for _, r := range s.Results {
+				if err := w.Write([]string{string(r.Target.Ecosystem), r.Target.Package, r.Target.Version, strconv.FormatBool(r.Success), r.Message}); err != nil {
+					log.Fatal(errors.Wrap(err, "writing CSV"))
+				}
+			}
+		default:
+			log.Fatalf("Unsupported format: %s", *format)
+		}
+	},
+}
+
+var annotate = &cobra.Command{
+	Use:   "annotate -api <URI> --ecosystem <ecosystem> --package <name> --version <version> [--artifact <name>] [--link <URL>] <text>",
+	Short: "Attach a note to the rebuild results of a package version",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *api == "" {
+			log.Fatal("API endpoint not provided")
+		}
+		apiURL, err := url.Parse(*api)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+		}
+		if isCloudRun(apiURL) {
+			apiURL.Scheme = "https"
+		}
+		// Annotations are attributed to the identity of the caller so the ID
+		// token is required even when the API is not on Cloud Run.
+		client, err := oauth.AuthorizedUserIDClient(ctx)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating authorized HTTP client"))
+		}
+		req := &schema.AnnotateRequest{
+			Ecosystem: rebuild.Ecosystem(*ecosystem),
+			Package:   *pkg,
+			Version:   *version,
+			Artifact:  *artifact,
+			Text:      args[0],
+			Link:      *link,
+		}
+		var resp schema.AnnotateResponse
+		if err := doJSON(client, makeHTTPRequest(ctx, apiURL.JoinPath("annotate"), req), &resp); err != nil {
+			log.Fatal(errors.Wrap(err, "creating annotation"))
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), resp.ID)
+	},
+}
+
+// readBuildDef returns the build definition from the --strategy file or, if
+// not provided, the one edited for the target in the local --run.
+func readBuildDef(ctx context.Context, t rebuild.Target) (*schema.StrategyOneOf, error) {
+	var r io.ReadCloser
+	if *strategyPath != "" {
+		f, err := os.Open(*strategyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening strategy file")
+		}
+		r = f
+	} else if *runFlag != "" {
+		assets, err := ide.LocalAssetStore(ctx, *runFlag)
+		if err != nil {
+			return nil, err
+		}
+		r, _, err = assets.Reader(ctx, rebuild.Asset{Type: rebuild.BuildDef, Target: t})
+		if err != nil {
+			return nil, errors.Wrap(err, "opening local build definition")
+		}
+	} else {
+		return nil, errors.New("one of --strategy or --run must be provided")
+	}
+	defer r.Close()
+	def := &schema.StrategyOneOf{}
+	if err := yaml.NewDecoder(r).Decode(def); err != nil {
+		return nil, errors.Wrap(err, "reading build definition")
+	}
+	return def, nil
+}
+
+// promoterSignature returns the identity provided by --promoter or, if not
+// provided, the git user configured for the repository.
+func promoterSignature(repo *git.Repository) (*object.Signature, error) {
+	if *promoter != "" {
+		addr, err := mail.ParseAddress(*promoter)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing promoter")
+		}
+		return &object.Signature{Name: addr.Name, Email: addr.Address}, nil
+	}
+	cfg, err := repo.ConfigScoped(gitconfig.GlobalScope)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading git config")
+	}
+	if cfg.User.Name == "" || cfg.User.Email == "" {
+		return nil, errors.New("git user not configured, use --promoter")
+	}
+	return &object.Signature{Name: cfg.User.Name, Email: cfg.User.Email}, nil
+}
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote --api <URI> --ecosystem <ecosystem> --package <name> --version <version> --artifact <name> (--run <ID> | --strategy <strategy.yaml>) --build-def-repo <path> [--build-def-repo-dir <dir>] [--promoter <\"name <email>\">]",
+	Short: "Verify a locally-fixed build definition and commit it to the build definition repository used in production",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *ecosystem == "" || *pkg == "" || *version == "" || *artifact == "" {
+			log.Fatal("ecosystem, package, version, and artifact must be provided")
+		}
+		if *buildDefRepo == "" {
+			log.Fatal("build definition repository not provided")
+		}
+		if *api == "" {
+			log.Fatal("API endpoint not provided")
+		}
+		apiURL, err := url.Parse(*api)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+		}
+		t := rebuild.Target{Ecosystem: rebuild.Ecosystem(*ecosystem), Package: *pkg, Version: *version, Artifact: *artifact}
+		def, err := readBuildDef(ctx, t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := promote.Validate(ctx, t, def); err != nil {
+			log.Fatal(errors.Wrap(err, "validating build definition"))
+		}
+		repo, err := git.PlainOpen(*buildDefRepo)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "opening build definition repository"))
+		}
+		sig, err := promoterSignature(repo)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Re-run the definition to ensure what is promoted is exactly what succeeded.
+		client, err := apiClient(ctx, apiURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		req, err := (&smoketestPipeline{}).NewRequest(ctx, apiURL, t, PipelineOpts{ID: "promote", Strategy: def})
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Verifying build definition for %s %s %s...", t.Ecosystem, t.Package, t.Version)
+		var resp schema.SmoketestResponse
+		if err := doJSON(client, req, &resp); err != nil {
+			log.Fatal(errors.Wrap(err, "verifying build definition"))
+		}
+		idx := slices.IndexFunc(resp.Verdicts, func(v schema.Verdict) bool { return v.Target.Artifact == t.Artifact })
+		if idx == -1 {
+			log.Fatalf("No verdict for %s in verification", t.Artifact)
+		}
+		if msg := resp.Verdicts[idx].Message; msg != "" {
+			log.Fatalf("Build definition failed verification: %s", msg)
+		}
+		hash, err := promote.Promote(ctx, repo, *buildDefRepoDir, t, def, promote.Provenance{
+			Promoter: *sig,
+			Run:      *runFlag,
+			Executor: resp.Executor,
+		})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "promoting build definition"))
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Committed %s to %s. Push it to make the definition available to production.\n", hash, *buildDefRepo)
+	},
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint --ecosystem <ecosystem> --package <name> --version <version> [--artifact <name>] (--strategy <strategy.yaml> | --run <ID>) [--check-urls]",
+	Short: "Statically check a build definition for problems that would fail its rebuild",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *ecosystem == "" || *pkg == "" || *version == "" {
+			log.Fatal("ecosystem, package, and version must be provided")
+		}
+		t := rebuild.Target{Ecosystem: rebuild.Ecosystem(*ecosystem), Package: *pkg, Version: *version, Artifact: *artifact}
+		def, err := readBuildDef(ctx, t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, err := def.Strategy()
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "reading build definition"))
+		}
+		opts := lint.Options{Env: rebuild.BuildEnv{HasRepo: true, TimewarpHost: "localhost:8081"}, Shell: "sh"}
+		if *checkURLs {
+			opts.Client = http.DefaultClient
+		}
+		findings := lint.Lint(ctx, t, s, opts)
+		for _, f := range findings {
+			fmt.Fprintln(cmd.OutOrStdout(), f)
+		}
+		if lint.HasErrors(findings) {
+			os.Exit(1)
+		}
+	},
+}
+
+var genGolden = &cobra.Command{
+	Use:   "gen-golden --ecosystem <ecosystem> --package <name> --version <version> --artifact <name> [--golden-root <dir>]",
+	Short: "Run inference for a target and record the strategy and its instructions as a golden test fixture",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *ecosystem == "" || *pkg == "" || *version == "" || *artifact == "" {
+			log.Fatal("ecosystem, package, version, and artifact must be provided")
+		}
+		req := schema.InferenceRequest{Ecosystem: rebuild.Ecosystem(*ecosystem), Package: *pkg, Version: *version}
+		oneof, err := inferenceservice.Infer(ctx, req, &inferenceservice.InferDeps{HTTPClient: http.DefaultClient})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "inferring strategy"))
+		}
+		// NOTE: Inference normalizes the package name so the fixture must too.
+		t := rebuild.Target{Ecosystem: req.Ecosystem, Package: *pkg, Version: *version, Artifact: *artifact}
+		if t.Package, err = pkgname.Normalize(t.Ecosystem, t.Package); err != nil {
+			log.Fatal(errors.Wrap(err, "normalizing package"))
+		}
+		f, err := golden.New(t, *oneof)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p := golden.Path(*goldenRoot, t)
+		if err := golden.Write(p, f); err != nil {
+			log.Fatal(errors.Wrap(err, "writing fixture"))
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", p)
+	},
+}
+
+var submitSBOM = &cobra.Command{
+	Use:   "submit-sbom smoketest|attest -api <URI> <sbom.json>",
+	Short: "Submit the components of a CycloneDX or SPDX SBOM to be executed by the API as a single batch",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		pipeline, err := pipelineFor(firestore.BenchmarkMode(args[0]))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *api == "" {
+			log.Fatal("API endpoint not provided")
+		}
+		apiURL, err := url.Parse(*api)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "parsing API endpoint"))
+		}
+		b, err := os.ReadFile(args[1])
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "reading SBOM"))
+		}
+		client, err := apiClient(ctx, apiURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		req := &schema.SBOMRequest{Mode: string(pipeline.Mode()), SBOM: string(b), Name: filepath.Base(args[1])}
+		var resp schema.SBOMResponse
+		if err := doJSON(client, makeFormRequest(ctx, apiURL.JoinPath("sbom"), req), &resp); err != nil {
+			log.Fatal(errors.Wrap(err, "submitting SBOM"))
+		}
+		for _, c := range resp.Unsupported {
+			log.Printf("Skipping unsupported component: %s\n", c)
+		}
+		log.Printf("Submitted %d targets. Report with: ctl batch-status -format=csv %s\n", resp.Total, resp.ID)
+		fmt.Fprintln(cmd.OutOrStdout(), resp.ID)
+	},
+}
+
+// doJSON sends req and decodes its JSON response into v.
+func doJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+var listRuns = &cobra.Command{
+	Use:   "list-runs -project <ID> [ -bench <benchmark.json> ]",
+	Short: "List runs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		var opts firestore.FetchRunsOpts
+		if *bench != "" {
+			log.Printf("Extracting benchmark %s...\n", filepath.Base(*bench))
+			set, err := readBenchmark(*bench)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "reading benchmark file"))
+			}
+			log.Printf("Loaded benchmark of %d artifacts...\n", set.Count)
+			opts.BenchmarkHash = hex.EncodeToString(set.Hash(sha256.New()))
+		}
+		if *project == "" {
+			log.Fatal("project not provided")
+		}
+		client, err := newFirestoreClient(ctx)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "creating firestore client"))
+		}
+		runs, err := client.FetchRuns(ctx, opts)
+		if err != nil {
+			log.Fatal("GetRuns error", err.Error())
+		}
+		var count int
+		for _, r := range runs {
+			fmt.Printf("  %s [bench=%s hash=%s]\n", r.ID, r.BenchmarkName, r.BenchmarkHash)
+			if r.BenchmarkCommit != "" {
+				fmt.Printf("    benchmark=%s@%s\n", r.BenchmarkRepo, r.BenchmarkCommit)
+			}
+			if r.Config != nil {
+				fmt.Printf("    api=%s build-local=%s inference=%s\n", r.Config.APIVersion, r.Config.BuildLocalVersion, r.Config.InferenceVersion)
+			}
+			count++
+		}
+		switch count {
+		case 0:
+			fmt.Println("No results found")
+		case 1:
+			fmt.Println("1 result found")
+		default:
+			fmt.Printf("%d results found\n", count)
+		}
+	},
+}
+
+var benchVersions = &cobra.Command{
+	Use:   "bench-versions -bench-repo <URL> [-bench-ref <ref>] [<benchmark.json>]",
+	Short: "List the benchmarks in a benchmark repository or the versions of one",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *benchRepo == "" {
+			log.Fatal("bench-repo not provided")
+		}
+		repo, err := benchmark.NewGitRepository(ctx, *benchRepo, *benchRef)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "opening benchmark repository"))
+		}
+		if len(args) == 0 {
+			names, err := repo.List(ctx)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "listing benchmarks"))
+			}
+			for _, name := range names {
+				fmt.Fprintln(cmd.OutOrStdout(), name)
+			}
+			return
+		}
+		versions, err := repo.Versions(ctx, args[0])
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "listing versions"))
+		}
+		for _, v := range versions {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s %s %s\n", v.Commit[:12], v.Time.UTC().Format(time.DateOnly), v.Subject)
+		}
+	},
+}
+
+func readBenchmarks(paths []string) ([]benchmark.PackageSet, error) {
+	var sets []benchmark.PackageSet
+	for _, path := range paths {
+		ps, err := readBenchmark(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading benchmark %s", path)
+		}
+		sets = append(sets, ps)
+	}
+	return sets, nil
+}
+
+var benchCmd = &cobra.Command{
+	Use:   "bench diff|merge|intersect|subtract|from-run|refresh",
+	Short: "Compose benchmark files",
+}
+
+var benchDiff = &cobra.Command{
+	Use:   "diff <before.json> <after.json>",
+	Short: "List the targets added and removed between two benchmarks",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		sets, err := readBenchmarks(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		added, removed := benchmark.Diff(sets[0], sets[1])
+		for _, t := range removed.Targets() {
+			fmt.Fprintf(cmd.OutOrStdout(), "- %s %s %s\n", t.Ecosystem, t.Name, t.Version)
+		}
+		for _, t := range added.Targets() {
+			fmt.Fprintf(cmd.OutOrStdout(), "+ %s %s %s\n", t.Ecosystem, t.Name, t.Version)
+		}
+		log.Printf("%d added, %d removed\n", added.Count, removed.Count)
+	},
+}
+
+var benchMerge = &cobra.Command{
+	Use:   "merge <benchmark.json>...",
+	Short: "Print a benchmark containing the targets of any of the provided benchmarks",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sets, err := readBenchmarks(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeBenchmark(cmd.OutOrStdout(), benchmark.Merge(sets...)); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var benchIntersect = &cobra.Command{
+	Use:   "intersect <benchmark.json>...",
+	Short: "Print a benchmark containing the targets common to all the provided benchmarks",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sets, err := readBenchmarks(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeBenchmark(cmd.OutOrStdout(), benchmark.Intersect(sets[0], sets[1:]...)); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var benchSubtract = &cobra.Command{
+	Use:   "subtract <benchmark.json> <benchmark.json>...",
+	Short: "Print a benchmark containing the targets of the first benchmark absent from the rest",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		sets, err := readBenchmarks(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeBenchmark(cmd.OutOrStdout(), benchmark.Subtract(sets[0], sets[1:]...)); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var benchFromRun = &cobra.Command{
+	Use:   "from-run -project <ID> -run <ID> [-bench <benchmark.json>] [-filter <verdict>] [-failures]",
+	Short: "Print a benchmark containing the targets of a run's results",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		req, err := buildFetchRebuildRequest(cmd.Context(), *bench, *runFlag, *filter, *clean)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fireClient, err := newFirestoreClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+		rebuilds, err := fireClient.FetchRebuilds(cmd.Context(), req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var rbs []firestore.Rebuild
+		for _, r := range rebuilds {
+			if *failuresOnly && r.Success {
+				continue
+			}
+			rbs = append(rbs, r)
+		}
+		if err := writeBenchmark(cmd.OutOrStdout(), benchmark.FromTargets(rebuildTargets(rbs))); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var benchRefresh = &cobra.Command{
+	Use:   "refresh [-versions N] <benchmark.json>",
+	Short: "Print a benchmark with each unpinned package updated to its latest published versions",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ps, err := readBenchmark(args[0])
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "reading benchmark file"))
+		}
+		mux := rebuild.RegistryMux{
+			CratesIO: cratesreg.HTTPRegistry{Client: http.DefaultClient},
+			NPM:      npmreg.HTTPRegistry{Client: http.DefaultClient},
+			PyPI:     pypireg.HTTPRegistry{Client: http.DefaultClient},
+		}
+		refreshed := benchmark.Refresh(cmd.Context(), ps, mux, *refreshVersions)
+		added, removed := benchmark.Diff(ps, refreshed)
+		log.Printf("Refreshed benchmark: %d added, %d removed\n", added.Count, removed.Count)
+		if err := writeBenchmark(cmd.OutOrStdout(), refreshed); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var devCmd = &cobra.Command{
+	Use:   "dev up|down",
+	Short: "Manage a local stack of the OSS Rebuild services",
+}
+
+var devUp = &cobra.Command{
+	Use:   "up [--port <port>] [--data-dir <dir>]",
+	Short: "Build and run the services locally until interrupted",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		rt, err := docker.RuntimeFor(*containerRuntime)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := dev.Up(ctx, dev.Options{Port: *devPort, Output: cmd.OutOrStdout(), Runtime: rt, DependencyCacheKey: *dependencyCache, DataDir: *devDataDir}); err != nil {
+			log.Fatal(errors.Wrap(err, "running local stack"))
+		}
+	},
+}
+
+var devDown = &cobra.Command{
+	Use:   "down",
+	Short: "Stop a local stack left running by a previous invocation",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		rt, err := docker.RuntimeFor(*containerRuntime)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := dev.Down(context.Background(), rt); err != nil {
+			log.Fatal(errors.Wrap(err, "stopping local stack"))
+		}
+	},
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay [--container-runtime <runtime>] <bundle.jsonl>",
+	Short: "Re-execute the rebuild described by an attestation bundle and check that it still matches",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "opening bundle"))
+		}
+		bundle, err := verifier.ReadBundle(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		rt, err := docker.RuntimeFor(*containerRuntime)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res, err := replay.Replay(ctx, bundle, replay.Options{Runtime: rt, Output: cmd.ErrOrStderr()})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "replaying rebuild"))
+		}
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "target:    %s %s %s %s\n", res.Target.Ecosystem, res.Target.Package, res.Target.Version, res.Target.Artifact)
+		fmt.Fprintf(w, "rebuild:   %s (attested %s)\n", res.Digest, res.WantDigest)
+		fmt.Fprintf(w, "canonical: %s (attested %s)\n", res.CanonicalDigest, res.WantCanonicalDigest)
+		switch {
+		case res.ExactMatch():
+			fmt.Fprintln(w, "result:    MATCH (identical to attested rebuild)")
+		case res.Matches():
+			fmt.Fprintln(w, "result:    MATCH (equivalent to upstream after stabilization)")
+		default:
+			fmt.Fprintln(w, "result:    MISMATCH")
+			os.Exit(1)
+		}
+	},
+}
+
+var diffVersionsCmd = &cobra.Command{
+	Use:   "diff-versions [--container-runtime <runtime>] [--format=summary|json] <old-bundle.jsonl> <new-bundle.jsonl>",
+	Short: "Rebuild two versions of a package from their attestation bundles and report what changed between them",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		var bundles []*verifier.Bundle
+		for _, arg := range args {
+			f, err := os.Open(arg)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "opening bundle"))
+			}
+			bundle, err := verifier.ReadBundle(f)
+			f.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+			bundles = append(bundles, bundle)
+		}
+		rt, err := docker.RuntimeFor(*containerRuntime)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d, err := replay.DiffVersions(ctx, bundles[0], bundles[1], replay.Options{Runtime: rt, Output: cmd.ErrOrStderr()})
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "diffing versions"))
+		}
+		for _, res := range []*replay.Result{d.Old, d.New} {
+			if !res.Matches() {
+				log.Printf("WARNING: rebuild of %s %s no longer matches its attestation", res.Target.Package, res.Target.Version)
+			}
+		}
+		w := cmd.OutOrStdout()
+		switch *format {
+		case "summary":
+			fmt.Fprintf(w, "%s %s: %s -> %s\n", d.Old.Target.Ecosystem, d.Old.Target.Package, d.Old.Target.Version, d.New.Target.Version)
+			for _, c := range d.Changes {
+				var reasons string
+				if len(c.Reasons) > 0 {
+					var rs []string
+					for _, r := range c.Reasons {
+						rs = append(rs, string(r))
+					}
+					reasons = " [" + strings.Join(rs, ", ") + "]"
+				}
+				fmt.Fprintf(w, " %-8s %s%s\n", c.Change, c.Path, reasons)
+			}
+			fmt.Fprintf(w, "%d added, %d removed, %d modified\n", d.Count(rebuild.ChangeAdded), d.Count(rebuild.ChangeRemoved), d.Count(rebuild.ChangeModified))
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(d.VersionDiff); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("Unknown --format type: %s", *format)
+		}
+	},
+}
+
+var freshnessCmd = &cobra.Command{
+	Use:   "freshness [--check-sources] [--format=summary|json] <bundle.jsonl>...",
+	Short: "Report which build inputs recorded in attestation bundles have since changed or been withdrawn upstream",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		mux := rebuild.RegistryMux{
+			CratesIO: cratesreg.HTTPRegistry{Client: http.DefaultClient},
+			NPM:      npmreg.HTTPRegistry{Client: http.DefaultClient},
+			PyPI:     pypireg.HTTPRegistry{Client: http.DefaultClient},
+		}
+		opts := freshness.Options{
+			Images:   &oci.Resolver{Client: http.DefaultClient},
+			Registry: &mux,
+			Sources:  *checkSources,
+		}
+		var reports []*freshness.Report
+		for _, arg := range args {
+			f, err := os.Open(arg)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "opening bundle"))
+			}
+			bundle, err := verifier.ReadBundle(f)
+			f.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+			r, err := freshness.Check(ctx, bundle, opts)
+			if err != nil {
+				log.Fatal(errors.Wrapf(err, "checking %s", arg))
+			}
+			reports = append(reports, r)
+		}
+		w := cmd.OutOrStdout()
+		switch *format {
+		case "summary":
+			for _, r := range reports {
+				fmt.Fprintf(w, "%s %s %s %s\n", r.Target.Ecosystem, r.Target.Package, r.Target.Version, r.Target.Artifact)
+				for _, f := range r.Findings {
+					var detail string
+					if f.Detail != "" {
+						detail = " (" + f.Detail + ")"
+					}
+					fmt.Fprintf(w, " %-11s %-8s %s%s\n", f.Status, f.Kind, f.Name, detail)
+				}
+			}
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(reports); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("Unknown --format type: %s", *format)
+		}
+		var stale int
+		for _, r := range reports {
+			if r.Stale() {
+				stale++
+			}
+		}
+		if stale > 0 {
+			log.Printf("%d of %d attested rebuilds have stale inputs", stale, len(reports))
+			os.Exit(1)
+		}
+	},
+}
+
+// readAsset reads the entirety of an asset from the store.
+func readAsset(ctx context.Context, store rebuild.AssetStore, a rebuild.Asset) ([]byte, error) {
+	r, _, err := store.Reader(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+var suggestStabilizers = &cobra.Command{
+	Use:   "suggest-stabilizers -project <ID> -run <ID> --debug-bucket <bucket> [-bench <benchmark.json>] [-filter <verdict>] [--min-targets N] [--format=summary|json]",
+	Short: "Propose stabilizers for the differences recurring across the mismatches of runs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *debugBucket == "" {
+			log.Fatal("--debug-bucket must be provided")
+		}
+		bucket := *debugBucket
+		if !strings.Contains(bucket, "://") {
+			bucket = "gs://" + bucket
+		}
+		gcsOpts, err := googleClientOptions(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx = context.WithValue(ctx, rebuild.GCSClientOptionsID, gcsOpts)
+		req, err := buildFetchRebuildRequest(ctx, *bench, *runFlag, *filter, false)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fireClient, err := newFirestoreClient(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rebuilds, err := fireClient.FetchRebuilds(ctx, req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stores := make(map[string]rebuild.AssetStore)
+		for _, runID := range req.Runs {
+			if stores[runID], err = rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, runID), bucket); err != nil {
+				log.Fatal(errors.Wrap(err, "creating asset store"))
+			}
+		}
+		var (
+			mu        sync.Mutex
+			wg        sync.WaitGroup
+			summaries []*stabilizers.Summary
+			skipped   int
+		)
+		sem := make(chan struct{}, 10)
+		for _, r := range rebuilds {
+			// NOTE: Only mismatches have both a rebuilt and an upstream artifact to compare.
+			if r.Success || r.Artifact == "" {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(r firestore.Rebuild) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s, err := func() (*stabilizers.Summary, error) {
+					store := stores[r.Run]
+					rb, err := readAsset(ctx, store, rebuild.Asset{Target: r.Target(), Type: rebuild.DebugRebuildAsset})
+					if err != nil {
+						return nil, err
+					}
+					up, err := readAsset(ctx, store, rebuild.Asset{Target: r.Target(), Type: rebuild.DebugUpstreamAsset})
+					if err != nil {
+						return nil, err
+					}
+					return stabilizers.Summarize(r.Target(), rb, up)
+				}()
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					skipped++
+					return
+				}
+				summaries = append(summaries, s)
+			}(r)
+		}
+		wg.Wait()
+		log.Printf("Compared %d mismatches, skipped %d lacking comparable artifacts", len(summaries), skipped)
+		suggestions := stabilizers.Suggest(summaries, *minTargets)
+		w := cmd.OutOrStdout()
+		switch *format {
+		case "summary":
+			for _, s := range suggestions {
+				fmt.Fprintf(w, "%5d %-8s %s\n", s.Targets, s.Ecosystem, s.Rule)
+				for _, t := range s.Examples {
+					fmt.Fprintf(w, "      e.g. %s %s\n", t.Package, t.Version)
+				}
+			}
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(suggestions); err != nil {
+				log.Fatal(err)
+			}
 		default:
-			fmt.Printf("%d results found\n", count)
+			log.Fatalf("Unknown --format type: %s", *format)
+		}
+	},
+}
+
+var aiTriage = &cobra.Command{
+	Use:   "ai-triage -project <ID> -run <ID> --debug-bucket <bucket> --assistant-model <model> [-bench <benchmark.json>] [-filter <verdict>] [--format=summary|json]",
+	Short: "Summarize and cluster the failures of runs using a language model",
+	Long: `Summarize and cluster the failures of runs using a language model.
+
+Each failure's summary is saved as a local asset as soon as it is generated
+and failures already summarized are skipped, so an interrupted job resumes
+where it left off when re-run. The tokens consumed, and their cost if
+--prompt-token-cost and --output-token-cost are provided, are reported at the
+end.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *debugBucket == "" {
+			log.Fatal("--debug-bucket must be provided")
+		}
+		if *assistantModel == "" {
+			log.Fatal("--assistant-model must be provided")
+		}
+		bucket := *debugBucket
+		if !strings.Contains(bucket, "://") {
+			bucket = "gs://" + bucket
+		}
+		gcsOpts, err := googleClientOptions(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx = context.WithValue(ctx, rebuild.GCSClientOptionsID, gcsOpts)
+		req, err := buildFetchRebuildRequest(ctx, *bench, *runFlag, *filter, false)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fireClient, err := newFirestoreClient(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rebuilds, err := fireClient.FetchRebuilds(ctx, req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		usage := new(assistant.Usage)
+		models, err := newAssistant(ctx, usage)
+		if err != nil {
+			log.Fatal(err)
+		}
+		model := models.For(assistant.TriageTask)
+		debugStores := make(map[string]rebuild.AssetStore)
+		summaryStores := make(map[string]rebuild.AssetStore)
+		for _, runID := range req.Runs {
+			if debugStores[runID], err = rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, runID), bucket); err != nil {
+				log.Fatal(errors.Wrap(err, "creating asset store"))
+			}
+			if summaryStores[runID], err = ide.LocalAssetStore(ctx, runID); err != nil {
+				log.Fatal(errors.Wrap(err, "creating local asset store"))
+			}
+		}
+		var (
+			mu              sync.Mutex
+			wg              sync.WaitGroup
+			summaries       []assistant.FailureSummary
+			resumed, failed int
+		)
+		sem := make(chan struct{}, 10)
+		for _, r := range rebuilds {
+			if r.Success {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(r firestore.Rebuild) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				asset := rebuild.Asset{Target: r.Target(), Type: rebuild.FailureSummaryAsset}
+				var s assistant.FailureSummary
+				if b, err := readAsset(ctx, summaryStores[r.Run], asset); err == nil && json.Unmarshal(b, &s) == nil {
+					mu.Lock()
+					defer mu.Unlock()
+					summaries = append(summaries, s)
+					resumed++
+					return
+				}
+				err := func() error {
+					// NOTE: Not every failure produces logs so those lacking them are summarized without.
+					logs, err := readAsset(ctx, debugStores[r.Run], rebuild.Asset{Target: r.Target(), Type: rebuild.DebugLogsAsset})
+					if err != nil && !errors.Is(err, rebuild.ErrAssetNotFound) {
+						return err
+					}
+					summary, err := assistant.SummarizeFailure(ctx, model, r.Target(), r.Message, r.FailureSnippet, string(logs))
+					if err != nil {
+						return err
+					}
+					s = *summary
+					w, _, err := summaryStores[r.Run].Writer(ctx, asset)
+					if err != nil {
+						return err
+					}
+					if err := json.NewEncoder(w).Encode(s); err != nil {
+						w.Close()
+						return err
+					}
+					return w.Close()
+				}()
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					log.Println(errors.Wrapf(err, "summarizing %s", r.ID()))
+					failed++
+					return
+				}
+				summaries = append(summaries, s)
+			}(r)
+		}
+		wg.Wait()
+		log.Printf("Summarized %d failures (%d from a previous run), %d failed and will be retried when re-run", len(summaries), resumed, failed)
+		clusters := assistant.ClusterSummaries(summaries)
+		w := cmd.OutOrStdout()
+		switch *format {
+		case "summary":
+			for _, c := range clusters {
+				fmt.Fprintf(w, "%5d %s\n", len(c.Summaries), c.Category)
+				for _, s := range c.Summaries[:min(len(c.Summaries), 5)] {
+					fmt.Fprintf(w, "      %s %s: %s\n", s.Target.Package, s.Target.Version, s.Summary)
+				}
+			}
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(clusters); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("Unknown --format type: %s", *format)
+		}
+		msg := "Consumed " + usage.String()
+		if *promptTokenCost != 0 || *outputTokenCost != 0 {
+			msg += fmt.Sprintf(" costing $%.2f", usage.Cost(assistant.Pricing{Prompt: *promptTokenCost, Output: *outputTokenCost}))
+		}
+		log.Println(msg)
+	},
+}
+
+var aiEval = &cobra.Command{
+	Use:   "ai-eval (--assistant-model <model> [--record-session <file>] | --replay-session <file>) [--baseline <report.json>] [--label <label>] [--format=summary|json] <corpus.jsonl>",
+	Short: "Evaluate the assistant's failure triage against a corpus of known failures",
+	Long: `Evaluate the assistant's failure triage against a corpus of known failures.
+
+Each case of the corpus is a recorded failure along with its known category
+and the keywords a correct summary mentions. Each triage is scored from 0 to 1
+and the JSON report may be provided as the --baseline of a later evaluation,
+in which case the cases whose scores regressed are reported and the command
+fails if there are any.
+
+The model's responses are saved to --record-session so that the evaluation can
+be re-run offline, and deterministically, with --replay-session.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "opening corpus"))
+		}
+		corpus, err := assistant.ReadCorpus(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var baseline *assistant.EvalReport
+		if *evalBaseline != "" {
+			b, err := os.ReadFile(*evalBaseline)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "reading baseline"))
+			}
+			baseline = new(assistant.EvalReport)
+			if err := json.Unmarshal(b, baseline); err != nil {
+				log.Fatal(errors.Wrap(err, "parsing baseline"))
+			}
+		}
+		usage := new(assistant.Usage)
+		var model assistant.Model
+		var session *assistant.Session
+		switch {
+		case *replaySession != "" && *recordSession != "":
+			log.Fatal("--record-session and --replay-session are mutually exclusive")
+		case *replaySession != "":
+			b, err := os.ReadFile(*replaySession)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "reading session"))
+			}
+			var s assistant.Session
+			if err := json.Unmarshal(b, &s); err != nil {
+				log.Fatal(errors.Wrap(err, "parsing session"))
+			}
+			model = assistant.NewReplayModel(&s)
+		case *assistantModel == "":
+			log.Fatal("--assistant-model or --replay-session must be provided")
+		default:
+			models, err := newAssistant(ctx, usage)
+			if err != nil {
+				log.Fatal(err)
+			}
+			model = models.For(assistant.TriageTask)
+			if *recordSession != "" {
+				session = new(assistant.Session)
+				model = &assistant.RecordingModel{Model: model, Session: session}
+			}
+		}
+		label := *evalLabel
+		if label == "" {
+			label = *assistantModel
+		}
+		report, err := assistant.Evaluate(ctx, model, label, corpus)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if session != nil {
+			b, err := json.MarshalIndent(session, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := os.WriteFile(*recordSession, b, 0644); err != nil {
+				log.Fatal(errors.Wrap(err, "writing session"))
+			}
+		}
+		w := cmd.OutOrStdout()
+		switch *format {
+		case "summary":
+			for _, g := range report.Grades {
+				if g.Error != "" {
+					fmt.Fprintf(w, "%.2f %s: %s\n", g.Score, g.ID, g.Error)
+				} else {
+					fmt.Fprintf(w, "%.2f %s: [%s] %s\n", g.Score, g.ID, g.Category, g.Summary)
+				}
+			}
+			fmt.Fprintf(w, "Score %.3f, category accuracy %.3f, %d errors over %d cases\n", report.Score, report.CategoryAccuracy, report.Errors, len(report.Grades))
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("Unknown --format type: %s", *format)
+		}
+		if usage.Requests.Load() > 0 {
+			log.Println("Consumed " + usage.String())
+		}
+		if baseline == nil {
+			return
+		}
+		c := assistant.Compare(baseline, report)
+		log.Printf("Score changed by %+.3f against %s; %d improved, %d regressed, %d added, %d removed", c.Delta, *evalBaseline, len(c.Improvements), len(c.Regressions), len(c.Added), len(c.Removed))
+		for _, r := range c.Regressions {
+			log.Printf("  regressed %s: %.2f -> %.2f", r.ID, r.Baseline, r.Candidate)
+		}
+		if len(c.Regressions) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var backfillStats = &cobra.Command{
+	Use:   "backfill-stats -project <ID>",
+	Short: "Compute the aggregate reproducibility statistics from the status index",
+	Long: `Compute the aggregate reproducibility statistics from the status index.
+
+The API maintains the statistics served by /stats as outcomes are recorded.
+This computes them for the outcomes recorded before that and should be run
+once, before deploying an API which maintains them.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		fireClient, err := newFirestoreClient(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		totals, err := fireClient.BackfillStats(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for eco, s := range totals {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %d of %d reproducible\n", eco, s.Reproducible, s.Attempted)
+		}
+	},
+}
+
+var explainDiff = &cobra.Command{
+	Use:   "explain-diff --assistant-model <model> (<rebuilt-file> <upstream-file> | -project <ID> -run <ID> --debug-bucket <bucket> --ecosystem <ecosystem> --package <name> --version <version> --artifact <name> --entry <path>) [--format=summary|json]",
+	Short: "Explain the differences between a rebuilt and upstream file using a language model",
+	Long: `Explain the differences between a rebuilt and upstream file using a language model.
+
+Each difference is attributed to a known cause, such as a tool version,
+signing, or ordering, along with the stabilizers which would remove it. The
+files are either provided directly or read as --entry from the rebuilt and
+upstream artifacts of a run.`,
+	Args: cobra.RangeArgs(0, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if len(args) == 1 {
+			log.Fatal("both a rebuilt and an upstream file must be provided")
+		}
+		if *assistantModel == "" {
+			log.Fatal("--assistant-model must be provided")
+		}
+		var name string
+		var rebuilt, upstream []byte
+		if len(args) == 2 {
+			var err error
+			if rebuilt, err = os.ReadFile(args[0]); err != nil {
+				log.Fatal(err)
+			}
+			if upstream, err = os.ReadFile(args[1]); err != nil {
+				log.Fatal(err)
+			}
+			name = args[1]
+		} else {
+			if *debugBucket == "" || *runFlag == "" || *entry == "" || *ecosystem == "" || *pkg == "" || *version == "" || *artifact == "" {
+				log.Fatal("without files, --debug-bucket, --run, --entry, --ecosystem, --package, --version, and --artifact must be provided")
+			}
+			bucket := *debugBucket
+			if !strings.Contains(bucket, "://") {
+				bucket = "gs://" + bucket
+			}
+			gcsOpts, err := googleClientOptions(ctx)
+			if err != nil {
+				log.Fatal(err)
+			}
+			ctx := context.WithValue(context.WithValue(ctx, rebuild.GCSClientOptionsID, gcsOpts), rebuild.RunID, *runFlag)
+			store, err := rebuild.NewAssetStoreFromURL(ctx, bucket)
+			if err != nil {
+				log.Fatal(errors.Wrap(err, "creating asset store"))
+			}
+			t := rebuild.Target{Ecosystem: rebuild.Ecosystem(*ecosystem), Package: *pkg, Version: *version, Artifact: *artifact}
+			for _, a := range []struct {
+				typ rebuild.AssetType
+				dst *[]byte
+			}{
+				{rebuild.DebugRebuildAsset, &rebuilt},
+				{rebuild.DebugUpstreamAsset, &upstream},
+			} {
+				b, err := readAsset(ctx, store, rebuild.Asset{Target: t, Type: a.typ})
+				if err != nil {
+					log.Fatal(errors.Wrapf(err, "reading %s", a.typ))
+				}
+				entries, err := archive.ReadEntries(bytes.NewReader(b), t.ArchiveType(), []string{*entry})
+				if err != nil {
+					log.Fatal(errors.Wrapf(err, "reading %s entries", a.typ))
+				}
+				var ok bool
+				if *a.dst, ok = entries[*entry]; !ok {
+					log.Fatalf("%s not found in the %s artifact", *entry, a.typ)
+				}
+			}
+			name = *entry
+		}
+		models, err := newAssistant(ctx, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		m, err := models.ToolsFor(assistant.ExplainDiffTask)
+		if err != nil {
+			log.Fatal(err)
+		}
+		e, err := assistant.ExplainDiff(ctx, m, name, rebuilt, upstream)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := cmd.OutOrStdout()
+		switch *format {
+		case "summary":
+			assistant.FormatExplanation(w, e)
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(e); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("Unknown --format type: %s", *format)
+		}
+	},
+}
+
+var indexFailures = &cobra.Command{
+	Use:   "index-failures -project <ID> -run <ID> [--failure-index <path>] [--embedding-model <model>]",
+	Short: "Add the failures of runs to the index searched for similar past failures",
+	Long: `Add the failures of runs to the index searched for similar past failures.
+
+Failures are resolved by the first later success of the same target among the
+indexed runs, so runs should be indexed as they complete.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		req, err := buildFetchRebuildRequest(ctx, "", *runFlag, "", false)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fireClient, err := newFirestoreClient(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rebuilds, err := fireClient.FetchRebuilds(ctx, req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		index, path, embedder, err := openFailureIndex(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var rs []firestore.Rebuild
+		for _, r := range rebuilds {
+			rs = append(rs, r)
+		}
+		added, err := index.Add(ctx, embedder, rs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resolved := index.Resolve(rs)
+		if err := index.Save(path); err != nil {
+			log.Fatal(errors.Wrap(err, "saving failure index"))
+		}
+		log.Printf("Indexed %d failures and resolved %d, %d total in %s", added, resolved, len(index.Entries), path)
+	},
+}
+
+var similarFailures = &cobra.Command{
+	Use:   "similar-failures -project <ID> -run <ID> --ecosystem <ecosystem> --package <name> --version <version> [--similar N]",
+	Short: "Show the indexed past failures most similar to a failed rebuild and how they were resolved",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *ecosystem == "" || *pkg == "" || *version == "" {
+			log.Fatal("--ecosystem, --package, and --version must be provided")
+		}
+		req, err := buildFetchRebuildRequest(ctx, "", *runFlag, "", false)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fireClient, err := newFirestoreClient(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rebuilds, err := fireClient.FetchRebuilds(ctx, req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var failure *firestore.Rebuild
+		for _, r := range rebuilds {
+			if r.Ecosystem == *ecosystem && r.Package == *pkg && r.Version == *version && !r.Success {
+				r := r
+				failure = &r
+				break
+			}
+		}
+		if failure == nil {
+			log.Fatalf("No failed rebuild of %s %s@%s found in run %s", *ecosystem, *pkg, *version, *runFlag)
+		}
+		index, _, embedder, err := openFailureIndex(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		matches, err := index.Similar(ctx, embedder, *failure, *numSimilar)
+		if err != nil {
+			log.Fatal(err)
+		}
+		failures.Format(cmd.OutOrStdout(), matches)
+	},
+}
+
+var migrateIndex = &cobra.Command{
+	Use:   "migrate-index --attestation-bucket <bucket> [--ecosystem <ecosystem> [--package <name>]]",
+	Short: "Write the per-package index files of the v2 attestation layout from the existing bundles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		if *attestationBucket == "" {
+			log.Fatal("--attestation-bucket must be provided")
+		}
+		if *pkg != "" && *ecosystem == "" {
+			log.Fatal("--package requires --ecosystem")
+		}
+		gcsOpts, err := googleClientOptions(ctx)
+		if err != nil {
+			log.Fatal(err)
 		}
+		client, err := gcs.NewClient(ctx, gcsOpts...)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "initializing GCS client"))
+		}
+		query := &gcs.Query{}
+		if *ecosystem != "" {
+			query.Prefix = path.Join(*ecosystem, *pkg) + "/"
+		}
+		if err := query.SetAttrSelection([]string{"Name", "Created"}); err != nil {
+			log.Fatal(err)
+		}
+		var bundles []verifier.IndexedBundle
+		it := client.Bucket(*attestationBucket).Objects(ctx, query)
+		for {
+			obj, err := it.Next()
+			if err == iterator.Done {
+				break
+			} else if err != nil {
+				log.Fatal(errors.Wrap(err, "listing bundles"))
+			}
+			t, ok := verifier.BundleTarget(obj.Name)
+			if !ok || (*pkg != "" && t.Package != *pkg) {
+				continue
+			}
+			bundles = append(bundles, verifier.IndexedBundle{
+				Target:     t,
+				IndexEntry: verifier.IndexEntry{Bundle: fmt.Sprintf("gs://%s/%s", *attestationBucket, obj.Name), Published: obj.Created.UTC()},
+			})
+		}
+		store, err := rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, ""), "gs://"+*attestationBucket)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "initializing attestation store"))
+		}
+		n, err := verifier.RebuildIndexes(ctx, store, bundles)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Wrote %d package indexes from %d bundles", n, len(bundles))
 	},
 }
 
 var (
 	// Shared
-	api = flag.String("api", "", "OSS Rebuild API endpoint URI")
+	api                       = flag.String("api", "", "OSS Rebuild API endpoint URI")
+	readOnly                  = flag.Bool("read-only", false, "disallow commands and TUI actions that write runs, annotations, or build definitions or that trigger rebuilds")
+	impersonateServiceAccount = flag.String("impersonate-service-account", "", "the service account whose credentials are used for Google Cloud APIs, e.g. one granted only viewer roles")
 	// run-bench
 	maxConcurrency = flag.Int("max-concurrency", 90, "maximum number of inflight requests")
 	buildLocal     = flag.Bool("local", false, "true if this request is going direct to build-local (not through API first)")
+	metricsPort    = flag.Int("metrics-port", 0, "if provided, the port on which to serve benchmark metrics at /metrics")
+	// run-bench, resume
+	progressDirFlag = flag.String("progress-dir", "", "the directory in which benchmark run progress is recorded. Defaults to the user cache directory")
 	// get-results
 	runFlag         = flag.String("run", "", "the run(s) from which to fetch results")
 	bench           = flag.String("bench", "", "a path to a benchmark file. if provided, only results from that benchmark will be fetched")
+	benchRepo       = flag.String("bench-repo", "", "if provided, the git repository from which benchmarks are read instead of the local filesystem")
+	benchRef        = flag.String("bench-ref", "HEAD", "the branch, tag, or commit of the benchmark repository from which benchmarks are read")
 	format          = flag.String("format", "summary", "the format to be printed. Options: summary, bench")
 	filter          = flag.String("filter", "", "a verdict message (or prefix) which will restrict the returned results")
 	sample          = flag.Int("sample", -1, "if provided, only N results will be displayed")
+	failuresOnly    = flag.Bool("failures", false, "whether to only include the targets of failed rebuilds")
+	refreshVersions = flag.Int("versions", 0, "the number of versions of each package to select when refreshing. Defaults to the number currently in the benchmark")
 	project         = flag.String("project", "", "the project from which to fetch the Firestore data")
 	clean           = flag.Bool("clean", false, "whether to apply normalization heuristics to group similar verdicts")
 	debugBucket     = flag.String("debug-bucket", "", "the gcs bucket to find debug logs and artifacts")
 	strategyPath    = flag.String("strategy", "", "the strategy file to use")
 	useStrategyRepo = flag.Bool("strategy-from-repo", false, "whether to lookup and use the strategy from the server-configured repo")
+	bypassCache     = flag.Bool("bypass-build-cache", false, "whether to execute attest mode builds even if the result of an identical build is cached")
+	allArtifacts    = flag.Bool("all-artifacts", false, "whether attest mode builds also rebuild and attest the other artifacts of each version")
+	noInstallHooks  = flag.Bool("disable-install-hooks", false, "whether to install the dependencies of npm and PyPI builds without running their install hooks")
+	// tui, dev, replay, diff-versions
+	dependencyCache  = flag.String("dependency-cache", "", "if provided, the name of the persistent dependency cache volumes to mount, read-only, into the local rebuilder")
+	populateDepCache = flag.Bool("populate-dependency-cache", false, "whether to mount the --dependency-cache volumes writable so that they are filled by the rebuilds executed")
+	containerRuntime = flag.String("container-runtime", "docker", "the container runtime used to run services locally. Options: docker, podman, nerdctl")
+	localWorkers     = flag.Int("local-workers", 1, "the number of local rebuilds the TUI executes concurrently, each in its own rebuilder container served on successive host ports from 8080")
+	// tui
+	columns               = flag.String("columns", strings.Join(ide.DefaultColumns, ","), "the comma-separated columns shown for each rebuild in the TUI, from: "+strings.Join(ide.ColumnNames(), ", "))
+	sortBy                = flag.String("sort-by", "", "the column by which the TUI initially sorts rebuilds. If empty, rebuilds are shown in the order fetched")
+	assistantModel        = flag.String("assistant-model", "", "if provided, the comma-separated Vertex AI models used by the assistant, each tried in turn on context overflow, exhausted quota, or a low-confidence response, e.g. gemini-1.5-flash,gemini-1.5-pro")
+	assistantOverrides    = flag.String("assistant-overrides", "", "the models used for particular assistant tasks in place of --assistant-model, as task=model[,model][;task=...]. Tasks: "+strings.Join(assistant.TaskNames(), ", "))
+	assistantMinLogprob   = flag.Float64("assistant-min-logprob", 0, "if nonzero, the least average token log probability of an assistant response, below which the next model is tried")
+	assistantLocation     = flag.String("assistant-location", "us-central1", "the Vertex AI location serving --assistant-model")
+	assistantLogStrategy  = flag.String("assistant-log-strategy", string(assistant.DefaultLogConfig.Strategy), "how logs exceeding --assistant-context-bytes are provided to --assistant-model. Options: tail, map-reduce")
+	assistantContextBytes = flag.Int("assistant-context-bytes", assistant.DefaultLogConfig.ContextBytes, fmt.Sprintf("the most log content provided to --assistant-model in a single request. Use %d for models without long context", assistant.DefaultContextBytes))
+	// ai-eval
+	evalBaseline  = flag.String("baseline", "", "if provided, the JSON report of a previous evaluation against which regressions are reported")
+	evalLabel     = flag.String("label", "", "the label identifying the evaluated model or prompt in the report. Defaults to --assistant-model")
+	recordSession = flag.String("record-session", "", "if provided, the file to which the model's responses are saved for replay")
+	replaySession = flag.String("replay-session", "", "if provided, the file of recorded responses from which the evaluation is replayed in place of a model")
+	// explain-diff
+	entry = flag.String("entry", "", "the path of the archive entry to compare")
+	// ai-triage
+	promptTokenCost = flag.Float64("prompt-token-cost", 0, "the cost in dollars per million prompt tokens of --assistant-model, used to report the cost of a job")
+	outputTokenCost = flag.Float64("output-token-cost", 0, "the cost in dollars per million output tokens of --assistant-model, used to report the cost of a job")
+	// tui, index-failures, similar-failures
+	failureIndexFlag = flag.String("failure-index", "", "the file in which past failures are indexed. Defaults to the user cache directory")
+	embeddingModel   = flag.String("embedding-model", "text-embedding-004", "the Vertex AI model with which failures are embedded, served from --assistant-location")
+	numSimilar       = flag.Int("similar", 5, "the number of similar past failures shown")
 
 	ecosystem = flag.String("ecosystem", "", "the ecosystem")
 	pkg       = flag.String("package", "", "the package name")
 	version   = flag.String("version", "", "the version of the package")
 	artifact  = flag.String("artifact", "", "the artifact name")
+	// annotate
+	link = flag.String("link", "", "a URL providing further context for an annotation, such as an upstream issue")
+	// lint
+	checkURLs = flag.Bool("check-urls", false, "whether to check that the URLs referenced by the build definition are reachable")
+	// freshness
+	checkSources = flag.Bool("check-sources", false, "whether to check that the source repositories recorded in bundles remain available")
+	// promote
+	buildDefRepo    = flag.String("build-def-repo", "", "the path to a local checkout of the build definition repository")
+	buildDefRepoDir = flag.String("build-def-repo-dir", ".", "relpath within the build definitions repository")
+	promoter        = flag.String("promoter", "", "the identity to which a promotion is attributed, as \"name <email>\". Defaults to the configured git user")
+	// tui, run-bench, resume
+	hooksDir = flag.String("hooks-dir", hooks.DefaultDir(), "the directory containing the executables run on events, in a subdirectory named for each event type")
+	// suggest-stabilizers
+	minTargets = flag.Int("min-targets", 3, "the minimum number of targets exhibiting a difference for a stabilizer to be suggested")
+	// gen-golden
+	goldenRoot = flag.String("golden-root", "pkg/rebuild", "the directory containing the ecosystem packages under which golden fixtures are written")
+	// migrate-index
+	attestationBucket = flag.String("attestation-bucket", "", "the gcs bucket to which rebuild attestations are published")
+	// dev
+	devPort    = flag.Int("port", 8080, "the host port on which to serve the local API")
+	devDataDir = flag.String("data-dir", "", "the directory in which the local stack stores rebuild metadata, attestations, and its signing key. Defaults to a directory in the user cache directory")
 )
 
 func init() {
 	runBenchmark.Flags().AddGoFlag(flag.Lookup("api"))
 	runBenchmark.Flags().AddGoFlag(flag.Lookup("max-concurrency"))
 	runBenchmark.Flags().AddGoFlag(flag.Lookup("local"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("dependency-cache"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("populate-dependency-cache"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("container-runtime"))
 	runBenchmark.Flags().AddGoFlag(flag.Lookup("format"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("metrics-port"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("progress-dir"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("bypass-build-cache"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("all-artifacts"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("disable-install-hooks"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("bench-repo"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("bench-ref"))
+	runBenchmark.Flags().AddGoFlag(flag.Lookup("hooks-dir"))
+
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("api"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("max-concurrency"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("container-runtime"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("format"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("metrics-port"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("progress-dir"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("bypass-build-cache"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("all-artifacts"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("disable-install-hooks"))
+	resumeBenchmark.Flags().AddGoFlag(flag.Lookup("hooks-dir"))
 
 	runOne.Flags().AddGoFlag(flag.Lookup("api"))
 	runOne.Flags().AddGoFlag(flag.Lookup("strategy"))
 	runOne.Flags().AddGoFlag(flag.Lookup("strategy-from-repo"))
+	runOne.Flags().AddGoFlag(flag.Lookup("bypass-build-cache"))
+	runOne.Flags().AddGoFlag(flag.Lookup("all-artifacts"))
+	runOne.Flags().AddGoFlag(flag.Lookup("disable-install-hooks"))
 	runOne.Flags().AddGoFlag(flag.Lookup("ecosystem"))
 	runOne.Flags().AddGoFlag(flag.Lookup("package"))
 	runOne.Flags().AddGoFlag(flag.Lookup("version"))
@@ -700,15 +2645,197 @@ func init() {
 	tui.Flags().AddGoFlag(flag.Lookup("project"))
 	tui.Flags().AddGoFlag(flag.Lookup("clean"))
 	tui.Flags().AddGoFlag(flag.Lookup("debug-bucket"))
+	tui.Flags().AddGoFlag(flag.Lookup("container-runtime"))
+	tui.Flags().AddGoFlag(flag.Lookup("dependency-cache"))
+	tui.Flags().AddGoFlag(flag.Lookup("local-workers"))
+	tui.Flags().AddGoFlag(flag.Lookup("api"))
+	tui.Flags().AddGoFlag(flag.Lookup("hooks-dir"))
+	tui.Flags().AddGoFlag(flag.Lookup("columns"))
+	tui.Flags().AddGoFlag(flag.Lookup("sort-by"))
+	tui.Flags().AddGoFlag(flag.Lookup("assistant-model"))
+	tui.Flags().AddGoFlag(flag.Lookup("assistant-location"))
+	tui.Flags().AddGoFlag(flag.Lookup("assistant-overrides"))
+	tui.Flags().AddGoFlag(flag.Lookup("assistant-min-logprob"))
+	tui.Flags().AddGoFlag(flag.Lookup("assistant-log-strategy"))
+	tui.Flags().AddGoFlag(flag.Lookup("assistant-context-bytes"))
+	tui.Flags().AddGoFlag(flag.Lookup("failure-index"))
+	tui.Flags().AddGoFlag(flag.Lookup("embedding-model"))
 
 	listRuns.Flags().AddGoFlag(flag.Lookup("project"))
 	listRuns.Flags().AddGoFlag(flag.Lookup("bench"))
 
+	benchVersions.Flags().AddGoFlag(flag.Lookup("bench-repo"))
+	benchVersions.Flags().AddGoFlag(flag.Lookup("bench-ref"))
+
 	rootCmd.AddCommand(runBenchmark)
+	rootCmd.AddCommand(resumeBenchmark)
 	rootCmd.AddCommand(runOne)
 	rootCmd.AddCommand(getResults)
 	rootCmd.AddCommand(tui)
 	rootCmd.AddCommand(listRuns)
+	rootCmd.AddCommand(benchVersions)
+
+	submitBatch.Flags().AddGoFlag(flag.Lookup("api"))
+	batchStatus.Flags().AddGoFlag(flag.Lookup("api"))
+	batchStatus.Flags().AddGoFlag(flag.Lookup("format"))
+	submitSBOM.Flags().AddGoFlag(flag.Lookup("api"))
+	rootCmd.AddCommand(submitBatch)
+	rootCmd.AddCommand(batchStatus)
+	rootCmd.AddCommand(submitSBOM)
+
+	annotate.Flags().AddGoFlag(flag.Lookup("api"))
+	annotate.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	annotate.Flags().AddGoFlag(flag.Lookup("package"))
+	annotate.Flags().AddGoFlag(flag.Lookup("version"))
+	annotate.Flags().AddGoFlag(flag.Lookup("artifact"))
+	annotate.Flags().AddGoFlag(flag.Lookup("link"))
+	rootCmd.AddCommand(annotate)
+
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("api"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("package"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("version"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("artifact"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("run"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("strategy"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("build-def-repo"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("build-def-repo-dir"))
+	promoteCmd.Flags().AddGoFlag(flag.Lookup("promoter"))
+	rootCmd.AddCommand(promoteCmd)
+
+	lintCmd.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	lintCmd.Flags().AddGoFlag(flag.Lookup("package"))
+	lintCmd.Flags().AddGoFlag(flag.Lookup("version"))
+	lintCmd.Flags().AddGoFlag(flag.Lookup("artifact"))
+	lintCmd.Flags().AddGoFlag(flag.Lookup("strategy"))
+	lintCmd.Flags().AddGoFlag(flag.Lookup("run"))
+	lintCmd.Flags().AddGoFlag(flag.Lookup("check-urls"))
+	rootCmd.AddCommand(lintCmd)
+
+	genGolden.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	genGolden.Flags().AddGoFlag(flag.Lookup("package"))
+	genGolden.Flags().AddGoFlag(flag.Lookup("version"))
+	genGolden.Flags().AddGoFlag(flag.Lookup("artifact"))
+	genGolden.Flags().AddGoFlag(flag.Lookup("golden-root"))
+	rootCmd.AddCommand(genGolden)
+
+	requeue.Flags().AddGoFlag(flag.Lookup("project"))
+	requeue.Flags().AddGoFlag(flag.Lookup("filter"))
+	requeue.Flags().AddGoFlag(flag.Lookup("max-concurrency"))
+	rootCmd.AddCommand(requeue)
+
+	benchFromRun.Flags().AddGoFlag(flag.Lookup("project"))
+	benchFromRun.Flags().AddGoFlag(flag.Lookup("run"))
+	benchFromRun.Flags().AddGoFlag(flag.Lookup("bench"))
+	benchFromRun.Flags().AddGoFlag(flag.Lookup("filter"))
+	benchFromRun.Flags().AddGoFlag(flag.Lookup("clean"))
+	benchFromRun.Flags().AddGoFlag(flag.Lookup("failures"))
+	benchCmd.AddCommand(benchDiff)
+	benchCmd.AddCommand(benchMerge)
+	benchCmd.AddCommand(benchIntersect)
+	benchCmd.AddCommand(benchSubtract)
+	benchRefresh.Flags().AddGoFlag(flag.Lookup("versions"))
+	benchCmd.AddCommand(benchFromRun)
+	benchCmd.AddCommand(benchRefresh)
+	rootCmd.AddCommand(benchCmd)
+
+	devUp.Flags().AddGoFlag(flag.Lookup("port"))
+	devUp.Flags().AddGoFlag(flag.Lookup("container-runtime"))
+	devUp.Flags().AddGoFlag(flag.Lookup("dependency-cache"))
+	devUp.Flags().AddGoFlag(flag.Lookup("data-dir"))
+	devDown.Flags().AddGoFlag(flag.Lookup("container-runtime"))
+	devCmd.AddCommand(devUp)
+	devCmd.AddCommand(devDown)
+	rootCmd.AddCommand(devCmd)
+
+	replayCmd.Flags().AddGoFlag(flag.Lookup("container-runtime"))
+	rootCmd.AddCommand(replayCmd)
+
+	diffVersionsCmd.Flags().AddGoFlag(flag.Lookup("container-runtime"))
+	diffVersionsCmd.Flags().AddGoFlag(flag.Lookup("format"))
+	rootCmd.AddCommand(diffVersionsCmd)
+
+	freshnessCmd.Flags().AddGoFlag(flag.Lookup("check-sources"))
+	freshnessCmd.Flags().AddGoFlag(flag.Lookup("format"))
+	rootCmd.AddCommand(freshnessCmd)
+
+	suggestStabilizers.Flags().AddGoFlag(flag.Lookup("project"))
+	suggestStabilizers.Flags().AddGoFlag(flag.Lookup("run"))
+	suggestStabilizers.Flags().AddGoFlag(flag.Lookup("bench"))
+	suggestStabilizers.Flags().AddGoFlag(flag.Lookup("filter"))
+	suggestStabilizers.Flags().AddGoFlag(flag.Lookup("debug-bucket"))
+	suggestStabilizers.Flags().AddGoFlag(flag.Lookup("min-targets"))
+	suggestStabilizers.Flags().AddGoFlag(flag.Lookup("format"))
+	rootCmd.AddCommand(suggestStabilizers)
+	aiTriage.Flags().AddGoFlag(flag.Lookup("project"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("run"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("bench"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("filter"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("debug-bucket"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("assistant-model"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("assistant-location"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("assistant-overrides"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("assistant-min-logprob"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("prompt-token-cost"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("output-token-cost"))
+	aiTriage.Flags().AddGoFlag(flag.Lookup("format"))
+	rootCmd.AddCommand(aiTriage)
+	aiEval.Flags().AddGoFlag(flag.Lookup("project"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("assistant-model"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("assistant-location"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("assistant-overrides"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("assistant-min-logprob"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("baseline"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("label"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("record-session"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("replay-session"))
+	aiEval.Flags().AddGoFlag(flag.Lookup("format"))
+	rootCmd.AddCommand(aiEval)
+	explainDiff.Flags().AddGoFlag(flag.Lookup("project"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("run"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("debug-bucket"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("package"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("version"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("artifact"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("entry"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("assistant-model"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("assistant-location"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("assistant-overrides"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("assistant-min-logprob"))
+	explainDiff.Flags().AddGoFlag(flag.Lookup("format"))
+	rootCmd.AddCommand(explainDiff)
+	indexFailures.Flags().AddGoFlag(flag.Lookup("project"))
+	indexFailures.Flags().AddGoFlag(flag.Lookup("run"))
+	indexFailures.Flags().AddGoFlag(flag.Lookup("failure-index"))
+	indexFailures.Flags().AddGoFlag(flag.Lookup("embedding-model"))
+	indexFailures.Flags().AddGoFlag(flag.Lookup("assistant-location"))
+	rootCmd.AddCommand(indexFailures)
+	similarFailures.Flags().AddGoFlag(flag.Lookup("project"))
+	similarFailures.Flags().AddGoFlag(flag.Lookup("run"))
+	similarFailures.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	similarFailures.Flags().AddGoFlag(flag.Lookup("package"))
+	similarFailures.Flags().AddGoFlag(flag.Lookup("version"))
+	similarFailures.Flags().AddGoFlag(flag.Lookup("failure-index"))
+	similarFailures.Flags().AddGoFlag(flag.Lookup("embedding-model"))
+	similarFailures.Flags().AddGoFlag(flag.Lookup("assistant-location"))
+	similarFailures.Flags().AddGoFlag(flag.Lookup("similar"))
+	rootCmd.AddCommand(similarFailures)
+
+	migrateIndex.Flags().AddGoFlag(flag.Lookup("attestation-bucket"))
+	migrateIndex.Flags().AddGoFlag(flag.Lookup("ecosystem"))
+	migrateIndex.Flags().AddGoFlag(flag.Lookup("package"))
+	rootCmd.AddCommand(migrateIndex)
+
+	backfillStats.Flags().AddGoFlag(flag.Lookup("project"))
+	rootCmd.AddCommand(backfillStats)
+
+	rootCmd.PersistentFlags().AddGoFlag(flag.Lookup("read-only"))
+	rootCmd.PersistentFlags().AddGoFlag(flag.Lookup("impersonate-service-account"))
+	rootCmd.PersistentPreRunE = checkReadOnly
+	for _, cmd := range []*cobra.Command{runBenchmark, resumeBenchmark, runOne, requeue, submitBatch, annotate, promoteCmd, submitSBOM, migrateIndex, backfillStats} {
+		cmd.Annotations = map[string]string{writesAnnotation: "true"}
+	}
 }
 
 func main() {
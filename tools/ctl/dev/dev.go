@@ -0,0 +1,253 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dev runs the OSS Rebuild services as a local stack of docker containers.
+package dev
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/oss-rebuild/build/binary"
+	"github.com/google/oss-rebuild/build/container"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/docker"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Network is the docker network shared by the local stack.
+	Network = "oss-rebuild-dev"
+	// Project is the placeholder GCP project used by the local stack.
+	Project = "oss-rebuild-dev"
+
+	firestoreImage = "gcr.io/google.com/cloudsdktool/google-cloud-cli:emulators"
+	firestoreName  = "firestore"
+	firestorePort  = 8081
+
+	// dataMount is where the data directory is mounted in the API container.
+	dataMount = "/data"
+	// signingKeyName is the file in the data directory holding the attestation signing key.
+	signingKeyName = "signing-key.pem"
+)
+
+// Options configures the local stack.
+type Options struct {
+	// Port is the host port on which the API will be served.
+	Port int
+	// Output receives the logs of all containers.
+	Output io.Writer
+	// Runtime is the container runtime used to run the stack. Defaults to docker.
+	Runtime docker.Runtime
+	// DependencyCacheKey, if provided, names the dependency cache volumes mounted into the rebuilder.
+	DependencyCacheKey string
+	// DataDir is the host directory holding the stack's metadata and
+	// attestation stores and its signing key. Defaults to a directory in the
+	// user's cache directory so that results persist across runs.
+	DataDir string
+}
+
+type service struct {
+	name         string
+	port         int
+	args         []string
+	env          []string
+	volumes      []string
+	securityOpts []string
+}
+
+// services returns the project services in the order they should be started.
+func services(opts Options) []service {
+	// NOTE: The rebuilder executes upstream build scripts so it runs sandboxed.
+	rebuilder := service{name: "rebuilder", args: []string{"--user-agent=OSSRebuildLocal/0.0.0"}, securityOpts: rebuild.Sandbox{}.SecurityOpts()}
+	if opts.DependencyCacheKey != "" {
+		rebuilder.args = append(rebuilder.args, "--dependency-cache-key="+opts.DependencyCacheKey)
+		rebuilder.volumes = rebuild.DependencyCacheMounts(opts.DependencyCacheKey, false)
+	}
+	return []service{
+		rebuilder,
+		// NOTE: The runner executes the attested builds, which Cloud Build would otherwise run.
+		{name: "runner", args: []string{"--name=oss-rebuild-dev"}, securityOpts: rebuild.Sandbox{}.SecurityOpts()},
+		{name: "inference", args: []string{"--user-agent=OSSRebuildLocal/0.0.0"}},
+		{
+			name: "api",
+			port: opts.Port,
+			args: []string{
+				"--project=" + Project,
+				"--build-local-url=http://rebuilder:8080",
+				"--inference-url=http://inference:8080",
+				"--linux-runner-url=http://runner:8080",
+				"--user-agent=OSSRebuildLocal/0.0.0",
+				"--build-def-repo=https://github.com/google/oss-rebuild",
+				"--build-def-repo-dir=definitions",
+				"--metadata-bucket=file://" + path.Join(dataMount, "metadata"),
+				"--attestation-bucket=file://" + path.Join(dataMount, "attestations"),
+				"--signing-key-file=" + path.Join(dataMount, signingKeyName),
+				// NOTE: Batch targets are enqueued and delivered back to the API in-process.
+				"--task-queue=memory",
+				"--self-url=http://localhost:8080",
+			},
+			env:     []string{fmt.Sprintf("FIRESTORE_EMULATOR_HOST=%s:%d", firestoreName, firestorePort)},
+			volumes: []string{opts.DataDir + ":" + dataMount},
+		},
+	}
+}
+
+// prepareDataDir creates the data directory and, if absent, the key with which the stack signs attestations.
+func prepareDataDir(dir string) error {
+	for _, sub := range []string{"metadata", "attestations"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return errors.Wrap(err, "creating data directory")
+		}
+	}
+	keyPath := filepath.Join(dir, signingKeyName)
+	if _, err := os.Stat(keyPath); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return errors.Wrap(err, "checking signing key")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return errors.Wrap(err, "generating signing key")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return errors.Wrap(err, "encoding signing key")
+	}
+	return os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+}
+
+// Up builds and runs the local stack, blocking until ctx is cancelled or a container exits.
+//
+// The stack consists of a Firestore emulator along with the api, rebuilder,
+// runner, and inference services which communicate over a dedicated docker
+// network. Only the API is published to the host. Attested rebuilds are
+// executed by the runner, signed with a local key, and stored beneath
+// opts.DataDir so that the full pipeline runs without a GCP project.
+func Up(ctx context.Context, opts Options) error {
+	if opts.Port == 0 {
+		opts.Port = 8080
+	}
+	if opts.DataDir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return errors.Wrap(err, "locating cache directory")
+		}
+		opts.DataDir = filepath.Join(cache, "oss-rebuild-dev")
+	}
+	var err error
+	opts.DataDir, err = filepath.Abs(opts.DataDir)
+	if err != nil {
+		return errors.Wrap(err, "resolving data directory")
+	}
+	if err := prepareDataDir(opts.DataDir); err != nil {
+		return err
+	}
+	if opts.Output == nil {
+		opts.Output = log.Writer()
+	}
+	if opts.Runtime == nil {
+		opts.Runtime = docker.Docker
+	}
+	rt := opts.Runtime
+	svcs := services(opts)
+	for _, svc := range svcs {
+		path, err := binary.Build(ctx, svc.name)
+		if err != nil {
+			return errors.Wrapf(err, "building %s binary", svc.name)
+		}
+		if err := container.BuildWith(ctx, rt, svc.name, path); err != nil {
+			return errors.Wrapf(err, "building %s container", svc.name)
+		}
+	}
+	if err := rt.CreateNetwork(ctx, Network); err != nil {
+		return errors.Wrap(err, "creating network")
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, len(svcs)+1)
+	run := func(img, name string, port int, ro docker.RunOptions) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ro.Name = name
+			ro.Network = Network
+			ro.Output = prefixWriter(opts.Output, name)
+			// NOTE: Cancel the stack once any container exits so a failure is not masked.
+			errs <- errors.Wrapf(rt.RunServer(runCtx, img, port, &ro), "running %s", name)
+			cancel()
+		}()
+	}
+	run(firestoreImage, firestoreName, 0, docker.RunOptions{
+		Args: []string{"gcloud", "emulators", "firestore", "start", fmt.Sprintf("--host-port=0.0.0.0:%d", firestorePort)},
+	})
+	for _, svc := range svcs {
+		run(svc.name, svc.name, svc.port, docker.RunOptions{Args: svc.args, Env: svc.env, Volumes: svc.volumes, SecurityOpts: svc.securityOpts})
+	}
+	log.Printf("Local stack serving at http://localhost:%d with data in %s", opts.Port, opts.DataDir)
+	wg.Wait()
+	close(errs)
+	if err := Down(context.WithoutCancel(ctx), rt); err != nil {
+		log.Printf("Error tearing down local stack: %v", err)
+	}
+	if ctx.Err() != nil {
+		// The stack was stopped by the caller.
+		return nil
+	}
+	return <-errs
+}
+
+// Down stops all containers in the local stack and removes its network.
+func Down(ctx context.Context, rt docker.Runtime) error {
+	names := []string{firestoreName}
+	for _, svc := range services(Options{}) {
+		names = append(names, svc.name)
+	}
+	for _, name := range names {
+		// NOTE: Errors are expected for containers that are not running.
+		rt.Kill(ctx, name)
+	}
+	if err := rt.RemoveNetwork(ctx, Network); err != nil {
+		return errors.Wrap(err, "removing network")
+	}
+	return nil
+}
+
+type prefixedWriter struct {
+	w      io.Writer
+	prefix []byte
+}
+
+func (p prefixedWriter) Write(b []byte) (int, error) {
+	if _, err := p.w.Write(append(append([]byte{}, p.prefix...), b...)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func prefixWriter(w io.Writer, name string) io.Writer {
+	return prefixedWriter{w: w, prefix: []byte(fmt.Sprintf("[%s] ", name))}
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dev
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPrepareDataDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := prepareDataDir(dir); err != nil {
+		t.Fatalf("prepareDataDir() returned error: %v", err)
+	}
+	for _, sub := range []string{"metadata", "attestations"} {
+		if fi, err := os.Stat(filepath.Join(dir, sub)); err != nil || !fi.IsDir() {
+			t.Errorf("%s directory not created: %v", sub, err)
+		}
+	}
+	keyPath := filepath.Join(dir, signingKeyName)
+	b, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("reading signing key: %v", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatal("signing key is not PEM-encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing signing key: %v", err)
+	}
+	if _, ok := key.(*ecdsa.PrivateKey); !ok {
+		t.Errorf("signing key type = %T, want *ecdsa.PrivateKey", key)
+	}
+	// The key is retained so attestations from previous runs remain verifiable.
+	if err := prepareDataDir(dir); err != nil {
+		t.Fatalf("prepareDataDir() returned error on reuse: %v", err)
+	}
+	if again, err := os.ReadFile(keyPath); err != nil || !bytes.Equal(again, b) {
+		t.Errorf("signing key replaced on reuse")
+	}
+}
+
+func TestServicesOffline(t *testing.T) {
+	svcs := services(Options{Port: 8080, DataDir: "/tmp/dev"})
+	var names []string
+	var api service
+	for _, svc := range svcs {
+		names = append(names, svc.name)
+		if svc.name == "api" {
+			api = svc
+		}
+	}
+	if !slices.Contains(names, "runner") {
+		t.Errorf("services = %v, want a runner", names)
+	}
+	for _, want := range []string{
+		"--linux-runner-url=http://runner:8080",
+		"--metadata-bucket=file:///data/metadata",
+		"--attestation-bucket=file:///data/attestations",
+		"--signing-key-file=/data/signing-key.pem",
+		"--task-queue=memory",
+	} {
+		if !slices.Contains(api.args, want) {
+			t.Errorf("api args missing %q", want)
+		}
+	}
+	if !slices.Equal(api.volumes, []string{"/tmp/dev:/data"}) {
+		t.Errorf("api volumes = %v, want [/tmp/dev:/data]", api.volumes)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package failures indexes the failures of past rebuilds so that those most
+// similar to a new failure, and how they were resolved, can be found.
+//
+// Each failure is reduced to a Signature of its message and log excerpt with
+// package-specific details removed, which is embedded by a language model.
+// Failures are resolved by the first later successful rebuild of the same
+// target, whose strategy is recorded as the fix.
+package failures
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+)
+
+// Embedder embeds texts as vectors whose similarity reflects that of the texts.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Resolution is a successful rebuild of a target which had previously failed.
+type Resolution struct {
+	Run      string    `json:"run"`
+	Strategy string    `json:"strategy"`
+	Created  time.Time `json:"created"`
+}
+
+// Entry is an indexed failure.
+type Entry struct {
+	// ID identifies the target, as in firestore.Rebuild.ID.
+	ID        string         `json:"id"`
+	Target    rebuild.Target `json:"target"`
+	Run       string         `json:"run"`
+	Created   time.Time      `json:"created"`
+	Message   string         `json:"message"`
+	Signature string         `json:"signature"`
+	Embedding []float32      `json:"embedding"`
+	// Resolution is the first later success of the target, if known.
+	Resolution *Resolution `json:"resolution,omitempty"`
+}
+
+// Index is a collection of failures and their embeddings.
+type Index struct {
+	// Model is the embedding model. Embeddings of different models are not comparable.
+	Model   string  `json:"model"`
+	Entries []Entry `json:"entries"`
+}
+
+// Open reads the index at path, returning an empty one if none exists.
+func Open(path, model string) (*Index, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Index{Model: model}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading index")
+	}
+	var x Index
+	if err := json.Unmarshal(b, &x); err != nil {
+		return nil, errors.Wrap(err, "parsing index")
+	}
+	if x.Model != model {
+		return nil, errors.Errorf("index was embedded by %s, not %s", x.Model, model)
+	}
+	return &x, nil
+}
+
+// Save writes the index to path.
+func (x *Index) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	// NOTE: Write and rename so an interrupted save does not lose the existing index.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// maxSignatureBytes keeps signatures within the input limit of embedding models.
+const maxSignatureBytes = 6 << 10
+
+var hash = regexp.MustCompile(`\b[0-9a-f]{12,}\b`)
+
+// Signature returns the text embedded for a failure, its message and log
+// excerpt, with the details specific to the target removed.
+func Signature(r firestore.Rebuild) string {
+	s := strings.TrimSpace(r.Message)
+	if snippet := strings.TrimSpace(r.FailureSnippet); snippet != "" {
+		s += "\n" + snippet
+	}
+	if r.Package != "" {
+		s = strings.ReplaceAll(s, r.Package, "{package}")
+	}
+	if r.Version != "" {
+		s = strings.ReplaceAll(s, r.Version, "{version}")
+	}
+	s = hash.ReplaceAllString(s, "{hash}")
+	if len(s) > maxSignatureBytes {
+		s = s[:maxSignatureBytes]
+	}
+	return s
+}
+
+// Add embeds and indexes the failures among rebuilds which are not yet
+// indexed, returning the number added.
+func (x *Index) Add(ctx context.Context, em Embedder, rebuilds []firestore.Rebuild) (int, error) {
+	type key struct{ id, run string }
+	indexed := make(map[key]bool)
+	for _, e := range x.Entries {
+		indexed[key{e.ID, e.Run}] = true
+	}
+	var added []Entry
+	var texts []string
+	for _, r := range rebuilds {
+		k := key{r.ID(), r.Run}
+		if r.Success || indexed[k] {
+			continue
+		}
+		indexed[k] = true
+		e := Entry{ID: r.ID(), Target: r.Target(), Run: r.Run, Created: r.Created, Message: r.Message, Signature: Signature(r)}
+		added = append(added, e)
+		texts = append(texts, e.Signature)
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+	vecs, err := em.Embed(ctx, texts)
+	if err != nil {
+		return 0, errors.Wrap(err, "embedding failures")
+	} else if len(vecs) != len(added) {
+		return 0, errors.Errorf("embedded %d of %d failures", len(vecs), len(added))
+	}
+	for i := range added {
+		added[i].Embedding = vecs[i]
+	}
+	x.Entries = append(x.Entries, added...)
+	return len(added), nil
+}
+
+// Resolve records the first success among rebuilds after each indexed
+// failure of the same target, returning the number of entries updated.
+func (x *Index) Resolve(rebuilds []firestore.Rebuild) int {
+	successes := make(map[string][]firestore.Rebuild)
+	for _, r := range rebuilds {
+		if r.Success {
+			successes[r.ID()] = append(successes[r.ID()], r)
+		}
+	}
+	for _, s := range successes {
+		slices.SortFunc(s, func(a, b firestore.Rebuild) int { return a.Created.Compare(b.Created) })
+	}
+	var n int
+	for i := range x.Entries {
+		e := &x.Entries[i]
+		for _, s := range successes[e.ID] {
+			if !s.Created.After(e.Created) {
+				continue
+			}
+			if e.Resolution == nil || s.Created.Before(e.Resolution.Created) {
+				e.Resolution = &Resolution{Run: s.Run, Strategy: s.Strategy, Created: s.Created}
+				n++
+			}
+			break
+		}
+	}
+	return n
+}
+
+// Match is an indexed failure similar to the one searched for.
+type Match struct {
+	Entry
+	// Score is the cosine similarity of the failures, at most 1.
+	Score float64
+}
+
+// Similar returns the k indexed failures most similar to that of r,
+// excluding those of r's own target.
+func (x *Index) Similar(ctx context.Context, em Embedder, r firestore.Rebuild, k int) ([]Match, error) {
+	vecs, err := em.Embed(ctx, []string{Signature(r)})
+	if err != nil {
+		return nil, errors.Wrap(err, "embedding failure")
+	}
+	var matches []Match
+	for _, e := range x.Entries {
+		if e.ID == r.ID() {
+			continue
+		}
+		matches = append(matches, Match{Entry: e, Score: cosine(vecs[0], e.Embedding)})
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int { return cmp.Compare(b.Score, a.Score) })
+	return matches[:min(k, len(matches))], nil
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// Format writes a human-readable listing of the matches.
+func Format(w io.Writer, matches []Match) {
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "No similar failures indexed.")
+		return
+	}
+	for _, m := range matches {
+		fmt.Fprintf(w, "%.3f %s %s %s (run %s)\n", m.Score, m.Target.Ecosystem, m.Target.Package, m.Target.Version, m.Run)
+		fmt.Fprintf(w, "      %s\n", firstLine(m.Message))
+		if m.Resolution == nil {
+			fmt.Fprintln(w, "      unresolved")
+			continue
+		}
+		fmt.Fprintf(w, "      resolved in run %s with strategy:\n", m.Resolution.Run)
+		strategy := m.Resolution.Strategy
+		var buf bytes.Buffer
+		if json.Indent(&buf, []byte(strategy), "", "  ") == nil {
+			strategy = buf.String()
+		}
+		for _, line := range strings.Split(strings.TrimSpace(strategy), "\n") {
+			fmt.Fprintf(w, "        %s\n", line)
+		}
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failures
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+)
+
+// keywordEmbedder embeds texts by the presence of each keyword.
+type keywordEmbedder []string
+
+func (k keywordEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	var out [][]float32
+	for _, t := range texts {
+		v := make([]float32, len(k))
+		for i, kw := range k {
+			if strings.Contains(t, kw) {
+				v[i] = 1
+			}
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func TestSignature(t *testing.T) {
+	r := firestore.Rebuild{
+		Package:        "left-pad",
+		Version:        "1.3.0",
+		Message:        "build failed for left-pad@1.3.0",
+		FailureSnippet: "checkout 0123456789abcdef failed",
+	}
+	want := "build failed for {package}@{version}\ncheckout {hash} failed"
+	if got := Signature(r); got != want {
+		t.Errorf("Signature() = %q, want %q", got, want)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	em := keywordEmbedder{"node-gyp", "timeout", "checksum"}
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	rebuilds := []firestore.Rebuild{
+		{Ecosystem: "npm", Package: "a", Version: "1", Run: "r1", Created: day(1), Message: "node-gyp rebuild failed"},
+		{Ecosystem: "npm", Package: "b", Version: "1", Run: "r1", Created: day(1), Message: "network timeout"},
+		{Ecosystem: "npm", Package: "c", Version: "1", Run: "r1", Created: day(1), Message: "node-gyp and timeout"},
+		{Ecosystem: "npm", Package: "d", Version: "1", Run: "r1", Created: day(1), Success: true},
+		{Ecosystem: "npm", Package: "a", Version: "1", Run: "r2", Created: day(3), Success: true, Strategy: "fixed"},
+		{Ecosystem: "npm", Package: "a", Version: "1", Run: "r3", Created: day(2), Success: true, Strategy: "first fix"},
+	}
+	x := &Index{Model: "test"}
+	if n, err := x.Add(ctx, em, rebuilds); err != nil || n != 3 {
+		t.Fatalf("Add() = %d, %v, want 3 failures added", n, err)
+	}
+	if n, err := x.Add(ctx, em, rebuilds); err != nil || n != 0 {
+		t.Fatalf("Add() again = %d, %v, want none added", n, err)
+	}
+	if n := x.Resolve(rebuilds); n != 1 {
+		t.Errorf("Resolve() = %d, want 1", n)
+	}
+	if r := x.Entries[0].Resolution; r == nil || r.Run != "r3" || r.Strategy != "first fix" {
+		t.Errorf("Resolution = %+v, want the first later success", r)
+	}
+	query := firestore.Rebuild{Ecosystem: "npm", Package: "c", Version: "1", Message: "node-gyp: not found"}
+	matches, err := x.Similar(ctx, em, query, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range matches {
+		got = append(got, m.Target.Package)
+	}
+	// NOTE: c's own failure is excluded despite matching best.
+	if diff := cmp.Diff([]string{"a", "b"}, got); diff != "" {
+		t.Errorf("Similar() mismatch (-want +got):\n%s", diff)
+	}
+	path := filepath.Join(t.TempDir(), "index", "failures.json")
+	if err := x.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	y, err := Open(path, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(x, y); diff != "" {
+		t.Errorf("Open() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := Open(path, "other"); err == nil {
+		t.Error("Open() with another model succeeded, want error")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firestore
+
+import (
+	"cmp"
+	"context"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/pipe"
+	"github.com/pkg/errors"
+)
+
+// Fake is an in-memory Reader and Writer.
+//
+// Records are stored in their firestore encoding and queries return them in
+// the order firestore would so that code using a Client can be tested
+// without access to GCP.
+type Fake struct {
+	mu sync.Mutex
+	// attempts are keyed by document path.
+	attempts    map[string]schema.SmoketestAttempt
+	runs        map[string]Run
+	annotations []schema.Annotation
+}
+
+var _ Reader = &Fake{}
+var _ Writer = &Fake{}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{attempts: make(map[string]schema.SmoketestAttempt), runs: make(map[string]Run)}
+}
+
+// FetchRebuilds returns the rebuilds matching req.
+func (f *Fake) FetchRebuilds(ctx context.Context, req *FetchRebuildRequest) (map[string]Rebuild, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	var matches []Rebuild
+	// NOTE: Firestore returns the results of a collection group query in document path order.
+	for _, p := range sortedKeys(f.attempts) {
+		sa := f.attempts[p]
+		if len(req.Executors) != 0 && !slices.Contains(req.Executors, sa.ExecutorVersion) {
+			continue
+		}
+		if len(req.Runs) != 0 && !slices.Contains(req.Runs, sa.RunID) {
+			continue
+		}
+		matches = append(matches, newRebuildFromAttempt(sa, p))
+	}
+	f.mu.Unlock()
+	all := make(chan Rebuild)
+	p := pipe.FromContext(ctx, all)
+	go func() {
+		defer close(all)
+		for _, r := range matches {
+			select {
+			case all <- r:
+			case <-p.Context().Done():
+				return
+			}
+		}
+	}()
+	return processRebuilds(p, req)
+}
+
+// FetchHistory returns the rebuilds of the package matching req, grouped by package version.
+func (f *Fake) FetchHistory(ctx context.Context, req *FetchHistoryRequest) ([]History, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var rebuilds []Rebuild
+	for _, p := range sortedKeys(f.attempts) {
+		sa := f.attempts[p]
+		if sa.Package != req.Package || (req.Ecosystem != "" && sa.Ecosystem != req.Ecosystem) || (req.Version != "" && sa.Version != req.Version) {
+			continue
+		}
+		rebuilds = append(rebuilds, newRebuildFromAttempt(sa, p))
+	}
+	return groupHistory(rebuilds), nil
+}
+
+// FetchRuns returns the runs matching opts in order of their IDs.
+func (f *Fake) FetchRuns(ctx context.Context, opts FetchRunsOpts) ([]Run, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	runs := make([]Run, 0, 0)
+	for _, id := range sortedKeys(f.runs) {
+		if r := f.runs[id]; opts.BenchmarkHash == "" || r.BenchmarkHash == opts.BenchmarkHash {
+			runs = append(runs, r)
+		}
+	}
+	return runs, nil
+}
+
+// FetchAnnotations returns the annotations attached to the results of a target, oldest first.
+func (f *Fake) FetchAnnotations(ctx context.Context, t rebuild.Target) ([]schema.Annotation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []schema.Annotation
+	for _, a := range f.annotations {
+		if a.Ecosystem != string(t.Ecosystem) || a.Package != t.Package || a.Version != t.Version {
+			continue
+		}
+		if t.Artifact == "" || a.Artifact == "" || a.Artifact == t.Artifact {
+			out = append(out, a)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b schema.Annotation) int { return cmp.Compare(a.Created, b.Created) })
+	return out, nil
+}
+
+// WriteRun records a run, failing if one with the same ID exists.
+func (f *Fake) WriteRun(ctx context.Context, r Run) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.runs[r.ID]; ok {
+		return errors.Errorf("writing run: run %s already exists", r.ID)
+	}
+	// NOTE: Firestore stores the creation time with millisecond precision.
+	r.Created = time.UnixMilli(r.Created.UnixMilli())
+	if r.Config != nil {
+		cfg := *r.Config
+		r.Config = &cfg
+	}
+	f.runs[r.ID] = r
+	return nil
+}
+
+// WriteRebuild records a rebuild, replacing any previous rebuild of the same
+// package version in its run.
+func (f *Fake) WriteRebuild(ctx context.Context, r Rebuild) error {
+	sa, err := r.attempt()
+	if err != nil {
+		return err
+	}
+	p := path.Join("ecosystem", r.Ecosystem, "packages", sanitize(r.Package), "versions", r.Version, "attempts", r.Run)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts[p] = sa
+	return nil
+}
+
+// WriteAnnotation records an annotation.
+func (f *Fake) WriteAnnotation(ctx context.Context, a schema.Annotation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.annotations = append(f.annotations, a)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
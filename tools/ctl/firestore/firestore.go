@@ -16,7 +16,9 @@
 package firestore
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"slices"
@@ -30,6 +32,7 @@ import (
 	"github.com/google/oss-rebuild/tools/ctl/pipe"
 	"github.com/pkg/errors"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
 // Rebuild represents the result of a specific rebuild.
@@ -45,6 +48,10 @@ type Rebuild struct {
 	Run       string
 	Created   time.Time
 	Timings   rebuild.Timings
+	// Risk is the assessment of a mismatched rebuild's differences, if one was recorded.
+	Risk *rebuild.RiskAssessment
+	// FailureSnippet is the excerpt of the build logs likely to explain a failure, if one was found.
+	FailureSnippet string
 }
 
 // NewRebuildFromFirestore creates a Rebuild instance from a "attempt" collection document.
@@ -53,6 +60,11 @@ func NewRebuildFromFirestore(doc *firestore.DocumentSnapshot) Rebuild {
 	if err := doc.DataTo(&sa); err != nil {
 		panic(err)
 	}
+	return newRebuildFromAttempt(sa, doc.Ref.Path)
+}
+
+// newRebuildFromAttempt creates a Rebuild from the attempt stored at path.
+func newRebuildFromAttempt(sa schema.SmoketestAttempt, path string) Rebuild {
 	var rb Rebuild
 	rb.Ecosystem = sa.Ecosystem
 	rb.Package = sa.Package
@@ -64,13 +76,54 @@ func NewRebuildFromFirestore(doc *firestore.DocumentSnapshot) Rebuild {
 	rb.Run = sa.RunID
 	rb.Created = time.UnixMilli(sa.Created)
 	rb.Artifact = sa.Artifact
+	rb.FailureSnippet = sa.FailureSnippet
 	rb.Timings.CloneEstimate = time.Duration(sa.TimeCloneEstimate * float64(time.Second))
 	rb.Timings.Source = time.Duration(sa.TimeSource * float64(time.Second))
 	rb.Timings.Infer = time.Duration(sa.TimeInfer * float64(time.Second))
 	rb.Timings.Build = time.Duration(sa.TimeBuild * float64(time.Second))
+	if sa.RiskScore > 0 || sa.RiskEvidence != "" {
+		rb.Risk = &rebuild.RiskAssessment{Score: sa.RiskScore}
+		if sa.RiskEvidence != "" {
+			if err := json.Unmarshal([]byte(sa.RiskEvidence), &rb.Risk.Evidence); err != nil {
+				log.Printf("invalid risk evidence for %s: %v", path, err)
+			}
+		}
+	}
 	return rb
 }
 
+// attempt returns the "attempt" collection document recording the Rebuild.
+func (r Rebuild) attempt() (schema.SmoketestAttempt, error) {
+	sa := schema.SmoketestAttempt{
+		Ecosystem:         r.Ecosystem,
+		Package:           r.Package,
+		Version:           r.Version,
+		Artifact:          r.Artifact,
+		Success:           r.Success,
+		Message:           r.Message,
+		Strategy:          r.Strategy,
+		TimeCloneEstimate: r.Timings.CloneEstimate.Seconds(),
+		TimeSource:        r.Timings.Source.Seconds(),
+		TimeInfer:         r.Timings.Infer.Seconds(),
+		TimeBuild:         r.Timings.Build.Seconds(),
+		ExecutorVersion:   r.Executor,
+		RunID:             r.Run,
+		Created:           r.Created.UnixMilli(),
+		FailureSnippet:    r.FailureSnippet,
+	}
+	if r.Risk != nil {
+		sa.RiskScore = r.Risk.Score
+		if len(r.Risk.Evidence) > 0 {
+			evidence, err := json.Marshal(r.Risk.Evidence)
+			if err != nil {
+				return schema.SmoketestAttempt{}, errors.Wrap(err, "encoding risk evidence")
+			}
+			sa.RiskEvidence = string(evidence)
+		}
+	}
+	return sa, nil
+}
+
 func (r Rebuild) Target() rebuild.Target {
 	return rebuild.Target{
 		Ecosystem: rebuild.Ecosystem(r.Ecosystem),
@@ -92,8 +145,29 @@ type Run struct {
 	ID            string
 	BenchmarkName string
 	BenchmarkHash string
-	Type          BenchmarkMode
-	Created       time.Time
+	// BenchmarkRepo and BenchmarkCommit identify the revision from which the benchmark was read, if any.
+	BenchmarkRepo   string
+	BenchmarkCommit string
+	Type            BenchmarkMode
+	Created         time.Time
+	// Config is the service configuration recorded at run creation, if any.
+	Config *schema.RunConfig
+}
+
+// runDocument returns the "runs" collection document recording the Run.
+func (r Run) runDocument() map[string]any {
+	doc := map[string]any{
+		"benchmark_name":   r.BenchmarkName,
+		"benchmark_hash":   r.BenchmarkHash,
+		"benchmark_repo":   r.BenchmarkRepo,
+		"benchmark_commit": r.BenchmarkCommit,
+		"run_type":         string(r.Type),
+		"created":          r.Created.UnixMilli(),
+	}
+	if r.Config != nil {
+		doc["config"] = r.Config
+	}
+	return doc
 }
 
 // NewRunFromFirestore creates a Run instance from a "runs" collection document.
@@ -102,13 +176,32 @@ func NewRunFromFirestore(doc *firestore.DocumentSnapshot) Run {
 	if maybeType, ok := doc.Data()["run_type"]; ok {
 		typ = BenchmarkMode(maybeType.(string))
 	}
+	var snapshot struct {
+		BenchmarkRepo   string            `firestore:"benchmark_repo"`
+		BenchmarkCommit string            `firestore:"benchmark_commit"`
+		Config          *schema.RunConfig `firestore:"config"`
+	}
+	if err := doc.DataTo(&snapshot); err != nil {
+		panic(err)
+	}
 	return Run{
-		ID:            doc.Ref.ID,
-		BenchmarkName: doc.Data()["benchmark_name"].(string),
-		BenchmarkHash: doc.Data()["benchmark_hash"].(string),
-		Type:          typ,
-		Created:       time.UnixMilli(doc.Data()["created"].(int64)),
+		ID:              doc.Ref.ID,
+		BenchmarkName:   doc.Data()["benchmark_name"].(string),
+		BenchmarkHash:   doc.Data()["benchmark_hash"].(string),
+		BenchmarkRepo:   snapshot.BenchmarkRepo,
+		BenchmarkCommit: snapshot.BenchmarkCommit,
+		Type:            typ,
+		Created:         time.UnixMilli(doc.Data()["created"].(int64)),
+		Config:          snapshot.Config,
+	}
+}
+
+// RiskScore returns the recorded risk score, or zero if none was recorded.
+func (r *Rebuild) RiskScore() int {
+	if r.Risk == nil {
+		return 0
 	}
+	return r.Risk.Score
 }
 
 // ID returns a stable, human-readable formatting of the ecosystem, package, and version.
@@ -203,17 +296,38 @@ func cleanVerdict(m string) string {
 	return m
 }
 
+// Reader queries the recorded results of rebuilds.
+type Reader interface {
+	FetchRebuilds(context.Context, *FetchRebuildRequest) (map[string]Rebuild, error)
+	FetchRuns(context.Context, FetchRunsOpts) ([]Run, error)
+	FetchAnnotations(context.Context, rebuild.Target) ([]schema.Annotation, error)
+	FetchHistory(context.Context, *FetchHistoryRequest) ([]History, error)
+}
+
+// Writer records the results of rebuilds.
+type Writer interface {
+	WriteRun(context.Context, Run) error
+	WriteRebuild(context.Context, Rebuild) error
+	WriteAnnotation(context.Context, schema.Annotation) error
+}
+
+// maxDisjunctions is the maximum number of values firestore accepts in an "in" filter.
+const maxDisjunctions = 30
+
 // Client is a wrapper around the external firestore client.
 type Client struct {
 	Client *firestore.Client
 }
 
-// NewClient creates a new FirestoreClient.
-func NewClient(ctx context.Context, project string) (*Client, error) {
+var _ Reader = &Client{}
+var _ Writer = &Client{}
+
+// NewClient creates a new FirestoreClient, passing opts to the underlying firestore client.
+func NewClient(ctx context.Context, project string, opts ...option.ClientOption) (*Client, error) {
 	if project == "" {
 		return nil, errors.New("empty project provided")
 	}
-	client, err := firestore.NewClient(ctx, project)
+	client, err := firestore.NewClient(ctx, project, opts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating firestore client")
 	}
@@ -231,16 +345,28 @@ type FetchRebuildRequest struct {
 	Executors []string
 	Runs      []string
 	Opts      FetchRebuildOpts
+	// Progress, if provided, records the number of rebuilds fetched and retained.
+	Progress *pipe.Progress
 }
 
-// FetchRebuilds fetches the Rebuild objects out of firestore.
-func (f *Client) FetchRebuilds(ctx context.Context, req *FetchRebuildRequest) (rebuilds map[string]Rebuild, err error) {
-	log.Println("Analyzing results...")
+func (req *FetchRebuildRequest) validate() error {
 	if len(req.Executors) != 0 && len(req.Runs) != 0 {
-		return nil, errors.New("only provide one of executors and runs")
+		return errors.New("only provide one of executors and runs")
 	}
 	if req.Bench != nil && req.Bench.Count == 0 {
-		return nil, errors.New("empty bench provided")
+		return errors.New("empty bench provided")
+	}
+	if len(req.Executors) > maxDisjunctions || len(req.Runs) > maxDisjunctions {
+		return errors.Errorf("at most %d executors or runs may be provided", maxDisjunctions)
+	}
+	return nil
+}
+
+// FetchRebuilds fetches the Rebuild objects out of firestore.
+func (f *Client) FetchRebuilds(ctx context.Context, req *FetchRebuildRequest) (map[string]Rebuild, error) {
+	log.Println("Analyzing results...")
+	if err := req.validate(); err != nil {
+		return nil, err
 	}
 	q := f.Client.CollectionGroup("attempts").Query
 	if len(req.Executors) != 0 {
@@ -252,8 +378,24 @@ func (f *Client) FetchRebuilds(ctx context.Context, req *FetchRebuildRequest) (r
 		q = q.Where("run_id", "in", req.Runs)
 	}
 	all := make(chan Rebuild)
-	cerr := DoQuery(ctx, q, NewRebuildFromFirestore, all)
-	p := pipe.From(all)
+	p := pipe.FromContext(ctx, all)
+	cerr := DoQuery(p.Context(), q, NewRebuildFromFirestore, all)
+	rebuilds, err := processRebuilds(p, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := <-cerr; err != nil {
+		return nil, errors.Wrap(err, "querying rebuilds")
+	}
+	return rebuilds, nil
+}
+
+// processRebuilds applies the filters and post-processing of req to the
+// queried rebuilds, retaining the latest rebuild of each package version.
+func processRebuilds(p pipe.Pipe[Rebuild], req *FetchRebuildRequest) (map[string]Rebuild, error) {
+	if req.Progress != nil {
+		p = p.Track(req.Progress, "fetched")
+	}
 	if req.Bench != nil {
 		benchMap := make(map[string]benchmark.Package)
 		for _, bp := range req.Bench.Packages {
@@ -273,15 +415,19 @@ func (f *Client) FetchRebuilds(ctx context.Context, req *FetchRebuildRequest) (r
 		})
 	}
 	// Post-processing
-	p = p.Do(func(in Rebuild, out chan<- Rebuild) {
+	p = p.DoErr(func(_ context.Context, in Rebuild, out chan<- Rebuild) error {
 		if strings.HasPrefix(in.Message, `rebuild failure: rebuilt artifact not found upstream: `) {
 			artifact := strings.TrimPrefix(in.Message, `rebuild failure: rebuilt artifact not found upstream: `)
-			builtVersion := strings.Split(artifact, "-")[1]
-			if builtVersion != in.Version {
+			parts := strings.Split(artifact, "-")
+			if len(parts) < 2 {
+				return errors.Errorf("unexpected artifact name for %s: %s", in.ID(), artifact)
+			}
+			if builtVersion := parts[1]; builtVersion != in.Version {
 				in.Message = fmt.Sprintf("built version does not match requested version (%s vs %s)", builtVersion, in.Version)
 			}
 		}
 		out <- in
+		return nil
 	})
 	if req.Opts.Clean {
 		p = p.Do(func(in Rebuild, out chan<- Rebuild) {
@@ -289,7 +435,10 @@ func (f *Client) FetchRebuilds(ctx context.Context, req *FetchRebuildRequest) (r
 			out <- in
 		})
 	}
-	rebuilds = make(map[string]Rebuild)
+	if req.Progress != nil {
+		p = p.Track(req.Progress, "matched")
+	}
+	rebuilds := make(map[string]Rebuild)
 	for r := range p.Out() {
 		if existing, seen := rebuilds[r.ID()]; seen && existing.Created.After(r.Created) {
 			continue
@@ -297,10 +446,90 @@ func (f *Client) FetchRebuilds(ctx context.Context, req *FetchRebuildRequest) (r
 		r.Message = strings.ReplaceAll(r.Message, "\n", "\\n")
 		rebuilds[r.ID()] = r
 	}
+	// NOTE: A failed stage cancels the query so its error takes precedence.
+	if err := p.Err(); err != nil {
+		return nil, errors.Wrap(err, "processing rebuilds")
+	}
+	return rebuilds, nil
+}
+
+// FetchHistoryRequest describes the package whose rebuilds across all runs you would like to fetch from firestore.
+type FetchHistoryRequest struct {
+	Package string
+	// Ecosystem and Version, if provided, restrict the history to that ecosystem and version of Package.
+	Ecosystem string
+	Version   string
+}
+
+func (req *FetchHistoryRequest) validate() error {
+	if req.Package == "" {
+		return errors.New("empty package provided")
+	}
+	if req.Version != "" && req.Ecosystem == "" {
+		return errors.New("version provided without ecosystem")
+	}
+	return nil
+}
+
+// History is the rebuilds of a single package version across runs, oldest first.
+type History struct {
+	ID       string
+	Rebuilds []Rebuild
+}
+
+// Changed returns whether the i-th rebuild's verdict differs from the one preceding it.
+func (h History) Changed(i int) bool {
+	if i <= 0 || i >= len(h.Rebuilds) {
+		return false
+	}
+	prev, cur := h.Rebuilds[i-1], h.Rebuilds[i]
+	return prev.Success != cur.Success || prev.Message != cur.Message
+}
+
+// groupHistory groups rebuilds by package version, ordering each group oldest first.
+func groupHistory(rebuilds []Rebuild) []History {
+	slices.SortStableFunc(rebuilds, func(a, b Rebuild) int {
+		if c := strings.Compare(a.ID(), b.ID()); c != 0 {
+			return c
+		}
+		if c := a.Created.Compare(b.Created); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Run, b.Run)
+	})
+	var hists []History
+	for _, r := range rebuilds {
+		r.Message = strings.ReplaceAll(r.Message, "\n", "\\n")
+		if len(hists) == 0 || hists[len(hists)-1].ID != r.ID() {
+			hists = append(hists, History{ID: r.ID()})
+		}
+		hists[len(hists)-1].Rebuilds = append(hists[len(hists)-1].Rebuilds, r)
+	}
+	return hists
+}
+
+// FetchHistory fetches the rebuilds of a package from all runs, grouped by package version.
+func (f *Client) FetchHistory(ctx context.Context, req *FetchHistoryRequest) ([]History, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	q := f.Client.CollectionGroup("attempts").Where("package", "==", req.Package)
+	if req.Ecosystem != "" {
+		q = q.Where("ecosystem", "==", req.Ecosystem)
+	}
+	if req.Version != "" {
+		q = q.Where("version", "==", req.Version)
+	}
+	all := make(chan Rebuild)
+	cerr := DoQuery(ctx, q, NewRebuildFromFirestore, all)
+	var rebuilds []Rebuild
+	for r := range all {
+		rebuilds = append(rebuilds, r)
+	}
 	if err := <-cerr; err != nil {
-		log.Fatal("query error", err.Error())
+		return nil, errors.Wrap(err, "querying rebuilds")
 	}
-	return
+	return groupHistory(rebuilds), nil
 }
 
 // FetchRunsOpts  describes which Runs you would like to fetch from firestore.
@@ -326,10 +555,168 @@ func (f *Client) FetchRuns(ctx context.Context, opts FetchRunsOpts) ([]Run, erro
 	return runSlice, nil
 }
 
+// FetchAnnotations fetches the annotations attached to the results of a target, oldest first.
+func (f *Client) FetchAnnotations(ctx context.Context, t rebuild.Target) ([]schema.Annotation, error) {
+	q := f.Client.Collection("annotations").
+		Where("ecosystem", "==", string(t.Ecosystem)).
+		Where("package", "==", t.Package).
+		Where("version", "==", t.Version)
+	annotations := make(chan schema.Annotation)
+	cerr := DoQuery(ctx, q, func(doc *firestore.DocumentSnapshot) schema.Annotation {
+		var a schema.Annotation
+		if err := doc.DataTo(&a); err != nil {
+			panic(err)
+		}
+		return a
+	}, annotations)
+	var out []schema.Annotation
+	for a := range annotations {
+		if t.Artifact == "" || a.Artifact == "" || a.Artifact == t.Artifact {
+			out = append(out, a)
+		}
+	}
+	if err := <-cerr; err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	slices.SortStableFunc(out, func(a, b schema.Annotation) int { return cmp.Compare(a.Created, b.Created) })
+	return out, nil
+}
+
+// WriteRun records a run, failing if one with the same ID exists.
+func (f *Client) WriteRun(ctx context.Context, r Run) error {
+	if _, err := f.Client.Collection("runs").Doc(r.ID).Create(ctx, r.runDocument()); err != nil {
+		return errors.Wrap(err, "writing run")
+	}
+	return nil
+}
+
+// WriteRebuild records a rebuild, replacing any previous rebuild of the same
+// package version in its run.
+func (f *Client) WriteRebuild(ctx context.Context, r Rebuild) error {
+	sa, err := r.attempt()
+	if err != nil {
+		return err
+	}
+	doc := f.Client.Collection("ecosystem").Doc(r.Ecosystem).Collection("packages").Doc(sanitize(r.Package)).Collection("versions").Doc(r.Version).Collection("attempts").Doc(r.Run)
+	if _, err := doc.Set(ctx, sa); err != nil {
+		return errors.Wrap(err, "writing rebuild")
+	}
+	return nil
+}
+
+// WriteAnnotation records an annotation.
+func (f *Client) WriteAnnotation(ctx context.Context, a schema.Annotation) error {
+	if _, _, err := f.Client.Collection("annotations").Add(ctx, a); err != nil {
+		return errors.Wrap(err, "writing annotation")
+	}
+	return nil
+}
+
+// BackfillStats computes the aggregate reproducibility statistics of each
+// ecosystem from the index of the latest attested rebuild outcome of each
+// artifact, replacing those stored. Outcomes superseded before the statistics
+// were maintained are unrecorded so each month counts only the latest outcomes
+// within it. It should be run once, before the statistics are maintained.
+func (f *Client) BackfillStats(ctx context.Context) (map[string]schema.ReproducibilityStats, error) {
+	docs, err := f.Client.Collection("status").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading statuses")
+	}
+	totals := make(map[string]schema.ReproducibilityStats)
+	months := make(map[string]map[string]schema.ReproducibilityStats)
+	for _, doc := range docs {
+		var s schema.RebuildStatus
+		if err := doc.DataTo(&s); err != nil {
+			return nil, errors.Wrapf(err, "parsing status %s", doc.Ref.ID)
+		}
+		o := schema.OutcomeStats(s)
+		totals[s.Ecosystem] = totals[s.Ecosystem].Add(o)
+		if s.Updated == 0 {
+			continue
+		}
+		if months[s.Ecosystem] == nil {
+			months[s.Ecosystem] = make(map[string]schema.ReproducibilityStats)
+		}
+		m := schema.StatsMonth(s.Updated)
+		months[s.Ecosystem][m] = months[s.Ecosystem][m].Add(o)
+	}
+	for eco, total := range totals {
+		doc := f.Client.Collection("stats").Doc(eco)
+		if _, err := doc.Set(ctx, total); err != nil {
+			return nil, errors.Wrapf(err, "writing %s stats", eco)
+		}
+		for m, stats := range months[eco] {
+			if _, err := doc.Collection("months").Doc(m).Set(ctx, stats); err != nil {
+				return nil, errors.Wrapf(err, "writing %s stats of %s", eco, m)
+			}
+		}
+	}
+	return totals, nil
+}
+
+// sanitize returns key in a form usable as a document ID.
+func sanitize(key string) string {
+	return strings.ReplaceAll(key, "/", "!")
+}
+
+// RebuildPair is the result of a target in two runs.
+type RebuildPair struct {
+	Before, After Rebuild
+}
+
+// RunComparison categorizes the targets of two runs by how their results changed.
+type RunComparison struct {
+	Fixed        []RebuildPair
+	Regressed    []RebuildPair
+	StillFailing []RebuildPair
+	// New are the targets only rebuilt in the later run.
+	New []Rebuild
+	// Removed are the targets only rebuilt in the earlier run.
+	Removed []Rebuild
+	// StillPassing is the number of targets that succeeded in both runs.
+	StillPassing int
+}
+
+// CompareRuns compares the rebuilds of two runs, as returned by FetchRebuilds.
+// Each category is ordered by target.
+func CompareRuns(before, after map[string]Rebuild) RunComparison {
+	var c RunComparison
+	for id, a := range after {
+		b, ok := before[id]
+		switch {
+		case !ok:
+			c.New = append(c.New, a)
+		case b.Success && a.Success:
+			c.StillPassing++
+		case a.Success:
+			c.Fixed = append(c.Fixed, RebuildPair{b, a})
+		case b.Success:
+			c.Regressed = append(c.Regressed, RebuildPair{b, a})
+		default:
+			c.StillFailing = append(c.StillFailing, RebuildPair{b, a})
+		}
+	}
+	for id, b := range before {
+		if _, ok := after[id]; !ok {
+			c.Removed = append(c.Removed, b)
+		}
+	}
+	byPair := func(x, y RebuildPair) int { return strings.Compare(x.After.ID(), y.After.ID()) }
+	byID := func(x, y Rebuild) int { return strings.Compare(x.ID(), y.ID()) }
+	slices.SortFunc(c.Fixed, byPair)
+	slices.SortFunc(c.Regressed, byPair)
+	slices.SortFunc(c.StillFailing, byPair)
+	slices.SortFunc(c.New, byID)
+	slices.SortFunc(c.Removed, byID)
+	return c
+}
+
 // VerdictGroup is a collection of Rebuild objects, grouped by the same Message.
 type VerdictGroup struct {
-	Msg      string
-	Count    int
+	Msg   string
+	Count int
+	// MaxRisk is the highest risk score among the group's rebuilds.
+	MaxRisk  int
 	Examples []Rebuild
 }
 
@@ -341,10 +728,15 @@ func GroupRebuilds(rebuilds map[string]Rebuild) (byCount []*VerdictGroup) {
 			msgs[r.Message] = &VerdictGroup{Msg: r.Message}
 		}
 		msgs[r.Message].Count++
+		msgs[r.Message].MaxRisk = max(msgs[r.Message].MaxRisk, r.RiskScore())
 		msgs[r.Message].Examples = append(msgs[r.Message].Examples, r)
 	}
 	for _, vg := range msgs {
+		// Surface the riskiest examples first so likely tampering is triaged before noise.
 		slices.SortFunc(vg.Examples, func(a, b Rebuild) int {
+			if c := cmp.Compare(b.RiskScore(), a.RiskScore()); c != 0 {
+				return c
+			}
 			return strings.Compare(a.ID(), b.ID())
 		})
 		byCount = append(byCount, vg)
@@ -0,0 +1,321 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firestore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/benchmark"
+)
+
+type readWriter interface {
+	Reader
+	Writer
+}
+
+func TestFake(t *testing.T) {
+	testReadWriter(t, func(t *testing.T) readWriter { return NewFake() })
+}
+
+// TestEmulator runs against the firestore emulator identified by
+// FIRESTORE_EMULATOR_HOST, as started by "ctl dev up".
+func TestEmulator(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+	testReadWriter(t, func(t *testing.T) readWriter {
+		// NOTE: The emulator isolates the data of each project.
+		project := fmt.Sprintf("test-%d", time.Now().UnixNano())
+		c, err := NewClient(context.Background(), project)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Client.Close() })
+		return c
+	})
+}
+
+func TestCompareRuns(t *testing.T) {
+	rb := func(pkg string, success bool) Rebuild {
+		r := Rebuild{Ecosystem: "npm", Package: pkg, Version: "1.0.0", Success: success}
+		if !success {
+			r.Message = "content differences found"
+		}
+		return r
+	}
+	byID := func(rbs ...Rebuild) map[string]Rebuild {
+		m := make(map[string]Rebuild)
+		for _, r := range rbs {
+			m[r.ID()] = r
+		}
+		return m
+	}
+	before := byID(rb("passing", true), rb("fixed", false), rb("regressed", true), rb("failing", false), rb("removed", true))
+	after := byID(rb("passing", true), rb("fixed", true), rb("regressed", false), rb("failing", false), rb("new-b", false), rb("new-a", true))
+	want := RunComparison{
+		Fixed:        []RebuildPair{{rb("fixed", false), rb("fixed", true)}},
+		Regressed:    []RebuildPair{{rb("regressed", true), rb("regressed", false)}},
+		StillFailing: []RebuildPair{{rb("failing", false), rb("failing", false)}},
+		New:          []Rebuild{rb("new-a", true), rb("new-b", false)},
+		Removed:      []Rebuild{rb("removed", true)},
+		StillPassing: 1,
+	}
+	if diff := cmp.Diff(want, CompareRuns(before, after)); diff != "" {
+		t.Errorf("CompareRuns() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func testReadWriter(t *testing.T, newReadWriter func(*testing.T) readWriter) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newRebuild := func(pkg, version, run string, offset time.Duration) Rebuild {
+		return Rebuild{
+			Ecosystem: "npm",
+			Package:   pkg,
+			Version:   version,
+			Artifact:  strings.ReplaceAll(strings.TrimPrefix(pkg, "@"), "/", "-") + "-" + version + ".tgz",
+			Success:   true,
+			Executor:  "executor-" + run,
+			Run:       run,
+			Created:   created.Add(offset),
+			Timings:   rebuild.Timings{Source: 1500 * time.Millisecond, Build: 90 * time.Second},
+		}
+	}
+	t.Run("RebuildRoundTrip", func(t *testing.T) {
+		ctx := context.Background()
+		rw := newReadWriter(t)
+		want := newRebuild("@scope/pkg", "1.0.0", "run-a", 0)
+		want.Success = false
+		want.Message = "content mismatch"
+		want.Risk = &rebuild.RiskAssessment{Score: 3, Evidence: []rebuild.RiskEvidence{{Path: "package/index.js"}}}
+		want.FailureSnippet = "npm ERR! code ELIFECYCLE"
+		if err := rw.WriteRebuild(ctx, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := rw.FetchRebuilds(ctx, &FetchRebuildRequest{Runs: []string{"run-a"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(map[string]Rebuild{want.ID(): want}, got, cmp.Comparer(time.Time.Equal)); diff != "" {
+			t.Errorf("FetchRebuilds() mismatch (-want +got):\n%s", diff)
+		}
+	})
+	t.Run("RebuildQueries", func(t *testing.T) {
+		ctx := context.Background()
+		rw := newReadWriter(t)
+		for _, r := range []Rebuild{
+			newRebuild("a", "1.0.0", "run-a", 0),
+			newRebuild("a", "1.0.0", "run-b", time.Hour),
+			newRebuild("b", "2.0.0", "run-a", 0),
+			newRebuild("c", "3.0.0", "run-c", 0),
+		} {
+			if err := rw.WriteRebuild(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, tc := range []struct {
+			name string
+			req  FetchRebuildRequest
+			want map[string]string
+		}{
+			{
+				name: "all",
+				want: map[string]string{"npm!a!1.0.0": "run-b", "npm!b!2.0.0": "run-a", "npm!c!3.0.0": "run-c"},
+			},
+			{
+				name: "runs",
+				req:  FetchRebuildRequest{Runs: []string{"run-a", "run-c"}},
+				want: map[string]string{"npm!a!1.0.0": "run-a", "npm!b!2.0.0": "run-a", "npm!c!3.0.0": "run-c"},
+			},
+			{
+				name: "executors",
+				req:  FetchRebuildRequest{Executors: []string{"executor-run-b"}},
+				want: map[string]string{"npm!a!1.0.0": "run-b"},
+			},
+			{
+				name: "bench",
+				req: FetchRebuildRequest{Bench: &benchmark.PackageSet{Metadata: benchmark.Metadata{Count: 1}, Packages: []benchmark.Package{
+					{Ecosystem: "npm", Name: "b", Versions: []string{"2.0.0"}},
+				}}},
+				want: map[string]string{"npm!b!2.0.0": "run-a"},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				rebuilds, err := rw.FetchRebuilds(ctx, &tc.req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := make(map[string]string)
+				for id, r := range rebuilds {
+					got[id] = r.Run
+				}
+				if diff := cmp.Diff(tc.want, got); diff != "" {
+					t.Errorf("FetchRebuilds() runs mismatch (-want +got):\n%s", diff)
+				}
+			})
+		}
+	})
+	t.Run("RebuildOverwrite", func(t *testing.T) {
+		ctx := context.Background()
+		rw := newReadWriter(t)
+		first := newRebuild("a", "1.0.0", "run-a", 0)
+		second := first
+		second.Success, second.Message = false, "build failed"
+		for _, r := range []Rebuild{first, second} {
+			if err := rw.WriteRebuild(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := rw.FetchRebuilds(ctx, &FetchRebuildRequest{Runs: []string{"run-a"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[first.ID()].Message != second.Message {
+			t.Errorf("FetchRebuilds() = %v, want only the second write", got)
+		}
+	})
+	t.Run("InvalidRebuildRequests", func(t *testing.T) {
+		ctx := context.Background()
+		rw := newReadWriter(t)
+		tooMany := make([]string, maxDisjunctions+1)
+		for i := range tooMany {
+			tooMany[i] = fmt.Sprint("run-", i)
+		}
+		for _, req := range []FetchRebuildRequest{
+			{Runs: []string{"run-a"}, Executors: []string{"executor"}},
+			{Bench: &benchmark.PackageSet{}},
+			{Runs: tooMany},
+		} {
+			if _, err := rw.FetchRebuilds(ctx, &req); err == nil {
+				t.Errorf("FetchRebuilds(%+v) succeeded, want error", req)
+			}
+		}
+	})
+	t.Run("History", func(t *testing.T) {
+		ctx := context.Background()
+		rw := newReadWriter(t)
+		failed := newRebuild("a", "1.0.0", "run-b", time.Hour)
+		failed.Success, failed.Message = false, "build failed"
+		for _, r := range []Rebuild{
+			newRebuild("a", "1.0.0", "run-c", 2*time.Hour),
+			failed,
+			newRebuild("a", "1.0.0", "run-a", 0),
+			newRebuild("a", "2.0.0", "run-a", 0),
+			newRebuild("b", "1.0.0", "run-a", 0),
+		} {
+			if err := rw.WriteRebuild(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		runs := func(hists []History) map[string][]string {
+			got := make(map[string][]string)
+			for _, h := range hists {
+				for _, r := range h.Rebuilds {
+					got[h.ID] = append(got[h.ID], r.Run)
+				}
+			}
+			return got
+		}
+		hists, err := rw.FetchHistory(ctx, &FetchHistoryRequest{Package: "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string][]string{"npm!a!1.0.0": {"run-a", "run-b", "run-c"}, "npm!a!2.0.0": {"run-a"}}
+		if diff := cmp.Diff(want, runs(hists)); diff != "" {
+			t.Errorf("FetchHistory() runs mismatch (-want +got):\n%s", diff)
+		}
+		if len(hists) != 0 {
+			var changed []bool
+			for i := range hists[0].Rebuilds {
+				changed = append(changed, hists[0].Changed(i))
+			}
+			if diff := cmp.Diff([]bool{false, true, true}, changed); diff != "" {
+				t.Errorf("Changed() mismatch (-want +got):\n%s", diff)
+			}
+		}
+		hists, err = rw.FetchHistory(ctx, &FetchHistoryRequest{Package: "a", Ecosystem: "npm", Version: "2.0.0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(map[string][]string{"npm!a!2.0.0": {"run-a"}}, runs(hists)); diff != "" {
+			t.Errorf("FetchHistory(Version) runs mismatch (-want +got):\n%s", diff)
+		}
+		if _, err := rw.FetchHistory(ctx, &FetchHistoryRequest{}); err == nil {
+			t.Error("FetchHistory() of empty package succeeded, want error")
+		}
+	})
+	t.Run("Runs", func(t *testing.T) {
+		ctx := context.Background()
+		rw := newReadWriter(t)
+		runs := []Run{
+			{ID: "2024-01-02T00:00:00Z", BenchmarkName: "top.json", BenchmarkHash: "abc", Type: AttestMode, Created: created.Add(24*time.Hour + time.Microsecond)},
+			{ID: "2024-01-01T00:00:00Z", BenchmarkName: "all.json", BenchmarkHash: "def", Type: SmoketestMode, Created: created, Config: &schema.RunConfig{APIVersion: "v1"}},
+		}
+		for _, r := range runs {
+			if err := rw.WriteRun(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := rw.WriteRun(ctx, runs[0]); err == nil {
+			t.Error("WriteRun() of existing run succeeded, want error")
+		}
+		got, err := rw.FetchRuns(ctx, FetchRunsOpts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []Run{runs[1], runs[0]}
+		// NOTE: The creation time is stored with millisecond precision.
+		want[1].Created = created.Add(24 * time.Hour)
+		if diff := cmp.Diff(want, got, cmp.Comparer(time.Time.Equal)); diff != "" {
+			t.Errorf("FetchRuns() mismatch (-want +got):\n%s", diff)
+		}
+		got, err = rw.FetchRuns(ctx, FetchRunsOpts{BenchmarkHash: "abc"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].ID != runs[0].ID {
+			t.Errorf("FetchRuns(BenchmarkHash) = %v, want [%s]", got, runs[0].ID)
+		}
+	})
+	t.Run("Annotations", func(t *testing.T) {
+		ctx := context.Background()
+		rw := newReadWriter(t)
+		annotations := []schema.Annotation{
+			{Ecosystem: "npm", Package: "a", Version: "1.0.0", Artifact: "a-1.0.0.tgz", Text: "second", Created: 2},
+			{Ecosystem: "npm", Package: "a", Version: "1.0.0", Text: "first", Created: 1},
+			{Ecosystem: "npm", Package: "a", Version: "1.0.0", Artifact: "other.tgz", Text: "other artifact", Created: 3},
+			{Ecosystem: "npm", Package: "a", Version: "2.0.0", Text: "other version", Created: 4},
+		}
+		for _, a := range annotations {
+			if err := rw.WriteAnnotation(ctx, a); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := rw.FetchAnnotations(ctx, rebuild.Target{Ecosystem: rebuild.NPM, Package: "a", Version: "1.0.0", Artifact: "a-1.0.0.tgz"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]schema.Annotation{annotations[1], annotations[0]}, got); diff != "" {
+			t.Errorf("FetchAnnotations() mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
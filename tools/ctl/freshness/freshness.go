@@ -0,0 +1,256 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package freshness reports which of the build inputs recorded in attestation
+// bundles have since changed or been withdrawn upstream.
+//
+// Such changes do not invalidate an attestation but signal that the rebuild
+// it describes may no longer be reproducible.
+package freshness
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	pypireg "github.com/google/oss-rebuild/pkg/registry/pypi"
+	"github.com/pkg/errors"
+)
+
+// InputKind is the class of a recorded build input.
+type InputKind string
+
+const (
+	// ImageInput is a container image used by the build.
+	ImageInput InputKind = "image"
+	// SourceInput is the source repository from which the artifact was built.
+	SourceInput InputKind = "source"
+	// UpstreamInput is the published artifact to which the rebuild was compared.
+	UpstreamInput InputKind = "upstream"
+)
+
+// Status is the current state of a recorded build input.
+type Status string
+
+const (
+	// Current indicates the input is unchanged.
+	Current Status = "current"
+	// Changed indicates the input now refers to different content.
+	Changed Status = "changed"
+	// Withdrawn indicates the input was yanked, deprecated, or removed upstream.
+	Withdrawn Status = "withdrawn"
+	// Unavailable indicates the current state of the input could not be determined.
+	Unavailable Status = "unavailable"
+)
+
+// Finding is the current state of a single build input.
+type Finding struct {
+	Kind   InputKind `json:"kind"`
+	Name   string    `json:"name"`
+	Status Status    `json:"status"`
+	// Recorded and Current are the attested and present digests of the input, if applicable.
+	Recorded string `json:"recorded,omitempty"`
+	Current  string `json:"current,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Report describes the freshness of the inputs of a single attested rebuild.
+type Report struct {
+	Target   rebuild.Target `json:"target"`
+	Findings []Finding      `json:"findings"`
+}
+
+// Stale returns whether any input of the rebuild is no longer current.
+func (r *Report) Stale() bool {
+	return slices.ContainsFunc(r.Findings, func(f Finding) bool { return f.Status != Current })
+}
+
+// Options configures the checks applied to each input. Inputs whose check is not configured are skipped.
+type Options struct {
+	// Images resolves the present digest of the images used by the build.
+	Images rebuild.ImageResolver
+	// Registry provides the present state of the upstream artifact.
+	Registry *rebuild.RegistryMux
+	// Sources enables checking that the source repository remains available.
+	Sources bool
+}
+
+// Check compares the build inputs recorded in the bundle to their present state.
+func Check(ctx context.Context, b *verifier.Bundle, opts Options) (*Report, error) {
+	t, err := b.Target()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading target")
+	}
+	r := &Report{Target: t, Findings: []Finding{}}
+	images, repos, err := recordedInputs(b)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Images != nil {
+		names := make([]string, 0, len(images))
+		for name := range images {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			r.Findings = append(r.Findings, checkImage(ctx, opts.Images, name, images[name]))
+		}
+	}
+	if opts.Sources {
+		for _, repo := range repos {
+			r.Findings = append(r.Findings, checkSource(ctx, repo))
+		}
+	}
+	if opts.Registry != nil {
+		r.Findings = append(r.Findings, checkUpstream(ctx, *opts.Registry, t))
+	}
+	return r, nil
+}
+
+// recordedInputs returns the digests of the images and the source
+// repositories recorded by the bundle's rebuild attestation.
+func recordedInputs(b *verifier.Bundle) (images map[string]string, repos []string, err error) {
+	att, err := b.RebuildAttestation()
+	if err != nil {
+		return nil, nil, err
+	}
+	images = make(map[string]string)
+	for _, rd := range att.Predicate.BuildDefinition.ResolvedDependencies {
+		switch {
+		case strings.HasPrefix(rd.Name, "git+"):
+			repos = append(repos, strings.TrimPrefix(rd.Name, "git+"))
+		case rd.Content != nil:
+			// Inline content such as the build definition has no upstream state.
+		default:
+			// NOTE: Only steps recorded with their image digest can be checked.
+			if d := rd.Digest["sha256"]; isSHA256(d) {
+				images[rd.Name] = normalizeDigest(d)
+			}
+		}
+	}
+	// NOTE: The environment is absent for rebuilds that predate its collection.
+	if raw, err := b.Byproduct("environment.json"); err == nil {
+		var env rebuild.Environment
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return nil, nil, errors.Wrap(err, "parsing environment")
+		}
+		for ref, d := range env.Images {
+			images[ref] = normalizeDigest(d)
+		}
+	}
+	return images, repos, nil
+}
+
+func normalizeDigest(d string) string {
+	if strings.HasPrefix(d, "sha256:") {
+		return d
+	}
+	return "sha256:" + d
+}
+
+func isSHA256(d string) bool {
+	d = strings.TrimPrefix(d, "sha256:")
+	_, err := hex.DecodeString(d)
+	return err == nil && len(d) == 64
+}
+
+func checkImage(ctx context.Context, resolver rebuild.ImageResolver, ref, recorded string) Finding {
+	f := Finding{Kind: ImageInput, Name: ref, Recorded: recorded}
+	current, err := resolver.Resolve(ctx, ref)
+	switch {
+	case err != nil:
+		f.Status = Unavailable
+		f.Detail = err.Error()
+	case normalizeDigest(current) != recorded:
+		f.Status = Changed
+		f.Current = normalizeDigest(current)
+		f.Detail = "tag now refers to a different image"
+	default:
+		f.Status = Current
+		f.Current = recorded
+	}
+	return f
+}
+
+// checkSource reports whether the repository can still be listed.
+//
+// Whether the recorded commit remains reachable is not checked since git
+// servers do not generally advertise commits other than those at ref tips.
+func checkSource(ctx context.Context, repo string) Finding {
+	f := Finding{Kind: SourceInput, Name: repo}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{repo}})
+	if _, err := remote.ListContext(ctx, &git.ListOptions{}); err != nil {
+		f.Status = Unavailable
+		f.Detail = err.Error()
+	} else {
+		f.Status = Current
+	}
+	return f
+}
+
+// checkUpstream reports whether the upstream artifact has been yanked or deprecated.
+func checkUpstream(ctx context.Context, mux rebuild.RegistryMux, t rebuild.Target) Finding {
+	f := Finding{Kind: UpstreamInput, Name: t.Artifact, Status: Current}
+	unavailable := func(err error) Finding {
+		f.Status = Unavailable
+		f.Detail = err.Error()
+		return f
+	}
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		v, err := mux.NPM.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return unavailable(err)
+		}
+		if v.Deprecated != "" {
+			f.Status = Withdrawn
+			f.Detail = "deprecated: " + v.Deprecated
+		}
+	case rebuild.PyPI:
+		release, err := mux.PyPI.Release(ctx, t.Package, t.Version)
+		if err != nil {
+			return unavailable(err)
+		}
+		i := slices.IndexFunc(release.Artifacts, func(a pypireg.Artifact) bool { return a.Filename == t.Artifact })
+		if i == -1 {
+			f.Status = Withdrawn
+			f.Detail = "artifact removed from release"
+		} else if a := release.Artifacts[i]; a.Yanked {
+			f.Status = Withdrawn
+			f.Detail = "yanked"
+			if a.YankedReason != "" {
+				f.Detail += ": " + a.YankedReason
+			}
+		}
+	case rebuild.CratesIO:
+		v, err := mux.CratesIO.Version(ctx, t.Package, t.Version)
+		if err != nil {
+			return unavailable(err)
+		}
+		if v.Yanked {
+			f.Status = Withdrawn
+			f.Detail = "yanked"
+		}
+	default:
+		return unavailable(errors.Errorf("unsupported ecosystem: %s", t.Ecosystem))
+	}
+	return f
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hooks runs user-defined executables when ctl events occur.
+//
+// The hooks for an event are the executable files in the "<dir>/<event>/"
+// directory. They run in lexical order, each receiving the JSON-encoded Event
+// on stdin and the event type in the OSS_REBUILD_EVENT environment variable.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// EventType identifies the occurrence that triggered a hook.
+type EventType string
+
+const (
+	// RebuildCompleted occurs when a local rebuild completes. Data is a Rebuild.
+	RebuildCompleted EventType = "rebuild-completed"
+	// VerdictChanged occurs when a local rebuild's verdict differs from the
+	// verdict recorded for its run. Data is a Rebuild.
+	VerdictChanged EventType = "verdict-changed"
+	// BenchmarkFinished occurs when all the targets of a benchmark run have been
+	// rebuilt. Data is a Benchmark.
+	BenchmarkFinished EventType = "benchmark-finished"
+)
+
+// Event is the payload passed to hooks.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// Rebuild describes the result of a rebuild.
+type Rebuild struct {
+	Ecosystem string `json:"ecosystem"`
+	Package   string `json:"package"`
+	Version   string `json:"version"`
+	Artifact  string `json:"artifact"`
+	// Run is the run whose recorded rebuild was reproduced.
+	Run     string `json:"run"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	// PreviousSuccess and PreviousMessage are the verdict recorded for Run.
+	PreviousSuccess bool   `json:"previous_success"`
+	PreviousMessage string `json:"previous_message"`
+	// LogPath is the local path of the rebuild's logs.
+	LogPath string `json:"log_path,omitempty"`
+}
+
+// Benchmark describes the results of a benchmark run.
+type Benchmark struct {
+	Run       string `json:"run"`
+	Mode      string `json:"mode"`
+	Total     int    `json:"total"`
+	Successes int    `json:"successes"`
+}
+
+// DefaultTimeout bounds the execution of each hook.
+const DefaultTimeout = time.Minute
+
+// Runner executes the hooks for events.
+//
+// A nil Runner executes no hooks.
+type Runner struct {
+	// Dir is the directory containing the hooks for each event type.
+	Dir string
+	// Timeout bounds the execution of each hook. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// Output, if provided, receives the stdout and stderr of the hooks.
+	Output io.Writer
+}
+
+// DefaultDir returns the default hooks directory under the user's config directory.
+func DefaultDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "oss-rebuild", "hooks")
+}
+
+// Hooks returns the paths of the hooks for the event type in execution order.
+func (r *Runner) Hooks(typ EventType) ([]string, error) {
+	if r == nil || r.Dir == "" {
+		return nil, nil
+	}
+	dir := filepath.Join(r.Dir, string(typ))
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", dir)
+	}
+	var paths []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", entry.Name())
+		}
+		if info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Fire executes the hooks for the event, returning the first error encountered.
+//
+// A failing hook does not prevent the execution of those following it.
+func (r *Runner) Fire(ctx context.Context, e Event) error {
+	paths, err := r.Hooks(e.Type)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "marshalling event")
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	var firstErr error
+	for _, path := range paths {
+		if err := r.run(ctx, path, e.Type, payload, timeout); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "running hook %s", path)
+		}
+	}
+	return firstErr
+}
+
+func (r *Runner) run(ctx context.Context, path string, typ EventType, payload []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), "OSS_REBUILD_EVENT="+string(typ))
+	cmd.Stdout = r.Output
+	cmd.Stderr = r.Output
+	return cmd.Run()
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeHook(t *testing.T, dir string, typ EventType, name, script string, perm os.FileMode) {
+	t.Helper()
+	path := filepath.Join(dir, string(typ), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFire(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "out")
+	writeHook(t, dir, RebuildCompleted, "b", `echo "b $OSS_REBUILD_EVENT" >> `+out+"\n", 0755)
+	writeHook(t, dir, RebuildCompleted, "a", "exit 1\n", 0755)
+	writeHook(t, dir, RebuildCompleted, "c", `cat >> `+out+"\n", 0755)
+	writeHook(t, dir, RebuildCompleted, "README", "exit 1\n", 0644)
+	writeHook(t, dir, VerdictChanged, "d", `echo d >> `+out+"\n", 0755)
+	r := &Runner{Dir: dir}
+	e := Event{
+		Type: RebuildCompleted,
+		Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Data: Rebuild{Ecosystem: "npm", Package: "pkg", Version: "1.0.0", Run: "run", Success: true},
+	}
+	err := r.Fire(context.Background(), e)
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(string(RebuildCompleted), "a")) {
+		t.Errorf("Fire() error = %v, want failure of hook a", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, payload, _ := strings.Cut(string(got), "\n")
+	if first != "b rebuild-completed" {
+		t.Errorf("hook b output = %q, want %q", first, "b rebuild-completed")
+	}
+	var gotEvent map[string]any
+	if err := json.Unmarshal([]byte(payload), &gotEvent); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type": "rebuild-completed",
+		"time": "2024-01-01T00:00:00Z",
+		"data": map[string]any{
+			"ecosystem":        "npm",
+			"package":          "pkg",
+			"version":          "1.0.0",
+			"artifact":         "",
+			"run":              "run",
+			"success":          true,
+			"message":          "",
+			"previous_success": false,
+			"previous_message": "",
+		},
+	}
+	if diff := cmp.Diff(want, gotEvent); diff != "" {
+		t.Errorf("hook c payload mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFireNoHooks(t *testing.T) {
+	for _, r := range []*Runner{nil, {}, {Dir: filepath.Join(t.TempDir(), "missing")}} {
+		if err := r.Fire(context.Background(), Event{Type: BenchmarkFinished}); err != nil {
+			t.Errorf("Fire() = %v, want nil", err)
+		}
+	}
+}
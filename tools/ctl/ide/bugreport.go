@@ -0,0 +1,118 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/assistant"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// bugReportEvidence collects the evidence of the nondeterminism of a mismatched rebuild.
+func (e *explorer) bugReportEvidence(ctx context.Context, example firestore.Rebuild) (*assistant.BugReportEvidence, error) {
+	paths, err := fetchAssets(ctx, example, rebuild.DebugRebuildAsset, rebuild.DebugUpstreamAsset)
+	if err != nil {
+		return nil, err
+	}
+	ev := assistant.BugReportEvidence{Target: example.Target()}
+	if ev.Diff, err = diffoscope(paths[0], paths[1], false); err != nil {
+		return nil, err
+	}
+	var strategy schema.StrategyOneOf
+	if err := json.Unmarshal([]byte(example.Strategy), &strategy); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal strategy")
+	}
+	buf := new(bytes.Buffer)
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(strategy); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal strategy")
+	}
+	ev.Strategy = buf.String()
+	// NOTE: Not every rebuild produces these assets so the report is drafted without them.
+	for _, opt := range []struct {
+		typ rebuild.AssetType
+		dst *string
+	}{
+		{rebuild.DockerfileAsset, &ev.Dockerfile},
+		{rebuild.BuildInfoAsset, &ev.Environment},
+	} {
+		paths, err := fetchAssets(ctx, example, opt.typ)
+		if err != nil {
+			log.Println(errors.Wrapf(err, "omitting %s from bug report", opt.typ))
+			continue
+		}
+		b, err := os.ReadFile(paths[0])
+		if err != nil {
+			return nil, err
+		}
+		*opt.dst = string(b)
+	}
+	annotations, err := e.firestore.FetchAnnotations(ctx, example.Target())
+	if err != nil {
+		log.Println(errors.Wrap(err, "omitting annotations from bug report"))
+	}
+	for _, a := range annotations {
+		ev.Notes = append(ev.Notes, a.Text)
+	}
+	return &ev, nil
+}
+
+// draftBugReport drafts a report of the rebuild's nondeterminism for the
+// package's maintainers, saving it as an asset and showing it for review.
+func (e *explorer) draftBugReport(ctx context.Context, example firestore.Rebuild) {
+	log.Printf("Drafting upstream bug report for %s...\n", example.ID())
+	ev, err := e.bugReportEvidence(ctx, example)
+	if err != nil {
+		log.Println(errors.Wrap(err, "failed to collect evidence"))
+		return
+	}
+	report, err := assistant.DraftBugReport(ctx, e.assistant.For(assistant.BugReportTask), *ev)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	localAssets, err := LocalAssetStore(ctx, example.Run)
+	if err != nil {
+		log.Println(errors.Wrap(err, "failed to create local asset store"))
+		return
+	}
+	w, path, err := localAssets.Writer(ctx, rebuild.Asset{Target: example.Target(), Type: rebuild.BugReportAsset})
+	if err != nil {
+		log.Println(errors.Wrap(err, "failed to save bug report"))
+		return
+	}
+	if _, err := io.WriteString(w, report); err != nil {
+		w.Close()
+		log.Println(errors.Wrap(err, "failed to save bug report"))
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Println(errors.Wrap(err, "failed to save bug report"))
+		return
+	}
+	log.Printf("Saved draft bug report for review: %s\n", path)
+	e.showPager("Draft bug report "+example.ID(), report, nil)
+}
@@ -0,0 +1,277 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+	"github.com/rivo/tview"
+)
+
+// column is a field of the rebuilds shown in the rebuild list.
+type column struct {
+	name  string
+	value func(e *explorer, r firestore.Rebuild) string
+	// compare orders rebuilds by the column. Defaults to comparing values.
+	compare func(e *explorer, a, b firestore.Rebuild) int
+}
+
+// columns are the columns available to the rebuild list.
+var columns = []column{
+	{
+		name:  "id",
+		value: func(_ *explorer, r firestore.Rebuild) string { return r.ID() },
+	},
+	{
+		name: "duration",
+		value: func(_ *explorer, r firestore.Rebuild) string {
+			return fmt.Sprintf("[%ds]", int(r.Timings.EstimateCleanBuild().Seconds()))
+		},
+		compare: func(_ *explorer, a, b firestore.Rebuild) int {
+			return cmp.Compare(a.Timings.EstimateCleanBuild(), b.Timings.EstimateCleanBuild())
+		},
+	},
+	{
+		name:  "strategy",
+		value: func(_ *explorer, r firestore.Rebuild) string { return strategyType(r) },
+	},
+	{
+		name:  "verdict",
+		value: func(_ *explorer, r firestore.Rebuild) string { return verdictClass(r) },
+	},
+	{
+		name: "triage",
+		value: func(e *explorer, r firestore.Rebuild) string {
+			annotated, ok := e.annotated.Load(r.ID())
+			switch {
+			case !ok:
+				return "?"
+			case annotated.(bool):
+				return "annotated"
+			default:
+				return "open"
+			}
+		},
+	},
+	{
+		name: "failure",
+		value: func(_ *explorer, r firestore.Rebuild) string {
+			// NOTE: The first line of the snippet distinguishes the failures within a verdict group.
+			first, _, _ := strings.Cut(r.FailureSnippet, "\n")
+			if len(first) > maxFailureLabel {
+				first = first[:maxFailureLabel] + "..."
+			}
+			return first
+		},
+	},
+	{
+		name:  "run",
+		value: func(_ *explorer, r firestore.Rebuild) string { return r.Run },
+	},
+	{
+		name:  "executor",
+		value: func(_ *explorer, r firestore.Rebuild) string { return r.Executor },
+	},
+}
+
+// DefaultColumns are the columns of the rebuild list if none are configured.
+var DefaultColumns = []string{"id", "duration", "failure"}
+
+// ColumnNames returns the names of the available columns.
+func ColumnNames() []string {
+	var names []string
+	for _, c := range columns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+func columnByName(name string) (column, bool) {
+	idx := slices.IndexFunc(columns, func(c column) bool { return c.name == name })
+	if idx == -1 {
+		return column{}, false
+	}
+	return columns[idx], true
+}
+
+// strategyType returns the name of the type of the rebuild's strategy.
+func strategyType(r firestore.Rebuild) string {
+	var oneof map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(r.Strategy), &oneof); err != nil {
+		return "unknown"
+	}
+	for name := range oneof {
+		return name
+	}
+	return "none"
+}
+
+// verdictClass returns the broad class of the rebuild's verdict.
+func verdictClass(r firestore.Rebuild) string {
+	switch {
+	case r.Success:
+		return "success"
+	case r.Risk != nil && r.Risk.Score > 0:
+		return "risk"
+	case isMismatch(r):
+		return "mismatch"
+	default:
+		return "failure"
+	}
+}
+
+// listLayout is the configured layout of the rebuild list.
+type listLayout struct {
+	columns []column
+	// sortBy is the index of the column by which rebuilds are sorted, or -1
+	// to retain the order of the verdict group.
+	sortBy int
+}
+
+func newListLayout(names []string, sortBy string) listLayout {
+	if len(names) == 0 {
+		names = DefaultColumns
+	}
+	l := listLayout{sortBy: -1}
+	for _, name := range names {
+		c, ok := columnByName(name)
+		if !ok {
+			log.Printf("Unknown column %q, available columns: %s\n", name, strings.Join(ColumnNames(), ", "))
+			continue
+		}
+		l.columns = append(l.columns, c)
+		if name == sortBy {
+			l.sortBy = len(l.columns) - 1
+		}
+	}
+	return l
+}
+
+func (l listLayout) has(name string) bool {
+	return slices.ContainsFunc(l.columns, func(c column) bool { return c.name == name })
+}
+
+// exampleLabel returns the label of the rebuild, padding each column to the corresponding width, if any.
+func (e *explorer) exampleLabel(r firestore.Rebuild, widths []int) string {
+	var vals []string
+	for i, c := range e.layout.columns {
+		v := c.value(e, r)
+		if i < len(widths) && i < len(e.layout.columns)-1 {
+			v += strings.Repeat(" ", max(widths[i]-len(v), 0))
+		}
+		vals = append(vals, v)
+	}
+	label := tview.Escape(strings.TrimRight(strings.Join(vals, " "), " "))
+	if e.selection.contains(r) {
+		label = selectedMarker + label
+	}
+	return label
+}
+
+// layoutGroup sorts and aligns the columns of the rebuilds of a verdict group node.
+// It must be called from the UI goroutine.
+func (e *explorer) layoutGroup(node *tview.TreeNode) {
+	vg, ok := node.GetReference().(*firestore.VerdictGroup)
+	if !ok {
+		return
+	}
+	var cmds, examples []*tview.TreeNode
+	for _, child := range node.GetChildren() {
+		if _, ok := child.GetReference().(firestore.Rebuild); ok {
+			examples = append(examples, child)
+		} else {
+			cmds = append(cmds, child)
+		}
+	}
+	rebuildOf := func(n *tview.TreeNode) firestore.Rebuild { return n.GetReference().(firestore.Rebuild) }
+	if e.layout.sortBy >= 0 {
+		c := e.layout.columns[e.layout.sortBy]
+		slices.SortStableFunc(examples, func(a, b *tview.TreeNode) int {
+			if c.compare != nil {
+				return c.compare(e, rebuildOf(a), rebuildOf(b))
+			}
+			return strings.Compare(c.value(e, rebuildOf(a)), c.value(e, rebuildOf(b)))
+		})
+	} else {
+		order := make(map[string]int)
+		for i, r := range vg.Examples {
+			order[selectionKey(r)] = i
+		}
+		slices.SortStableFunc(examples, func(a, b *tview.TreeNode) int {
+			return cmp.Compare(order[selectionKey(rebuildOf(a))], order[selectionKey(rebuildOf(b))])
+		})
+	}
+	widths := make([]int, len(e.layout.columns))
+	for _, n := range examples {
+		for i, c := range e.layout.columns {
+			widths[i] = max(widths[i], len(c.value(e, rebuildOf(n))))
+		}
+	}
+	for _, n := range examples {
+		n.SetText(e.exampleLabel(rebuildOf(n), widths))
+	}
+	node.SetChildren(append(cmds, examples...))
+}
+
+// cycleSort sorts the rebuild list by the next column, wrapping around to the
+// order of the verdict groups. It must be called from the UI goroutine.
+func (e *explorer) cycleSort() {
+	e.layout.sortBy++
+	if e.layout.sortBy >= len(e.layout.columns) {
+		e.layout.sortBy = -1
+	}
+	if e.layout.sortBy >= 0 {
+		log.Printf("Sorting rebuilds by %s\n", e.layout.columns[e.layout.sortBy].name)
+	} else {
+		log.Println("Sorting rebuilds by verdict group order")
+	}
+	e.root.Walk(func(node, _ *tview.TreeNode) bool {
+		e.layoutGroup(node)
+		return true
+	})
+}
+
+// fetchTriageState fetches whether each rebuild has been annotated, updating the node's layout once complete.
+func (e *explorer) fetchTriageState(node *tview.TreeNode, rebuilds []firestore.Rebuild) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, triageConcurrency)
+	for _, r := range rebuilds {
+		if _, ok := e.annotated.Load(r.ID()); ok {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r firestore.Rebuild) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			annotations, err := e.firestore.FetchAnnotations(e.ctx, r.Target())
+			if err != nil {
+				log.Println(errors.Wrapf(err, "failed to fetch annotations for %s", r.ID()))
+				return
+			}
+			e.annotated.Store(r.ID(), len(annotations) > 0)
+		}(r)
+	}
+	wg.Wait()
+	e.app.QueueUpdateDraw(func() { e.layoutGroup(node) })
+}
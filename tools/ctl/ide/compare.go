@@ -0,0 +1,155 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	tcell "github.com/gdamore/tcell/v2"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+	"github.com/rivo/tview"
+)
+
+// promptCompareRuns asks for two runs to compare, most recent first.
+func (e *explorer) promptCompareRuns() {
+	runs, err := e.firestore.FetchRuns(e.ctx, firestore.FetchRunsOpts{})
+	if err != nil {
+		log.Println(errors.Wrap(err, "failed to fetch runs"))
+		return
+	}
+	var ids []string
+	for _, run := range runs {
+		if run.Type != firestore.AttestMode {
+			ids = append(ids, run.ID)
+		}
+	}
+	if len(ids) < 2 {
+		log.Println("At least two runs are required to compare")
+		return
+	}
+	slices.Sort(ids)
+	slices.Reverse(ids)
+	form := tview.NewForm().
+		AddDropDown("before", ids, 1, nil).
+		AddDropDown("after", ids, 0, nil)
+	form.AddButton("compare", func() {
+		_, before := form.GetFormItemByLabel("before").(*tview.DropDown).GetCurrentOption()
+		_, after := form.GetFormItemByLabel("after").(*tview.DropDown).GetCurrentOption()
+		e.container.RemovePage("compare")
+		go e.compareRuns(before, after)
+	})
+	form.AddButton("cancel", func() { e.container.RemovePage("compare") })
+	form.SetBorder(true).SetTitle("Compare runs")
+	e.app.QueueUpdateDraw(func() {
+		e.container.AddPage("compare", modal(form, 10), true, true)
+	})
+}
+
+// compareRuns shows how the results of the targets of two runs changed.
+func (e *explorer) compareRuns(before, after string) {
+	fetch := func(runid string) (map[string]firestore.Rebuild, error) {
+		return e.firestore.FetchRebuilds(e.ctx, &firestore.FetchRebuildRequest{Runs: []string{runid}, Opts: e.firestoreOpts})
+	}
+	rbBefore, err := fetch(before)
+	if err != nil {
+		log.Println(errors.Wrapf(err, "failed to get rebuilds for runid: %s", before))
+		return
+	}
+	rbAfter, err := fetch(after)
+	if err != nil {
+		log.Println(errors.Wrapf(err, "failed to get rebuilds for runid: %s", after))
+		return
+	}
+	c := firestore.CompareRuns(rbBefore, rbAfter)
+	root := tview.NewTreeNode(fmt.Sprintf("%s -> %s (%d still passing)", before, after, c.StillPassing)).SetColor(tcell.ColorGreen)
+	root.AddChild(e.makeComparisonNode("fixed", tcell.ColorGreen, c.Fixed))
+	root.AddChild(e.makeComparisonNode("regressed", tcell.ColorRed, c.Regressed))
+	root.AddChild(e.makeComparisonNode("still failing", tcell.ColorYellow, c.StillFailing))
+	root.AddChild(e.makeTargetsNode("new", c.New))
+	root.AddChild(e.makeTargetsNode("removed", c.Removed))
+	tree := tview.NewTreeView().SetRoot(root).SetCurrentNode(root)
+	tree.SetBorder(true).SetTitle("Run comparison")
+	tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyESC {
+			e.container.RemovePage("comparison")
+		}
+		return event
+	})
+	e.makeSelectable(tree)
+	e.app.QueueUpdateDraw(func() {
+		e.container.AddPage("comparison", tree, true, true)
+	})
+}
+
+func verdictText(r firestore.Rebuild) string {
+	if r.Message == "" {
+		return "Success!"
+	}
+	return r.Message
+}
+
+// makeComparisonNode groups the targets of a category, each of which expands to its result in both runs.
+func (e *explorer) makeComparisonNode(name string, color tcell.Color, pairs []firestore.RebuildPair) *tview.TreeNode {
+	node := tview.NewTreeNode(fmt.Sprintf("%4d %s", len(pairs), name)).SetColor(color).SetSelectable(true)
+	node.SetSelectedFunc(func() {
+		if len(node.GetChildren()) != 0 {
+			node.SetExpanded(!node.IsExpanded())
+			return
+		}
+		for _, pair := range pairs {
+			pair := pair
+			text := pair.After.ID()
+			if pair.Before.Message != pair.After.Message {
+				text = fmt.Sprintf("%s: %s -> %s", text, verdictText(pair.Before), verdictText(pair.After))
+			} else {
+				text = fmt.Sprintf("%s: %s", text, verdictText(pair.After))
+			}
+			target := tview.NewTreeNode(text).SetColor(tcell.ColorYellow).SetSelectable(true)
+			target.SetSelectedFunc(func() {
+				if len(target.GetChildren()) != 0 {
+					target.SetExpanded(!target.IsExpanded())
+					return
+				}
+				for _, r := range []firestore.Rebuild{pair.Before, pair.After} {
+					example := e.makeExampleNode(r)
+					example.SetText(fmt.Sprintf("%s: %s", r.Run, verdictText(r)))
+					target.AddChild(example)
+				}
+			})
+			node.AddChild(target)
+		}
+	})
+	return node
+}
+
+// makeTargetsNode groups the targets only rebuilt in one of the runs.
+func (e *explorer) makeTargetsNode(name string, rebuilds []firestore.Rebuild) *tview.TreeNode {
+	node := tview.NewTreeNode(fmt.Sprintf("%4d %s", len(rebuilds), name)).SetColor(tcell.ColorDarkCyan).SetSelectable(true)
+	node.SetSelectedFunc(func() {
+		if len(node.GetChildren()) != 0 {
+			node.SetExpanded(!node.IsExpanded())
+			return
+		}
+		for _, r := range rebuilds {
+			example := e.makeExampleNode(r)
+			example.SetText(fmt.Sprintf("%s: %s", r.ID(), verdictText(r)))
+			node.AddChild(example)
+		}
+	})
+	return node
+}
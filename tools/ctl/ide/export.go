@@ -0,0 +1,204 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tcell "github.com/gdamore/tcell/v2"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+	"github.com/rivo/tview"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// rebuildReport is the shareable summary of a rebuild, as pasted into issues and bug reports.
+type rebuildReport struct {
+	Ecosystem string               `json:"ecosystem"`
+	Package   string               `json:"package"`
+	Version   string               `json:"version"`
+	Artifact  string               `json:"artifact"`
+	Run       string               `json:"run"`
+	Success   bool                 `json:"success"`
+	Verdict   string               `json:"verdict"`
+	Strategy  schema.StrategyOneOf `json:"strategy"`
+	// Assets maps the asset type to its URL in the run's asset store.
+	Assets map[rebuild.AssetType]string `json:"assets,omitempty"`
+}
+
+// reportAssets are the asset types linked from a rebuildReport.
+var reportAssets = []rebuild.AssetType{rebuild.DebugRebuildAsset, rebuild.DebugUpstreamAsset, rebuild.DebugLogsAsset}
+
+func newRebuildReport(ctx context.Context, example firestore.Rebuild) (*rebuildReport, error) {
+	r := rebuildReport{
+		Ecosystem: example.Ecosystem,
+		Package:   example.Package,
+		Version:   example.Version,
+		Artifact:  example.Artifact,
+		Run:       example.Run,
+		Success:   example.Success,
+		Verdict:   verdictText(example),
+		Assets:    make(map[rebuild.AssetType]string),
+	}
+	if err := json.Unmarshal([]byte(example.Strategy), &r.Strategy); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal strategy")
+	}
+	if example.Artifact == "" {
+		return &r, nil
+	}
+	store, err := gcsAssetStore(ctx, example.Run)
+	if err != nil {
+		log.Println(errors.Wrap(err, "omitting asset URLs"))
+		return &r, nil
+	}
+	for _, typ := range reportAssets {
+		rc, uri, err := store.Reader(ctx, rebuild.Asset{Target: example.Target(), Type: typ})
+		if err != nil {
+			// NOTE: Not every rebuild produces every asset.
+			continue
+		}
+		rc.Close()
+		r.Assets[typ] = uri
+	}
+	return &r, nil
+}
+
+// JSON returns the report as indented JSON.
+func (r *rebuildReport) JSON() (string, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// Markdown returns the report formatted for an issue comment.
+func (r *rebuildReport) Markdown() (string, error) {
+	strategy := new(bytes.Buffer)
+	enc := yaml.NewEncoder(strategy)
+	enc.SetIndent(2)
+	if err := enc.Encode(r.Strategy); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s %s@%s\n\n", r.Ecosystem, r.Package, r.Version)
+	fmt.Fprintf(&b, "- **Artifact:** `%s`\n", r.Artifact)
+	fmt.Fprintf(&b, "- **Run:** `%s`\n", r.Run)
+	fmt.Fprintf(&b, "- **Verdict:** %s\n", r.Verdict)
+	for _, typ := range reportAssets {
+		if uri, ok := r.Assets[typ]; ok {
+			fmt.Fprintf(&b, "- **%s:** %s\n", typ, uri)
+		}
+	}
+	fmt.Fprintf(&b, "\n**Strategy:**\n\n```yaml\n%s```\n", strategy.String())
+	return b.String(), nil
+}
+
+// clipboardCmds are the commands tried, in order, to write to the system clipboard.
+var clipboardCmds = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	// NOTE: Sets the clipboard of the terminal running tmux using OSC 52.
+	{"tmux", "load-buffer", "-w", "-"},
+}
+
+func copyToClipboard(text string) error {
+	for _, args := range clipboardCmds {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return errors.Wrapf(cmd.Run(), "running %s", args[0])
+	}
+	return errors.New("no clipboard command found")
+}
+
+// exportDetails offers to copy or save the rebuild's report.
+func (e *explorer) exportDetails(ctx context.Context, example firestore.Rebuild) {
+	report, err := newRebuildReport(ctx, example)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	formats := []struct {
+		name, ext string
+		format    func() (string, error)
+	}{
+		{"markdown", "md", report.Markdown},
+		{"JSON", "json", report.JSON},
+	}
+	list := tview.NewList()
+	list.SetBorder(true).SetTitle("Export " + example.ID())
+	closeList := func() { e.container.RemovePage("export") }
+	for _, f := range formats {
+		f := f
+		list.AddItem("copy "+f.name, "", 0, func() {
+			closeList()
+			text, err := f.format()
+			if err != nil {
+				log.Println(errors.Wrapf(err, "failed to format %s", f.name))
+				return
+			}
+			if err := copyToClipboard(text); err != nil {
+				log.Println(errors.Wrap(err, "failed to copy to clipboard"))
+				return
+			}
+			log.Printf("Copied %s details of %s\n", f.name, example.ID())
+		})
+	}
+	for _, f := range formats {
+		f := f
+		list.AddItem("save "+f.name, "", 0, func() {
+			closeList()
+			text, err := f.format()
+			if err != nil {
+				log.Println(errors.Wrapf(err, "failed to format %s", f.name))
+				return
+			}
+			path := filepath.Join("/tmp/oss-rebuild", example.Run, strings.ReplaceAll(example.ID(), "/", "!")+"."+f.ext)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				log.Println(errors.Wrapf(err, "failed to create directory %s", filepath.Dir(path)))
+				return
+			}
+			if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+				log.Println(errors.Wrapf(err, "failed to write %s", path))
+				return
+			}
+			log.Printf("Saved %s details to %s\n", f.name, path)
+		})
+	}
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyESC {
+			closeList()
+		}
+		return event
+	})
+	e.app.QueueUpdateDraw(func() {
+		e.container.AddPage("export", modal(list, 10), true, true)
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tcell "github.com/gdamore/tcell/v2"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+	"github.com/rivo/tview"
+)
+
+// anyEcosystem is the ecosystem option matching all ecosystems.
+const anyEcosystem = "any"
+
+// promptHistory asks for a package, and optionally its ecosystem and version, whose history to show.
+func (e *explorer) promptHistory() {
+	ecosystems := []string{anyEcosystem}
+	for _, eco := range []rebuild.Ecosystem{rebuild.NPM, rebuild.PyPI, rebuild.CratesIO, rebuild.Maven, rebuild.OCI} {
+		ecosystems = append(ecosystems, string(eco))
+	}
+	form := tview.NewForm().
+		AddInputField("package", "", 40, nil, nil).
+		AddInputField("version", "", 20, nil, nil).
+		AddDropDown("ecosystem", ecosystems, 0, nil)
+	form.AddButton("search", func() {
+		req := firestore.FetchHistoryRequest{
+			Package: strings.TrimSpace(form.GetFormItemByLabel("package").(*tview.InputField).GetText()),
+			Version: strings.TrimSpace(form.GetFormItemByLabel("version").(*tview.InputField).GetText()),
+		}
+		if _, eco := form.GetFormItemByLabel("ecosystem").(*tview.DropDown).GetCurrentOption(); eco != anyEcosystem {
+			req.Ecosystem = eco
+		}
+		e.container.RemovePage("history-search")
+		go e.showHistory(&req)
+	})
+	form.AddButton("cancel", func() { e.container.RemovePage("history-search") })
+	form.SetBorder(true).SetTitle("Package history (version requires ecosystem)")
+	e.app.QueueUpdateDraw(func() {
+		e.container.AddPage("history-search", modal(form, 10), true, true)
+	})
+}
+
+// showHistory shows the rebuilds of a package across all runs, oldest first,
+// marking those whose verdict changed from the previous rebuild.
+func (e *explorer) showHistory(req *firestore.FetchHistoryRequest) {
+	log.Printf("Searching history of %s...\n", req.Package)
+	hists, err := e.firestore.FetchHistory(e.ctx, req)
+	if err != nil {
+		log.Println(errors.Wrap(err, "failed to fetch history"))
+		return
+	}
+	if len(hists) == 0 {
+		log.Printf("No rebuilds found for %s\n", req.Package)
+		return
+	}
+	root := tview.NewTreeNode(fmt.Sprintf("%s (%d versions)", req.Package, len(hists))).SetColor(tcell.ColorGreen)
+	for _, h := range hists {
+		root.AddChild(e.makeHistoryNode(h))
+	}
+	tree := tview.NewTreeView().SetRoot(root).SetCurrentNode(root)
+	tree.SetBorder(true).SetTitle("Package history")
+	tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyESC {
+			e.container.RemovePage("history")
+		}
+		return event
+	})
+	e.makeSelectable(tree)
+	e.app.QueueUpdateDraw(func() {
+		e.container.AddPage("history", tree, true, true)
+	})
+}
+
+// makeHistoryNode summarizes the history of a package version, expanding to each of its rebuilds.
+func (e *explorer) makeHistoryNode(h firestore.History) *tview.TreeNode {
+	var changes int
+	for i := range h.Rebuilds {
+		if h.Changed(i) {
+			changes++
+		}
+	}
+	latest := h.Rebuilds[len(h.Rebuilds)-1]
+	color := tcell.ColorRed
+	if latest.Success {
+		color = tcell.ColorGreen
+	}
+	text := fmt.Sprintf("%s: %d rebuilds, %d changes, latest: %s", h.ID, len(h.Rebuilds), changes, verdictText(latest))
+	node := tview.NewTreeNode(tview.Escape(text)).SetColor(color).SetSelectable(true)
+	node.SetSelectedFunc(func() {
+		if len(node.GetChildren()) != 0 {
+			node.SetExpanded(!node.IsExpanded())
+			return
+		}
+		for i, r := range h.Rebuilds {
+			marker := "  "
+			if h.Changed(i) {
+				marker = "> "
+			}
+			example := e.makeExampleNode(r)
+			example.SetText(tview.Escape(fmt.Sprintf("%s%s %s: %s", marker, r.Created.Format(time.DateTime), r.Run, verdictText(r))))
+			if !r.Success {
+				example.SetColor(tcell.ColorRed)
+			}
+			node.AddChild(example)
+		}
+	})
+	return node
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tcell "github.com/gdamore/tcell/v2"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/rivo/tview"
+)
+
+var (
+	ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	// errorMarker matches the lines the pager can jump between with "e" and "E".
+	errorMarker = regexp.MustCompile(`(?i)\b(error|errors|failed|failure|fatal|panic)\b`)
+)
+
+// pager is a scrollable view of text with ANSI colors supporting search and
+// jumping between error markers.
+type pager struct {
+	flex   *tview.Flex
+	text   *tview.TextView
+	status *tview.TextView
+	input  *tview.InputField
+	// lines are the lines of the text with ANSI escapes removed.
+	lines    []string
+	errLines []int
+	matches  []int
+	query    string
+	// failure is the likely cause of a failure found in the text, if any.
+	failure *rebuild.LogFailure
+	// current is the index of the highlighted line, or -1 if there is none.
+	current int
+}
+
+const pagerHelp = "/: search  n/N: next/previous match  e/E: next/previous error  f: failure  g/G: top/bottom  esc: close"
+
+// newPager creates a pager for content, starting at the failure if one is provided.
+func newPager(app *tview.Application, title, content string, failure *rebuild.LogFailure, onExit func()) *pager {
+	p := &pager{
+		failure: failure,
+		flex:    tview.NewFlex().SetDirection(tview.FlexRow),
+		text:    tview.NewTextView().SetDynamicColors(true).SetRegions(true),
+		status:  tview.NewTextView().SetText(pagerHelp),
+		input:   tview.NewInputField().SetLabel("/"),
+		current: -1,
+	}
+	p.lines = strings.Split(ansiEscape.ReplaceAllString(content, ""), "\n")
+	for i, line := range p.lines {
+		if errorMarker.MatchString(line) {
+			p.errLines = append(p.errLines, i)
+		}
+	}
+	// NOTE: Each line is a region so that it can be highlighted and scrolled to.
+	var b strings.Builder
+	for i, line := range strings.Split(content, "\n") {
+		fmt.Fprintf(&b, "[\"%d\"]%s[\"\"]\n", i, tview.TranslateANSI(tview.Escape(line)))
+	}
+	p.text.SetText(b.String())
+	p.text.SetBorder(true).SetTitle(title)
+	p.flex.AddItem(p.text, 0, 1, true).AddItem(p.status, 1, 0, false)
+	p.input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			p.search(p.input.GetText())
+		}
+		p.flex.RemoveItem(p.input)
+		p.flex.AddItem(p.status, 1, 0, false)
+		app.SetFocus(p.text)
+	})
+	p.text.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyESC {
+			onExit()
+			return event
+		}
+		switch event.Rune() {
+		case '/':
+			p.input.SetText("")
+			p.flex.RemoveItem(p.status)
+			p.flex.AddItem(p.input, 1, 0, true)
+			app.SetFocus(p.input)
+			return nil
+		case 'n':
+			p.jump(p.matches, true)
+		case 'N':
+			p.jump(p.matches, false)
+		case 'e':
+			p.jump(p.errLines, true)
+		case 'E':
+			p.jump(p.errLines, false)
+		case 'f':
+			p.jumpToFailure()
+		case 'g':
+			p.text.ScrollToBeginning()
+		case 'G':
+			p.text.ScrollToEnd()
+		default:
+			return event
+		}
+		return nil
+	})
+	p.jumpToFailure()
+	return p
+}
+
+func (p *pager) jumpToFailure() {
+	if p.failure == nil {
+		p.status.SetText("no failure found  " + pagerHelp)
+		return
+	}
+	p.current = p.failure.Line
+	p.text.Highlight(strconv.Itoa(p.current)).ScrollToHighlight()
+	p.status.SetText(fmt.Sprintf("failure at line %d  %s", p.current+1, pagerHelp))
+}
+
+// search finds the lines containing query and highlights the first following the current line.
+func (p *pager) search(query string) {
+	p.query = query
+	p.matches = nil
+	if query == "" {
+		p.status.SetText(pagerHelp)
+		return
+	}
+	lower := strings.ToLower(query)
+	for i, line := range p.lines {
+		if strings.Contains(strings.ToLower(line), lower) {
+			p.matches = append(p.matches, i)
+		}
+	}
+	if len(p.matches) == 0 {
+		p.status.SetText(fmt.Sprintf("pattern not found: %s", query))
+		return
+	}
+	p.jump(p.matches, true)
+}
+
+// jump highlights the next or previous of the given lines relative to the current line, wrapping around.
+func (p *pager) jump(lines []int, forward bool) {
+	if len(lines) == 0 {
+		return
+	}
+	idx := -1
+	if forward {
+		for i, line := range lines {
+			if line > p.current {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			idx = 0
+		}
+	} else {
+		for i := len(lines) - 1; i >= 0; i-- {
+			if lines[i] < p.current {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			idx = len(lines) - 1
+		}
+	}
+	p.current = lines[idx]
+	p.text.Highlight(strconv.Itoa(p.current)).ScrollToHighlight()
+	p.status.SetText(fmt.Sprintf("line %d (%d/%d)  %s", p.current+1, idx+1, len(lines), pagerHelp))
+}
+
+// showPager shows the content in a pager, replacing any pager already shown.
+func (e *explorer) showPager(title, content string, failure *rebuild.LogFailure) {
+	e.app.QueueUpdateDraw(func() {
+		p := newPager(e.app, title, content, failure, func() { e.container.RemovePage("pager") })
+		e.container.AddPage("pager", p.flex, true, true)
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/google/oss-rebuild/internal/httpx"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// Popularity measures how widely a package version is used.
+type Popularity struct {
+	// Downloads is the package's recent download count, if the registry publishes one.
+	Downloads int64
+	// Dependents is the number of packages depending on the version.
+	Dependents int64
+}
+
+// PopularitySource looks up the Popularity of a package version.
+type PopularitySource interface {
+	Popularity(ctx context.Context, t rebuild.Target) (Popularity, error)
+}
+
+// RegistryPopularity reads download counts from the ecosystem's registry and
+// dependent counts from deps.dev.
+type RegistryPopularity struct {
+	Client httpx.BasicClient
+}
+
+var _ PopularitySource = &RegistryPopularity{}
+
+var depsDevSystems = map[rebuild.Ecosystem]string{
+	rebuild.NPM:      "npm",
+	rebuild.PyPI:     "pypi",
+	rebuild.CratesIO: "cargo",
+	rebuild.Maven:    "maven",
+}
+
+// Popularity returns the popularity of t, leaving unpublished measures zero.
+func (p *RegistryPopularity) Popularity(ctx context.Context, t rebuild.Target) (Popularity, error) {
+	var pop Popularity
+	if system, ok := depsDevSystems[t.Ecosystem]; ok {
+		var resp struct {
+			DependentCount int64 `json:"dependentCount"`
+		}
+		u := "https://api.deps.dev/v3alpha/systems/" + system + "/packages/" + url.PathEscape(t.Package) + "/versions/" + url.PathEscape(t.Version) + ":dependents"
+		if err := p.getJSON(ctx, u, &resp); err != nil {
+			return pop, errors.Wrap(err, "fetching dependents")
+		}
+		pop.Dependents = resp.DependentCount
+	}
+	switch t.Ecosystem {
+	case rebuild.NPM:
+		var resp struct {
+			Downloads int64 `json:"downloads"`
+		}
+		if err := p.getJSON(ctx, "https://api.npmjs.org/downloads/point/last-month/"+t.Package, &resp); err != nil {
+			return pop, errors.Wrap(err, "fetching downloads")
+		}
+		pop.Downloads = resp.Downloads
+	case rebuild.CratesIO:
+		var resp struct {
+			Crate struct {
+				RecentDownloads int64 `json:"recent_downloads"`
+			} `json:"crate"`
+		}
+		if err := p.getJSON(ctx, "https://crates.io/api/v1/crates/"+url.PathEscape(t.Package), &resp); err != nil {
+			return pop, errors.Wrap(err, "fetching downloads")
+		}
+		pop.Downloads = resp.Crate.RecentDownloads
+	}
+	return pop, nil
+}
+
+func (p *RegistryPopularity) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// NOTE: Packages unknown to the source have no measurable popularity.
+		return nil
+	} else if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,190 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+)
+
+// JobState is the progress of a local rebuild.
+type JobState int
+
+const (
+	JobQueued JobState = iota
+	JobRunning
+	JobSucceeded
+	JobFailed
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobQueued:
+		return "queued"
+	case JobRunning:
+		return "running"
+	case JobSucceeded:
+		return "succeeded"
+	case JobFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// QueueEntry describes a local rebuild submitted to the Rebuilder.
+type QueueEntry struct {
+	ID    string
+	State JobState
+	// Elapsed is the time spent waiting, if queued, or executing, otherwise.
+	Elapsed time.Duration
+	// LogPath is the file to which the logs of the rebuild are written.
+	LogPath string
+}
+
+type job struct {
+	rebuild  firestore.Rebuild
+	opts     RunLocalOpts
+	logPath  string
+	state    JobState
+	queued   time.Time
+	started  time.Time
+	finished time.Time
+	// worker is the index of the worker executing the job, if running.
+	worker int
+	log    *os.File
+	done   chan struct{}
+}
+
+// jobQueue tracks the local rebuilds of a Rebuilder and bounds how many execute at once.
+type jobQueue struct {
+	jobs []*job
+	// workers holds the indices of the idle workers.
+	workers chan int
+	m       sync.Mutex
+}
+
+func (q *jobQueue) add(r firestore.Rebuild, opts RunLocalOpts, logDir string) *job {
+	j := &job{
+		rebuild: r,
+		opts:    opts,
+		logPath: filepath.Join(logDir, r.Ecosystem, sanitize(r.Package), r.Version+".log"),
+		state:   JobQueued,
+		queued:  time.Now(),
+		done:    make(chan struct{}),
+	}
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.jobs = append(q.jobs, j)
+	return j
+}
+
+// acquire blocks until one of the n workers is available, returning its index
+// or false if ctx is cancelled first.
+func (q *jobQueue) acquire(ctx context.Context, n int) (int, bool) {
+	q.m.Lock()
+	if q.workers == nil {
+		q.workers = make(chan int, n)
+		for i := 0; i < n; i++ {
+			q.workers <- i
+		}
+	}
+	workers := q.workers
+	q.m.Unlock()
+	select {
+	case w := <-workers:
+		return w, true
+	case <-ctx.Done():
+		return 0, false
+	}
+}
+
+func (q *jobQueue) release(worker int) {
+	q.workers <- worker
+}
+
+// start marks the job as running on the worker and returns a logger writing
+// to both the default log and the job's log file.
+func (q *jobQueue) start(j *job, worker int) (*log.Logger, error) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	j.state = JobRunning
+	j.started = time.Now()
+	j.worker = worker
+	if err := os.MkdirAll(filepath.Dir(j.logPath), 0755); err != nil {
+		return nil, errors.Wrap(err, "creating log directory")
+	}
+	f, err := os.Create(j.logPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating log file")
+	}
+	j.log = f
+	return log.New(io.MultiWriter(log.Default().Writer(), f), log.Default().Prefix(), log.Default().Flags()), nil
+}
+
+func (q *jobQueue) finish(j *job, success bool) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	j.state = JobFailed
+	if success {
+		j.state = JobSucceeded
+	}
+	j.finished = time.Now()
+	if j.log != nil {
+		j.log.Close()
+		j.log = nil
+	}
+}
+
+// route writes a line of the logs of a worker's rebuilder instance to the log
+// file of the job it is running.
+func (q *jobQueue) route(worker int, line string) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	for _, j := range q.jobs {
+		if j.log != nil && j.state == JobRunning && j.worker == worker {
+			io.WriteString(j.log, line)
+		}
+	}
+}
+
+func (q *jobQueue) entries() []QueueEntry {
+	q.m.Lock()
+	defer q.m.Unlock()
+	entries := make([]QueueEntry, len(q.jobs))
+	for i, j := range q.jobs {
+		e := QueueEntry{ID: j.rebuild.ID(), State: j.state, LogPath: j.logPath}
+		switch j.state {
+		case JobQueued:
+			e.Elapsed = time.Since(j.queued)
+		case JobRunning:
+			e.Elapsed = time.Since(j.started)
+		default:
+			if !j.started.IsZero() {
+				e.Elapsed = j.finished.Sub(j.started)
+			}
+		}
+		entries[i] = e
+	}
+	return entries
+}
@@ -29,14 +29,17 @@ import (
 	"github.com/google/oss-rebuild/build/binary"
 	"github.com/google/oss-rebuild/build/container"
 	"github.com/google/oss-rebuild/internal/api"
+	"github.com/google/oss-rebuild/internal/httpx"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
 	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/google/oss-rebuild/tools/ctl/hooks"
 	"github.com/google/oss-rebuild/tools/docker"
 	"github.com/pkg/errors"
 )
 
-func logWriter(dest *log.Logger) io.Writer {
+// lineWriter returns a writer passing each line written to it to fn.
+func lineWriter(fn func(line string)) io.Writer {
 	pr, pw := io.Pipe()
 	br := bufio.NewReader(pr)
 	go func() {
@@ -45,12 +48,16 @@ func logWriter(dest *log.Logger) io.Writer {
 			if err != nil {
 				return
 			}
-			dest.Output(1, line)
+			fn(line)
 		}
 	}()
 	return pw
 }
 
+func logWriter(dest *log.Logger) io.Writer {
+	return lineWriter(func(line string) { dest.Output(1, line) })
+}
+
 type instanceState int
 
 const (
@@ -64,21 +71,40 @@ const (
 
 // Instance represents a single run of the rebuilder container.
 type Instance struct {
-	ID     string
+	ID       string
+	runtime  docker.Runtime
+	cacheKey string
+	// populate mounts the dependency caches writable so they are filled by the rebuilds.
+	populate bool
+	// name identifies the instance in the log.
+	name string
+	// port is the host port on which the instance serves.
+	port int
+	// output, if provided, additionally receives the logs of the container.
+	output io.Writer
 	cancel func()
 	state  instanceState
+	start  sync.Once
 }
 
 // Run triggers the startup of the Instance.
-// Should only be called once to initialize an Instance.
+// Calls after the first have no effect.
 func (in *Instance) Run(ctx context.Context) {
+	in.start.Do(func() { in.run(ctx) })
+}
+
+func (in *Instance) run(ctx context.Context) {
 	if in.state != created {
 		return
 	}
 	in.state = starting
 	ctx, in.cancel = context.WithCancel(ctx)
 	// Make the rebuilder write out to the log widget with a [rebuilder] prefix.
-	rblog := log.New(log.Default().Writer(), logPrefix("rebuilder"), 0)
+	rblog := log.New(log.Default().Writer(), logPrefix(in.name), 0)
+	output := logWriter(rblog)
+	if in.output != nil {
+		output = io.MultiWriter(output, in.output)
+	}
 	go func() {
 		in.state = building
 		path, err := binary.Build(ctx, "rebuilder")
@@ -87,7 +113,7 @@ func (in *Instance) Run(ctx context.Context) {
 			in.state = dead
 			return
 		}
-		err = container.Build(ctx, "rebuilder", path)
+		err = container.BuildWith(ctx, in.runtime, "rebuilder", path)
 		if err != nil {
 			rblog.Println("Error building container: ", err.Error())
 			in.state = dead
@@ -96,7 +122,12 @@ func (in *Instance) Run(ctx context.Context) {
 		in.state = running
 		idchan := make(chan string)
 		go func() {
-			err = docker.RunServer(ctx, "rebuilder", 8080, &docker.RunOptions{ID: idchan, Output: logWriter(rblog)})
+			opts := &docker.RunOptions{ID: idchan, Output: output, SecurityOpts: rebuild.Sandbox{}.SecurityOpts(), HostPort: in.port}
+			if in.cacheKey != "" {
+				opts.Volumes = rebuild.DependencyCacheMounts(in.cacheKey, in.populate)
+				opts.Args = []string{"--user-agent=OSSRebuildLocal/0.0.0", "--dependency-cache-key=" + in.cacheKey}
+			}
+			err = in.runtime.RunServer(ctx, "rebuilder", 8080, opts)
 			if err != nil {
 				rblog.Println("Error running rebuilder: ", err.Error())
 				in.state = dead
@@ -116,6 +147,11 @@ func (in *Instance) Kill() {
 	in.state = dead
 }
 
+// URL returns the URL of the Instance's endpoint with the provided path.
+func (in *Instance) URL(path string) *url.URL {
+	return &url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", in.port), Path: path}
+}
+
 // Serving returns whether the Instance is serving.
 func (in *Instance) Serving() bool {
 	return in.state == serving
@@ -147,36 +183,93 @@ func (in *Instance) Wait(ctx context.Context) <-chan error {
 	return out
 }
 
-// Rebuilder manages a local instance of the rebuilder docker container.
+// basePort is the host port of the first rebuilder instance. Those of further
+// workers follow it.
+const basePort = 8080
+
+// Rebuilder manages the local instances of the rebuilder container.
+//
+// Each worker has its own instance so the logs of its container belong only
+// to the rebuild it is executing.
 type Rebuilder struct {
-	instance *Instance
-	m        sync.Mutex
+	// Runtime is the container runtime used to run the rebuilder. Defaults to docker.
+	Runtime docker.Runtime
+	// RemoteAPI, if provided, is the OSS Rebuild API used to execute rebuilds with RunRemote.
+	RemoteAPI *url.URL
+	// RemoteClient is the client used to call RemoteAPI.
+	RemoteClient httpx.BasicClient
+	// DependencyCacheKey, if provided, names the persistent dependency cache volumes mounted into the rebuilder.
+	DependencyCacheKey string
+	// PopulateDependencyCache mounts the dependency cache volumes writable so
+	// that local rebuilds fill them. Rebuilds then execute one at a time.
+	PopulateDependencyCache bool
+	// Workers is the number of local rebuilds executed concurrently, each by
+	// its own rebuilder instance. Defaults to 1.
+	Workers int
+	// LogDir is the directory to which the logs of each local rebuild are written.
+	// Defaults to /tmp/oss-rebuild/logs.
+	LogDir string
+	// Hooks, if provided, are executed as local rebuilds complete.
+	Hooks *hooks.Runner
+	// ReadOnly disallows starting the rebuilder and executing rebuilds.
+	ReadOnly  bool
+	instances []*Instance
+	m         sync.Mutex
+	queue     jobQueue
 }
 
-// Kill does a non-blocking shutdown of the rebuilder container.
+func (rb *Rebuilder) runtime() docker.Runtime {
+	if rb.Runtime == nil {
+		return docker.Docker
+	}
+	return rb.Runtime
+}
+
+// Kill does a non-blocking shutdown of the rebuilder containers.
 func (rb *Rebuilder) Kill() {
 	rb.m.Lock()
 	defer rb.m.Unlock()
-	if rb.instance != nil && !rb.instance.Dead() {
-		log.Println("Killing the exisitng rebuilder")
-		rb.instance.Kill()
-		rb.instance = nil
-		log.Printf("rebuilder exited")
+	for i, inst := range rb.instances {
+		if inst != nil && !inst.Dead() {
+			log.Printf("Killing the existing %s", inst.name)
+			inst.Kill()
+			log.Printf("%s exited", inst.name)
+		}
+		rb.instances[i] = nil
 	}
 }
 
-// Instance returns the underlying rebuilder instance currently in use.
+// Instance returns the rebuilder instance of the first worker.
 func (rb *Rebuilder) Instance() *Instance {
+	return rb.instanceFor(0)
+}
+
+// instanceFor returns the rebuilder instance of the worker with the provided index.
+func (rb *Rebuilder) instanceFor(worker int) *Instance {
 	rb.m.Lock()
 	defer rb.m.Unlock()
-	if rb.instance == nil || rb.instance.Dead() {
-		rb.instance = &Instance{}
+	if worker >= len(rb.instances) {
+		rb.instances = append(rb.instances, make([]*Instance, worker+1-len(rb.instances))...)
 	}
-	return rb.instance
+	if inst := rb.instances[worker]; inst == nil || inst.Dead() {
+		name := "rebuilder"
+		if worker > 0 {
+			name = fmt.Sprintf("rebuilder-%d", worker)
+		}
+		rb.instances[worker] = &Instance{
+			runtime:  rb.runtime(),
+			cacheKey: rb.DependencyCacheKey,
+			populate: rb.PopulateDependencyCache,
+			name:     name,
+			port:     basePort + worker,
+			output:   lineWriter(func(line string) { rb.queue.route(worker, line) }),
+		}
+	}
+	return rb.instances[worker]
 }
 
-func (rb *Rebuilder) runningInstance(ctx context.Context) (*Instance, error) {
-	inst := rb.Instance()
+func (rb *Rebuilder) runningInstance(ctx context.Context, worker int) (*Instance, error) {
+	inst := rb.instanceFor(worker)
 	inst.Run(ctx)
 	ctxtimeout, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
@@ -188,11 +281,26 @@ func (rb *Rebuilder) runningInstance(ctx context.Context) (*Instance, error) {
 	return inst, nil
 }
 
+var errReadOnly = errors.New("rebuilds are disallowed in read-only mode")
+
+// Start starts the rebuilder container of the first worker, if not already
+// running, and returns it once serving.
+func (rb *Rebuilder) Start(ctx context.Context) (*Instance, error) {
+	if rb.ReadOnly {
+		return nil, errReadOnly
+	}
+	return rb.runningInstance(ctx, 0)
+}
+
 // Restart restarts the rebuilder container.
 func (rb *Rebuilder) Restart(ctx context.Context) {
+	if rb.ReadOnly {
+		log.Println(errReadOnly)
+		return
+	}
 	rb.Kill()
 	log.Println("Starting new local instance of the rebuilder.")
-	_, err := rb.runningInstance(ctx)
+	_, err := rb.runningInstance(ctx, 0)
 	if err != nil {
 		log.Println(err)
 	}
@@ -202,37 +310,207 @@ type RunLocalOpts struct {
 	Strategy *schema.StrategyOneOf
 }
 
-// RunLocal runs the rebuilder for the given example.
+// RunLocal runs the rebuilder for the given example, blocking until it completes.
+//
+// Up to Workers local rebuilds execute concurrently with the remainder queued.
 func (rb *Rebuilder) RunLocal(ctx context.Context, r firestore.Rebuild, opts RunLocalOpts) {
-	_, err := rb.runningInstance(ctx)
+	<-rb.Enqueue(ctx, r, opts)
+}
+
+// Enqueue queues a local rebuild of the given example, returning a channel
+// which is closed once it completes.
+func (rb *Rebuilder) Enqueue(ctx context.Context, r firestore.Rebuild, opts RunLocalOpts) <-chan struct{} {
+	if rb.ReadOnly {
+		log.Println(errReadOnly)
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	j := rb.queue.add(r, opts, rb.logDir())
+	go func() {
+		defer close(j.done)
+		worker, ok := rb.queue.acquire(ctx, rb.workers())
+		if !ok {
+			rb.queue.finish(j, false)
+			return
+		}
+		defer rb.queue.release(worker)
+		rb.queue.finish(j, rb.runJob(ctx, j, worker))
+	}()
+	return j.done
+}
+
+func (rb *Rebuilder) workers() int {
+	if rb.PopulateDependencyCache {
+		return 1
+	}
+	return max(rb.Workers, 1)
+}
+
+func (rb *Rebuilder) logDir() string {
+	if rb.LogDir == "" {
+		return "/tmp/oss-rebuild/logs"
+	}
+	return rb.LogDir
+}
+
+// runJob executes the local rebuild on the worker's instance and returns whether it succeeded.
+func (rb *Rebuilder) runJob(ctx context.Context, j *job, worker int) bool {
+	jl, err := rb.queue.start(j, worker)
 	if err != nil {
-		log.Println(err.Error())
+		log.Println(errors.Wrapf(err, "creating log for %s", j.rebuild.ID()))
+		jl = log.Default()
+	}
+	inst, err := rb.runningInstance(ctx, worker)
+	if err != nil {
+		jl.Println(err.Error())
+		return false
+	}
+	jl.Printf("Calling the %s for %s\n", inst.name, j.rebuild.ID())
+	u := inst.URL("/smoketest")
+	jl.Println("Requesting a smoketest from: " + u.String())
+	stub := api.Stub[schema.SmoketestRequest, schema.SmoketestResponse](http.DefaultClient, *u)
+	resp, err := stub(ctx, smoketestRequest(j.rebuild, j.opts))
+	if err != nil {
+		jl.Println(err.Error())
+		return false
+	}
+	success := logVerdict(jl, resp)
+	rb.fireHooks(ctx, j, success, resp)
+	return success
+}
+
+// fireHooks executes the hooks for the completion of a local rebuild.
+func (rb *Rebuilder) fireHooks(ctx context.Context, j *job, success bool, resp *schema.SmoketestResponse) {
+	r := j.rebuild
+	data := hooks.Rebuild{
+		Ecosystem:       r.Ecosystem,
+		Package:         r.Package,
+		Version:         r.Version,
+		Artifact:        r.Artifact,
+		Run:             r.Run,
+		Success:         success,
+		PreviousSuccess: r.Success,
+		PreviousMessage: r.Message,
+		LogPath:         j.logPath,
+	}
+	if len(resp.Verdicts) == 1 {
+		data.Message = resp.Verdicts[0].Message
+	}
+	events := []hooks.EventType{hooks.RebuildCompleted}
+	if data.Success != data.PreviousSuccess || data.Message != data.PreviousMessage {
+		events = append(events, hooks.VerdictChanged)
+	}
+	for _, typ := range events {
+		if err := rb.Hooks.Fire(ctx, hooks.Event{Type: typ, Data: data}); err != nil {
+			log.Println(errors.Wrapf(err, "%s hooks for %s", typ, r.ID()))
+		}
+	}
+}
+
+// Queue returns the local rebuilds in the order they were queued.
+func (rb *Rebuilder) Queue() []QueueEntry {
+	return rb.queue.entries()
+}
+
+// Remote returns whether the Rebuilder is configured to execute rebuilds remotely.
+func (rb *Rebuilder) Remote() bool {
+	return rb.RemoteAPI != nil
+}
+
+// RunRemote runs the given example on the remote builders behind RemoteAPI.
+//
+// Once the rebuild completes, its logs are fetched from the debug bucket, if
+// configured, and written to the log with a [remote] prefix.
+func (rb *Rebuilder) RunRemote(ctx context.Context, r firestore.Rebuild, opts RunLocalOpts) {
+	if !rb.Remote() {
+		log.Println("No remote API configured")
+		return
+	} else if rb.ReadOnly {
+		log.Println(errReadOnly)
 		return
 	}
-	log.Printf("Calling the rebuilder for %s\n", r.ID())
-	u, err := url.Parse("http://localhost:8080/smoketest")
+	client := rb.RemoteClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u := rb.RemoteAPI.JoinPath("smoketest")
+	log.Println("Requesting a remote smoketest from: " + u.String())
+	req := smoketestRequest(r, opts)
+	stub := api.Stub[schema.SmoketestRequest, schema.SmoketestResponse](client, *u)
+	resp, err := stub(ctx, req)
 	if err != nil {
 		log.Println(err.Error())
 		return
 	}
-	log.Println("Requesting a smoketest from: " + u.String())
-	stub := api.Stub[schema.SmoketestRequest, schema.SmoketestResponse](http.DefaultClient, *u)
-	resp, err := stub(ctx, schema.SmoketestRequest{
+	remotelog := log.New(log.Default().Writer(), logPrefix("remote"), 0)
+	if err := copyRemoteLogs(ctx, req.ID, resp, remotelog); err != nil {
+		log.Println(errors.Wrap(err, "fetching remote logs"))
+	}
+	logVerdict(log.Default(), resp)
+}
+
+// FollowLogs copies the logs of the in-progress remote rebuild of r to w until the build completes.
+func (rb *Rebuilder) FollowLogs(ctx context.Context, r firestore.Rebuild, w io.Writer) error {
+	if !rb.Remote() {
+		return errors.New("no remote API configured")
+	}
+	client := rb.RemoteClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	stub := api.StreamStub[schema.LogsRequest](client, *rb.RemoteAPI.JoinPath("logs"))
+	t := r.Target()
+	body, err := stub(ctx, schema.LogsRequest{Ecosystem: t.Ecosystem, Package: t.Package, Version: t.Version, Artifact: t.Artifact, ID: r.Run})
+	if err != nil {
+		return errors.Wrap(err, "requesting logs")
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return errors.Wrap(err, "reading logs")
+}
+
+func copyRemoteLogs(ctx context.Context, runID string, resp *schema.SmoketestResponse, dest *log.Logger) error {
+	store, err := gcsAssetStore(ctx, runID)
+	if err != nil {
+		return err
+	}
+	for _, v := range resp.Verdicts {
+		r, _, err := store.Reader(ctx, rebuild.Asset{Type: rebuild.DebugLogsAsset, Target: v.Target})
+		if err != nil {
+			return errors.Wrapf(err, "reading logs for %s", v.Target.Artifact)
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			dest.Println(scanner.Text())
+		}
+		r.Close()
+		if err := scanner.Err(); err != nil {
+			return errors.Wrapf(err, "copying logs for %s", v.Target.Artifact)
+		}
+	}
+	return nil
+}
+
+func smoketestRequest(r firestore.Rebuild, opts RunLocalOpts) schema.SmoketestRequest {
+	return schema.SmoketestRequest{
 		Ecosystem: rebuild.Ecosystem(r.Ecosystem),
 		Package:   r.Package,
 		Versions:  []string{r.Version},
 		ID:        time.Now().UTC().Format(time.RFC3339),
 		Strategy:  opts.Strategy,
-	})
-	if err != nil {
-		log.Println(err.Error())
-		return
 	}
+}
+
+// logVerdict logs the result of the smoketest and returns whether it succeeded.
+func logVerdict(l *log.Logger, resp *schema.SmoketestResponse) bool {
+	success := len(resp.Verdicts) == 1 && resp.Verdicts[0].Message == ""
 	msg := "FAILED"
-	if len(resp.Verdicts) == 1 && resp.Verdicts[0].Message == "" {
+	if success {
 		msg = "SUCCESS"
 	}
-	log.Printf("Smoketest %s:\n%v", msg, resp)
+	l.Printf("Smoketest %s:\n%v", msg, resp)
+	return success
 }
 
 // Attach opens a new tmux window that's attached to the rebuilder container.
@@ -241,6 +519,6 @@ func (rb *Rebuilder) Attach(ctx context.Context) error {
 	if !inst.Serving() {
 		return errors.New("rebuilder container not serving")
 	}
-	cmd := exec.CommandContext(ctx, "tmux", "new-window", fmt.Sprintf("docker exec -it %s sh", inst.ID))
+	cmd := exec.CommandContext(ctx, "tmux", "new-window", rb.runtime().ShellCommand(inst.ID))
 	return cmd.Run()
 }
@@ -0,0 +1,207 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	tcell "github.com/gdamore/tcell/v2"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/rivo/tview"
+)
+
+// rebuildGroupCmd is a command executed on a group of rebuilds.
+type rebuildGroupCmd struct {
+	Name string
+	Func func(ctx context.Context, rebuilds []firestore.Rebuild)
+}
+
+// rebuildGroupCmds returns the commands available for groups of rebuilds,
+// whether predefined, like verdict groups, or selected by hand.
+func (e *explorer) rebuildGroupCmds() []rebuildGroupCmd {
+	if e.rb.ReadOnly {
+		return nil
+	}
+	return []rebuildGroupCmd{
+		{
+			Name: "run all local",
+			Func: func(ctx context.Context, rebuilds []firestore.Rebuild) {
+				for _, example := range rebuilds {
+					e.rb.Enqueue(ctx, example, RunLocalOpts{})
+				}
+				log.Printf("Queued %d local rebuilds\n", len(rebuilds))
+			},
+		},
+	}
+}
+
+const selectedMarker = "* "
+
+// selection is a hand-picked set of rebuilds.
+type selection struct {
+	mu       sync.Mutex
+	rebuilds map[string]firestore.Rebuild
+	// visual selects each rebuild as the cursor moves onto it.
+	visual bool
+}
+
+func newSelection() *selection {
+	return &selection{rebuilds: make(map[string]firestore.Rebuild)}
+}
+
+func selectionKey(r firestore.Rebuild) string {
+	return r.ID() + "@" + r.Run
+}
+
+// toggle flips the selection of the rebuild referenced by node, if any.
+func (s *selection) toggle(node *tview.TreeNode) {
+	r, ok := node.GetReference().(firestore.Rebuild)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, selected := s.rebuilds[selectionKey(r)]; selected {
+		delete(s.rebuilds, selectionKey(r))
+		node.SetText(strings.TrimPrefix(node.GetText(), selectedMarker))
+	} else {
+		s.rebuilds[selectionKey(r)] = r
+		node.SetText(selectedMarker + node.GetText())
+	}
+}
+
+// add selects the rebuild referenced by node, if any.
+func (s *selection) add(node *tview.TreeNode) {
+	r, ok := node.GetReference().(firestore.Rebuild)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	_, selected := s.rebuilds[selectionKey(r)]
+	s.mu.Unlock()
+	if !selected {
+		s.toggle(node)
+	}
+}
+
+// contains returns whether the rebuild is selected.
+func (s *selection) contains(r firestore.Rebuild) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, selected := s.rebuilds[selectionKey(r)]
+	return selected
+}
+
+// list returns the selected rebuilds ordered by target.
+func (s *selection) list() []firestore.Rebuild {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rebuilds := make([]firestore.Rebuild, 0, len(s.rebuilds))
+	for _, r := range s.rebuilds {
+		rebuilds = append(rebuilds, r)
+	}
+	slices.SortFunc(rebuilds, func(a, b firestore.Rebuild) int { return strings.Compare(selectionKey(a), selectionKey(b)) })
+	return rebuilds
+}
+
+// clear deselects all rebuilds, unmarking the nodes beneath roots.
+func (s *selection) clear(roots ...*tview.TreeNode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.rebuilds)
+	s.visual = false
+	for _, root := range roots {
+		root.Walk(func(node, _ *tview.TreeNode) bool {
+			node.SetText(strings.TrimPrefix(node.GetText(), selectedMarker))
+			return true
+		})
+	}
+}
+
+// makeSelectable enables selecting the rebuilds of tree.
+//
+// Space toggles the rebuild under the cursor and "V" toggles visual mode in
+// which each rebuild is selected as the cursor moves onto it. The selection
+// is acted upon using showSelection.
+func (e *explorer) makeSelectable(tree *tview.TreeView) {
+	capture := tree.GetInputCapture()
+	tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyRune && event.Rune() == ' ':
+			if node := tree.GetCurrentNode(); node != nil {
+				e.selection.toggle(node)
+			}
+			return nil
+		case event.Key() == tcell.KeyRune && event.Rune() == 'V':
+			e.selection.mu.Lock()
+			e.selection.visual = !e.selection.visual
+			visual := e.selection.visual
+			e.selection.mu.Unlock()
+			if node := tree.GetCurrentNode(); visual && node != nil {
+				e.selection.add(node)
+			}
+			log.Printf("Visual mode: %t\n", visual)
+			return nil
+		}
+		if capture != nil {
+			return capture(event)
+		}
+		return event
+	})
+	tree.SetChangedFunc(func(node *tview.TreeNode) {
+		e.selection.mu.Lock()
+		visual := e.selection.visual
+		e.selection.mu.Unlock()
+		if visual {
+			e.selection.add(node)
+		}
+	})
+}
+
+// showSelection offers the group commands for the selected rebuilds.
+func (e *explorer) showSelection() {
+	rebuilds := e.selection.list()
+	list := tview.NewList()
+	list.SetBorder(true).SetTitle(fmt.Sprintf("%d selected rebuilds", len(rebuilds)))
+	closeList := func() { e.container.RemovePage("selection") }
+	for _, cmd := range e.rebuildGroupCmds() {
+		cmd := cmd
+		list.AddItem(cmd.Name, "", 0, func() {
+			closeList()
+			go cmd.Func(e.ctx, rebuilds)
+		})
+	}
+	list.AddItem("clear selection", "", 0, func() {
+		closeList()
+		e.selection.clear(e.root)
+	})
+	for _, r := range rebuilds {
+		list.AddItem(r.ID(), r.Run+": "+verdictText(r), 0, nil)
+	}
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyESC {
+			closeList()
+		}
+		return event
+	})
+	e.app.QueueUpdateDraw(func() {
+		e.container.AddPage("selection", modal(list, 10), true, true)
+	})
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+	"github.com/rivo/tview"
+)
+
+// Session is the state of the TUI restored across restarts.
+//
+// Tree nodes are identified by the path of keys from the root to the node.
+type Session struct {
+	// Expanded are the paths of the expanded nodes, each preceding its descendants.
+	Expanded [][]string `json:"expanded,omitempty"`
+	// Current is the path of the node under the cursor.
+	Current []string `json:"current,omitempty"`
+	// Triage is the ID of the current rebuild in the triage of each run.
+	Triage map[string]string `json:"triage,omitempty"`
+}
+
+// sessionSaveInterval is the period at which the session is saved while the TUI runs.
+const sessionSaveInterval = 10 * time.Second
+
+// LoadSession reads the session at path, returning an empty session if none exists.
+func LoadSession(path string) (*Session, error) {
+	var s Session
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, errors.Wrap(err, "parsing session")
+	}
+	return &s, nil
+}
+
+// Save writes the session to path, replacing any existing session.
+func (s *Session) Save(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// NOTE: Write then rename so a crash mid-write leaves the previous session intact.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// benchRef and runRef are the references of benchmark and run nodes.
+type benchRef string
+type runRef string
+
+// nodeKey identifies the node among its siblings in a way that is stable across restarts.
+func nodeKey(node *tview.TreeNode) string {
+	switch ref := node.GetReference().(type) {
+	case benchRef:
+		return string(ref)
+	case runRef:
+		return string(ref)
+	case *firestore.VerdictGroup:
+		return ref.Msg
+	case firestore.Rebuild:
+		return ref.ID()
+	default:
+		return node.GetText()
+	}
+}
+
+func findChild(node *tview.TreeNode, key string) *tview.TreeNode {
+	for _, child := range node.GetChildren() {
+		if nodeKey(child) == key {
+			return child
+		}
+	}
+	return nil
+}
+
+// findPath returns the node at path, or nil if it does not exist.
+func (e *explorer) findPath(path []string) *tview.TreeNode {
+	node := e.root
+	for _, key := range path {
+		if node = findChild(node, key); node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// snapshot returns the current session. It must be called from the UI goroutine.
+func (e *explorer) snapshot() *Session {
+	s := Session{Triage: make(map[string]string)}
+	current := e.tree.GetCurrentNode()
+	var walk func(node *tview.TreeNode, path []string)
+	walk = func(node *tview.TreeNode, path []string) {
+		for _, child := range node.GetChildren() {
+			childPath := append(path[:len(path):len(path)], nodeKey(child))
+			if child == current {
+				s.Current = childPath
+			}
+			if len(child.GetChildren()) != 0 && child.IsExpanded() {
+				s.Expanded = append(s.Expanded, childPath)
+				walk(child, childPath)
+			}
+		}
+	}
+	walk(e.root, nil)
+	e.triagePosMu.Lock()
+	defer e.triagePosMu.Unlock()
+	for run, id := range e.triagePos {
+		s.Triage[run] = id
+	}
+	return &s
+}
+
+// onUI executes fn on the UI goroutine, blocking until it completes.
+func (e *explorer) onUI(fn func()) {
+	done := make(chan struct{})
+	e.app.QueueUpdateDraw(func() {
+		defer close(done)
+		fn()
+	})
+	<-done
+}
+
+// restore expands the nodes and moves the cursor as recorded in the session.
+// It must be called after LoadTree and not from the UI goroutine.
+func (e *explorer) restore(s *Session) {
+	e.triagePosMu.Lock()
+	for run, id := range s.Triage {
+		e.triagePos[run] = id
+	}
+	e.triagePosMu.Unlock()
+	for _, path := range s.Expanded {
+		var node *tview.TreeNode
+		e.onUI(func() {
+			if node = e.findPath(path); node == nil || len(node.GetChildren()) != 0 {
+				return
+			}
+			if _, ok := node.GetReference().(runRef); ok {
+				// NOTE: Runs are loaded below to avoid blocking the UI.
+				return
+			}
+			if populate, ok := e.populators.Load(node); ok {
+				populate.(func())()
+			}
+		})
+		if node == nil {
+			continue
+		}
+		if run, ok := node.GetReference().(runRef); ok {
+			var loaded bool
+			e.onUI(func() { loaded = len(node.GetChildren()) != 0 })
+			if !loaded {
+				e.loadRun(node, string(run))
+			}
+		}
+		e.onUI(func() { node.SetExpanded(true) })
+	}
+	e.onUI(func() {
+		if node := e.findPath(s.Current); node != nil && s.Current != nil {
+			e.tree.SetCurrentNode(node)
+		}
+	})
+}
+
+// persist periodically saves the session to path until the context is cancelled.
+func (e *explorer) persist(path string) {
+	ticker := time.NewTicker(sessionSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var s *Session
+		e.onUI(func() { s = e.snapshot() })
+		if err := s.Save(path); err != nil {
+			log.Println(errors.Wrap(err, "saving session"))
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ide
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	tcell "github.com/gdamore/tcell/v2"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+	"github.com/rivo/tview"
+)
+
+// mismatchVerdicts are the verdict fragments reported when a rebuild's content differs from upstream.
+var mismatchVerdicts = []string{
+	"found in upstream but not rebuild",
+	"found in rebuild but not upstream",
+	"mismatched file(s)",
+	"differences found",
+	"moved between upstream and rebuild",
+	"line endings found in upstream",
+}
+
+// isMismatch returns whether the rebuild completed but differed from upstream.
+func isMismatch(r firestore.Rebuild) bool {
+	if r.Risk != nil {
+		return true
+	}
+	for _, v := range mismatchVerdicts {
+		if strings.Contains(r.Message, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// impactScore ranks a mismatch by the harm of it going unexamined.
+//
+// Risk is weighted most heavily since it indicates tampering. Popularity is
+// scored logarithmically so that it orders mismatches of similar risk.
+func impactScore(r firestore.Rebuild, p Popularity) float64 {
+	return float64(r.RiskScore()) + 5*math.Log10(1+float64(p.Downloads)) + 5*math.Log10(1+float64(p.Dependents))
+}
+
+// triageItem is a mismatch awaiting triage.
+type triageItem struct {
+	rebuild    firestore.Rebuild
+	popularity Popularity
+	score      float64
+	// ready is closed once the assets have been fetched.
+	ready chan struct{}
+	// logs, rba, and usa are the local paths of the fetched assets.
+	logs, rba, usa string
+	err            error
+}
+
+// triageConcurrency is the number of mismatches whose impact is computed concurrently.
+const triageConcurrency = 10
+
+// buildTriageQueue returns the unannotated mismatches among rebuilds, highest impact first.
+func buildTriageQueue(ctx context.Context, reader firestore.Reader, pop PopularitySource, rebuilds map[string]firestore.Rebuild) []*triageItem {
+	var mu sync.Mutex
+	var items []*triageItem
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, triageConcurrency)
+	for _, r := range rebuilds {
+		if !isMismatch(r) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r firestore.Rebuild) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			annotations, err := reader.FetchAnnotations(ctx, r.Target())
+			if err != nil {
+				log.Println(errors.Wrapf(err, "failed to fetch annotations for %s", r.ID()))
+			} else if len(annotations) > 0 {
+				// NOTE: Annotated mismatches have already been triaged.
+				return
+			}
+			p, err := pop.Popularity(ctx, r.Target())
+			if err != nil {
+				log.Println(errors.Wrapf(err, "failed to fetch popularity for %s", r.ID()))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			items = append(items, &triageItem{rebuild: r, popularity: p, score: impactScore(r, p), ready: make(chan struct{})})
+		}(r)
+	}
+	wg.Wait()
+	slices.SortFunc(items, func(a, b *triageItem) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.rebuild.ID(), b.rebuild.ID())
+	})
+	return items
+}
+
+// prefetch fetches the assets of each item in queue order.
+func prefetch(ctx context.Context, items []*triageItem) {
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return
+		}
+		paths, err := fetchAssets(ctx, item.rebuild, rebuild.DebugLogsAsset, rebuild.DebugRebuildAsset, rebuild.DebugUpstreamAsset)
+		if err != nil {
+			item.err = err
+		} else {
+			item.logs, item.rba, item.usa = paths[0], paths[1], paths[2]
+		}
+		close(item.ready)
+	}
+}
+
+func (item *triageItem) fetched() bool {
+	select {
+	case <-item.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func (item *triageItem) describe(pos, total int) string {
+	var b strings.Builder
+	r := item.rebuild
+	fmt.Fprintf(&b, "[%d/%d] %s\n\n", pos, total, r.ID())
+	fmt.Fprintf(&b, "artifact:   %s\n", r.Artifact)
+	fmt.Fprintf(&b, "verdict:    %s\n", r.Message)
+	fmt.Fprintf(&b, "impact:     %.1f\n", item.score)
+	fmt.Fprintf(&b, "risk:       %d\n", r.RiskScore())
+	fmt.Fprintf(&b, "downloads:  %d\n", item.popularity.Downloads)
+	fmt.Fprintf(&b, "dependents: %d\n", item.popularity.Dependents)
+	if r.Risk != nil {
+		for _, ev := range r.Risk.Evidence {
+			fmt.Fprintf(&b, "  %s: %s %s\n", ev.Indicator, ev.Path, ev.Detail)
+		}
+	}
+	if r.FailureSnippet != "" {
+		fmt.Fprintf(&b, "failure:\n  %s\n", strings.ReplaceAll(r.FailureSnippet, "\n", "\n  "))
+	}
+	switch {
+	case !item.fetched():
+		b.WriteString("\nassets:     fetching...\n")
+	case item.err != nil:
+		fmt.Fprintf(&b, "\nassets:     %v\n", item.err)
+	default:
+		b.WriteString("\nassets:     ready\n")
+	}
+	b.WriteString("\nn: next  p: previous  d: diff  l: logs  b: build local  i: details  esc: exit")
+	return b.String()
+}
+
+// triage walks through the unresolved mismatches of a run, highest impact first.
+func (e *explorer) triage(runid string) {
+	rebuilds, err := e.firestore.FetchRebuilds(e.ctx, &firestore.FetchRebuildRequest{Runs: []string{runid}, Opts: e.firestoreOpts})
+	if err != nil {
+		log.Println(errors.Wrapf(err, "failed to get rebuilds for runid: %s", runid))
+		return
+	}
+	items := buildTriageQueue(e.ctx, e.firestore, e.popularity, rebuilds)
+	if len(items) == 0 {
+		log.Printf("No unresolved mismatches in run %s\n", runid)
+		return
+	}
+	log.Printf("Triaging %d unresolved mismatches in run %s\n", len(items), runid)
+	ctx, cancel := context.WithCancel(e.ctx)
+	go prefetch(ctx, items)
+	tv := tview.NewTextView()
+	tv.SetBorder(true).SetTitle("Triage " + runid)
+	// NOTE: idx must only be accessed from the UI goroutine.
+	var idx int
+	e.triagePosMu.Lock()
+	if id, ok := e.triagePos[runid]; ok {
+		// Resume from the position of the previous triage of the run.
+		idx = max(slices.IndexFunc(items, func(item *triageItem) bool { return item.rebuild.ID() == id }), 0)
+	}
+	e.triagePosMu.Unlock()
+	render := func() {
+		tv.SetText(items[idx].describe(idx+1, len(items)))
+		e.triagePosMu.Lock()
+		e.triagePos[runid] = items[idx].rebuild.ID()
+		e.triagePosMu.Unlock()
+	}
+	withAssets := func(fn func(item *triageItem)) {
+		item := items[idx]
+		go func() {
+			<-item.ready
+			if item.err != nil {
+				log.Println(item.err)
+				return
+			}
+			fn(item)
+		}()
+	}
+	// NOTE: Keys must not collide with the TuiApp's commands which are handled regardless of focus.
+	tv.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyESC {
+			cancel()
+			e.container.RemovePage("triage")
+			return event
+		}
+		switch event.Rune() {
+		case 'n':
+			idx = min(idx+1, len(items)-1)
+			render()
+		case 'p':
+			idx = max(idx-1, 0)
+			render()
+		case 'd':
+			withAssets(func(item *triageItem) { e.openDiff(item.rba, item.usa) })
+		case 'l':
+			withAssets(func(item *triageItem) { e.openLogs(item.rebuild.Target().Ecosystem, item.logs) })
+		case 'b':
+			// NOTE: RunLocal refuses in read-only mode.
+			go e.rb.RunLocal(e.ctx, items[idx].rebuild, RunLocalOpts{})
+		case 'i':
+			go e.showDetails(e.ctx, items[idx].rebuild)
+		}
+		return event
+	})
+	e.app.QueueUpdateDraw(func() {
+		render()
+		e.container.AddPage("triage", modal(tv, 10), true, true)
+	})
+	// Refresh the current item as its assets become available.
+	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			return
+		case <-item.ready:
+			e.app.QueueUpdateDraw(render)
+		}
+	}
+}
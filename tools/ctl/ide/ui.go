@@ -21,18 +21,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	tcell "github.com/gdamore/tcell/v2"
 	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/google/oss-rebuild/internal/httpx"
 	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/assistant"
+	"github.com/google/oss-rebuild/tools/ctl/failures"
 	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/google/oss-rebuild/tools/ctl/hooks"
+	"github.com/google/oss-rebuild/tools/ctl/pipe"
+	"github.com/google/oss-rebuild/tools/docker"
 	"github.com/pkg/errors"
 	"github.com/rivo/tview"
 	yaml "gopkg.in/yaml.v3"
@@ -57,27 +68,75 @@ type explorer struct {
 	container     *tview.Pages
 	tree          *tview.TreeView
 	root          *tview.TreeNode
+	logs          *tview.TextView
 	rb            *Rebuilder
-	firestore     *firestore.Client
+	firestore     firestore.Reader
 	firestoreOpts firestore.FetchRebuildOpts
+	popularity    PopularitySource
+	selection     *selection
+	// populators holds the function populating the children of each lazyNode.
+	populators sync.Map
+	// triagePos is the ID of the current rebuild in the triage of each run.
+	triagePos   map[string]string
+	triagePosMu sync.Mutex
+	// layout is the layout of the rebuilds listed in each verdict group.
+	layout listLayout
+	// annotated records whether each rebuild has been annotated, keyed by ID.
+	annotated sync.Map
+	// assistant, if provided, drafts upstream bug reports and diagnoses failures.
+	assistant *assistant.Selector
+	// logConfig configures how logs are provided to the assistant.
+	logConfig assistant.LogConfig
+	// failures, if provided, is searched for past failures similar to those explored.
+	failures *failures.Index
+	embedder failures.Embedder
 }
 
-func newExplorer(ctx context.Context, app *tview.Application, firestore *firestore.Client, firestoreOpts firestore.FetchRebuildOpts, rb *Rebuilder) *explorer {
+func newExplorer(ctx context.Context, app *tview.Application, logs *tview.TextView, firestore firestore.Reader, firestoreOpts firestore.FetchRebuildOpts, rb *Rebuilder, popularity PopularitySource, layout listLayout, assistant *assistant.Selector, logConfig assistant.LogConfig) *explorer {
 	e := explorer{
 		ctx:           ctx,
 		app:           app,
 		container:     tview.NewPages(),
 		tree:          tview.NewTreeView(),
 		root:          tview.NewTreeNode("root").SetColor(tcell.ColorRed),
+		logs:          logs,
 		rb:            rb,
 		firestore:     firestore,
 		firestoreOpts: firestoreOpts,
+		popularity:    popularity,
+		selection:     newSelection(),
+		triagePos:     make(map[string]string),
+		layout:        layout,
+		assistant:     assistant,
+		logConfig:     logConfig,
 	}
 	e.tree.SetRoot(e.root).SetCurrentNode(e.root)
+	e.tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && event.Rune() == 'o' {
+			e.cycleSort()
+			return nil
+		}
+		return event
+	})
+	e.makeSelectable(e.tree)
 	e.container.AddPage("explorer", e.tree, true, true)
 	return &e
 }
 
+// lazyNode makes node populate its children using populate when first
+// selected, toggling its expansion thereafter.
+func (e *explorer) lazyNode(node *tview.TreeNode, populate func()) *tview.TreeNode {
+	e.populators.Store(node, populate)
+	node.SetSelectedFunc(func() {
+		if len(node.GetChildren()) == 0 {
+			populate()
+		} else {
+			node.SetExpanded(!node.IsExpanded())
+		}
+	})
+	return node
+}
+
 func makeCommandNode(name string, handler func()) *tview.TreeNode {
 	return tview.NewTreeNode(name).SetColor(tcell.ColorDarkCyan).SetSelectedFunc(handler)
 }
@@ -87,7 +146,9 @@ func sanitize(name string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(name, "@", ""), "/", "-")
 }
 
-func localAssetStore(ctx context.Context, runID string) (rebuild.AssetStore, error) {
+// LocalAssetStore returns the store of assets from the run kept on the local machine,
+// including the build definitions edited during local rebuilds.
+func LocalAssetStore(ctx context.Context, runID string) (rebuild.AssetStore, error) {
 	// TODO: Maybe this should be a different ctx variable?
 	dir := filepath.Join("/tmp/oss-rebuild", runID)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -105,48 +166,78 @@ func gcsAssetStore(ctx context.Context, runID string) (rebuild.AssetStore, error
 	if !ok {
 		return nil, errors.Errorf("GCS bucket was not specified")
 	}
-	return rebuild.NewGCSStore(context.WithValue(ctx, rebuild.RunID, runID), bucket)
+	return rebuild.NewAssetStoreFromURL(context.WithValue(ctx, rebuild.RunID, runID), bucket)
 }
 
-func diffArtifacts(ctx context.Context, example firestore.Rebuild) {
+// fetchAssets copies the example's assets of the provided types from GCS to
+// the local asset store, returning their local paths.
+func fetchAssets(ctx context.Context, example firestore.Rebuild, types ...rebuild.AssetType) ([]string, error) {
 	if example.Artifact == "" {
-		log.Println("Firestore does not have the artifact, cannot find GCS path.")
-		return
+		return nil, errors.New("firestore does not have the artifact, cannot find GCS path")
 	}
-	t := rebuild.Target{
-		Ecosystem: rebuild.Ecosystem(example.Ecosystem),
-		Package:   example.Package,
-		Version:   example.Version,
-		Artifact:  example.Artifact,
-	}
-	localAssets, err := localAssetStore(ctx, example.Run)
+	localAssets, err := LocalAssetStore(ctx, example.Run)
 	if err != nil {
-		log.Println(errors.Wrap(err, "failed to create local asset store"))
-		return
+		return nil, errors.Wrap(err, "failed to create local asset store")
 	}
 	gcsAssets, err := gcsAssetStore(ctx, example.Run)
 	if err != nil {
-		log.Println(errors.Wrap(err, "failed to create gcs asset store"))
-		return
+		return nil, errors.Wrap(err, "failed to create gcs asset store")
 	}
 	// TODO: Clean up these artifacts.
 	// TODO: Check if these are already downloaded.
-	var rba, usa string
-	rba, err = rebuild.AssetCopy(ctx, localAssets, gcsAssets, rebuild.Asset{Target: t, Type: rebuild.DebugRebuildAsset})
+	paths := make([]string, 0, len(types))
+	for _, typ := range types {
+		path, err := rebuild.AssetCopy(ctx, localAssets, gcsAssets, rebuild.Asset{Target: example.Target(), Type: typ})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to copy %s asset", typ)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func (e *explorer) diffArtifacts(ctx context.Context, example firestore.Rebuild) {
+	paths, err := fetchAssets(ctx, example, rebuild.DebugRebuildAsset, rebuild.DebugUpstreamAsset)
 	if err != nil {
-		log.Println(errors.Wrap(err, "failed to copy rebuild asset"))
+		log.Println(err)
 		return
 	}
-	usa, err = rebuild.AssetCopy(ctx, localAssets, gcsAssets, rebuild.Asset{Target: t, Type: rebuild.DebugUpstreamAsset})
+	log.Printf("downloaded rebuild and upstream:\n\t%s\n\t%s", paths[0], paths[1])
+	e.openDiff(paths[0], paths[1])
+}
+
+// diffoscope returns the differences between the rebuild and upstream artifacts.
+func diffoscope(rba, usa string, color bool) (string, error) {
+	textColor := "--text-color=never"
+	if color {
+		textColor = "--text-color=always"
+	}
+	out, err := exec.Command("diffoscope", textColor, rba, usa).Output()
+	// NOTE: diffoscope exits with status 1 when differences are found.
+	if exitErr, ok := err.(*exec.ExitError); err != nil && !(ok && exitErr.ExitCode() == 1) {
+		return "", errors.Wrap(err, "failed to run diffoscope")
+	}
+	return string(out), nil
+}
+
+// openDiff shows the differences between the rebuild and upstream artifacts in a pager.
+func (e *explorer) openDiff(rba, usa string) {
+	out, err := diffoscope(rba, usa, true)
 	if err != nil {
-		log.Println(errors.Wrap(err, "failed to copy upstream asset"))
+		log.Println(err)
 		return
 	}
-	log.Printf("downloaded rebuild and upstream:\n\t%s\n\t%s", rba, usa)
-	cmd := exec.Command("tmux", "new-window", fmt.Sprintf("diffoscope --text-color=always %s %s | less -R", rba, usa))
-	if err := cmd.Run(); err != nil {
-		log.Println(errors.Wrap(err, "failed to run diffoscope"))
+	e.showPager("Diff "+filepath.Base(rba), out, nil)
+}
+
+// openLogs shows the build logs of a rebuild in the ecosystem in a pager, starting at the likely failure.
+func (e *explorer) openLogs(eco rebuild.Ecosystem, logs string) {
+	content, err := os.ReadFile(logs)
+	if err != nil {
+		log.Println(errors.Wrap(err, "failed to read logs"))
+		return
 	}
+	e.showPager("Logs "+filepath.Base(logs), string(content), rebuild.FindLogFailure(eco, string(content)))
 }
 
 func (e *explorer) showModal(ctx context.Context, tv *tview.TextView, onExit func()) {
@@ -170,20 +261,30 @@ func (e *explorer) showDetails(ctx context.Context, example firestore.Rebuild) {
 		log.Println(errors.Wrap(err, "failed to unmarshal strategy"))
 		return
 	}
+	annotations, err := e.firestore.FetchAnnotations(ctx, example.Target())
+	if err != nil {
+		log.Println(errors.Wrap(err, "failed to fetch annotations"))
+	}
 	type detailsStruct struct {
-		Success  bool
-		Message  string
-		Timings  rebuild.Timings
-		Strategy schema.StrategyOneOf
+		Success        bool
+		Message        string
+		Timings        rebuild.Timings
+		Strategy       schema.StrategyOneOf
+		Risk           *rebuild.RiskAssessment `yaml:",omitempty"`
+		FailureSnippet string                  `yaml:",omitempty"`
+		Annotations    []schema.Annotation     `yaml:",omitempty"`
 	}
 	detailsYaml := new(bytes.Buffer)
 	enc := yaml.NewEncoder(detailsYaml)
 	enc.SetIndent(2)
-	err := enc.Encode(detailsStruct{
-		Success:  example.Success,
-		Message:  example.Message,
-		Timings:  example.Timings,
-		Strategy: stratOneof,
+	err = enc.Encode(detailsStruct{
+		Success:        example.Success,
+		Message:        example.Message,
+		Timings:        example.Timings,
+		Strategy:       stratOneof,
+		Risk:           example.Risk,
+		FailureSnippet: example.FailureSnippet,
+		Annotations:    annotations,
 	})
 	if err != nil {
 		log.Println(errors.Wrap(err, "failed to marshal details"))
@@ -194,39 +295,92 @@ func (e *explorer) showDetails(ctx context.Context, example firestore.Rebuild) {
 }
 
 func (e *explorer) showLogs(ctx context.Context, example firestore.Rebuild) {
-	if example.Artifact == "" {
-		log.Println("Firestore does not have the artifact, cannot find GCS path.")
+	paths, err := fetchAssets(ctx, example, rebuild.DebugLogsAsset)
+	if err != nil {
+		log.Println(err)
 		return
 	}
-	t := rebuild.Target{
-		Ecosystem: rebuild.Ecosystem(example.Ecosystem),
-		Package:   example.Package,
-		Version:   example.Version,
-		Artifact:  example.Artifact,
+	e.openLogs(example.Target().Ecosystem, paths[0])
+}
+
+// diagnoseLogs asks the assistant for the root cause of the failure in the rebuild's logs.
+func (e *explorer) diagnoseLogs(ctx context.Context, example firestore.Rebuild) {
+	paths, err := fetchAssets(ctx, example, rebuild.DebugLogsAsset)
+	if err != nil {
+		log.Println(err)
+		return
 	}
-	localAssets, err := localAssetStore(ctx, example.Run)
+	logs, err := os.ReadFile(paths[0])
 	if err != nil {
-		log.Println(errors.Wrap(err, "failed to create local asset store"))
+		log.Println(errors.Wrap(err, "failed to read logs"))
 		return
 	}
-	gcsAssets, err := gcsAssetStore(ctx, example.Run)
+	log.Printf("Diagnosing %d bytes of logs for %s...\n", len(logs), example.ID())
+	diagnosis, err := assistant.DiagnoseLogs(ctx, e.assistant.For(assistant.DiagnoseLogsTask), example.Target(), string(logs), e.logConfig)
 	if err != nil {
-		log.Println(errors.Wrap(err, "failed to create gcs asset store"))
+		log.Println(errors.Wrap(err, "failed to diagnose logs"))
 		return
 	}
-	logs, err := rebuild.AssetCopy(ctx, localAssets, gcsAssets, rebuild.Asset{Target: t, Type: rebuild.DebugLogsAsset})
+	e.showPager("Diagnosis "+example.ID(), diagnosis, nil)
+}
+
+// maxSimilarFailures is the number of similar past failures shown.
+const maxSimilarFailures = 5
+
+// showSimilarFailures lists the indexed past failures most similar to the rebuild's and how they were resolved.
+func (e *explorer) showSimilarFailures(ctx context.Context, example firestore.Rebuild) {
+	matches, err := e.failures.Similar(ctx, e.embedder, example, maxSimilarFailures)
 	if err != nil {
-		log.Println(errors.Wrap(err, "failed to copy rebuild asset"))
+		log.Println(errors.Wrap(err, "failed to search failures"))
 		return
 	}
-	cmd := exec.Command("tmux", "new-window", fmt.Sprintf("cat %s | less", logs))
-	if err := cmd.Run(); err != nil {
-		log.Println(errors.Wrap(err, "failed to read logs"))
+	var b strings.Builder
+	failures.Format(&b, matches)
+	e.showPager("Similar failures "+example.ID(), b.String(), nil)
+}
+
+// showQueue shows the state of the local rebuilds, refreshing until dismissed.
+func (e *explorer) showQueue(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	tv := tview.NewTextView()
+	tv.SetBorder(true).SetTitle("Local rebuild queue")
+	e.showModal(ctx, tv, cancel)
+	for {
+		var b strings.Builder
+		for _, entry := range e.rb.Queue() {
+			fmt.Fprintf(&b, "%-9s %6s %s\n\t%s\n", entry.State, entry.Elapsed.Round(time.Second), entry.ID, entry.LogPath)
+		}
+		text := b.String()
+		e.app.QueueUpdateDraw(func() { tv.SetText(text) })
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
 	}
 }
 
+// followLogs shows the logs of the example's in-progress remote build, following new output until it completes.
+func (e *explorer) followLogs(ctx context.Context, example firestore.Rebuild) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tv := tview.NewTextView().SetChangedFunc(func() { e.app.Draw() })
+	tv.ScrollToEnd()
+	tv.SetBorder(true).SetTitle("Remote build logs (following)")
+	e.showModal(ctx, tv, cancel)
+	if err := e.rb.FollowLogs(ctx, example, tv); err != nil {
+		if ctx.Err() == nil {
+			log.Println(errors.Wrap(err, "following logs"))
+		}
+		return
+	}
+	e.app.QueueUpdateDraw(func() {
+		tv.SetTitle("Remote build logs (complete)")
+	})
+}
+
 func (e *explorer) editAndRun(ctx context.Context, example firestore.Rebuild) error {
-	localAssets, err := localAssetStore(ctx, example.Run)
+	localAssets, err := LocalAssetStore(ctx, example.Run)
 	if err != nil {
 		return errors.Wrap(err, "failed to create local asset store")
 	}
@@ -280,12 +434,13 @@ func (e *explorer) editAndRun(ctx context.Context, example firestore.Rebuild) er
 	return nil
 }
 
+// maxFailureLabel is the maximum length of the failure shown in an example's label.
+const maxFailureLabel = 80
+
 func (e *explorer) makeExampleNode(example firestore.Rebuild) *tview.TreeNode {
-	name := fmt.Sprintf("%s [%ds]", example.ID(), int(example.Timings.EstimateCleanBuild().Seconds()))
-	node := tview.NewTreeNode(name).SetColor(tcell.ColorYellow)
-	node.SetSelectedFunc(func() {
-		children := node.GetChildren()
-		if len(children) == 0 {
+	node := tview.NewTreeNode(e.exampleLabel(example, nil)).SetColor(tcell.ColorYellow).SetReference(example)
+	return e.lazyNode(node, func() {
+		if !e.rb.ReadOnly {
 			node.AddChild(makeCommandNode("run local", func() {
 				go e.rb.RunLocal(e.ctx, example, RunLocalOpts{})
 			}))
@@ -295,6 +450,11 @@ func (e *explorer) makeExampleNode(example firestore.Rebuild) *tview.TreeNode {
 					e.rb.RunLocal(e.ctx, example, RunLocalOpts{})
 				}()
 			}))
+			if e.rb.Remote() {
+				node.AddChild(makeCommandNode("run remote", func() {
+					go e.rb.RunRemote(e.ctx, example, RunLocalOpts{})
+				}))
+			}
 			node.AddChild(makeCommandNode("edit and run local", func() {
 				go func() {
 					if err := e.editAndRun(e.ctx, example); err != nil {
@@ -302,20 +462,40 @@ func (e *explorer) makeExampleNode(example firestore.Rebuild) *tview.TreeNode {
 					}
 				}()
 			}))
-			node.AddChild(makeCommandNode("details", func() {
-				go e.showDetails(e.ctx, example)
+		}
+		node.AddChild(makeCommandNode("details", func() {
+			go e.showDetails(e.ctx, example)
+		}))
+		node.AddChild(makeCommandNode("export details", func() {
+			go e.exportDetails(e.ctx, example)
+		}))
+		node.AddChild(makeCommandNode("logs", func() {
+			go e.showLogs(e.ctx, example)
+		}))
+		if e.assistant != nil && !example.Success {
+			node.AddChild(makeCommandNode("diagnose logs", func() {
+				go e.diagnoseLogs(e.ctx, example)
+			}))
+		}
+		if e.failures != nil && !example.Success {
+			node.AddChild(makeCommandNode("similar past failures", func() {
+				go e.showSimilarFailures(e.ctx, example)
 			}))
-			node.AddChild(makeCommandNode("logs", func() {
-				go e.showLogs(e.ctx, example)
+		}
+		if e.rb.Remote() {
+			node.AddChild(makeCommandNode("follow remote logs", func() {
+				go e.followLogs(e.ctx, example)
 			}))
-			node.AddChild(makeCommandNode("diff", func() {
-				go diffArtifacts(e.ctx, example)
+		}
+		node.AddChild(makeCommandNode("diff", func() {
+			go e.diffArtifacts(e.ctx, example)
+		}))
+		if e.assistant != nil && isMismatch(example) {
+			node.AddChild(makeCommandNode("draft upstream bug report", func() {
+				go e.draftBugReport(e.ctx, example)
 			}))
-		} else {
-			node.SetExpanded(!node.IsExpanded())
 		}
 	})
-	return node
 }
 
 func (e *explorer) makeVerdictGroupNode(vg *firestore.VerdictGroup, percent float32) *tview.TreeNode {
@@ -331,60 +511,81 @@ func (e *explorer) makeVerdictGroupNode(vg *firestore.VerdictGroup, percent floa
 	} else {
 		pct = fmt.Sprintf("%3.0f%%", percent)
 	}
-	node := tview.NewTreeNode(fmt.Sprintf("%4d %s %s", vg.Count, pct, msg)).SetColor(tcell.ColorGreen).SetSelectable(true).SetReference(vg)
-	node.SetSelectedFunc(func() {
-		children := node.GetChildren()
-		if len(children) == 0 {
-			for _, example := range vg.Examples {
-				node.AddChild(e.makeExampleNode(example))
-			}
-		} else {
-			node.SetExpanded(!node.IsExpanded())
+	color := tcell.ColorGreen
+	if vg.MaxRisk > 0 {
+		msg = fmt.Sprintf("(risk %d) %s", vg.MaxRisk, msg)
+		color = tcell.ColorRed
+	}
+	node := tview.NewTreeNode(fmt.Sprintf("%4d %s %s", vg.Count, pct, msg)).SetColor(color).SetSelectable(true).SetReference(vg)
+	return e.lazyNode(node, func() {
+		for _, cmd := range e.rebuildGroupCmds() {
+			cmd := cmd
+			node.AddChild(makeCommandNode(cmd.Name, func() {
+				go cmd.Func(e.ctx, vg.Examples)
+			}))
+		}
+		for _, example := range vg.Examples {
+			node.AddChild(e.makeExampleNode(example))
+		}
+		e.layoutGroup(node)
+		if e.layout.has("triage") {
+			go e.fetchTriageState(node, vg.Examples)
 		}
 	})
-	return node
 }
 
 func (e *explorer) makeRunNode(runid string) *tview.TreeNode {
-	node := tview.NewTreeNode(runid).SetColor(tcell.ColorGreen).SetSelectable(true)
-	node.SetSelectedFunc(func() {
-		children := node.GetChildren()
-		if len(children) == 0 {
-			rebuilds, err := e.firestore.FetchRebuilds(e.ctx, &firestore.FetchRebuildRequest{Runs: []string{runid}, Opts: e.firestoreOpts})
-			if err != nil {
-				log.Println(errors.Wrapf(err, "failed to get rebuilds for runid: %s", runid))
-				return
-			}
-			byCount := firestore.GroupRebuilds(rebuilds)
-			for i := len(byCount) - 1; i >= 0; i-- {
-				vgnode := e.makeVerdictGroupNode(byCount[i], 100*float32(byCount[i].Count)/float32(len(rebuilds)))
-				node.AddChild(vgnode)
-			}
-		} else {
-			node.SetExpanded(!node.IsExpanded())
+	node := tview.NewTreeNode(runid).SetColor(tcell.ColorGreen).SetSelectable(true).SetReference(runRef(runid))
+	return e.lazyNode(node, func() {
+		go e.loadRun(node, runid)
+	})
+}
+
+// loadRun populates the run's node with its verdict groups, showing the progress of the fetch in the title of the log pane.
+func (e *explorer) loadRun(node *tview.TreeNode, runid string) {
+	progress := &pipe.Progress{}
+	stop := progress.Report(e.ctx, time.Second, func(s string) {
+		e.app.QueueUpdateDraw(func() { e.logs.SetTitle(fmt.Sprintf("Logs (loading %s: %s)", runid, s)) })
+	})
+	rebuilds, err := e.firestore.FetchRebuilds(e.ctx, &firestore.FetchRebuildRequest{Runs: []string{runid}, Opts: e.firestoreOpts, Progress: progress})
+	stop()
+	e.app.QueueUpdateDraw(func() { e.logs.SetTitle("Logs") })
+	if err != nil {
+		log.Println(errors.Wrapf(err, "failed to get rebuilds for runid: %s", runid))
+		return
+	}
+	byCount := firestore.GroupRebuilds(rebuilds)
+	e.app.QueueUpdateDraw(func() {
+		if len(node.GetChildren()) != 0 {
+			// NOTE: The run was loaded concurrently.
+			return
+		}
+		node.AddChild(makeCommandNode("triage mismatches by impact", func() {
+			go e.triage(runid)
+		}))
+		for i := len(byCount) - 1; i >= 0; i-- {
+			vgnode := e.makeVerdictGroupNode(byCount[i], 100*float32(byCount[i].Count)/float32(len(rebuilds)))
+			node.AddChild(vgnode)
 		}
 	})
-	return node
 }
 
 func (e *explorer) makeRunGroupNode(benchName string, runs []string) *tview.TreeNode {
-	node := tview.NewTreeNode(fmt.Sprintf("%3d %s", len(runs), benchName)).SetColor(tcell.ColorGreen).SetSelectable(true)
-	node.SetSelectedFunc(func() {
-		children := node.GetChildren()
-		if len(children) == 0 {
-			for _, run := range runs {
-				node.AddChild(e.makeRunNode(run))
-			}
-		} else {
-			node.SetExpanded(!node.IsExpanded())
+	node := tview.NewTreeNode(fmt.Sprintf("%3d %s", len(runs), benchName)).SetColor(tcell.ColorGreen).SetSelectable(true).SetReference(benchRef(benchName))
+	return e.lazyNode(node, func() {
+		for _, run := range runs {
+			node.AddChild(e.makeRunNode(run))
 		}
 	})
-	return node
 }
 
 // LoadTree will query firestore for all the runs, then display them.
 func (e *explorer) LoadTree() error {
 	e.root.ClearChildren()
+	e.populators.Range(func(node, _ any) bool {
+		e.populators.Delete(node)
+		return true
+	})
 	runs, err := e.firestore.FetchRuns(e.ctx, firestore.FetchRunsOpts{})
 	if err != nil {
 		return err
@@ -415,6 +616,8 @@ type tuiAppCmd struct {
 	Name string
 	Rune rune
 	Func func()
+	// Rebuilder is whether the command controls the rebuilder, disallowed in read-only mode.
+	Rebuilder bool
 }
 
 // TuiApp represents the entire IDE, containing UI widgets and worker processes.
@@ -426,10 +629,51 @@ type TuiApp struct {
 	logs      *tview.TextView
 	cmds      []tuiAppCmd
 	rb        *Rebuilder
+	// sessionPath is the file to which the session is persisted, if any.
+	sessionPath string
+	// restored is set once the session has been restored and may be saved.
+	restored atomic.Bool
+}
+
+// TuiAppOpts configures optional behavior of the TuiApp.
+type TuiAppOpts struct {
+	// Runtime is the container runtime used for local rebuilds. Defaults to docker.
+	Runtime docker.Runtime
+	// RemoteAPI, if provided, enables running rebuilds on the remote builders behind this API.
+	RemoteAPI *url.URL
+	// RemoteClient is the client used to call RemoteAPI.
+	RemoteClient httpx.BasicClient
+	// DependencyCacheKey, if provided, names the dependency cache volumes mounted into the local rebuilder.
+	DependencyCacheKey string
+	// LocalWorkers is the number of local rebuilds executed concurrently. Defaults to 1.
+	LocalWorkers int
+	// Popularity is used to rank mismatches for triage. Defaults to the registries and deps.dev.
+	Popularity PopularitySource
+	// Hooks, if provided, are executed as local rebuilds complete.
+	// Their output is written to the log with a [hooks] prefix.
+	Hooks *hooks.Runner
+	// ReadOnly disallows the rebuilder and the commands executing rebuilds.
+	ReadOnly bool
+	// SessionPath, if provided, is the file from which the session is restored
+	// on startup and to which it is periodically saved.
+	SessionPath string
+	// Columns are the names of the columns shown for each rebuild, in order. Defaults to DefaultColumns.
+	Columns []string
+	// SortBy, if provided, is the name of the column by which rebuilds are initially sorted.
+	SortBy string
+	// Assistant, if provided, enables drafting upstream bug reports for
+	// mismatches and diagnosing failures from their logs.
+	Assistant *assistant.Selector
+	// AssistantLogs configures how logs are provided to Assistant. Defaults to assistant.DefaultLogConfig.
+	AssistantLogs assistant.LogConfig
+	// Failures, if provided, enables searching for similar past failures
+	// using Embedder, which must be the model which embedded the index.
+	Failures *failures.Index
+	Embedder failures.Embedder
 }
 
 // NewTuiApp creates a new tuiApp object.
-func NewTuiApp(ctx context.Context, fireClient *firestore.Client, firestoreOpts firestore.FetchRebuildOpts) *TuiApp {
+func NewTuiApp(ctx context.Context, fireClient firestore.Reader, firestoreOpts firestore.FetchRebuildOpts, opts TuiAppOpts) *TuiApp {
 	var t *TuiApp
 	{
 		app := tview.NewApplication()
@@ -440,22 +684,38 @@ func NewTuiApp(ctx context.Context, fireClient *firestore.Client, firestoreOpts
 		log.Default().SetPrefix(logPrefix("ctl"))
 		log.Default().SetFlags(0)
 		logs.SetBorder(true).SetTitle("Logs")
-		rb := &Rebuilder{}
+		rb := &Rebuilder{Runtime: opts.Runtime, RemoteAPI: opts.RemoteAPI, RemoteClient: opts.RemoteClient, DependencyCacheKey: opts.DependencyCacheKey, Workers: opts.LocalWorkers, ReadOnly: opts.ReadOnly}
+		if opts.Hooks != nil {
+			h := *opts.Hooks
+			h.Output = logWriter(log.New(log.Default().Writer(), logPrefix("hooks"), 0))
+			rb.Hooks = &h
+		}
+		popularity := opts.Popularity
+		if popularity == nil {
+			popularity = &RegistryPopularity{Client: http.DefaultClient}
+		}
+		logConfig := opts.AssistantLogs
+		if logConfig.Strategy == "" {
+			logConfig = assistant.DefaultLogConfig
+		}
 		t = &TuiApp{
 			Ctx:      ctx,
 			app:      app,
-			explorer: newExplorer(ctx, app, fireClient, firestoreOpts, rb),
+			explorer: newExplorer(ctx, app, logs, fireClient, firestoreOpts, rb, popularity, newListLayout(opts.Columns, opts.SortBy), opts.Assistant, logConfig),
 			// When the widgets are updated, we should refresh the application.
-			statusBox: tview.NewTextView().SetChangedFunc(func() { app.Draw() }),
-			logs:      logs,
-			rb:        rb,
+			statusBox:   tview.NewTextView().SetChangedFunc(func() { app.Draw() }),
+			logs:        logs,
+			rb:          rb,
+			sessionPath: opts.SessionPath,
 		}
+		t.explorer.failures, t.explorer.embedder = opts.Failures, opts.Embedder
 	}
 	t.cmds = []tuiAppCmd{
 		{
-			Name: "restart rebuilder",
-			Rune: 'r',
-			Func: func() { t.rb.Restart(t.Ctx) },
+			Name:      "restart rebuilder",
+			Rune:      'r',
+			Func:      func() { t.rb.Restart(t.Ctx) },
+			Rebuilder: true,
 		},
 		{
 			Name: "kill rebuilder",
@@ -463,6 +723,7 @@ func NewTuiApp(ctx context.Context, fireClient *firestore.Client, firestoreOpts
 			Func: func() {
 				t.rb.Kill()
 			},
+			Rebuilder: true,
 		},
 		{
 			Name: "attach",
@@ -473,6 +734,27 @@ func NewTuiApp(ctx context.Context, fireClient *firestore.Client, firestoreOpts
 				}
 				t.updateStatus()
 			},
+			Rebuilder: true,
+		},
+		{
+			Name: "queue",
+			Rune: 'q',
+			Func: func() { t.explorer.showQueue(t.Ctx) },
+		},
+		{
+			Name: "compare runs",
+			Rune: 'c',
+			Func: func() { t.explorer.promptCompareRuns() },
+		},
+		{
+			Name: "package history",
+			Rune: 'H',
+			Func: func() { t.explorer.promptHistory() },
+		},
+		{
+			Name: "selection",
+			Rune: 's',
+			Func: func() { t.explorer.showSelection() },
 		},
 		{
 			Name: "logs up",
@@ -496,6 +778,9 @@ func NewTuiApp(ctx context.Context, fireClient *firestore.Client, firestoreOpts
 			},
 		},
 	}
+	if opts.ReadOnly {
+		t.cmds = slices.DeleteFunc(t.cmds, func(cmd tuiAppCmd) bool { return cmd.Rebuilder })
+	}
 
 	var root tview.Primitive
 	{
@@ -535,6 +820,10 @@ func NewTuiApp(ctx context.Context, fireClient *firestore.Client, firestoreOpts
 			t.rb.Kill()
 			return event
 		}
+		if _, ok := t.app.GetFocus().(*tview.InputField); ok {
+			// NOTE: Text entry must not trigger commands.
+			return event
+		}
 		for _, cmd := range t.cmds {
 			if event.Rune() == cmd.Rune {
 				go cmd.Func()
@@ -571,6 +860,27 @@ func (t *TuiApp) Run() error {
 		}
 		t.app.Draw()
 		log.Println("Finished loading the tree.")
+		if t.sessionPath == "" {
+			return
+		}
+		session, err := LoadSession(t.sessionPath)
+		if err != nil {
+			log.Println(errors.Wrap(err, "loading session"))
+		} else {
+			t.explorer.restore(session)
+			log.Println("Restored the previous session.")
+		}
+		t.restored.Store(true)
+		t.explorer.persist(t.sessionPath)
 	}()
-	return t.app.Run()
+	if err := t.app.Run(); err != nil {
+		return err
+	}
+	if t.restored.Load() {
+		// NOTE: The app has stopped so the tree may be read from this goroutine.
+		if err := t.explorer.snapshot().Save(t.sessionPath); err != nil {
+			return errors.Wrap(err, "saving session")
+		}
+	}
+	return nil
 }
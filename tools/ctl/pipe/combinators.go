@@ -0,0 +1,95 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipe
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Backoff configures the retries of Retry.
+type Backoff struct {
+	// Attempts is the maximum number of calls, including the first.
+	Attempts int
+	// Initial is the delay before the first retry. It doubles for each subsequent retry.
+	Initial time.Duration
+	// Max, if non-zero, bounds the delay between retries.
+	Max time.Duration
+}
+
+// Retry adapts fn for use with DoErr or IntoErr, retrying failed calls with exponential backoff.
+// The error of the final attempt is returned if all attempts fail.
+func Retry[T, S any](b Backoff, fn func(context.Context, T) (S, error)) func(context.Context, T, chan<- S) error {
+	return func(ctx context.Context, in T, out chan<- S) error {
+		delay := b.Initial
+		for attempt := 1; ; attempt++ {
+			s, err := fn(ctx, in)
+			if err == nil {
+				out <- s
+				return nil
+			}
+			if attempt >= b.Attempts {
+				return errors.Wrapf(err, "failed after %d attempts", attempt)
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay *= 2
+			if b.Max > 0 && delay > b.Max {
+				delay = b.Max
+			}
+		}
+	}
+}
+
+// RateLimit adds a stage passing through at most burst items at once and one item per interval thereafter.
+func (p Pipe[T]) RateLimit(interval time.Duration, burst int) Pipe[T] {
+	g := p.g
+	return p.DoFor(func(in <-chan T, out chan<- T) {
+		defer close(out)
+		tokens := make(chan struct{}, max(burst, 1))
+		for len(tokens) < cap(tokens) {
+			tokens <- struct{}{}
+		}
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					select {
+					case tokens <- struct{}{}:
+					default:
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+		for t := range in {
+			select {
+			case <-tokens:
+				out <- t
+			case <-g.ctx.Done():
+			}
+		}
+	})
+}
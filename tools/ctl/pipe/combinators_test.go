@@ -0,0 +1,155 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipe
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRetry(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	for _, tc := range []struct {
+		name      string
+		failures  int
+		attempts  int
+		wantCalls int
+		wantOut   []int
+		wantErr   bool
+	}{
+		{name: "first attempt", failures: 0, attempts: 3, wantCalls: 1, wantOut: []int{2}},
+		{name: "after retries", failures: 2, attempts: 3, wantCalls: 3, wantOut: []int{2}},
+		{name: "exhausted", failures: 3, attempts: 3, wantCalls: 3, wantErr: true},
+		{name: "single attempt", failures: 1, attempts: 1, wantCalls: 1, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int
+			fn := Retry(Backoff{Attempts: tc.attempts, Initial: time.Millisecond}, func(ctx context.Context, in int) (int, error) {
+				calls++
+				if calls <= tc.failures {
+					return 0, errFlaky
+				}
+				return in * 2, nil
+			})
+			out := make(chan int, 1)
+			err := fn(context.Background(), 1, out)
+			close(out)
+			if tc.wantErr {
+				if errors.Cause(err) != errFlaky {
+					t.Errorf("error = %v, want the final attempt's error", err)
+				}
+			} else if err != nil {
+				t.Errorf("error = %v, want nil", err)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tc.wantCalls)
+			}
+			if got := collect(out); !slices.Equal(got, tc.wantOut) {
+				t.Errorf("out = %v, want %v", got, tc.wantOut)
+			}
+		})
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	fn := Retry(Backoff{Attempts: 5, Initial: time.Hour}, func(ctx context.Context, in int) (int, error) {
+		calls++
+		cancel()
+		return 0, errors.New("flaky")
+	})
+	if err := fn(ctx, 1, make(chan int)); err != context.Canceled {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryPipe(t *testing.T) {
+	var calls int
+	p := From(source(1, 2)).DoErr(Retry(Backoff{Attempts: 2, Initial: time.Millisecond}, func(ctx context.Context, in int) (int, error) {
+		calls++
+		return 0, errors.Errorf("failed %d", in)
+	}))
+	collect(p.Out())
+	if err := p.Err(); err == nil || errors.Cause(err).Error() != "failed 1" {
+		t.Errorf("Err() = %v, want failed 1", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want the 2 attempts of the first item", calls)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	in := make(chan int)
+	p := From(in).RateLimit(interval, 2)
+	// NOTE: Idling first would accrue tokens were refill not capped at the burst.
+	time.Sleep(3 * interval)
+	start := time.Now()
+	go func() {
+		defer close(in)
+		for i := 0; i < 4; i++ {
+			in <- i
+		}
+	}()
+	var elapsed []time.Duration
+	for range p.Out() {
+		elapsed = append(elapsed, time.Since(start))
+	}
+	if len(elapsed) != 4 {
+		t.Fatalf("got %d items, want 4", len(elapsed))
+	}
+	if elapsed[1] >= interval {
+		t.Errorf("burst took %v, want under %v", elapsed[1], interval)
+	}
+	// The two items beyond the burst each wait for a token to be refilled.
+	if elapsed[3] < 3*interval/2 {
+		t.Errorf("items beyond the burst took %v, want at least %v", elapsed[3], 3*interval/2)
+	}
+	if err := p.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+func TestRateLimitCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan int)
+	go func() {
+		defer close(in)
+		for i := 0; i < 10; i++ {
+			in <- i
+		}
+	}()
+	p := FromContext(ctx, in).RateLimit(time.Hour, 1)
+	var got []int
+	for i := range p.Out() {
+		got = append(got, i)
+		cancel()
+	}
+	if !slices.Equal(got, []int{0}) {
+		t.Errorf("Out() = %v, want [0]", got)
+	}
+	if err := p.Err(); err != context.Canceled {
+		t.Errorf("Err() = %v, want %v", err, context.Canceled)
+	}
+}
@@ -15,15 +15,63 @@
 // Package pipe provides a simple way of applying transforms to a channel.
 package pipe
 
+import (
+	"context"
+	"sync"
+)
+
+// group is the cancellation and error state shared by the stages of a Pipe.
+type group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	err    error
+}
+
+// fail records err as the cause of the pipeline's termination if it is the first.
+func (g *group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
 // Pipe constructs a series of executions.
+//
+// When a stage fails or the pipeline's context is cancelled, the remaining
+// items are drained without being processed so that upstream senders are not
+// blocked. The source should stop producing once the pipeline's context is done.
 type Pipe[T any] struct {
 	Width int
 	steps []chan T
+	g     *group
 }
 
 // From creates a Pipe from the given input channel.
 func From[T any](in chan T) Pipe[T] {
-	return Pipe[T]{steps: []chan T{in}, Width: cap(in)}
+	return FromContext(context.Background(), in)
+}
+
+// FromContext creates a Pipe from the given input channel that stops processing when ctx is cancelled.
+func FromContext[T any](ctx context.Context, in chan T) Pipe[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	return Pipe[T]{steps: []chan T{in}, Width: cap(in), g: &group{ctx: ctx, cancel: cancel}}
+}
+
+// Context returns the context of the pipeline which is cancelled when a stage fails.
+func (p Pipe[T]) Context() context.Context {
+	return p.g.ctx
+}
+
+// Err returns the error that terminated the pipeline, if any.
+//
+// It should be called once Out has been drained. If the pipeline's context was
+// cancelled, the context's error is returned.
+func (p Pipe[T]) Err() error {
+	p.g.once.Do(func() { p.g.err = p.g.ctx.Err() })
+	// NOTE: The pipeline is complete so its context's resources can be released.
+	p.g.cancel()
+	return p.g.err
 }
 
 // DoFor adds a pipeline combinator.
@@ -37,10 +85,25 @@ func (p Pipe[T]) DoFor(fn func(in <-chan T, out chan<- T)) Pipe[T] {
 
 // Do adds a per-item combinator.
 func (p Pipe[T]) Do(fn func(in T, out chan<- T)) Pipe[T] {
+	return p.DoErr(func(_ context.Context, in T, out chan<- T) error {
+		fn(in, out)
+		return nil
+	})
+}
+
+// DoErr adds a per-item combinator that may fail.
+// A failure cancels the pipeline and is returned from Err.
+func (p Pipe[T]) DoErr(fn func(ctx context.Context, in T, out chan<- T) error) Pipe[T] {
+	g := p.g
 	return p.DoFor(func(in <-chan T, out chan<- T) {
 		defer close(out)
 		for t := range in {
-			fn(t, out)
+			if g.ctx.Err() != nil {
+				continue
+			}
+			if err := fn(g.ctx, t, out); err != nil {
+				g.fail(err)
+			}
 		}
 	})
 }
@@ -54,16 +117,30 @@ func (p Pipe[T]) Out() <-chan T {
 func IntoFor[T, S any](in Pipe[T], fn func(in <-chan T, out chan<- S)) Pipe[S] {
 	next := make(chan S, in.Width)
 	go fn(in.steps[len(in.steps)-1], next)
-	out := From(next)
-	return out
+	return Pipe[S]{steps: []chan S{next}, Width: in.Width, g: in.g}
 }
 
 // Into takes the input pipe and transforms it to another type.
 func Into[T, S any](in Pipe[T], fn func(in T, out chan<- S)) Pipe[S] {
+	return IntoErr(in, func(_ context.Context, in T, out chan<- S) error {
+		fn(in, out)
+		return nil
+	})
+}
+
+// IntoErr takes the input pipe and transforms it to another type using a function that may fail.
+// A failure cancels the pipeline and is returned from Err.
+func IntoErr[T, S any](in Pipe[T], fn func(ctx context.Context, in T, out chan<- S) error) Pipe[S] {
+	g := in.g
 	return IntoFor(in, func(in <-chan T, out chan<- S) {
 		defer close(out)
 		for t := range in {
-			fn(t, out)
+			if g.ctx.Err() != nil {
+				continue
+			}
+			if err := fn(g.ctx, t, out); err != nil {
+				g.fail(err)
+			}
 		}
 	})
 }
@@ -0,0 +1,146 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// source returns an unbuffered channel producing items and closed after the last.
+func source(items ...int) chan int {
+	c := make(chan int)
+	go func() {
+		defer close(c)
+		for _, i := range items {
+			c <- i
+		}
+	}()
+	return c
+}
+
+func collect[T any](c <-chan T) []T {
+	var ts []T
+	for t := range c {
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+func TestPipe(t *testing.T) {
+	p := From(source(1, 2, 3)).Do(func(in int, out chan<- int) {
+		out <- in * 10
+	})
+	s := Into(p, func(in int, out chan<- string) {
+		out <- fmt.Sprint(in)
+	})
+	if got, want := collect(s.Out()), []string{"10", "20", "30"}; !slices.Equal(got, want) {
+		t.Errorf("Out() = %v, want %v", got, want)
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+func TestPipeFirstErrorWins(t *testing.T) {
+	var calls []int
+	p := From(source(1, 2, 3, 4)).DoErr(func(ctx context.Context, in int, out chan<- int) error {
+		calls = append(calls, in)
+		if in == 1 {
+			out <- in
+			return nil
+		}
+		// Fail only once the downstream failure has cancelled the pipeline.
+		<-ctx.Done()
+		return errors.New("upstream")
+	})
+	s := IntoErr(p, func(ctx context.Context, in int, out chan<- int) error {
+		return errors.New("downstream")
+	})
+	if got := collect(s.Out()); len(got) != 0 {
+		t.Errorf("Out() = %v, want none", got)
+	}
+	if err := s.Err(); err == nil || err.Error() != "downstream" {
+		t.Errorf("Err() = %v, want downstream", err)
+	}
+	if slices.Contains(calls, 3) || slices.Contains(calls, 4) {
+		t.Errorf("items processed after failure: %v", calls)
+	}
+}
+
+func TestPipeFirstErrorWinsWithinStage(t *testing.T) {
+	var calls []int
+	p := From(source(1, 2, 3)).DoErr(func(ctx context.Context, in int, out chan<- int) error {
+		calls = append(calls, in)
+		return errors.Errorf("failed %d", in)
+	})
+	collect(p.Out())
+	if err := p.Err(); err == nil || err.Error() != "failed 1" {
+		t.Errorf("Err() = %v, want failed 1", err)
+	}
+	if !slices.Equal(calls, []int{1}) {
+		t.Errorf("processed %v, want [1]", calls)
+	}
+	// Err is stable once the pipeline has terminated.
+	if err := p.Err(); err == nil || err.Error() != "failed 1" {
+		t.Errorf("second Err() = %v, want failed 1", err)
+	}
+}
+
+func TestPipeDrainsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		defer close(in)
+		for i := 0; i < 100; i++ {
+			// NOTE: The source ignores the context so that draining alone
+			// must keep it from blocking.
+			in <- i
+		}
+	}()
+	var processed int
+	p := FromContext(ctx, in).Do(func(in int, out chan<- int) {
+		processed++
+		if in == 4 {
+			cancel()
+		}
+		out <- in
+	})
+	got := collect(p.Out())
+	<-sent
+	if len(got) != 5 || processed != 5 {
+		t.Errorf("processed %d and produced %v, want the 5 items before cancellation", processed, got)
+	}
+	if err := p.Err(); err != context.Canceled {
+		t.Errorf("Err() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPipeErrCancelsContext(t *testing.T) {
+	p := From(source(1)).Do(func(in int, out chan<- int) { out <- in })
+	collect(p.Out())
+	if err := p.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+	if p.Context().Err() == nil {
+		t.Error("Context() not cancelled after Err()")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress counts the items completed by the tracked stages of a Pipe.
+type Progress struct {
+	// Total, if non-zero, is the number of items expected to enter the pipeline.
+	Total int64
+
+	mu     sync.Mutex
+	start  time.Time
+	stages []*stageProgress
+}
+
+type stageProgress struct {
+	name  string
+	count atomic.Int64
+}
+
+func (pr *Progress) add(name string) *stageProgress {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if pr.start.IsZero() {
+		pr.start = time.Now()
+	}
+	s := &stageProgress{name: name}
+	pr.stages = append(pr.stages, s)
+	return s
+}
+
+// Track adds a stage recording the items that have completed the preceding stages under name.
+func (p Pipe[T]) Track(pr *Progress, name string) Pipe[T] {
+	s := pr.add(name)
+	return p.Do(func(in T, out chan<- T) {
+		s.count.Add(1)
+		out <- in
+	})
+}
+
+// String renders the count and throughput of each tracked stage on a single line.
+func (pr *Progress) String() string {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	elapsed := time.Since(pr.start).Seconds()
+	parts := make([]string, 0, len(pr.stages))
+	for _, s := range pr.stages {
+		n := s.count.Load()
+		part := fmt.Sprintf("%s %d", s.name, n)
+		if pr.Total > 0 {
+			part += fmt.Sprintf("/%d (%d%%)", pr.Total, 100*n/pr.Total)
+		}
+		if elapsed > 0 {
+			part += fmt.Sprintf(" %.1f/s", float64(n)/elapsed)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// Report calls fn with the rendered progress every interval until the returned stop function is called or ctx is done.
+// NOTE: fn is called from a separate goroutine and stop waits for any call in progress.
+func (pr *Progress) Report(ctx context.Context, interval time.Duration, fn func(string)) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn(pr.String())
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
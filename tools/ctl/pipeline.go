@@ -0,0 +1,119 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/ctl/firestore"
+	"github.com/pkg/errors"
+)
+
+// Pipeline executes rebuilds in one of the benchmark modes.
+//
+// Mode-specific behavior is confined to implementations of Pipeline so that
+// the commands which execute rebuilds are written once for all modes.
+type Pipeline interface {
+	// Mode identifies the pipeline.
+	Mode() firestore.BenchmarkMode
+	// ExecutorService is the service whose version identifies the rebuild executor.
+	ExecutorService() string
+	// NewWorker returns a worker executing the pipeline on benchmark packages.
+	NewWorker(conf WorkerConfig) PackageWorker
+	// NewRequest returns a request executing the pipeline on a single target.
+	NewRequest(ctx context.Context, apiURL *url.URL, t rebuild.Target, opts PipelineOpts) (*http.Request, error)
+}
+
+// PipelineOpts configures the execution of a single target.
+type PipelineOpts struct {
+	ID               string
+	Strategy         *schema.StrategyOneOf
+	StrategyFromRepo bool
+	BypassCache      bool
+	AllArtifacts     bool
+	// DisableInstallHooks installs dependencies without running their install hooks.
+	DisableInstallHooks bool
+}
+
+var pipelines = []Pipeline{&smoketestPipeline{}, &attestPipeline{}}
+
+// pipelineFor returns the Pipeline executing the provided mode.
+func pipelineFor(mode firestore.BenchmarkMode) (Pipeline, error) {
+	var names []string
+	for _, p := range pipelines {
+		if p.Mode() == mode {
+			return p, nil
+		}
+		names = append(names, "'"+string(p.Mode())+"'")
+	}
+	return nil, errors.Errorf("unknown mode: %s. Expected one of %s", mode, strings.Join(names, " or "))
+}
+
+type smoketestPipeline struct{}
+
+var _ Pipeline = &smoketestPipeline{}
+
+func (*smoketestPipeline) Mode() firestore.BenchmarkMode { return firestore.SmoketestMode }
+
+func (*smoketestPipeline) ExecutorService() string { return "build-local" }
+
+func (*smoketestPipeline) NewWorker(conf WorkerConfig) PackageWorker {
+	return &SmoketestWorker{WorkerConfig: conf, warmup: isCloudRun(conf.url)}
+}
+
+func (*smoketestPipeline) NewRequest(ctx context.Context, apiURL *url.URL, t rebuild.Target, opts PipelineOpts) (*http.Request, error) {
+	return makeHTTPRequest(ctx, apiURL.JoinPath("smoketest"), &schema.SmoketestRequest{
+		Ecosystem:           t.Ecosystem,
+		Package:             t.Package,
+		Versions:            []string{t.Version},
+		Strategy:            opts.Strategy,
+		ID:                  opts.ID,
+		DisableInstallHooks: opts.DisableInstallHooks,
+	}), nil
+}
+
+type attestPipeline struct{}
+
+var _ Pipeline = &attestPipeline{}
+
+func (*attestPipeline) Mode() firestore.BenchmarkMode { return firestore.AttestMode }
+
+// ExecutorService returns the empty service which identifies the API itself.
+func (*attestPipeline) ExecutorService() string { return "" }
+
+func (*attestPipeline) NewWorker(conf WorkerConfig) PackageWorker {
+	return &AttestWorker{WorkerConfig: conf}
+}
+
+func (*attestPipeline) NewRequest(ctx context.Context, apiURL *url.URL, t rebuild.Target, opts PipelineOpts) (*http.Request, error) {
+	if opts.Strategy != nil {
+		return nil, errors.New("strategy not supported in attest mode, use --strategy-from-repo")
+	}
+	return makeHTTPRequest(ctx, apiURL.JoinPath("rebuild"), &schema.RebuildPackageRequest{
+		Ecosystem:           t.Ecosystem,
+		Package:             t.Package,
+		Version:             t.Version,
+		StrategyFromRepo:    opts.StrategyFromRepo,
+		BypassCache:         opts.BypassCache,
+		AllArtifacts:        opts.AllArtifacts,
+		DisableInstallHooks: opts.DisableInstallHooks,
+		ID:                  opts.ID,
+	}), nil
+}
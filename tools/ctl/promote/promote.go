@@ -0,0 +1,162 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package promote submits build definitions developed and verified locally
+// to the build definition repository read by the production pipeline.
+package promote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/oss-rebuild/pkg/rebuild/lint"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Provenance records who promoted a build definition and the rebuild that verified it.
+type Provenance struct {
+	// Promoter is the name and email of the person promoting the definition.
+	Promoter object.Signature
+	// Run is the local run in which the definition was developed, if any.
+	Run string
+	// Executor is the version of the rebuilder on which the definition succeeded.
+	Executor string
+}
+
+// Validate returns the strategy described by the build definition or an
+// error if linting finds it would not produce a build for the target.
+func Validate(ctx context.Context, t rebuild.Target, def *schema.StrategyOneOf) (rebuild.Strategy, error) {
+	s, err := def.Strategy()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading build definition")
+	}
+	findings := lint.Lint(ctx, t, s, lint.Options{Env: rebuild.BuildEnv{HasRepo: true, TimewarpHost: "localhost:8081"}, Shell: "sh"})
+	if lint.HasErrors(findings) {
+		var msgs []string
+		for _, f := range findings {
+			msgs = append(msgs, f.String())
+		}
+		return nil, errors.Errorf("invalid build definition:\n%s", strings.Join(msgs, "\n"))
+	}
+	return s, nil
+}
+
+// Promote writes the build definition for the target into dir of the
+// repository's worktree and commits it with the provenance of its promotion.
+// The commit is not pushed.
+func Promote(ctx context.Context, repo *git.Repository, dir string, t rebuild.Target, def *schema.StrategyOneOf, p Provenance) (plumbing.Hash, error) {
+	if p.Promoter.Name == "" || p.Promoter.Email == "" {
+		return plumbing.ZeroHash, errors.New("promoter name and email required")
+	}
+	if p.Promoter.When.IsZero() {
+		p.Promoter.When = time.Now().UTC()
+	}
+	if _, err := Validate(ctx, t, def); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "getting worktree")
+	}
+	// NOTE: Any changes already staged would be included in the promotion commit.
+	status, err := w.Status()
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "getting worktree status")
+	}
+	for path, s := range status {
+		if s.Staging != git.Unmodified && s.Staging != git.Untracked {
+			return plumbing.ZeroHash, errors.Errorf("worktree has staged changes: %s", path)
+		}
+	}
+	defs, err := w.Filesystem.Chroot(dir)
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "opening build definition directory")
+	}
+	wr, uri, err := rebuild.NewFilesystemAssetStore(defs).Writer(ctx, rebuild.Asset{Type: rebuild.BuildDef, Target: t})
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "creating build definition")
+	}
+	content, err := encode(def, p)
+	if err != nil {
+		wr.Close()
+		return plumbing.ZeroHash, err
+	}
+	if _, err := wr.Write(content); err != nil {
+		wr.Close()
+		return plumbing.ZeroHash, errors.Wrap(err, "writing build definition")
+	}
+	if err := wr.Close(); err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "writing build definition")
+	}
+	path, err := filepath.Rel(w.Filesystem.Root(), uri)
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "locating build definition")
+	}
+	if _, err := w.Add(path); err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "staging build definition")
+	}
+	hash, err := w.Commit(commitMessage(t, p), &git.CommitOptions{Author: &p.Promoter})
+	if errors.Is(err, git.ErrEmptyCommit) {
+		return plumbing.ZeroHash, errors.Errorf("build definition already promoted: %s", path)
+	} else if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "committing build definition")
+	}
+	return hash, nil
+}
+
+// encode returns the build definition file content annotated with its provenance.
+func encode(def *schema.StrategyOneOf, p Provenance) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Promoted by %s <%s> at %s.\n", p.Promoter.Name, p.Promoter.Email, p.Promoter.When.UTC().Format(time.RFC3339))
+	if p.Run != "" {
+		fmt.Fprintf(&b, "# Developed in local run %s.\n", p.Run)
+	}
+	if p.Executor != "" {
+		fmt.Fprintf(&b, "# Verified by rebuilder %s.\n", p.Executor)
+	}
+	e := yaml.NewEncoder(&b)
+	if err := e.Encode(def); err != nil {
+		return nil, errors.Wrap(err, "encoding build definition")
+	}
+	if err := e.Close(); err != nil {
+		return nil, errors.Wrap(err, "encoding build definition")
+	}
+	return b.Bytes(), nil
+}
+
+func commitMessage(t rebuild.Target, p Provenance) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Promote build definition for %s %s %s", t.Ecosystem, t.Package, t.Version)
+	if t.Artifact != "" {
+		fmt.Fprintf(&b, " (%s)", t.Artifact)
+	}
+	fmt.Fprintf(&b, "\n\nPromoted-By: %s <%s>\n", p.Promoter.Name, p.Promoter.Email)
+	if p.Run != "" {
+		fmt.Fprintf(&b, "Run: %s\n", p.Run)
+	}
+	if p.Executor != "" {
+		fmt.Fprintf(&b, "Verified-By: %s\n", p.Executor)
+	}
+	return b.String()
+}
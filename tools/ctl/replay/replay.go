@@ -0,0 +1,305 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay re-executes the rebuild described by an attestation bundle.
+package replay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/oss-rebuild/internal/verifier"
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/google/oss-rebuild/pkg/rebuild/schema"
+	"github.com/google/oss-rebuild/tools/docker"
+	"github.com/pkg/errors"
+)
+
+// Options configures a replay.
+type Options struct {
+	// Runtime is the container runtime used to execute the rebuild. Defaults to docker.
+	Runtime docker.Runtime
+	// Output receives the logs of the rebuild container.
+	Output io.Writer
+	// OutputDir, if provided, is the directory to which the rebuilt artifact is written.
+	// Defaults to a temporary directory that is removed once the replay completes.
+	OutputDir string
+}
+
+// Plan is the rebuild reconstructed from an attestation bundle.
+type Plan struct {
+	Target     rebuild.Target
+	Strategy   rebuild.Strategy
+	Dockerfile string
+	// Images maps the base images used by the rebuild to their recorded digests.
+	Images map[string]string
+	// Stabilizers are those recorded in the bundle. Nil if the bundle predates their recording.
+	Stabilizers []string
+	// WantDigest and WantCanonicalDigest are the sha256 digests recorded in the bundle.
+	WantDigest          string
+	WantCanonicalDigest string
+}
+
+// checkStabilizers returns an error unless the recorded stabilizers are
+// exactly those applied by archive.Canonicalize, without which the canonical
+// digest of the replay is not comparable to that attested.
+func (p *Plan) checkStabilizers() error {
+	if p.Stabilizers == nil {
+		return errors.New("bundle does not record its stabilizers")
+	}
+	var unsupported, unrecorded []string
+	for _, s := range p.Stabilizers {
+		if !slices.Contains(archive.Stabilizers, s) {
+			unsupported = append(unsupported, s)
+		}
+	}
+	for _, s := range archive.Stabilizers {
+		if !slices.Contains(p.Stabilizers, s) {
+			unrecorded = append(unrecorded, s)
+		}
+	}
+	if len(unsupported) > 0 || len(unrecorded) > 0 {
+		return errors.Errorf("bundle stabilizers cannot be applied: %d unsupported %v, %d not recorded %v", len(unsupported), unsupported, len(unrecorded), unrecorded)
+	}
+	return nil
+}
+
+// Result describes the outcome of a replay.
+type Result struct {
+	*Plan
+	// Digest and CanonicalDigest are the sha256 digests of the replayed artifact.
+	Digest          string
+	CanonicalDigest string
+}
+
+// ExactMatch returns whether the replayed artifact is identical to the attested rebuild.
+func (r *Result) ExactMatch() bool {
+	return r.Digest == r.WantDigest
+}
+
+// Matches returns whether the replayed artifact is still equivalent to upstream.
+func (r *Result) Matches() bool {
+	return r.CanonicalDigest == r.WantCanonicalDigest
+}
+
+// NewPlan reconstructs the rebuild described by the bundle.
+func NewPlan(b *verifier.Bundle) (*Plan, error) {
+	var p Plan
+	var err error
+	p.Target, err = b.Target()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading target")
+	}
+	{
+		raw, err := b.Byproduct("build.json")
+		if err != nil {
+			return nil, errors.Wrap(err, "reading strategy")
+		}
+		var oneof schema.StrategyOneOf
+		if err := json.Unmarshal(raw, &oneof); err != nil {
+			return nil, errors.Wrap(err, "parsing strategy")
+		}
+		p.Strategy, err = oneof.Strategy()
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing strategy")
+		}
+	}
+	{
+		raw, err := b.Byproduct("Dockerfile")
+		if err != nil {
+			return nil, errors.Wrap(err, "reading Dockerfile")
+		}
+		p.Dockerfile = string(raw)
+	}
+	// NOTE: The environment is absent for rebuilds that predate its collection.
+	if raw, err := b.Byproduct("environment.json"); err == nil {
+		var env rebuild.Environment
+		if err := json.Unmarshal(raw, &env); err != nil {
+			return nil, errors.Wrap(err, "parsing environment")
+		}
+		p.Images = env.Images
+		p.Dockerfile = pinImages(p.Dockerfile, env.Images)
+	}
+	p.Stabilizers, err = b.Stabilizers()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading stabilizers")
+	}
+	rb, err := b.RebuildAttestation()
+	if err != nil {
+		return nil, err
+	}
+	if len(rb.Subject) != 1 {
+		return nil, errors.Errorf("expected one rebuild subject, got %d", len(rb.Subject))
+	}
+	p.WantDigest = rb.Subject[0].Digest["sha256"]
+	eq, err := b.EquivalenceAttestation()
+	if err != nil {
+		return nil, err
+	}
+	normalized := path.Join("normalized", p.Target.Artifact)
+	for _, bp := range eq.Predicate.RunDetails.Byproducts {
+		if bp.Name == normalized {
+			p.WantCanonicalDigest = bp.Digest["sha256"]
+		}
+	}
+	if p.WantDigest == "" || p.WantCanonicalDigest == "" {
+		return nil, errors.New("bundle missing sha256 digests")
+	}
+	return &p, nil
+}
+
+// pinImages qualifies unpinned base images in the Dockerfile with their recorded digests.
+func pinImages(dockerfile string, images map[string]string) string {
+	lines := strings.Split(dockerfile, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		if digest, ok := images[fields[1]]; ok {
+			fields[1] += "@" + digest
+			lines[i] = strings.Join(fields, " ")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Replay re-executes the rebuild described by the bundle and compares the result to that which was attested.
+// It fails if the stabilizers recorded in the bundle cannot be applied to the result.
+func Replay(ctx context.Context, b *verifier.Bundle, opts Options) (*Result, error) {
+	p, err := NewPlan(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkStabilizers(); err != nil {
+		return nil, err
+	}
+	rt := opts.Runtime
+	if rt == nil {
+		rt = docker.Docker
+	}
+	output := opts.Output
+	if output == nil {
+		output = io.Discard
+	}
+	dir, err := os.MkdirTemp("", "oss-rebuild-replay")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating temp dir")
+	}
+	defer os.RemoveAll(dir)
+	dockerfile := filepath.Join(dir, "Dockerfile")
+	if err := os.WriteFile(dockerfile, []byte(p.Dockerfile), 0644); err != nil {
+		return nil, errors.Wrap(err, "writing Dockerfile")
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.Wrap(err, "generating id")
+	}
+	name := "oss-rebuild-replay-" + hex.EncodeToString(id)
+	if err := rt.BuildImage(ctx, name, dockerfile, dir); err != nil {
+		return nil, errors.Wrap(err, "building image")
+	}
+	defer rt.Kill(context.WithoutCancel(ctx), name)
+	if err := rt.Run(ctx, name, &docker.RunOptions{Name: name, Output: output}); err != nil {
+		return nil, errors.Wrap(err, "running rebuild")
+	}
+	outDir := opts.OutputDir
+	if outDir == "" {
+		outDir = dir
+	}
+	dst := filepath.Join(outDir, p.Target.Artifact)
+	if err := rt.Copy(ctx, name, path.Join("/out", p.Target.Artifact), dst); err != nil {
+		return nil, errors.Wrap(err, "copying artifact")
+	}
+	res := &Result{Plan: p}
+	res.Digest, res.CanonicalDigest, err = digests(dst, p.Target.ArchiveType())
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// digests returns the hex-encoded sha256 digests of the file and its canonicalized form.
+func digests(file string, f archive.Format) (digest, canonical string, err error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return "", "", errors.Wrap(err, "opening artifact")
+	}
+	defer r.Close()
+	h, ch := sha256.New(), sha256.New()
+	tr := io.TeeReader(r, h)
+	if err := archive.Canonicalize(ch, tr, f); err != nil {
+		return "", "", errors.Wrap(err, "canonicalizing artifact")
+	}
+	// NOTE: Canonicalization need not consume trailing data so drain the remainder into the hash.
+	if _, err := io.Copy(io.Discard, tr); err != nil {
+		return "", "", errors.Wrap(err, "reading artifact")
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), fmt.Sprintf("%x", ch.Sum(nil)), nil
+}
+
+// VersionDiff is the outcome of replaying the rebuilds of two versions of a package.
+type VersionDiff struct {
+	*rebuild.VersionDiff
+	Old, New *Result
+}
+
+// DiffVersions replays the rebuilds described by the bundles of two versions
+// of a package and compares their stabilized outputs.
+func DiffVersions(ctx context.Context, old, new *verifier.Bundle, opts Options) (*VersionDiff, error) {
+	dir, err := os.MkdirTemp("", "oss-rebuild-diff")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating temp dir")
+	}
+	defer os.RemoveAll(dir)
+	var results [2]*Result
+	var summaries [2]*archive.ContentSummary
+	for i, b := range []*verifier.Bundle{old, new} {
+		o := opts
+		o.OutputDir = filepath.Join(dir, fmt.Sprint(i))
+		if err := os.Mkdir(o.OutputDir, 0755); err != nil {
+			return nil, errors.Wrap(err, "creating output dir")
+		}
+		res, err := Replay(ctx, b, o)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(filepath.Join(o.OutputDir, res.Target.Artifact))
+		if err != nil {
+			return nil, errors.Wrap(err, "opening artifact")
+		}
+		summaries[i], err = archive.NewContentSummary(f, res.Target.ArchiveType())
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "summarizing %s", res.Target.Artifact)
+		}
+		results[i] = res
+	}
+	d, err := rebuild.DiffVersions(results[0].Target, results[1].Target, summaries[0], summaries[1])
+	if err != nil {
+		return nil, err
+	}
+	return &VersionDiff{VersionDiff: d, Old: results[0], New: results[1]}, nil
+}
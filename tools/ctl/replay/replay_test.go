@@ -0,0 +1,45 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+)
+
+func TestCheckStabilizers(t *testing.T) {
+	reversed := slices.Clone(archive.Stabilizers)
+	slices.Reverse(reversed)
+	for _, tc := range []struct {
+		name        string
+		stabilizers []string
+		wantErr     bool
+	}{
+		{name: "current", stabilizers: archive.Stabilizers},
+		{name: "reordered", stabilizers: reversed},
+		{name: "unrecorded", wantErr: true},
+		{name: "subset", stabilizers: archive.Stabilizers[1:], wantErr: true},
+		{name: "unsupported", stabilizers: append(slices.Clone(archive.Stabilizers), "zip-future-stabilizer"), wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Plan{Stabilizers: tc.stabilizers}
+			if err := p.checkStabilizers(); (err != nil) != tc.wantErr {
+				t.Errorf("checkStabilizers() = %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
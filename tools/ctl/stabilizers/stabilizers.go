@@ -0,0 +1,276 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stabilizers proposes candidate stabilizers from the differences
+// that recur across many mismatched rebuilds.
+//
+// Each mismatch is reduced to a Summary of its differing entries, each
+// classified by the kind of content that differs. Entries are generalized
+// across targets by replacing the package name and version in their paths so
+// that the same difference in many packages yields a single Suggestion.
+package stabilizers
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/oss-rebuild/pkg/archive"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+	"github.com/pkg/errors"
+)
+
+// ChangeKind is how an entry differs between the rebuild and upstream.
+type ChangeKind string
+
+const (
+	// RebuildOnly entries are present only in the rebuild.
+	RebuildOnly ChangeKind = "rebuild-only"
+	// UpstreamOnly entries are present only upstream.
+	UpstreamOnly ChangeKind = "upstream-only"
+	// Changed entries are present in both with different content.
+	Changed ChangeKind = "changed"
+)
+
+// RegionClass describes the content which differs within a changed entry.
+type RegionClass string
+
+const (
+	// WholeEntry applies to entries present in only one artifact.
+	WholeEntry RegionClass = "entry"
+	// LineEndings differences disappear when CRLF is replaced with LF.
+	LineEndings RegionClass = "line-endings"
+	// Whitespace differences disappear when whitespace is removed.
+	Whitespace RegionClass = "whitespace"
+	// Ordering differences disappear when lines are sorted.
+	Ordering RegionClass = "ordering"
+	// Numeric differences are confined to runs of digits, such as timestamps and build numbers.
+	Numeric RegionClass = "numeric"
+	// Appended differences are content added to the end of one of the entries.
+	Appended RegionClass = "appended"
+	// Binary differences are within non-text content.
+	Binary RegionClass = "binary"
+	// Content differences fit none of the other classes.
+	Content RegionClass = "content"
+)
+
+// EntryDiff is an archive entry which differs between the rebuild and upstream.
+type EntryDiff struct {
+	Path  string      `json:"path"`
+	Kind  ChangeKind  `json:"kind"`
+	Class RegionClass `json:"class"`
+}
+
+// Summary is the structured difference between a rebuild and upstream.
+type Summary struct {
+	Target  rebuild.Target `json:"target"`
+	Entries []EntryDiff    `json:"entries"`
+}
+
+// Summarize compares the canonicalized rebuild and upstream artifacts of t.
+func Summarize(t rebuild.Target, rb, up []byte) (*Summary, error) {
+	csRB, err := archive.NewContentSummary(bytes.NewReader(rb), t.ArchiveType())
+	if err != nil {
+		return nil, errors.Wrap(err, "summarizing rebuild")
+	}
+	csUP, err := archive.NewContentSummary(bytes.NewReader(up), t.ArchiveType())
+	if err != nil {
+		return nil, errors.Wrap(err, "summarizing upstream")
+	}
+	rbOnly, diffs, upOnly := csRB.Diff(csUP)
+	s := Summary{Target: t}
+	for _, p := range rbOnly {
+		s.Entries = append(s.Entries, EntryDiff{Path: p, Kind: RebuildOnly, Class: WholeEntry})
+	}
+	for _, p := range upOnly {
+		s.Entries = append(s.Entries, EntryDiff{Path: p, Kind: UpstreamOnly, Class: WholeEntry})
+	}
+	if len(diffs) > 0 {
+		rbEntries, err := archive.ReadEntries(bytes.NewReader(rb), t.ArchiveType(), diffs)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading rebuild entries")
+		}
+		upEntries, err := archive.ReadEntries(bytes.NewReader(up), t.ArchiveType(), diffs)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading upstream entries")
+		}
+		for _, p := range diffs {
+			s.Entries = append(s.Entries, EntryDiff{Path: p, Kind: Changed, Class: Classify(rbEntries[p], upEntries[p])})
+		}
+	}
+	slices.SortFunc(s.Entries, func(a, b EntryDiff) int { return strings.Compare(a.Path, b.Path) })
+	return &s, nil
+}
+
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// Classify returns the class of the differences between the two versions of an entry.
+func Classify(rb, up []byte) RegionClass {
+	if !utf8.Valid(rb) || !utf8.Valid(up) {
+		return Binary
+	}
+	a, b := string(rb), string(up)
+	switch {
+	case strings.ReplaceAll(a, "\r\n", "\n") == strings.ReplaceAll(b, "\r\n", "\n"):
+		return LineEndings
+	case strings.Join(strings.Fields(a), "") == strings.Join(strings.Fields(b), ""):
+		return Whitespace
+	case sortedLines(a) == sortedLines(b):
+		return Ordering
+	case digits.ReplaceAllString(a, "0") == digits.ReplaceAllString(b, "0"):
+		return Numeric
+	case strings.HasPrefix(a, b) || strings.HasPrefix(b, a):
+		return Appended
+	default:
+		return Content
+	}
+}
+
+func sortedLines(s string) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	slices.Sort(lines)
+	return strings.Join(lines, "\n")
+}
+
+// Pattern generalizes an entry's path across targets by replacing the
+// target's version and package name with placeholders.
+func Pattern(t rebuild.Target, path string) string {
+	if t.Version != "" {
+		path = strings.ReplaceAll(path, t.Version, "{version}")
+	}
+	name := t.Package
+	if _, artifactID, found := strings.Cut(name, ":"); found {
+		// NOTE: Maven archives are named for the artifact ID alone.
+		name = artifactID
+	}
+	name = strings.TrimPrefix(name[strings.LastIndex(name, "/")+1:], "@")
+	if name == "" {
+		return path
+	}
+	// NOTE: Archives often normalize the package name's case and separators.
+	var variants []string
+	for _, v := range []string{name, strings.ToLower(name)} {
+		variants = append(variants, v, strings.ReplaceAll(v, "-", "_"), strings.ReplaceAll(v, "_", "-"))
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		// NOTE: Only whole segments or their prefixes are replaced so that short names do not match arbitrary text.
+		for _, v := range variants {
+			if rest, ok := strings.CutPrefix(seg, v); ok && (rest == "" || strings.ContainsAny(rest[:1], "-_.")) {
+				segments[i] = "{package}" + rest
+				break
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Suggestion is a candidate stabilizer for a difference recurring across targets.
+type Suggestion struct {
+	Ecosystem rebuild.Ecosystem `json:"ecosystem"`
+	Pattern   string            `json:"pattern"`
+	Kind      ChangeKind        `json:"kind"`
+	Class     RegionClass       `json:"class"`
+	// Targets is the number of targets exhibiting the difference.
+	Targets int `json:"targets"`
+	// Examples are a sample of the targets exhibiting the difference.
+	Examples []rebuild.Target `json:"examples"`
+	// Rule describes the stabilizer which would remove the difference.
+	Rule string `json:"rule"`
+}
+
+// maxExamples is the number of example targets recorded for each Suggestion.
+const maxExamples = 5
+
+func (s Suggestion) rule() string {
+	switch {
+	case s.Kind == RebuildOnly:
+		return fmt.Sprintf("drop entries matching %s, present only in the rebuild", s.Pattern)
+	case s.Kind == UpstreamOnly:
+		return fmt.Sprintf("drop entries matching %s, present only upstream", s.Pattern)
+	case s.Class == LineEndings:
+		return fmt.Sprintf("normalize the line endings of %s", s.Pattern)
+	case s.Class == Whitespace:
+		return fmt.Sprintf("normalize the whitespace of %s", s.Pattern)
+	case s.Class == Ordering:
+		return fmt.Sprintf("sort the lines of %s", s.Pattern)
+	case s.Class == Numeric:
+		return fmt.Sprintf("mask the numeric fields, such as timestamps and build numbers, of %s", s.Pattern)
+	case s.Class == Appended:
+		return fmt.Sprintf("strip content appended to %s", s.Pattern)
+	default:
+		return fmt.Sprintf("investigate the %s differences in %s", s.Class, s.Pattern)
+	}
+}
+
+// Suggest groups the differences of the summaries by ecosystem, path pattern,
+// and class, returning a Suggestion for each group recurring across at least
+// minTargets targets, most widespread first.
+func Suggest(summaries []*Summary, minTargets int) []Suggestion {
+	type key struct {
+		eco     rebuild.Ecosystem
+		pattern string
+		kind    ChangeKind
+		class   RegionClass
+	}
+	groups := make(map[key]*Suggestion)
+	for _, s := range summaries {
+		// NOTE: A pattern may match several entries of one target but the target is counted once.
+		seen := make(map[key]bool)
+		for _, e := range s.Entries {
+			k := key{s.Target.Ecosystem, Pattern(s.Target, e.Path), e.Kind, e.Class}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			g, ok := groups[k]
+			if !ok {
+				g = &Suggestion{Ecosystem: k.eco, Pattern: k.pattern, Kind: k.kind, Class: k.class}
+				groups[k] = g
+			}
+			g.Targets++
+			if len(g.Examples) < maxExamples {
+				g.Examples = append(g.Examples, s.Target)
+			}
+		}
+	}
+	var out []Suggestion
+	for _, g := range groups {
+		if g.Targets < minTargets {
+			continue
+		}
+		g.Rule = g.rule()
+		out = append(out, *g)
+	}
+	slices.SortFunc(out, func(a, b Suggestion) int {
+		if c := cmp.Compare(b.Targets, a.Targets); c != 0 {
+			return c
+		}
+		if c := strings.Compare(string(a.Ecosystem), string(b.Ecosystem)); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Pattern, b.Pattern); c != 0 {
+			return c
+		}
+		if c := strings.Compare(string(a.Kind), string(b.Kind)); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.Class), string(b.Class))
+	})
+	return out
+}
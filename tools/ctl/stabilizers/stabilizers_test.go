@@ -0,0 +1,104 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stabilizers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		rb, up string
+		want   RegionClass
+	}{
+		{"line endings", "a\nb\n", "a\r\nb\r\n", LineEndings},
+		{"whitespace", "{\"a\": 1}", "{\n  \"a\":1\n}", Whitespace},
+		{"ordering", "a\nb\nc\n", "c\na\nb\n", Ordering},
+		{"numeric", "built 2024-01-01 12:00", "built 2023-12-31 09:30", Numeric},
+		{"appended", "a\nb\n", "a\nb\nsignature\n", Appended},
+		{"binary", "\xff\x00", "\xfe\x00", Binary},
+		{"content", "a = 1", "b = 2x", Content},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Classify([]byte(tc.rb), []byte(tc.up)); got != tc.want {
+				t.Errorf("Classify() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPattern(t *testing.T) {
+	for _, tc := range []struct {
+		target rebuild.Target
+		path   string
+		want   string
+	}{
+		{rebuild.Target{Ecosystem: rebuild.PyPI, Package: "My-Pkg", Version: "1.2.0"}, "my_pkg-1.2.0/my_pkg.egg-info/PKG-INFO", "{package}-{version}/{package}.egg-info/PKG-INFO"},
+		{rebuild.Target{Ecosystem: rebuild.NPM, Package: "@scope/a", Version: "2.0.0"}, "package/lib/a.js", "package/lib/{package}.js"},
+		{rebuild.Target{Ecosystem: rebuild.NPM, Package: "a", Version: "2.0.0"}, "package/data/abc.json", "package/data/abc.json"},
+		{rebuild.Target{Ecosystem: rebuild.Maven, Package: "org.example:lib", Version: "3.1"}, "META-INF/maven/org.example/lib/pom.properties", "META-INF/maven/org.example/{package}/pom.properties"},
+	} {
+		if got := Pattern(tc.target, tc.path); got != tc.want {
+			t.Errorf("Pattern(%s) = %s, want %s", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	target := func(pkg string) rebuild.Target {
+		return rebuild.Target{Ecosystem: rebuild.PyPI, Package: pkg, Version: "1.0"}
+	}
+	summaries := []*Summary{
+		{Target: target("a"), Entries: []EntryDiff{
+			{Path: "a-1.0/PKG-INFO", Kind: Changed, Class: Numeric},
+			{Path: "a-1.0/a/_version.py", Kind: Changed, Class: Content},
+		}},
+		{Target: target("b"), Entries: []EntryDiff{
+			{Path: "b-1.0/PKG-INFO", Kind: Changed, Class: Numeric},
+			{Path: "b-1.0/setup.cfg", Kind: UpstreamOnly, Class: WholeEntry},
+		}},
+		{Target: target("c"), Entries: []EntryDiff{
+			{Path: "c-1.0/setup.cfg", Kind: UpstreamOnly, Class: WholeEntry},
+			{Path: "c-1.0/PKG-INFO", Kind: Changed, Class: Numeric},
+		}},
+	}
+	want := []Suggestion{
+		{
+			Ecosystem: rebuild.PyPI,
+			Pattern:   "{package}-{version}/PKG-INFO",
+			Kind:      Changed,
+			Class:     Numeric,
+			Targets:   3,
+			Examples:  []rebuild.Target{target("a"), target("b"), target("c")},
+			Rule:      "mask the numeric fields, such as timestamps and build numbers, of {package}-{version}/PKG-INFO",
+		},
+		{
+			Ecosystem: rebuild.PyPI,
+			Pattern:   "{package}-{version}/setup.cfg",
+			Kind:      UpstreamOnly,
+			Class:     WholeEntry,
+			Targets:   2,
+			Examples:  []rebuild.Target{target("b"), target("c")},
+			Rule:      "drop entries matching {package}-{version}/setup.cfg, present only upstream",
+		},
+	}
+	if diff := cmp.Diff(want, Suggest(summaries, 2)); diff != "" {
+		t.Errorf("Suggest() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -17,44 +17,47 @@ package docker
 
 import (
 	"context"
-	"fmt"
 	"io"
-	"log"
-	"os/exec"
-	"strings"
-	"syscall"
 )
 
-// RunOptions defines optional arguments for RunServer.
+// RunOptions defines optional arguments for RunServer and Run.
 type RunOptions struct {
 	ID     chan<- string
 	Output io.Writer
+	// Name is the name to assign to the container.
+	Name string
+	// Network is the docker network to which the container should be attached.
+	Network string
+	// Env are environment variables in KEY=VALUE form to set in the container.
+	Env []string
+	// Args, if provided, replace the default arguments passed to the container.
+	Args []string
+	// Volumes are mounts in SOURCE:TARGET form to attach to the container.
+	Volumes []string
+	// SecurityOpts are the security options, such as "no-new-privileges", applied to the container.
+	SecurityOpts []string
+	// HostPort, if provided, is the host port on which a server's port is
+	// published in place of the same port number.
+	HostPort int
 }
 
 // RunServer runs a docker container hosting a simple server.
+// If port is zero, no port will be published to the host.
 func RunServer(ctx context.Context, img string, port int, opts *RunOptions) error {
-	cmd := exec.CommandContext(ctx, "docker", "run", "--detach", "-p", fmt.Sprintf("%d:%d", port, port), "--rm", img, "--user-agent=OSSRebuildLocal/0.0.0")
-	log.Print(cmd.String())
-	out, err := cmd.Output()
-	if err != nil {
-		if opts.ID != nil {
-			close(opts.ID)
-		}
-		return err
-	}
-	containerID := strings.TrimSpace(string(out))
-	if opts.ID != nil {
-		opts.ID <- containerID
-		close(opts.ID)
-	}
+	return Docker.RunServer(ctx, img, port, opts)
+}
+
+// CreateNetwork creates a docker bridge network if one does not already exist.
+func CreateNetwork(ctx context.Context, name string) error {
+	return Docker.CreateNetwork(ctx, name)
+}
+
+// RemoveNetwork removes a docker network.
+func RemoveNetwork(ctx context.Context, name string) error {
+	return Docker.RemoveNetwork(ctx, name)
+}
 
-	// Attach to the container to receive logs.
-	cmd = exec.CommandContext(ctx, "docker", "attach", containerID)
-	cmd.Stdout = opts.Output
-	cmd.Stderr = opts.Output
-	// NOTE: The default sends SIGKILL which is not proxied to the container.
-	cmd.Cancel = func() error {
-		return cmd.Process.Signal(syscall.SIGINT)
-	}
-	return cmd.Run()
+// Kill forcibly stops and removes the named container.
+func Kill(ctx context.Context, name string) error {
+	return Docker.Kill(ctx, name)
 }
@@ -0,0 +1,225 @@
+// Copyright 2024 The OSS Rebuild Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Runtime is a container engine used to build and run the project's containers.
+type Runtime interface {
+	// Command returns the name of the CLI tool used to interact with the runtime.
+	Command() string
+	// BuildImage builds the image tag from dockerfile using contextDir as the build context.
+	BuildImage(ctx context.Context, tag, dockerfile, contextDir string, buildArgs ...string) error
+	// RunServer runs a container hosting a simple server, blocking until it exits.
+	// If port is zero, no port will be published to the host.
+	RunServer(ctx context.Context, img string, port int, opts *RunOptions) error
+	// Run runs a container to completion without removing it on exit.
+	Run(ctx context.Context, img string, opts *RunOptions) error
+	// Copy copies src from the named container to dst on the host.
+	Copy(ctx context.Context, container, src, dst string) error
+	// CreateNetwork creates a bridge network if one does not already exist.
+	CreateNetwork(ctx context.Context, name string) error
+	// RemoveNetwork removes a network.
+	RemoveNetwork(ctx context.Context, name string) error
+	// Kill forcibly stops and removes the named container.
+	Kill(ctx context.Context, name string) error
+	// ShellCommand returns the command line that opens an interactive shell in the container.
+	ShellCommand(containerID string) string
+}
+
+// CLIRuntime is a Runtime driven by a docker-compatible command-line tool.
+type CLIRuntime struct {
+	// Tool is the name or path of the command-line tool.
+	Tool string
+	// FollowLogs streams output using "logs --follow" for tools that do not support "attach".
+	FollowLogs bool
+}
+
+var _ Runtime = &CLIRuntime{}
+
+var (
+	// Docker is the Runtime for the Docker daemon.
+	Docker = &CLIRuntime{Tool: "docker"}
+	// Podman is the Runtime for Podman, including rootless installations.
+	Podman = &CLIRuntime{Tool: "podman"}
+	// Nerdctl is the Runtime for containerd, accessed using nerdctl.
+	Nerdctl = &CLIRuntime{Tool: "nerdctl", FollowLogs: true}
+)
+
+// RuntimeFor returns the Runtime with the provided name.
+func RuntimeFor(name string) (Runtime, error) {
+	switch name {
+	case "", "docker":
+		return Docker, nil
+	case "podman":
+		return Podman, nil
+	case "nerdctl", "containerd":
+		return Nerdctl, nil
+	default:
+		return nil, errors.Errorf("unknown container runtime: %s", name)
+	}
+}
+
+// Command returns the name of the CLI tool used to interact with the runtime.
+func (r *CLIRuntime) Command() string {
+	return r.Tool
+}
+
+func (r *CLIRuntime) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, r.Tool, args...)
+	log.Print(cmd.String())
+	return cmd
+}
+
+// BuildImage builds the image tag from dockerfile using contextDir as the build context.
+func (r *CLIRuntime) BuildImage(ctx context.Context, tag, dockerfile, contextDir string, buildArgs ...string) error {
+	args := []string{"build"}
+	for _, a := range buildArgs {
+		args = append(args, "--build-arg", a)
+	}
+	args = append(args, "--tag", tag, "--file", dockerfile, contextDir)
+	cmd := r.command(ctx, args...)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = log.Writer()
+	return cmd.Run()
+}
+
+// RunServer runs a container hosting a simple server, blocking until it exits.
+func (r *CLIRuntime) RunServer(ctx context.Context, img string, port int, opts *RunOptions) error {
+	args := []string{"run", "--detach", "--rm"}
+	if port != 0 {
+		hostPort := port
+		if opts.HostPort != 0 {
+			hostPort = opts.HostPort
+		}
+		args = append(args, "-p", fmt.Sprintf("%d:%d", hostPort, port))
+	}
+	if opts.Name != "" {
+		args = append(args, "--name", opts.Name)
+	}
+	if opts.Network != "" {
+		args = append(args, "--network", opts.Network)
+	}
+	for _, e := range opts.Env {
+		args = append(args, "--env", e)
+	}
+	for _, v := range opts.Volumes {
+		args = append(args, "--volume", v)
+	}
+	for _, o := range opts.SecurityOpts {
+		args = append(args, "--security-opt", o)
+	}
+	args = append(args, img)
+	if opts.Args != nil {
+		args = append(args, opts.Args...)
+	} else {
+		args = append(args, "--user-agent=OSSRebuildLocal/0.0.0")
+	}
+	out, err := r.command(ctx, args...).Output()
+	if err != nil {
+		if opts.ID != nil {
+			close(opts.ID)
+		}
+		return err
+	}
+	containerID := strings.TrimSpace(string(out))
+	if opts.ID != nil {
+		opts.ID <- containerID
+		close(opts.ID)
+	}
+
+	var cmd *exec.Cmd
+	if r.FollowLogs {
+		cmd = exec.CommandContext(ctx, r.Tool, "logs", "--follow", containerID)
+		// NOTE: Log streaming does not proxy signals so the container must be stopped explicitly.
+		cmd.Cancel = func() error {
+			exec.Command(r.Tool, "stop", containerID).Run()
+			return cmd.Process.Kill()
+		}
+	} else {
+		// Attach to the container to receive logs.
+		cmd = exec.CommandContext(ctx, r.Tool, "attach", containerID)
+		// NOTE: The default sends SIGKILL which is not proxied to the container.
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(syscall.SIGINT)
+		}
+	}
+	cmd.Stdout = opts.Output
+	cmd.Stderr = opts.Output
+	return cmd.Run()
+}
+
+// Run runs a container to completion without removing it on exit.
+func (r *CLIRuntime) Run(ctx context.Context, img string, opts *RunOptions) error {
+	args := []string{"run"}
+	if opts.Name != "" {
+		args = append(args, "--name", opts.Name)
+	}
+	if opts.Network != "" {
+		args = append(args, "--network", opts.Network)
+	}
+	for _, e := range opts.Env {
+		args = append(args, "--env", e)
+	}
+	for _, v := range opts.Volumes {
+		args = append(args, "--volume", v)
+	}
+	for _, o := range opts.SecurityOpts {
+		args = append(args, "--security-opt", o)
+	}
+	args = append(args, img)
+	args = append(args, opts.Args...)
+	cmd := r.command(ctx, args...)
+	cmd.Stdout = opts.Output
+	cmd.Stderr = opts.Output
+	return cmd.Run()
+}
+
+// Copy copies src from the named container to dst on the host.
+func (r *CLIRuntime) Copy(ctx context.Context, container, src, dst string) error {
+	return r.command(ctx, "cp", container+":"+src, dst).Run()
+}
+
+// CreateNetwork creates a bridge network if one does not already exist.
+func (r *CLIRuntime) CreateNetwork(ctx context.Context, name string) error {
+	if err := exec.CommandContext(ctx, r.Tool, "network", "inspect", name).Run(); err == nil {
+		return nil
+	}
+	return r.command(ctx, "network", "create", name).Run()
+}
+
+// RemoveNetwork removes a network.
+func (r *CLIRuntime) RemoveNetwork(ctx context.Context, name string) error {
+	return r.command(ctx, "network", "rm", name).Run()
+}
+
+// Kill forcibly stops and removes the named container.
+func (r *CLIRuntime) Kill(ctx context.Context, name string) error {
+	return r.command(ctx, "rm", "--force", name).Run()
+}
+
+// ShellCommand returns the command line that opens an interactive shell in the container.
+func (r *CLIRuntime) ShellCommand(containerID string) string {
+	return fmt.Sprintf("%s exec -it %s sh", r.Tool, containerID)
+}
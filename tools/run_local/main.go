@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/oss-rebuild/build/binary"
 	"github.com/google/oss-rebuild/build/container"
+	"github.com/google/oss-rebuild/pkg/rebuild/rebuild"
 	"github.com/google/oss-rebuild/tools/docker"
 )
 
@@ -40,7 +41,7 @@ func main() {
 	idchan := make(chan string)
 	log.Printf("Starting container")
 	go func() { log.Printf("Started container [ID=%s]\n", <-idchan) }()
-	err = docker.RunServer(ctx, "rebuilder", 8080, &docker.RunOptions{ID: idchan, Output: log.Writer()})
+	err = docker.RunServer(ctx, "rebuilder", 8080, &docker.RunOptions{ID: idchan, Output: log.Writer(), SecurityOpts: rebuild.Sandbox{}.SecurityOpts()})
 	if err != nil {
 		log.Fatal("Error running rebuilder: ", err.Error())
 	}